@@ -0,0 +1,295 @@
+// Command tracely-sync keeps a collection in step with a directory of YAML
+// files, so collections can be committed and reviewed with the code they
+// test.
+//
+//	tracely-sync pull -collection <id> [-environment <id>...] <dir>
+//	tracely-sync diff [-exit-code] <dir>
+//	tracely-sync push <dir>
+//
+// The API address, token and workspace come from -api, -token and
+// -workspace, or TRACELY_API_URL, TRACELY_TOKEN and TRACELY_WORKSPACE.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+type syncChange struct {
+	Action string   `json:"action"`
+	Kind   string   `json:"kind"`
+	Path   string   `json:"path"`
+	Name   string   `json:"name"`
+	Fields []string `json:"fields"`
+}
+
+type syncPlan struct {
+	CollectionID   string       `json:"collection_id"`
+	EnvironmentIDs []string     `json:"environment_ids"`
+	Changes        []syncChange `json:"changes"`
+	Warnings       []string     `json:"warnings"`
+}
+
+type client struct {
+	api, token, workspace string
+	http                  *http.Client
+}
+
+type stringList []string
+
+func (l *stringList) String() string     { return strings.Join(*l, ",") }
+func (l *stringList) Set(v string) error { *l = append(*l, v); return nil }
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	command := os.Args[1]
+
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	api := flags.String("api", envOr("TRACELY_API_URL", "http://localhost:8081/api/v1"), "API base URL")
+	token := flags.String("token", os.Getenv("TRACELY_TOKEN"), "access token")
+	workspace := flags.String("workspace", os.Getenv("TRACELY_WORKSPACE"), "workspace ID")
+	collection := flags.String("collection", "", "collection ID (pull)")
+	var environments stringList
+	flags.Var(&environments, "environment", "environment ID to include, repeatable (pull)")
+	exitCode := flags.Bool("exit-code", false, "exit with status 1 when there are changes (diff)")
+	flags.Parse(os.Args[2:])
+
+	if flags.NArg() != 1 || *token == "" || *workspace == "" {
+		usage()
+	}
+	dir := flags.Arg(0)
+	c := &client{api: strings.TrimSuffix(*api, "/"), token: *token, workspace: *workspace, http: &http.Client{Timeout: time.Minute}}
+
+	var err error
+	switch command {
+	case "pull":
+		if *collection == "" {
+			usage()
+		}
+		err = c.pull(dir, *collection, environments)
+	case "diff":
+		var plan *syncPlan
+		if plan, err = c.push(dir, true); err == nil {
+			printPlan(plan)
+			if *exitCode && len(plan.Changes) > 0 {
+				os.Exit(1)
+			}
+		}
+	case "push":
+		var plan *syncPlan
+		if plan, err = c.push(dir, false); err == nil {
+			printPlan(plan)
+			// Pull again so new items get their IDs written back
+			err = c.pull(dir, plan.CollectionID, plan.EnvironmentIDs)
+		}
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "tracely-sync:", err)
+		os.Exit(2)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `usage:
+  tracely-sync pull -collection <id> [-environment <id>...] <dir>
+  tracely-sync diff [-exit-code] <dir>
+  tracely-sync push <dir>
+
+flags -api, -token and -workspace default to TRACELY_API_URL, TRACELY_TOKEN and TRACELY_WORKSPACE`)
+	os.Exit(2)
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// pull writes the collection's files into dir and removes synced files that
+// no longer exist, leaving everything else in the directory alone.
+func (c *client) pull(dir, collectionID string, environmentIDs []string) error {
+	query := url.Values{"environment_id": environmentIDs}
+	var response struct {
+		Files map[string]string `json:"files"`
+	}
+	if err := c.do(http.MethodGet, "/collections/"+url.PathEscape(collectionID)+"/sync?"+query.Encode(), nil, &response); err != nil {
+		return err
+	}
+
+	existing, err := readSyncDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	var removed []string
+	for name := range existing {
+		if _, kept := response.Files[name]; !kept {
+			if err := os.Remove(filepath.Join(dir, filepath.FromSlash(name))); err != nil {
+				return err
+			}
+			removed = append(removed, name)
+		}
+	}
+	for name, content := range response.Files {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			return err
+		}
+		if existing[name] == content {
+			continue
+		}
+		if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			return err
+		}
+	}
+	removeEmptyDirs(dir, removed)
+	fmt.Printf("pulled %d files into %s\n", len(response.Files), dir)
+	return nil
+}
+
+// push sends the directory's files to be reconciled with the workspace.
+func (c *client) push(dir string, dryRun bool) (*syncPlan, error) {
+	files, err := readSyncDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(map[string]interface{}{"files": files})
+	if err != nil {
+		return nil, err
+	}
+	var plan syncPlan
+	if err := c.do(http.MethodPost, fmt.Sprintf("/sync?dry_run=%t", dryRun), body, &plan); err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+func (c *client) do(method, endpoint string, body []byte, result interface{}) error {
+	req, err := http.NewRequest(method, c.api+"/workspaces/"+url.PathEscape(c.workspace)+endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiError struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &apiError) == nil && apiError.Error != "" {
+			return fmt.Errorf("%s: %s", resp.Status, apiError.Error)
+		}
+		return fmt.Errorf("%s", resp.Status)
+	}
+	return json.Unmarshal(data, result)
+}
+
+// readSyncDir reads the synced files under dir, keyed by slash-separated
+// path. Hidden directories such as .git are skipped.
+func readSyncDir(dir string) (map[string]string, error) {
+	files := map[string]string{}
+	err := filepath.WalkDir(dir, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if file != dir && strings.HasPrefix(entry.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if !isSyncFile(rel) {
+			return nil
+		}
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		files[rel] = string(content)
+		return nil
+	})
+	return files, err
+}
+
+// isSyncFile reports whether a path is one the sync owns.
+func isSyncFile(rel string) bool {
+	dir, base := path.Split(rel)
+	switch {
+	case rel == "collection.yaml", base == "folder.yaml" && dir != "", strings.HasSuffix(base, ".request.yaml"):
+		return true
+	case dir == "environments/" && strings.HasSuffix(base, ".yaml"):
+		return true
+	}
+	return false
+}
+
+// removeEmptyDirs removes the directories of removed sync files, and their
+// parents, that the removal left empty. Other empty directories under dir
+// are not the sync's and stay.
+func removeEmptyDirs(dir string, removed []string) {
+	candidates := map[string]bool{}
+	for _, name := range removed {
+		for parent := path.Dir(name); parent != "."; parent = path.Dir(parent) {
+			candidates[parent] = true
+		}
+	}
+	dirs := make([]string, 0, len(candidates))
+	for candidate := range candidates {
+		dirs = append(dirs, candidate)
+	}
+	// Deepest first, so a parent is checked after its subdirectories
+	sort.Sort(sort.Reverse(sort.StringSlice(dirs)))
+	for _, d := range dirs {
+		full := filepath.Join(dir, filepath.FromSlash(d))
+		if entries, err := os.ReadDir(full); err == nil && len(entries) == 0 {
+			os.Remove(full)
+		}
+	}
+}
+
+func printPlan(plan *syncPlan) {
+	symbols := map[string]string{"create": "+", "update": "~", "delete": "-"}
+	for _, change := range plan.Changes {
+		line := fmt.Sprintf("%s %s %s", symbols[change.Action], change.Kind, change.Path)
+		if len(change.Fields) > 0 {
+			line += " (" + strings.Join(change.Fields, ", ") + ")"
+		}
+		fmt.Println(line)
+	}
+	for _, warning := range plan.Warnings {
+		fmt.Println("warning:", warning)
+	}
+	if len(plan.Changes) == 0 {
+		fmt.Println("no changes")
+	}
+}
@@ -63,13 +63,31 @@ func RunMigrations(db *gorm.DB) error {
 		return fmt.Errorf("failed to create uuid extension: %w", err)
 	}
 
+	// Pact verifications stored before they ran in the background have no
+	// status, but are finished
+	backfillPactStatus := !db.Migrator().HasColumn(&models.PactVerification{}, "Status")
+
 	// Auto migrate all models
 	err := db.AutoMigrate(
 		&models.User{},
 		&models.Workspace{},
 		&models.WorkspaceMember{},
 		&models.Collection{},
+		&models.Folder{},
 		&models.Request{},
+		&models.CollectionRun{},
+		&models.File{},
+		&models.GraphQLSchema{},
+		&models.ResponseBaseline{},
+		&models.Revision{},
+		&models.APISpec{},
+		&models.Contract{},
+		&models.ContractViolation{},
+		&models.Pact{},
+		&models.PactVerification{},
+		&models.InferredSchema{},
+		&models.SchemaDrift{},
+		&models.FuzzRun{},
 		&models.Execution{},
 		&models.Trace{},
 		&models.Span{},
@@ -90,6 +108,10 @@ func RunMigrations(db *gorm.DB) error {
 		return fmt.Errorf("failed to run migrations: %w", err)
 	}
 
+	if backfillPactStatus {
+		db.Exec("UPDATE pact_verifications SET status = CASE WHEN success THEN 'passed' ELSE 'failed' END;")
+	}
+
 	// Create indexes for better performance
 	createIndexes(db)
 
@@ -157,12 +179,16 @@ func createIndexes(db *gorm.DB) {
 	// Request indexes
 	db.Exec("CREATE INDEX IF NOT EXISTS idx_requests_collection_id ON requests(collection_id);")
 
+	// File indexes
+	db.Exec("CREATE INDEX IF NOT EXISTS idx_files_workspace_id ON files(workspace_id);")
+
 	// Execution indexes
 	db.Exec("CREATE INDEX IF NOT EXISTS idx_executions_request_id ON executions(request_id);")
 	db.Exec("CREATE INDEX IF NOT EXISTS idx_executions_trace_id ON executions(trace_id);")
 	db.Exec("CREATE INDEX IF NOT EXISTS idx_executions_span_id ON executions(span_id);")
 	db.Exec("CREATE INDEX IF NOT EXISTS idx_executions_parent_span_id ON executions(parent_span_id);")
 	db.Exec("CREATE INDEX IF NOT EXISTS idx_executions_timestamp ON executions(timestamp);")
+	db.Exec("CREATE INDEX IF NOT EXISTS idx_executions_parent_execution_id ON executions(parent_execution_id);")
 
 	// Trace indexes
 	db.Exec("CREATE INDEX IF NOT EXISTS idx_traces_workspace_id ON traces(workspace_id);")
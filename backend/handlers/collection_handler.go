@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"encoding/json"
 	"net/http"
 	"backend/middlewares"
 	"backend/services"
@@ -18,8 +19,28 @@ func NewCollectionHandler(collectionService *services.CollectionService) *Collec
 }
 
 type CreateCollectionRequest struct {
-	Name        string `json:"name" binding:"required"`
-	Description string `json:"description"`
+	Name        string                 `json:"name" binding:"required"`
+	Description string                 `json:"description"`
+	Auth        map[string]interface{}   `json:"auth"`
+	Settings    *services.ClientSettings `json:"settings"`
+}
+
+// authJSON marshals an optional auth block, returning "" when it was omitted.
+func authJSON(auth map[string]interface{}) string {
+	if auth == nil {
+		return ""
+	}
+	data, _ := json.Marshal(auth)
+	return string(data)
+}
+
+// settingsJSON marshals optional client settings, returning "" when they were omitted.
+func settingsJSON(settings *services.ClientSettings) string {
+	if settings == nil {
+		return ""
+	}
+	data, _ := json.Marshal(settings)
+	return string(data)
 }
 
 func (h *CollectionHandler) Create(c *gin.Context) {
@@ -36,12 +57,13 @@ func (h *CollectionHandler) Create(c *gin.Context) {
 		return
 	}
 
-	collection, err := h.collectionService.Create(workspaceID, req.Name, req.Description, userID)
+	collection, err := h.collectionService.Create(workspaceID, req.Name, req.Description, authJSON(req.Auth), settingsJSON(req.Settings), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	services.RedactCollection(collection)
 	c.JSON(http.StatusCreated, collection)
 }
 
@@ -59,6 +81,9 @@ func (h *CollectionHandler) GetAll(c *gin.Context) {
 		return
 	}
 
+	for i := range collections {
+		services.RedactCollection(&collections[i])
+	}
 	c.JSON(http.StatusOK, gin.H{"collections": collections})
 }
 
@@ -76,6 +101,7 @@ func (h *CollectionHandler) GetByID(c *gin.Context) {
 		return
 	}
 
+	services.RedactCollection(collection)
 	c.JSON(http.StatusOK, collection)
 }
 
@@ -93,12 +119,13 @@ func (h *CollectionHandler) Update(c *gin.Context) {
 		return
 	}
 
-	collection, err := h.collectionService.Update(collectionID, userID, req.Name, req.Description)
+	collection, err := h.collectionService.Update(collectionID, userID, req.Name, req.Description, authJSON(req.Auth), settingsJSON(req.Settings))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	services.RedactCollection(collection)
 	c.JSON(http.StatusOK, collection)
 }
 
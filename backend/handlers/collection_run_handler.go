@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"backend/middlewares"
+	"backend/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// CollectionRunHandler starts collection runs and reports on them.
+type CollectionRunHandler struct {
+	runService *services.CollectionRunService
+}
+
+func NewCollectionRunHandler(runService *services.CollectionRunService) *CollectionRunHandler {
+	return &CollectionRunHandler{runService: runService}
+}
+
+type StartRunRequest struct {
+	EnvironmentID *uuid.UUID `json:"environment_id"`
+	DataFileID    *uuid.UUID `json:"data_file_id"` // a CSV or JSON file uploaded through /files
+	Mode          string     `json:"mode"`         // sequential (default) or parallel
+	Iterations    int        `json:"iterations"`
+	Concurrency   int        `json:"concurrency"`
+	DelayMs       int        `json:"delay_ms"`
+	StopOnFailure bool       `json:"stop_on_failure"`
+}
+
+// Start runs a collection in the background and returns the pending run
+func (h *CollectionRunHandler) Start(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	collectionID, err := uuid.Parse(c.Param("collection_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid collection ID"})
+		return
+	}
+
+	var req StartRunRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	run, err := h.runService.Start(collectionID, userID, services.RunOptions{
+		EnvironmentID: req.EnvironmentID,
+		DataFileID:    req.DataFileID,
+		Mode:          req.Mode,
+		Iterations:    req.Iterations,
+		Concurrency:   req.Concurrency,
+		DelayMs:       req.DelayMs,
+		StopOnFailure: req.StopOnFailure,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, run)
+}
+
+func (h *CollectionRunHandler) List(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	collectionID, err := uuid.Parse(c.Param("collection_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid collection ID"})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	runs, err := h.runService.List(collectionID, userID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"runs": runs})
+}
+
+// Get returns a run's progress and, once finished, its report
+func (h *CollectionRunHandler) Get(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	runID, err := uuid.Parse(c.Param("run_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	run, err := h.runService.Get(runID, userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Run not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, run)
+}
+
+// Cancel stops a run in progress. Poll Get for the cancelled run's report
+func (h *CollectionRunHandler) Cancel(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	runID, err := uuid.Parse(c.Param("run_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return
+	}
+
+	if err := h.runService.Cancel(runID, userID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Run cancelled"})
+}
@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"backend/middlewares"
+	"backend/models"
+	"backend/services"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ContractHandler manages a workspace's contract registry and the
+// violations recorded against it.
+type ContractHandler struct {
+	contractService *services.ContractService
+}
+
+func NewContractHandler(contractService *services.ContractService) *ContractHandler {
+	return &ContractHandler{contractService: contractService}
+}
+
+type CreateContractRequest struct {
+	Name            string          `json:"name"`
+	RequestID       *uuid.UUID      `json:"request_id"`    // attach to a request...
+	Method          string          `json:"method"`        // ...or to a method
+	PathTemplate    string          `json:"path_template"` // and path template, e.g. /orders/{id}
+	StatusCode      string          `json:"status_code"`   // 200, 2XX, default or empty for any
+	RequestSchema   json.RawMessage `json:"request_schema"`
+	ResponseSchema  json.RawMessage `json:"response_schema"`
+	FailOnViolation bool            `json:"fail_on_violation"`
+}
+
+// GetContracts lists contracts, optionally only those of a request_id or
+// spec_id.
+func (h *ContractHandler) GetContracts(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	workspaceID, err := uuid.Parse(c.Param("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace ID"})
+		return
+	}
+	requestID, ok := optionalUUID(c, "request_id")
+	if !ok {
+		return
+	}
+	specID, ok := optionalUUID(c, "spec_id")
+	if !ok {
+		return
+	}
+
+	contracts, err := h.contractService.List(workspaceID, userID, requestID, specID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"contracts": contracts})
+}
+
+func (h *ContractHandler) CreateContract(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	workspaceID, err := uuid.Parse(c.Param("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace ID"})
+		return
+	}
+
+	var req CreateContractRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	contract, err := h.contractService.Create(workspaceID, userID, &models.Contract{
+		Name:            req.Name,
+		RequestID:       req.RequestID,
+		Method:          req.Method,
+		PathTemplate:    req.PathTemplate,
+		StatusCode:      req.StatusCode,
+		RequestSchema:   jsonText(req.RequestSchema),
+		ResponseSchema:  jsonText(req.ResponseSchema),
+		FailOnViolation: req.FailOnViolation,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"contract": contract})
+}
+
+func (h *ContractHandler) GetContract(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	workspaceID, contractID, ok := contractParams(c)
+	if !ok {
+		return
+	}
+
+	contract, err := h.contractService.GetByID(workspaceID, contractID, userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Contract not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"contract": contract})
+}
+
+// UpdateContract changes the fields present in the body. Schemas may be
+// given as JSON objects.
+func (h *ContractHandler) UpdateContract(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	workspaceID, contractID, ok := contractParams(c)
+	if !ok {
+		return
+	}
+
+	var updates map[string]interface{}
+	if err := c.ShouldBindJSON(&updates); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	for _, key := range []string{"request_schema", "response_schema"} {
+		if value, ok := updates[key]; ok && value != nil {
+			if _, isString := value.(string); !isString {
+				encoded, _ := json.Marshal(value)
+				updates[key] = string(encoded)
+			}
+		}
+	}
+
+	contract, err := h.contractService.Update(workspaceID, contractID, userID, updates)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"contract": contract})
+}
+
+func (h *ContractHandler) DeleteContract(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	workspaceID, contractID, ok := contractParams(c)
+	if !ok {
+		return
+	}
+
+	if err := h.contractService.Delete(workspaceID, contractID, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Contract deleted successfully"})
+}
+
+// GetViolations lists violations, newest first. They can be filtered by
+// contract_id, method, path, and since/until (RFC 3339), and paged with
+// limit and offset.
+func (h *ContractHandler) GetViolations(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	workspaceID, err := uuid.Parse(c.Param("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace ID"})
+		return
+	}
+
+	filter := services.ContractViolationFilter{Method: c.Query("method"), Path: c.Query("path")}
+	var ok bool
+	if filter.ContractID, ok = optionalUUID(c, "contract_id"); !ok {
+		return
+	}
+	for key, target := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if value := c.Query(key); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": key + " must be an RFC 3339 time"})
+				return
+			}
+			*target = parsed
+		}
+	}
+	filter.Limit, _ = strconv.Atoi(c.DefaultQuery("limit", "50"))
+	filter.Offset, _ = strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	violations, total, err := h.contractService.ListViolations(workspaceID, userID, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"violations": violations, "total": total})
+}
+
+// GetViolationsByEndpoint counts violations per endpoint over time_range
+// (last_hour, last_24h, last_7d or last_30d).
+func (h *ContractHandler) GetViolationsByEndpoint(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	workspaceID, err := uuid.Parse(c.Param("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace ID"})
+		return
+	}
+
+	now := time.Now()
+	since, width := services.TimeRangeWindow(c.DefaultQuery("time_range", "last_24h"), now)
+	endpoints, err := h.contractService.ViolationsByEndpoint(workspaceID, userID, since, now, width)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"endpoints": endpoints, "since": since, "bucket_seconds": int(width.Seconds())})
+}
+
+func contractParams(c *gin.Context) (workspaceID, contractID uuid.UUID, ok bool) {
+	workspaceID, err := uuid.Parse(c.Param("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace ID"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	contractID, err = uuid.Parse(c.Param("contract_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid contract ID"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	return workspaceID, contractID, true
+}
+
+// optionalUUID reads an optional UUID query param, answering 400 if it is
+// set but invalid.
+func optionalUUID(c *gin.Context, key string) (*uuid.UUID, bool) {
+	value := c.Query(key)
+	if value == "" {
+		return nil, true
+	}
+	id, err := uuid.Parse(value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + key})
+		return nil, false
+	}
+	return &id, true
+}
+
+// jsonText returns a JSON string's contents, or other JSON as text. Null and
+// missing values are empty.
+func jsonText(raw json.RawMessage) string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+	var text string
+	if json.Unmarshal(raw, &text) == nil {
+		return text
+	}
+	return string(raw)
+}
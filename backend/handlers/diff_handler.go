@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"backend/middlewares"
+	"backend/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// DiffHandler compares executions and manages request baselines.
+type DiffHandler struct {
+	diffService *services.DiffService
+}
+
+func NewDiffHandler(diffService *services.DiffService) *DiffHandler {
+	return &DiffHandler{diffService: diffService}
+}
+
+type CompareExecutionsRequest struct {
+	BaseExecutionID   uuid.UUID `json:"base_execution_id" binding:"required"`
+	TargetExecutionID uuid.UUID `json:"target_execution_id" binding:"required"`
+	services.DiffOptions
+}
+
+type SetBaselineRequest struct {
+	ExecutionID uuid.UUID `json:"execution_id" binding:"required"`
+	services.DiffOptions
+}
+
+type CompareToBaselineRequest struct {
+	ExecutionID uuid.UUID `json:"execution_id"` // unset compares the latest execution
+	services.DiffOptions
+}
+
+// Compare diffs two executions
+func (h *DiffHandler) Compare(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	workspaceID, err := uuid.Parse(c.Param("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace ID"})
+		return
+	}
+
+	var req CompareExecutionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	diff, err := h.diffService.Compare(workspaceID, userID, req.BaseExecutionID, req.TargetExecutionID, req.DiffOptions)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, diff)
+}
+
+// SetBaseline saves an execution as the request's baseline
+func (h *DiffHandler) SetBaseline(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	workspaceID, err := uuid.Parse(c.Param("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace ID"})
+		return
+	}
+	requestID, err := uuid.Parse(c.Param("request_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request ID"})
+		return
+	}
+
+	var req SetBaselineRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	baseline, err := h.diffService.SetBaseline(workspaceID, userID, requestID, req.ExecutionID, req.DiffOptions)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, baseline)
+}
+
+func (h *DiffHandler) GetBaseline(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	workspaceID, err := uuid.Parse(c.Param("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace ID"})
+		return
+	}
+	requestID, err := uuid.Parse(c.Param("request_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request ID"})
+		return
+	}
+
+	baseline, err := h.diffService.GetBaseline(workspaceID, userID, requestID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Baseline not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, baseline)
+}
+
+func (h *DiffHandler) DeleteBaseline(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	workspaceID, err := uuid.Parse(c.Param("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace ID"})
+		return
+	}
+	requestID, err := uuid.Parse(c.Param("request_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request ID"})
+		return
+	}
+
+	if err := h.diffService.DeleteBaseline(workspaceID, userID, requestID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Baseline deleted successfully"})
+}
+
+// CompareToBaseline diffs an execution, by default the latest, against the request's baseline
+func (h *DiffHandler) CompareToBaseline(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	workspaceID, err := uuid.Parse(c.Param("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace ID"})
+		return
+	}
+	requestID, err := uuid.Parse(c.Param("request_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request ID"})
+		return
+	}
+
+	var req CompareToBaselineRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	diff, err := h.diffService.CompareToBaseline(workspaceID, userID, requestID, req.ExecutionID, req.DiffOptions)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, diff)
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"backend/middlewares"
+	"backend/services"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ExportHandler serves collections in other tools' formats.
+type ExportHandler struct {
+	exportService *services.ExportService
+}
+
+func NewExportHandler(exportService *services.ExportService) *ExportHandler {
+	return &ExportHandler{exportService: exportService}
+}
+
+// Export downloads a collection. The format query param is postman
+// (default), insomnia or openapi; environment_id may be repeated to include
+// environments. The number of items the format could not carry is returned
+// in the X-Export-Warnings header.
+func (h *ExportHandler) Export(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	collectionID, err := uuid.Parse(c.Param("collection_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid collection ID"})
+		return
+	}
+
+	var environmentIDs []uuid.UUID
+	for _, value := range c.QueryArray("environment_id") {
+		for _, part := range strings.Split(value, ",") {
+			environmentID, err := uuid.Parse(strings.TrimSpace(part))
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid environment ID"})
+				return
+			}
+			environmentIDs = append(environmentIDs, environmentID)
+		}
+	}
+
+	format := c.DefaultQuery("format", services.ExportFormatPostman)
+	switch format {
+	case services.ExportFormatPostman, services.ExportFormatInsomnia, services.ExportFormatOpenAPI:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be postman, insomnia or openapi"})
+		return
+	}
+
+	file, err := h.exportService.Export(collectionID, userID, format, environmentIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="`+file.Name+`"`)
+	c.Header("X-Export-Warnings", strconv.Itoa(len(file.Warnings)))
+	c.Data(http.StatusOK, file.ContentType, file.Data)
+}
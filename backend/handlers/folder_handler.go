@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"backend/middlewares"
+	"backend/models"
+	"backend/services"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// FolderHandler manages the folder tree inside collections.
+type FolderHandler struct {
+	folderService *services.FolderService
+}
+
+func NewFolderHandler(folderService *services.FolderService) *FolderHandler {
+	return &FolderHandler{folderService: folderService}
+}
+
+// folderJSONColumns are the Folder fields persisted as JSON strings.
+var folderJSONColumns = []string{"auth", "settings", "variables"}
+
+type CreateFolderRequest struct {
+	Name        string                   `json:"name" binding:"required"`
+	Description string                   `json:"description"`
+	ParentID    *uuid.UUID               `json:"parent_id"` // unset creates the folder at the collection root
+	Auth        map[string]interface{}   `json:"auth"`
+	Settings    *services.ClientSettings `json:"settings"`
+	Variables   map[string]string        `json:"variables"`
+}
+
+type MoveRequest struct {
+	ParentID *uuid.UUID `json:"parent_id"` // folder to move into, unset for the collection root
+	Position *int       `json:"position"`  // index among the new siblings, unset appends
+}
+
+func (h *FolderHandler) Create(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	collectionID, err := uuid.Parse(c.Param("collection_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid collection ID"})
+		return
+	}
+
+	var req CreateFolderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var variablesJSON []byte
+	if req.Variables != nil {
+		variablesJSON, _ = json.Marshal(req.Variables)
+	}
+
+	folder, err := h.folderService.Create(collectionID, userID, &models.Folder{
+		ParentID:    req.ParentID,
+		Name:        req.Name,
+		Description: req.Description,
+		Auth:        authJSON(req.Auth),
+		Settings:    settingsJSON(req.Settings),
+		Variables:   string(variablesJSON),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	services.RedactFolder(folder)
+	c.JSON(http.StatusCreated, folder)
+}
+
+// GetTree returns a collection's folders and requests nested in order
+func (h *FolderHandler) GetTree(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	collectionID, err := uuid.Parse(c.Param("collection_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid collection ID"})
+		return
+	}
+
+	items, err := h.folderService.GetTree(collectionID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	services.RedactTree(items)
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
+
+func (h *FolderHandler) GetByID(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	folderID, err := uuid.Parse(c.Param("folder_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid folder ID"})
+		return
+	}
+
+	folder, err := h.folderService.GetByID(folderID, userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+		return
+	}
+
+	services.RedactFolder(folder)
+	c.JSON(http.StatusOK, folder)
+}
+
+func (h *FolderHandler) Update(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	folderID, err := uuid.Parse(c.Param("folder_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid folder ID"})
+		return
+	}
+
+	var updates map[string]interface{}
+	if err := c.ShouldBindJSON(&updates); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// JSON columns are stored as strings; accept objects from clients. An
+	// empty string is not valid JSON and clears the column instead.
+	for _, key := range folderJSONColumns {
+		if value, ok := updates[key]; ok {
+			switch v := value.(type) {
+			case nil:
+			case string:
+				if v == "" {
+					updates[key] = nil
+				}
+			default:
+				data, _ := json.Marshal(value)
+				updates[key] = string(data)
+			}
+		}
+	}
+
+	folder, err := h.folderService.Update(folderID, userID, updates)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	services.RedactFolder(folder)
+	c.JSON(http.StatusOK, folder)
+}
+
+// Delete removes a folder along with its subfolders and requests
+func (h *FolderHandler) Delete(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	folderID, err := uuid.Parse(c.Param("folder_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid folder ID"})
+		return
+	}
+
+	if err := h.folderService.Delete(folderID, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Folder deleted successfully"})
+}
+
+// Move reparents and/or reorders a folder
+func (h *FolderHandler) Move(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	folderID, err := uuid.Parse(c.Param("folder_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid folder ID"})
+		return
+	}
+
+	var req MoveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	folder, err := h.folderService.Move(folderID, userID, req.ParentID, movePosition(req.Position))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	services.RedactFolder(folder)
+	c.JSON(http.StatusOK, folder)
+}
+
+// MoveRequest moves a request into a folder, or the collection root, and/or reorders it
+func (h *FolderHandler) MoveRequest(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	requestID, err := uuid.Parse(c.Param("request_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request ID"})
+		return
+	}
+
+	var req MoveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	request, err := h.folderService.MoveRequest(requestID, userID, req.ParentID, movePosition(req.Position))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	services.RedactRequest(request)
+	c.JSON(http.StatusOK, request)
+}
+
+// Execute runs every request in the folder and its subfolders in order
+func (h *FolderHandler) Execute(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	folderID, err := uuid.Parse(c.Param("folder_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid folder ID"})
+		return
+	}
+
+	run, err := h.folderService.Execute(c.Request.Context(), folderID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, run)
+}
+
+// movePosition treats an omitted position as "append".
+func movePosition(position *int) int {
+	if position == nil {
+		return -1
+	}
+	return *position
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"backend/middlewares"
+	"backend/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// FuzzHandler starts schema-driven fuzz runs against requests and reports
+// on them.
+type FuzzHandler struct {
+	fuzzService *services.FuzzService
+}
+
+func NewFuzzHandler(fuzzService *services.FuzzService) *FuzzHandler {
+	return &FuzzHandler{fuzzService: fuzzService}
+}
+
+type StartFuzzRequest struct {
+	ContractID    *uuid.UUID `json:"contract_id"` // supplies the request schema, by default the request's contract
+	EnvironmentID *uuid.UUID `json:"environment_id"`
+	CollectionID  *uuid.UUID `json:"collection_id"` // where reproductions are saved, by default a new collection
+	MaxCases      int        `json:"max_cases"`
+	DelayMs       int        `json:"delay_ms"`
+}
+
+// Start fuzzes a request in the background and returns the pending run
+func (h *FuzzHandler) Start(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	workspaceID, requestID, ok := fuzzRequestParams(c)
+	if !ok {
+		return
+	}
+
+	var req StartFuzzRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	run, err := h.fuzzService.Start(workspaceID, requestID, userID, services.FuzzOptions{
+		ContractID:    req.ContractID,
+		EnvironmentID: req.EnvironmentID,
+		CollectionID:  req.CollectionID,
+		MaxCases:      req.MaxCases,
+		DelayMs:       req.DelayMs,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, run)
+}
+
+func (h *FuzzHandler) List(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	workspaceID, requestID, ok := fuzzRequestParams(c)
+	if !ok {
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	runs, err := h.fuzzService.List(workspaceID, requestID, userID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"runs": runs})
+}
+
+// Get returns a fuzz run's progress and, once finished, its report
+func (h *FuzzHandler) Get(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	workspaceID, err := uuid.Parse(c.Param("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace ID"})
+		return
+	}
+	runID, err := uuid.Parse(c.Param("fuzz_run_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid fuzz run ID"})
+		return
+	}
+
+	run, err := h.fuzzService.Get(workspaceID, runID, userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Fuzz run not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, run)
+}
+
+func fuzzRequestParams(c *gin.Context) (workspaceID, requestID uuid.UUID, ok bool) {
+	workspaceID, err := uuid.Parse(c.Param("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace ID"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	requestID, err = uuid.Parse(c.Param("request_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request ID"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	return workspaceID, requestID, true
+}
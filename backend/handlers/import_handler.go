@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"backend/middlewares"
+	"backend/services"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ImportHandler creates collections from other tools' exports.
+type ImportHandler struct {
+	importService *services.ImportService
+}
+
+func NewImportHandler(importService *services.ImportService) *ImportHandler {
+	return &ImportHandler{importService: importService}
+}
+
+type ImportPostmanRequest struct {
+	Collection   json.RawMessage   `json:"collection" binding:"required"` // Postman v2.1 collection export
+	Environments []json.RawMessage `json:"environments"`                  // Postman environment exports
+}
+
+// ImportPostman accepts either a JSON body or a multipart upload with a
+// "collection" file and any number of "environments" files.
+func (h *ImportHandler) ImportPostman(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	workspaceID, err := uuid.Parse(c.Param("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace ID"})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, services.MaxFileSize+1<<20)
+
+	var collection []byte
+	var environments [][]byte
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		form, err := c.MultipartForm()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if len(form.File["collection"]) != 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "exactly one collection file is required"})
+			return
+		}
+		if collection, err = readFormFile(form.File["collection"][0]); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		for _, header := range form.File["environments"] {
+			data, err := readFormFile(header)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			environments = append(environments, data)
+		}
+	} else {
+		var req ImportPostmanRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		collection = req.Collection
+		for _, environment := range req.Environments {
+			environments = append(environments, environment)
+		}
+	}
+
+	report, err := h.importService.ImportPostman(workspaceID, userID, collection, environments)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if report.Collection != nil {
+		services.RedactCollection(report.Collection)
+	}
+	c.JSON(http.StatusCreated, report)
+}
+
+// ImportOpenAPI accepts an OpenAPI 3 document, in JSON or YAML, either as
+// the request body or as a multipart upload in a "spec" file.
+func (h *ImportHandler) ImportOpenAPI(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	workspaceID, err := uuid.Parse(c.Param("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace ID"})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, services.MaxFileSize+1<<20)
+
+	var spec []byte
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		header, err := c.FormFile("spec")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "a spec file is required"})
+			return
+		}
+		if spec, err = readFormFile(header); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	} else if spec, err = io.ReadAll(c.Request.Body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report, err := h.importService.ImportOpenAPI(workspaceID, userID, spec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if report.Collection != nil {
+		services.RedactCollection(report.Collection)
+	}
+	c.JSON(http.StatusCreated, report)
+}
+
+// ImportHAR accepts a HAR archive, either as the request body or as a
+// multipart upload in a "har" file. The target query param chooses between
+// a collection (default) and a trace; include_assets=true keeps static
+// assets; name overrides the page title.
+func (h *ImportHandler) ImportHAR(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	workspaceID, err := uuid.Parse(c.Param("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace ID"})
+		return
+	}
+
+	target := c.DefaultQuery("target", "collection")
+	if target != "collection" && target != "trace" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "target must be collection or trace"})
+		return
+	}
+	options := services.HARImportOptions{
+		Name:          c.Query("name"),
+		IncludeAssets: c.Query("include_assets") == "true",
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, services.MaxFileSize+1<<20)
+
+	var archive []byte
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		header, err := c.FormFile("har")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "a har file is required"})
+			return
+		}
+		if archive, err = readFormFile(header); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	} else if archive, err = io.ReadAll(c.Request.Body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var report *services.ImportReport
+	if target == "trace" {
+		report, err = h.importService.ImportHARTrace(workspaceID, userID, archive, options)
+	} else {
+		report, err = h.importService.ImportHAR(workspaceID, userID, archive, options)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if report.Collection != nil {
+		services.RedactCollection(report.Collection)
+	}
+	c.JSON(http.StatusCreated, report)
+}
+
+func readFormFile(header *multipart.FileHeader) ([]byte, error) {
+	f, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
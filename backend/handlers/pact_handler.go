@@ -0,0 +1,292 @@
+package handlers
+
+import (
+	"backend/middlewares"
+	"backend/services"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// PactHandler manages consumer-driven contracts: imported or generated
+// Pact files and their verification results.
+type PactHandler struct {
+	pactService *services.PactService
+}
+
+func NewPactHandler(pactService *services.PactService) *PactHandler {
+	return &PactHandler{pactService: pactService}
+}
+
+type VerifyPactRequest struct {
+	BaseURL         string            `json:"base_url" binding:"required"`
+	ProviderVersion string            `json:"provider_version"`
+	StateChangeURL  string            `json:"state_change_url"`
+	StateTeardown   bool              `json:"state_teardown"`
+	Headers         map[string]string `json:"headers"`
+	Auth            json.RawMessage   `json:"auth"`
+}
+
+type GeneratePactRequest struct {
+	Consumer        string     `json:"consumer" binding:"required"`
+	Provider        string     `json:"provider" binding:"required"`
+	TimeRange       string     `json:"time_range"` // last_hour, last_24h, last_7d (default) or last_30d
+	TraceID         *uuid.UUID `json:"trace_id"`
+	Save            bool       `json:"save"`
+	ConsumerVersion string     `json:"consumer_version"`
+}
+
+// GetPacts lists pacts, optionally only those of a consumer or provider.
+func (h *PactHandler) GetPacts(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	workspaceID, err := uuid.Parse(c.Param("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace ID"})
+		return
+	}
+
+	pacts, err := h.pactService.List(workspaceID, userID, c.Query("consumer"), c.Query("provider"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"pacts": pacts})
+}
+
+// ImportPact stores a Pact file sent as the request body or as a multipart
+// upload in a "pact" file, for the consumer_version query param.
+func (h *PactHandler) ImportPact(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	workspaceID, err := uuid.Parse(c.Param("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace ID"})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, services.MaxFileSize+1<<20)
+
+	var document []byte
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		header, err := c.FormFile("pact")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "a pact file is required"})
+			return
+		}
+		if document, err = readFormFile(header); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	} else if document, err = io.ReadAll(c.Request.Body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pact, err := h.pactService.Import(workspaceID, userID, document, c.Query("consumer_version"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"pact": pact})
+}
+
+// GetPact returns a pact with its interactions.
+func (h *PactHandler) GetPact(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	workspaceID, pactID, ok := pactParams(c)
+	if !ok {
+		return
+	}
+
+	pact, err := h.pactService.GetByID(workspaceID, pactID, userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pact not found"})
+		return
+	}
+	file, err := services.ParsePact([]byte(pact.Content))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"pact": pact, "interactions": file.Interactions})
+}
+
+// GetPactFile downloads the Pact file.
+func (h *PactHandler) GetPactFile(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	workspaceID, pactID, ok := pactParams(c)
+	if !ok {
+		return
+	}
+
+	pact, err := h.pactService.GetByID(workspaceID, pactID, userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pact not found"})
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="`+services.PactFileName(pact.Consumer, pact.Provider)+`"`)
+	c.Data(http.StatusOK, "application/json", []byte(pact.Content))
+}
+
+func (h *PactHandler) DeletePact(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	workspaceID, pactID, ok := pactParams(c)
+	if !ok {
+		return
+	}
+
+	if err := h.pactService.Delete(workspaceID, pactID, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Pact deleted successfully"})
+}
+
+// VerifyPact starts replaying a pact against a provider and returns the
+// pending verification; poll GetVerification for the results. Interactions
+// that fail are reported in the results; the call itself only fails when
+// the pact cannot be verified at all.
+func (h *PactHandler) VerifyPact(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	workspaceID, pactID, ok := pactParams(c)
+	if !ok {
+		return
+	}
+
+	var req VerifyPactRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	verification, err := h.pactService.Verify(workspaceID, pactID, userID, services.PactVerifyOptions{
+		BaseURL:         req.BaseURL,
+		ProviderVersion: req.ProviderVersion,
+		StateChangeURL:  req.StateChangeURL,
+		StateTeardown:   req.StateTeardown,
+		Headers:         req.Headers,
+		Auth:            jsonText(req.Auth),
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"verification": verification})
+}
+
+// GetVerification returns a verification's progress and, once finished,
+// its results
+func (h *PactHandler) GetVerification(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	workspaceID, err := uuid.Parse(c.Param("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace ID"})
+		return
+	}
+	verificationID, err := uuid.Parse(c.Param("verification_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid verification ID"})
+		return
+	}
+
+	verification, err := h.pactService.GetVerification(workspaceID, verificationID, userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Verification not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"verification": verification})
+}
+
+// GetVerifications lists verification results, newest first. They can be
+// filtered by pact_id, consumer, provider, consumer_version and
+// provider_version, and paged with limit and offset.
+func (h *PactHandler) GetVerifications(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	workspaceID, err := uuid.Parse(c.Param("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace ID"})
+		return
+	}
+
+	filter := services.PactVerificationFilter{
+		Consumer:        c.Query("consumer"),
+		Provider:        c.Query("provider"),
+		ConsumerVersion: c.Query("consumer_version"),
+		ProviderVersion: c.Query("provider_version"),
+	}
+	var ok bool
+	if filter.PactID, ok = optionalUUID(c, "pact_id"); !ok {
+		return
+	}
+	filter.Limit, _ = strconv.Atoi(c.DefaultQuery("limit", "50"))
+	filter.Offset, _ = strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	verifications, total, err := h.pactService.ListVerifications(workspaceID, userID, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"verifications": verifications, "total": total})
+}
+
+// GeneratePact writes a Pact file from the consumer's recorded calls to the
+// provider, and stores it when save is set.
+func (h *PactHandler) GeneratePact(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	workspaceID, err := uuid.Parse(c.Param("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace ID"})
+		return
+	}
+
+	var req GeneratePactRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.TimeRange == "" {
+		req.TimeRange = "last_7d"
+	}
+
+	since, _ := services.TimeRangeWindow(req.TimeRange, time.Now())
+	file, err := h.pactService.Generate(workspaceID, userID, services.PactGenerateOptions{
+		Consumer: req.Consumer,
+		Provider: req.Provider,
+		Since:    since,
+		TraceID:  req.TraceID,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !req.Save {
+		c.JSON(http.StatusOK, gin.H{"file": file})
+		return
+	}
+
+	pact, err := h.pactService.SaveGenerated(workspaceID, userID, file, req.ConsumerVersion)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"pact": pact, "file": file})
+}
+
+func pactParams(c *gin.Context) (workspaceID, pactID uuid.UUID, ok bool) {
+	workspaceID, err := uuid.Parse(c.Param("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace ID"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	pactID, err = uuid.Parse(c.Param("pact_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pact ID"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	return workspaceID, pactID, true
+}
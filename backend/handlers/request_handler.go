@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"strconv"
 	"backend/middlewares"
+	"backend/models"
 	"backend/services"
 
 	"github.com/gin-gonic/gin"
@@ -19,14 +20,25 @@ func NewRequestHandler(requestService *services.RequestService) *RequestHandler
 	return &RequestHandler{requestService: requestService}
 }
 
+// requestJSONColumns are the Request fields persisted as JSON strings.
+var requestJSONColumns = []string{"headers", "query_params", "body", "auth", "settings", "graphql", "grpc", "stream"}
+
 type CreateRequestRequest struct {
-	Name        string                 `json:"name" binding:"required"`
-	Method      string                 `json:"method" binding:"required"`
-	URL         string                 `json:"url" binding:"required"`
-	Headers     map[string]string      `json:"headers"`
-	QueryParams map[string]string      `json:"query_params"`
-	Body        interface{}            `json:"body"`
-	Description string                 `json:"description"`
+	Name        string                   `json:"name" binding:"required"`
+	Method      string                   `json:"method" binding:"required"`
+	URL         string                   `json:"url" binding:"required"`
+	Headers     map[string]string        `json:"headers"`
+	QueryParams interface{}              `json:"query_params"` // object or array of {key, value, enabled}
+	Body        interface{}              `json:"body"`
+	BodyMode    string                   `json:"body_mode"`
+	Auth        map[string]interface{}   `json:"auth"`
+	Settings    *services.ClientSettings `json:"settings"` // client settings, unset fields inherit
+	Kind        string                   `json:"kind"`     // http (default), graphql, grpc, websocket or sse
+	GraphQL     *services.GraphQLRequest `json:"graphql"`  // query, variables and operation_name
+	GRPC        *services.GRPCRequest    `json:"grpc"`     // service, method, message and metadata
+	Stream      *services.StreamRequest  `json:"stream"`   // websocket and sse: messages, duration and assertions
+	Description string                   `json:"description"`
+	FolderID    *uuid.UUID               `json:"folder_id"` // unset adds the request at the collection root
 }
 
 func (h *RequestHandler) Create(c *gin.Context) {
@@ -47,23 +59,43 @@ func (h *RequestHandler) Create(c *gin.Context) {
 	paramsJSON, _ := json.Marshal(req.QueryParams)
 	bodyJSON, _ := json.Marshal(req.Body)
 
-	request, err := h.requestService.Create(
-		collectionID,
-		req.Name,
-		req.Method,
-		req.URL,
-		string(headersJSON),
-		string(paramsJSON),
-		string(bodyJSON),
-		req.Description,
-		userID,
-	)
+	var graphQLJSON []byte
+	if req.GraphQL != nil {
+		graphQLJSON, _ = json.Marshal(req.GraphQL)
+	}
+	var grpcJSON []byte
+	if req.GRPC != nil {
+		grpcJSON, _ = json.Marshal(req.GRPC)
+	}
+	var streamJSON []byte
+	if req.Stream != nil {
+		streamJSON, _ = json.Marshal(req.Stream)
+	}
+
+	request, err := h.requestService.Create(collectionID, &models.Request{
+		Name:        req.Name,
+		Method:      req.Method,
+		URL:         req.URL,
+		Headers:     string(headersJSON),
+		QueryParams: string(paramsJSON),
+		Body:        string(bodyJSON),
+		BodyMode:    req.BodyMode,
+		Auth:        authJSON(req.Auth),
+		Settings:    settingsJSON(req.Settings),
+		Kind:        req.Kind,
+		GraphQL:     string(graphQLJSON),
+		GRPC:        string(grpcJSON),
+		Stream:      string(streamJSON),
+		Description: req.Description,
+		FolderID:    req.FolderID,
+	}, userID)
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	services.RedactRequest(request)
 	c.JSON(http.StatusCreated, request)
 }
 
@@ -79,6 +111,9 @@ func (h *RequestHandler) GetByCollection(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	for i := range requests {
+		services.RedactRequest(&requests[i])
+	}
 	c.JSON(http.StatusOK, gin.H{"requests": requests})
 }
 
@@ -96,6 +131,7 @@ func (h *RequestHandler) GetByID(c *gin.Context) {
 		return
 	}
 
+	services.RedactRequest(request)
 	c.JSON(http.StatusOK, request)
 }
 
@@ -113,12 +149,30 @@ func (h *RequestHandler) Update(c *gin.Context) {
 		return
 	}
 
+	// JSON columns are stored as strings; accept objects from clients. An
+	// empty string is not valid JSON and clears the column instead.
+	for _, key := range requestJSONColumns {
+		if value, ok := updates[key]; ok {
+			switch v := value.(type) {
+			case nil:
+			case string:
+				if v == "" {
+					updates[key] = nil
+				}
+			default:
+				data, _ := json.Marshal(value)
+				updates[key] = string(data)
+			}
+		}
+	}
+
 	request, err := h.requestService.Update(requestID, userID, updates)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	services.RedactRequest(request)
 	c.JSON(http.StatusOK, request)
 }
 
@@ -182,7 +236,7 @@ func (h *RequestHandler) Execute(c *gin.Context) {
 		}
 	}
 
-	execution, err := h.requestService.Execute(requestID, userID, req.OverrideURL, req.OverrideHeaders, traceID, spanID, parentSpanID)
+	execution, err := h.requestService.ExecuteContext(c.Request.Context(), requestID, userID, req.OverrideURL, req.OverrideHeaders, traceID, spanID, parentSpanID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -213,3 +267,142 @@ func (h *RequestHandler) GetHistory(c *gin.Context) {
 		"total":      total,
 	})
 }
+
+// IntrospectGraphQL fetches and caches the schema of a GraphQL request's endpoint
+func (h *RequestHandler) IntrospectGraphQL(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	requestID, err := uuid.Parse(c.Param("request_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request ID"})
+		return
+	}
+
+	var environmentID *uuid.UUID
+	if envParam := c.Query("environment_id"); envParam != "" {
+		id, err := uuid.Parse(envParam)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid environment ID"})
+			return
+		}
+		environmentID = &id
+	}
+
+	schema, err := h.requestService.IntrospectGraphQL(requestID, userID, environmentID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, schema)
+}
+
+// ValidateGraphQL validates a GraphQL request against its endpoint's cached schema
+func (h *RequestHandler) ValidateGraphQL(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	requestID, err := uuid.Parse(c.Param("request_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request ID"})
+		return
+	}
+
+	var environmentID *uuid.UUID
+	if envParam := c.Query("environment_id"); envParam != "" {
+		id, err := uuid.Parse(envParam)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid environment ID"})
+			return
+		}
+		environmentID = &id
+	}
+
+	errs, err := h.requestService.ValidateGraphQL(requestID, userID, environmentID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"valid":  len(errs) == 0,
+		"errors": errs,
+	})
+}
+
+// DescribeGRPC lists the services and methods of a gRPC request's server or proto files
+func (h *RequestHandler) DescribeGRPC(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	requestID, err := uuid.Parse(c.Param("request_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request ID"})
+		return
+	}
+
+	grpcServices, err := h.requestService.DescribeGRPC(c.Request.Context(), requestID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"services": grpcServices})
+}
+
+type ImportCurlRequest struct {
+	Command string `json:"command" binding:"required"`
+	Name    string `json:"name"` // defaults to the method and URL path
+}
+
+// ImportCurl creates a request in the collection from a pasted curl command
+func (h *RequestHandler) ImportCurl(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	collectionID, err := uuid.Parse(c.Param("collection_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid collection ID"})
+		return
+	}
+
+	var req ImportCurlRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	request, err := h.requestService.ImportCurl(collectionID, userID, req.Command, req.Name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	services.RedactRequest(request)
+	c.JSON(http.StatusCreated, request)
+}
+
+// GenerateSnippet renders a request as curl, Go, Python, JavaScript or HTTPie code
+func (h *RequestHandler) GenerateSnippet(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	requestID, err := uuid.Parse(c.Param("request_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request ID"})
+		return
+	}
+
+	var environmentID *uuid.UUID
+	if envParam := c.Query("environment_id"); envParam != "" {
+		id, err := uuid.Parse(envParam)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid environment ID"})
+			return
+		}
+		environmentID = &id
+	}
+
+	language := c.DefaultQuery("language", services.SnippetCurl)
+	snippet, err := h.requestService.GenerateSnippet(requestID, userID, language, environmentID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"language": language,
+		"snippet":  snippet,
+	})
+}
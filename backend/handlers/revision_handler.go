@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"backend/middlewares"
+	"backend/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RevisionHandler exposes the change history of requests and collections.
+type RevisionHandler struct {
+	revisionService *services.RevisionService
+}
+
+func NewRevisionHandler(revisionService *services.RevisionService) *RevisionHandler {
+	return &RevisionHandler{revisionService: revisionService}
+}
+
+// GetRequestRevisions lists a request's revisions, newest first
+func (h *RevisionHandler) GetRequestRevisions(c *gin.Context) {
+	h.list(c, services.RevisionResourceRequest, "request_id", "Invalid request ID")
+}
+
+// GetCollectionRevisions lists a collection's revisions, newest first
+func (h *RevisionHandler) GetCollectionRevisions(c *gin.Context) {
+	h.list(c, services.RevisionResourceCollection, "collection_id", "Invalid collection ID")
+}
+
+func (h *RevisionHandler) list(c *gin.Context, resourceType, param, invalidMessage string) {
+	userID, _ := middlewares.GetUserID(c)
+	workspaceID, err := uuid.Parse(c.Param("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace ID"})
+		return
+	}
+	resourceID, err := uuid.Parse(c.Param(param))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": invalidMessage})
+		return
+	}
+
+	revisions, err := h.revisionService.List(workspaceID, userID, resourceType, resourceID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"revisions": revisions})
+}
+
+func (h *RevisionHandler) GetRevision(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	workspaceID, err := uuid.Parse(c.Param("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace ID"})
+		return
+	}
+	revisionID, err := uuid.Parse(c.Param("revision_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid revision ID"})
+		return
+	}
+
+	revision, err := h.revisionService.Get(workspaceID, userID, revisionID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Revision not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, revision)
+}
+
+// DiffRevision compares a revision with ?against=<revision_id>, by default the one before it
+func (h *RevisionHandler) DiffRevision(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	workspaceID, err := uuid.Parse(c.Param("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace ID"})
+		return
+	}
+	revisionID, err := uuid.Parse(c.Param("revision_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid revision ID"})
+		return
+	}
+
+	var againstID *uuid.UUID
+	if against := c.Query("against"); against != "" {
+		id, err := uuid.Parse(against)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid revision ID"})
+			return
+		}
+		againstID = &id
+	}
+
+	diff, err := h.revisionService.Diff(workspaceID, userID, revisionID, againstID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, diff)
+}
+
+// RestoreRevision rolls a request or collection back to a revision
+func (h *RevisionHandler) RestoreRevision(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	workspaceID, err := uuid.Parse(c.Param("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace ID"})
+		return
+	}
+	revisionID, err := uuid.Parse(c.Param("revision_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid revision ID"})
+		return
+	}
+
+	revision, err := h.revisionService.Restore(workspaceID, userID, revisionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, revision)
+}
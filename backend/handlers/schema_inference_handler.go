@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"backend/middlewares"
+	"backend/services"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// SchemaInferenceHandler exposes the JSON Schemas learned from observed
+// response bodies, their versions and the bodies that drifted from them.
+type SchemaInferenceHandler struct {
+	schemaService *services.SchemaInferenceService
+}
+
+func NewSchemaInferenceHandler(schemaService *services.SchemaInferenceService) *SchemaInferenceHandler {
+	return &SchemaInferenceHandler{schemaService: schemaService}
+}
+
+type LearnSchemasRequest struct {
+	TimeRange string `json:"time_range"` // last_hour, last_24h, last_7d (default) or last_30d
+}
+
+// GetSchemas lists the latest schema of each endpoint, optionally only
+// those of a method or path template.
+func (h *SchemaInferenceHandler) GetSchemas(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	workspaceID, err := uuid.Parse(c.Param("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace ID"})
+		return
+	}
+
+	schemas, err := h.schemaService.List(workspaceID, userID, c.Query("method"), c.Query("path"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"schemas": schemas})
+}
+
+// LearnSchemas relearns every endpoint's schema from the executions and
+// spans of time_range.
+func (h *SchemaInferenceHandler) LearnSchemas(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	workspaceID, err := uuid.Parse(c.Param("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace ID"})
+		return
+	}
+
+	var req LearnSchemasRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.TimeRange == "" {
+		req.TimeRange = "last_7d"
+	}
+
+	since, _ := services.TimeRangeWindow(req.TimeRange, time.Now())
+	schemas, err := h.schemaService.Learn(workspaceID, userID, since)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"schemas": schemas})
+}
+
+func (h *SchemaInferenceHandler) GetSchema(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	workspaceID, schemaID, ok := inferredSchemaParams(c)
+	if !ok {
+		return
+	}
+
+	schema, err := h.schemaService.GetByID(workspaceID, schemaID, userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Schema not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"schema": schema})
+}
+
+// GetSchemaVersions lists every version of a schema's endpoint, newest
+// first.
+func (h *SchemaInferenceHandler) GetSchemaVersions(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	workspaceID, schemaID, ok := inferredSchemaParams(c)
+	if !ok {
+		return
+	}
+
+	versions, err := h.schemaService.Versions(workspaceID, schemaID, userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Schema not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"versions": versions})
+}
+
+// GetDrifts lists bodies that did not fit their endpoint's schema, newest
+// first. They can be filtered by schema_id, method, path, and since/until
+// (RFC 3339), and paged with limit and offset.
+func (h *SchemaInferenceHandler) GetDrifts(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	workspaceID, err := uuid.Parse(c.Param("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace ID"})
+		return
+	}
+
+	filter := services.SchemaDriftFilter{Method: c.Query("method"), PathTemplate: c.Query("path")}
+	var ok bool
+	if filter.SchemaID, ok = optionalUUID(c, "schema_id"); !ok {
+		return
+	}
+	for key, target := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if value := c.Query(key); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": key + " must be an RFC 3339 time"})
+				return
+			}
+			*target = parsed
+		}
+	}
+	filter.Limit, _ = strconv.Atoi(c.DefaultQuery("limit", "50"))
+	filter.Offset, _ = strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	drifts, total, err := h.schemaService.ListDrifts(workspaceID, userID, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"drifts": drifts, "total": total})
+}
+
+func inferredSchemaParams(c *gin.Context) (workspaceID, schemaID uuid.UUID, ok bool) {
+	workspaceID, err := uuid.Parse(c.Param("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace ID"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	schemaID, err = uuid.Parse(c.Param("schema_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid schema ID"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	return workspaceID, schemaID, true
+}
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"backend/middlewares"
+	"backend/services"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// SpecHandler serves stored API descriptions and validates calls against
+// them.
+type SpecHandler struct {
+	specService *services.SpecService
+}
+
+func NewSpecHandler(specService *services.SpecService) *SpecHandler {
+	return &SpecHandler{specService: specService}
+}
+
+// ValidateSpecRequest is either a stored execution or a call described
+// inline. Headers that are left out are not checked; bodies may be given
+// as JSON values.
+type ValidateSpecRequest struct {
+	ExecutionID     *uuid.UUID        `json:"execution_id"`
+	Method          string            `json:"method"`
+	URL             string            `json:"url"`
+	RequestHeaders  map[string]string `json:"request_headers"`
+	RequestBody     json.RawMessage   `json:"request_body"`
+	StatusCode      int               `json:"status_code"` // 0 to check the request only
+	ResponseHeaders map[string]string `json:"response_headers"`
+	ResponseBody    json.RawMessage   `json:"response_body"`
+}
+
+func (h *SpecHandler) GetSpecs(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	workspaceID, err := uuid.Parse(c.Param("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace ID"})
+		return
+	}
+
+	specs, err := h.specService.List(workspaceID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"specs": specs})
+}
+
+func (h *SpecHandler) GetSpec(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	workspaceID, specID, ok := specParams(c)
+	if !ok {
+		return
+	}
+
+	spec, err := h.specService.GetByID(workspaceID, specID, userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Spec not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"spec": spec})
+}
+
+// CreateSpec stores a new version of a spec from an OpenAPI 3 document, in
+// JSON or YAML, sent as the request body or as a multipart upload in a
+// "spec" file. When an earlier version exists the response includes what
+// changed since it, with the traffic of the last 7 days.
+func (h *SpecHandler) CreateSpec(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	workspaceID, err := uuid.Parse(c.Param("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace ID"})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, services.MaxFileSize+1<<20)
+
+	var document []byte
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		header, err := c.FormFile("spec")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "a spec file is required"})
+			return
+		}
+		if document, err = readFormFile(header); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	} else if document, err = io.ReadAll(c.Request.Body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	spec, err := h.specService.Create(workspaceID, userID, document)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := gin.H{"spec": spec}
+	since, _ := services.TimeRangeWindow("last_7d", time.Now())
+	if diff, err := h.specService.Diff(workspaceID, spec.ID, userID, nil, since); err == nil {
+		response["diff"] = diff
+	}
+	c.JSON(http.StatusCreated, response)
+}
+
+// GetDiff compares a spec with base_id, or by default with its previous
+// version. Breaking changes show how often their endpoint was called over
+// time_range (last_hour, last_24h, last_7d or last_30d).
+func (h *SpecHandler) GetDiff(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	workspaceID, specID, ok := specParams(c)
+	if !ok {
+		return
+	}
+	baseID, ok := optionalUUID(c, "base_id")
+	if !ok {
+		return
+	}
+
+	since, _ := services.TimeRangeWindow(c.DefaultQuery("time_range", "last_7d"), time.Now())
+	diff, err := h.specService.Diff(workspaceID, specID, userID, baseID, since)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, diff)
+}
+
+// Validate checks a call against the spec's operations and reports each
+// problem with JSON pointers into the spec and the payload.
+func (h *SpecHandler) Validate(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	workspaceID, specID, ok := specParams(c)
+	if !ok {
+		return
+	}
+
+	var req ValidateSpecRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var result *services.OpenAPIValidation
+	var err error
+	if req.ExecutionID != nil {
+		result, err = h.specService.ValidateExecution(workspaceID, specID, *req.ExecutionID, userID)
+	} else {
+		if req.Method == "" || req.URL == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "execution_id, or method and url, are required"})
+			return
+		}
+		result, err = h.specService.Validate(workspaceID, specID, userID, services.OpenAPIExchange{
+			Method:          req.Method,
+			URL:             req.URL,
+			RequestHeaders:  httpHeader(req.RequestHeaders),
+			RequestBody:     jsonText(req.RequestBody),
+			StatusCode:      req.StatusCode,
+			ResponseHeaders: httpHeader(req.ResponseHeaders),
+			ResponseBody:    jsonText(req.ResponseBody),
+		})
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func specParams(c *gin.Context) (workspaceID, specID uuid.UUID, ok bool) {
+	workspaceID, err := uuid.Parse(c.Param("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace ID"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	specID, err = uuid.Parse(c.Param("spec_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid spec ID"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	return workspaceID, specID, true
+}
+
+// httpHeader converts headers from a request body, keeping nil as nil so
+// they are not checked.
+func httpHeader(headers map[string]string) http.Header {
+	if headers == nil {
+		return nil
+	}
+	converted := http.Header{}
+	for key, value := range headers {
+		converted.Add(key, value)
+	}
+	return converted
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"backend/middlewares"
+	"backend/services"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// SyncHandler keeps collections in step with YAML files kept in version
+// control.
+type SyncHandler struct {
+	syncService *services.CollectionSyncService
+}
+
+func NewSyncHandler(syncService *services.CollectionSyncService) *SyncHandler {
+	return &SyncHandler{syncService: syncService}
+}
+
+type SyncFilesRequest struct {
+	Files map[string]string `json:"files" binding:"required"` // file contents keyed by path relative to the collection directory
+}
+
+// Pull returns a collection as YAML files keyed by path. environment_id may
+// be repeated to include environments; secret values are never included.
+func (h *SyncHandler) Pull(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	collectionID, err := uuid.Parse(c.Param("collection_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid collection ID"})
+		return
+	}
+
+	var environmentIDs []uuid.UUID
+	for _, value := range c.QueryArray("environment_id") {
+		for _, part := range strings.Split(value, ",") {
+			environmentID, err := uuid.Parse(strings.TrimSpace(part))
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid environment ID"})
+				return
+			}
+			environmentIDs = append(environmentIDs, environmentID)
+		}
+	}
+
+	files, err := h.syncService.Serialize(collectionID, userID, environmentIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"files": files})
+}
+
+// Push reconciles the workspace with the posted files. With dry_run=true
+// nothing is changed and the plan lists what would be.
+func (h *SyncHandler) Push(c *gin.Context) {
+	userID, _ := middlewares.GetUserID(c)
+	workspaceID, err := uuid.Parse(c.Param("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace ID"})
+		return
+	}
+
+	dryRun := false
+	if value := c.Query("dry_run"); value != "" {
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "dry_run must be true or false"})
+			return
+		}
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, services.MaxFileSize)
+	var req SyncFilesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	plan, err := h.syncService.Reconcile(workspaceID, userID, req.Files, dryRun)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
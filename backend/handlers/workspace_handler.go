@@ -32,6 +32,14 @@ type CreateWorkspaceRequest struct {
 	Description string `json:"description"`
 }
 
+// UpdateWorkspaceRequest defines the payload for updating a workspace.
+// Settings are the default client settings for every request in the workspace.
+type UpdateWorkspaceRequest struct {
+	Name        string                   `json:"name" binding:"required"`
+	Description string                   `json:"description"`
+	Settings    *services.ClientSettings `json:"settings"`
+}
+
 // Create handles POST /workspaces
 // It creates a new workspace for the authenticated user
 func (h *WorkspaceHandler) Create(c *gin.Context) {
@@ -115,13 +123,13 @@ func (h *WorkspaceHandler) Update(c *gin.Context) {
 		return
 	}
 
-	var req CreateWorkspaceRequest
+	var req UpdateWorkspaceRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 	// Update workspace via service
-	workspace, err := h.workspaceService.Update(workspaceID, userID, req.Name, req.Description)
+	workspace, err := h.workspaceService.Update(workspaceID, userID, req.Name, req.Description, settingsJSON(req.Settings))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -52,7 +52,6 @@ func (c *CloudWatchIntegration) PutMetric(metricName string, value float64, unit
 
 // GetMetricStatistics retrieves aggregated statistics for a CloudWatch metric over a specified time range. 
 // The caller specifies the aggregation period and the statistic to compute.
-func (c *CloudWatchIntegration) GetMetricStatistics(
 func (c *CloudWatchIntegration) GetMetricStatistics(metricName string, start, end time.Time, period int32, statistic types.Statistic) ([]types.Datapoint, error) {
 	result, err := c.client.GetMetricStatistics(context.TODO(), &cloudwatch.GetMetricStatisticsInput{
 		Namespace:  aws.String(c.namespace),
@@ -0,0 +1,133 @@
+package integrations
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// HAR is an HTTP Archive, as exported by browser devtools and proxies.
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+type HARLog struct {
+	Version string `json:"version"`
+	Creator struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	} `json:"creator"`
+	Pages   []HARPage  `json:"pages"`
+	Entries []HAREntry `json:"entries"`
+}
+
+type HARPage struct {
+	ID              string    `json:"id"`
+	Title           string    `json:"title"`
+	StartedDateTime time.Time `json:"startedDateTime"`
+}
+
+// HAREntry is one request and its response.
+type HAREntry struct {
+	Pageref         string      `json:"pageref"`
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"` // total milliseconds, -1 if unknown
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	ServerIPAddress string      `json:"serverIPAddress"`
+	ResourceType    string      `json:"_resourceType"` // Chrome only: document, xhr, fetch, script, stylesheet, image, font, ...
+}
+
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData"`
+}
+
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+}
+
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HARPostData is a request body. Params is set for form bodies; files in
+// multipart bodies have a FileName and usually no value.
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Params   []struct {
+		Name        string `json:"name"`
+		Value       string `json:"value"`
+		FileName    string `json:"fileName"`
+		ContentType string `json:"contentType"`
+	} `json:"params"`
+}
+
+type HARContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Encoding string `json:"encoding"` // base64 for binary bodies
+}
+
+// Body returns the response body, decoding base64 content.
+func (c HARContent) Body() ([]byte, error) {
+	if c.Encoding == "base64" {
+		return base64.StdEncoding.DecodeString(c.Text)
+	}
+	return []byte(c.Text), nil
+}
+
+// Header returns the first request header with the given name, ignoring case.
+func (r HARRequest) Header(name string) string {
+	return harHeader(r.Headers, name)
+}
+
+// Header returns the first response header with the given name, ignoring case.
+func (r HARResponse) Header(name string) string {
+	return harHeader(r.Headers, name)
+}
+
+func harHeader(headers []HARNameValue, name string) string {
+	for _, header := range headers {
+		if strings.EqualFold(header.Name, name) {
+			return header.Value
+		}
+	}
+	return ""
+}
+
+type HARImporter struct{}
+
+func NewHARImporter() *HARImporter {
+	return &HARImporter{}
+}
+
+// Parse decodes a HAR 1.1 or 1.2 archive.
+func (h *HARImporter) Parse(data []byte) (*HAR, error) {
+	var archive HAR
+	if err := json.Unmarshal(data, &archive); err != nil {
+		return nil, fmt.Errorf("invalid HAR: %w", err)
+	}
+	if archive.Log.Entries == nil {
+		return nil, errors.New("not a HAR archive: missing log.entries")
+	}
+	if archive.Log.Version != "" && !strings.HasPrefix(archive.Log.Version, "1.") {
+		return nil, fmt.Errorf("unsupported HAR version: %s", archive.Log.Version)
+	}
+	return &archive, nil
+}
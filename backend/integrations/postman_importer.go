@@ -2,45 +2,268 @@ package integrations
 
 import (
 	"encoding/json"
-	"io/ioutil"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
 )
 
-// PostmanCollection represents the top-level structure of a Postman collection JSON file, including metadata and request items.
+// PostmanCollection represents the top-level structure of a Postman v2.1 collection JSON file.
 type PostmanCollection struct {
 	Info struct {
-		Name        string `json:"name"`
-		Description string `json:"description"`
+		Name        string             `json:"name"`
+		Description PostmanDescription `json:"description"`
+		Schema      string             `json:"schema"`
 	} `json:"info"`
-	Item []PostmanItem `json:"item"`
+	Item     []PostmanItem     `json:"item"`
+	Auth     *PostmanAuth      `json:"auth"`
+	Variable []PostmanVariable `json:"variable"`
+	Event    []PostmanEvent    `json:"event"`
 }
 
-// PostmanItem represents a single request entry in a Postman collection.
+// PostmanItem is either a request or, when Item is set, a folder of further items.
 type PostmanItem struct {
-	Name    string         `json:"name"`
-	Request PostmanRequest `json:"request"`
+	Name        string             `json:"name"`
+	Description PostmanDescription `json:"description"`
+	Request     *PostmanRequest    `json:"request"`
+	Response    []json.RawMessage  `json:"response"`
+	Event       []PostmanEvent     `json:"event"`
+
+	// Folder fields
+	Item     []PostmanItem     `json:"item"`
+	Auth     *PostmanAuth      `json:"auth"`
+	Variable []PostmanVariable `json:"variable"`
+
+	ProtocolProfileBehavior map[string]interface{} `json:"protocolProfileBehavior"`
+}
+
+// IsFolder reports whether the item groups other items rather than holding a request.
+func (i PostmanItem) IsFolder() bool {
+	return i.Request == nil && i.Item != nil
 }
 
 // PostmanRequest models the structure of an HTTP request as defined by Postman.
 type PostmanRequest struct {
-	// Method is the HTTP method. 
+	// Method is the HTTP method.
 	Method string `json:"method"`
 
-	// URL contains the raw request URL.
-	URL    struct {
-		Raw string `json:"raw"`
-	} `json:"url"`
+	// URL contains the raw request URL and its parsed parts.
+	URL PostmanURL `json:"url"`
 
 	// Header represents the list of HTTP headers for the request.
-	Header []struct {
-		Key   string `json:"key"`
-		Value string `json:"value"`
-	} `json:"header"`.
+	Header []PostmanKeyValue `json:"header"`
 
 	// Body contains request body configuration
-	Body struct {
-		Mode string `json:"mode"`
-		Raw  string `json:"raw"`
-	} `json:"body"`
+	Body *PostmanBody `json:"body"`
+
+	Auth        *PostmanAuth       `json:"auth"`
+	Description PostmanDescription `json:"description"`
+}
+
+// UnmarshalJSON accepts the shorthand where a request is just its URL.
+func (r *PostmanRequest) UnmarshalJSON(data []byte) error {
+	var raw string
+	if json.Unmarshal(data, &raw) == nil {
+		*r = PostmanRequest{Method: "GET", URL: PostmanURL{Raw: raw}}
+		return nil
+	}
+
+	type request PostmanRequest
+	var decoded struct {
+		request
+		Header json.RawMessage `json:"header"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*r = PostmanRequest(decoded.request)
+
+	// Headers may also be given as a raw "Key: value" block
+	var block string
+	if json.Unmarshal(decoded.Header, &block) == nil {
+		for _, line := range strings.Split(block, "\n") {
+			key, value, ok := strings.Cut(line, ":")
+			if ok && strings.TrimSpace(key) != "" {
+				r.Header = append(r.Header, PostmanKeyValue{Key: strings.TrimSpace(key), Value: strings.TrimSpace(value)})
+			}
+		}
+		return nil
+	}
+	if len(decoded.Header) > 0 && string(decoded.Header) != "null" {
+		return json.Unmarshal(decoded.Header, &r.Header)
+	}
+	return nil
+}
+
+// PostmanURL is a request URL. Raw holds the URL as typed, including {{variables}}.
+type PostmanURL struct {
+	Raw      string            `json:"raw"`
+	Query    []PostmanKeyValue `json:"query"`
+	Variable []PostmanKeyValue `json:"variable"` // values for :name path segments
+}
+
+// UnmarshalJSON accepts a URL given as a plain string.
+func (u *PostmanURL) UnmarshalJSON(data []byte) error {
+	var raw string
+	if json.Unmarshal(data, &raw) == nil {
+		*u = PostmanURL{Raw: raw}
+		return nil
+	}
+	type postmanURL PostmanURL
+	return json.Unmarshal(data, (*postmanURL)(u))
+}
+
+// PostmanKeyValue is a header, query param, form field or path variable.
+type PostmanKeyValue struct {
+	Key         string             `json:"key"`
+	Value       string             `json:"value"`
+	Disabled    bool               `json:"disabled"`
+	Type        string             `json:"type"`        // form data: text or file
+	Src         interface{}        `json:"src"`         // form data file path(s)
+	ContentType string             `json:"contentType"` // form data part content type
+	Description PostmanDescription `json:"description"`
+}
+
+// PostmanBody is a request body in one of Postman's modes: raw, urlencoded,
+// formdata, file or graphql.
+type PostmanBody struct {
+	Mode       string            `json:"mode"`
+	Raw        string            `json:"raw"`
+	URLEncoded []PostmanKeyValue `json:"urlencoded"`
+	FormData   []PostmanKeyValue `json:"formdata"`
+	File       *struct {
+		Src string `json:"src"`
+	} `json:"file"`
+	GraphQL *struct {
+		Query     string `json:"query"`
+		Variables string `json:"variables"`
+	} `json:"graphql"`
+	Options struct {
+		Raw struct {
+			Language string `json:"language"` // json, xml, text, javascript, html
+		} `json:"raw"`
+	} `json:"options"`
+	Disabled bool `json:"disabled"`
+}
+
+// PostmanAuth holds the auth type and the attributes of each auth type, e.g.
+// Params["bearer"]["token"].
+type PostmanAuth struct {
+	Type   string
+	Params map[string]map[string]string
+}
+
+func (a *PostmanAuth) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	a.Params = map[string]map[string]string{}
+	for name, raw := range fields {
+		if name == "type" {
+			if err := json.Unmarshal(raw, &a.Type); err != nil {
+				return err
+			}
+			continue
+		}
+		var attributes []struct {
+			Key   string      `json:"key"`
+			Value interface{} `json:"value"`
+		}
+		if json.Unmarshal(raw, &attributes) != nil {
+			continue
+		}
+		params := map[string]string{}
+		for _, attribute := range attributes {
+			params[attribute.Key] = postmanString(attribute.Value)
+		}
+		a.Params[name] = params
+	}
+	return nil
+}
+
+// Get returns an attribute of the configured auth type.
+func (a *PostmanAuth) Get(key string) string {
+	return a.Params[a.Type][key]
+}
+
+// PostmanVariable is a collection, folder or environment variable.
+type PostmanVariable struct {
+	Key      string
+	Value    string
+	Type     string // environments: default or secret
+	Disabled bool
+}
+
+func (v *PostmanVariable) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Key      string      `json:"key"`
+		ID       string      `json:"id"`
+		Value    interface{} `json:"value"`
+		Type     string      `json:"type"`
+		Disabled bool        `json:"disabled"`
+		Enabled  *bool       `json:"enabled"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*v = PostmanVariable{Key: raw.Key, Value: postmanString(raw.Value), Type: raw.Type, Disabled: raw.Disabled}
+	if v.Key == "" {
+		v.Key = raw.ID
+	}
+	if raw.Enabled != nil && !*raw.Enabled {
+		v.Disabled = true
+	}
+	return nil
+}
+
+// PostmanEvent is a pre-request or test script.
+type PostmanEvent struct {
+	Listen string `json:"listen"` // prerequest, test
+	Script struct {
+		Exec interface{} `json:"exec"` // a line array or a single string
+	} `json:"script"`
+	Disabled bool `json:"disabled"`
+}
+
+// HasCode reports whether the script contains anything besides whitespace.
+func (e PostmanEvent) HasCode() bool {
+	switch exec := e.Script.Exec.(type) {
+	case string:
+		return strings.TrimSpace(exec) != ""
+	case []interface{}:
+		for _, line := range exec {
+			if text, ok := line.(string); ok && strings.TrimSpace(text) != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// PostmanDescription accepts both a plain string and a {"content": ...} object.
+type PostmanDescription string
+
+func (d *PostmanDescription) UnmarshalJSON(data []byte) error {
+	var text string
+	if json.Unmarshal(data, &text) == nil {
+		*d = PostmanDescription(text)
+		return nil
+	}
+	var object struct {
+		Content string `json:"content"`
+	}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return err
+	}
+	*d = PostmanDescription(object.Content)
+	return nil
+}
+
+// PostmanEnvironment represents an exported Postman environment file.
+type PostmanEnvironment struct {
+	Name   string            `json:"name"`
+	Values []PostmanVariable `json:"values"`
 }
 
 type PostmanImporter struct{}
@@ -49,45 +272,53 @@ func NewPostmanImporter() *PostmanImporter {
 	return &PostmanImporter{}
 }
 
-// ImportFromFile reads a Postman collection JSON file from disk and unmarshals it into a PostmanCollection structure.
+// ImportFromFile reads a Postman collection JSON file from disk and parses it.
 func (p *PostmanImporter) ImportFromFile(filepath string) (*PostmanCollection, error) {
-	// Read the collection file.
-	data, err := ioutil.ReadFile(filepath)
+	data, err := os.ReadFile(filepath)
 	if err != nil {
 		return nil, err
 	}
-	
-	// Parse JSON into PostmanCollection struct.
+	return p.Parse(data)
+}
+
+// Parse decodes a Postman v2.0 or v2.1 collection export.
+func (p *PostmanImporter) Parse(data []byte) (*PostmanCollection, error) {
 	var collection PostmanCollection
 	if err := json.Unmarshal(data, &collection); err != nil {
-		return nil, err
+		return nil, fmt.Errorf("invalid Postman collection: %w", err)
 	}
 
+	// v1 exports have a top-level "requests" array and no info block
+	if collection.Info.Name == "" && collection.Item == nil {
+		return nil, errors.New("not a Postman v2 collection: export it as Collection v2.1")
+	}
+	if collection.Info.Schema != "" && !strings.Contains(collection.Info.Schema, "/v2.") {
+		return nil, fmt.Errorf("unsupported Postman collection schema: %s", collection.Info.Schema)
+	}
 	return &collection, nil
 }
 
-// ConvertToRequests converts a Postman collection into a generic slice of request definitions that can be used by other APIs.
-func (p *PostmanImporter) ConvertToRequests(collection *PostmanCollection) []map[string]interface{} {
-	requests := []map[string]interface{}{}
-
-	for _, item := range collection.Item {
-		// Convert headers into a key-value map.
-		headers := make(map[string]string)
-		for _, h := range item.Request.Header {
-			headers[h.Key] = h.Value
-		}
-
-		// Normalize request into a generic structure.
-		request := map[string]interface{}{
-			"name":    item.Name,
-			"method":  item.Request.Method,
-			"url":     item.Request.URL.Raw,
-			"headers": headers,
-			"body":    item.Request.Body.Raw,
-		}
-
-		requests = append(requests, request)
+// ParseEnvironment decodes a Postman environment export.
+func (p *PostmanImporter) ParseEnvironment(data []byte) (*PostmanEnvironment, error) {
+	var environment PostmanEnvironment
+	if err := json.Unmarshal(data, &environment); err != nil {
+		return nil, fmt.Errorf("invalid Postman environment: %w", err)
+	}
+	if environment.Name == "" && environment.Values == nil {
+		return nil, errors.New("not a Postman environment export")
 	}
+	return &environment, nil
+}
 
-	return requests
+// postmanString renders variable values, which Postman allows to be any JSON type.
+func postmanString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		encoded, _ := json.Marshal(v)
+		return string(encoded)
+	}
 }
@@ -52,7 +52,16 @@ func main() {
 	authService := services.NewAuthService(db, cfg)
 	workspaceService := services.NewWorkspaceService(db)
 	collectionService := services.NewCollectionService(db)
-	requestService := services.NewRequestService(db)
+	secretsService := services.NewSecretsService(db, cfg.JWTSecret)
+	requestService := services.NewRequestService(db, secretsService)
+	folderService := services.NewFolderService(db, requestService)
+	collectionRunService := services.NewCollectionRunService(db, requestService)
+	if failed, err := collectionRunService.FailUnfinished(); err != nil {
+		log.Printf("Failed to close unfinished collection runs: %v", err)
+	} else if failed > 0 {
+		log.Printf("Marked %d unfinished collection run(s) as failed", failed)
+	}
+	fuzzService := services.NewFuzzService(db, requestService)
 	traceService := services.NewTraceService(db)
 	waterfallService := services.NewWaterfallService(db)
 	tracingConfigService := services.NewTracingConfigService(db)
@@ -60,17 +69,34 @@ func main() {
 	governanceService := services.NewGovernanceService(db)
 	replayService := services.NewReplayService(db)
 	mockService := services.NewMockService(db)
-	workflowService := services.NewWorkflowService(db)
+	workflowService := services.NewWorkflowService(db, requestService)
 	environmentService := services.NewEnvironmentService(db)
-	secretsService := services.NewSecretsService(db, cfg.JWTSecret)
 	settingsService := services.NewSettingsService(db)
 	alertingService := services.NewAlertingService(db)
-	loadTestService := services.NewLoadTestService(db)
+	loadTestService := services.NewLoadTestService(db, requestService)
+	fileService := services.NewFileService(db)
+	importService := services.NewImportService(db, requestService, folderService)
+	exportService := services.NewExportService(db, folderService)
+	syncService := services.NewCollectionSyncService(db, folderService)
+	contractService := services.NewContractService(db)
+	specService := services.NewSpecService(db)
+	pactService := services.NewPactService(db, requestService)
+	if failed, err := pactService.FailUnfinished(); err != nil {
+		log.Printf("Failed to close unfinished pact verifications: %v", err)
+	} else if failed > 0 {
+		log.Printf("Marked %d unfinished pact verification(s) as failed", failed)
+	}
+	schemaInferenceService := services.NewSchemaInferenceService(db)
+	diffService := services.NewDiffService(db)
+	revisionService := services.NewRevisionService(db)
 
 	// Handlers
 	authHandler := handlers.NewAuthHandler(authService)
 	workspaceHandler := handlers.NewWorkspaceHandler(workspaceService)
 	collectionHandler := handlers.NewCollectionHandler(collectionService)
+	folderHandler := handlers.NewFolderHandler(folderService)
+	collectionRunHandler := handlers.NewCollectionRunHandler(collectionRunService)
+	fuzzHandler := handlers.NewFuzzHandler(fuzzService)
 	requestHandler := handlers.NewRequestHandler(requestService)
 	traceHandler := handlers.NewTraceHandler(traceService, waterfallService)
 	tracingConfigHandler := handlers.NewTracingConfigHandler(tracingConfigService)
@@ -84,6 +110,16 @@ func main() {
 	settingsHandler := handlers.NewSettingsHandler(settingsService)
 	alertHandler := handlers.NewAlertHandler(alertingService)
 	loadTestHandler := handlers.NewLoadTestHandler(loadTestService)
+	fileHandler := handlers.NewFileHandler(fileService)
+	importHandler := handlers.NewImportHandler(importService)
+	exportHandler := handlers.NewExportHandler(exportService)
+	syncHandler := handlers.NewSyncHandler(syncService)
+	contractHandler := handlers.NewContractHandler(contractService)
+	specHandler := handlers.NewSpecHandler(specService)
+	pactHandler := handlers.NewPactHandler(pactService)
+	schemaInferenceHandler := handlers.NewSchemaInferenceHandler(schemaInferenceService)
+	diffHandler := handlers.NewDiffHandler(diffService)
+	revisionHandler := handlers.NewRevisionHandler(revisionService)
 
 	api := router.Group("/api/v1")
 	{
@@ -120,14 +156,106 @@ func main() {
 				w.PUT("/collections/:collection_id", collectionHandler.Update)
 				w.DELETE("/collections/:collection_id", collectionHandler.Delete)
 
+				// Folders
+				w.GET("/collections/:collection_id/tree", folderHandler.GetTree)
+				w.POST("/collections/:collection_id/folders", folderHandler.Create)
+				w.GET("/folders/:folder_id", folderHandler.GetByID)
+				w.PUT("/folders/:folder_id", folderHandler.Update)
+				w.DELETE("/folders/:folder_id", folderHandler.Delete)
+				w.POST("/folders/:folder_id/move", folderHandler.Move)
+				w.POST("/folders/:folder_id/execute", folderHandler.Execute)
+				w.POST("/requests/:request_id/move", folderHandler.MoveRequest)
+
+				// Collection runs
+				w.POST("/collections/:collection_id/runs", collectionRunHandler.Start)
+				w.GET("/collections/:collection_id/runs", collectionRunHandler.List)
+				w.GET("/runs/:run_id", collectionRunHandler.Get)
+				w.POST("/runs/:run_id/cancel", collectionRunHandler.Cancel)
+
 				// Requests (under collection)
 				w.POST("/collections/:collection_id/requests", requestHandler.Create)
 				w.GET("/collections/:collection_id/requests", requestHandler.GetByCollection)
+				w.POST("/collections/:collection_id/requests/import/curl", requestHandler.ImportCurl)
 				w.GET("/requests/:request_id", requestHandler.GetByID)
 				w.PUT("/requests/:request_id", requestHandler.Update)
 				w.DELETE("/requests/:request_id", requestHandler.Delete)
 				w.POST("/requests/:request_id/execute", requestHandler.Execute)
 				w.GET("/requests/:request_id/history", requestHandler.GetHistory)
+				w.POST("/requests/:request_id/graphql/introspect", requestHandler.IntrospectGraphQL)
+				w.POST("/requests/:request_id/graphql/validate", requestHandler.ValidateGraphQL)
+				w.GET("/requests/:request_id/grpc/services", requestHandler.DescribeGRPC)
+				w.GET("/requests/:request_id/snippet", requestHandler.GenerateSnippet)
+
+				// Schema-driven fuzzing
+				w.POST("/requests/:request_id/fuzz", fuzzHandler.Start)
+				w.GET("/requests/:request_id/fuzz", fuzzHandler.List)
+				w.GET("/fuzz-runs/:fuzz_run_id", fuzzHandler.Get)
+
+				// Response diffs and baselines
+				w.POST("/executions/diff", diffHandler.Compare)
+				w.GET("/requests/:request_id/baseline", diffHandler.GetBaseline)
+				w.PUT("/requests/:request_id/baseline", diffHandler.SetBaseline)
+				w.DELETE("/requests/:request_id/baseline", diffHandler.DeleteBaseline)
+				w.POST("/requests/:request_id/baseline/diff", diffHandler.CompareToBaseline)
+
+				// Revision history
+				w.GET("/requests/:request_id/revisions", revisionHandler.GetRequestRevisions)
+				w.GET("/collections/:collection_id/revisions", revisionHandler.GetCollectionRevisions)
+				w.GET("/revisions/:revision_id", revisionHandler.GetRevision)
+				w.GET("/revisions/:revision_id/diff", revisionHandler.DiffRevision)
+				w.POST("/revisions/:revision_id/restore", revisionHandler.RestoreRevision)
+
+				// Files (multipart and binary request bodies)
+				w.GET("/files", fileHandler.GetAll)
+				w.POST("/files", fileHandler.Upload)
+				w.GET("/files/:file_id", fileHandler.Download)
+				w.DELETE("/files/:file_id", fileHandler.Delete)
+
+				// Imports
+				w.POST("/import/postman", importHandler.ImportPostman)
+				w.POST("/import/openapi", importHandler.ImportOpenAPI)
+				w.POST("/import/har", importHandler.ImportHAR)
+
+				// Exports
+				w.GET("/collections/:collection_id/export", exportHandler.Export)
+
+				// Git sync of collections as YAML files
+				w.GET("/collections/:collection_id/sync", syncHandler.Pull)
+				w.POST("/sync", syncHandler.Push)
+
+				// Contracts
+				w.GET("/contracts", contractHandler.GetContracts)
+				w.POST("/contracts", contractHandler.CreateContract)
+				w.GET("/contracts/violations", contractHandler.GetViolations)
+				w.GET("/contracts/violations/endpoints", contractHandler.GetViolationsByEndpoint)
+				w.GET("/contracts/:contract_id", contractHandler.GetContract)
+				w.PUT("/contracts/:contract_id", contractHandler.UpdateContract)
+				w.DELETE("/contracts/:contract_id", contractHandler.DeleteContract)
+
+				// API specs
+				w.GET("/specs", specHandler.GetSpecs)
+				w.POST("/specs", specHandler.CreateSpec)
+				w.GET("/specs/:spec_id", specHandler.GetSpec)
+				w.GET("/specs/:spec_id/diff", specHandler.GetDiff)
+				w.POST("/specs/:spec_id/validate", specHandler.Validate)
+
+				// Consumer-driven contracts (Pact)
+				w.GET("/pacts", pactHandler.GetPacts)
+				w.POST("/pacts", pactHandler.ImportPact)
+				w.POST("/pacts/generate", pactHandler.GeneratePact)
+				w.GET("/pacts/verifications", pactHandler.GetVerifications)
+				w.GET("/pacts/verifications/:verification_id", pactHandler.GetVerification)
+				w.GET("/pacts/:pact_id", pactHandler.GetPact)
+				w.GET("/pacts/:pact_id/file", pactHandler.GetPactFile)
+				w.DELETE("/pacts/:pact_id", pactHandler.DeletePact)
+				w.POST("/pacts/:pact_id/verify", pactHandler.VerifyPact)
+
+				// JSON Schemas inferred from observed responses
+				w.GET("/inferred-schemas", schemaInferenceHandler.GetSchemas)
+				w.POST("/inferred-schemas/learn", schemaInferenceHandler.LearnSchemas)
+				w.GET("/inferred-schemas/drifts", schemaInferenceHandler.GetDrifts)
+				w.GET("/inferred-schemas/:schema_id", schemaInferenceHandler.GetSchema)
+				w.GET("/inferred-schemas/:schema_id/versions", schemaInferenceHandler.GetSchemaVersions)
 
 				// Traces
 				w.GET("/traces", traceHandler.GetTraces)
@@ -27,6 +27,7 @@ type Workspace struct {
 	OwnerID     uuid.UUID         `gorm:"type:uuid;not null" json:"owner_id"`
 	Owner       User              `gorm:"foreignKey:OwnerID" json:"owner,omitempty"`
 	Members     []WorkspaceMember `gorm:"foreignKey:WorkspaceID" json:"members,omitempty"`
+	Settings    string            `gorm:"type:jsonb;serializer:jsonnull" json:"settings"` // JSON string, client settings inherited by collections
 	CreatedAt   time.Time         `json:"created_at"`
 	UpdatedAt   time.Time         `json:"updated_at"`
 	DeletedAt   gorm.DeletedAt    `gorm:"index" json:"-"`
@@ -51,6 +52,8 @@ type Collection struct {
 	WorkspaceID  uuid.UUID      `gorm:"type:uuid;not null" json:"workspace_id"`
 	Workspace    Workspace      `gorm:"foreignKey:WorkspaceID" json:"workspace,omitempty"`
 	RequestCount int            `gorm:"default:0" json:"request_count"`
+	Auth         string         `gorm:"type:jsonb;serializer:jsonnull" json:"auth"`     // JSON string, inherited by requests
+	Settings     string         `gorm:"type:jsonb;serializer:jsonnull" json:"settings"` // JSON string, client settings inherited by requests
 	CreatedAt    time.Time      `json:"created_at"`
 	UpdatedAt    time.Time      `json:"updated_at"`
 	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
@@ -62,15 +65,99 @@ type Request struct {
 	Name         string         `gorm:"not null" json:"name"`
 	Method       string         `gorm:"not null" json:"method"` // GET, POST, PUT, DELETE, PATCH, etc.
 	URL          string         `gorm:"not null" json:"url"`
-	Headers      string         `gorm:"type:jsonb" json:"headers"`      // JSON string
-	QueryParams  string         `gorm:"type:jsonb" json:"query_params"` // JSON string
-	Body         string         `gorm:"type:jsonb" json:"body"`         // JSON string
+	Headers      string         `gorm:"type:jsonb" json:"headers"`                                    // JSON string
+	QueryParams  string         `gorm:"type:jsonb" json:"query_params"`                               // JSON string
+	Body         string         `gorm:"type:jsonb" json:"body"`                                       // JSON string
+	BodyMode     string         `json:"body_mode"`                                                    // raw, json, xml, text, urlencoded, formdata, binary, none
+	Auth         string         `gorm:"type:jsonb;serializer:jsonnull" json:"auth"`                   // JSON string, empty inherits from collection
+	Settings     string         `gorm:"type:jsonb;serializer:jsonnull" json:"settings"`               // JSON string, overrides collection client settings
+	Kind         string         `json:"kind"`                                                         // http (default), graphql, grpc, websocket, sse
+	GraphQL      string         `gorm:"column:graphql;type:jsonb;serializer:jsonnull" json:"graphql"` // JSON string: query, variables, operation_name
+	GRPC         string         `gorm:"column:grpc;type:jsonb;serializer:jsonnull" json:"grpc"`       // JSON string: service, method, message, metadata, proto_file_ids
+	Stream       string         `gorm:"type:jsonb;serializer:jsonnull" json:"stream"`                 // JSON string: scripted messages, duration and assertions for websocket and sse
 	Description  string         `json:"description"`
 	CollectionID uuid.UUID      `gorm:"type:uuid;not null" json:"collection_id"`
 	Collection   Collection     `gorm:"foreignKey:CollectionID" json:"collection,omitempty"`
+	FolderID     *uuid.UUID     `gorm:"type:uuid;index" json:"folder_id"` // nil at the collection root
+	Position     int            `gorm:"default:0" json:"position"`        // order among the folders and requests sharing its parent
 	CreatedAt    time.Time      `json:"created_at"`
 	UpdatedAt    time.Time      `json:"updated_at"`
 	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
+
+	// Folders is the chain of folders from the collection root down to the
+	// request's folder, loaded to resolve inherited auth, settings and variables
+	Folders []Folder `gorm:"-" json:"-"`
+}
+
+// Folder groups requests inside a collection. Folders nest, and each level's
+// auth, settings and variables apply to everything beneath it.
+type Folder struct {
+	ID           uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	CollectionID uuid.UUID      `gorm:"type:uuid;not null;index" json:"collection_id"`
+	Collection   Collection     `gorm:"foreignKey:CollectionID" json:"collection,omitempty"`
+	ParentID     *uuid.UUID     `gorm:"type:uuid;index" json:"parent_id"` // nil at the collection root
+	Name         string         `gorm:"not null" json:"name"`
+	Description  string         `json:"description"`
+	Position     int            `gorm:"default:0" json:"position"`                       // order among the folders and requests sharing its parent
+	Auth         string         `gorm:"type:jsonb;serializer:jsonnull" json:"auth"`      // JSON string, empty inherits from the parent
+	Settings     string         `gorm:"type:jsonb;serializer:jsonnull" json:"settings"`  // JSON string, client settings inherited by contents
+	Variables    string         `gorm:"type:jsonb;serializer:jsonnull" json:"variables"` // JSON object of {{variable}} values, inner folders win
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// CollectionRun runs every request in a collection in order, once per iteration
+type CollectionRun struct {
+	ID            uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	WorkspaceID   uuid.UUID  `gorm:"type:uuid;not null;index" json:"workspace_id"`
+	CollectionID  uuid.UUID  `gorm:"type:uuid;not null;index" json:"collection_id"`
+	EnvironmentID *uuid.UUID `gorm:"type:uuid" json:"environment_id,omitempty"`
+	DataFileID    *uuid.UUID `gorm:"type:uuid" json:"data_file_id,omitempty"`   // uploaded CSV or JSON file, one row per iteration
+	Mode          string     `gorm:"not null;default:'sequential'" json:"mode"` // sequential, parallel
+	Iterations    int        `gorm:"not null" json:"iterations"`
+	Concurrency   int        `gorm:"default:1" json:"concurrency"` // iterations in flight in parallel mode
+	DelayMs       int        `gorm:"default:0" json:"delay_ms"`    // pause between requests
+	StopOnFailure bool       `json:"stop_on_failure"`
+	Status        string     `gorm:"default:'pending'" json:"status"` // pending, running, passed, failed, cancelled
+	Stopped       bool       `json:"stopped"`                         // ended early by stop_on_failure or a cancel
+	TotalRequests int        `json:"total_requests"`
+	Passed        int        `json:"passed"`
+	Failed        int        `json:"failed"`
+	Report        string     `gorm:"type:jsonb;serializer:jsonnull" json:"report,omitempty"` // JSON, per-iteration results
+	CreatedBy     uuid.UUID  `gorm:"type:uuid" json:"created_by"`
+	CreatedAt     time.Time  `json:"created_at"`
+	StartedAt     *time.Time `json:"started_at,omitempty"`
+	CompletedAt   *time.Time `json:"completed_at,omitempty"`
+}
+
+// GraphQLSchema caches the introspection result for a GraphQL endpoint
+type GraphQLSchema struct {
+	ID            uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	WorkspaceID   uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_graphql_schemas_workspace_endpoint" json:"workspace_id"`
+	Endpoint      string    `gorm:"not null;uniqueIndex:idx_graphql_schemas_workspace_endpoint" json:"endpoint"`
+	Introspection string    `gorm:"type:jsonb;serializer:jsonnull" json:"introspection"`
+	FetchedAt     time.Time `json:"fetched_at"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// TableName keeps gorm from naming the table graph_ql_schemas
+func (GraphQLSchema) TableName() string {
+	return "graphql_schemas"
+}
+
+// File represents an uploaded file used by multipart and binary request bodies
+type File struct {
+	ID          uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	WorkspaceID uuid.UUID      `gorm:"type:uuid;not null" json:"workspace_id"`
+	Name        string         `gorm:"not null" json:"name"`
+	ContentType string         `json:"content_type"`
+	Size        int64          `json:"size"`
+	Data        []byte         `gorm:"type:bytea" json:"-"`
+	CreatedBy   uuid.UUID      `gorm:"type:uuid" json:"created_by"`
+	CreatedAt   time.Time      `json:"created_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 // Execution represents a request execution
@@ -89,6 +176,210 @@ type Execution struct {
 	Timestamp       time.Time      `gorm:"not null" json:"timestamp"`
 	CreatedAt       time.Time      `json:"created_at"`
 	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
+
+	// Timing breakdown and connection details captured with net/http/httptrace
+	DNSLookupMs       float64    `json:"dns_lookup_ms"`
+	TCPConnectMs      float64    `json:"tcp_connect_ms"`
+	TLSHandshakeMs    float64    `json:"tls_handshake_ms"`
+	TimeToFirstByteMs float64    `json:"time_to_first_byte_ms"` // request written -> first response byte
+	ContentTransferMs float64    `json:"content_transfer_ms"`   // first response byte -> body fully read
+	ConnectionReused  bool       `json:"connection_reused"`
+	RemoteAddress     string     `json:"remote_address,omitempty"`
+	Protocol          string     `json:"protocol,omitempty"` // HTTP/1.1, HTTP/2.0, gRPC, WebSocket, SSE
+	TLSVersion        string     `json:"tls_version,omitempty"`
+	TLSCipherSuite    string     `json:"tls_cipher_suite,omitempty"`
+	TLSCertExpiresAt  *time.Time `json:"tls_cert_expires_at,omitempty"`                                                        // earliest expiry in the peer chain
+	RedirectChain     string     `gorm:"type:jsonb;serializer:jsonnull" json:"redirect_chain,omitempty"`                       // JSON array of followed redirects
+	GraphQLErrors     string     `gorm:"column:graphql_errors;type:jsonb;serializer:jsonnull" json:"graphql_errors,omitempty"` // JSON array, validation or response errors
+	GRPCStatus        string     `gorm:"column:grpc_status" json:"grpc_status,omitempty"`                                      // gRPC code name, e.g. OK, NotFound
+	ResponseTrailers  string     `gorm:"type:jsonb;serializer:jsonnull" json:"response_trailers,omitempty"`                    // JSON string, gRPC trailer metadata
+	Transcript        string     `gorm:"type:jsonb;serializer:jsonnull" json:"transcript,omitempty"`                           // JSON array of WebSocket frames or SSE events
+	AssertionResults  string     `gorm:"type:jsonb;serializer:jsonnull" json:"assertion_results,omitempty"`                    // JSON array, stream assertions checked against the transcript
+
+	// Retries: the logical execution carries the final outcome and each attempt
+	// is stored as a child execution
+	ParentExecutionID *uuid.UUID  `gorm:"type:uuid" json:"parent_execution_id,omitempty"`
+	Attempt           int         `json:"attempt,omitempty"` // 1-based, set on attempts
+	AttemptCount      int         `gorm:"default:1" json:"attempt_count"`
+	Attempts          []Execution `gorm:"foreignKey:ParentExecutionID" json:"attempts,omitempty"`
+}
+
+// ResponseBaseline is the execution a request's later responses are diffed against
+type ResponseBaseline struct {
+	ID            uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	RequestID     uuid.UUID `gorm:"type:uuid;not null;uniqueIndex" json:"request_id"`
+	ExecutionID   uuid.UUID `gorm:"type:uuid;not null" json:"execution_id"`
+	Execution     Execution `gorm:"foreignKey:ExecutionID" json:"execution,omitempty"`
+	IgnorePaths   string    `gorm:"type:jsonb" json:"ignore_paths"`   // JSON array of body paths skipped when diffing
+	IgnoreHeaders string    `gorm:"type:jsonb" json:"ignore_headers"` // JSON array of header names skipped when diffing
+	CreatedBy     uuid.UUID `gorm:"type:uuid" json:"created_by"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// Revision is a snapshot of a request or collection taken on every change
+type Revision struct {
+	ID           uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	WorkspaceID  uuid.UUID `gorm:"type:uuid;not null;index" json:"workspace_id"`
+	ResourceType string    `gorm:"not null;uniqueIndex:idx_revisions_resource_version" json:"resource_type"` // request, collection
+	ResourceID   uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_revisions_resource_version" json:"resource_id"`
+	Version      int       `gorm:"not null;uniqueIndex:idx_revisions_resource_version" json:"version"` // 1-based, per resource
+	Action       string    `gorm:"not null" json:"action"`                                             // create, update, delete, restore
+	Snapshot     string    `gorm:"type:jsonb" json:"snapshot"`                                         // JSON object of the resource's fields after the change
+	AuthorID     uuid.UUID `gorm:"type:uuid" json:"author_id"`
+	Author       User      `gorm:"foreignKey:AuthorID" json:"author,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// APISpec is a stored API description document, one row per version
+type APISpec struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	WorkspaceID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"workspace_id"`
+	CollectionID   *uuid.UUID `gorm:"type:uuid;index" json:"collection_id,omitempty"` // collection generated from the spec
+	Name           string     `gorm:"not null;index" json:"name"`                     // info.title, shared by the spec's versions
+	Version        string     `json:"version"`                                        // info.version
+	Format         string     `gorm:"not null" json:"format"`                         // openapi
+	FormatVersion  string     `json:"format_version"`                                 // e.g. 3.0.3
+	OperationCount int        `json:"operation_count"`
+	Content        string     `gorm:"type:jsonb" json:"-"` // the document as JSON
+	CreatedBy      uuid.UUID  `gorm:"type:uuid" json:"created_by"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// Contract is the request and response schema an endpoint is expected to honour
+type Contract struct {
+	ID              uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	WorkspaceID     uuid.UUID      `gorm:"type:uuid;not null;index" json:"workspace_id"`
+	RequestID       *uuid.UUID     `gorm:"type:uuid;index" json:"request_id,omitempty"`
+	SpecID          *uuid.UUID     `gorm:"type:uuid;index" json:"spec_id,omitempty"`
+	Name            string         `json:"name"`
+	Method          string         `gorm:"not null" json:"method"`
+	PathTemplate    string         `gorm:"not null" json:"path_template"` // e.g. /orders/{id}
+	StatusCode      string         `json:"status_code"`                   // 200, 2XX or default
+	RequestSchema   string         `gorm:"type:jsonb;serializer:jsonnull" json:"request_schema"`
+	ResponseSchema  string         `gorm:"type:jsonb;serializer:jsonnull" json:"response_schema"`
+	Source          string         `json:"source"`                                 // openapi, manual
+	FailOnViolation bool           `gorm:"default:false" json:"fail_on_violation"` // violations fail the execution's assertions
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// ContractViolation is an execution or ingested span that did not honour a
+// contract. Method and Path name the endpoint as it was when the violation
+// was seen, so history survives the contract being edited or deleted.
+type ContractViolation struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	WorkspaceID uuid.UUID  `gorm:"type:uuid;not null;index:idx_contract_violations_workspace_time" json:"workspace_id"`
+	ContractID  uuid.UUID  `gorm:"type:uuid;not null;index" json:"contract_id"`
+	Source      string     `gorm:"not null" json:"source"` // execution or span
+	ExecutionID *uuid.UUID `gorm:"type:uuid;index" json:"execution_id,omitempty"`
+	TraceID     *uuid.UUID `gorm:"type:uuid" json:"trace_id,omitempty"`
+	SpanID      *uuid.UUID `gorm:"type:uuid" json:"span_id,omitempty"`
+	Method      string     `json:"method"`
+	Path        string     `json:"path"` // the contract's path template, or the request path for request contracts
+	StatusCode  int        `json:"status_code"`
+	Errors      string     `gorm:"type:jsonb" json:"errors"` // JSON array of validation errors
+	CreatedAt   time.Time  `gorm:"index:idx_contract_violations_workspace_time" json:"created_at"`
+}
+
+// Pact is a consumer-driven contract: the interactions a consumer expects
+// from a provider, imported from a Pact file or generated from traces
+type Pact struct {
+	ID               uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	WorkspaceID      uuid.UUID `gorm:"type:uuid;not null;index" json:"workspace_id"`
+	Consumer         string    `gorm:"not null;index" json:"consumer"`
+	Provider         string    `gorm:"not null;index" json:"provider"`
+	ConsumerVersion  string    `json:"consumer_version"`
+	SpecVersion      string    `json:"spec_version"` // Pact specification version, e.g. 3.0.0
+	InteractionCount int       `json:"interaction_count"`
+	Source           string    `json:"source"`              // import, traces
+	Content          string    `gorm:"type:jsonb" json:"-"` // the Pact file as JSON
+	CreatedBy        uuid.UUID `gorm:"type:uuid" json:"created_by"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// PactVerification is the outcome of replaying a pact's interactions against
+// one version of its provider
+type PactVerification struct {
+	ID              uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	WorkspaceID     uuid.UUID  `gorm:"type:uuid;not null;index" json:"workspace_id"`
+	PactID          uuid.UUID  `gorm:"type:uuid;not null;index" json:"pact_id"`
+	Consumer        string     `gorm:"not null" json:"consumer"`
+	Provider        string     `gorm:"not null;index" json:"provider"`
+	ConsumerVersion string     `json:"consumer_version"`
+	ProviderVersion string     `gorm:"index" json:"provider_version"`
+	BaseURL         string     `json:"base_url"`
+	Status          string     `gorm:"default:'pending'" json:"status"` // pending, running, passed, failed
+	Success         bool       `json:"success"`
+	Passed          int        `json:"passed"` // interactions verified so far
+	Failed          int        `json:"failed"`
+	Results         string     `gorm:"type:jsonb" json:"results"` // JSON array of interaction results, once finished
+	DurationMs      int64      `json:"duration_ms"`
+	CreatedBy       uuid.UUID  `gorm:"type:uuid" json:"created_by"`
+	CreatedAt       time.Time  `json:"created_at"`
+	StartedAt       *time.Time `json:"started_at,omitempty"`
+	CompletedAt     *time.Time `json:"completed_at,omitempty"`
+}
+
+// InferredSchema is a JSON Schema learned from the response bodies an
+// endpoint returned with one status, one row per version
+type InferredSchema struct {
+	ID           uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	WorkspaceID  uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_inferred_schemas_endpoint_version" json:"workspace_id"`
+	Method       string    `gorm:"not null;uniqueIndex:idx_inferred_schemas_endpoint_version" json:"method"`
+	PathTemplate string    `gorm:"not null;uniqueIndex:idx_inferred_schemas_endpoint_version" json:"path_template"` // e.g. /orders/{orderId}
+	StatusCode   int       `gorm:"uniqueIndex:idx_inferred_schemas_endpoint_version" json:"status_code"`
+	Version      int       `gorm:"not null;uniqueIndex:idx_inferred_schemas_endpoint_version" json:"version"`
+	Schema       string    `gorm:"type:jsonb" json:"schema"`
+	Stats        string    `gorm:"type:jsonb" json:"-"` // learner counts, to keep learning from new samples
+	SampleCount  int       `json:"sample_count"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// SchemaDrift is an execution or ingested span whose response body did not
+// have the shape of its endpoint's inferred schema
+type SchemaDrift struct {
+	ID               uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	WorkspaceID      uuid.UUID  `gorm:"type:uuid;not null;index:idx_schema_drifts_workspace_time" json:"workspace_id"`
+	InferredSchemaID uuid.UUID  `gorm:"type:uuid;not null;index" json:"inferred_schema_id"` // the version drifted from
+	Version          int        `json:"version"`
+	Source           string     `gorm:"not null" json:"source"` // execution or span
+	ExecutionID      *uuid.UUID `gorm:"type:uuid;index" json:"execution_id,omitempty"`
+	TraceID          *uuid.UUID `gorm:"type:uuid" json:"trace_id,omitempty"`
+	SpanID           *uuid.UUID `gorm:"type:uuid" json:"span_id,omitempty"`
+	Method           string     `json:"method"`
+	PathTemplate     string     `json:"path_template"`
+	StatusCode       int        `json:"status_code"`
+	Changes          string     `gorm:"type:jsonb" json:"changes"` // JSON array of drift changes
+	CreatedAt        time.Time  `gorm:"index:idx_schema_drifts_workspace_time" json:"created_at"`
+}
+
+// FuzzRun sends payloads generated from a contract's request schema to a
+// request's endpoint and reports the server errors, response contract
+// violations and crashes they cause. Minimized reproductions of each finding
+// are saved as requests in CollectionID.
+type FuzzRun struct {
+	ID               uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	WorkspaceID      uuid.UUID  `gorm:"type:uuid;not null;index" json:"workspace_id"`
+	RequestID        uuid.UUID  `gorm:"type:uuid;not null;index" json:"request_id"`
+	ContractID       uuid.UUID  `gorm:"type:uuid;not null" json:"contract_id"` // supplies the request schema
+	EnvironmentID    *uuid.UUID `gorm:"type:uuid" json:"environment_id,omitempty"`
+	CollectionID     *uuid.UUID `gorm:"type:uuid" json:"collection_id,omitempty"` // reproductions, once there are findings
+	MaxCases         int        `json:"max_cases"`
+	DelayMs          int        `gorm:"default:0" json:"delay_ms"`       // pause between requests
+	Status           string     `gorm:"default:'pending'" json:"status"` // pending, running, passed, failed
+	TotalCases       int        `json:"total_cases"`                     // cases sent so far
+	ServerErrors     int        `json:"server_errors"`
+	SchemaViolations int        `json:"schema_violations"`
+	Crashes          int        `json:"crashes"`
+	Error            string     `json:"error,omitempty"`                                        // why the run ended early
+	Report           string     `gorm:"type:jsonb;serializer:jsonnull" json:"report,omitempty"` // JSON, per-case results and findings
+	CreatedBy        uuid.UUID  `gorm:"type:uuid" json:"created_by"`
+	CreatedAt        time.Time  `json:"created_at"`
+	StartedAt        *time.Time `json:"started_at,omitempty"`
+	CompletedAt      *time.Time `json:"completed_at,omitempty"`
 }
 
 // Trace represents a distributed trace
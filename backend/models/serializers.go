@@ -0,0 +1,36 @@
+package models
+
+import (
+	"context"
+	"reflect"
+
+	"gorm.io/gorm/schema"
+)
+
+func init() {
+	schema.RegisterSerializer("jsonnull", JSONNullSerializer{})
+}
+
+// JSONNullSerializer stores an empty string field as NULL and reads NULL back
+// as an empty string. Postgres rejects an empty string in a jsonb column, so string fields
+// holding optional JSON use it: `gorm:"type:jsonb;serializer:jsonnull"`.
+// Updates with a map bypass serializers and must write NULL themselves.
+type JSONNullSerializer struct{}
+
+func (JSONNullSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var value string
+	switch v := dbValue.(type) {
+	case []byte:
+		value = string(v)
+	case string:
+		value = v
+	}
+	return field.Set(ctx, dst, value)
+}
+
+func (JSONNullSerializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	if value, _ := fieldValue.(string); value != "" {
+		return value, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,565 @@
+package services
+
+import (
+	"backend/models"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Collection run modes and statuses.
+const (
+	RunModeSequential = "sequential"
+	RunModeParallel   = "parallel"
+
+	RunStatusPending   = "pending"
+	RunStatusRunning   = "running"
+	RunStatusPassed    = "passed"
+	RunStatusFailed    = "failed"
+	RunStatusCancelled = "cancelled"
+)
+
+// Limits on a single run.
+const (
+	maxRunIterations      = 1000
+	maxRunConcurrency     = 20
+	maxRunDelayMs         = 60000
+	defaultRunConcurrency = 4
+)
+
+// RunOptions configures a collection run. Zero values take the defaults.
+type RunOptions struct {
+	EnvironmentID *uuid.UUID
+	DataFileID    *uuid.UUID
+	Mode          string // sequential (default) or parallel
+	Iterations    int    // defaults to one per data row, or 1 without a data file
+	Concurrency   int    // parallel mode only, defaults to 4
+	DelayMs       int
+	StopOnFailure bool
+}
+
+// RunReport is stored on a finished run.
+type RunReport struct {
+	Iterations        []RunIteration `json:"iterations"`
+	AssertionsPassed  int            `json:"assertions_passed"`
+	AssertionsFailed  int            `json:"assertions_failed"`
+	AvgResponseTimeMs float64        `json:"avg_response_time_ms"`
+	DurationMs        int64          `json:"duration_ms"`
+}
+
+type RunIteration struct {
+	Iteration int               `json:"iteration"` // 1-based
+	TraceID   uuid.UUID         `json:"trace_id"`  // shared by the iteration's executions
+	Data      map[string]string `json:"data,omitempty"`
+	Results   []RunResult       `json:"results"`
+	Passed    int               `json:"passed"`
+	Failed    int               `json:"failed"`
+}
+
+type RunResult struct {
+	RequestID      uuid.UUID               `json:"request_id"`
+	Name           string                  `json:"name"`
+	Path           string                  `json:"path"` // enclosing folder names, e.g. "Orders/Refunds"
+	ExecutionID    *uuid.UUID              `json:"execution_id,omitempty"`
+	StatusCode     int                     `json:"status_code"`
+	ResponseTimeMs int64                   `json:"response_time_ms"`
+	Error          string                  `json:"error,omitempty"`
+	Assertions     []StreamAssertionResult `json:"assertions,omitempty"`
+	Passed         bool                    `json:"passed"`
+}
+
+// runStep is a request ready to execute, with its folders attached.
+type runStep struct {
+	request *models.Request
+	path    string
+}
+
+type CollectionRunService struct {
+	db               *gorm.DB
+	workspaceService *WorkspaceService
+	requestService   *RequestService
+
+	mu      sync.Mutex
+	cancels map[uuid.UUID]context.CancelFunc // runs in progress on this instance
+}
+
+func NewCollectionRunService(db *gorm.DB, requestService *RequestService) *CollectionRunService {
+	return &CollectionRunService{
+		db:               db,
+		workspaceService: NewWorkspaceService(db),
+		requestService:   requestService,
+		cancels:          map[uuid.UUID]context.CancelFunc{},
+	}
+}
+
+// Start validates the options and runs the collection in the background.
+// Poll Get for progress and the report.
+func (s *CollectionRunService) Start(collectionID, userID uuid.UUID, options RunOptions) (*models.CollectionRun, error) {
+	var collection models.Collection
+	if err := s.db.First(&collection, collectionID).Error; err != nil {
+		return nil, err
+	}
+	if !s.workspaceService.HasAccess(collection.WorkspaceID, userID) {
+		return nil, errors.New("access denied")
+	}
+
+	if options.Mode == "" {
+		options.Mode = RunModeSequential
+	}
+	if options.Mode != RunModeSequential && options.Mode != RunModeParallel {
+		return nil, fmt.Errorf("unsupported run mode: %s", options.Mode)
+	}
+	if options.Iterations < 0 || options.Iterations > maxRunIterations {
+		return nil, fmt.Errorf("iterations must be between 1 and %d", maxRunIterations)
+	}
+	if options.DelayMs < 0 || options.DelayMs > maxRunDelayMs {
+		return nil, fmt.Errorf("delay_ms must be between 0 and %d", maxRunDelayMs)
+	}
+	if options.Concurrency < 0 || options.Concurrency > maxRunConcurrency {
+		return nil, fmt.Errorf("concurrency must be between 1 and %d", maxRunConcurrency)
+	}
+	switch {
+	case options.Mode == RunModeSequential:
+		options.Concurrency = 1
+	case options.Concurrency == 0:
+		options.Concurrency = defaultRunConcurrency
+	}
+
+	vars := map[string]string{}
+	if options.EnvironmentID != nil {
+		values, err := s.requestService.environmentVariables(collection.WorkspaceID, *options.EnvironmentID)
+		if err != nil {
+			return nil, err
+		}
+		vars = values
+	}
+
+	var rows []map[string]string
+	if options.DataFileID != nil {
+		file, err := s.requestService.loadBodyFile(options.DataFileID.String(), collection.WorkspaceID)
+		if err != nil {
+			return nil, err
+		}
+		if rows, err = ParseRunData(file.Name, file.ContentType, file.Data); err != nil {
+			return nil, err
+		}
+		if len(rows) == 0 {
+			return nil, errors.New("data file has no rows")
+		}
+	}
+	if options.Iterations == 0 {
+		options.Iterations = 1
+		if len(rows) > 0 {
+			options.Iterations = min(len(rows), maxRunIterations)
+		}
+	}
+
+	steps, err := s.steps(&collection)
+	if err != nil {
+		return nil, err
+	}
+	if len(steps) == 0 {
+		return nil, errors.New("collection has no requests to run")
+	}
+
+	run := &models.CollectionRun{
+		WorkspaceID:   collection.WorkspaceID,
+		CollectionID:  collection.ID,
+		EnvironmentID: options.EnvironmentID,
+		DataFileID:    options.DataFileID,
+		Mode:          options.Mode,
+		Iterations:    options.Iterations,
+		Concurrency:   options.Concurrency,
+		DelayMs:       options.DelayMs,
+		StopOnFailure: options.StopOnFailure,
+		Status:        RunStatusPending,
+		CreatedBy:     userID,
+	}
+	if err := s.db.Create(run).Error; err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.mu.Lock()
+	s.cancels[run.ID] = cancel
+	s.mu.Unlock()
+
+	go func() {
+		defer func() {
+			s.mu.Lock()
+			delete(s.cancels, run.ID)
+			s.mu.Unlock()
+			cancel()
+		}()
+		s.execute(ctx, run, steps, vars, rows)
+	}()
+
+	return run, nil
+}
+
+// Cancel stops a run in progress. The requests in flight are aborted and the
+// run is stored as cancelled with the results gathered so far.
+func (s *CollectionRunService) Cancel(runID, userID uuid.UUID) error {
+	run, err := s.Get(runID, userID)
+	if err != nil {
+		return err
+	}
+	if run.Status != RunStatusPending && run.Status != RunStatusRunning {
+		return errors.New("run is not in progress")
+	}
+
+	s.mu.Lock()
+	cancel, ok := s.cancels[runID]
+	s.mu.Unlock()
+	if ok {
+		cancel()
+		return nil
+	}
+
+	// No instance is running it any more, so nothing will finish it
+	return s.db.Model(run).Updates(map[string]interface{}{
+		"status":       RunStatusCancelled,
+		"stopped":      true,
+		"completed_at": time.Now(),
+	}).Error
+}
+
+// FailUnfinished marks runs left pending or running by a previous process as
+// failed. Call it on startup, before any run is started.
+func (s *CollectionRunService) FailUnfinished() (int64, error) {
+	result := s.db.Model(&models.CollectionRun{}).
+		Where("status IN ?", []string{RunStatusPending, RunStatusRunning}).
+		Updates(map[string]interface{}{
+			"status":       RunStatusFailed,
+			"completed_at": time.Now(),
+		})
+	return result.RowsAffected, result.Error
+}
+
+func (s *CollectionRunService) Get(runID, userID uuid.UUID) (*models.CollectionRun, error) {
+	var run models.CollectionRun
+	if err := s.db.First(&run, runID).Error; err != nil {
+		return nil, err
+	}
+	if !s.workspaceService.HasAccess(run.WorkspaceID, userID) {
+		return nil, errors.New("access denied")
+	}
+	return &run, nil
+}
+
+// List returns a collection's runs, newest first, without their reports.
+func (s *CollectionRunService) List(collectionID, userID uuid.UUID, limit int) ([]models.CollectionRun, error) {
+	var collection models.Collection
+	if err := s.db.First(&collection, collectionID).Error; err != nil {
+		return nil, err
+	}
+	if !s.workspaceService.HasAccess(collection.WorkspaceID, userID) {
+		return nil, errors.New("access denied")
+	}
+
+	var runs []models.CollectionRun
+	err := s.db.Omit("report").
+		Where("collection_id = ?", collectionID).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&runs).Error
+	if err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+// steps lists the collection's requests in tree order.
+func (s *CollectionRunService) steps(collection *models.Collection) ([]runStep, error) {
+	folders, err := collectionFolders(s.db, collection.ID)
+	if err != nil {
+		return nil, err
+	}
+	var requests []models.Request
+	if err := s.db.Where("collection_id = ?", collection.ID).Find(&requests).Error; err != nil {
+		return nil, err
+	}
+
+	var steps []runStep
+	var walk func(items []TreeItem, chain []models.Folder)
+	walk = func(items []TreeItem, chain []models.Folder) {
+		for _, item := range items {
+			if item.Type == TreeItemFolder {
+				walk(item.Items, append(chain[:len(chain):len(chain)], *item.Folder))
+				continue
+			}
+			request := *item.Request
+			request.Collection = *collection
+			request.Folders = chain
+			names := make([]string, len(chain))
+			for i, folder := range chain {
+				names[i] = folder.Name
+			}
+			steps = append(steps, runStep{request: &request, path: strings.Join(names, "/")})
+		}
+	}
+	walk(buildTree(folders, requests, nil, 0), nil)
+	return steps, nil
+}
+
+// execute performs a run's iterations and stores the report. Cancelling ctx
+// stops the run and stores it as cancelled.
+func (s *CollectionRunService) execute(ctx context.Context, run *models.CollectionRun, steps []runStep, vars map[string]string, rows []map[string]string) {
+	startedAt := time.Now()
+	s.db.Model(run).Updates(map[string]interface{}{
+		"status":     RunStatusRunning,
+		"started_at": startedAt,
+	})
+
+	var (
+		mu         sync.Mutex
+		wg         sync.WaitGroup
+		stopped    atomic.Bool
+		iterations = make([]*RunIteration, run.Iterations)
+		passed     int
+		failed     int
+	)
+	slots := make(chan struct{}, run.Concurrency)
+
+	for i := 0; i < run.Iterations && !stopped.Load() && ctx.Err() == nil; i++ {
+		if i > 0 && run.Mode == RunModeSequential {
+			s.pause(ctx, run)
+		}
+
+		iterationVars := make(map[string]string, len(vars))
+		for key, value := range vars {
+			iterationVars[key] = value
+		}
+		var data map[string]string
+		if len(rows) > 0 {
+			data = rows[i%len(rows)]
+			for key, value := range data {
+				iterationVars[key] = value
+			}
+		}
+
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer func() { <-slots; wg.Done() }()
+
+			iteration := s.iterate(ctx, run, steps, iterationVars, &stopped)
+			iteration.Iteration = i + 1
+			iteration.Data = data
+
+			mu.Lock()
+			defer mu.Unlock()
+			iterations[i] = iteration
+			passed += iteration.Passed
+			failed += iteration.Failed
+			s.db.Model(&models.CollectionRun{}).Where("id = ?", run.ID).Updates(map[string]interface{}{
+				"total_requests": passed + failed,
+				"passed":         passed,
+				"failed":         failed,
+			})
+		}(i)
+
+		if run.Mode == RunModeSequential {
+			wg.Wait()
+		}
+	}
+	wg.Wait()
+
+	report := RunReport{Iterations: []RunIteration{}}
+	var totalMs int64
+	var timed int
+	for _, iteration := range iterations {
+		if iteration == nil {
+			continue
+		}
+		report.Iterations = append(report.Iterations, *iteration)
+		for _, result := range iteration.Results {
+			if result.ExecutionID != nil {
+				totalMs += result.ResponseTimeMs
+				timed++
+			}
+			for _, assertion := range result.Assertions {
+				if assertion.Passed {
+					report.AssertionsPassed++
+				} else {
+					report.AssertionsFailed++
+				}
+			}
+		}
+	}
+	if timed > 0 {
+		report.AvgResponseTimeMs = float64(totalMs) / float64(timed)
+	}
+	completedAt := time.Now()
+	report.DurationMs = completedAt.Sub(startedAt).Milliseconds()
+
+	status := RunStatusPassed
+	switch {
+	case ctx.Err() != nil:
+		status = RunStatusCancelled
+		stopped.Store(true)
+	case failed > 0:
+		status = RunStatusFailed
+	}
+	reportJSON, _ := json.Marshal(report)
+	s.db.Model(run).Updates(map[string]interface{}{
+		"status":         status,
+		"stopped":        stopped.Load(),
+		"total_requests": passed + failed,
+		"passed":         passed,
+		"failed":         failed,
+		"report":         string(reportJSON),
+		"completed_at":   completedAt,
+	})
+}
+
+// iterate runs every step once, in order. It stops early once the run is
+// stopped, which a failure here does when the run has StopOnFailure, or
+// cancelled.
+func (s *CollectionRunService) iterate(ctx context.Context, run *models.CollectionRun, steps []runStep, vars map[string]string, stopped *atomic.Bool) *RunIteration {
+	iteration := &RunIteration{TraceID: uuid.New(), Results: []RunResult{}}
+	for i, step := range steps {
+		if i > 0 {
+			s.pause(ctx, run)
+		}
+		if stopped.Load() || ctx.Err() != nil {
+			break
+		}
+
+		execution, err := s.requestService.execute(ctx, step.request, vars, "", nil, iteration.TraceID, nil, nil)
+		result := runResult(step, execution, err)
+		iteration.Results = append(iteration.Results, result)
+		if result.Passed {
+			iteration.Passed++
+			continue
+		}
+		iteration.Failed++
+		if run.StopOnFailure {
+			stopped.Store(true)
+		}
+	}
+	return iteration
+}
+
+func (s *CollectionRunService) pause(ctx context.Context, run *models.CollectionRun) {
+	if run.DelayMs <= 0 {
+		return
+	}
+	timer := time.NewTimer(time.Duration(run.DelayMs) * time.Millisecond)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
+
+// runResult summarises an execution. It passes when the request was sent,
+// answered below 400 and every assertion held.
+func runResult(step runStep, execution *models.Execution, err error) RunResult {
+	result := RunResult{RequestID: step.request.ID, Name: step.request.Name, Path: step.path}
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.ExecutionID = &execution.ID
+	result.StatusCode = execution.StatusCode
+	result.ResponseTimeMs = execution.ResponseTimeMs
+	result.Error = execution.ErrorMessage
+	if execution.AssertionResults != "" {
+		json.Unmarshal([]byte(execution.AssertionResults), &result.Assertions)
+	}
+
+	result.Passed = result.Error == "" && execution.StatusCode < 400
+	for _, assertion := range result.Assertions {
+		result.Passed = result.Passed && assertion.Passed
+	}
+	return result
+}
+
+// ParseRunData reads a run's data file into one variable map per row. JSON
+// files hold an array of objects; anything else is read as CSV with a header
+// row naming the variables.
+func ParseRunData(name, contentType string, data []byte) ([]map[string]string, error) {
+	trimmed := bytes.TrimSpace(data)
+	isJSON := strings.Contains(contentType, "json") ||
+		strings.EqualFold(path.Ext(name), ".json") ||
+		bytes.HasPrefix(trimmed, []byte("["))
+	if isJSON {
+		return parseJSONRunData(trimmed)
+	}
+	return parseCSVRunData(trimmed)
+}
+
+func parseJSONRunData(data []byte) ([]map[string]string, error) {
+	var objects []map[string]interface{}
+	if err := json.Unmarshal(data, &objects); err != nil {
+		return nil, errors.New("JSON data file must be an array of objects")
+	}
+
+	rows := make([]map[string]string, 0, len(objects))
+	for _, object := range objects {
+		row := make(map[string]string, len(object))
+		for key, value := range object {
+			switch v := value.(type) {
+			case nil:
+				row[key] = ""
+			case string:
+				row[key] = v
+			default:
+				encoded, _ := json.Marshal(v)
+				row[key] = string(encoded)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func parseCSVRunData(data []byte) ([]map[string]string, error) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\ufeff"))))
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV data file: %w", err)
+	}
+	for i, column := range header {
+		header[i] = strings.TrimSpace(column)
+		if header[i] == "" {
+			return nil, fmt.Errorf("CSV data file has an empty column name in column %d", i+1)
+		}
+	}
+
+	var rows []map[string]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV data file: %w", err)
+		}
+		row := make(map[string]string, len(header))
+		for i, column := range header {
+			row[column] = record[i]
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
@@ -0,0 +1,233 @@
+package services
+
+import (
+	"backend/models"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRunData_CSV(t *testing.T) {
+	rows, err := ParseRunData("users.csv", "text/csv", []byte("\ufeffuser_id, name\n1,\"Ann, Jr\"\n2,Bob\n"))
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]string{
+		{"user_id": "1", "name": "Ann, Jr"},
+		{"user_id": "2", "name": "Bob"},
+	}, rows)
+
+	_, err = ParseRunData("users.csv", "text/csv", []byte("user_id,name\n1\n"))
+	assert.Error(t, err, "rows must match the header")
+}
+
+func TestParseRunData_JSON(t *testing.T) {
+	rows, err := ParseRunData("data", "", []byte(`[{"id":7,"name":"Ann","tags":["a"],"note":null}]`))
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]string{{"id": "7", "name": "Ann", "tags": `["a"]`, "note": ""}}, rows)
+
+	_, err = ParseRunData("data.json", "application/json", []byte(`{"id":7}`))
+	assert.EqualError(t, err, "JSON data file must be an array of objects")
+}
+
+func TestRunResult_FailsOnAssertion(t *testing.T) {
+	step := runStep{request: &models.Request{ID: uuid.New(), Name: "Events"}, path: "Streams"}
+	assertions, _ := json.Marshal([]StreamAssertionResult{
+		{StreamAssertion: StreamAssertion{Type: StreamAssertContains, Value: "ready"}, Passed: true},
+		{StreamAssertion: StreamAssertion{Type: StreamAssertMinCount, Value: "3"}, Passed: false},
+	})
+
+	result := runResult(step, &models.Execution{ID: uuid.New(), StatusCode: 200, AssertionResults: string(assertions)}, nil)
+	assert.False(t, result.Passed)
+	assert.Len(t, result.Assertions, 2)
+	assert.Equal(t, "Streams", result.Path)
+
+	result = runResult(step, &models.Execution{ID: uuid.New(), StatusCode: 204}, nil)
+	assert.True(t, result.Passed)
+}
+
+func TestCollectionRunService_Steps_TreeOrderWithFolders(t *testing.T) {
+	db, mock := setupTestDBRequest(t)
+	service := NewCollectionRunService(db, NewRequestService(db, nil))
+
+	collection := &models.Collection{ID: uuid.New(), WorkspaceID: uuid.New(), Name: "Shop"}
+	ordersID := uuid.New()
+
+	mock.ExpectQuery(`(?i)SELECT \* FROM "folders" WHERE collection_id = \$1`).
+		WithArgs(collection.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "collection_id", "name", "position", "variables"}).
+			AddRow(ordersID, collection.ID, "Orders", 1, `{"version":"v2"}`))
+	mock.ExpectQuery(`(?i)SELECT \* FROM "requests" WHERE collection_id = \$1`).
+		WithArgs(collection.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "collection_id", "folder_id", "name", "position"}).
+			AddRow(uuid.New(), collection.ID, ordersID, "List orders", 0).
+			AddRow(uuid.New(), collection.ID, nil, "Login", 0))
+
+	steps, err := service.steps(collection)
+	require.NoError(t, err)
+	require.Len(t, steps, 2)
+	assert.Equal(t, "Login", steps[0].request.Name)
+	assert.Equal(t, "", steps[0].path)
+	assert.Equal(t, "List orders", steps[1].request.Name)
+	assert.Equal(t, "Orders", steps[1].path)
+	assert.Equal(t, "Shop", steps[1].request.Collection.Name)
+	assert.Equal(t, map[string]string{"version": "v2"}, folderVariables(steps[1].request))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCollectionRunService_Iterate_StopsOnFailure(t *testing.T) {
+	db, mock := setupTestDBRequest(t)
+	service := NewCollectionRunService(db, NewRequestService(db, nil))
+
+	var paths []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	workspaceID := uuid.New()
+	collection := models.Collection{ID: uuid.New(), WorkspaceID: workspaceID}
+	steps := []runStep{
+		{request: &models.Request{ID: uuid.New(), Name: "Get user", Method: "GET", URL: ts.URL + "/users/{{user_id}}", Collection: collection}},
+		{request: &models.Request{ID: uuid.New(), Name: "Never sent", Method: "GET", URL: ts.URL + "/never", Collection: collection}},
+	}
+
+	mock.ExpectQuery(`(?i)SELECT "settings" FROM "workspaces"`).
+		WithArgs(workspaceID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"settings"}).AddRow(""))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`(?i)INSERT INTO "executions"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery(`(?i)INSERT INTO "traces"`).
+		WillReturnRows(sqlmock.NewRows([]string{"span_count"}).AddRow(0))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery(`(?i)INSERT INTO "spans"`).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("error"))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(`(?i)UPDATE "traces"`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	var stopped atomic.Bool
+	run := &models.CollectionRun{StopOnFailure: true}
+	iteration := service.iterate(context.Background(), run, steps, map[string]string{"user_id": "42"}, &stopped)
+
+	assert.True(t, stopped.Load())
+	assert.Equal(t, []string{"/users/42"}, paths)
+	require.Len(t, iteration.Results, 1)
+	assert.Equal(t, 500, iteration.Results[0].StatusCode)
+	assert.Equal(t, 1, iteration.Failed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCollectionRunService_Iterate_ResolvesStreamVariables(t *testing.T) {
+	db, mock := setupTestDBRequest(t)
+	service := NewCollectionRunService(db, NewRequestService(db, nil))
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "event: order\ndata: {\"id\":\"o-42\"}\n\n")
+		w.(http.Flusher).Flush()
+	}))
+	defer ts.Close()
+
+	workspaceID := uuid.New()
+	collection := models.Collection{ID: uuid.New(), WorkspaceID: workspaceID}
+	steps := []runStep{{request: &models.Request{
+		ID: uuid.New(), Name: "Order events", Method: "GET", URL: ts.URL, Kind: RequestKindSSE, Collection: collection,
+		Stream: `{"max_messages":1,"duration_ms":2000,"assertions":[{"type":"json_path","event":"{{event}}","path":"id","value":"{{order_id}}"}]}`,
+	}}}
+
+	mock.ExpectQuery(`(?i)SELECT "settings" FROM "workspaces"`).
+		WithArgs(workspaceID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"settings"}).AddRow(""))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`(?i)INSERT INTO "executions"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectCommit()
+
+	var stopped atomic.Bool
+	iteration := service.iterate(context.Background(), &models.CollectionRun{}, steps, map[string]string{"event": "order", "order_id": "o-42"}, &stopped)
+
+	require.Len(t, iteration.Results, 1)
+	require.Len(t, iteration.Results[0].Assertions, 1)
+	assert.True(t, iteration.Results[0].Assertions[0].Passed, iteration.Results[0].Assertions[0].Message)
+	assert.Equal(t, 1, iteration.Passed)
+}
+
+func TestCollectionRunService_Cancel(t *testing.T) {
+	db, mock := setupTestDBRequest(t)
+	service := NewCollectionRunService(db, NewRequestService(db, nil))
+
+	workspaceID := uuid.New()
+	userID := uuid.New()
+	expectRun := func(runID uuid.UUID) {
+		mock.ExpectQuery(`(?i)SELECT \* FROM "collection_runs"`).
+			WithArgs(runID, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "workspace_id", "status"}).AddRow(runID, workspaceID, RunStatusRunning))
+		mock.ExpectQuery(`(?i)SELECT count\(\*\) FROM "workspace_members"`).
+			WithArgs(workspaceID, userID).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	}
+
+	// A run on this instance is cancelled through its context
+	runID := uuid.New()
+	ctx, cancel := context.WithCancel(context.Background())
+	service.cancels[runID] = cancel
+	expectRun(runID)
+	require.NoError(t, service.Cancel(runID, userID))
+	assert.Error(t, ctx.Err())
+
+	// A run nobody is executing is closed directly
+	orphanID := uuid.New()
+	expectRun(orphanID)
+	mock.ExpectBegin()
+	mock.ExpectExec(`(?i)UPDATE "collection_runs" SET "completed_at"=\$1,"status"=\$2,"stopped"=\$3 WHERE "id" = \$4`).
+		WithArgs(sqlmock.AnyArg(), RunStatusCancelled, true, orphanID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	require.NoError(t, service.Cancel(orphanID, userID))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCollectionRunService_Iterate_StopsWhenCancelled(t *testing.T) {
+	db, mock := setupTestDBRequest(t)
+	service := NewCollectionRunService(db, NewRequestService(db, nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	steps := []runStep{{request: &models.Request{ID: uuid.New(), Method: "GET", URL: "http://localhost"}}}
+
+	var stopped atomic.Bool
+	iteration := service.iterate(ctx, &models.CollectionRun{}, steps, nil, &stopped)
+	assert.Empty(t, iteration.Results)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCollectionRunService_FailUnfinished(t *testing.T) {
+	db, mock := setupTestDBRequest(t)
+	service := NewCollectionRunService(db, NewRequestService(db, nil))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`(?i)UPDATE "collection_runs" SET "completed_at"=\$1,"status"=\$2 WHERE status IN \(\$3,\$4\)`).
+		WithArgs(sqlmock.AnyArg(), RunStatusFailed, RunStatusPending, RunStatusRunning).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	failed, err := service.FailUnfinished()
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), failed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -11,16 +11,18 @@ import (
 type CollectionService struct {
 	db               *gorm.DB
 	workspaceService *WorkspaceService
+	revisions        *RevisionService
 }
 
 func NewCollectionService(db *gorm.DB) *CollectionService {
 	return &CollectionService{
 		db:               db,
 		workspaceService: NewWorkspaceService(db),
+		revisions:        NewRevisionService(db),
 	}
 }
 
-func (s *CollectionService) Create(workspaceID uuid.UUID, name, description string, userID uuid.UUID) (*models.Collection, error) {
+func (s *CollectionService) Create(workspaceID uuid.UUID, name, description, auth, settings string, userID uuid.UUID) (*models.Collection, error) {
 	if !s.workspaceService.HasAccess(workspaceID, userID) {
 		return nil, errors.New("access denied")
 	}
@@ -29,9 +31,18 @@ func (s *CollectionService) Create(workspaceID uuid.UUID, name, description stri
 		Name:        name,
 		Description: description,
 		WorkspaceID: workspaceID,
+		Auth:        auth,
+		Settings:    settings,
 	}
 
-	if err := s.db.Create(&collection).Error; err != nil {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&collection).Error; err != nil {
+			return err
+		}
+		_, err := s.revisions.recordCollection(tx, userID, &collection, RevisionCreate)
+		return err
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -61,7 +72,9 @@ func (s *CollectionService) GetByID(collectionID, userID uuid.UUID) (*models.Col
 	return &collection, nil
 }
 
-func (s *CollectionService) Update(collectionID, userID uuid.UUID, name, description string) (*models.Collection, error) {
+// Update replaces name and description. Auth and settings are only replaced
+// when non-empty.
+func (s *CollectionService) Update(collectionID, userID uuid.UUID, name, description, auth, settings string) (*models.Collection, error) {
 	var collection models.Collection
 	if err := s.db.First(&collection, collectionID).Error; err != nil {
 		return nil, err
@@ -73,8 +86,21 @@ func (s *CollectionService) Update(collectionID, userID uuid.UUID, name, descrip
 
 	collection.Name = name
 	collection.Description = description
+	if auth != "" {
+		collection.Auth = mergeAuthSecrets(auth, collection.Auth)
+	}
+	if settings != "" {
+		collection.Settings = settings
+	}
 
-	if err := s.db.Save(&collection).Error; err != nil {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&collection).Error; err != nil {
+			return err
+		}
+		_, err := s.revisions.recordCollection(tx, userID, &collection, RevisionUpdate)
+		return err
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -91,5 +117,11 @@ func (s *CollectionService) Delete(collectionID, userID uuid.UUID) error {
 		return errors.New("access denied")
 	}
 
-	return s.db.Delete(&collection).Error
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&collection).Error; err != nil {
+			return err
+		}
+		_, err := s.revisions.recordCollection(tx, userID, &collection, RevisionDelete)
+		return err
+	})
 }
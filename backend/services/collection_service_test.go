@@ -58,9 +58,10 @@ func TestCollectionService_Create_Success(t *testing.T) {
 	mock.ExpectBegin()
 	mock.ExpectQuery(`INSERT INTO "collections"`).
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	expectRevisionRecorded(mock, RevisionResourceCollection, sqlmock.AnyArg())
 	mock.ExpectCommit()
 
-	collection, err := service.Create(workspaceID, name, description, userID)
+	collection, err := service.Create(workspaceID, name, description, "", "", userID)
 
 	require.NoError(t, err) // require stops execution if nil to avoid panic
 	assert.NotNil(t, collection)
@@ -123,9 +124,10 @@ func TestCollectionService_Update_Success(t *testing.T) {
 	mock.ExpectBegin()
 	mock.ExpectExec(`UPDATE "collections" SET`).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectRevisionRecorded(mock, RevisionResourceCollection, collectionID)
 	mock.ExpectCommit()
 
-	collection, err := service.Update(collectionID, userID, "New Name", "New Desc")
+	collection, err := service.Update(collectionID, userID, "New Name", "New Desc", "", "")
 
 	assert.NoError(t, err)
 	assert.NotNil(t, collection)
@@ -161,6 +163,7 @@ func TestCollectionService_Delete_Success(t *testing.T) {
 	mock.ExpectExec(`UPDATE "collections" SET "deleted_at"=\$1 WHERE "collections"\."id" = \$2 AND "collections"\."deleted_at" IS NULL`).
 		WithArgs(sqlmock.AnyArg(), collectionID). // $1 is the timestamp, $2 is the ID
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectRevisionRecorded(mock, RevisionResourceCollection, collectionID)
 	mock.ExpectCommit()
 
 	err := service.Delete(collectionID, userID)
@@ -0,0 +1,1044 @@
+package services
+
+import (
+	"backend/models"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+)
+
+// A synced collection is a directory of YAML files meant to be committed to
+// git and reviewed like code:
+//
+//	collection.yaml                  name, description, auth, settings
+//	environments/staging.yaml        variables, and secret keys without values
+//	orders/folder.yaml               one directory per folder
+//	orders/get-order.request.yaml    one file per request
+//
+// Every file carries the ID of what it describes, so renames and moves are
+// updates rather than a delete and a create. Auth secrets are written as a
+// {{redacted}} placeholder, and pushing the placeholder back keeps the
+// secret stored in the workspace.
+const (
+	syncCollectionFile = "collection.yaml"
+	syncFolderFile     = "folder.yaml"
+	syncRequestSuffix  = ".request.yaml"
+	syncEnvironmentDir = "environments"
+)
+
+// Sync change actions.
+const (
+	SyncActionCreate = "create"
+	SyncActionUpdate = "update"
+	SyncActionDelete = "delete"
+)
+
+// SyncChange is one create, update or delete a sync makes, or would make on
+// a dry run.
+type SyncChange struct {
+	Action string     `json:"action"`
+	Kind   string     `json:"kind"` // collection, folder, request or environment
+	Path   string     `json:"path"` // file in the synced directory
+	Name   string     `json:"name"`
+	ID     *uuid.UUID `json:"id,omitempty"`     // unset for creates on a dry run
+	Fields []string   `json:"fields,omitempty"` // what changed, updates only
+}
+
+// SyncPlan is the outcome of reconciling a directory with the workspace.
+type SyncPlan struct {
+	CollectionID   uuid.UUID    `json:"collection_id"` // uuid.Nil on a dry run that would create the collection
+	EnvironmentIDs []uuid.UUID  `json:"environment_ids"`
+	DryRun         bool         `json:"dry_run"`
+	Changes        []SyncChange `json:"changes"`
+	Warnings       []string     `json:"warnings"`
+}
+
+type syncCollection struct {
+	ID          string      `yaml:"id,omitempty"`
+	Name        string      `yaml:"name"`
+	Description string      `yaml:"description,omitempty"`
+	Auth        interface{} `yaml:"auth,omitempty"`
+	Settings    interface{} `yaml:"settings,omitempty"`
+}
+
+type syncFolder struct {
+	ID          string      `yaml:"id,omitempty"`
+	Name        string      `yaml:"name"`
+	Description string      `yaml:"description,omitempty"`
+	Position    int         `yaml:"position"`
+	Auth        interface{} `yaml:"auth,omitempty"`
+	Settings    interface{} `yaml:"settings,omitempty"`
+	Variables   interface{} `yaml:"variables,omitempty"`
+}
+
+type syncRequest struct {
+	ID          string      `yaml:"id,omitempty"`
+	Name        string      `yaml:"name"`
+	Description string      `yaml:"description,omitempty"`
+	Kind        string      `yaml:"kind,omitempty"`
+	Method      string      `yaml:"method"`
+	URL         string      `yaml:"url"`
+	Position    int         `yaml:"position"`
+	Headers     interface{} `yaml:"headers,omitempty"`
+	Query       interface{} `yaml:"query,omitempty"`
+	Body        *syncBody   `yaml:"body,omitempty"`
+	Auth        interface{} `yaml:"auth,omitempty"`
+	Settings    interface{} `yaml:"settings,omitempty"`
+	GraphQL     interface{} `yaml:"graphql,omitempty"`
+	GRPC        interface{} `yaml:"grpc,omitempty"`
+	Stream      interface{} `yaml:"stream,omitempty"` // scripted messages and assertions
+}
+
+// syncBody holds a text body (raw, xml, text) as a string and anything else
+// (json, form params, binary file references) as structured YAML.
+type syncBody struct {
+	Mode string      `yaml:"mode,omitempty"`
+	Text *string     `yaml:"text,omitempty"`
+	JSON interface{} `yaml:"json,omitempty"`
+}
+
+type syncEnvironment struct {
+	ID          string         `yaml:"id,omitempty"`
+	Name        string         `yaml:"name"`
+	Type        string         `yaml:"type,omitempty"`
+	Description string         `yaml:"description,omitempty"`
+	Variables   []syncVariable `yaml:"variables,omitempty"`
+	Secrets     []string       `yaml:"secrets,omitempty"` // keys only, values stay in the workspace
+}
+
+type syncVariable struct {
+	Key         string `yaml:"key"`
+	Value       string `yaml:"value"`
+	Type        string `yaml:"type,omitempty"`
+	Description string `yaml:"description,omitempty"`
+}
+
+// CollectionSyncService writes collections to YAML files and reconciles
+// edited files back into the workspace.
+type CollectionSyncService struct {
+	db                 *gorm.DB
+	workspaceService   *WorkspaceService
+	collectionService  *CollectionService
+	folderService      *FolderService
+	environmentService *EnvironmentService
+	revisions          *RevisionService
+}
+
+func NewCollectionSyncService(db *gorm.DB, folderService *FolderService) *CollectionSyncService {
+	return &CollectionSyncService{
+		db:                 db,
+		workspaceService:   NewWorkspaceService(db),
+		collectionService:  NewCollectionService(db),
+		folderService:      folderService,
+		environmentService: NewEnvironmentService(db),
+		revisions:          NewRevisionService(db),
+	}
+}
+
+// Serialize renders a collection and the given workspace environments as
+// files keyed by slash-separated path. Secret values are never written.
+func (s *CollectionSyncService) Serialize(collectionID, userID uuid.UUID, environmentIDs []uuid.UUID) (map[string]string, error) {
+	collection, err := s.collectionService.GetByID(collectionID, userID)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := s.folderService.GetTree(collectionID, userID)
+	if err != nil {
+		return nil, err
+	}
+	data := &exportData{collection: collection, tree: tree}
+	for _, environmentID := range environmentIDs {
+		environment, err := s.environmentService.GetByID(collection.WorkspaceID, environmentID, userID)
+		if err != nil {
+			return nil, fmt.Errorf("environment %s: %w", environmentID, err)
+		}
+		data.environments = append(data.environments, *environment)
+	}
+
+	files, _, err := syncLayout(data)
+	return files, err
+}
+
+// syncLayout renders collection data as files and returns the path of every
+// folder, request and environment file by ID. File names come from item
+// names, numbered when siblings share one, and folders and requests are
+// laid out in tree order so the same collection always gives the same files.
+func syncLayout(data *exportData) (map[string]string, map[uuid.UUID]string, error) {
+	files := map[string]string{}
+	paths := map[uuid.UUID]string{}
+	add := func(file string, value interface{}) error {
+		encoded, err := syncMarshal(value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		files[file] = encoded
+		return nil
+	}
+
+	if err := add(syncCollectionFile, collectionToSync(data.collection)); err != nil {
+		return nil, nil, err
+	}
+
+	var walk func(items []TreeItem, dir string, used map[string]bool) error
+	walk = func(items []TreeItem, dir string, used map[string]bool) error {
+		for _, item := range items {
+			if folder := item.Folder; folder != nil {
+				folderDir := path.Join(dir, claimSyncSlug(used, folder.Name))
+				file := path.Join(folderDir, syncFolderFile)
+				paths[folder.ID] = file
+				if err := add(file, folderToSync(folder)); err != nil {
+					return err
+				}
+				if err := walk(item.Items, folderDir, map[string]bool{}); err != nil {
+					return err
+				}
+				continue
+			}
+			if request := item.Request; request != nil {
+				file := path.Join(dir, claimSyncSlug(used, request.Name)+syncRequestSuffix)
+				paths[request.ID] = file
+				if err := add(file, requestToSync(request)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	// A root folder cannot take the environments directory's name
+	if err := walk(data.tree, "", map[string]bool{syncEnvironmentDir: true}); err != nil {
+		return nil, nil, err
+	}
+
+	used := map[string]bool{}
+	for _, environment := range data.environments {
+		file := path.Join(syncEnvironmentDir, claimSyncSlug(used, environment.Name)+".yaml")
+		paths[environment.ID] = file
+		if err := add(file, environmentToSync(environment)); err != nil {
+			return nil, nil, err
+		}
+	}
+	return files, paths, nil
+}
+
+func collectionToSync(collection *models.Collection) syncCollection {
+	return syncCollection{
+		ID:          syncID(collection.ID),
+		Name:        collection.Name,
+		Description: collection.Description,
+		Auth:        syncValue(RedactAuth(collection.Auth)),
+		Settings:    syncValue(collection.Settings),
+	}
+}
+
+func folderToSync(folder *models.Folder) syncFolder {
+	return syncFolder{
+		ID:          syncID(folder.ID),
+		Name:        folder.Name,
+		Description: folder.Description,
+		Position:    folder.Position,
+		Auth:        syncValue(RedactAuth(folder.Auth)),
+		Settings:    syncValue(folder.Settings),
+		Variables:   syncValue(folder.Variables),
+	}
+}
+
+func requestToSync(request *models.Request) syncRequest {
+	synced := syncRequest{
+		ID:          syncID(request.ID),
+		Name:        request.Name,
+		Description: request.Description,
+		Kind:        request.Kind,
+		Method:      request.Method,
+		URL:         request.URL,
+		Position:    request.Position,
+		Headers:     syncValue(request.Headers),
+		Query:       syncValue(request.QueryParams),
+		Auth:        syncValue(RedactAuth(request.Auth)),
+		Settings:    syncValue(request.Settings),
+		GraphQL:     syncValue(request.GraphQL),
+		GRPC:        syncValue(request.GRPC),
+		Stream:      syncValue(request.Stream),
+	}
+	body := syncValue(request.Body)
+	if request.BodyMode != "" || body != nil {
+		synced.Body = &syncBody{Mode: request.BodyMode}
+		if text, ok := body.(string); ok {
+			synced.Body.Text = &text
+		} else {
+			synced.Body.JSON = body
+		}
+	}
+	return synced
+}
+
+// environmentToSync lists variables by key and secrets by key only.
+func environmentToSync(environment models.Environment) syncEnvironment {
+	synced := syncEnvironment{
+		ID:          syncID(environment.ID),
+		Name:        environment.Name,
+		Type:        environment.Type,
+		Description: environment.Description,
+	}
+	for _, variable := range environment.Variables {
+		synced.Variables = append(synced.Variables, syncVariable{Key: variable.Key, Value: variable.Value, Type: variable.Type, Description: variable.Description})
+	}
+	sort.Slice(synced.Variables, func(i, j int) bool { return synced.Variables[i].Key < synced.Variables[j].Key })
+	for _, secret := range environment.Secrets {
+		synced.Secrets = append(synced.Secrets, secret.Key)
+	}
+	sort.Strings(synced.Secrets)
+	return synced
+}
+
+// Reconcile makes the workspace match the files: the collection named by
+// collection.yaml (or a new one if its ID is unknown here) gets exactly the
+// folders and requests in the files, and the environments in the files are
+// created or updated. Environments that are not in the files are left
+// alone, as are secrets, which are only listed by key. With dryRun the
+// changes are worked out but not made. Changes are applied in a single
+// transaction.
+func (s *CollectionSyncService) Reconcile(workspaceID, userID uuid.UUID, files map[string]string, dryRun bool) (*SyncPlan, error) {
+	if !s.workspaceService.HasAccess(workspaceID, userID) {
+		return nil, errors.New("access denied")
+	}
+	tree, err := parseSyncFiles(files)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &SyncPlan{DryRun: dryRun, EnvironmentIDs: []uuid.UUID{}, Changes: []SyncChange{}, Warnings: []string{}}
+	// change records a change and returns its index, so creates can be given
+	// their ID once applied
+	change := func(action, kind, file, name string, id uuid.UUID, fields []string) int {
+		entry := SyncChange{Action: action, Kind: kind, Path: file, Name: name, Fields: fields}
+		if id != uuid.Nil {
+			entry.ID = &id
+		}
+		plan.Changes = append(plan.Changes, entry)
+		return len(plan.Changes) - 1
+	}
+	created := func(index int, id uuid.UUID) {
+		plan.Changes[index].ID = &id
+	}
+
+	// The collection as it is now
+	var collection *models.Collection
+	var folders []models.Folder
+	var requests []models.Request
+	if id, err := uuid.Parse(tree.collection.ID); err == nil {
+		var existing models.Collection
+		err := s.db.Where("id = ? AND workspace_id = ?", id, workspaceID).First(&existing).Error
+		switch {
+		case err == nil:
+			collection = &existing
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return nil, err
+		}
+	}
+	current := &exportData{collection: &models.Collection{}}
+	if collection != nil {
+		current.collection = collection
+		if folders, err = collectionFolders(s.db, collection.ID); err != nil {
+			return nil, err
+		}
+		if err := s.db.Where("collection_id = ?", collection.ID).Find(&requests).Error; err != nil {
+			return nil, err
+		}
+		current.tree = buildTree(folders, requests, nil, 0)
+	}
+	_, currentPaths, err := syncLayout(current)
+	if err != nil {
+		return nil, err
+	}
+
+	collectionColumns, err := tree.collection.columns()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", syncCollectionFile, err)
+	}
+	collectionChange := -1
+	if collection == nil {
+		collectionChange = change(SyncActionCreate, "collection", syncCollectionFile, tree.collection.Name, uuid.Nil, nil)
+	} else {
+		existingColumns, _ := collectionToSync(collection).columns()
+		if fields := changedSyncFields(existingColumns, collectionColumns); len(fields) > 0 {
+			change(SyncActionUpdate, "collection", syncCollectionFile, tree.collection.Name, collection.ID, fields)
+		}
+		collectionColumns["auth"] = mergeAuthSecrets(collectionColumns["auth"].(string), collection.Auth)
+	}
+
+	// Folders, parents first. Folders are told apart by ID; one whose ID is
+	// not in this collection is created, and new folders are referred to by
+	// their directory until they have an ID.
+	existingFolders := make(map[uuid.UUID]*models.Folder, len(folders))
+	for i := range folders {
+		existingFolders[folders[i].ID] = &folders[i]
+	}
+	folderIDs := map[string]*models.Folder{} // directory to existing folder
+	parentKey := func(dir string) string {
+		if dir == "" {
+			return ""
+		}
+		if folder := folderIDs[dir]; folder != nil {
+			return folder.ID.String()
+		}
+		return "new:" + dir
+	}
+	kept := map[uuid.UUID]bool{}
+	claim := func(file, rawID string) (*uuid.UUID, error) {
+		id, err := uuid.Parse(rawID)
+		if err != nil {
+			return nil, nil
+		}
+		if kept[id] {
+			return nil, fmt.Errorf("%s: id %s is used by more than one file", file, id)
+		}
+		kept[id] = true
+		return &id, nil
+	}
+
+	type folderStep struct {
+		entry    *syncFolderEntry
+		existing *models.Folder
+		columns  map[string]interface{}
+		change   int // index of the create, -1 for existing folders
+	}
+	var folderSteps []folderStep
+	for _, dir := range tree.folderDirs() {
+		entry := tree.folders[dir]
+		columns, err := entry.folder.columns()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.file, err)
+		}
+		if err := validateFolderVariables(columns["variables"].(string)); err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.file, err)
+		}
+		columns["parent_id"] = parentKey(syncParentDir(dir))
+
+		id, err := claim(entry.file, entry.folder.ID)
+		if err != nil {
+			return nil, err
+		}
+		var existing *models.Folder
+		if id != nil {
+			existing = existingFolders[*id]
+		}
+		step := folderStep{entry: entry, existing: existing, columns: columns, change: -1}
+		if existing == nil {
+			step.change = change(SyncActionCreate, "folder", entry.file, entry.folder.Name, uuid.Nil, nil)
+		} else {
+			folderIDs[dir] = existing
+			existingColumns, _ := folderToSync(existing).columns()
+			existingColumns["parent_id"] = syncParentKey(existing.ParentID)
+			if fields := changedSyncFields(existingColumns, columns); len(fields) > 0 {
+				change(SyncActionUpdate, "folder", entry.file, entry.folder.Name, existing.ID, fields)
+			}
+			columns["auth"] = mergeAuthSecrets(columns["auth"].(string), existing.Auth)
+		}
+		folderSteps = append(folderSteps, step)
+	}
+
+	type requestStep struct {
+		entry    *syncRequestEntry
+		existing *models.Request
+		columns  map[string]interface{}
+		change   int
+	}
+	existingRequests := make(map[uuid.UUID]*models.Request, len(requests))
+	for i := range requests {
+		existingRequests[requests[i].ID] = &requests[i]
+	}
+	var requestSteps []requestStep
+	for i := range tree.requests {
+		entry := &tree.requests[i]
+		columns, err := entry.request.columns()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.file, err)
+		}
+		columns["folder_id"] = parentKey(entry.dir)
+
+		id, err := claim(entry.file, entry.request.ID)
+		if err != nil {
+			return nil, err
+		}
+		var existing *models.Request
+		if id != nil {
+			existing = existingRequests[*id]
+		}
+		step := requestStep{entry: entry, existing: existing, columns: columns, change: -1}
+		if existing == nil {
+			step.change = change(SyncActionCreate, "request", entry.file, entry.request.Name, uuid.Nil, nil)
+		} else {
+			existingColumns, _ := requestToSync(existing).columns()
+			existingColumns["folder_id"] = syncParentKey(existing.FolderID)
+			if fields := changedSyncFields(existingColumns, columns); len(fields) > 0 {
+				change(SyncActionUpdate, "request", entry.file, entry.request.Name, existing.ID, fields)
+			}
+			columns["auth"] = mergeAuthSecrets(columns["auth"].(string), existing.Auth)
+		}
+		requestSteps = append(requestSteps, step)
+	}
+
+	var deletedRequests []*models.Request
+	for i := range requests {
+		if !kept[requests[i].ID] {
+			deletedRequests = append(deletedRequests, &requests[i])
+			change(SyncActionDelete, "request", currentPaths[requests[i].ID], requests[i].Name, requests[i].ID, nil)
+		}
+	}
+	var deletedFolders []uuid.UUID
+	for i := range folders {
+		if !kept[folders[i].ID] {
+			deletedFolders = append(deletedFolders, folders[i].ID)
+			change(SyncActionDelete, "folder", currentPaths[folders[i].ID], folders[i].Name, folders[i].ID, nil)
+		}
+	}
+
+	// Environments are matched by ID, then by name, within the workspace
+	var environments []models.Environment
+	if err := s.db.Where("workspace_id = ?", workspaceID).Preload("Variables").Preload("Secrets").Find(&environments).Error; err != nil {
+		return nil, err
+	}
+	type environmentStep struct {
+		entry    *syncEnvironmentEntry
+		existing *models.Environment
+		change   int
+	}
+	var environmentSteps []environmentStep
+	matched := map[uuid.UUID]bool{}
+	for i := range tree.environments {
+		entry := &tree.environments[i]
+		var existing *models.Environment
+		id, _ := uuid.Parse(entry.environment.ID)
+		for j := range environments {
+			if environments[j].ID == id {
+				existing = &environments[j]
+			}
+		}
+		for j := range environments {
+			if existing == nil && environments[j].Name == entry.environment.Name {
+				existing = &environments[j]
+			}
+		}
+		if existing != nil && matched[existing.ID] {
+			return nil, fmt.Errorf("%s: environment %q is described by more than one file", entry.file, existing.Name)
+		}
+
+		step := environmentStep{entry: entry, existing: existing, change: -1}
+		secrets := map[string]bool{}
+		if existing == nil {
+			step.change = change(SyncActionCreate, "environment", entry.file, entry.environment.Name, uuid.Nil, nil)
+		} else {
+			matched[existing.ID] = true
+			plan.EnvironmentIDs = append(plan.EnvironmentIDs, existing.ID)
+			if fields := changedSyncFields(environmentToSync(*existing).columns(), entry.environment.columns()); len(fields) > 0 {
+				change(SyncActionUpdate, "environment", entry.file, entry.environment.Name, existing.ID, fields)
+			}
+			for _, secret := range existing.Secrets {
+				secrets[secret.Key] = true
+			}
+		}
+		for _, key := range entry.environment.Secrets {
+			if !secrets[key] {
+				plan.Warnings = append(plan.Warnings, fmt.Sprintf("%s: secret %q has no value in the workspace yet", entry.file, key))
+			}
+		}
+		environmentSteps = append(environmentSteps, step)
+	}
+
+	if dryRun {
+		if collection != nil {
+			plan.CollectionID = collection.ID
+		}
+		return plan, nil
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if collection == nil {
+			collection = &models.Collection{WorkspaceID: workspaceID, Name: tree.collection.Name}
+			if err := tx.Omit("Workspace").Create(collection).Error; err != nil {
+				return err
+			}
+			created(collectionChange, collection.ID)
+		}
+		if collectionChange >= 0 || plannedUpdate(plan.Changes, collection.ID) {
+			if err := tx.Model(collection).Omit("Workspace").Updates(syncNulls(collectionColumns)).Error; err != nil {
+				return err
+			}
+		}
+
+		// Parents are either existing folder IDs or directories of folders
+		// created earlier in this loop
+		resolve := func(key string) (*uuid.UUID, error) {
+			if key == "" {
+				return nil, nil
+			}
+			if dir := strings.TrimPrefix(key, "new:"); dir != key {
+				folder := folderIDs[dir]
+				if folder == nil {
+					return nil, fmt.Errorf("folder %s was not created", dir)
+				}
+				return &folder.ID, nil
+			}
+			id, err := uuid.Parse(key)
+			return &id, err
+		}
+
+		for _, step := range folderSteps {
+			folder := step.existing
+			if folder != nil && !plannedUpdate(plan.Changes, folder.ID) {
+				continue
+			}
+			parentID, err := resolve(step.columns["parent_id"].(string))
+			if err != nil {
+				return err
+			}
+			step.columns["parent_id"] = parentID
+			if folder == nil {
+				folder = &models.Folder{CollectionID: collection.ID, Name: step.entry.folder.Name}
+				if err := tx.Omit("Collection").Create(folder).Error; err != nil {
+					return fmt.Errorf("%s: %w", step.entry.file, err)
+				}
+				folderIDs[step.entry.dir] = folder
+				created(step.change, folder.ID)
+			}
+			if err := tx.Model(folder).Omit("Collection").Updates(syncNulls(step.columns)).Error; err != nil {
+				return fmt.Errorf("%s: %w", step.entry.file, err)
+			}
+		}
+
+		for _, step := range requestSteps {
+			request, action := step.existing, RevisionUpdate
+			if request != nil && !plannedUpdate(plan.Changes, request.ID) {
+				continue
+			}
+			folderID, err := resolve(step.columns["folder_id"].(string))
+			if err != nil {
+				return err
+			}
+			step.columns["folder_id"] = folderID
+			if request == nil {
+				request, action = &models.Request{CollectionID: collection.ID, Name: step.entry.request.Name, Method: step.entry.request.Method}, RevisionCreate
+				if err := tx.Omit("Collection").Create(request).Error; err != nil {
+					return fmt.Errorf("%s: %w", step.entry.file, err)
+				}
+				created(step.change, request.ID)
+			}
+			if err := tx.Model(request).Omit("Collection").Updates(syncNulls(step.columns)).Error; err != nil {
+				return fmt.Errorf("%s: %w", step.entry.file, err)
+			}
+			if _, err := s.revisions.recordRequest(tx, workspaceID, userID, request, action); err != nil {
+				return err
+			}
+		}
+
+		for _, request := range deletedRequests {
+			if err := tx.Delete(request).Error; err != nil {
+				return err
+			}
+			if _, err := s.revisions.recordRequest(tx, workspaceID, userID, request, RevisionDelete); err != nil {
+				return err
+			}
+		}
+		if len(deletedFolders) > 0 {
+			if err := tx.Where("id IN ?", deletedFolders).Delete(&models.Folder{}).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Model(collection).Update("request_count", len(requestSteps)).Error; err != nil {
+			return err
+		}
+		if collectionChange >= 0 {
+			if _, err := s.revisions.recordCollection(tx, userID, collection, RevisionCreate); err != nil {
+				return err
+			}
+		} else if plannedUpdate(plan.Changes, collection.ID) {
+			if _, err := s.revisions.recordCollection(tx, userID, collection, RevisionUpdate); err != nil {
+				return err
+			}
+		}
+
+		for _, step := range environmentSteps {
+			if step.existing != nil && !plannedUpdate(plan.Changes, step.existing.ID) {
+				continue
+			}
+			environment, err := applySyncEnvironment(tx, workspaceID, step.existing, step.entry.environment)
+			if err != nil {
+				return fmt.Errorf("%s: %w", step.entry.file, err)
+			}
+			if step.change >= 0 {
+				created(step.change, environment.ID)
+				plan.EnvironmentIDs = append(plan.EnvironmentIDs, environment.ID)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	plan.CollectionID = collection.ID
+	return plan, nil
+}
+
+// applySyncEnvironment creates or updates an environment and makes its
+// variables match. Secrets are not touched.
+func applySyncEnvironment(tx *gorm.DB, workspaceID uuid.UUID, existing *models.Environment, synced syncEnvironment) (*models.Environment, error) {
+	envType := synced.Type
+	if envType == "" {
+		envType = environmentType(synced.Name)
+	}
+	environment := existing
+	if environment == nil {
+		environment = &models.Environment{WorkspaceID: workspaceID, Name: synced.Name, Type: envType, Description: synced.Description, IsActive: true}
+		if err := tx.Omit("Workspace", "Variables", "Secrets").Create(environment).Error; err != nil {
+			return nil, err
+		}
+	} else {
+		updates := map[string]interface{}{"name": synced.Name, "type": envType, "description": synced.Description}
+		if err := tx.Model(environment).Omit("Workspace").Updates(updates).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	variables := map[string]*models.EnvironmentVariable{}
+	for i := range environment.Variables {
+		variables[environment.Variables[i].Key] = &environment.Variables[i]
+	}
+	for _, variable := range synced.Variables {
+		variableType := variable.Type
+		if variableType == "" {
+			variableType = "string"
+		}
+		current := variables[variable.Key]
+		delete(variables, variable.Key)
+		if current == nil {
+			created := &models.EnvironmentVariable{EnvironmentID: environment.ID, Key: variable.Key, Value: variable.Value, Type: variableType, Description: variable.Description}
+			if err := tx.Omit("Environment").Create(created).Error; err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if current.Value != variable.Value || current.Type != variableType || current.Description != variable.Description {
+			updates := map[string]interface{}{"value": variable.Value, "type": variableType, "description": variable.Description}
+			if err := tx.Model(current).Omit("Environment").Updates(updates).Error; err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, variable := range variables {
+		if err := tx.Delete(variable).Error; err != nil {
+			return nil, err
+		}
+	}
+	return environment, nil
+}
+
+// plannedUpdate reports whether the plan updates the item with this ID.
+func plannedUpdate(changes []SyncChange, id uuid.UUID) bool {
+	for _, change := range changes {
+		if change.Action == SyncActionUpdate && change.ID != nil && *change.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+type syncFolderEntry struct {
+	file, dir string
+	folder    syncFolder
+}
+
+type syncRequestEntry struct {
+	file, dir string
+	request   syncRequest
+}
+
+type syncEnvironmentEntry struct {
+	file        string
+	environment syncEnvironment
+}
+
+// syncTree is a parsed synced directory.
+type syncTree struct {
+	collection   syncCollection
+	folders      map[string]*syncFolderEntry // by directory
+	requests     []syncRequestEntry          // in path order
+	environments []syncEnvironmentEntry      // in path order
+}
+
+// folderDirs returns the folder directories with parents before children.
+func (t *syncTree) folderDirs() []string {
+	dirs := make([]string, 0, len(t.folders))
+	for dir := range t.folders {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// parseSyncFiles reads a synced directory. Files it does not recognise,
+// such as a README, are ignored; unknown keys in the files it does are an
+// error, so a typo is not silently dropped.
+func parseSyncFiles(files map[string]string) (*syncTree, error) {
+	tree := &syncTree{folders: map[string]*syncFolderEntry{}}
+	foundCollection := false
+	for _, name := range sortedStringKeys(files) {
+		file := path.Clean(strings.ReplaceAll(name, "\\", "/"))
+		if path.IsAbs(file) || file == ".." || strings.HasPrefix(file, "../") {
+			return nil, fmt.Errorf("%s: path must be relative to the collection directory", name)
+		}
+		dir, base := path.Split(file)
+		dir = strings.TrimSuffix(dir, "/")
+		content := files[name]
+
+		switch {
+		case file == syncCollectionFile:
+			if err := syncUnmarshal(content, &tree.collection); err != nil {
+				return nil, fmt.Errorf("%s: %w", file, err)
+			}
+			foundCollection = true
+		case dir == syncEnvironmentDir && strings.HasSuffix(base, ".yaml"):
+			entry := syncEnvironmentEntry{file: file}
+			if err := syncUnmarshal(content, &entry.environment); err != nil {
+				return nil, fmt.Errorf("%s: %w", file, err)
+			}
+			if entry.environment.Name == "" {
+				return nil, fmt.Errorf("%s: name is required", file)
+			}
+			tree.environments = append(tree.environments, entry)
+		case base == syncFolderFile && dir != "":
+			if depth := strings.Count(dir, "/") + 1; depth > maxFolderDepth {
+				return nil, fmt.Errorf("%s: folders can be nested at most %d levels deep", file, maxFolderDepth)
+			}
+			entry := &syncFolderEntry{file: file, dir: dir}
+			if err := syncUnmarshal(content, &entry.folder); err != nil {
+				return nil, fmt.Errorf("%s: %w", file, err)
+			}
+			if entry.folder.Name == "" {
+				return nil, fmt.Errorf("%s: name is required", file)
+			}
+			tree.folders[dir] = entry
+		case strings.HasSuffix(base, syncRequestSuffix):
+			entry := syncRequestEntry{file: file, dir: dir}
+			if err := syncUnmarshal(content, &entry.request); err != nil {
+				return nil, fmt.Errorf("%s: %w", file, err)
+			}
+			if entry.request.Name == "" || entry.request.Method == "" {
+				return nil, fmt.Errorf("%s: name and method are required", file)
+			}
+			tree.requests = append(tree.requests, entry)
+		}
+	}
+	if !foundCollection {
+		return nil, fmt.Errorf("%s is missing", syncCollectionFile)
+	}
+	if tree.collection.Name == "" {
+		return nil, fmt.Errorf("%s: name is required", syncCollectionFile)
+	}
+
+	for dir, entry := range tree.folders {
+		if parent := syncParentDir(dir); parent != "" && tree.folders[parent] == nil {
+			return nil, fmt.Errorf("%s: %s has no %s", entry.file, parent, syncFolderFile)
+		}
+	}
+	for _, entry := range tree.requests {
+		if entry.dir != "" && tree.folders[entry.dir] == nil {
+			return nil, fmt.Errorf("%s: %s has no %s", entry.file, entry.dir, syncFolderFile)
+		}
+	}
+	return tree, nil
+}
+
+// columns returns the database columns a collection file sets.
+func (c syncCollection) columns() (map[string]interface{}, error) {
+	return syncColumns(map[string]interface{}{"name": c.Name, "description": c.Description},
+		map[string]interface{}{"auth": c.Auth, "settings": c.Settings})
+}
+
+func (f syncFolder) columns() (map[string]interface{}, error) {
+	return syncColumns(map[string]interface{}{"name": f.Name, "description": f.Description, "position": f.Position},
+		map[string]interface{}{"auth": f.Auth, "settings": f.Settings, "variables": f.Variables})
+}
+
+func (r syncRequest) columns() (map[string]interface{}, error) {
+	columns, err := syncColumns(map[string]interface{}{
+		"name":        r.Name,
+		"description": r.Description,
+		"kind":        r.Kind,
+		"method":      r.Method,
+		"url":         r.URL,
+		"position":    r.Position,
+	}, map[string]interface{}{
+		"headers":      r.Headers,
+		"query_params": r.Query,
+		"auth":         r.Auth,
+		"settings":     r.Settings,
+		"graphql":      r.GraphQL,
+		"grpc":         r.GRPC,
+		"stream":       r.Stream,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	columns["body_mode"], columns["body"] = "", ""
+	if r.Body != nil {
+		columns["body_mode"] = r.Body.Mode
+		var body interface{} = r.Body.JSON
+		if r.Body.Text != nil {
+			body = *r.Body.Text
+		}
+		if columns["body"], err = syncColumn(body); err != nil {
+			return nil, fmt.Errorf("body: %w", err)
+		}
+	}
+	return columns, nil
+}
+
+// columns returns the fields compared to decide whether an environment
+// changed. Secrets are left out as they are never synced.
+func (e syncEnvironment) columns() map[string]interface{} {
+	variables, _ := json.Marshal(e.Variables)
+	return map[string]interface{}{"name": e.Name, "type": e.Type, "description": e.Description, "variables": string(variables)}
+}
+
+// syncColumns adds JSON columns, encoded, to plain ones.
+func syncColumns(plain, jsonColumns map[string]interface{}) (map[string]interface{}, error) {
+	for column, value := range jsonColumns {
+		encoded, err := syncColumn(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", column, err)
+		}
+		plain[column] = encoded
+	}
+	return plain, nil
+}
+
+// syncJSONColumns are the columns syncColumn encodes, plus body.
+var syncJSONColumns = map[string]bool{
+	"auth": true, "settings": true, "variables": true, "headers": true, "query_params": true,
+	"body": true, "graphql": true, "grpc": true, "stream": true,
+}
+
+// syncNulls returns columns with empty JSON columns set to NULL, as jsonb
+// rejects an empty string. Updates with a map skip the models' serializers.
+func syncNulls(columns map[string]interface{}) map[string]interface{} {
+	updates := make(map[string]interface{}, len(columns))
+	for column, value := range columns {
+		if syncJSONColumns[column] && value == "" {
+			value = nil
+		}
+		updates[column] = value
+	}
+	return updates
+}
+
+// syncValue decodes a JSON column for a YAML file. Empty columns are nil.
+func syncValue(column string) interface{} {
+	column = strings.TrimSpace(column)
+	if column == "" || column == "null" {
+		return nil
+	}
+	var value interface{}
+	if err := json.Unmarshal([]byte(column), &value); err != nil {
+		return column
+	}
+	return value
+}
+
+// syncColumn encodes a value read from a YAML file for a JSON column. Keys
+// are sorted, so equal values always encode the same.
+func syncColumn(value interface{}) (string, error) {
+	if value == nil {
+		return "", nil
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", errors.New("must only use string keys")
+	}
+	return string(encoded), nil
+}
+
+// changedSyncFields lists the columns whose values differ.
+func changedSyncFields(current, desired map[string]interface{}) []string {
+	var fields []string
+	for _, column := range sortedKeys(desired) {
+		if current[column] != desired[column] {
+			fields = append(fields, column)
+		}
+	}
+	return fields
+}
+
+func syncParentDir(dir string) string {
+	if parent := path.Dir(dir); parent != "." {
+		return parent
+	}
+	return ""
+}
+
+func syncParentKey(id *uuid.UUID) string {
+	if id == nil {
+		return ""
+	}
+	return id.String()
+}
+
+func syncID(id uuid.UUID) string {
+	if id == uuid.Nil {
+		return ""
+	}
+	return id.String()
+}
+
+func syncMarshal(value interface{}) (string, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(value); err != nil {
+		return "", err
+	}
+	if err := encoder.Close(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func syncUnmarshal(content string, value interface{}) error {
+	decoder := yaml.NewDecoder(strings.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(value); err != nil {
+		if errors.Is(err, io.EOF) {
+			return errors.New("file is empty")
+		}
+		return err
+	}
+	return nil
+}
+
+var syncSlugChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// claimSyncSlug turns a name into a file name not yet used among its
+// siblings.
+func claimSyncSlug(used map[string]bool, name string) string {
+	base := strings.Trim(syncSlugChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if base == "" {
+		base = "untitled"
+	}
+	slug := base
+	for i := 2; used[slug]; i++ {
+		slug = fmt.Sprintf("%s-%d", base, i)
+	}
+	used[slug] = true
+	return slug
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"backend/models"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncLayout(t *testing.T) {
+	data := exportFixture()
+	data.tree[0].Items[1].Request.Stream = `{"duration_ms":5000,"assertions":[{"type":"message_count","min":1}]}`
+	files, paths, err := syncLayout(data)
+	require.NoError(t, err)
+
+	names := sortedStringKeys(files)
+	assert.Equal(t, []string{
+		"collection.yaml",
+		"environments/staging.yaml",
+		"events.request.yaml",
+		"orders/create-order.request.yaml",
+		"orders/folder.yaml",
+		"orders/get-order.request.yaml",
+	}, names)
+	assert.Equal(t, "orders/get-order.request.yaml", paths[data.tree[0].Items[0].Request.ID])
+
+	assert.Equal(t, "id: "+data.environments[0].ID.String()+`
+name: Staging
+variables:
+  - key: baseUrl
+    value: https://staging.shop.test
+secrets:
+  - token
+`, files["environments/staging.yaml"], "secret values are never written")
+	assert.Contains(t, files["orders/create-order.request.yaml"], "body:\n  mode: json\n  json:\n    note: gift\n    qty: 2\n")
+	assert.Contains(t, files["orders/create-order.request.yaml"], "assertions:")
+
+	again, _, err := syncLayout(data)
+	require.NoError(t, err)
+	assert.Equal(t, files, again, "layout is stable")
+}
+
+func TestParseSyncFiles_RoundTrips(t *testing.T) {
+	data := exportFixture()
+	files, _, err := syncLayout(data)
+	require.NoError(t, err)
+	files["README.md"] = "not synced"
+
+	tree, err := parseSyncFiles(files)
+	require.NoError(t, err)
+	assert.Equal(t, "Shop API", tree.collection.Name)
+	assert.Equal(t, []string{"orders"}, tree.folderDirs())
+	require.Len(t, tree.requests, 3)
+	require.Len(t, tree.environments, 1)
+
+	// Every item parses back to the columns it was written from
+	for _, entry := range tree.requests {
+		var original *syncRequest
+		walkExportTree(data.tree, nil, func(request *models.Request, _ []models.Folder) {
+			if request.ID.String() == entry.request.ID {
+				synced := requestToSync(request)
+				original = &synced
+			}
+		})
+		require.NotNil(t, original, entry.file)
+		want, err := original.columns()
+		require.NoError(t, err)
+		got, err := entry.request.columns()
+		require.NoError(t, err)
+		assert.Empty(t, changedSyncFields(want, got), entry.file)
+	}
+	want, _ := collectionToSync(data.collection).columns()
+	got, _ := tree.collection.columns()
+	assert.Empty(t, changedSyncFields(want, got))
+	assert.Empty(t, changedSyncFields(environmentToSync(data.environments[0]).columns(), tree.environments[0].environment.columns()))
+}
+
+func TestParseSyncFiles_DetectsChanges(t *testing.T) {
+	data := exportFixture()
+	files, _, err := syncLayout(data)
+	require.NoError(t, err)
+	files["orders/get-order.request.yaml"] = strings.Replace(files["orders/get-order.request.yaml"], "X-Tenant: acme", "X-Tenant: globex", 1)
+	files["orders/get-order.request.yaml"] = strings.Replace(files["orders/get-order.request.yaml"], "url: '{{baseUrl}}/orders/42'", "url: '{{baseUrl}}/orders/43'", 1)
+
+	tree, err := parseSyncFiles(files)
+	require.NoError(t, err)
+	want, _ := requestToSync(data.tree[0].Items[0].Request).columns()
+	for _, entry := range tree.requests {
+		if entry.file == "orders/get-order.request.yaml" {
+			got, err := entry.request.columns()
+			require.NoError(t, err)
+			assert.Equal(t, []string{"headers", "url"}, changedSyncFields(want, got))
+		}
+	}
+}
+
+func TestParseSyncFiles_Errors(t *testing.T) {
+	collection := "name: Shop API\n"
+	request := "name: Ping\nmethod: GET\nurl: /ping\nposition: 0\n"
+
+	_, err := parseSyncFiles(map[string]string{"ping.request.yaml": request})
+	assert.ErrorContains(t, err, "collection.yaml is missing")
+
+	_, err = parseSyncFiles(map[string]string{"collection.yaml": collection, "health/ping.request.yaml": request})
+	assert.ErrorContains(t, err, "health has no folder.yaml")
+
+	_, err = parseSyncFiles(map[string]string{"collection.yaml": collection, "ping.request.yaml": request + "methd: POST\n"})
+	assert.ErrorContains(t, err, "field methd not found", "unknown keys are rejected")
+
+	_, err = parseSyncFiles(map[string]string{"collection.yaml": collection, "../ping.request.yaml": request})
+	assert.Error(t, err)
+}
+
+func TestClaimSyncSlug(t *testing.T) {
+	used := map[string]bool{"environments": true}
+	assert.Equal(t, "get-order", claimSyncSlug(used, "Get order"))
+	assert.Equal(t, "get-order-2", claimSyncSlug(used, "GET /order"))
+	assert.Equal(t, "environments-2", claimSyncSlug(used, "Environments"))
+	assert.Equal(t, "untitled", claimSyncSlug(used, "🙂"))
+}
+
+func TestSyncLayout_RedactsAuthSecrets(t *testing.T) {
+	data := exportFixture()
+	request := data.tree[0].Items[0].Request
+	request.Auth = `{"type":"bearer","bearer":{"token":"s3cret"}}`
+	files, _, err := syncLayout(data)
+	require.NoError(t, err)
+
+	file := files["orders/get-order.request.yaml"]
+	assert.NotContains(t, file, "s3cret")
+	assert.Contains(t, file, "token: '{{redacted}}'")
+
+	// Pushing the placeholder back is not a change and keeps the stored token
+	tree, err := parseSyncFiles(files)
+	require.NoError(t, err)
+	want, _ := requestToSync(request).columns()
+	for _, entry := range tree.requests {
+		if entry.file == "orders/get-order.request.yaml" {
+			got, err := entry.request.columns()
+			require.NoError(t, err)
+			assert.Empty(t, changedSyncFields(want, got))
+			assert.JSONEq(t, request.Auth, mergeAuthSecrets(got["auth"].(string), request.Auth))
+		}
+	}
+}
+
+func TestSyncNulls(t *testing.T) {
+	assert.Equal(t, map[string]interface{}{"name": "", "auth": nil, "settings": `{"timeout_ms":100}`},
+		syncNulls(map[string]interface{}{"name": "", "auth": "", "settings": `{"timeout_ms":100}`}))
+}
@@ -0,0 +1,735 @@
+package services
+
+import (
+	"backend/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Contract violation sources.
+const (
+	ViolationSourceExecution = "execution"
+	ViolationSourceSpan      = "span"
+)
+
+// StreamAssertContract is the assertion type added to executions by
+// contracts that fail on violation.
+const StreamAssertContract = "contract"
+
+// maxViolationBuckets bounds the time buckets returned per endpoint.
+const maxViolationBuckets = 500
+
+// ContractService stores contracts and checks executions and spans
+// against them.
+type ContractService struct {
+	db               *gorm.DB
+	workspaceService *WorkspaceService
+	validator        *SchemaValidator
+	specs            *SpecService
+}
+
+func NewContractService(db *gorm.DB) *ContractService {
+	return &ContractService{
+		db:               db,
+		workspaceService: NewWorkspaceService(db),
+		validator:        NewSchemaValidator(),
+		specs:            NewSpecService(db),
+	}
+}
+
+// Create adds a contract to a workspace. A contract applies either to one
+// request or to every call of a method and path template.
+func (s *ContractService) Create(workspaceID, userID uuid.UUID, contract *models.Contract) (*models.Contract, error) {
+	if !s.workspaceService.HasAccess(workspaceID, userID) {
+		return nil, errors.New("access denied")
+	}
+	contract.ID = uuid.Nil
+	contract.WorkspaceID = workspaceID
+	if contract.Source == "" {
+		contract.Source = "manual"
+	}
+	if err := s.validate(contract); err != nil {
+		return nil, err
+	}
+	if err := s.db.Create(contract).Error; err != nil {
+		return nil, err
+	}
+	return contract, nil
+}
+
+// List returns a workspace's contracts, optionally only those of a request
+// or a spec.
+func (s *ContractService) List(workspaceID, userID uuid.UUID, requestID, specID *uuid.UUID) ([]models.Contract, error) {
+	if !s.workspaceService.HasAccess(workspaceID, userID) {
+		return nil, errors.New("access denied")
+	}
+	query := s.db.Where("workspace_id = ?", workspaceID)
+	if requestID != nil {
+		query = query.Where("request_id = ?", *requestID)
+	}
+	if specID != nil {
+		query = query.Where("spec_id = ?", *specID)
+	}
+	var contracts []models.Contract
+	err := query.Order("path_template, method, status_code").Find(&contracts).Error
+	return contracts, err
+}
+
+func (s *ContractService) GetByID(workspaceID, contractID, userID uuid.UUID) (*models.Contract, error) {
+	if !s.workspaceService.HasAccess(workspaceID, userID) {
+		return nil, errors.New("access denied")
+	}
+	var contract models.Contract
+	if err := s.db.Where("id = ? AND workspace_id = ?", contractID, workspaceID).First(&contract).Error; err != nil {
+		return nil, err
+	}
+	return &contract, nil
+}
+
+// Update changes a contract's fields. Its workspace and source are kept.
+func (s *ContractService) Update(workspaceID, contractID, userID uuid.UUID, updates map[string]interface{}) (*models.Contract, error) {
+	contract, err := s.GetByID(workspaceID, contractID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := map[string]bool{
+		"name": true, "request_id": true, "method": true, "path_template": true, "status_code": true,
+		"request_schema": true, "response_schema": true, "fail_on_violation": true,
+	}
+	for key := range updates {
+		if !allowed[key] {
+			return nil, fmt.Errorf("%s cannot be updated", key)
+		}
+	}
+
+	updated := *contract
+	encoded, _ := json.Marshal(updates)
+	if err := json.Unmarshal(encoded, &updated); err != nil {
+		return nil, err
+	}
+	if err := s.validate(&updated); err != nil {
+		return nil, err
+	}
+	// Updating from the struct lets the empty schema be stored as NULL
+	if err := s.db.Model(contract).
+		Select("name", "request_id", "method", "path_template", "status_code", "request_schema", "response_schema", "fail_on_violation").
+		Updates(&updated).Error; err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+func (s *ContractService) Delete(workspaceID, contractID, userID uuid.UUID) error {
+	contract, err := s.GetByID(workspaceID, contractID, userID)
+	if err != nil {
+		return err
+	}
+	return s.db.Delete(contract).Error
+}
+
+// validate normalises a contract and checks it can be applied.
+func (s *ContractService) validate(contract *models.Contract) error {
+	contract.Method = strings.ToUpper(strings.TrimSpace(contract.Method))
+	contract.PathTemplate = strings.TrimSpace(contract.PathTemplate)
+	contract.StatusCode = strings.ToUpper(strings.TrimSpace(contract.StatusCode))
+	if contract.StatusCode == "DEFAULT" {
+		contract.StatusCode = "default"
+	}
+
+	if contract.RequestID != nil {
+		var request models.Request
+		err := s.db.Select("requests.id").Joins("JOIN collections ON collections.id = requests.collection_id").
+			Where("requests.id = ? AND collections.workspace_id = ?", *contract.RequestID, contract.WorkspaceID).
+			First(&request).Error
+		if err != nil {
+			return errors.New("request not found in this workspace")
+		}
+	} else {
+		if contract.Method == "" || !strings.HasPrefix(contract.PathTemplate, "/") {
+			return errors.New("a contract needs a request or a method and a path template starting with /")
+		}
+	}
+	if contract.Name == "" {
+		contract.Name = strings.TrimSpace(contract.Method + " " + contract.PathTemplate + " " + contract.StatusCode)
+	}
+	if contract.StatusCode != "" && contract.StatusCode != "default" && statusRank(contract.StatusCode, 0) < 0 {
+		return errors.New("status_code must be a status such as 200, a class such as 2XX, or default")
+	}
+	for name, schema := range map[string]string{"request_schema": contract.RequestSchema, "response_schema": contract.ResponseSchema} {
+		if schema != "" && !json.Valid([]byte(schema)) {
+			return fmt.Errorf("%s must be a JSON schema", name)
+		}
+	}
+	if contract.RequestSchema == "" && contract.ResponseSchema == "" {
+		return errors.New("a contract needs a request or response schema")
+	}
+	return nil
+}
+
+// CheckExecution validates an execution of request against the contracts
+// that apply to it and records violations. Contracts that fail on violation
+// add their outcome to the execution's assertion results. rawURL is the URL
+// the request was sent to.
+func (s *ContractService) CheckExecution(workspaceID uuid.UUID, request *models.Request, execution *models.Execution, rawURL string) error {
+	if execution.StatusCode == 0 || !contractCheckable(request) {
+		return nil
+	}
+	method := strings.ToUpper(request.Method)
+	contracts, err := s.candidates(workspaceID, &request.ID, method)
+	if err != nil || len(contracts) == 0 {
+		return err
+	}
+	urlPath := contractURLPath(rawURL)
+	exchange := executionExchange(request, execution, rawURL)
+
+	var assertions []StreamAssertionResult
+	var firstErr error
+	for _, contract := range applicableContracts(contracts, &request.ID, method, urlPath, execution.StatusCode) {
+		violations, err := s.check(contract, exchange)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("contract %q: %w", contract.Name, err)
+			}
+			continue
+		}
+		if len(violations) > 0 {
+			violation := contractViolation(contract, ViolationSourceExecution, method, urlPath, execution.StatusCode, violations)
+			violation.ExecutionID = &execution.ID
+			violation.SpanID = execution.SpanID
+			if execution.TraceID != uuid.Nil {
+				violation.TraceID = &execution.TraceID
+			}
+			if err := s.db.Create(violation).Error; err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		if contract.FailOnViolation {
+			assertions = append(assertions, contractAssertion(contract, violations))
+		}
+	}
+
+	if len(assertions) > 0 {
+		var results []StreamAssertionResult
+		if execution.AssertionResults != "" {
+			json.Unmarshal([]byte(execution.AssertionResults), &results)
+		}
+		encoded, _ := json.Marshal(append(results, assertions...))
+		execution.AssertionResults = string(encoded)
+		if err := s.db.Model(execution).Update("assertion_results", execution.AssertionResults).Error; err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// contractCheckable reports whether executions of request are plain
+// request/response exchanges that contracts can be checked against. Streams
+// and gRPC calls are not.
+func contractCheckable(request *models.Request) bool {
+	switch request.Kind {
+	case "", RequestKindHTTP, RequestKindGraphQL:
+		return true
+	}
+	return false
+}
+
+// CheckSpans validates ingested spans that carry a request or response body
+// in their http.request.body or http.response.body tags, and records
+// violations. Spans without bodies are skipped.
+func (s *ContractService) CheckSpans(workspaceID uuid.UUID, spans []models.Span) error {
+	var firstErr error
+	cache := map[string][]models.Contract{}
+	for i := range spans {
+		call, ok := spanCall(&spans[i])
+		if !ok {
+			continue
+		}
+		key := call.method
+		if call.requestID != nil {
+			key += " " + call.requestID.String()
+		}
+		contracts, cached := cache[key]
+		if !cached {
+			var err error
+			if contracts, err = s.candidates(workspaceID, call.requestID, call.method); err != nil {
+				return err
+			}
+			cache[key] = contracts
+		}
+
+		for _, contract := range applicableContracts(contracts, call.requestID, call.method, call.path, call.status) {
+			violations, err := s.check(contract, call.exchange())
+			if err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("contract %q: %w", contract.Name, err)
+				}
+				continue
+			}
+			if len(violations) == 0 {
+				continue
+			}
+			violation := contractViolation(contract, ViolationSourceSpan, call.method, call.path, call.status, violations)
+			violation.TraceID = &spans[i].TraceID
+			violation.SpanID = &spans[i].ID
+			if err := s.db.Create(violation).Error; err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// candidates loads the contracts that might apply to a call: those of its
+// request and those of its method.
+func (s *ContractService) candidates(workspaceID uuid.UUID, requestID *uuid.UUID, method string) ([]models.Contract, error) {
+	query := s.db.Where("workspace_id = ?", workspaceID)
+	if requestID != nil {
+		query = query.Where("request_id = ? OR (request_id IS NULL AND method = ?)", *requestID, method)
+	} else {
+		query = query.Where("request_id IS NULL AND method = ?", method)
+	}
+	var contracts []models.Contract
+	err := query.Find(&contracts).Error
+	return contracts, err
+}
+
+// check validates a call against a contract. A contract imported with its
+// spec checks the whole operation: parameters, status, headers and bodies.
+// Other contracts check bodies against their schemas: a request body only
+// when there is one that is not of a non-JSON media type, while a response
+// schema needs a JSON body.
+func (s *ContractService) check(contract models.Contract, exchange OpenAPIExchange) ([]ValidationError, error) {
+	if contract.SpecID != nil {
+		// A spec that cannot be loaded falls back to the contract's schemas
+		if validator, err := s.specs.validatorFor(*contract.SpecID); err == nil {
+			result, err := validator.Validate(exchange)
+			if err != nil {
+				return nil, err
+			}
+			return result.Errors, nil
+		}
+	}
+
+	var violations []ValidationError
+	requestBody := exchange.RequestBody
+	mediaType := bodyMediaType(exchange.RequestHeaders, requestBody)
+	if contract.RequestSchema != "" && strings.TrimSpace(requestBody) != "" && (mediaType == "" || isJSONMediaType(mediaType)) {
+		errs, err := s.validateBody(contract.RequestSchema, requestBody, "request")
+		if err != nil {
+			return nil, err
+		}
+		violations = append(violations, errs...)
+	}
+	if contract.ResponseSchema != "" {
+		errs, err := s.validateBody(contract.ResponseSchema, exchange.ResponseBody, "response")
+		if err != nil {
+			return nil, err
+		}
+		violations = append(violations, errs...)
+	}
+	return violations, nil
+}
+
+func (s *ContractService) validateBody(schema, body, in string) ([]ValidationError, error) {
+	switch {
+	case strings.TrimSpace(body) == "":
+		return []ValidationError{{In: in, Field: "(root)", Type: "required", Description: in + " has no body"}}, nil
+	case !json.Valid([]byte(body)):
+		return []ValidationError{{In: in, Field: "(root)", Type: "invalid_json", Description: in + " body is not JSON"}}, nil
+	}
+	result, err := s.validator.ValidateAgainstOpenAPI(body, schema)
+	if err != nil {
+		return nil, err
+	}
+	for i := range result.Errors {
+		result.Errors[i].In = in
+	}
+	return result.Errors, nil
+}
+
+// applicableContracts picks the contracts to check a call against. Contracts
+// are grouped by what they attach to (a request, or a method and path
+// template), and from each group the one for the response status is used:
+// an exact status beats a class such as 2XX, which beats default.
+func applicableContracts(contracts []models.Contract, requestID *uuid.UUID, method, urlPath string, status int) []models.Contract {
+	best := map[string]models.Contract{}
+	rank := map[string]int{}
+	for _, contract := range contracts {
+		var key string
+		switch {
+		case contract.RequestID != nil:
+			if requestID == nil || *contract.RequestID != *requestID {
+				continue
+			}
+			key = "request " + contract.RequestID.String()
+		case strings.EqualFold(contract.Method, method) && pathTemplateMatches(contract.PathTemplate, urlPath):
+			key = contract.Method + " " + contract.PathTemplate
+		default:
+			continue
+		}
+		r := statusRank(contract.StatusCode, status)
+		if r <= 0 {
+			continue
+		}
+		if current, ok := rank[key]; !ok || r > current {
+			best[key], rank[key] = contract, r
+		}
+	}
+
+	applicable := make([]models.Contract, 0, len(best))
+	for _, contract := range best {
+		applicable = append(applicable, contract)
+	}
+	sort.Slice(applicable, func(i, j int) bool { return applicable[i].Name < applicable[j].Name })
+	return applicable
+}
+
+// statusRank scores how well a contract's status code matches a response
+// status: 3 for an exact match, 2 for a class such as 2XX, 1 for default or
+// no status, 0 for no match and -1 for a status code that cannot be read.
+func statusRank(contractStatus string, status int) int {
+	switch {
+	case contractStatus == "" || contractStatus == "default":
+		return 1
+	case len(contractStatus) == 3 && strings.HasSuffix(contractStatus, "XX"):
+		class, err := strconv.Atoi(contractStatus[:1])
+		if err != nil || class < 1 || class > 5 {
+			return -1
+		}
+		if status/100 == class {
+			return 2
+		}
+		return 0
+	}
+	code, err := strconv.Atoi(contractStatus)
+	if err != nil || code < 100 || code > 599 {
+		return -1
+	}
+	if code == status {
+		return 3
+	}
+	return 0
+}
+
+// pathTemplateMatches reports whether a URL path is an instance of a path
+// template such as /orders/{id}. The path may have a prefix, such as the
+// base path of the server the template is relative to.
+func pathTemplateMatches(template, urlPath string) bool {
+	templateSegments := strings.Split(strings.Trim(template, "/"), "/")
+	pathSegments := strings.Split(strings.Trim(urlPath, "/"), "/")
+	if len(pathSegments) < len(templateSegments) {
+		return false
+	}
+	pathSegments = pathSegments[len(pathSegments)-len(templateSegments):]
+	for i, segment := range templateSegments {
+		if openAPIPathParam.MatchString(segment) {
+			if !templateSegmentPattern(segment).MatchString(pathSegments[i]) {
+				return false
+			}
+			continue
+		}
+		if segment != pathSegments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// templateSegmentPattern matches a path segment against a template segment
+// such as {id} or {name}.json.
+func templateSegmentPattern(segment string) *regexp.Regexp {
+	var pattern strings.Builder
+	pattern.WriteString("^")
+	last := 0
+	for _, loc := range openAPIPathParam.FindAllStringIndex(segment, -1) {
+		pattern.WriteString(regexp.QuoteMeta(segment[last:loc[0]]))
+		pattern.WriteString("([^/]+)")
+		last = loc[1]
+	}
+	pattern.WriteString(regexp.QuoteMeta(segment[last:]))
+	pattern.WriteString("$")
+	return regexp.MustCompile(pattern.String())
+}
+
+// contractURLPath returns the path of a request URL, without its query.
+func contractURLPath(rawURL string) string {
+	rawURL, _, _ = strings.Cut(rawURL, "#")
+	if parsed, err := url.Parse(rawURL); err == nil && parsed.Path != "" {
+		return parsed.Path
+	}
+	rawURL, _, _ = strings.Cut(rawURL, "?")
+	if i := strings.Index(rawURL, "://"); i >= 0 {
+		rawURL = rawURL[i+3:]
+		if slash := strings.Index(rawURL, "/"); slash >= 0 {
+			return rawURL[slash:]
+		}
+		return "/"
+	}
+	return rawURL
+}
+
+func contractViolation(contract models.Contract, source, method, urlPath string, status int, errs []ValidationError) *models.ContractViolation {
+	encoded, _ := json.Marshal(errs)
+	path := contract.PathTemplate
+	if path == "" {
+		path = urlPath
+	}
+	return &models.ContractViolation{
+		WorkspaceID: contract.WorkspaceID,
+		ContractID:  contract.ID,
+		Source:      source,
+		Method:      method,
+		Path:        path,
+		StatusCode:  status,
+		Errors:      string(encoded),
+	}
+}
+
+// contractAssertion is the assertion result of a contract that fails on
+// violation.
+func contractAssertion(contract models.Contract, violations []ValidationError) StreamAssertionResult {
+	result := StreamAssertionResult{StreamAssertion: StreamAssertion{Type: StreamAssertContract, Value: contract.Name}, Passed: len(violations) == 0}
+	if len(violations) > 0 {
+		first := violations[0]
+		result.Message = fmt.Sprintf("%s %s: %s", first.In, first.Field, first.Description)
+		if len(violations) > 1 {
+			result.Message += fmt.Sprintf(" (and %d more)", len(violations)-1)
+		}
+	}
+	return result
+}
+
+// spanHTTPCall is what a span's tags say about the HTTP call it records.
+type spanHTTPCall struct {
+	method, url, path         string
+	status                    int
+	requestBody, responseBody string
+	requestID                 *uuid.UUID
+}
+
+// spanCall reads an HTTP call from span tags. Only spans with a method and
+// a request or response body are checked against contracts.
+func spanCall(span *models.Span) (spanHTTPCall, bool) {
+	tags := spanTags(span)
+	call := spanHTTPCall{
+		requestBody:  spanTag(tags, "http.request.body"),
+		responseBody: spanTag(tags, "http.response.body"),
+	}
+	call.method, call.url = spanEndpoint(tags)
+	if call.method == "" || (call.requestBody == "" && call.responseBody == "") {
+		return spanHTTPCall{}, false
+	}
+	call.path = contractURLPath(call.url)
+	call.status = spanStatusCode(tags)
+	if id, err := uuid.Parse(spanTag(tags, "request.id")); err == nil {
+		call.requestID = &id
+	}
+	return call, true
+}
+
+// spanTags decodes a span's tags; spans without valid tags have none.
+func spanTags(span *models.Span) map[string]interface{} {
+	var tags map[string]interface{}
+	if span.Tags != "" {
+		json.Unmarshal([]byte(span.Tags), &tags)
+	}
+	return tags
+}
+
+// spanTag returns the first of keys that is a non-empty string tag.
+func spanTag(tags map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		if value, ok := tags[key].(string); ok && value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// spanStatusCode returns the response status a span records, or 0.
+func spanStatusCode(tags map[string]interface{}) int {
+	for _, key := range []string{"http.status_code", "http.response.status_code"} {
+		switch value := tags[key].(type) {
+		case float64:
+			if value != 0 {
+				return int(value)
+			}
+		case string:
+			if status, err := strconv.Atoi(value); err == nil && status != 0 {
+				return status
+			}
+		}
+	}
+	return 0
+}
+
+// spanEndpoint returns the method and URL, or target path, of the HTTP call
+// a span records, under either the old or the stable semantic conventions.
+func spanEndpoint(tags map[string]interface{}) (method, rawURL string) {
+	method = strings.ToUpper(spanTag(tags, "http.method", "http.request.method"))
+	rawURL = spanTag(tags, "http.target", "url.path", "http.url", "url.full")
+	return method, rawURL
+}
+
+// exchange is the call as far as span tags capture it: headers are not.
+func (c spanHTTPCall) exchange() OpenAPIExchange {
+	return OpenAPIExchange{
+		Method:       c.method,
+		URL:          c.url,
+		RequestBody:  c.requestBody,
+		StatusCode:   c.status,
+		ResponseBody: c.responseBody,
+	}
+}
+
+// ContractViolationFilter narrows a violation listing. Zero values do not
+// filter.
+type ContractViolationFilter struct {
+	ContractID *uuid.UUID
+	Method     string
+	Path       string
+	Since      time.Time
+	Until      time.Time
+	Limit      int
+	Offset     int
+}
+
+// ListViolations returns a workspace's violations, newest first, and how
+// many match the filter in total.
+func (s *ContractService) ListViolations(workspaceID, userID uuid.UUID, filter ContractViolationFilter) ([]models.ContractViolation, int64, error) {
+	if !s.workspaceService.HasAccess(workspaceID, userID) {
+		return nil, 0, errors.New("access denied")
+	}
+	query := s.violationQuery(workspaceID, filter)
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = 50
+	}
+	var violations []models.ContractViolation
+	err := query.Order("created_at DESC").Limit(filter.Limit).Offset(filter.Offset).Find(&violations).Error
+	return violations, total, err
+}
+
+// EndpointViolations is how often an endpoint broke its contracts over a
+// time range, in buckets of equal width.
+type EndpointViolations struct {
+	Method   string            `json:"method"`
+	Path     string            `json:"path"`
+	Total    int               `json:"total"`
+	LastSeen time.Time         `json:"last_seen"`
+	Buckets  []ViolationBucket `json:"buckets"`
+}
+
+type ViolationBucket struct {
+	Start time.Time `json:"start"`
+	Count int       `json:"count"`
+}
+
+// ViolationsByEndpoint counts a workspace's violations per endpoint in
+// buckets of width between since and until, most violated endpoint first.
+func (s *ContractService) ViolationsByEndpoint(workspaceID, userID uuid.UUID, since, until time.Time, width time.Duration) ([]EndpointViolations, error) {
+	if !s.workspaceService.HasAccess(workspaceID, userID) {
+		return nil, errors.New("access denied")
+	}
+	var rows []models.ContractViolation
+	err := s.violationQuery(workspaceID, ContractViolationFilter{Since: since, Until: until}).
+		Select("method", "path", "created_at").Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	return bucketViolations(rows, since, until, width), nil
+}
+
+func (s *ContractService) violationQuery(workspaceID uuid.UUID, filter ContractViolationFilter) *gorm.DB {
+	query := s.db.Model(&models.ContractViolation{}).Where("workspace_id = ?", workspaceID)
+	if filter.ContractID != nil {
+		query = query.Where("contract_id = ?", *filter.ContractID)
+	}
+	if filter.Method != "" {
+		query = query.Where("method = ?", strings.ToUpper(filter.Method))
+	}
+	if filter.Path != "" {
+		query = query.Where("path = ?", filter.Path)
+	}
+	if !filter.Since.IsZero() {
+		query = query.Where("created_at >= ?", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		query = query.Where("created_at < ?", filter.Until)
+	}
+	return query
+}
+
+// bucketViolations groups violations by endpoint and time bucket. The
+// bucket width is widened if the range would need too many buckets.
+func bucketViolations(rows []models.ContractViolation, since, until time.Time, width time.Duration) []EndpointViolations {
+	if width <= 0 {
+		width = time.Hour
+	}
+	if count := until.Sub(since) / width; count > maxViolationBuckets {
+		width = until.Sub(since)/maxViolationBuckets + 1
+	}
+	buckets := int(until.Sub(since)/width) + 1
+
+	byEndpoint := map[string]*EndpointViolations{}
+	for _, row := range rows {
+		if row.CreatedAt.Before(since) || !row.CreatedAt.Before(until) {
+			continue
+		}
+		key := row.Method + " " + row.Path
+		endpoint := byEndpoint[key]
+		if endpoint == nil {
+			endpoint = &EndpointViolations{Method: row.Method, Path: row.Path, Buckets: make([]ViolationBucket, buckets)}
+			for i := range endpoint.Buckets {
+				endpoint.Buckets[i].Start = since.Add(time.Duration(i) * width)
+			}
+			byEndpoint[key] = endpoint
+		}
+		endpoint.Total++
+		endpoint.Buckets[int(row.CreatedAt.Sub(since)/width)].Count++
+		if row.CreatedAt.After(endpoint.LastSeen) {
+			endpoint.LastSeen = row.CreatedAt
+		}
+	}
+
+	endpoints := make([]EndpointViolations, 0, len(byEndpoint))
+	for _, endpoint := range byEndpoint {
+		endpoints = append(endpoints, *endpoint)
+	}
+	sort.Slice(endpoints, func(i, j int) bool {
+		if endpoints[i].Total != endpoints[j].Total {
+			return endpoints[i].Total > endpoints[j].Total
+		}
+		return endpoints[i].Method+" "+endpoints[i].Path < endpoints[j].Method+" "+endpoints[j].Path
+	})
+	return endpoints
+}
+
+// TimeRangeWindow turns a time_range (last_hour, last_24h, last_7d or
+// last_30d, as used by monitoring) into a start time and bucket width.
+func TimeRangeWindow(timeRange string, now time.Time) (time.Time, time.Duration) {
+	switch timeRange {
+	case "last_24h":
+		return now.Add(-24 * time.Hour), time.Hour
+	case "last_7d":
+		return now.Add(-7 * 24 * time.Hour), 6 * time.Hour
+	case "last_30d":
+		return now.Add(-30 * 24 * time.Hour), 24 * time.Hour
+	default:
+		return now.Add(-time.Hour), 5 * time.Minute
+	}
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"backend/models"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplicableContracts(t *testing.T) {
+	requestID := uuid.New()
+	contracts := []models.Contract{
+		{Name: "order ok", Method: "GET", PathTemplate: "/orders/{id}", StatusCode: "200"},
+		{Name: "order success", Method: "GET", PathTemplate: "/orders/{id}", StatusCode: "2XX"},
+		{Name: "order any", Method: "GET", PathTemplate: "/orders/{id}", StatusCode: "default"},
+		{Name: "order list", Method: "GET", PathTemplate: "/orders"},
+		{Name: "order create", Method: "POST", PathTemplate: "/orders/{id}"},
+		{Name: "saved request", RequestID: &requestID},
+		{Name: "other request", RequestID: ptrUUID(uuid.New())},
+	}
+
+	names := func(list []models.Contract) []string {
+		var out []string
+		for _, contract := range list {
+			out = append(out, contract.Name)
+		}
+		return out
+	}
+	assert.Equal(t, []string{"order ok", "saved request"}, names(applicableContracts(contracts, &requestID, "GET", "/api/v1/orders/42", 200)))
+	assert.Equal(t, []string{"order success"}, names(applicableContracts(contracts, nil, "get", "/orders/42", 201)))
+	assert.Equal(t, []string{"order any"}, names(applicableContracts(contracts, nil, "GET", "/orders/42", 404)))
+	assert.Empty(t, applicableContracts(contracts, nil, "DELETE", "/orders/42", 200))
+}
+
+func TestStatusRank(t *testing.T) {
+	assert.Equal(t, 3, statusRank("404", 404))
+	assert.Equal(t, 0, statusRank("404", 400))
+	assert.Equal(t, 2, statusRank("4XX", 404))
+	assert.Equal(t, 0, statusRank("2XX", 404))
+	assert.Equal(t, 1, statusRank("default", 500))
+	assert.Equal(t, 1, statusRank("", 500))
+	assert.Equal(t, -1, statusRank("9XX", 500))
+	assert.Equal(t, -1, statusRank("ok", 200))
+}
+
+func TestPathTemplateMatches(t *testing.T) {
+	assert.True(t, pathTemplateMatches("/orders/{id}", "/orders/42"))
+	assert.True(t, pathTemplateMatches("/orders/{id}", "/api/v1/orders/42/"), "a base path is allowed")
+	assert.True(t, pathTemplateMatches("/files/{name}.json", "/files/report.json"))
+	assert.False(t, pathTemplateMatches("/files/{name}.json", "/files/report.csv"))
+	assert.False(t, pathTemplateMatches("/orders/{id}", "/orders"))
+	assert.False(t, pathTemplateMatches("/orders/{id}", "/users/42"))
+
+	assert.Equal(t, "/orders/42", contractURLPath("https://shop.test/orders/42?expand=items#top"))
+	assert.Equal(t, "/orders/42", contractURLPath("/orders/42?x=1"))
+}
+
+func TestSpanCall(t *testing.T) {
+	requestID := uuid.New()
+	call, ok := spanCall(&models.Span{Tags: `{"http.method":"post","http.url":"https://shop.test/orders?x=1","http.status_code":201,"http.response.body":"{}","request.id":"` + requestID.String() + `"}`})
+	require.True(t, ok)
+	assert.Equal(t, spanHTTPCall{method: "POST", url: "https://shop.test/orders?x=1", path: "/orders", status: 201, responseBody: "{}", requestID: &requestID}, call)
+
+	call, ok = spanCall(&models.Span{Tags: `{"http.request.method":"GET","http.target":"/health","http.response.status_code":"200","http.response.body":"ok"}`})
+	require.True(t, ok)
+	assert.Equal(t, 200, call.status)
+	assert.Equal(t, "/health", call.path)
+
+	_, ok = spanCall(&models.Span{Tags: `{"http.method":"GET","http.url":"/orders"}`})
+	assert.False(t, ok, "spans without bodies are not checked")
+	_, ok = spanCall(&models.Span{Tags: `{"db.statement":"SELECT 1"}`})
+	assert.False(t, ok)
+}
+
+func TestContractService_Check(t *testing.T) {
+	s := &ContractService{validator: NewSchemaValidator()}
+	contract := models.Contract{
+		Name:           "create order",
+		RequestSchema:  `{"type":"object","required":["qty"],"properties":{"qty":{"type":"integer"}}}`,
+		ResponseSchema: `{"type":"object","required":["id"]}`,
+	}
+
+	call := func(requestBody, responseBody string) OpenAPIExchange {
+		return OpenAPIExchange{Method: "POST", URL: "/orders", RequestBody: requestBody, StatusCode: 201, ResponseBody: responseBody}
+	}
+	errs, err := s.check(contract, call(`{"qty":2}`, `{"id":"o-1"}`))
+	require.NoError(t, err)
+	assert.Empty(t, errs)
+
+	errs, err = s.check(contract, call("", `{"id":"o-1"}`))
+	require.NoError(t, err)
+	assert.Empty(t, errs, "a missing request body is not checked")
+
+	errs, err = s.check(contract, OpenAPIExchange{RequestHeaders: http.Header{"Content-Type": {"text/plain"}}, RequestBody: "two", ResponseBody: `{"id":1}`})
+	require.NoError(t, err)
+	assert.Empty(t, errs, "request bodies that are not JSON are not checked")
+
+	errs, err = s.check(contract, call(`{"qty":"two"}`, "<html>"))
+	require.NoError(t, err)
+	require.Len(t, errs, 2)
+	assert.Equal(t, "request", errs[0].In)
+	assert.Equal(t, "response", errs[1].In)
+	assert.Equal(t, "invalid_json", errs[1].Type)
+
+	result := contractAssertion(contract, errs)
+	assert.False(t, result.Passed)
+	assert.Equal(t, StreamAssertContract, result.Type)
+	assert.Contains(t, result.Message, "(and 1 more)")
+	assert.True(t, contractAssertion(contract, nil).Passed)
+}
+
+func TestBucketViolations(t *testing.T) {
+	since := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	until := since.Add(time.Hour)
+	at := func(minutes int) time.Time { return since.Add(time.Duration(minutes) * time.Minute) }
+	rows := []models.ContractViolation{
+		{Method: "GET", Path: "/orders/{id}", CreatedAt: at(1)},
+		{Method: "GET", Path: "/orders/{id}", CreatedAt: at(3)},
+		{Method: "GET", Path: "/orders/{id}", CreatedAt: at(59)},
+		{Method: "POST", Path: "/orders", CreatedAt: at(30)},
+		{Method: "POST", Path: "/orders", CreatedAt: at(61)},
+	}
+
+	endpoints := bucketViolations(rows, since, until, 5*time.Minute)
+	require.Len(t, endpoints, 2)
+	assert.Equal(t, "GET", endpoints[0].Method)
+	assert.Equal(t, 3, endpoints[0].Total)
+	assert.Equal(t, at(59), endpoints[0].LastSeen)
+	assert.Equal(t, 2, endpoints[0].Buckets[0].Count)
+	assert.Equal(t, 1, endpoints[0].Buckets[11].Count)
+	assert.Equal(t, at(55), endpoints[0].Buckets[11].Start)
+	assert.Equal(t, 1, endpoints[1].Total, "violations after until are left out")
+
+	wide := bucketViolations(rows, since.Add(-30*24*time.Hour), until, time.Second)
+	assert.LessOrEqual(t, len(wide[0].Buckets), maxViolationBuckets+1)
+}
+
+func ptrUUID(id uuid.UUID) *uuid.UUID { return &id }
+
+func TestContractService_CheckAgainstSpec(t *testing.T) {
+	specID := uuid.New()
+	s := &ContractService{
+		validator: NewSchemaValidator(),
+		specs:     &SpecService{validators: map[uuid.UUID]*OpenAPIValidator{specID: newTestValidator(t)}},
+	}
+	contract := models.Contract{Name: "GET /orders/{id} 200", SpecID: &specID, ResponseSchema: `{"type":"object"}`}
+
+	exchange := validGetOrder()
+	exchange.RequestHeaders = http.Header{}
+	errs, err := s.check(contract, exchange)
+	require.NoError(t, err)
+	require.Len(t, errs, 1, "the whole operation is checked, not only the body")
+	assert.Equal(t, "header", errs[0].In)
+}
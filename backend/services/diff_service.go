@@ -0,0 +1,420 @@
+package services
+
+import (
+	"backend/models"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Change types in an ExecutionDiff.
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeChanged = "changed"
+)
+
+// defaultIgnoredHeaders differ on every response and are never diffed.
+var defaultIgnoredHeaders = []string{"Date"}
+
+// DiffOptions controls what a diff skips.
+type DiffOptions struct {
+	IgnorePaths   []string `json:"ignore_paths,omitempty"`   // body paths, * matches one segment, e.g. data.items.*.updated_at
+	IgnoreHeaders []string `json:"ignore_headers,omitempty"` // header names, case-insensitive
+}
+
+// ExecutionDiff compares a target execution against a base one.
+type ExecutionDiff struct {
+	BaseExecutionID   uuid.UUID      `json:"base_execution_id"`
+	TargetExecutionID uuid.UUID      `json:"target_execution_id"`
+	Identical         bool           `json:"identical"` // status, headers and body match; timings are not considered
+	Status            StatusDiff     `json:"status"`
+	Headers           []HeaderChange `json:"headers"`
+	Body              BodyDiff       `json:"body"`
+	Timings           []TimingDelta  `json:"timings"`
+}
+
+type StatusDiff struct {
+	Base    int  `json:"base"`
+	Target  int  `json:"target"`
+	Changed bool `json:"changed"`
+}
+
+type HeaderChange struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Base   string `json:"base,omitempty"`
+	Target string `json:"target,omitempty"`
+}
+
+// BodyDiff lists path-level changes. Non-JSON bodies are compared as a whole
+// and reported as a single change at $.
+type BodyDiff struct {
+	Format  string       `json:"format"` // json, text
+	Changes []BodyChange `json:"changes"`
+}
+
+type BodyChange struct {
+	Path   string      `json:"path"` // dotted, array indexes as segments, e.g. data.items.0.id
+	Type   string      `json:"type"`
+	Base   interface{} `json:"base,omitempty"`
+	Target interface{} `json:"target,omitempty"`
+}
+
+type TimingDelta struct {
+	Name    string  `json:"name"`
+	Base    float64 `json:"base"`
+	Target  float64 `json:"target"`
+	DeltaMs float64 `json:"delta_ms"` // target - base
+}
+
+// DiffService compares executions with each other and with saved baselines.
+type DiffService struct {
+	db               *gorm.DB
+	workspaceService *WorkspaceService
+}
+
+func NewDiffService(db *gorm.DB) *DiffService {
+	return &DiffService{
+		db:               db,
+		workspaceService: NewWorkspaceService(db),
+	}
+}
+
+// Compare diffs two executions from the workspace.
+func (s *DiffService) Compare(workspaceID, userID, baseID, targetID uuid.UUID, opts DiffOptions) (*ExecutionDiff, error) {
+	if !s.workspaceService.HasAccess(workspaceID, userID) {
+		return nil, errors.New("access denied")
+	}
+
+	base, err := s.execution(workspaceID, baseID)
+	if err != nil {
+		return nil, err
+	}
+	target, err := s.execution(workspaceID, targetID)
+	if err != nil {
+		return nil, err
+	}
+
+	return DiffExecutions(base, target, opts), nil
+}
+
+// SetBaseline saves an execution of the request as its baseline, replacing any previous one.
+func (s *DiffService) SetBaseline(workspaceID, userID, requestID, executionID uuid.UUID, opts DiffOptions) (*models.ResponseBaseline, error) {
+	if !s.workspaceService.HasAccess(workspaceID, userID) {
+		return nil, errors.New("access denied")
+	}
+
+	execution, err := s.execution(workspaceID, executionID)
+	if err != nil {
+		return nil, err
+	}
+	if execution.RequestID != requestID {
+		return nil, errors.New("execution does not belong to this request")
+	}
+
+	ignorePaths, _ := json.Marshal(nonNil(opts.IgnorePaths))
+	ignoreHeaders, _ := json.Marshal(nonNil(opts.IgnoreHeaders))
+	baseline := models.ResponseBaseline{
+		RequestID:     requestID,
+		ExecutionID:   executionID,
+		IgnorePaths:   string(ignorePaths),
+		IgnoreHeaders: string(ignoreHeaders),
+		CreatedBy:     userID,
+	}
+
+	err = s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "request_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"execution_id", "ignore_paths", "ignore_headers", "created_by", "updated_at"}),
+	}).Create(&baseline).Error
+	if err != nil {
+		return nil, err
+	}
+
+	baseline.Execution = *execution
+	return &baseline, nil
+}
+
+// GetBaseline returns the request's baseline with its execution.
+func (s *DiffService) GetBaseline(workspaceID, userID, requestID uuid.UUID) (*models.ResponseBaseline, error) {
+	if !s.workspaceService.HasAccess(workspaceID, userID) {
+		return nil, errors.New("access denied")
+	}
+
+	var baseline models.ResponseBaseline
+	err := s.db.Joins("JOIN requests ON requests.id = response_baselines.request_id").
+		Joins("JOIN collections ON collections.id = requests.collection_id").
+		Where("response_baselines.request_id = ? AND collections.workspace_id = ?", requestID, workspaceID).
+		Preload("Execution").
+		First(&baseline).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &baseline, nil
+}
+
+// DeleteBaseline removes the request's baseline.
+func (s *DiffService) DeleteBaseline(workspaceID, userID, requestID uuid.UUID) error {
+	baseline, err := s.GetBaseline(workspaceID, userID, requestID)
+	if err != nil {
+		return err
+	}
+	return s.db.Delete(baseline).Error
+}
+
+// CompareToBaseline diffs an execution against the request's baseline. A nil
+// executionID compares the latest execution. The baseline's ignore lists are
+// combined with opts.
+func (s *DiffService) CompareToBaseline(workspaceID, userID, requestID, executionID uuid.UUID, opts DiffOptions) (*ExecutionDiff, error) {
+	baseline, err := s.GetBaseline(workspaceID, userID, requestID)
+	if err != nil {
+		return nil, err
+	}
+
+	var target *models.Execution
+	if executionID == uuid.Nil {
+		var latest models.Execution
+		err := s.db.Where("request_id = ? AND parent_execution_id IS NULL", requestID).
+			Order("timestamp DESC").
+			First(&latest).Error
+		if err != nil {
+			return nil, err
+		}
+		target = &latest
+	} else {
+		if target, err = s.execution(workspaceID, executionID); err != nil {
+			return nil, err
+		}
+	}
+
+	var saved DiffOptions
+	json.Unmarshal([]byte(baseline.IgnorePaths), &saved.IgnorePaths)
+	json.Unmarshal([]byte(baseline.IgnoreHeaders), &saved.IgnoreHeaders)
+	opts.IgnorePaths = append(saved.IgnorePaths, opts.IgnorePaths...)
+	opts.IgnoreHeaders = append(saved.IgnoreHeaders, opts.IgnoreHeaders...)
+
+	return DiffExecutions(&baseline.Execution, target, opts), nil
+}
+
+// execution loads an execution of a request in the workspace.
+func (s *DiffService) execution(workspaceID, executionID uuid.UUID) (*models.Execution, error) {
+	var execution models.Execution
+	err := s.db.Joins("JOIN requests ON requests.id = executions.request_id").
+		Joins("JOIN collections ON collections.id = requests.collection_id").
+		Where("executions.id = ? AND collections.workspace_id = ?", executionID, workspaceID).
+		First(&execution).Error
+	if err != nil {
+		return nil, err
+	}
+	return &execution, nil
+}
+
+// DiffExecutions compares target against base.
+func DiffExecutions(base, target *models.Execution, opts DiffOptions) *ExecutionDiff {
+	diff := &ExecutionDiff{
+		BaseExecutionID:   base.ID,
+		TargetExecutionID: target.ID,
+		Status: StatusDiff{
+			Base:    base.StatusCode,
+			Target:  target.StatusCode,
+			Changed: base.StatusCode != target.StatusCode,
+		},
+		Headers: diffHeaders(base.ResponseHeaders, target.ResponseHeaders, opts.IgnoreHeaders),
+		Body:    diffBodies(base.ResponseBody, target.ResponseBody, opts.IgnorePaths),
+		Timings: []TimingDelta{
+			timingDelta("response_time_ms", float64(base.ResponseTimeMs), float64(target.ResponseTimeMs)),
+			timingDelta("dns_lookup_ms", base.DNSLookupMs, target.DNSLookupMs),
+			timingDelta("tcp_connect_ms", base.TCPConnectMs, target.TCPConnectMs),
+			timingDelta("tls_handshake_ms", base.TLSHandshakeMs, target.TLSHandshakeMs),
+			timingDelta("time_to_first_byte_ms", base.TimeToFirstByteMs, target.TimeToFirstByteMs),
+			timingDelta("content_transfer_ms", base.ContentTransferMs, target.ContentTransferMs),
+		},
+	}
+	diff.Identical = !diff.Status.Changed && len(diff.Headers) == 0 && len(diff.Body.Changes) == 0
+	return diff
+}
+
+func timingDelta(name string, base, target float64) TimingDelta {
+	return TimingDelta{Name: name, Base: base, Target: target, DeltaMs: target - base}
+}
+
+func diffHeaders(baseJSON, targetJSON string, ignore []string) []HeaderChange {
+	base := parseHeaderJSON(baseJSON)
+	target := parseHeaderJSON(targetJSON)
+
+	ignored := map[string]bool{}
+	for _, name := range append(defaultIgnoredHeaders, ignore...) {
+		ignored[http.CanonicalHeaderKey(name)] = true
+	}
+
+	names := map[string]bool{}
+	for name := range base {
+		names[name] = true
+	}
+	for name := range target {
+		names[name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		if !ignored[name] {
+			sorted = append(sorted, name)
+		}
+	}
+	sort.Strings(sorted)
+
+	changes := []HeaderChange{}
+	for _, name := range sorted {
+		baseValue, inBase := base[name]
+		targetValue, inTarget := target[name]
+		switch {
+		case !inBase:
+			changes = append(changes, HeaderChange{Name: name, Type: ChangeAdded, Target: targetValue})
+		case !inTarget:
+			changes = append(changes, HeaderChange{Name: name, Type: ChangeRemoved, Base: baseValue})
+		case baseValue != targetValue:
+			changes = append(changes, HeaderChange{Name: name, Type: ChangeChanged, Base: baseValue, Target: targetValue})
+		}
+	}
+	return changes
+}
+
+// parseHeaderJSON reads stored response headers, keyed by canonical name with
+// multiple values joined.
+func parseHeaderJSON(raw string) map[string]string {
+	var header map[string][]string
+	json.Unmarshal([]byte(raw), &header)
+	result := make(map[string]string, len(header))
+	for name, values := range header {
+		result[http.CanonicalHeaderKey(name)] = strings.Join(values, ", ")
+	}
+	return result
+}
+
+func diffBodies(base, target string, ignore []string) BodyDiff {
+	var baseDoc, targetDoc interface{}
+	baseErr := json.Unmarshal([]byte(base), &baseDoc)
+	targetErr := json.Unmarshal([]byte(target), &targetDoc)
+	if baseErr != nil || targetErr != nil {
+		diff := BodyDiff{Format: "text", Changes: []BodyChange{}}
+		if base != target {
+			diff.Changes = append(diff.Changes, BodyChange{Path: "$", Type: ChangeChanged, Base: base, Target: target})
+		}
+		return diff
+	}
+
+	patterns := make([][]string, 0, len(ignore))
+	for _, path := range ignore {
+		path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+		if path != "" {
+			patterns = append(patterns, strings.Split(path, "."))
+		}
+	}
+
+	diff := BodyDiff{Format: "json", Changes: []BodyChange{}}
+	diffJSONValues(nil, baseDoc, targetDoc, patterns, &diff.Changes)
+	return diff
+}
+
+// diffJSONValues appends the changes between base and target under path.
+// Arrays are compared by index.
+func diffJSONValues(path []string, base, target interface{}, ignore [][]string, changes *[]BodyChange) {
+	if ignoredPath(path, ignore) {
+		return
+	}
+
+	switch baseNode := base.(type) {
+	case map[string]interface{}:
+		if targetNode, ok := target.(map[string]interface{}); ok {
+			keys := make([]string, 0, len(baseNode)+len(targetNode))
+			for key := range baseNode {
+				keys = append(keys, key)
+			}
+			for key := range targetNode {
+				if _, ok := baseNode[key]; !ok {
+					keys = append(keys, key)
+				}
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				childPath := append(append([]string{}, path...), key)
+				baseValue, inBase := baseNode[key]
+				targetValue, inTarget := targetNode[key]
+				switch {
+				case !inBase:
+					addBodyChange(childPath, ChangeAdded, nil, targetValue, ignore, changes)
+				case !inTarget:
+					addBodyChange(childPath, ChangeRemoved, baseValue, nil, ignore, changes)
+				default:
+					diffJSONValues(childPath, baseValue, targetValue, ignore, changes)
+				}
+			}
+			return
+		}
+	case []interface{}:
+		if targetNode, ok := target.([]interface{}); ok {
+			for i := 0; i < len(baseNode) || i < len(targetNode); i++ {
+				childPath := append(append([]string{}, path...), strconv.Itoa(i))
+				switch {
+				case i >= len(baseNode):
+					addBodyChange(childPath, ChangeAdded, nil, targetNode[i], ignore, changes)
+				case i >= len(targetNode):
+					addBodyChange(childPath, ChangeRemoved, baseNode[i], nil, ignore, changes)
+				default:
+					diffJSONValues(childPath, baseNode[i], targetNode[i], ignore, changes)
+				}
+			}
+			return
+		}
+	}
+
+	if !reflect.DeepEqual(base, target) {
+		addBodyChange(path, ChangeChanged, base, target, ignore, changes)
+	}
+}
+
+func addBodyChange(path []string, changeType string, base, target interface{}, ignore [][]string, changes *[]BodyChange) {
+	if ignoredPath(path, ignore) {
+		return
+	}
+	display := "$"
+	if len(path) > 0 {
+		display = strings.Join(path, ".")
+	}
+	*changes = append(*changes, BodyChange{Path: display, Type: changeType, Base: base, Target: target})
+}
+
+// ignoredPath reports whether a pattern matches path or one of its parents.
+func ignoredPath(path []string, patterns [][]string) bool {
+	for _, pattern := range patterns {
+		if len(pattern) > len(path) {
+			continue
+		}
+		matched := true
+		for i, segment := range pattern {
+			if segment != "*" && segment != path[i] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
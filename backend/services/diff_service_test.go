@@ -0,0 +1,134 @@
+package services
+
+import (
+	"backend/models"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffExecutions_JSONBody(t *testing.T) {
+	base := &models.Execution{
+		ID:              uuid.New(),
+		StatusCode:      200,
+		ResponseTimeMs:  120,
+		ResponseHeaders: `{"Content-Type":["application/json"],"Date":["Mon, 01 Jan 2024 00:00:00 GMT"],"X-Cache":["HIT"]}`,
+		ResponseBody:    `{"id":"a1","updated_at":"2024-01-01","user":{"name":"Ann","role":"admin"},"items":[{"id":1,"ts":5},{"id":2,"ts":6}]}`,
+	}
+	target := &models.Execution{
+		ID:              uuid.New(),
+		StatusCode:      200,
+		ResponseTimeMs:  150,
+		ResponseHeaders: `{"content-type":["application/json"],"Date":["Tue, 02 Jan 2024 00:00:00 GMT"],"X-Request-Id":["r-9"]}`,
+		ResponseBody:    `{"id":"b2","updated_at":"2024-01-02","user":{"name":"Ann","email":"ann@example.com"},"items":[{"id":1,"ts":7}]}`,
+	}
+
+	diff := DiffExecutions(base, target, DiffOptions{
+		IgnorePaths:   []string{"$.id", "updated_at", "items.*.ts"},
+		IgnoreHeaders: []string{"x-request-id"},
+	})
+
+	assert.False(t, diff.Identical)
+	assert.False(t, diff.Status.Changed)
+	assert.Equal(t, []HeaderChange{{Name: "X-Cache", Type: ChangeRemoved, Base: "HIT"}}, diff.Headers)
+
+	assert.Equal(t, "json", diff.Body.Format)
+	assert.Equal(t, []BodyChange{
+		{Path: "items.1", Type: ChangeRemoved, Base: map[string]interface{}{"id": float64(2), "ts": float64(6)}},
+		{Path: "user.email", Type: ChangeAdded, Target: "ann@example.com"},
+		{Path: "user.role", Type: ChangeRemoved, Base: "admin"},
+	}, diff.Body.Changes)
+
+	require.NotEmpty(t, diff.Timings)
+	assert.Equal(t, TimingDelta{Name: "response_time_ms", Base: 120, Target: 150, DeltaMs: 30}, diff.Timings[0])
+}
+
+func TestDiffExecutions_TextAndIdentical(t *testing.T) {
+	base := &models.Execution{StatusCode: 200, ResponseBody: "ok"}
+	target := &models.Execution{StatusCode: 503, ResponseBody: "unavailable"}
+
+	diff := DiffExecutions(base, target, DiffOptions{})
+	assert.True(t, diff.Status.Changed)
+	assert.Equal(t, "text", diff.Body.Format)
+	assert.Equal(t, []BodyChange{{Path: "$", Type: ChangeChanged, Base: "ok", Target: "unavailable"}}, diff.Body.Changes)
+
+	same := DiffExecutions(base, &models.Execution{StatusCode: 200, ResponseBody: "ok"}, DiffOptions{})
+	assert.True(t, same.Identical)
+	assert.Empty(t, same.Headers)
+}
+
+func TestIgnoredPath(t *testing.T) {
+	patterns := [][]string{{"data", "*", "id"}, {"meta"}}
+	assert.True(t, ignoredPath([]string{"data", "3", "id"}, patterns))
+	assert.True(t, ignoredPath([]string{"meta", "page"}, patterns), "parents cover their subtree")
+	assert.False(t, ignoredPath([]string{"data", "3", "name"}, patterns))
+	assert.False(t, ignoredPath([]string{"data"}, patterns))
+}
+
+func TestDiffService_Compare(t *testing.T) {
+	db, mock := setupTestDBRequest(t)
+	service := NewDiffService(db)
+
+	workspaceID := uuid.New()
+	userID := uuid.New()
+	baseID := uuid.New()
+	targetID := uuid.New()
+	requestID := uuid.New()
+
+	mock.ExpectQuery(`(?i)SELECT count\(\*\) FROM "workspace_members"`).
+		WithArgs(workspaceID, userID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(`(?i)SELECT .* FROM "executions" JOIN requests .* JOIN collections .* WHERE \(executions.id = \$1 AND collections.workspace_id = \$2\)`).
+		WithArgs(baseID, workspaceID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "request_id", "status_code", "response_body"}).
+			AddRow(baseID, requestID, 200, `{"count":1}`))
+	mock.ExpectQuery(`(?i)SELECT .* FROM "executions" JOIN requests`).
+		WithArgs(targetID, workspaceID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "request_id", "status_code", "response_body"}).
+			AddRow(targetID, requestID, 200, `{"count":2}`))
+
+	diff, err := service.Compare(workspaceID, userID, baseID, targetID, DiffOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, baseID, diff.BaseExecutionID)
+	assert.Equal(t, []BodyChange{{Path: "count", Type: ChangeChanged, Base: float64(1), Target: float64(2)}}, diff.Body.Changes)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDiffService_CompareToBaseline_Latest(t *testing.T) {
+	db, mock := setupTestDBRequest(t)
+	service := NewDiffService(db)
+
+	workspaceID := uuid.New()
+	userID := uuid.New()
+	requestID := uuid.New()
+	baselineExecutionID := uuid.New()
+	latestID := uuid.New()
+
+	mock.ExpectQuery(`(?i)SELECT count\(\*\) FROM "workspace_members"`).
+		WithArgs(workspaceID, userID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(`(?i)SELECT .* FROM "response_baselines" JOIN requests .* WHERE response_baselines.request_id = \$1 AND collections.workspace_id = \$2`).
+		WithArgs(requestID, workspaceID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "request_id", "execution_id", "ignore_paths", "ignore_headers"}).
+			AddRow(uuid.New(), requestID, baselineExecutionID, `["generated_at"]`, `[]`))
+	mock.ExpectQuery(`(?i)SELECT \* FROM "executions" WHERE "executions"."id" = \$1`).
+		WithArgs(baselineExecutionID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "request_id", "status_code", "response_body"}).
+			AddRow(baselineExecutionID, requestID, 200, `{"total":3,"generated_at":"t1"}`))
+	mock.ExpectQuery(`(?i)SELECT \* FROM "executions" WHERE \(request_id = \$1 AND parent_execution_id IS NULL\) .* ORDER BY timestamp DESC`).
+		WithArgs(requestID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "request_id", "status_code", "response_body"}).
+			AddRow(latestID, requestID, 200, `{"total":3,"generated_at":"t2"}`))
+
+	diff, err := service.CompareToBaseline(workspaceID, userID, requestID, uuid.Nil, DiffOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, baselineExecutionID, diff.BaseExecutionID)
+	assert.Equal(t, latestID, diff.TargetExecutionID)
+	assert.True(t, diff.Identical, "saved ignore paths apply")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
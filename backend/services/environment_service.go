@@ -179,6 +179,43 @@ func (s *EnvironmentService) AddVariable(workspaceID, environmentID uuid.UUID, k
 	return variable, nil
 }
 
+// AddSecret adds a secret to an environment. The value is stored as given,
+// so callers encrypt it first.
+func (s *EnvironmentService) AddSecret(workspaceID, environmentID uuid.UUID, key, value, description string, userID uuid.UUID) (*models.EnvironmentSecret, error) {
+	// Check if user has access to workspace
+	var workspaceMember models.WorkspaceMember
+	if err := s.db.Where("workspace_id = ? AND user_id = ?", workspaceID, userID).First(&workspaceMember).Error; err != nil {
+		return nil, err
+	}
+
+	// Check if environment exists
+	var environment models.Environment
+	if err := s.db.Where("id = ? AND workspace_id = ?", environmentID, workspaceID).First(&environment).Error; err != nil {
+		return nil, err
+	}
+
+	// Check if secret with same key exists
+	var existingSecret models.EnvironmentSecret
+	if err := s.db.Where("environment_id = ? AND key = ?", environmentID, key).First(&existingSecret).Error; err == nil {
+		return nil, gorm.ErrDuplicatedKey
+	}
+
+	secret := &models.EnvironmentSecret{
+		EnvironmentID: environmentID,
+		Key:           key,
+		Value:         value,
+		Description:   description,
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+	}
+
+	if err := s.db.Create(secret).Error; err != nil {
+		return nil, err
+	}
+
+	return secret, nil
+}
+
 // UpdateVariable updates a variable
 func (s *EnvironmentService) UpdateVariable(workspaceID, environmentID, variableID uuid.UUID, userID uuid.UUID, updates map[string]interface{}) (*models.EnvironmentVariable, error) {
 	// Check if user has access to workspace
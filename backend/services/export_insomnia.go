@@ -0,0 +1,259 @@
+package services
+
+import (
+	"backend/models"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// insomniaExport renders a collection as an Insomnia v4 export: a workspace
+// with a base environment, one sub-environment per exported environment,
+// request groups for folders and the requests. Insomnia's auth inheritance
+// differs between versions, so every request carries its resolved auth.
+func insomniaExport(data *exportData, warn func(item, message string)) map[string]interface{} {
+	workspaceID := insomniaID("wrk", data.collection.ID)
+	baseEnvironmentID := insomniaID("env", data.collection.ID)
+	resources := []interface{}{
+		map[string]interface{}{
+			"_id":         workspaceID,
+			"_type":       "workspace",
+			"parentId":    nil,
+			"name":        data.collection.Name,
+			"description": data.collection.Description,
+			"scope":       "collection",
+		},
+		map[string]interface{}{
+			"_id":      baseEnvironmentID,
+			"_type":    "environment",
+			"parentId": workspaceID,
+			"name":     "Base Environment",
+			"data":     map[string]interface{}{},
+		},
+	}
+	for i, environment := range data.environments {
+		values := map[string]interface{}{}
+		for _, variable := range exportVariables(environment) {
+			if _, exists := values[variable.key]; !exists {
+				values[variable.key] = insomniaTemplate(variable.value)
+			}
+		}
+		resources = append(resources, map[string]interface{}{
+			"_id":         insomniaID("env", environment.ID),
+			"_type":       "environment",
+			"parentId":    baseEnvironmentID,
+			"name":        environment.Name,
+			"data":        values,
+			"metaSortKey": i,
+		})
+	}
+
+	var walk func(items []TreeItem, parentID, parentPath string, chain []models.Folder)
+	walk = func(items []TreeItem, parentID, parentPath string, chain []models.Folder) {
+		for _, item := range items {
+			if folder := item.Folder; folder != nil {
+				variables := map[string]string{}
+				if folder.Variables != "" {
+					json.Unmarshal([]byte(folder.Variables), &variables)
+				}
+				environment := map[string]interface{}{}
+				for key, value := range variables {
+					environment[key] = insomniaTemplate(value)
+				}
+				id := insomniaID("fld", folder.ID)
+				resources = append(resources, map[string]interface{}{
+					"_id":         id,
+					"_type":       "request_group",
+					"parentId":    parentID,
+					"name":        folder.Name,
+					"description": folder.Description,
+					"environment": environment,
+					"metaSortKey": folder.Position,
+				})
+				walk(item.Items, id, joinItemPath(parentPath, folder.Name), append(append([]models.Folder{}, chain...), *folder))
+				continue
+			}
+
+			request := item.Request
+			path := joinItemPath(parentPath, request.Name)
+			if !exportable(request) {
+				warn(path, fmt.Sprintf("%s requests cannot be exported to Insomnia and were skipped", request.Kind))
+				continue
+			}
+			resources = append(resources, insomniaRequest(request, parentID, path, chain, data.collection, warn))
+		}
+	}
+	walk(data.tree, workspaceID, "", nil)
+
+	return map[string]interface{}{
+		"_type":           "export",
+		"__export_format": 4,
+		"__export_date":   time.Now().UTC().Format(time.RFC3339),
+		"__export_source": "tracely",
+		"resources":       resources,
+	}
+}
+
+func insomniaRequest(request *models.Request, parentID, path string, chain []models.Folder, collection *models.Collection, warn func(item, message string)) map[string]interface{} {
+	headers := requestHeaders(request)
+	body, contentType := insomniaBody(request, path, warn)
+	if contentType != "" && headerValue(headers, "Content-Type") == "" {
+		headers["Content-Type"] = contentType
+	}
+	headerList := []interface{}{}
+	for _, key := range sortedStringKeys(headers) {
+		headerList = append(headerList, map[string]interface{}{"name": key, "value": insomniaTemplate(headers[key])})
+	}
+
+	parameters := []interface{}{}
+	params, err := ParseRequestParams(request.QueryParams)
+	if err != nil {
+		warn(path, "query params are invalid and were skipped")
+	}
+	for _, param := range params {
+		parameters = append(parameters, map[string]interface{}{"name": param.Key, "value": insomniaTemplate(param.Value), "disabled": !param.enabled()})
+	}
+
+	authentication := map[string]interface{}{}
+	if cfg, err := effectiveAuth(request, chain, collection); err != nil {
+		warn(path, "auth is invalid and was skipped")
+	} else if cfg != nil {
+		authentication = insomniaAuth(cfg, path, warn)
+	}
+
+	return map[string]interface{}{
+		"_id":            insomniaID("req", request.ID),
+		"_type":          "request",
+		"parentId":       parentID,
+		"name":           request.Name,
+		"description":    request.Description,
+		"method":         strings.ToUpper(request.Method),
+		"url":            insomniaTemplate(request.URL),
+		"headers":        headerList,
+		"parameters":     parameters,
+		"body":           body,
+		"authentication": authentication,
+		"metaSortKey":    request.Position,
+	}
+}
+
+// insomniaBody converts a request body and returns the content type it implies.
+func insomniaBody(request *models.Request, path string, warn func(item, message string)) (map[string]interface{}, string) {
+	text := func(mimeType, value string) (map[string]interface{}, string) {
+		return map[string]interface{}{"mimeType": mimeType, "text": insomniaTemplate(value)}, mimeType
+	}
+	params := func(mimeType string) (map[string]interface{}, string) {
+		fields, err := ParseRequestParams(request.Body)
+		if err != nil {
+			warn(path, "body params are invalid and were skipped")
+		}
+		list := make([]interface{}, 0, len(fields))
+		for _, field := range fields {
+			entry := map[string]interface{}{"name": field.Key, "value": insomniaTemplate(field.Value), "disabled": !field.enabled()}
+			if field.Type == "file" {
+				entry["type"], entry["fileName"] = "file", ""
+				delete(entry, "value")
+				warn(path, fmt.Sprintf("file field %q needs the file attached in Insomnia", field.Key))
+			}
+			list = append(list, entry)
+		}
+		return map[string]interface{}{"mimeType": mimeType, "params": list}, mimeType
+	}
+
+	if request.Kind == RequestKindGraphQL {
+		gql, err := ParseGraphQLRequest(request.GraphQL)
+		if err != nil {
+			warn(path, "GraphQL query is invalid and was skipped")
+			return map[string]interface{}{}, ""
+		}
+		payload := map[string]interface{}{"query": gql.Query}
+		if len(gql.Variables) > 0 {
+			payload["variables"] = gql.Variables
+		}
+		encoded, _ := json.Marshal(payload)
+		return map[string]interface{}{"mimeType": "application/graphql", "text": insomniaTemplate(string(encoded))}, "application/json"
+	}
+
+	switch request.BodyMode {
+	case "", BodyModeRaw, BodyModeJSON:
+		if request.Body == "" || request.Body == "null" {
+			break
+		}
+		return text("application/json", request.Body)
+	case BodyModeXML:
+		return text("application/xml", unquoteBody(request.Body))
+	case BodyModeText:
+		return text("text/plain", unquoteBody(request.Body))
+	case BodyModeURLEncoded:
+		return params("application/x-www-form-urlencoded")
+	case BodyModeFormData:
+		return params("multipart/form-data")
+	case BodyModeBinary:
+		var binary BinaryBody
+		json.Unmarshal([]byte(request.Body), &binary)
+		if binary.ContentType == "" {
+			binary.ContentType = "application/octet-stream"
+		}
+		warn(path, "binary body needs the file attached in Insomnia")
+		return map[string]interface{}{"mimeType": binary.ContentType, "fileName": ""}, binary.ContentType
+	}
+	return map[string]interface{}{}, ""
+}
+
+func insomniaAuth(cfg *AuthConfig, path string, warn func(item, message string)) map[string]interface{} {
+	switch {
+	case cfg.Type == AuthTypeBasic && cfg.Basic != nil:
+		return map[string]interface{}{"type": "basic", "username": insomniaTemplate(cfg.Basic.Username), "password": insomniaTemplate(cfg.Basic.Password)}
+	case cfg.Type == AuthTypeDigest && cfg.Digest != nil:
+		return map[string]interface{}{"type": "digest", "username": insomniaTemplate(cfg.Digest.Username), "password": insomniaTemplate(cfg.Digest.Password)}
+	case cfg.Type == AuthTypeBearer && cfg.Bearer != nil:
+		return map[string]interface{}{"type": "bearer", "token": insomniaTemplate(cfg.Bearer.Token), "prefix": ""}
+	case cfg.Type == AuthTypeAPIKey && cfg.APIKey != nil:
+		addTo := "header"
+		if cfg.APIKey.In == "query" {
+			addTo = "queryParams"
+		}
+		return map[string]interface{}{"type": "apikey", "key": cfg.APIKey.Key, "value": insomniaTemplate(cfg.APIKey.Value), "addTo": addTo}
+	case cfg.Type == AuthTypeAWSV4 && cfg.AWSV4 != nil:
+		return map[string]interface{}{
+			"type":            "iam",
+			"accessKeyId":     insomniaTemplate(cfg.AWSV4.AccessKeyID),
+			"secretAccessKey": insomniaTemplate(cfg.AWSV4.SecretAccessKey),
+			"sessionToken":    insomniaTemplate(cfg.AWSV4.SessionToken),
+			"region":          cfg.AWSV4.Region,
+			"service":         cfg.AWSV4.Service,
+		}
+	case cfg.Type == AuthTypeOAuth2 && cfg.OAuth2 != nil:
+		grantType := "client_credentials"
+		if cfg.OAuth2.GrantType == "password" {
+			grantType = "password"
+		}
+		return map[string]interface{}{
+			"type":              "oauth2",
+			"grantType":         grantType,
+			"accessTokenUrl":    insomniaTemplate(cfg.OAuth2.TokenURL),
+			"clientId":          insomniaTemplate(cfg.OAuth2.ClientID),
+			"clientSecret":      insomniaTemplate(cfg.OAuth2.ClientSecret),
+			"username":          insomniaTemplate(cfg.OAuth2.Username),
+			"password":          insomniaTemplate(cfg.OAuth2.Password),
+			"scope":             cfg.OAuth2.Scope,
+			"audience":          cfg.OAuth2.Audience,
+			"credentialsInBody": cfg.OAuth2.ClientAuth == "body",
+		}
+	}
+	warn(path, fmt.Sprintf("auth type %q could not be exported", cfg.Type))
+	return map[string]interface{}{}
+}
+
+// insomniaTemplate rewrites {{name}} references to Insomnia's {{ _.name }}.
+func insomniaTemplate(text string) string {
+	return variablePattern.ReplaceAllString(text, "{{ _.$1 }}")
+}
+
+// insomniaID derives a stable resource ID, e.g. req_0f8c..., from a UUID.
+func insomniaID(prefix string, id uuid.UUID) string {
+	return prefix + "_" + strings.ReplaceAll(id.String(), "-", "")
+}
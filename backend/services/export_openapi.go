@@ -0,0 +1,365 @@
+package services
+
+import (
+	"backend/models"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// openAPIServerPrefix splits a request URL into its server and path: either a
+// leading {{variable}} or a scheme and host.
+var openAPIServerPrefix = regexp.MustCompile(`^(\{\{\s*[\w.-]+\s*\}\}|[a-zA-Z][a-zA-Z0-9+.-]*://[^/?#]*)`)
+
+// openAPIIDSegment matches literal path segments that are most likely IDs.
+var openAPIIDSegment = regexp.MustCompile(`^(\d+|[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12})$`)
+
+// openAPIExportedHeaders are set by clients or auth and are not parameters.
+var openAPIExportedHeaders = map[string]bool{"accept": true, "authorization": true, "content-type": true, "cookie": true}
+
+// openAPIExport infers an OpenAPI 3.1 document from a collection. Paths come
+// from the request URLs, with {{variables}} and ID-like segments as path
+// parameters; request schemas come from the request bodies and response
+// schemas from the requests' recent executions. Top-level folders become tags.
+func openAPIExport(data *exportData, warn func(item, message string)) map[string]interface{} {
+	paths := map[string]interface{}{}
+	servers := []interface{}{}
+	seenServers := map[string]bool{}
+	schemes := map[string]interface{}{}
+	tags := []interface{}{}
+
+	for _, item := range data.tree {
+		if item.Folder != nil {
+			tags = append(tags, map[string]interface{}{"name": item.Folder.Name, "description": item.Folder.Description})
+		}
+	}
+
+	walkExportTree(data.tree, nil, func(request *models.Request, chain []models.Folder) {
+		path := request.Name
+		for i := len(chain) - 1; i >= 0; i-- {
+			path = chain[i].Name + "/" + path
+		}
+		if !exportable(request) {
+			warn(path, fmt.Sprintf("%s requests cannot be described in OpenAPI and were skipped", request.Kind))
+			return
+		}
+
+		server, template, pathParams := openAPIExportPath(request.URL)
+		if server != "" && !seenServers[server] {
+			seenServers[server] = true
+			servers = append(servers, openAPIExportServer(server, data.environments))
+		}
+		pathItem, _ := paths[template].(map[string]interface{})
+		if pathItem == nil {
+			pathItem = map[string]interface{}{}
+			paths[template] = pathItem
+		}
+		method := strings.ToLower(request.Method)
+		if _, exists := pathItem[method]; exists {
+			warn(path, fmt.Sprintf("%s %s is already described by another request and was skipped", request.Method, template))
+			return
+		}
+
+		operation := map[string]interface{}{
+			"summary":    request.Name,
+			"parameters": openAPIExportParameters(request, pathParams, path, warn),
+			"responses":  openAPIExportResponses(data.executions[request.ID]),
+		}
+		if request.Description != "" {
+			operation["description"] = request.Description
+		}
+		if len(chain) > 0 {
+			operation["tags"] = []interface{}{chain[0].Name}
+		}
+		if body := openAPIExportRequestBody(request); body != nil {
+			operation["requestBody"] = body
+		}
+		if cfg, err := effectiveAuth(request, chain, data.collection); err != nil {
+			warn(path, "auth is invalid and was not described")
+		} else if cfg == nil {
+			operation["security"] = []interface{}{}
+		} else if name, scheme := openAPISecurityScheme(cfg); scheme != nil {
+			schemes[name] = scheme
+			operation["security"] = []interface{}{map[string]interface{}{name: []interface{}{}}}
+		} else {
+			warn(path, fmt.Sprintf("auth type %q cannot be described in OpenAPI", cfg.Type))
+		}
+		pathItem[method] = operation
+	})
+
+	document := map[string]interface{}{
+		"openapi": "3.1.0",
+		"info": map[string]interface{}{
+			"title":       data.collection.Name,
+			"description": data.collection.Description,
+			"version":     "1.0.0",
+		},
+		"paths": paths,
+	}
+	if len(servers) > 0 {
+		document["servers"] = servers
+	}
+	if len(tags) > 0 {
+		document["tags"] = tags
+	}
+	if len(schemes) > 0 {
+		document["components"] = map[string]interface{}{"securitySchemes": schemes}
+	}
+	return document
+}
+
+// openAPIExportPath splits a request URL into its server and a path template,
+// returning the path parameters in order.
+func openAPIExportPath(rawURL string) (server, template string, params []string) {
+	rawURL, _, _ = strings.Cut(rawURL, "#")
+	rawURL, _, _ = strings.Cut(rawURL, "?")
+	if prefix := openAPIServerPrefix.FindString(rawURL); prefix != "" {
+		server = prefix
+		rawURL = strings.TrimPrefix(rawURL, prefix)
+	}
+
+	seen := map[string]bool{}
+	addParam := func(name string) string {
+		for candidate, i := name, 2; ; i++ {
+			if !seen[candidate] {
+				seen[candidate] = true
+				params = append(params, candidate)
+				return candidate
+			}
+			candidate = name + strconv.Itoa(i)
+		}
+	}
+
+	segments := strings.Split(strings.Trim(rawURL, "/"), "/")
+	for i, segment := range segments {
+		switch {
+		case variablePattern.MatchString(segment):
+			segments[i] = variablePattern.ReplaceAllStringFunc(segment, func(match string) string {
+				return "{" + addParam(variablePattern.FindStringSubmatch(match)[1]) + "}"
+			})
+		case openAPIIDSegment.MatchString(segment):
+			name := "id"
+			if i > 0 && !strings.Contains(segments[i-1], "{") {
+				name = strings.TrimSuffix(segments[i-1], "s") + "Id"
+			}
+			segments[i] = "{" + addParam(name) + "}"
+		}
+	}
+	return server, "/" + strings.Join(segments, "/"), params
+}
+
+// openAPIExportServer describes a server. A {{variable}} server becomes a
+// server variable, defaulting to its value in the first exported
+// environment that has it.
+func openAPIExportServer(server string, environments []models.Environment) map[string]interface{} {
+	match := variablePattern.FindStringSubmatch(server)
+	if match == nil {
+		return map[string]interface{}{"url": server}
+	}
+	name := match[1]
+	variable := map[string]interface{}{"default": ""}
+	for _, environment := range environments {
+		for _, entry := range environment.Variables {
+			if entry.Key == name {
+				variable["default"] = entry.Value
+				break
+			}
+		}
+		if variable["default"] != "" {
+			break
+		}
+	}
+	return map[string]interface{}{"url": "{" + name + "}", "variables": map[string]interface{}{name: variable}}
+}
+
+func openAPIExportParameters(request *models.Request, pathParams []string, path string, warn func(item, message string)) []interface{} {
+	parameters := []interface{}{}
+	for _, name := range pathParams {
+		parameters = append(parameters, map[string]interface{}{"name": name, "in": "path", "required": true, "schema": map[string]interface{}{"type": "string"}})
+	}
+
+	params, err := ParseRequestParams(request.QueryParams)
+	if err != nil {
+		warn(path, "query params are invalid and were skipped")
+	}
+	for _, param := range params {
+		parameter := map[string]interface{}{"name": param.Key, "in": "query", "required": param.enabled(), "schema": map[string]interface{}{"type": "string"}}
+		if param.Value != "" && !variablePattern.MatchString(param.Value) {
+			parameter["example"] = param.Value
+		}
+		parameters = append(parameters, parameter)
+	}
+
+	headers := requestHeaders(request)
+	for _, key := range sortedStringKeys(headers) {
+		if openAPIExportedHeaders[strings.ToLower(key)] {
+			continue
+		}
+		parameter := map[string]interface{}{"name": key, "in": "header", "required": true, "schema": map[string]interface{}{"type": "string"}}
+		if value := headers[key]; value != "" && !variablePattern.MatchString(value) {
+			parameter["example"] = value
+		}
+		parameters = append(parameters, parameter)
+	}
+	return parameters
+}
+
+// openAPIExportRequestBody describes the request body, inferring a schema
+// from JSON bodies. Bodies with unresolved {{variables}} outside strings are
+// not valid JSON and are described without a schema.
+func openAPIExportRequestBody(request *models.Request) map[string]interface{} {
+	media := func(mediaType string, schema map[string]interface{}, example interface{}) map[string]interface{} {
+		content := map[string]interface{}{"schema": schema}
+		if example != nil {
+			content["example"] = example
+		}
+		return map[string]interface{}{"content": map[string]interface{}{mediaType: content}}
+	}
+	formSchema := func(files bool) map[string]interface{} {
+		fields, _ := ParseRequestParams(request.Body)
+		properties := map[string]interface{}{}
+		for _, field := range fields {
+			property := map[string]interface{}{"type": "string"}
+			if files && field.Type == "file" {
+				property["format"] = "binary"
+			}
+			properties[field.Key] = property
+		}
+		return map[string]interface{}{"type": "object", "properties": properties}
+	}
+
+	if request.Kind == RequestKindGraphQL {
+		return media("application/json", map[string]interface{}{
+			"type":     "object",
+			"required": []interface{}{"query"},
+			"properties": map[string]interface{}{
+				"query":         map[string]interface{}{"type": "string"},
+				"variables":     map[string]interface{}{"type": "object"},
+				"operationName": map[string]interface{}{"type": "string"},
+			},
+		}, nil)
+	}
+
+	switch request.BodyMode {
+	case "", BodyModeRaw, BodyModeJSON:
+		if request.Body == "" || request.Body == "null" {
+			return nil
+		}
+		var example interface{}
+		if err := json.Unmarshal([]byte(request.Body), &example); err != nil {
+			return media("application/json", map[string]interface{}{}, nil)
+		}
+		return media("application/json", inferJSONSchema(example), example)
+	case BodyModeXML:
+		return media("application/xml", map[string]interface{}{"type": "string"}, nil)
+	case BodyModeText:
+		return media("text/plain", map[string]interface{}{"type": "string"}, unquoteBody(request.Body))
+	case BodyModeURLEncoded:
+		return media("application/x-www-form-urlencoded", formSchema(false), nil)
+	case BodyModeFormData:
+		return media("multipart/form-data", formSchema(true), nil)
+	case BodyModeBinary:
+		var binary BinaryBody
+		json.Unmarshal([]byte(request.Body), &binary)
+		if binary.ContentType == "" {
+			binary.ContentType = "application/octet-stream"
+		}
+		return media(binary.ContentType, map[string]interface{}{"type": "string", "format": "binary"}, nil)
+	}
+	return nil
+}
+
+// openAPIExportResponses describes the responses seen in executions, merging
+// the schemas of every JSON body returned with the same status code.
+func openAPIExportResponses(executions []models.Execution) map[string]interface{} {
+	responses := map[string]interface{}{}
+	schemas := map[string]map[string]interface{}{}
+	for _, execution := range executions {
+		status := strconv.Itoa(execution.StatusCode)
+		response, _ := responses[status].(map[string]interface{})
+		if response == nil {
+			description := http.StatusText(execution.StatusCode)
+			if description == "" {
+				description = "Response"
+			}
+			response = map[string]interface{}{"description": description}
+			responses[status] = response
+		}
+
+		headers := map[string][]string{}
+		json.Unmarshal([]byte(execution.ResponseHeaders), &headers)
+		contentType := ""
+		for key, values := range headers {
+			if strings.EqualFold(key, "Content-Type") && len(values) > 0 {
+				contentType, _, _ = mime.ParseMediaType(values[0])
+			}
+		}
+		if contentType == "" || execution.ResponseBody == "" {
+			continue
+		}
+
+		content, _ := response["content"].(map[string]interface{})
+		if content == nil {
+			content = map[string]interface{}{}
+			response["content"] = content
+		}
+		var body interface{}
+		if (contentType == "application/json" || strings.HasSuffix(contentType, "+json")) && json.Unmarshal([]byte(execution.ResponseBody), &body) == nil {
+			key := status + " " + contentType
+			schemas[key] = mergeJSONSchemas(schemas[key], inferJSONSchema(body))
+			media, _ := content[contentType].(map[string]interface{})
+			if media == nil {
+				// Executions are newest first, so the example is the latest body
+				media = map[string]interface{}{"example": body}
+				content[contentType] = media
+			}
+			media["schema"] = schemas[key]
+		} else if _, exists := content[contentType]; !exists {
+			content[contentType] = map[string]interface{}{"schema": map[string]interface{}{"type": "string"}}
+		}
+	}
+
+	if len(responses) == 0 {
+		responses["default"] = map[string]interface{}{"description": "Response"}
+	}
+	return responses
+}
+
+// openAPISecurityScheme describes resolved auth as a security scheme and
+// returns the name to register it under, or a nil scheme when OpenAPI cannot
+// describe it.
+func openAPISecurityScheme(cfg *AuthConfig) (string, map[string]interface{}) {
+	switch {
+	case cfg.Type == AuthTypeBasic:
+		return "basicAuth", map[string]interface{}{"type": "http", "scheme": "basic"}
+	case cfg.Type == AuthTypeDigest:
+		return "digestAuth", map[string]interface{}{"type": "http", "scheme": "digest"}
+	case cfg.Type == AuthTypeBearer:
+		return "bearerAuth", map[string]interface{}{"type": "http", "scheme": "bearer"}
+	case cfg.Type == AuthTypeAPIKey && cfg.APIKey != nil:
+		in := cfg.APIKey.In
+		if in == "" {
+			in = "header"
+		}
+		return "apiKey_" + cfg.APIKey.Key, map[string]interface{}{"type": "apiKey", "in": in, "name": cfg.APIKey.Key}
+	case cfg.Type == AuthTypeOAuth2 && cfg.OAuth2 != nil:
+		flow := map[string]interface{}{"tokenUrl": cfg.OAuth2.TokenURL, "scopes": map[string]interface{}{}}
+		if cfg.OAuth2.Scope != "" {
+			scopes := map[string]interface{}{}
+			for _, scope := range strings.Fields(cfg.OAuth2.Scope) {
+				scopes[scope] = ""
+			}
+			flow["scopes"] = scopes
+		}
+		flowName := "clientCredentials"
+		if cfg.OAuth2.GrantType == "password" {
+			flowName = "password"
+		}
+		return "oauth2", map[string]interface{}{"type": "oauth2", "flows": map[string]interface{}{flowName: flow}}
+	}
+	return "", nil
+}
@@ -0,0 +1,293 @@
+package services
+
+import (
+	"backend/models"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const postmanSchemaURL = "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"
+
+// postmanExport renders a collection as a Postman v2.1 collection. Auth is
+// exported where it is set, so inheritance works the same way in Postman.
+func postmanExport(data *exportData, warn func(item, message string)) map[string]interface{} {
+	collection := map[string]interface{}{
+		"info": map[string]interface{}{
+			"_postman_id": data.collection.ID.String(),
+			"name":        data.collection.Name,
+			"description": data.collection.Description,
+			"schema":      postmanSchemaURL,
+		},
+		"item": postmanExportItems(data.tree, "", warn),
+	}
+	if auth := postmanExportAuth(data.collection.Auth, data.collection.Name, warn); auth != nil {
+		collection["auth"] = auth
+	}
+	return collection
+}
+
+func postmanExportItems(items []TreeItem, parentPath string, warn func(item, message string)) []interface{} {
+	exported := []interface{}{}
+	for _, item := range items {
+		if folder := item.Folder; folder != nil {
+			path := joinItemPath(parentPath, folder.Name)
+			entry := map[string]interface{}{
+				"name":        folder.Name,
+				"description": folder.Description,
+				"item":        postmanExportItems(item.Items, path, warn),
+			}
+			if auth := postmanExportAuth(folder.Auth, path, warn); auth != nil {
+				entry["auth"] = auth
+			}
+			variables := map[string]string{}
+			if folder.Variables != "" {
+				json.Unmarshal([]byte(folder.Variables), &variables)
+			}
+			if len(variables) > 0 {
+				list := make([]interface{}, 0, len(variables))
+				for _, key := range sortedStringKeys(variables) {
+					list = append(list, map[string]interface{}{"key": key, "value": variables[key]})
+				}
+				entry["variable"] = list
+			}
+			exported = append(exported, entry)
+			continue
+		}
+
+		request := item.Request
+		path := joinItemPath(parentPath, request.Name)
+		if !exportable(request) {
+			warn(path, fmt.Sprintf("%s requests cannot be exported to Postman and were skipped", request.Kind))
+			continue
+		}
+		exported = append(exported, map[string]interface{}{
+			"name":    request.Name,
+			"request": postmanExportRequest(request, path, warn),
+		})
+	}
+	return exported
+}
+
+func postmanExportRequest(request *models.Request, path string, warn func(item, message string)) map[string]interface{} {
+	headers := requestHeaders(request)
+	headerList := []interface{}{}
+	for _, key := range sortedStringKeys(headers) {
+		headerList = append(headerList, map[string]interface{}{"key": key, "value": headers[key]})
+	}
+
+	rawURL := request.URL
+	url := map[string]interface{}{}
+	params, err := ParseRequestParams(request.QueryParams)
+	if err != nil {
+		warn(path, "query params are invalid and were skipped")
+	}
+	if len(params) > 0 {
+		query := make([]interface{}, 0, len(params))
+		var enabled []string
+		for _, param := range params {
+			query = append(query, map[string]interface{}{"key": param.Key, "value": param.Value, "disabled": !param.enabled()})
+			if param.enabled() {
+				enabled = append(enabled, param.Key+"="+param.Value)
+			}
+		}
+		url["query"] = query
+		if len(enabled) > 0 {
+			separator := "?"
+			if strings.Contains(rawURL, "?") {
+				separator = "&"
+			}
+			rawURL += separator + strings.Join(enabled, "&")
+		}
+	}
+	url["raw"] = rawURL
+
+	exported := map[string]interface{}{
+		"method":      strings.ToUpper(request.Method),
+		"header":      headerList,
+		"url":         url,
+		"description": request.Description,
+	}
+	if body := postmanExportBody(request, path, warn); body != nil {
+		exported["body"] = body
+	}
+	if auth := postmanExportAuth(request.Auth, path, warn); auth != nil {
+		exported["auth"] = auth
+	}
+	return exported
+}
+
+// postmanExportBody converts a request body to one of Postman's body modes.
+func postmanExportBody(request *models.Request, path string, warn func(item, message string)) map[string]interface{} {
+	raw := func(text, language string) map[string]interface{} {
+		return map[string]interface{}{
+			"mode":    "raw",
+			"raw":     text,
+			"options": map[string]interface{}{"raw": map[string]interface{}{"language": language}},
+		}
+	}
+	params := func() []interface{} {
+		fields, err := ParseRequestParams(request.Body)
+		if err != nil {
+			warn(path, "body params are invalid and were skipped")
+		}
+		list := make([]interface{}, 0, len(fields))
+		for _, field := range fields {
+			entry := map[string]interface{}{"key": field.Key, "value": field.Value, "disabled": !field.enabled(), "type": "text"}
+			if field.ContentType != "" {
+				entry["contentType"] = field.ContentType
+			}
+			if field.Type == "file" {
+				entry["type"], entry["src"] = "file", ""
+				delete(entry, "value")
+				warn(path, fmt.Sprintf("file field %q needs the file attached in Postman", field.Key))
+			}
+			list = append(list, entry)
+		}
+		return list
+	}
+
+	if request.Kind == RequestKindGraphQL {
+		gql, err := ParseGraphQLRequest(request.GraphQL)
+		if err != nil {
+			warn(path, "GraphQL query is invalid and was skipped")
+			return nil
+		}
+		variables := ""
+		if len(gql.Variables) > 0 {
+			encoded, _ := json.MarshalIndent(gql.Variables, "", "  ")
+			variables = string(encoded)
+		}
+		return map[string]interface{}{
+			"mode":    "graphql",
+			"graphql": map[string]interface{}{"query": gql.Query, "variables": variables},
+		}
+	}
+
+	switch request.BodyMode {
+	case BodyModeNone:
+		return nil
+	case "", BodyModeRaw, BodyModeJSON:
+		if request.Body == "" || request.Body == "null" {
+			return nil
+		}
+		return raw(request.Body, "json")
+	case BodyModeXML:
+		return raw(unquoteBody(request.Body), "xml")
+	case BodyModeText:
+		return raw(unquoteBody(request.Body), "text")
+	case BodyModeURLEncoded:
+		return map[string]interface{}{"mode": "urlencoded", "urlencoded": params()}
+	case BodyModeFormData:
+		return map[string]interface{}{"mode": "formdata", "formdata": params()}
+	case BodyModeBinary:
+		warn(path, "binary body needs the file attached in Postman")
+		return map[string]interface{}{"mode": "file", "file": map[string]interface{}{"src": ""}}
+	}
+	return nil
+}
+
+// postmanExportAuth converts an auth JSON column to Postman auth. Empty auth
+// inherits and is left out.
+func postmanExportAuth(raw, path string, warn func(item, message string)) map[string]interface{} {
+	cfg, err := ParseAuthConfig(raw)
+	if err != nil {
+		warn(path, "auth is invalid and was skipped")
+		return nil
+	}
+	if cfg == nil || cfg.Type == "" || cfg.Type == AuthTypeInherit {
+		return nil
+	}
+
+	auth := func(authType string, pairs ...string) map[string]interface{} {
+		attributes := make([]interface{}, 0, len(pairs)/2)
+		for i := 0; i+1 < len(pairs); i += 2 {
+			if pairs[i+1] != "" {
+				attributes = append(attributes, map[string]interface{}{"key": pairs[i], "value": pairs[i+1], "type": "string"})
+			}
+		}
+		return map[string]interface{}{"type": authType, authType: attributes}
+	}
+
+	switch {
+	case cfg.Type == AuthTypeNone:
+		return map[string]interface{}{"type": "noauth"}
+	case cfg.Type == AuthTypeBasic && cfg.Basic != nil:
+		return auth("basic", "username", cfg.Basic.Username, "password", cfg.Basic.Password)
+	case cfg.Type == AuthTypeDigest && cfg.Digest != nil:
+		return auth("digest", "username", cfg.Digest.Username, "password", cfg.Digest.Password)
+	case cfg.Type == AuthTypeBearer && cfg.Bearer != nil:
+		return auth("bearer", "token", cfg.Bearer.Token)
+	case cfg.Type == AuthTypeAPIKey && cfg.APIKey != nil:
+		in := cfg.APIKey.In
+		if in == "" {
+			in = "header"
+		}
+		return auth("apikey", "key", cfg.APIKey.Key, "value", cfg.APIKey.Value, "in", in)
+	case cfg.Type == AuthTypeAWSV4 && cfg.AWSV4 != nil:
+		return auth("awsv4",
+			"accessKey", cfg.AWSV4.AccessKeyID,
+			"secretKey", cfg.AWSV4.SecretAccessKey,
+			"sessionToken", cfg.AWSV4.SessionToken,
+			"region", cfg.AWSV4.Region,
+			"service", cfg.AWSV4.Service)
+	case cfg.Type == AuthTypeOAuth2 && cfg.OAuth2 != nil:
+		grantType := "client_credentials"
+		if cfg.OAuth2.GrantType == "password" {
+			grantType = "password_credentials"
+		}
+		clientAuth := "header"
+		if cfg.OAuth2.ClientAuth == "body" {
+			clientAuth = "body"
+		}
+		return auth("oauth2",
+			"grant_type", grantType,
+			"accessTokenUrl", cfg.OAuth2.TokenURL,
+			"clientId", cfg.OAuth2.ClientID,
+			"clientSecret", cfg.OAuth2.ClientSecret,
+			"username", cfg.OAuth2.Username,
+			"password", cfg.OAuth2.Password,
+			"scope", cfg.OAuth2.Scope,
+			"audience", cfg.OAuth2.Audience,
+			"client_authentication", clientAuth)
+	}
+	warn(path, fmt.Sprintf("auth type %q could not be exported", cfg.Type))
+	return nil
+}
+
+// postmanEnvironmentExport renders an environment as a Postman environment
+// export. Secrets are marked secret and have no value.
+func postmanEnvironmentExport(environment models.Environment) map[string]interface{} {
+	values := []interface{}{}
+	for _, variable := range exportVariables(environment) {
+		variableType := "default"
+		if variable.secret {
+			variableType = "secret"
+		}
+		values = append(values, map[string]interface{}{"key": variable.key, "value": variable.value, "type": variableType, "enabled": true})
+	}
+	return map[string]interface{}{
+		"id":                      environment.ID.String(),
+		"name":                    environment.Name,
+		"values":                  values,
+		"_postman_variable_scope": "environment",
+	}
+}
+
+// joinItemPath appends a folder or request name to a slash-separated path.
+func joinItemPath(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "/" + name
+}
+
+func sortedStringKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
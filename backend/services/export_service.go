@@ -0,0 +1,277 @@
+package services
+
+import (
+	"archive/zip"
+	"backend/models"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Collection export formats.
+const (
+	ExportFormatPostman  = "postman"
+	ExportFormatInsomnia = "insomnia"
+	ExportFormatOpenAPI  = "openapi"
+)
+
+// exportExecutionSamples bounds how many recent executions of each request
+// are used to infer its responses in an OpenAPI export.
+const exportExecutionSamples = 20
+
+// ExportFile is a rendered export, ready to download.
+type ExportFile struct {
+	Name        string
+	ContentType string
+	Data        []byte
+	Warnings    []string // what the format could not carry, e.g. skipped gRPC requests
+}
+
+// ExportService renders collections in other tools' formats.
+type ExportService struct {
+	db                 *gorm.DB
+	collectionService  *CollectionService
+	folderService      *FolderService
+	environmentService *EnvironmentService
+}
+
+func NewExportService(db *gorm.DB, folderService *FolderService) *ExportService {
+	return &ExportService{
+		db:                 db,
+		collectionService:  NewCollectionService(db),
+		folderService:      folderService,
+		environmentService: NewEnvironmentService(db),
+	}
+}
+
+// exportData is what the format renderers work from.
+type exportData struct {
+	collection   *models.Collection
+	tree         []TreeItem
+	environments []models.Environment             // with Variables and Secrets loaded
+	executions   map[uuid.UUID][]models.Execution // recent executions per request, OpenAPI only
+}
+
+// Export renders a collection and the given workspace environments. Secret
+// values are never exported; secrets appear as variables with empty values
+// and auth secrets as {{redacted}}, with a warning for each item.
+// A Postman export with environments is a zip of the collection and one
+// file per environment, as Postman keeps them apart.
+func (s *ExportService) Export(collectionID, userID uuid.UUID, format string, environmentIDs []uuid.UUID) (*ExportFile, error) {
+	switch format {
+	case ExportFormatPostman, ExportFormatInsomnia, ExportFormatOpenAPI:
+	default:
+		return nil, fmt.Errorf("unsupported export format: %s", format)
+	}
+
+	collection, err := s.collectionService.GetByID(collectionID, userID)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := s.folderService.GetTree(collectionID, userID)
+	if err != nil {
+		return nil, err
+	}
+	data := &exportData{collection: collection, tree: tree}
+	for _, environmentID := range environmentIDs {
+		environment, err := s.environmentService.GetByID(collection.WorkspaceID, environmentID, userID)
+		if err != nil {
+			return nil, fmt.Errorf("environment %s: %w", environmentID, err)
+		}
+		data.environments = append(data.environments, *environment)
+	}
+
+	file := &ExportFile{}
+	warn := func(item, message string) {
+		if item != "" {
+			message = item + ": " + message
+		}
+		file.Warnings = append(file.Warnings, message)
+	}
+	name := exportFileName(collection.Name)
+	// OpenAPI only names security schemes; the other formats carry auth values
+	if format != ExportFormatOpenAPI {
+		redactExport(data, warn)
+	}
+
+	switch format {
+	case ExportFormatPostman:
+		collectionJSON, err := json.MarshalIndent(postmanExport(data, warn), "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		if len(data.environments) == 0 {
+			file.Name, file.ContentType, file.Data = name+".postman_collection.json", "application/json", collectionJSON
+			break
+		}
+		var archive bytes.Buffer
+		writer := zip.NewWriter(&archive)
+		files := map[string][]byte{name + ".postman_collection.json": collectionJSON}
+		order := []string{name + ".postman_collection.json"}
+		for _, environment := range data.environments {
+			environmentJSON, err := json.MarshalIndent(postmanEnvironmentExport(environment), "", "  ")
+			if err != nil {
+				return nil, err
+			}
+			fileName := exportFileName(environment.Name) + ".postman_environment.json"
+			if _, exists := files[fileName]; exists {
+				fileName = exportFileName(environment.Name) + "-" + environment.ID.String()[:8] + ".postman_environment.json"
+			}
+			files[fileName] = environmentJSON
+			order = append(order, fileName)
+		}
+		for _, fileName := range order {
+			entry, err := writer.Create(fileName)
+			if err != nil {
+				return nil, err
+			}
+			entry.Write(files[fileName])
+		}
+		if err := writer.Close(); err != nil {
+			return nil, err
+		}
+		file.Name, file.ContentType, file.Data = name+".postman.zip", "application/zip", archive.Bytes()
+
+	case ExportFormatInsomnia:
+		encoded, err := json.MarshalIndent(insomniaExport(data, warn), "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		file.Name, file.ContentType, file.Data = name+".insomnia.json", "application/json", encoded
+
+	case ExportFormatOpenAPI:
+		if data.executions, err = s.recentExecutions(tree); err != nil {
+			return nil, err
+		}
+		encoded, err := json.MarshalIndent(openAPIExport(data, warn), "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		file.Name, file.ContentType, file.Data = name+".openapi.json", "application/json", encoded
+	}
+	return file, nil
+}
+
+// redactExport replaces the auth secrets of an export's collection, folders
+// and requests with redactedSecret, warning for each item that had any.
+func redactExport(data *exportData, warn func(item, message string)) {
+	redact := func(auth *string, path string) {
+		if redacted := RedactAuth(*auth); strings.Contains(redacted, redactedSecret) {
+			*auth = redacted
+			warn(path, "auth secrets were replaced with "+redactedSecret)
+		}
+	}
+	redact(&data.collection.Auth, "")
+	var walk func(items []TreeItem, parentPath string)
+	walk = func(items []TreeItem, parentPath string) {
+		for i := range items {
+			switch {
+			case items[i].Folder != nil:
+				path := joinItemPath(parentPath, items[i].Folder.Name)
+				redact(&items[i].Folder.Auth, path)
+				walk(items[i].Items, path)
+			case items[i].Request != nil:
+				redact(&items[i].Request.Auth, joinItemPath(parentPath, items[i].Request.Name))
+			}
+		}
+	}
+	walk(data.tree, "")
+}
+
+// recentExecutions loads the latest successful executions of every request
+// in the tree, newest first.
+func (s *ExportService) recentExecutions(tree []TreeItem) (map[uuid.UUID][]models.Execution, error) {
+	var requestIDs []uuid.UUID
+	walkExportTree(tree, nil, func(request *models.Request, _ []models.Folder) {
+		requestIDs = append(requestIDs, request.ID)
+	})
+	executions := map[uuid.UUID][]models.Execution{}
+	if len(requestIDs) == 0 {
+		return executions, nil
+	}
+
+	var rows []models.Execution
+	err := s.db.Select("request_id", "status_code", "response_body", "response_headers", "timestamp").
+		Where("request_id IN ? AND status_code > 0", requestIDs).
+		Order("timestamp DESC").
+		Limit(exportExecutionSamples * len(requestIDs)).
+		Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		if len(executions[row.RequestID]) < exportExecutionSamples {
+			executions[row.RequestID] = append(executions[row.RequestID], row)
+		}
+	}
+	return executions, nil
+}
+
+// walkExportTree calls visit for every request, depth first in tree order,
+// with the chain of folders from the root down to the request.
+func walkExportTree(items []TreeItem, chain []models.Folder, visit func(request *models.Request, chain []models.Folder)) {
+	for _, item := range items {
+		if item.Folder != nil {
+			walkExportTree(item.Items, append(append([]models.Folder{}, chain...), *item.Folder), visit)
+		} else if item.Request != nil {
+			visit(item.Request, chain)
+		}
+	}
+}
+
+// effectiveAuth resolves the auth a request is sent with, following its
+// folders and collection.
+func effectiveAuth(request *models.Request, chain []models.Folder, collection *models.Collection) (*AuthConfig, error) {
+	resolved := *request
+	resolved.Folders = chain
+	resolved.Collection = *collection
+	return resolveAuthConfig(&resolved)
+}
+
+// exportable reports whether a request kind can be carried by HTTP-based
+// export formats.
+func exportable(request *models.Request) bool {
+	switch request.Kind {
+	case "", RequestKindHTTP, RequestKindGraphQL:
+		return true
+	}
+	return false
+}
+
+// requestHeaders decodes a request's headers JSON column.
+func requestHeaders(request *models.Request) map[string]string {
+	headers := map[string]string{}
+	if request.Headers != "" {
+		json.Unmarshal([]byte(request.Headers), &headers)
+	}
+	return headers
+}
+
+// exportVariables returns an environment's variables followed by its
+// secrets, which have their values blanked.
+func exportVariables(environment models.Environment) []importVariable {
+	variables := make([]importVariable, 0, len(environment.Variables)+len(environment.Secrets))
+	for _, variable := range environment.Variables {
+		variables = append(variables, importVariable{key: variable.Key, value: variable.Value})
+	}
+	for _, secret := range environment.Secrets {
+		variables = append(variables, importVariable{key: secret.Key, secret: true})
+	}
+	return variables
+}
+
+var unsafeFileNameChars = regexp.MustCompile(`[^\w.-]+`)
+
+// exportFileName makes a name safe to use as a download file name.
+func exportFileName(name string) string {
+	name = strings.Trim(unsafeFileNameChars.ReplaceAllString(name, "-"), "-.")
+	if name == "" {
+		return "collection"
+	}
+	return name
+}
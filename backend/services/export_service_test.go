@@ -0,0 +1,209 @@
+package services
+
+import (
+	"backend/integrations"
+	"backend/models"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func exportFixture() *exportData {
+	folderID := uuid.New()
+	collection := &models.Collection{ID: uuid.New(), Name: "Shop API", Auth: `{"type":"bearer","bearer":{"token":"{{token}}"}}`}
+	get := &models.Request{
+		ID:          uuid.New(),
+		Name:        "Get order",
+		Method:      "GET",
+		URL:         "{{baseUrl}}/orders/42",
+		Headers:     `{"Accept":"application/json","X-Tenant":"acme"}`,
+		QueryParams: `[{"key":"expand","value":"items"},{"key":"trace","value":"1","enabled":false}]`,
+		BodyMode:    BodyModeNone,
+		FolderID:    &folderID,
+	}
+	create := &models.Request{
+		ID:       uuid.New(),
+		Name:     "Create order",
+		Method:   "POST",
+		URL:      "{{baseUrl}}/orders",
+		BodyMode: BodyModeJSON,
+		Body:     `{"qty":2,"note":"gift"}`,
+		Auth:     `{"type":"none"}`,
+		FolderID: &folderID,
+	}
+	stream := &models.Request{ID: uuid.New(), Name: "Events", Method: "GET", URL: "wss://shop.test/events", Kind: RequestKindWebSocket}
+
+	return &exportData{
+		collection: collection,
+		tree: []TreeItem{
+			{Type: TreeItemFolder, Folder: &models.Folder{ID: folderID, Name: "Orders", Variables: `{"version":"2"}`}, Items: []TreeItem{
+				{Type: TreeItemRequest, Request: get},
+				{Type: TreeItemRequest, Request: create},
+			}},
+			{Type: TreeItemRequest, Request: stream},
+		},
+		environments: []models.Environment{{
+			ID:        uuid.New(),
+			Name:      "Staging",
+			Variables: []models.EnvironmentVariable{{Key: "baseUrl", Value: "https://staging.shop.test"}},
+			Secrets:   []models.EnvironmentSecret{{Key: "token", Value: "s3cret"}},
+		}},
+		executions: map[uuid.UUID][]models.Execution{
+			get.ID: {
+				{StatusCode: 200, ResponseHeaders: `{"Content-Type":["application/json; charset=utf-8"]}`, ResponseBody: `{"id":42,"total":9.5,"note":null}`},
+				{StatusCode: 200, ResponseHeaders: `{"Content-Type":["application/json"]}`, ResponseBody: `{"id":41,"total":3}`},
+				{StatusCode: 404, ResponseHeaders: `{"Content-Type":["text/plain"]}`, ResponseBody: "not found"},
+			},
+		},
+	}
+}
+
+func TestPostmanExport_RoundTrips(t *testing.T) {
+	data := exportFixture()
+	var warnings []string
+	encoded, err := json.Marshal(postmanExport(data, func(item, message string) { warnings = append(warnings, item+": "+message) }))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Events: websocket requests cannot be exported to Postman and were skipped"}, warnings)
+
+	collection, err := integrations.NewPostmanImporter().Parse(encoded)
+	require.NoError(t, err)
+	assert.Equal(t, "Shop API", collection.Info.Name)
+	assert.Equal(t, "{{token}}", collection.Auth.Get("token"))
+	require.Len(t, collection.Item, 1)
+	require.True(t, collection.Item[0].IsFolder())
+	assert.Equal(t, "version", collection.Item[0].Variable[0].Key)
+
+	get := PostmanRequestToModel(collection.Item[0].Item[0], func(string) {})
+	assert.Equal(t, "{{baseUrl}}/orders/42", get.URL)
+	assert.JSONEq(t, data.tree[0].Items[0].Request.Headers, get.Headers)
+	assert.JSONEq(t, `[{"key":"expand","value":"items"},{"key":"trace","value":"1","enabled":false}]`, get.QueryParams)
+
+	create := PostmanRequestToModel(collection.Item[0].Item[1], func(string) {})
+	assert.Equal(t, BodyModeJSON, create.BodyMode)
+	assert.JSONEq(t, `{"qty":2,"note":"gift"}`, create.Body)
+	assert.JSONEq(t, `{"type":"none"}`, create.Auth)
+
+	environment := postmanEnvironmentExport(data.environments[0])
+	values := environment["values"].([]interface{})
+	require.Len(t, values, 2)
+	assert.Equal(t, map[string]interface{}{"key": "token", "value": "", "type": "secret", "enabled": true}, values[1], "secret values are not exported")
+}
+
+func TestInsomniaExport(t *testing.T) {
+	data := exportFixture()
+	export := insomniaExport(data, func(string, string) {})
+	assert.Equal(t, 4, export["__export_format"])
+
+	resources := export["resources"].([]interface{})
+	byType := map[string][]map[string]interface{}{}
+	for _, resource := range resources {
+		entry := resource.(map[string]interface{})
+		byType[entry["_type"].(string)] = append(byType[entry["_type"].(string)], entry)
+	}
+	require.Len(t, byType["workspace"], 1)
+	require.Len(t, byType["environment"], 2)
+	assert.Equal(t, map[string]interface{}{"baseUrl": "https://staging.shop.test", "token": ""}, byType["environment"][1]["data"])
+	require.Len(t, byType["request_group"], 1)
+	require.Len(t, byType["request"], 2)
+
+	get := byType["request"][0]
+	assert.Equal(t, "{{ _.baseUrl }}/orders/42", get["url"])
+	assert.Equal(t, byType["request_group"][0]["_id"], get["parentId"])
+	assert.Equal(t, map[string]interface{}{"type": "bearer", "token": "{{ _.token }}", "prefix": ""}, get["authentication"], "inherited auth is resolved")
+	assert.Equal(t, map[string]interface{}{}, byType["request"][1]["authentication"])
+	assert.Equal(t, map[string]interface{}{"mimeType": "application/json", "text": `{"qty":2,"note":"gift"}`}, byType["request"][1]["body"])
+}
+
+func TestRedactExport(t *testing.T) {
+	data := exportFixture()
+	data.collection.Auth = `{"type":"basic","basic":{"username":"shop","password":"collection-pass"}}`
+	data.tree[0].Folder.Auth = `{"type":"apikey","apikey":{"key":"X-Key","value":"folder-key","in":"header"}}`
+	data.tree[0].Items[0].Request.Auth = `{"type":"oauth2","oauth2":{"grant_type":"password","token_url":"https://auth.test/token","client_id":"shop","client_secret":"client-secret","username":"ann","password":"oauth-pass"}}`
+	data.tree[0].Items[1].Request.Auth = `{"type":"awsv4","awsv4":{"access_key_id":"AKID","secret_access_key":"aws-secret","session_token":"aws-session","region":"eu-west-1","service":"execute-api"}}`
+
+	var warnings []string
+	warn := func(item, message string) { warnings = append(warnings, item+": "+message) }
+	redactExport(data, warn)
+	assert.Equal(t, []string{
+		": auth secrets were replaced with {{redacted}}",
+		"Orders: auth secrets were replaced with {{redacted}}",
+		"Orders/Get order: auth secrets were replaced with {{redacted}}",
+		"Orders/Create order: auth secrets were replaced with {{redacted}}",
+	}, warnings)
+
+	postman, err := json.Marshal(postmanExport(data, func(string, string) {}))
+	require.NoError(t, err)
+	insomnia, err := json.Marshal(insomniaExport(data, func(string, string) {}))
+	require.NoError(t, err)
+	for _, secret := range []string{"collection-pass", "folder-key", "client-secret", "oauth-pass", "aws-secret", "aws-session"} {
+		assert.NotContains(t, string(postman), secret)
+		assert.NotContains(t, string(insomnia), secret)
+	}
+	assert.Contains(t, string(postman), "AKID", "only secrets are redacted")
+}
+
+func TestOpenAPIExport(t *testing.T) {
+	data := exportFixture()
+	encoded, err := json.Marshal(openAPIExport(data, func(string, string) {}))
+	require.NoError(t, err)
+
+	doc, err := ParseOpenAPI(encoded)
+	require.NoError(t, err, "the export is a valid OpenAPI document")
+	operations, err := doc.Operations()
+	require.NoError(t, err)
+	require.Len(t, operations, 2)
+	assert.Equal(t, "/orders", operations[0].Path)
+	assert.Equal(t, "/orders/{orderId}", operations[1].Path, "ID-like segments become path parameters")
+
+	servers := doc.Root["servers"].([]interface{})
+	assert.Equal(t, map[string]interface{}{
+		"url":       "{baseUrl}",
+		"variables": map[string]interface{}{"baseUrl": map[string]interface{}{"default": "https://staging.shop.test"}},
+	}, servers[0])
+
+	get := operations[1].Operation
+	assert.Equal(t, []interface{}{"Orders"}, get["tags"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"bearerAuth": []interface{}{}}}, get["security"])
+	names := []string{}
+	for _, parameter := range operations[1].Parameters {
+		names = append(names, parameter["in"].(string)+":"+parameter["name"].(string))
+	}
+	assert.Equal(t, []string{"path:orderId", "query:expand", "query:trace", "header:X-Tenant"}, names)
+
+	responses := get["responses"].(map[string]interface{})
+	ok := responses["200"].(map[string]interface{})["content"].(map[string]interface{})["application/json"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"id": 42.0, "total": 9.5, "note": nil}, ok["example"])
+	schema := ok["schema"].(map[string]interface{})
+	assert.Equal(t, []interface{}{"id", "total"}, schema["required"], "note is missing from one response")
+	assert.Equal(t, "number", schema["properties"].(map[string]interface{})["total"].(map[string]interface{})["type"])
+	assert.Contains(t, responses["404"].(map[string]interface{})["content"], "text/plain")
+
+	create := operations[0].Operation
+	assert.Equal(t, []interface{}{}, create["security"])
+	body := create["requestBody"].(map[string]interface{})["content"].(map[string]interface{})["application/json"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"qty": 2.0, "note": "gift"}, body["example"])
+	assert.Equal(t, map[string]interface{}{"default": map[string]interface{}{"description": "Response"}}, create["responses"])
+}
+
+func TestMergeJSONSchemas(t *testing.T) {
+	a := inferJSONSchema(map[string]interface{}{"id": 1.0, "tags": []interface{}{"x"}})
+	b := inferJSONSchema(map[string]interface{}{"id": 1.5, "tags": []interface{}{}, "next": nil})
+	merged := mergeJSONSchemas(a, b)
+
+	properties := merged["properties"].(map[string]interface{})
+	assert.Equal(t, "number", properties["id"].(map[string]interface{})["type"])
+	assert.Equal(t, map[string]interface{}{"type": "string"}, properties["tags"].(map[string]interface{})["items"])
+	assert.Equal(t, "null", properties["next"].(map[string]interface{})["type"])
+	assert.Equal(t, []interface{}{"id", "tags"}, merged["required"])
+
+	assert.Equal(t, []interface{}{"null", "string"}, mergeJSONSchemas(inferJSONSchema("a"), inferJSONSchema(nil))["type"])
+	assert.Equal(t, "uuid", inferJSONSchema("0f8fad5b-d9cb-469f-a165-70867728950e")["format"])
+}
+
+func TestExportFileName(t *testing.T) {
+	assert.Equal(t, "Shop-API-v2", exportFileName("Shop API / v2"))
+	assert.Equal(t, "collection", exportFileName("../"))
+}
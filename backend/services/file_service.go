@@ -0,0 +1,85 @@
+package services
+
+import (
+	"backend/models"
+	"errors"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// MaxFileSize is the largest upload accepted for request body files (32MB).
+const MaxFileSize = 32 << 20
+
+// FileService stores files referenced by multipart and binary request bodies.
+type FileService struct {
+	db               *gorm.DB
+	workspaceService *WorkspaceService
+}
+
+func NewFileService(db *gorm.DB) *FileService {
+	return &FileService{
+		db:               db,
+		workspaceService: NewWorkspaceService(db),
+	}
+}
+
+// Upload stores a file in the workspace.
+func (s *FileService) Upload(workspaceID, userID uuid.UUID, name, contentType string, data []byte) (*models.File, error) {
+	if !s.workspaceService.HasAccess(workspaceID, userID) {
+		return nil, errors.New("access denied")
+	}
+	if len(data) > MaxFileSize {
+		return nil, errors.New("file too large")
+	}
+
+	file := models.File{
+		WorkspaceID: workspaceID,
+		Name:        name,
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		Data:        data,
+		CreatedBy:   userID,
+	}
+
+	if err := s.db.Create(&file).Error; err != nil {
+		return nil, err
+	}
+
+	return &file, nil
+}
+
+// Get returns a file with its contents.
+func (s *FileService) Get(fileID, workspaceID, userID uuid.UUID) (*models.File, error) {
+	if !s.workspaceService.HasAccess(workspaceID, userID) {
+		return nil, errors.New("access denied")
+	}
+
+	var file models.File
+	if err := s.db.Where("id = ? AND workspace_id = ?", fileID, workspaceID).First(&file).Error; err != nil {
+		return nil, err
+	}
+
+	return &file, nil
+}
+
+// GetAll lists file metadata for a workspace without loading contents.
+func (s *FileService) GetAll(workspaceID, userID uuid.UUID) ([]models.File, error) {
+	if !s.workspaceService.HasAccess(workspaceID, userID) {
+		return nil, errors.New("access denied")
+	}
+
+	var files []models.File
+	err := s.db.Omit("data").Where("workspace_id = ?", workspaceID).Order("created_at DESC").Find(&files).Error
+	return files, err
+}
+
+// Delete removes a file from the workspace.
+func (s *FileService) Delete(fileID, workspaceID, userID uuid.UUID) error {
+	file, err := s.Get(fileID, workspaceID, userID)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Delete(file).Error
+}
@@ -0,0 +1,528 @@
+package services
+
+import (
+	"backend/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Tree item types.
+const (
+	TreeItemFolder  = "folder"
+	TreeItemRequest = "request"
+)
+
+// maxFolderDepth bounds folder nesting, and with it the ancestor walks.
+const maxFolderDepth = 32
+
+// TreeItem is a folder or request in a collection tree. Folders carry their
+// contents in Items, ordered by position.
+type TreeItem struct {
+	Type    string          `json:"type"` // folder, request
+	Folder  *models.Folder  `json:"folder,omitempty"`
+	Request *models.Request `json:"request,omitempty"`
+	Items   []TreeItem      `json:"items,omitempty"`
+}
+
+// FolderRun is the outcome of running every request in a folder.
+type FolderRun struct {
+	FolderID uuid.UUID       `json:"folder_id"`
+	TraceID  uuid.UUID       `json:"trace_id"` // shared by all executions of the run
+	Results  []FolderRunItem `json:"results"`
+	Passed   int             `json:"passed"`
+	Failed   int             `json:"failed"`
+}
+
+type FolderRunItem struct {
+	RequestID uuid.UUID         `json:"request_id"`
+	Name      string            `json:"name"`
+	Path      string            `json:"path"` // folder names from the run's folder down, e.g. "Orders/Refunds"
+	Execution *models.Execution `json:"execution,omitempty"`
+	Error     string            `json:"error,omitempty"`
+}
+
+type FolderService struct {
+	db               *gorm.DB
+	workspaceService *WorkspaceService
+	requestService   *RequestService
+}
+
+func NewFolderService(db *gorm.DB, requestService *RequestService) *FolderService {
+	return &FolderService{
+		db:               db,
+		workspaceService: NewWorkspaceService(db),
+		requestService:   requestService,
+	}
+}
+
+// Create adds a folder to a collection, at the root or under parentID.
+func (s *FolderService) Create(collectionID, userID uuid.UUID, folder *models.Folder) (*models.Folder, error) {
+	var collection models.Collection
+	if err := s.db.First(&collection, collectionID).Error; err != nil {
+		return nil, err
+	}
+	if !s.workspaceService.HasAccess(collection.WorkspaceID, userID) {
+		return nil, errors.New("access denied")
+	}
+
+	if folder.ParentID != nil {
+		chain, err := folderChain(s.db, collectionID, folder.ParentID)
+		if err != nil {
+			return nil, err
+		}
+		if len(chain) >= maxFolderDepth {
+			return nil, fmt.Errorf("folders can be nested at most %d levels deep", maxFolderDepth)
+		}
+	}
+	if err := validateFolderVariables(folder.Variables); err != nil {
+		return nil, err
+	}
+
+	folder.ID = uuid.Nil
+	folder.CollectionID = collectionID
+	if err := s.db.Create(folder).Error; err != nil {
+		return nil, err
+	}
+	return folder, nil
+}
+
+func (s *FolderService) GetByID(folderID, userID uuid.UUID) (*models.Folder, error) {
+	var folder models.Folder
+	if err := s.db.Preload("Collection").First(&folder, folderID).Error; err != nil {
+		return nil, err
+	}
+	if !s.workspaceService.HasAccess(folder.Collection.WorkspaceID, userID) {
+		return nil, errors.New("access denied")
+	}
+	return &folder, nil
+}
+
+// Update changes a folder's name, description, auth, settings or variables.
+// Use Move to change where it sits.
+func (s *FolderService) Update(folderID, userID uuid.UUID, updates map[string]interface{}) (*models.Folder, error) {
+	folder, err := s.GetByID(folderID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := map[string]bool{"name": true, "description": true, "auth": true, "settings": true, "variables": true}
+	for key := range updates {
+		if !allowed[key] {
+			return nil, fmt.Errorf("%s cannot be updated, use move to change a folder's parent or position", key)
+		}
+	}
+	if variables, ok := updates["variables"].(string); ok {
+		if err := validateFolderVariables(variables); err != nil {
+			return nil, err
+		}
+	}
+	if auth, ok := updates["auth"].(string); ok {
+		updates["auth"] = mergeAuthSecrets(auth, folder.Auth)
+	}
+
+	if err := s.db.Model(folder).Omit("Collection").Updates(updates).Error; err != nil {
+		return nil, err
+	}
+	return folder, nil
+}
+
+// Delete removes a folder with its subfolders and their requests.
+func (s *FolderService) Delete(folderID, userID uuid.UUID) error {
+	folder, err := s.GetByID(folderID, userID)
+	if err != nil {
+		return err
+	}
+
+	folders, err := collectionFolders(s.db, folder.CollectionID)
+	if err != nil {
+		return err
+	}
+	subtree := descendantFolderIDs(folders, folder.ID)
+
+	var requests []models.Request
+	if err := s.db.Where("folder_id IN ?", subtree).Find(&requests).Error; err != nil {
+		return err
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		for i := range requests {
+			if err := tx.Delete(&requests[i]).Error; err != nil {
+				return err
+			}
+			if _, err := s.requestService.revisions.recordRequest(tx, folder.Collection.WorkspaceID, userID, &requests[i], RevisionDelete); err != nil {
+				return err
+			}
+		}
+		if len(requests) > 0 {
+			if err := tx.Model(&folder.Collection).Update("request_count", gorm.Expr("request_count - ?", len(requests))).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Where("id IN ?", subtree).Delete(&models.Folder{}).Error
+	})
+}
+
+// GetTree returns a collection's folders and requests as a tree.
+func (s *FolderService) GetTree(collectionID, userID uuid.UUID) ([]TreeItem, error) {
+	var collection models.Collection
+	if err := s.db.First(&collection, collectionID).Error; err != nil {
+		return nil, err
+	}
+	if !s.workspaceService.HasAccess(collection.WorkspaceID, userID) {
+		return nil, errors.New("access denied")
+	}
+
+	folders, err := collectionFolders(s.db, collectionID)
+	if err != nil {
+		return nil, err
+	}
+	var requests []models.Request
+	if err := s.db.Where("collection_id = ?", collectionID).Find(&requests).Error; err != nil {
+		return nil, err
+	}
+
+	return buildTree(folders, requests, nil, 0), nil
+}
+
+// Move places a folder under parentID (nil for the collection root) at
+// position among its new siblings, renumbering them.
+func (s *FolderService) Move(folderID, userID uuid.UUID, parentID *uuid.UUID, position int) (*models.Folder, error) {
+	folder, err := s.GetByID(folderID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if parentID != nil {
+		chain, err := folderChain(s.db, folder.CollectionID, parentID)
+		if err != nil {
+			return nil, err
+		}
+		for _, ancestor := range chain {
+			if ancestor.ID == folder.ID {
+				return nil, errors.New("a folder cannot be moved into itself or one of its subfolders")
+			}
+		}
+		if len(chain)+folderHeight(s.db, folder) > maxFolderDepth {
+			return nil, fmt.Errorf("folders can be nested at most %d levels deep", maxFolderDepth)
+		}
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(folder).Omit("Collection").Update("parent_id", parentID).Error; err != nil {
+			return err
+		}
+		return reorderSiblings(tx, folder.CollectionID, parentID, TreeItemFolder, folder.ID, position)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.First(folder, folder.ID).Error; err != nil {
+		return nil, err
+	}
+	return folder, nil
+}
+
+// MoveRequest places a request in folderID (nil for the collection root) at
+// position among its new siblings, renumbering them. A change of folder is
+// recorded in the request's revision history.
+func (s *FolderService) MoveRequest(requestID, userID uuid.UUID, folderID *uuid.UUID, position int) (*models.Request, error) {
+	request, err := s.requestService.GetByID(requestID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if folderID != nil {
+		if _, err := folderChain(s.db, request.CollectionID, folderID); err != nil {
+			return nil, err
+		}
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(request).Omit("Collection").Update("folder_id", folderID).Error; err != nil {
+			return err
+		}
+		if err := reorderSiblings(tx, request.CollectionID, folderID, TreeItemRequest, request.ID, position); err != nil {
+			return err
+		}
+		request.FolderID = folderID
+		_, err := s.requestService.revisions.recordRequest(tx, request.Collection.WorkspaceID, userID, request, RevisionUpdate)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.First(request, request.ID).Error; err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+// Execute runs every request in a folder and its subfolders in tree order,
+// sharing one trace. A failed request does not stop the run; cancelling ctx
+// does.
+func (s *FolderService) Execute(ctx context.Context, folderID, userID uuid.UUID) (*FolderRun, error) {
+	folder, err := s.GetByID(folderID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	folders, err := collectionFolders(s.db, folder.CollectionID)
+	if err != nil {
+		return nil, err
+	}
+	var requests []models.Request
+	err = s.db.Where("folder_id IN ?", descendantFolderIDs(folders, folder.ID)).Find(&requests).Error
+	if err != nil {
+		return nil, err
+	}
+
+	run := &FolderRun{FolderID: folder.ID, TraceID: uuid.New(), Results: []FolderRunItem{}}
+	var walk func(items []TreeItem, path string)
+	walk = func(items []TreeItem, path string) {
+		for _, item := range items {
+			if ctx.Err() != nil {
+				return
+			}
+			if item.Type == TreeItemFolder {
+				walk(item.Items, path+"/"+item.Folder.Name)
+				continue
+			}
+			result := FolderRunItem{RequestID: item.Request.ID, Name: item.Request.Name, Path: path}
+			execution, err := s.requestService.ExecuteContext(ctx, item.Request.ID, userID, "", nil, run.TraceID, nil, nil)
+			if err != nil {
+				result.Error = err.Error()
+			} else {
+				result.Execution, result.Error = execution, execution.ErrorMessage
+			}
+			if result.Error != "" || execution.StatusCode >= 400 {
+				run.Failed++
+			} else {
+				run.Passed++
+			}
+			run.Results = append(run.Results, result)
+		}
+	}
+	walk(buildTree(folders, requests, &folder.ID, 0), folder.Name)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return run, nil
+}
+
+// folderChain returns the folders from the collection root down to folderID.
+func folderChain(db *gorm.DB, collectionID uuid.UUID, folderID *uuid.UUID) ([]models.Folder, error) {
+	if folderID == nil {
+		return nil, nil
+	}
+	folders, err := collectionFolders(db, collectionID)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[uuid.UUID]models.Folder, len(folders))
+	for _, folder := range folders {
+		byID[folder.ID] = folder
+	}
+
+	var chain []models.Folder
+	for id := folderID; id != nil; {
+		folder, ok := byID[*id]
+		if !ok {
+			return nil, errors.New("folder not found in this collection")
+		}
+		if len(chain) > maxFolderDepth {
+			return nil, errors.New("folder tree contains a cycle")
+		}
+		chain = append([]models.Folder{folder}, chain...)
+		id = folder.ParentID
+	}
+	return chain, nil
+}
+
+func collectionFolders(db *gorm.DB, collectionID uuid.UUID) ([]models.Folder, error) {
+	var folders []models.Folder
+	if err := db.Where("collection_id = ?", collectionID).Find(&folders).Error; err != nil {
+		return nil, err
+	}
+	return folders, nil
+}
+
+// descendantFolderIDs returns rootID and the IDs of every folder beneath it.
+func descendantFolderIDs(folders []models.Folder, rootID uuid.UUID) []uuid.UUID {
+	ids := []uuid.UUID{rootID}
+	for i := 0; i < len(ids) && i <= len(folders); i++ {
+		for _, folder := range folders {
+			if folder.ParentID != nil && *folder.ParentID == ids[i] {
+				ids = append(ids, folder.ID)
+			}
+		}
+	}
+	return ids
+}
+
+// folderHeight counts the levels a folder and its deepest subfolder occupy.
+func folderHeight(db *gorm.DB, folder *models.Folder) int {
+	folders, err := collectionFolders(db, folder.CollectionID)
+	if err != nil {
+		return 1
+	}
+	var height func(id uuid.UUID, depth int) int
+	height = func(id uuid.UUID, depth int) int {
+		max := depth
+		if depth > maxFolderDepth {
+			return depth
+		}
+		for _, child := range folders {
+			if child.ParentID != nil && *child.ParentID == id {
+				if h := height(child.ID, depth+1); h > max {
+					max = h
+				}
+			}
+		}
+		return max
+	}
+	return height(folder.ID, 1)
+}
+
+// buildTree nests the folders and requests under parentID, each level
+// ordered by position and then creation time.
+func buildTree(folders []models.Folder, requests []models.Request, parentID *uuid.UUID, depth int) []TreeItem {
+	items := []TreeItem{}
+	if depth > maxFolderDepth {
+		return items
+	}
+	for i := range folders {
+		if sameParent(folders[i].ParentID, parentID) {
+			items = append(items, TreeItem{
+				Type:   TreeItemFolder,
+				Folder: &folders[i],
+				Items:  buildTree(folders, requests, &folders[i].ID, depth+1),
+			})
+		}
+	}
+	for i := range requests {
+		if sameParent(requests[i].FolderID, parentID) {
+			items = append(items, TreeItem{Type: TreeItemRequest, Request: &requests[i]})
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		pi, ci := items[i].order()
+		pj, cj := items[j].order()
+		if pi != pj {
+			return pi < pj
+		}
+		return ci.Before(cj)
+	})
+	return items
+}
+
+func (item TreeItem) order() (int, time.Time) {
+	if item.Folder != nil {
+		return item.Folder.Position, item.Folder.CreatedAt
+	}
+	return item.Request.Position, item.Request.CreatedAt
+}
+
+func sameParent(a, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+// reorderSiblings moves one folder or request to position among everything
+// sharing its parent and renumbers the siblings 0..n-1.
+func reorderSiblings(tx *gorm.DB, collectionID uuid.UUID, parentID *uuid.UUID, itemType string, itemID uuid.UUID, position int) error {
+	folderQuery := tx.Where("collection_id = ?", collectionID)
+	requestQuery := tx.Where("collection_id = ?", collectionID)
+	if parentID == nil {
+		folderQuery = folderQuery.Where("parent_id IS NULL")
+		requestQuery = requestQuery.Where("folder_id IS NULL")
+	} else {
+		folderQuery = folderQuery.Where("parent_id = ?", *parentID)
+		requestQuery = requestQuery.Where("folder_id = ?", *parentID)
+	}
+
+	var folders []models.Folder
+	if err := folderQuery.Find(&folders).Error; err != nil {
+		return err
+	}
+	var requests []models.Request
+	if err := requestQuery.Find(&requests).Error; err != nil {
+		return err
+	}
+
+	// Only this level is needed, so start at the depth limit to skip nesting
+	siblings := buildTree(folders, requests, parentID, maxFolderDepth)
+	var moved *TreeItem
+	ordered := make([]TreeItem, 0, len(siblings))
+	for i := range siblings {
+		if siblings[i].Type == itemType && siblings[i].id() == itemID {
+			moved = &siblings[i]
+			continue
+		}
+		ordered = append(ordered, siblings[i])
+	}
+	if moved == nil {
+		return errors.New("moved item not found among its siblings")
+	}
+	if position < 0 || position > len(ordered) {
+		position = len(ordered)
+	}
+	ordered = append(ordered[:position], append([]TreeItem{*moved}, ordered[position:]...)...)
+
+	for i, item := range ordered {
+		if current, _ := item.order(); current == i {
+			continue
+		}
+		var err error
+		if item.Type == TreeItemFolder {
+			err = tx.Model(&models.Folder{}).Where("id = ?", item.id()).Update("position", i).Error
+		} else {
+			err = tx.Model(&models.Request{}).Where("id = ?", item.id()).Update("position", i).Error
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (item TreeItem) id() uuid.UUID {
+	if item.Folder != nil {
+		return item.Folder.ID
+	}
+	return item.Request.ID
+}
+
+// folderVariables merges the variables of a request's folders, inner folders
+// overriding outer ones.
+func folderVariables(request *models.Request) map[string]string {
+	vars := map[string]string{}
+	for _, folder := range request.Folders {
+		var values map[string]string
+		if folder.Variables != "" && json.Unmarshal([]byte(folder.Variables), &values) == nil {
+			for key, value := range values {
+				vars[key] = value
+			}
+		}
+	}
+	return vars
+}
+
+func validateFolderVariables(raw string) error {
+	if raw == "" {
+		return nil
+	}
+	var values map[string]string
+	if err := json.Unmarshal([]byte(raw), &values); err != nil {
+		return errors.New("folder variables must be an object of string values")
+	}
+	return nil
+}
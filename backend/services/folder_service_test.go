@@ -0,0 +1,227 @@
+package services
+
+import (
+	"backend/models"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildTree_OrdersByPositionThenCreation(t *testing.T) {
+	now := time.Now()
+	orders := models.Folder{ID: uuid.New(), Name: "Orders", Position: 1, CreatedAt: now}
+	refunds := models.Folder{ID: uuid.New(), ParentID: &orders.ID, Name: "Refunds", CreatedAt: now}
+	folders := []models.Folder{orders, refunds}
+	requests := []models.Request{
+		{ID: uuid.New(), Name: "Health", Position: 0, CreatedAt: now.Add(time.Second)},
+		{ID: uuid.New(), Name: "Login", Position: 0, CreatedAt: now},
+		{ID: uuid.New(), Name: "Create refund", FolderID: &refunds.ID},
+		{ID: uuid.New(), Name: "List orders", FolderID: &orders.ID, Position: 1},
+	}
+
+	tree := buildTree(folders, requests, nil, 0)
+	require.Len(t, tree, 3)
+	assert.Equal(t, "Login", tree[0].Request.Name)
+	assert.Equal(t, "Health", tree[1].Request.Name)
+	assert.Equal(t, "Orders", tree[2].Folder.Name)
+
+	require.Len(t, tree[2].Items, 2)
+	assert.Equal(t, "Refunds", tree[2].Items[0].Folder.Name)
+	assert.Equal(t, "Create refund", tree[2].Items[0].Items[0].Request.Name)
+	assert.Equal(t, "List orders", tree[2].Items[1].Request.Name)
+}
+
+func TestDescendantFolderIDs(t *testing.T) {
+	root := uuid.New()
+	child := models.Folder{ID: uuid.New(), ParentID: &root}
+	grandchild := models.Folder{ID: uuid.New(), ParentID: &child.ID}
+	sibling := models.Folder{ID: uuid.New()}
+
+	ids := descendantFolderIDs([]models.Folder{grandchild, sibling, {ID: root}, child}, root)
+	assert.ElementsMatch(t, []uuid.UUID{root, child.ID, grandchild.ID}, ids)
+}
+
+func TestResolveAuthConfig_InheritsThroughFolders(t *testing.T) {
+	request := &models.Request{
+		Auth: `{"type":"inherit"}`,
+		Folders: []models.Folder{
+			{Auth: `{"type":"bearer","bearer":{"token":"outer"}}`},
+			{Auth: `{"type":"bearer","bearer":{"token":"inner"}}`},
+			{Auth: `{"type":"inherit"}`},
+		},
+		Collection: models.Collection{Auth: `{"type":"bearer","bearer":{"token":"collection"}}`},
+	}
+
+	cfg, err := resolveAuthConfig(request)
+	require.NoError(t, err)
+	assert.Equal(t, "inner", cfg.Bearer.Token, "the closest folder with auth wins")
+
+	request.Folders = nil
+	cfg, err = resolveAuthConfig(request)
+	require.NoError(t, err)
+	assert.Equal(t, "collection", cfg.Bearer.Token)
+}
+
+func TestResolveClientSettings_LayersFolders(t *testing.T) {
+	request := &models.Request{
+		Collection: models.Collection{Settings: `{"timeout_ms":1000,"http2":true}`},
+		Folders: []models.Folder{
+			{Settings: `{"timeout_ms":2000,"max_redirects":3}`},
+			{Settings: `{"timeout_ms":3000}`},
+		},
+		Settings: `{"max_redirects":5}`,
+	}
+
+	settings, err := resolveClientSettings(`{"timeout_ms":500}`, request)
+	require.NoError(t, err)
+	assert.Equal(t, 3000, *settings.TimeoutMs)
+	assert.Equal(t, 5, *settings.MaxRedirects)
+	assert.True(t, *settings.HTTP2)
+}
+
+func TestFolderVariables_InnerOverridesOuter(t *testing.T) {
+	request := &models.Request{Folders: []models.Folder{
+		{Variables: `{"host":"api.test","version":"v1"}`},
+		{Variables: `{"version":"v2"}`},
+		{},
+	}}
+
+	assert.Equal(t, map[string]string{"host": "api.test", "version": "v2"}, folderVariables(request))
+	assert.Error(t, validateFolderVariables(`{"retries":3}`))
+}
+
+func TestFolderService_Move_RejectsCycle(t *testing.T) {
+	db, mock := setupTestDBRequest(t)
+	service := NewFolderService(db, NewRequestService(db, nil))
+
+	folderID := uuid.New()
+	childID := uuid.New()
+	collectionID := uuid.New()
+	workspaceID := uuid.New()
+	userID := uuid.New()
+
+	mock.ExpectQuery(`(?i)SELECT \* FROM "folders" WHERE "folders"."id" = \$1`).
+		WithArgs(folderID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "collection_id", "name"}).
+			AddRow(folderID, collectionID, "Orders"))
+	mock.ExpectQuery(`(?i)SELECT \* FROM "collections"`).
+		WithArgs(collectionID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "workspace_id"}).AddRow(collectionID, workspaceID))
+	mock.ExpectQuery(`(?i)SELECT count\(\*\) FROM "workspace_members"`).
+		WithArgs(workspaceID, userID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(`(?i)SELECT \* FROM "folders" WHERE collection_id = \$1`).
+		WithArgs(collectionID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "collection_id", "parent_id", "name"}).
+			AddRow(folderID, collectionID, nil, "Orders").
+			AddRow(childID, collectionID, folderID, "Refunds"))
+
+	_, err := service.Move(folderID, userID, &childID, 0)
+	assert.EqualError(t, err, "a folder cannot be moved into itself or one of its subfolders")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFolderService_Delete_UpdatesRequestCount(t *testing.T) {
+	db, mock := setupTestDBRequest(t)
+	service := NewFolderService(db, NewRequestService(db, nil))
+
+	folderID := uuid.New()
+	childID := uuid.New()
+	collectionID := uuid.New()
+	workspaceID := uuid.New()
+	userID := uuid.New()
+	firstID, secondID := uuid.New(), uuid.New()
+
+	mock.ExpectQuery(`(?i)SELECT \* FROM "folders" WHERE "folders"."id" = \$1`).
+		WithArgs(folderID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "collection_id", "name"}).
+			AddRow(folderID, collectionID, "Orders"))
+	mock.ExpectQuery(`(?i)SELECT \* FROM "collections"`).
+		WithArgs(collectionID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "workspace_id"}).AddRow(collectionID, workspaceID))
+	mock.ExpectQuery(`(?i)SELECT count\(\*\) FROM "workspace_members"`).
+		WithArgs(workspaceID, userID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(`(?i)SELECT \* FROM "folders" WHERE collection_id = \$1`).
+		WithArgs(collectionID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "collection_id", "parent_id", "name"}).
+			AddRow(folderID, collectionID, nil, "Orders").
+			AddRow(childID, collectionID, folderID, "Refunds"))
+	mock.ExpectQuery(`(?i)SELECT \* FROM "requests" WHERE folder_id IN \(\$1,\$2\)`).
+		WithArgs(folderID, childID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "collection_id", "folder_id"}).
+			AddRow(firstID, collectionID, folderID).
+			AddRow(secondID, collectionID, childID))
+
+	mock.ExpectBegin()
+	for _, requestID := range []uuid.UUID{firstID, secondID} {
+		mock.ExpectExec(`(?i)UPDATE "requests" SET "deleted_at"`).WillReturnResult(sqlmock.NewResult(0, 1))
+		expectRevisionRecorded(mock, RevisionResourceRequest, requestID)
+	}
+	// Both requests leave the collection's count
+	mock.ExpectExec(`(?i)UPDATE "collections" SET "request_count"=request_count - \$1`).
+		WithArgs(2, sqlmock.AnyArg(), collectionID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`(?i)UPDATE "folders" SET "deleted_at"`).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	require.NoError(t, service.Delete(folderID, userID))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFolderService_MoveRequest_RecordsRevision(t *testing.T) {
+	db, mock := setupTestDBRequest(t)
+	service := NewFolderService(db, NewRequestService(db, nil))
+
+	requestID := uuid.New()
+	folderID := uuid.New()
+	collectionID := uuid.New()
+	workspaceID := uuid.New()
+	userID := uuid.New()
+
+	mock.ExpectQuery(`(?i)SELECT \* FROM "requests"`).
+		WithArgs(requestID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "collection_id", "folder_id", "name"}).
+			AddRow(requestID, collectionID, folderID, "Health"))
+	mock.ExpectQuery(`(?i)SELECT \* FROM "collections"`).
+		WithArgs(collectionID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "workspace_id"}).AddRow(collectionID, workspaceID))
+	mock.ExpectQuery(`(?i)SELECT count\(\*\) FROM "workspace_members"`).
+		WithArgs(workspaceID, userID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(`(?i)SELECT \* FROM "folders" WHERE collection_id = \$1 AND "folders"."deleted_at" IS NULL`).
+		WithArgs(collectionID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "collection_id", "name"}).AddRow(folderID, collectionID, "Ops"))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`(?i)UPDATE "requests" SET "folder_id"=\$1`).
+		WithArgs(nil, sqlmock.AnyArg(), requestID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`(?i)SELECT \* FROM "folders" WHERE collection_id = \$1 AND parent_id IS NULL`).
+		WithArgs(collectionID).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(`(?i)SELECT \* FROM "requests" WHERE collection_id = \$1 AND folder_id IS NULL`).
+		WithArgs(collectionID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "collection_id", "position"}).AddRow(requestID, collectionID, 0))
+	// The move is part of the request's history
+	expectRevisionLock(mock, RevisionResourceRequest, requestID)
+	mock.ExpectQuery(`(?i)SELECT \* FROM "revisions" WHERE resource_type = \$1 AND resource_id = \$2 ORDER BY version DESC`).
+		WithArgs(RevisionResourceRequest, requestID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "version", "snapshot"}).
+			AddRow(uuid.New(), 1, `{"name":"Health","folder_id":"`+folderID.String()+`"}`))
+	mock.ExpectQuery(`(?i)INSERT INTO "revisions"`).
+		WithArgs(workspaceID, RevisionResourceRequest, requestID, 2, RevisionUpdate, snapshotArg{"name": "Health", "folder_id": nil}, userID, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`(?i)SELECT \* FROM "requests" WHERE "requests"."id" = \$1`).
+		WithArgs(requestID, requestID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "collection_id"}).AddRow(requestID, collectionID))
+
+	_, err := service.MoveRequest(requestID, userID, nil, 0)
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
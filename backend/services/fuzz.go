@@ -0,0 +1,447 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Fuzz case categories.
+const (
+	FuzzCategoryValid    = "valid"
+	FuzzCategoryBoundary = "boundary"
+	FuzzCategoryInvalid  = "invalid"
+)
+
+// Limits on the payloads generated for a request schema.
+const (
+	fuzzValidPayloads    = 3
+	maxFuzzFields        = 50 // fields mutated, in schema order
+	maxFuzzDepth         = 5  // nesting followed into objects and arrays
+	maxFuzzEnumValues    = 5
+	maxFuzzItems         = 100     // longest array generated for maxItems
+	hugeFuzzStringLength = 1 << 16 // characters in a huge string
+	minFuzzStringLength  = 16      // strings are not halved below this when minimizing
+)
+
+// fuzzInjections are strings that break naive SQL, HTML, template, shell,
+// path and NoSQL handling.
+var fuzzInjections = []string{
+	"' OR '1'='1",
+	"1; DROP TABLE users; --",
+	"<script>alert(1)</script>",
+	"{{7*7}}${7*7}",
+	"${jndi:ldap://127.0.0.1/a}",
+	"../../../../etc/passwd",
+	"$(id)`id`",
+	`{"$gt": ""}`,
+}
+
+// fuzzUnicode mixes scripts, combining and right-to-left marks, emoji joined
+// by zero-width joiners, and the edges of the Basic Multilingual Plane.
+const fuzzUnicode = "Zażółć gęślą jaźń 日本語 العربية \u202eevil\u202c \U0001F469\u200d\U0001F469\u200d\U0001F467 e\u0301 \u0000\uffff"
+
+// FuzzCase is a payload to send and how it was made.
+type FuzzCase struct {
+	Category string `json:"category"`
+	Mutation string `json:"mutation"`          // generated, empty_string, wrong_type, injection, ...
+	Pointer  string `json:"pointer,omitempty"` // JSON pointer to the mutated field, "" for the whole body
+	Valid    bool   `json:"valid"`             // the payload satisfies the request schema
+	Body     string `json:"-"`
+
+	tokens []string
+}
+
+// fuzzField is a place in the payload that is mutated.
+type fuzzField struct {
+	tokens   []string
+	schema   map[string]interface{}
+	required bool
+}
+
+// fuzzMutation replaces a field's value, or removes it.
+type fuzzMutation struct {
+	category, name string
+	value          interface{}
+	remove         bool
+}
+
+// fuzzCases generates payloads for a request schema: a few valid ones from
+// the TestDataGenerator, then boundary and deliberately invalid variants of
+// the first, field by field. Each payload is checked against the schema to
+// tell whether it is valid. Duplicates are dropped and at most limit cases
+// are returned.
+func fuzzCases(generator *TestDataGenerator, validator *SchemaValidator, schemaJSON string, limit int) ([]FuzzCase, error) {
+	var schema map[string]interface{}
+	if err := json.Unmarshal([]byte(schemaJSON), &schema); err != nil {
+		return nil, fmt.Errorf("request schema is not a JSON object: %w", err)
+	}
+
+	var cases []FuzzCase
+	seen := map[string]bool{}
+	add := func(category, mutation string, tokens []string, body string) {
+		if len(cases) >= limit || seen[body] {
+			return
+		}
+		seen[body] = true
+		cases = append(cases, FuzzCase{Category: category, Mutation: mutation, Pointer: jsonPointer(tokens), Body: body, tokens: tokens})
+	}
+	encode := func(value interface{}) string {
+		encoded, _ := json.Marshal(value)
+		return string(encoded)
+	}
+
+	var base interface{}
+	for i := 0; i < fuzzValidPayloads; i++ {
+		value := generator.generateFromSchemaObject(schema)
+		if i == 0 {
+			base = value
+		}
+		add(FuzzCategoryValid, "generated", nil, encode(value))
+	}
+
+	add(FuzzCategoryInvalid, "malformed_json", nil, strings.TrimSuffix(encode(base), "}")+`,"`)
+	add(FuzzCategoryInvalid, "empty_body", nil, "")
+	add(FuzzCategoryInvalid, "wrong_type", nil, encode(fuzzWrongType(schema)))
+
+	for _, field := range fuzzFields(schema, base) {
+		for _, mutation := range fuzzFieldMutations(generator, field) {
+			add(mutation.category, mutation.name, field.tokens, encode(withJSONValue(base, field.tokens, mutation.value, mutation.remove)))
+		}
+	}
+
+	for i := range cases {
+		if cases[i].Body == "" {
+			continue
+		}
+		result, err := validator.ValidateAgainstOpenAPI(cases[i].Body, schemaJSON)
+		if err != nil && i == 0 {
+			return nil, fmt.Errorf("request schema cannot be used: %w", err)
+		}
+		cases[i].Valid = err == nil && result.Valid
+	}
+	return cases, nil
+}
+
+// fuzzFields lists the fields of a schema that a generated value has, depth
+// first in property order, stopping at maxFuzzFields.
+func fuzzFields(schema map[string]interface{}, value interface{}) []fuzzField {
+	var fields []fuzzField
+	var walk func(schema map[string]interface{}, value interface{}, tokens []string)
+	walk = func(schema map[string]interface{}, value interface{}, tokens []string) {
+		if len(tokens) >= maxFuzzDepth {
+			return
+		}
+		switch v := value.(type) {
+		case map[string]interface{}:
+			properties, _ := schema["properties"].(map[string]interface{})
+			required := requiredSet(schema)
+			for _, key := range sortedKeys(properties) {
+				property, ok := properties[key].(map[string]interface{})
+				if !ok || len(fields) >= maxFuzzFields {
+					continue
+				}
+				path := appendPath(tokens, key)
+				fields = append(fields, fuzzField{tokens: path, schema: property, required: required[key]})
+				if child, ok := v[key]; ok {
+					walk(property, child, path)
+				}
+			}
+		case []interface{}:
+			items, ok := schema["items"].(map[string]interface{})
+			if !ok || len(v) == 0 || len(fields) >= maxFuzzFields {
+				return
+			}
+			path := appendPath(tokens, "0")
+			fields = append(fields, fuzzField{tokens: path, schema: items})
+			walk(items, v[0], path)
+		}
+	}
+	walk(schema, value, nil)
+	return fields
+}
+
+// fuzzFieldMutations lists the boundary values of a field, then invalid
+// values: out of range, a wrong type, null, the field left out when it is
+// required, and for strings huge, unicode and injection strings.
+func fuzzFieldMutations(generator *TestDataGenerator, field fuzzField) []fuzzMutation {
+	var mutations []fuzzMutation
+	boundary := func(name string, value interface{}) {
+		mutations = append(mutations, fuzzMutation{category: FuzzCategoryBoundary, name: name, value: value})
+	}
+	invalid := func(name string, value interface{}) {
+		mutations = append(mutations, fuzzMutation{category: FuzzCategoryInvalid, name: name, value: value})
+	}
+	schema := field.schema
+	minimum, hasMinimum := schema["minimum"].(float64)
+	maximum, hasMaximum := schema["maximum"].(float64)
+	minLength, hasMinLength := schema["minLength"].(float64)
+	maxLength, hasMaxLength := schema["maxLength"].(float64)
+	// Lengths beyond a huge string are left to huge_string
+	hasMinLength = hasMinLength && minLength <= hugeFuzzStringLength
+	hasMaxLength = hasMaxLength && maxLength <= hugeFuzzStringLength
+	minItems, hasMinItems := schema["minItems"].(float64)
+	maxItems, hasMaxItems := schema["maxItems"].(float64)
+	schemaType := fuzzSchemaType(schema)
+
+	if enum, ok := schema["enum"].([]interface{}); ok && len(enum) > 0 {
+		for i, value := range enum {
+			if i < maxFuzzEnumValues {
+				boundary("enum_value", value)
+			}
+		}
+		invalid("not_in_enum", "fuzz-not-in-enum")
+	} else {
+		switch schemaType {
+		case "string":
+			if hasMinLength {
+				boundary("min_length", strings.Repeat("a", int(minLength)))
+			} else {
+				boundary("empty_string", "")
+			}
+			if hasMaxLength {
+				boundary("max_length", strings.Repeat("a", int(maxLength)))
+			}
+			if hasMinLength && minLength > 0 {
+				invalid("below_min_length", strings.Repeat("a", int(minLength)-1))
+			}
+			if hasMaxLength {
+				invalid("above_max_length", strings.Repeat("a", int(maxLength)+1))
+			}
+			if format, ok := schema["format"].(string); ok {
+				invalid("bad_format", "not a valid "+format)
+			}
+		case "integer", "number":
+			if hasMinimum {
+				boundary("minimum", minimum)
+				invalid("below_minimum", minimum-1)
+			} else {
+				boundary("zero", 0)
+				boundary("negative", -1)
+			}
+			if hasMaximum {
+				boundary("maximum", maximum)
+				invalid("above_maximum", maximum+1)
+			} else if schemaType == "integer" {
+				boundary("large_number", 9007199254740991)
+			} else {
+				boundary("large_number", 1.7976931348623157e308)
+			}
+			if schemaType == "integer" {
+				invalid("fraction", 0.5)
+			}
+		case "array":
+			items, _ := schema["items"].(map[string]interface{})
+			array := func(count int) []interface{} {
+				values := make([]interface{}, count)
+				for i := range values {
+					if items != nil {
+						values[i] = generator.generateFromSchemaObject(items)
+					}
+				}
+				return values
+			}
+			if hasMinItems {
+				boundary("min_items", array(int(minItems)))
+			} else {
+				boundary("empty_array", []interface{}{})
+			}
+			if hasMaxItems && maxItems < maxFuzzItems {
+				boundary("max_items", array(int(maxItems)))
+				invalid("above_max_items", array(int(maxItems)+1))
+			}
+		}
+	}
+
+	invalid("wrong_type", fuzzWrongType(schema))
+	if !fuzzAllowsNull(schema) {
+		invalid("null", nil)
+	}
+	if field.required {
+		mutations = append(mutations, fuzzMutation{category: FuzzCategoryInvalid, name: "missing_required", remove: true})
+	}
+	if schemaType == "string" {
+		invalid("huge_string", strings.Repeat("A", hugeFuzzStringLength))
+		invalid("unicode", fuzzUnicode)
+		for _, injection := range fuzzInjections {
+			invalid("injection", injection)
+		}
+	}
+	return mutations
+}
+
+// fuzzSchemaType is the first type a schema allows other than null, or
+// object for an untyped schema with properties.
+func fuzzSchemaType(schema map[string]interface{}) string {
+	for _, t := range schemaTypes(schema) {
+		if t != "null" {
+			return t
+		}
+	}
+	if _, ok := schema["properties"]; ok {
+		return "object"
+	}
+	return ""
+}
+
+func fuzzAllowsNull(schema map[string]interface{}) bool {
+	nullable, _ := schema["nullable"].(bool)
+	return nullable || containsString(schemaTypes(schema), "null")
+}
+
+// fuzzWrongType returns a value of a type the schema does not expect.
+func fuzzWrongType(schema map[string]interface{}) interface{} {
+	switch fuzzSchemaType(schema) {
+	case "string":
+		return 12345
+	case "integer", "number":
+		return "12345"
+	case "boolean":
+		return "true"
+	case "array":
+		return map[string]interface{}{"0": "not an array"}
+	case "object":
+		return []interface{}{"not an object"}
+	}
+	return []interface{}{}
+}
+
+// withJSONValue returns doc with the value at tokens replaced, or removed
+// when remove is set. Only the containers along the path are copied. A
+// path that does not exist up to its parent leaves doc as it is.
+func withJSONValue(doc interface{}, tokens []string, value interface{}, remove bool) interface{} {
+	if len(tokens) == 0 {
+		return value
+	}
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			copied[key] = item
+		}
+		switch {
+		case len(tokens) > 1:
+			child, ok := v[tokens[0]]
+			if !ok {
+				return doc
+			}
+			copied[tokens[0]] = withJSONValue(child, tokens[1:], value, remove)
+		case remove:
+			delete(copied, tokens[0])
+		default:
+			copied[tokens[0]] = value
+		}
+		return copied
+	case []interface{}:
+		i, err := strconv.Atoi(tokens[0])
+		if err != nil || i < 0 || i >= len(v) {
+			return doc
+		}
+		copied := append([]interface{}(nil), v...)
+		switch {
+		case len(tokens) > 1:
+			copied[i] = withJSONValue(v[i], tokens[1:], value, remove)
+		case remove:
+			copied = append(copied[:i], copied[i+1:]...)
+		default:
+			copied[i] = value
+		}
+		return copied
+	}
+	return doc
+}
+
+// minimizeFuzzBody shrinks a JSON payload that reproduces a finding. It
+// drops object fields and array items, then halves long strings, keeping
+// each change for which reproduce still holds, until nothing more can be
+// dropped or attempts candidates were tried. The field at keep, the one the
+// case mutated, and the containers above it are never dropped. Bodies that
+// are not JSON are returned as they are.
+func minimizeFuzzBody(body string, keep []string, attempts int, reproduce func(body string) bool) string {
+	var doc interface{}
+	if json.Unmarshal([]byte(body), &doc) != nil {
+		return body
+	}
+	for attempts > 0 {
+		shrunk := false
+		for _, candidate := range fuzzShrinks(doc, keep) {
+			if attempts == 0 {
+				break
+			}
+			attempts--
+			encoded, _ := json.Marshal(candidate)
+			if reproduce(string(encoded)) {
+				doc, body, shrunk = candidate, string(encoded), true
+				break
+			}
+		}
+		if !shrunk {
+			break
+		}
+	}
+	return body
+}
+
+// fuzzShrinks lists smaller variants of root: removals first, as they
+// shrink the most, then either half of long strings. Along the path to keep, only array
+// items after the kept one are removed, so its index stays the same.
+func fuzzShrinks(root interface{}, keep []string) []interface{} {
+	var removals, halves []interface{}
+	var walk func(value interface{}, tokens []string)
+	walk = func(value interface{}, tokens []string) {
+		next := ""
+		if len(tokens) < len(keep) && equalTokens(tokens, keep[:len(tokens)]) {
+			next = keep[len(tokens)]
+		}
+		switch v := value.(type) {
+		case map[string]interface{}:
+			keys := make([]string, 0, len(v))
+			for key := range v {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				path := appendPath(tokens, key)
+				if key != next {
+					removals = append(removals, withJSONValue(root, path, nil, true))
+				}
+				walk(v[key], path)
+			}
+		case []interface{}:
+			kept := -1
+			if next != "" {
+				kept, _ = strconv.Atoi(next)
+			}
+			for i := len(v) - 1; i >= 0; i-- {
+				path := appendPath(tokens, strconv.Itoa(i))
+				if len(v) > 1 && (next == "" || i > kept) {
+					removals = append(removals, withJSONValue(root, path, nil, true))
+				}
+				walk(v[i], path)
+			}
+		case string:
+			if runes := []rune(v); len(runes) > minFuzzStringLength {
+				half := len(runes) / 2
+				halves = append(halves,
+					withJSONValue(root, tokens, string(runes[:half]), false),
+					withJSONValue(root, tokens, string(runes[half:]), false))
+			}
+		}
+	}
+	walk(root, nil)
+	return append(removals, halves...)
+}
+
+func equalTokens(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
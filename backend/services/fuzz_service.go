@@ -0,0 +1,537 @@
+package services
+
+import (
+	"backend/models"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Kinds of FuzzFinding.
+const (
+	FuzzFindingCrash           = "crash"            // the request got no response
+	FuzzFindingServerError     = "server_error"     // a 5xx status
+	FuzzFindingSchemaViolation = "schema_violation" // the response broke its contract
+)
+
+// Limits on a fuzz run.
+const (
+	defaultFuzzCases          = 200
+	maxFuzzCases              = 1000
+	maxFuzzMinimizeAttempts   = 30 // extra requests sent to minimize a finding
+	maxFuzzConsecutiveCrashes = 5  // unanswered requests in a row that end the run
+)
+
+// FuzzOptions configures a fuzz run. Zero values take the defaults.
+type FuzzOptions struct {
+	ContractID    *uuid.UUID // supplies the request schema, by default the request's contract
+	EnvironmentID *uuid.UUID
+	CollectionID  *uuid.UUID // where reproductions are saved, by default a new collection
+	MaxCases      int        // defaults to 200
+	DelayMs       int
+}
+
+// FuzzReport is stored on a finished fuzz run.
+type FuzzReport struct {
+	Cases      []FuzzCaseResult `json:"cases"`
+	Findings   []FuzzFinding    `json:"findings"`
+	DurationMs int64            `json:"duration_ms"`
+}
+
+type FuzzCaseResult struct {
+	FuzzCase
+	StatusCode     int    `json:"status_code"`
+	ResponseTimeMs int64  `json:"response_time_ms"`
+	Error          string `json:"error,omitempty"`
+	Finding        int    `json:"finding,omitempty"` // 1-based index into the report's findings
+}
+
+// FuzzFinding is a problem the run found, with the first case that found
+// it. Cases with the same mutation of the same field that find the same
+// kind of problem with the same status count as occurrences of one finding.
+type FuzzFinding struct {
+	Kind        string            `json:"kind"`
+	Category    string            `json:"category"`
+	Mutation    string            `json:"mutation"`
+	Pointer     string            `json:"pointer,omitempty"`
+	StatusCode  int               `json:"status_code,omitempty"`
+	Error       string            `json:"error,omitempty"`
+	Violations  []ValidationError `json:"violations,omitempty"`
+	Occurrences int               `json:"occurrences"`
+	Body        string            `json:"body"`                 // minimized payload that reproduces the finding
+	RequestID   *uuid.UUID        `json:"request_id,omitempty"` // the saved reproduction
+}
+
+func (f *FuzzFinding) signature() string {
+	return fmt.Sprintf("%s %d %s %s", f.Kind, f.StatusCode, f.Mutation, f.Pointer)
+}
+
+// FuzzService sends payloads generated from a contract's request schema to
+// a request's endpoint, reports what goes wrong and saves reproductions.
+type FuzzService struct {
+	db               *gorm.DB
+	workspaceService *WorkspaceService
+	requests         *RequestService
+	collections      *CollectionService
+	contracts        *ContractService
+	generator        *TestDataGenerator
+	validator        *SchemaValidator
+}
+
+func NewFuzzService(db *gorm.DB, requests *RequestService) *FuzzService {
+	return &FuzzService{
+		db:               db,
+		workspaceService: NewWorkspaceService(db),
+		requests:         requests,
+		collections:      NewCollectionService(db),
+		contracts:        NewContractService(db),
+		generator:        NewTestDataGenerator(),
+		validator:        NewSchemaValidator(),
+	}
+}
+
+// Start generates the cases and fuzzes the request in the background. Poll
+// Get for progress and the report.
+func (s *FuzzService) Start(workspaceID, requestID, userID uuid.UUID, options FuzzOptions) (*models.FuzzRun, error) {
+	if options.MaxCases == 0 {
+		options.MaxCases = defaultFuzzCases
+	}
+	if options.MaxCases < 0 || options.MaxCases > maxFuzzCases {
+		return nil, fmt.Errorf("max_cases must be between 1 and %d", maxFuzzCases)
+	}
+	if options.DelayMs < 0 || options.DelayMs > maxRunDelayMs {
+		return nil, fmt.Errorf("delay_ms must be between 0 and %d", maxRunDelayMs)
+	}
+
+	request, err := s.requests.GetByID(requestID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if request.Collection.WorkspaceID != workspaceID {
+		return nil, errors.New("request not found")
+	}
+	if request.Kind != "" && request.Kind != RequestKindHTTP {
+		return nil, errors.New("only HTTP requests can be fuzzed")
+	}
+
+	vars := folderVariables(request)
+	if options.EnvironmentID != nil {
+		values, err := s.requests.environmentVariables(workspaceID, *options.EnvironmentID)
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range values {
+			vars[key] = value
+		}
+	}
+	resolved := resolveRequestVariables(request, vars)
+
+	contract, err := s.contract(workspaceID, userID, resolved, options.ContractID)
+	if err != nil {
+		return nil, err
+	}
+	if options.CollectionID != nil {
+		var collection models.Collection
+		if err := s.db.Where("id = ? AND workspace_id = ?", *options.CollectionID, workspaceID).First(&collection).Error; err != nil {
+			return nil, errors.New("collection not found")
+		}
+	}
+	cases, err := fuzzCases(s.generator, s.validator, contract.RequestSchema, options.MaxCases)
+	if err != nil {
+		return nil, err
+	}
+
+	run := &models.FuzzRun{
+		WorkspaceID:   workspaceID,
+		RequestID:     request.ID,
+		ContractID:    contract.ID,
+		EnvironmentID: options.EnvironmentID,
+		CollectionID:  options.CollectionID,
+		MaxCases:      options.MaxCases,
+		DelayMs:       options.DelayMs,
+		Status:        RunStatusPending,
+		CreatedBy:     userID,
+	}
+	if err := s.db.Create(run).Error; err != nil {
+		return nil, err
+	}
+
+	go s.execute(run, resolved, cases, userID)
+
+	return run, nil
+}
+
+// contract picks the contract whose request schema is fuzzed: the one asked
+// for, or else one of the request's own before one of its method and path.
+func (s *FuzzService) contract(workspaceID, userID uuid.UUID, request *models.Request, contractID *uuid.UUID) (*models.Contract, error) {
+	if contractID != nil {
+		contract, err := s.contracts.GetByID(workspaceID, *contractID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if contract.RequestSchema == "" {
+			return nil, errors.New("contract has no request schema")
+		}
+		return contract, nil
+	}
+
+	method := strings.ToUpper(request.Method)
+	candidates, err := s.contracts.candidates(workspaceID, &request.ID, method)
+	if err != nil {
+		return nil, err
+	}
+	urlPath := contractURLPath(request.URL)
+	var matched *models.Contract
+	for i := range candidates {
+		contract := &candidates[i]
+		switch {
+		case contract.RequestSchema == "":
+		case contract.RequestID != nil:
+			return contract, nil
+		case matched == nil && pathTemplateMatches(contract.PathTemplate, urlPath):
+			matched = contract
+		}
+	}
+	if matched == nil {
+		return nil, errors.New("no contract with a request schema applies to this request")
+	}
+	return matched, nil
+}
+
+// execute sends a run's cases and stores the report.
+func (s *FuzzService) execute(run *models.FuzzRun, request *models.Request, cases []FuzzCase, userID uuid.UUID) {
+	startedAt := time.Now()
+	s.db.Model(run).Updates(map[string]interface{}{
+		"status":     RunStatusRunning,
+		"started_at": startedAt,
+	})
+
+	fuzzed := *request
+	fuzzed.BodyMode = BodyModeJSON
+	fuzzed.Body = cases[0].Body
+	report := &FuzzReport{Cases: []FuzzCaseResult{}, Findings: []FuzzFinding{}}
+	prepared, err := s.requests.prepare(&fuzzed, "", nil)
+	if err == nil {
+		// Without contracts, responses are not checked
+		contracts, _ := s.contracts.candidates(run.WorkspaceID, &request.ID, strings.ToUpper(request.Method))
+		f := &fuzzer{
+			send: fuzzSender(s.requests, prepared),
+			check: func(execution *models.Execution) []ValidationError {
+				return s.responseViolations(contracts, &fuzzed, execution)
+			},
+			delay: time.Duration(run.DelayMs) * time.Millisecond,
+			progress: func(report *FuzzReport) {
+				s.db.Model(&models.FuzzRun{}).Where("id = ?", run.ID).Updates(fuzzCounts(report))
+			},
+		}
+		report, err = f.run(cases)
+	}
+
+	if len(report.Findings) > 0 {
+		if saveErr := s.saveReproductions(run, request, report, userID); saveErr != nil && err == nil {
+			err = fmt.Errorf("reproductions were not saved: %w", saveErr)
+		}
+	}
+
+	completedAt := time.Now()
+	report.DurationMs = completedAt.Sub(startedAt).Milliseconds()
+	status := RunStatusPassed
+	if len(report.Findings) > 0 || err != nil {
+		status = RunStatusFailed
+	}
+	updates := fuzzCounts(report)
+	updates["status"] = status
+	updates["collection_id"] = run.CollectionID
+	updates["completed_at"] = completedAt
+	if err != nil {
+		updates["error"] = err.Error()
+	}
+	reportJSON, _ := json.Marshal(report)
+	updates["report"] = string(reportJSON)
+	s.db.Model(run).Updates(updates)
+}
+
+// fuzzSender sends a prepared request with other bodies, without saving an
+// execution. An empty body sends none.
+func fuzzSender(requests *RequestService, prepared *preparedRequest) func(body string) *models.Execution {
+	return func(body string) *models.Execution {
+		httpReq := prepared.httpReq.Clone(prepared.httpReq.Context())
+		var payload []byte
+		if body != "" {
+			payload = []byte(body)
+			httpReq.GetBody = func() (io.ReadCloser, error) {
+				return io.NopCloser(bytes.NewReader(payload)), nil
+			}
+		} else {
+			httpReq.Body, httpReq.GetBody = http.NoBody, nil
+		}
+		httpReq.ContentLength = int64(len(payload))
+
+		var execution models.Execution
+		requests.send(prepared.client, httpReq, payload, prepared.authConfig, &execution)
+		return &execution
+	}
+}
+
+// responseViolations checks a response against the contracts that apply to
+// it. Violations on the request side are what fuzzing sends on purpose, so
+// only the response's are kept.
+func (s *FuzzService) responseViolations(contracts []models.Contract, request *models.Request, execution *models.Execution) []ValidationError {
+	method := strings.ToUpper(request.Method)
+	exchange := executionExchange(request, execution, request.URL)
+	var violations []ValidationError
+	for _, contract := range applicableContracts(contracts, &request.ID, method, contractURLPath(request.URL), execution.StatusCode) {
+		errs, err := s.contracts.check(contract, exchange)
+		if err != nil {
+			continue
+		}
+		for _, e := range errs {
+			if e.In == "response" || e.In == "response_header" {
+				violations = append(violations, e)
+			}
+		}
+	}
+	return violations
+}
+
+// fuzzCounts is a report's progress as FuzzRun columns.
+func fuzzCounts(report *FuzzReport) map[string]interface{} {
+	counts := map[string]int{}
+	for _, result := range report.Cases {
+		if result.Finding > 0 {
+			counts[report.Findings[result.Finding-1].Kind]++
+		}
+	}
+	return map[string]interface{}{
+		"total_cases":       len(report.Cases),
+		"server_errors":     counts[FuzzFindingServerError],
+		"schema_violations": counts[FuzzFindingSchemaViolation],
+		"crashes":           counts[FuzzFindingCrash],
+	}
+}
+
+// fuzzer sends cases to one endpoint. send makes a request with a body, and
+// check lists the contract violations of its response.
+type fuzzer struct {
+	send     func(body string) *models.Execution
+	check    func(execution *models.Execution) []ValidationError
+	delay    time.Duration
+	progress func(report *FuzzReport)
+}
+
+// run sends the cases in order and collects findings, minimizing each the
+// first time it is found. The first case is a valid payload, so a run that
+// cannot reach the endpoint ends there; a run ends early too once the
+// server stops answering.
+func (f *fuzzer) run(cases []FuzzCase) (*FuzzReport, error) {
+	report := &FuzzReport{Cases: []FuzzCaseResult{}, Findings: []FuzzFinding{}}
+	found := map[string]int{}
+	unanswered := 0
+	for i, fuzzCase := range cases {
+		if i > 0 {
+			f.pause()
+		}
+		execution := f.send(fuzzCase.Body)
+		if execution.StatusCode == 0 && i == 0 {
+			return report, fmt.Errorf("the endpoint could not be reached: %s", execution.ErrorMessage)
+		}
+
+		result := FuzzCaseResult{
+			FuzzCase:       fuzzCase,
+			StatusCode:     execution.StatusCode,
+			ResponseTimeMs: execution.ResponseTimeMs,
+			Error:          execution.ErrorMessage,
+		}
+		if finding := f.classify(fuzzCase, execution); finding != nil {
+			if index, ok := found[finding.signature()]; ok {
+				report.Findings[index].Occurrences++
+				result.Finding = index + 1
+			} else {
+				finding.Body = f.minimize(fuzzCase, finding)
+				report.Findings = append(report.Findings, *finding)
+				found[finding.signature()] = len(report.Findings) - 1
+				result.Finding = len(report.Findings)
+			}
+		}
+		report.Cases = append(report.Cases, result)
+		if f.progress != nil {
+			f.progress(report)
+		}
+
+		if execution.StatusCode == 0 {
+			unanswered++
+		} else {
+			unanswered = 0
+		}
+		if unanswered >= maxFuzzConsecutiveCrashes {
+			return report, fmt.Errorf("the server stopped responding after %d cases", len(report.Cases))
+		}
+	}
+	return report, nil
+}
+
+func (f *fuzzer) pause() {
+	if f.delay > 0 {
+		time.Sleep(f.delay)
+	}
+}
+
+// classify returns what a case found, if anything: a crash when the request
+// got no response, a server error for a 5xx status, and otherwise a schema
+// violation when the response breaks its contract.
+func (f *fuzzer) classify(fuzzCase FuzzCase, execution *models.Execution) *FuzzFinding {
+	finding := &FuzzFinding{
+		Category:    fuzzCase.Category,
+		Mutation:    fuzzCase.Mutation,
+		Pointer:     fuzzCase.Pointer,
+		StatusCode:  execution.StatusCode,
+		Occurrences: 1,
+		Body:        fuzzCase.Body,
+	}
+	switch {
+	case execution.StatusCode == 0:
+		finding.Kind = FuzzFindingCrash
+		finding.Error = execution.ErrorMessage
+	case execution.StatusCode >= 500:
+		finding.Kind = FuzzFindingServerError
+	default:
+		violations := f.check(execution)
+		if len(violations) == 0 {
+			return nil
+		}
+		finding.Kind = FuzzFindingSchemaViolation
+		finding.Violations = violations
+	}
+	return finding
+}
+
+// minimize shrinks the payload of a new finding while it reproduces. Crashes
+// are kept as they are: with the server possibly down, every smaller payload
+// would seem to reproduce them.
+func (f *fuzzer) minimize(fuzzCase FuzzCase, finding *FuzzFinding) string {
+	if finding.Kind == FuzzFindingCrash {
+		return fuzzCase.Body
+	}
+	return minimizeFuzzBody(fuzzCase.Body, fuzzCase.tokens, maxFuzzMinimizeAttempts, func(body string) bool {
+		f.pause()
+		reproduced := f.classify(fuzzCase, f.send(body))
+		return reproduced != nil && reproduced.signature() == finding.signature()
+	})
+}
+
+// saveReproductions saves a request per finding that sends its minimized
+// payload, in the run's collection or a new one.
+func (s *FuzzService) saveReproductions(run *models.FuzzRun, request *models.Request, report *FuzzReport, userID uuid.UUID) error {
+	// Reproductions carry the auth they were sent with, wherever they are saved
+	auth := ""
+	if config, err := resolveAuthConfig(request); err == nil && config != nil {
+		encoded, _ := json.Marshal(config)
+		auth = string(encoded)
+	}
+
+	if run.CollectionID == nil {
+		collection, err := s.collections.Create(run.WorkspaceID, "Fuzz findings: "+request.Name,
+			fmt.Sprintf("Reproductions of the findings of fuzz run %s", run.ID), "", request.Collection.Settings, userID)
+		if err != nil {
+			return err
+		}
+		run.CollectionID = &collection.ID
+	}
+	for i := range report.Findings {
+		saved, err := s.requests.Create(*run.CollectionID, fuzzReproduction(request, &report.Findings[i], auth), userID)
+		if err != nil {
+			return err
+		}
+		report.Findings[i].RequestID = &saved.ID
+	}
+	return nil
+}
+
+// fuzzReproduction is a request that sends a finding's payload. Payloads
+// that are not JSON are kept as text, still sent as application/json.
+func fuzzReproduction(request *models.Request, finding *FuzzFinding, auth string) *models.Request {
+	name := finding.Kind + ": " + finding.Mutation
+	if finding.Pointer != "" {
+		name += " at " + finding.Pointer
+	}
+	description := fmt.Sprintf("Found by %s payloads, %d times.", finding.Category, finding.Occurrences)
+	switch {
+	case finding.StatusCode == 0:
+		description += " The request got no response: " + finding.Error
+	case finding.Kind == FuzzFindingServerError:
+		description += fmt.Sprintf(" The server answered %d.", finding.StatusCode)
+	case len(finding.Violations) > 0:
+		description += fmt.Sprintf(" The %d response broke its contract: %s", finding.StatusCode, finding.Violations[0].Description)
+	}
+
+	reproduction := &models.Request{
+		Name:        name,
+		Method:      request.Method,
+		URL:         request.URL,
+		Headers:     request.Headers,
+		QueryParams: request.QueryParams,
+		Auth:        auth,
+		Settings:    request.Settings,
+		Description: description,
+	}
+	switch {
+	case finding.Body == "":
+		reproduction.BodyMode = BodyModeNone
+	case json.Valid([]byte(finding.Body)):
+		reproduction.BodyMode = BodyModeJSON
+		reproduction.Body = finding.Body
+	default:
+		headers := requestHeaders(request)
+		if !hasHeader(headers, "Content-Type") {
+			headers["Content-Type"] = "application/json"
+		}
+		headersJSON, _ := json.Marshal(headers)
+		reproduction.Headers = string(headersJSON)
+		quoted, _ := json.Marshal(finding.Body)
+		reproduction.BodyMode = BodyModeText
+		reproduction.Body = string(quoted)
+	}
+	return reproduction
+}
+
+func hasHeader(headers map[string]string, name string) bool {
+	for key := range headers {
+		if strings.EqualFold(key, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *FuzzService) Get(workspaceID, runID, userID uuid.UUID) (*models.FuzzRun, error) {
+	if !s.workspaceService.HasAccess(workspaceID, userID) {
+		return nil, errors.New("access denied")
+	}
+	var run models.FuzzRun
+	if err := s.db.Where("id = ? AND workspace_id = ?", runID, workspaceID).First(&run).Error; err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// List returns a request's fuzz runs, newest first, without their reports.
+func (s *FuzzService) List(workspaceID, requestID, userID uuid.UUID, limit int) ([]models.FuzzRun, error) {
+	if !s.workspaceService.HasAccess(workspaceID, userID) {
+		return nil, errors.New("access denied")
+	}
+	var runs []models.FuzzRun
+	err := s.db.Omit("report").
+		Where("workspace_id = ? AND request_id = ?", workspaceID, requestID).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&runs).Error
+	return runs, err
+}
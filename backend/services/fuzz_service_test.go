@@ -0,0 +1,203 @@
+package services
+
+import (
+	"backend/models"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fuzzTarget is an endpoint that fails on quotes in name, drops the
+// connection on long names and answers an id of the wrong type for
+// negative quantities.
+func fuzzTarget(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var order struct {
+			Name string      `json:"name"`
+			Qty  json.Number `json:"qty"`
+		}
+		body, _ := io.ReadAll(r.Body)
+		if json.Unmarshal(body, &order) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch {
+		case len(order.Name) > 1000:
+			conn, _, err := w.(http.Hijacker).Hijack()
+			require.NoError(t, err)
+			conn.Close()
+		case strings.Contains(order.Name, "'"):
+			w.WriteHeader(http.StatusInternalServerError)
+		case strings.HasPrefix(string(order.Qty), "-"):
+			w.Write([]byte(`{"id": "not a number"}`))
+		default:
+			w.Write([]byte(`{"id": 1}`))
+		}
+	}))
+}
+
+func TestFuzzer_Run(t *testing.T) {
+	server := fuzzTarget(t)
+	defer server.Close()
+
+	request, err := http.NewRequest(http.MethodPost, server.URL+"/orders", nil)
+	require.NoError(t, err)
+	request.Header.Set("Content-Type", "application/json")
+	validator := NewSchemaValidator()
+	f := &fuzzer{
+		send: fuzzSender(&RequestService{}, &preparedRequest{httpReq: request, client: server.Client()}),
+		check: func(execution *models.Execution) []ValidationError {
+			if execution.StatusCode != http.StatusOK {
+				return nil
+			}
+			result, err := validator.ValidateAgainstOpenAPI(execution.ResponseBody, `{"properties": {"id": {"type": "integer"}}}`)
+			require.NoError(t, err)
+			return result.Errors
+		},
+	}
+
+	cases := []FuzzCase{
+		{Category: FuzzCategoryValid, Mutation: "generated", Body: `{"name": "a", "qty": 1, "note": "x"}`},
+		{Category: FuzzCategoryInvalid, Mutation: "malformed_json", Body: `{"name": `},
+		{Category: FuzzCategoryInvalid, Mutation: "injection", Pointer: "/name", Body: `{"name": "' OR '1'='1", "qty": 1, "note": "x"}`, tokens: []string{"name"}},
+		{Category: FuzzCategoryInvalid, Mutation: "injection", Pointer: "/name", Body: `{"name": "1'; --", "qty": 1, "note": "x"}`, tokens: []string{"name"}},
+		{Category: FuzzCategoryBoundary, Mutation: "negative", Pointer: "/qty", Body: `{"name": "a", "qty": -1, "note": "x"}`, tokens: []string{"qty"}},
+		{Category: FuzzCategoryInvalid, Mutation: "huge_string", Pointer: "/name", Body: `{"name": "` + strings.Repeat("A", 2000) + `", "qty": 1}`, tokens: []string{"name"}},
+		{Category: FuzzCategoryValid, Mutation: "generated", Body: `{"name": "b", "qty": 2}`},
+	}
+	var progress int
+	f.progress = func(report *FuzzReport) { progress = len(report.Cases) }
+
+	report, err := f.run(cases)
+	require.NoError(t, err)
+	assert.Equal(t, len(cases), progress)
+	require.Len(t, report.Cases, len(cases))
+	assert.Equal(t, http.StatusBadRequest, report.Cases[1].StatusCode)
+	assert.Zero(t, report.Cases[1].Finding, "rejecting a bad payload is not a finding")
+
+	require.Len(t, report.Findings, 3)
+	serverError, violation, crash := report.Findings[0], report.Findings[1], report.Findings[2]
+
+	assert.Equal(t, FuzzFindingServerError, serverError.Kind)
+	assert.Equal(t, http.StatusInternalServerError, serverError.StatusCode)
+	assert.Equal(t, 2, serverError.Occurrences, "the same mutation failing the same way is one finding")
+	assert.Equal(t, []int{1, 1}, []int{report.Cases[2].Finding, report.Cases[3].Finding})
+	var minimized map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(serverError.Body), &minimized))
+	assert.Equal(t, []string{"name"}, sortedKeys(minimized), "fields the failure does not need are dropped")
+
+	assert.Equal(t, FuzzFindingSchemaViolation, violation.Kind)
+	assert.Equal(t, "/qty", violation.Pointer)
+	require.NotEmpty(t, violation.Violations)
+	assert.Equal(t, "id", violation.Violations[0].Field)
+	assert.JSONEq(t, `{"qty": -1}`, violation.Body)
+
+	assert.Equal(t, FuzzFindingCrash, crash.Kind)
+	assert.NotEmpty(t, crash.Error)
+	assert.Equal(t, cases[5].Body, crash.Body, "crashes are not minimized")
+	assert.Equal(t, http.StatusOK, report.Cases[6].StatusCode)
+}
+
+func TestFuzzer_RunUnreachable(t *testing.T) {
+	var sent int
+	f := &fuzzer{
+		send: func(string) *models.Execution {
+			sent++
+			return &models.Execution{ErrorMessage: "connection refused"}
+		},
+		check: func(*models.Execution) []ValidationError { return nil },
+	}
+	cases := make([]FuzzCase, 10)
+
+	_, err := f.run(cases)
+	assert.EqualError(t, err, "the endpoint could not be reached: connection refused")
+	assert.Equal(t, 1, sent)
+
+	// A server that goes down part way ends the run
+	sent = 0
+	f.send = func(string) *models.Execution {
+		sent++
+		if sent == 1 {
+			return &models.Execution{StatusCode: http.StatusOK}
+		}
+		return &models.Execution{ErrorMessage: "connection reset by peer"}
+	}
+	report, err := f.run(cases)
+	assert.EqualError(t, err, "the server stopped responding after 6 cases")
+	assert.Len(t, report.Cases, 1+maxFuzzConsecutiveCrashes)
+	require.Len(t, report.Findings, 1)
+	assert.Equal(t, maxFuzzConsecutiveCrashes, report.Findings[0].Occurrences)
+	assert.Equal(t, map[string]interface{}{
+		"total_cases": 6, "server_errors": 0, "schema_violations": 0, "crashes": maxFuzzConsecutiveCrashes,
+	}, fuzzCounts(report))
+}
+
+func TestFuzzSender(t *testing.T) {
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = append(received, string(body))
+	}))
+	defer server.Close()
+
+	request, err := http.NewRequest(http.MethodPost, server.URL, bytes.NewReader([]byte(`{"base": true}`)))
+	require.NoError(t, err)
+	send := fuzzSender(&RequestService{}, &preparedRequest{httpReq: request, client: server.Client()})
+
+	for _, body := range []string{`{"a": "longer than the base body"}`, "", `1`} {
+		execution := send(body)
+		assert.Equal(t, http.StatusOK, execution.StatusCode, execution.ErrorMessage)
+	}
+	assert.Equal(t, []string{`{"a": "longer than the base body"}`, "", `1`}, received)
+}
+
+func TestFuzzReproduction(t *testing.T) {
+	request := &models.Request{
+		Method:  "POST",
+		URL:     "http://orders.internal/orders",
+		Headers: `{"X-Tenant": "acme"}`,
+	}
+	auth := `{"type": "bearer", "bearer": {"token": "t"}}`
+
+	reproduction := fuzzReproduction(request, &FuzzFinding{
+		Kind: FuzzFindingServerError, Category: FuzzCategoryInvalid, Mutation: "injection", Pointer: "/name",
+		StatusCode: 500, Occurrences: 3, Body: `{"name": "'"}`,
+	}, auth)
+	assert.Equal(t, "server_error: injection at /name", reproduction.Name)
+	assert.Equal(t, "Found by invalid payloads, 3 times. The server answered 500.", reproduction.Description)
+	assert.Equal(t, BodyModeJSON, reproduction.BodyMode)
+	assert.Equal(t, `{"name": "'"}`, reproduction.Body)
+	assert.Equal(t, auth, reproduction.Auth)
+	assert.Equal(t, request.Headers, reproduction.Headers)
+
+	reproduction = fuzzReproduction(request, &FuzzFinding{
+		Kind: FuzzFindingServerError, Mutation: "malformed_json", StatusCode: 502, Body: `{"name": `,
+	}, "")
+	assert.Equal(t, BodyModeText, reproduction.BodyMode)
+	assert.Equal(t, `{"name": `, unquoteBody(reproduction.Body))
+	assert.JSONEq(t, `{"X-Tenant": "acme", "Content-Type": "application/json"}`, reproduction.Headers)
+
+	reproduction = fuzzReproduction(request, &FuzzFinding{Kind: FuzzFindingServerError, Mutation: "empty_body", StatusCode: 500}, "")
+	assert.Equal(t, BodyModeNone, reproduction.BodyMode)
+}
+
+func TestFuzzService_StartOptions(t *testing.T) {
+	service := &FuzzService{}
+	for _, options := range []FuzzOptions{
+		{MaxCases: -1},
+		{MaxCases: maxFuzzCases + 1},
+		{DelayMs: -1},
+		{DelayMs: maxRunDelayMs + 1},
+	} {
+		_, err := service.Start(uuid.New(), uuid.New(), uuid.New(), options)
+		assert.Error(t, err, options)
+	}
+}
@@ -0,0 +1,155 @@
+package services
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const fuzzSchemaFixture = `{
+  "type": "object",
+  "properties": {
+    "name": {"type": "string", "minLength": 1, "maxLength": 20},
+    "email": {"type": "string", "format": "email"},
+    "qty": {"type": "integer", "minimum": 1, "maximum": 10},
+    "state": {"type": "string", "enum": ["open", "paid"]},
+    "tags": {"type": "array", "items": {"type": "string"}, "maxItems": 3}
+  },
+  "required": ["name", "qty"]
+}`
+
+func TestFuzzCases(t *testing.T) {
+	validator := NewSchemaValidator()
+	cases, err := fuzzCases(NewTestDataGenerator(), validator, fuzzSchemaFixture, 1000)
+	require.NoError(t, err)
+
+	byMutation := map[string][]FuzzCase{}
+	for _, c := range cases {
+		byMutation[c.Mutation+" "+c.Pointer] = append(byMutation[c.Mutation+" "+c.Pointer], c)
+	}
+	assert.Equal(t, FuzzCategoryValid, cases[0].Category)
+	assert.True(t, cases[0].Valid, "generated payloads satisfy the schema")
+
+	for _, mutation := range []string{
+		"malformed_json ", "empty_body ", "wrong_type ",
+		"max_length /name", "below_min_length /name", "above_max_length /name",
+		"huge_string /name", "unicode /name", "injection /name", "missing_required /name",
+		"bad_format /email", "below_minimum /qty", "above_maximum /qty", "fraction /qty",
+		"enum_value /state", "not_in_enum /state", "max_items /tags", "above_max_items /tags", "wrong_type /tags/0",
+	} {
+		assert.NotEmpty(t, byMutation[mutation], mutation)
+	}
+	assert.Len(t, byMutation["injection /name"], len(fuzzInjections))
+	assert.Empty(t, byMutation["missing_required /email"], "optional fields are not reported missing")
+
+	// Boundary values are valid and invalid values are not
+	for _, mutation := range []string{"max_length /name", "enum_value /state"} {
+		assert.True(t, byMutation[mutation][0].Valid, mutation)
+		assert.Equal(t, FuzzCategoryBoundary, byMutation[mutation][0].Category)
+	}
+	for _, mutation := range []string{"above_max_length /name", "below_minimum /qty", "missing_required /qty", "null /qty", "wrong_type "} {
+		assert.False(t, byMutation[mutation][0].Valid, mutation)
+		assert.Equal(t, FuzzCategoryInvalid, byMutation[mutation][0].Category)
+	}
+
+	var payload map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(byMutation["missing_required /qty"][0].Body), &payload))
+	assert.NotContains(t, payload, "qty")
+	assert.Contains(t, payload, "name", "other fields keep their generated values")
+	assert.False(t, json.Valid([]byte(byMutation["malformed_json "][0].Body)))
+
+	limited, err := fuzzCases(NewTestDataGenerator(), validator, fuzzSchemaFixture, 10)
+	require.NoError(t, err)
+	assert.Len(t, limited, 10)
+
+	_, err = fuzzCases(NewTestDataGenerator(), validator, `[`, 10)
+	assert.Error(t, err)
+}
+
+func TestFuzzFieldMutations(t *testing.T) {
+	summary := func(schema string, required bool) map[string]interface{} {
+		field := fuzzField{tokens: []string{"f"}, required: required}
+		require.NoError(t, json.Unmarshal([]byte(schema), &field.schema))
+		values := map[string]interface{}{}
+		for _, mutation := range fuzzFieldMutations(NewTestDataGenerator(), field) {
+			if mutation.remove {
+				values[mutation.name] = "(removed)"
+			} else if _, ok := values[mutation.name]; !ok {
+				values[mutation.name] = mutation.value
+			}
+		}
+		return values
+	}
+
+	values := summary(`{"type": "integer", "minimum": 1, "maximum": 10}`, true)
+	assert.Equal(t, float64(1), values["minimum"])
+	assert.Equal(t, float64(10), values["maximum"])
+	assert.Equal(t, float64(0), values["below_minimum"])
+	assert.Equal(t, float64(11), values["above_maximum"])
+	assert.Equal(t, "12345", values["wrong_type"])
+	assert.Equal(t, "(removed)", values["missing_required"])
+	assert.Contains(t, values, "null")
+	assert.NotContains(t, values, "huge_string")
+
+	values = summary(`{"type": ["number", "null"]}`, false)
+	assert.Equal(t, 0, values["zero"])
+	assert.Equal(t, -1, values["negative"])
+	assert.Contains(t, values, "large_number")
+	assert.NotContains(t, values, "null", "null is allowed")
+	assert.NotContains(t, values, "missing_required")
+
+	values = summary(`{"type": "string", "minLength": 2}`, false)
+	assert.Equal(t, "aa", values["min_length"])
+	assert.Equal(t, "a", values["below_min_length"])
+	assert.Len(t, values["huge_string"], hugeFuzzStringLength)
+	assert.Equal(t, fuzzInjections[0], values["injection"])
+
+	values = summary(`{"type": "array", "items": {"type": "integer"}, "minItems": 2}`, false)
+	assert.Len(t, values["min_items"], 2)
+	assert.Equal(t, map[string]interface{}{"0": "not an array"}, values["wrong_type"])
+}
+
+func TestWithJSONValue(t *testing.T) {
+	doc := decodeSample(t, `{"a": {"b": [1, 2]}, "c": true}`)
+
+	assert.Equal(t, decodeSample(t, `{"a": {"b": [1, "x"]}, "c": true}`), withJSONValue(doc, []string{"a", "b", "1"}, "x", false))
+	assert.Equal(t, decodeSample(t, `{"a": {"b": [2]}, "c": true}`), withJSONValue(doc, []string{"a", "b", "0"}, nil, true))
+	assert.Equal(t, decodeSample(t, `{"a": {"b": [1, 2]}}`), withJSONValue(doc, []string{"c"}, nil, true))
+	assert.Equal(t, doc, withJSONValue(doc, []string{"missing", "x"}, 1, false), "paths that do not exist are left alone")
+	assert.Equal(t, decodeSample(t, `{"a": {"b": [1, 2]}, "c": true}`), doc, "the original is not changed")
+}
+
+func TestMinimizeFuzzBody(t *testing.T) {
+	// The server fails whenever name holds a quote
+	reproduce := func(body string) bool {
+		var payload map[string]interface{}
+		if json.Unmarshal([]byte(body), &payload) != nil {
+			return false
+		}
+		name, _ := payload["name"].(string)
+		return strings.Contains(name, "'")
+	}
+
+	body := `{"name": "` + strings.Repeat("a", 40) + `' OR '1'='1", "email": "a@example.com", "tags": ["x", "y", "z"]}`
+	minimized := minimizeFuzzBody(body, []string{"name"}, 100, reproduce)
+	assert.True(t, reproduce(minimized))
+	var payload map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(minimized), &payload))
+	assert.Equal(t, []string{"name"}, sortedKeys(payload), "unrelated fields are dropped")
+	assert.Less(t, len(payload["name"].(string)), 40, "the string is shortened while it still fails")
+
+	limited := 0
+	minimizeFuzzBody(body, []string{"name"}, 3, func(string) bool { limited++; return false })
+	assert.Equal(t, 3, limited, "attempts bound the requests sent")
+
+	assert.Equal(t, `{"a",`, minimizeFuzzBody(`{"a",`, nil, 10, func(string) bool { return true }), "bodies that are not JSON are kept")
+
+	// The kept field's index survives items being dropped around it
+	kept := minimizeFuzzBody(`{"items": [{"n": 1}, {"n": "bad"}, {"n": 3}]}`, []string{"items", "1", "n"}, 30, func(body string) bool {
+		return strings.Contains(body, `"bad"`) && strings.Contains(body, `"items":[`)
+	})
+	assert.JSONEq(t, `{"items": [{}, {"n": "bad"}]}`, kept)
+}
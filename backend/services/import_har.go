@@ -0,0 +1,442 @@
+package services
+
+import (
+	"backend/integrations"
+	"backend/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// HARServiceName is the service name of the root span of an imported HAR trace.
+const HARServiceName = "har-import"
+
+// maxHARBodyTagBytes bounds the request and response bodies kept as span tags.
+const maxHARBodyTagBytes = 1 << 20
+
+// harStaticResourceTypes are Chrome resource types that load page assets.
+var harStaticResourceTypes = map[string]bool{
+	"stylesheet": true, "script": true, "image": true, "font": true, "media": true, "manifest": true, "texttrack": true,
+}
+
+// harStaticExtensions are URL path extensions of page assets.
+var harStaticExtensions = map[string]bool{
+	".js": true, ".mjs": true, ".css": true, ".map": true,
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".svg": true, ".ico": true, ".webp": true, ".avif": true, ".bmp": true,
+	".woff": true, ".woff2": true, ".ttf": true, ".otf": true, ".eot": true,
+	".mp4": true, ".webm": true, ".mp3": true, ".ogg": true, ".wav": true,
+}
+
+// harSkippedHeaders are set by the browser or the transport and are not
+// part of the request a user would send.
+var harSkippedHeaders = map[string]bool{
+	"host": true, "content-length": true, "connection": true, "accept-encoding": true, "user-agent": true,
+	"origin": true, "referer": true, "pragma": true, "cache-control": true, "priority": true, "dnt": true,
+	"upgrade-insecure-requests": true, "if-none-match": true, "if-modified-since": true, "te": true,
+}
+
+// HARImportOptions configures a HAR import.
+type HARImportOptions struct {
+	Name          string // collection or trace name, defaults to the first page title
+	IncludeAssets bool   // keep scripts, stylesheets, images, fonts and media
+}
+
+// harEntries returns the entries worth importing, dropping static assets
+// unless asked to keep them, CORS preflights and non-HTTP URLs.
+func harEntries(archive *integrations.HAR, options HARImportOptions, warn func(item, format string, args ...interface{})) []integrations.HAREntry {
+	var entries []integrations.HAREntry
+	assets, preflights := 0, 0
+	for _, entry := range archive.Log.Entries {
+		parsed, err := url.Parse(entry.Request.URL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			continue
+		}
+		if entry.Request.Method == "OPTIONS" && entry.Request.Header("Access-Control-Request-Method") != "" {
+			preflights++
+			continue
+		}
+		if !options.IncludeAssets && harStaticAsset(entry, parsed) {
+			assets++
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if assets > 0 {
+		warn("", "%d static asset request(s) were skipped", assets)
+	}
+	if preflights > 0 {
+		warn("", "%d CORS preflight request(s) were skipped", preflights)
+	}
+	return entries
+}
+
+// harStaticAsset reports whether an entry loads a page asset, going by its
+// resource type, path extension or response content type.
+func harStaticAsset(entry integrations.HAREntry, parsed *url.URL) bool {
+	if harStaticResourceTypes[entry.ResourceType] {
+		return true
+	}
+	if harStaticExtensions[strings.ToLower(path.Ext(parsed.Path))] {
+		return true
+	}
+	mediaType, _, _ := mime.ParseMediaType(entry.Response.Content.MimeType)
+	switch {
+	case strings.HasPrefix(mediaType, "image/"), strings.HasPrefix(mediaType, "font/"),
+		strings.HasPrefix(mediaType, "video/"), strings.HasPrefix(mediaType, "audio/"),
+		mediaType == "text/css", strings.HasSuffix(mediaType, "javascript"):
+		return true
+	}
+	return false
+}
+
+// harName returns the import name: the option, the first page title or a default.
+func harName(archive *integrations.HAR, options HARImportOptions) string {
+	if options.Name != "" {
+		return options.Name
+	}
+	for _, page := range archive.Log.Pages {
+		if page.Title != "" {
+			return page.Title
+		}
+	}
+	return "HAR import"
+}
+
+// ImportHAR creates a collection from a HAR archive with one request per
+// method and URL template; requests repeating one already imported, e.g.
+// /orders/41 after /orders/42, are merged into it. With several hosts the
+// requests are grouped in a folder per host. Cookies and Authorization
+// headers are not imported.
+func (s *ImportService) ImportHAR(workspaceID, userID uuid.UUID, data []byte, options HARImportOptions) (*ImportReport, error) {
+	if !s.workspaceService.HasAccess(workspaceID, userID) {
+		return nil, errors.New("access denied")
+	}
+	archive, err := integrations.NewHARImporter().Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ImportReport{Environments: []ImportedEnvironment{}, Warnings: []ImportWarning{}}
+	warn := func(item, format string, args ...interface{}) {
+		report.Warnings = append(report.Warnings, ImportWarning{Item: item, Message: fmt.Sprintf(format, args...)})
+	}
+
+	entries := harEntries(archive, options, warn)
+	var unique []integrations.HAREntry
+	var hosts []string
+	seen := map[string]bool{}
+	seenHosts := map[string]bool{}
+	duplicates := 0
+	for _, entry := range entries {
+		server, template, _ := openAPIExportPath(entry.Request.URL)
+		key := strings.ToUpper(entry.Request.Method) + " " + server + template
+		if seen[key] {
+			duplicates++
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, entry)
+		if !seenHosts[server] {
+			seenHosts[server] = true
+			hosts = append(hosts, server)
+		}
+	}
+	if len(unique) == 0 {
+		return nil, errors.New("HAR archive has no API requests to import")
+	}
+	if duplicates > 0 {
+		warn("", "%d request(s) repeating an imported method and URL template were merged", duplicates)
+	}
+
+	collection, err := s.collectionService.Create(workspaceID, harName(archive, options), "Imported from a HAR archive", "", "", userID)
+	if err != nil {
+		return nil, err
+	}
+	report.Collection = collection
+
+	err = func() error {
+		folders := map[string]*uuid.UUID{}
+		if len(hosts) > 1 {
+			for position, host := range hosts {
+				folder, err := s.folderService.Create(collection.ID, userID, &models.Folder{Name: strings.SplitN(host, "://", 2)[1], Position: position})
+				if err != nil {
+					return fmt.Errorf("creating folder %q: %w", host, err)
+				}
+				folders[host] = &folder.ID
+				report.Folders++
+			}
+		}
+
+		positions := map[string]int{}
+		for _, entry := range unique {
+			server, template, _ := openAPIExportPath(entry.Request.URL)
+			request := HAREntryToRequest(entry, func(message string) { warn(entry.Request.Method+" "+template, "%s", message) })
+			request.Name = strings.ToUpper(entry.Request.Method) + " " + template
+			request.FolderID = folders[server]
+			request.Position = positions[server]
+			positions[server]++
+			if _, err := s.requestService.Create(collection.ID, request, userID); err != nil {
+				return fmt.Errorf("creating request %q: %w", request.Name, err)
+			}
+			report.Requests++
+		}
+		return nil
+	}()
+	if err != nil {
+		s.collectionService.Delete(collection.ID, userID)
+		return nil, err
+	}
+	return report, nil
+}
+
+// HAREntryToRequest converts the request of a HAR entry. Anything that
+// cannot be represented is passed to warn.
+func HAREntryToRequest(entry integrations.HAREntry, warn func(string)) *models.Request {
+	source := entry.Request
+	request := &models.Request{
+		Method:   strings.ToUpper(source.Method),
+		URL:      source.URL,
+		BodyMode: BodyModeNone,
+	}
+
+	// queryString is the decoded form of the URL's query
+	if parsed, err := url.Parse(source.URL); err == nil && parsed.RawQuery != "" {
+		parsed.RawQuery = ""
+		request.URL = parsed.String()
+		params := make([]RequestParam, 0, len(source.QueryString))
+		for _, query := range source.QueryString {
+			params = append(params, RequestParam{Key: query.Name, Value: query.Value})
+		}
+		if len(params) == 0 {
+			for key, values := range parsed.Query() {
+				for _, value := range values {
+					params = append(params, RequestParam{Key: key, Value: value})
+				}
+			}
+		}
+		encoded, _ := json.Marshal(params)
+		request.QueryParams = string(encoded)
+	}
+
+	headers := map[string]string{}
+	credentials := false
+	for _, header := range source.Headers {
+		name := strings.ToLower(header.Name)
+		switch {
+		case strings.HasPrefix(name, ":"), strings.HasPrefix(name, "sec-"), harSkippedHeaders[name]:
+		case name == "cookie" || name == "authorization":
+			credentials = true
+		default:
+			headers[header.Name] = header.Value
+		}
+	}
+	if credentials {
+		warn("cookies and Authorization headers were not imported; set up auth on the collection")
+	}
+
+	if body := source.PostData; body != nil {
+		mediaType, _, _ := mime.ParseMediaType(body.MimeType)
+		quoted := func(text string) string {
+			encoded, _ := json.Marshal(text)
+			return string(encoded)
+		}
+		switch {
+		case mediaType == "application/x-www-form-urlencoded":
+			params := make([]RequestParam, 0, len(body.Params))
+			for _, param := range body.Params {
+				params = append(params, RequestParam{Key: param.Name, Value: param.Value})
+			}
+			if len(params) == 0 {
+				values, _ := url.ParseQuery(body.Text)
+				for key, list := range values {
+					for _, value := range list {
+						params = append(params, RequestParam{Key: key, Value: value})
+					}
+				}
+			}
+			encoded, _ := json.Marshal(params)
+			request.BodyMode, request.Body = BodyModeURLEncoded, string(encoded)
+			delete(headers, headerKey(headers, "Content-Type"))
+		case mediaType == "multipart/form-data":
+			params := make([]RequestParam, 0, len(body.Params))
+			for _, param := range body.Params {
+				field := RequestParam{Key: param.Name, Value: param.Value, ContentType: param.ContentType}
+				if param.FileName != "" {
+					field.Type, field.Value = "file", ""
+					warn(fmt.Sprintf("file field %q needs the file uploaded and attached", param.Name))
+				}
+				params = append(params, field)
+			}
+			encoded, _ := json.Marshal(params)
+			request.BodyMode, request.Body = BodyModeFormData, string(encoded)
+			// The recorded boundary no longer matches
+			delete(headers, headerKey(headers, "Content-Type"))
+		case body.Text == "":
+		case (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")) && json.Valid([]byte(body.Text)):
+			request.BodyMode, request.Body = BodyModeJSON, body.Text
+		case strings.HasSuffix(mediaType, "xml"):
+			request.BodyMode, request.Body = BodyModeXML, quoted(body.Text)
+		default:
+			request.BodyMode, request.Body = BodyModeText, quoted(body.Text)
+		}
+	}
+
+	headersJSON, _ := json.Marshal(headers)
+	request.Headers = string(headersJSON)
+	return request
+}
+
+// ImportHARTrace stores a HAR archive as a trace: a root span covering the
+// session with one child span per entry. Entry spans carry the http.* tags
+// MockService.GenerateFromTrace reads, so the recording can become mocks.
+func (s *ImportService) ImportHARTrace(workspaceID, userID uuid.UUID, data []byte, options HARImportOptions) (*ImportReport, error) {
+	if !s.workspaceService.HasAccess(workspaceID, userID) {
+		return nil, errors.New("access denied")
+	}
+	archive, err := integrations.NewHARImporter().Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ImportReport{Environments: []ImportedEnvironment{}, Warnings: []ImportWarning{}}
+	warn := func(item, format string, args ...interface{}) {
+		report.Warnings = append(report.Warnings, ImportWarning{Item: item, Message: fmt.Sprintf(format, args...)})
+	}
+	entries := harEntries(archive, options, warn)
+	if len(entries) == 0 {
+		return nil, errors.New("HAR archive has no requests to import")
+	}
+
+	trace, spans := harTrace(workspaceID, harName(archive, options), entries, warn)
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(trace).Error; err != nil {
+			return err
+		}
+		return tx.Create(&spans).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.contracts.CheckSpans(workspaceID, spans)
+	s.schemas.ObserveSpans(workspaceID, spans)
+	report.TraceID = &trace.ID
+	report.Spans = len(spans)
+	return report, nil
+}
+
+// harTrace builds the trace and spans for the entries. IDs are assigned
+// here so the spans can reference their root.
+func harTrace(workspaceID uuid.UUID, name string, entries []integrations.HAREntry, warn func(item, format string, args ...interface{})) (*models.Trace, []models.Span) {
+	trace := &models.Trace{ID: uuid.New(), WorkspaceID: workspaceID, ServiceName: HARServiceName, Status: "success"}
+	root := models.Span{
+		ID:            uuid.New(),
+		TraceID:       trace.ID,
+		OperationName: name,
+		ServiceName:   HARServiceName,
+		Logs:          "{}",
+		Status:        "ok",
+	}
+	spans := []models.Span{root}
+
+	for i, entry := range entries {
+		started := entry.StartedDateTime
+		if started.IsZero() {
+			started = time.Now()
+		}
+		duration := entry.Time
+		if duration < 0 {
+			duration = 0
+		}
+		end := started.Add(time.Duration(duration * float64(time.Millisecond)))
+		if i == 0 || started.Before(trace.StartTime) {
+			trace.StartTime = started
+		}
+		if end.After(trace.EndTime) {
+			trace.EndTime = end
+		}
+
+		method := strings.ToUpper(entry.Request.Method)
+		parsed, _ := url.Parse(entry.Request.URL)
+		tags := map[string]interface{}{
+			"span.kind":        "client",
+			"http.method":      method,
+			"http.url":         entry.Request.URL,
+			"http.status_code": entry.Response.Status,
+			"http.target":      parsed.RequestURI(),
+			"net.peer.name":    parsed.Hostname(),
+			"har.entry":        i,
+		}
+		if entry.ServerIPAddress != "" {
+			tags["net.peer.addr"] = entry.ServerIPAddress
+		}
+		if entry.ResourceType != "" {
+			tags["har.resource_type"] = entry.ResourceType
+		}
+		if contentType := entry.Response.Content.MimeType; contentType != "" {
+			tags["http.response.content_type"] = contentType
+		}
+		if body := entry.Request.PostData; body != nil && body.Text != "" {
+			if len(body.Text) <= maxHARBodyTagBytes {
+				tags["http.request.body"] = body.Text
+			} else {
+				warn(method+" "+entry.Request.URL, "request body is larger than %d bytes and was not kept", maxHARBodyTagBytes)
+			}
+		}
+		if body, err := entry.Response.Content.Body(); err != nil {
+			warn(method+" "+entry.Request.URL, "response body could not be decoded: %v", err)
+		} else if len(body) > maxHARBodyTagBytes {
+			warn(method+" "+entry.Request.URL, "response body is larger than %d bytes and was not kept", maxHARBodyTagBytes)
+		} else if len(body) > 0 && utf8.Valid(body) {
+			tags["http.response.body"] = string(body)
+		}
+
+		status := "ok"
+		// 0 is a request the browser blocked or aborted
+		if entry.Response.Status == 0 || entry.Response.Status >= 400 {
+			status = "error"
+			tags["error"] = true
+			trace.Status = "error"
+		}
+
+		tagsJSON, _ := json.Marshal(tags)
+		spans = append(spans, models.Span{
+			ID:            uuid.New(),
+			TraceID:       trace.ID,
+			ParentSpanID:  &root.ID,
+			OperationName: method + " " + parsed.Path,
+			ServiceName:   parsed.Host,
+			StartTime:     started,
+			DurationMs:    duration,
+			Tags:          string(tagsJSON),
+			Logs:          "{}",
+			Status:        status,
+		})
+	}
+
+	spans[0].StartTime = trace.StartTime
+	spans[0].DurationMs = float64(trace.EndTime.Sub(trace.StartTime)) / float64(time.Millisecond)
+	rootTags, _ := json.Marshal(map[string]interface{}{"har.entries": len(entries)})
+	spans[0].Tags = string(rootTags)
+	trace.SpanCount = len(spans)
+	trace.TotalDurationMs = spans[0].DurationMs
+	return trace, spans
+}
+
+// headerKey returns the stored spelling of a header name, ignoring case.
+func headerKey(headers map[string]string, name string) string {
+	for key := range headers {
+		if strings.EqualFold(key, name) {
+			return key
+		}
+	}
+	return name
+}
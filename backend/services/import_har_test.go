@@ -0,0 +1,130 @@
+package services
+
+import (
+	"backend/integrations"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const harFixture = `{
+  "log": {
+    "version": "1.2",
+    "creator": {"name": "WebInspector", "version": "537.36"},
+    "pages": [{"id": "page_1", "title": "Shop checkout", "startedDateTime": "2024-05-01T10:00:00.000Z"}],
+    "entries": [
+      {
+        "startedDateTime": "2024-05-01T10:00:00.000Z", "time": 120, "_resourceType": "fetch",
+        "request": {
+          "method": "GET", "url": "https://api.shop.test/orders/42?expand=items",
+          "headers": [{"name": ":authority", "value": "api.shop.test"}, {"name": "Accept", "value": "application/json"}, {"name": "Cookie", "value": "sid=1"}, {"name": "sec-fetch-mode", "value": "cors"}],
+          "queryString": [{"name": "expand", "value": "items"}]
+        },
+        "response": {"status": 200, "headers": [], "content": {"mimeType": "application/json", "text": "eyJpZCI6NDJ9", "encoding": "base64"}}
+      },
+      {
+        "startedDateTime": "2024-05-01T10:00:01.000Z", "time": 80, "_resourceType": "fetch",
+        "request": {"method": "GET", "url": "https://api.shop.test/orders/43", "headers": []},
+        "response": {"status": 200, "headers": [], "content": {"mimeType": "application/json", "text": "{\"id\":43}"}}
+      },
+      {
+        "startedDateTime": "2024-05-01T10:00:02.000Z", "time": 30,
+        "request": {"method": "OPTIONS", "url": "https://api.shop.test/orders", "headers": [{"name": "Access-Control-Request-Method", "value": "POST"}]},
+        "response": {"status": 204, "headers": [], "content": {}}
+      },
+      {
+        "startedDateTime": "2024-05-01T10:00:02.100Z", "time": 200,
+        "request": {
+          "method": "POST", "url": "https://api.shop.test/orders",
+          "headers": [{"name": "Content-Type", "value": "application/json"}, {"name": "Content-Length", "value": "9"}],
+          "postData": {"mimeType": "application/json", "text": "{\"qty\":2}"}
+        },
+        "response": {"status": 422, "headers": [], "content": {"mimeType": "application/json", "text": "{\"error\":\"stock\"}"}}
+      },
+      {
+        "startedDateTime": "2024-05-01T10:00:03.000Z", "time": 15, "_resourceType": "script",
+        "request": {"method": "GET", "url": "https://cdn.shop.test/app.js", "headers": []},
+        "response": {"status": 200, "headers": [], "content": {"mimeType": "application/javascript"}}
+      },
+      {
+        "startedDateTime": "2024-05-01T10:00:03.500Z", "time": 10,
+        "request": {"method": "GET", "url": "https://cdn.shop.test/logo.png", "headers": []},
+        "response": {"status": 200, "headers": [], "content": {"mimeType": "image/png"}}
+      }
+    ]
+  }
+}`
+
+func TestHAREntries_FiltersAssetsAndPreflights(t *testing.T) {
+	archive, err := integrations.NewHARImporter().Parse([]byte(harFixture))
+	require.NoError(t, err)
+
+	var warnings []string
+	warn := func(item, format string, args ...interface{}) { warnings = append(warnings, format) }
+	entries := harEntries(archive, HARImportOptions{}, warn)
+	require.Len(t, entries, 3)
+	assert.Equal(t, "POST", entries[2].Request.Method)
+	assert.Len(t, warnings, 2)
+
+	withAssets := harEntries(archive, HARImportOptions{IncludeAssets: true}, warn)
+	assert.Len(t, withAssets, 5, "preflights are always skipped")
+
+	_, err = integrations.NewHARImporter().Parse([]byte(`{"log": {"version": "2.0", "entries": []}}`))
+	assert.Error(t, err)
+	_, err = integrations.NewHARImporter().Parse([]byte(`{"info": {}}`))
+	assert.Error(t, err)
+}
+
+func TestHAREntryToRequest(t *testing.T) {
+	archive, err := integrations.NewHARImporter().Parse([]byte(harFixture))
+	require.NoError(t, err)
+
+	var warnings []string
+	get := HAREntryToRequest(archive.Log.Entries[0], func(message string) { warnings = append(warnings, message) })
+	assert.Equal(t, "https://api.shop.test/orders/42", get.URL)
+	assert.Equal(t, `{"Accept":"application/json"}`, get.Headers, "pseudo, sec-* and cookie headers are dropped")
+	assert.Equal(t, `[{"key":"expand","value":"items"}]`, get.QueryParams)
+	assert.Len(t, warnings, 1)
+
+	post := HAREntryToRequest(archive.Log.Entries[3], func(string) {})
+	assert.Equal(t, BodyModeJSON, post.BodyMode)
+	assert.Equal(t, `{"qty":2}`, post.Body)
+	assert.Equal(t, `{"Content-Type":"application/json"}`, post.Headers)
+
+	_, first, _ := openAPIExportPath(archive.Log.Entries[0].Request.URL)
+	_, second, _ := openAPIExportPath(archive.Log.Entries[1].Request.URL)
+	assert.Equal(t, first, second, "requests differing only by ID share a template")
+}
+
+func TestHARTrace(t *testing.T) {
+	archive, err := integrations.NewHARImporter().Parse([]byte(harFixture))
+	require.NoError(t, err)
+	entries := harEntries(archive, HARImportOptions{}, func(string, string, ...interface{}) {})
+
+	workspaceID := uuid.New()
+	trace, spans := harTrace(workspaceID, harName(archive, HARImportOptions{}), entries, func(string, string, ...interface{}) {})
+	require.Len(t, spans, 4)
+	assert.Equal(t, workspaceID, trace.WorkspaceID)
+	assert.Equal(t, 4, trace.SpanCount)
+	assert.Equal(t, "error", trace.Status, "a 422 response fails the trace")
+	assert.Equal(t, "Shop checkout", spans[0].OperationName)
+	assert.InDelta(t, 2300, spans[0].DurationMs, 0.001, "the root span covers the session")
+
+	for _, span := range spans[1:] {
+		assert.Equal(t, spans[0].ID, *span.ParentSpanID)
+		assert.Equal(t, trace.ID, span.TraceID)
+	}
+
+	var tags map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(spans[1].Tags), &tags))
+	assert.Equal(t, "GET", tags["http.method"])
+	assert.Equal(t, "https://api.shop.test/orders/42?expand=items", tags["http.url"])
+	assert.Equal(t, 200.0, tags["http.status_code"])
+	assert.Equal(t, `{"id":42}`, tags["http.response.body"], "base64 bodies are decoded")
+	assert.Equal(t, "GET /orders/42", spans[1].OperationName)
+	assert.Equal(t, "api.shop.test", spans[1].ServiceName)
+	assert.Equal(t, "error", spans[3].Status)
+}
@@ -0,0 +1,699 @@
+package services
+
+import (
+	"backend/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// openAPIPathParam matches {name} path template parameters and server variables.
+var openAPIPathParam = regexp.MustCompile(`\{([^{}/]+)\}`)
+
+// ImportOpenAPI creates a collection from an OpenAPI 3.0 or 3.1 document: a
+// folder per tag, a request per operation with an example body, an
+// environment per server and a contract per documented JSON response. The
+// document itself is stored as an APISpec that the contracts point back to.
+// If creating the collection's contents fails, the partial import is deleted.
+func (s *ImportService) ImportOpenAPI(workspaceID, userID uuid.UUID, data []byte) (*ImportReport, error) {
+	if !s.workspaceService.HasAccess(workspaceID, userID) {
+		return nil, errors.New("access denied")
+	}
+
+	doc, err := ParseOpenAPI(data)
+	if err != nil {
+		return nil, err
+	}
+	operations, err := doc.Operations()
+	if err != nil {
+		return nil, err
+	}
+	if len(operations) == 0 {
+		return nil, errors.New("OpenAPI document has no operations")
+	}
+
+	report := &ImportReport{Environments: []ImportedEnvironment{}, Warnings: []ImportWarning{}}
+	warn := func(item, format string, args ...interface{}) {
+		report.Warnings = append(report.Warnings, ImportWarning{Item: item, Message: fmt.Sprintf(format, args...)})
+	}
+
+	name := doc.Info("title")
+	if name == "" {
+		name = "OpenAPI import"
+	}
+	var variables []importVariable
+	auth := ""
+	if security, ok := doc.Root["security"]; ok {
+		auth = openAPIAuth(doc, security, &variables, func(message string) { warn("", "%s", message) })
+	}
+
+	collection, err := s.collectionService.Create(workspaceID, name, doc.Info("description"), auth, "", userID)
+	if err != nil {
+		return nil, err
+	}
+	report.Collection = collection
+
+	content, _ := json.Marshal(doc.Root)
+	spec := &models.APISpec{
+		WorkspaceID:    workspaceID,
+		CollectionID:   &collection.ID,
+		Name:           name,
+		Version:        doc.Info("version"),
+		Format:         "openapi",
+		FormatVersion:  doc.Version,
+		OperationCount: len(operations),
+		Content:        string(content),
+		CreatedBy:      userID,
+	}
+	if err := s.db.Create(spec).Error; err != nil {
+		s.collectionService.Delete(collection.ID, userID)
+		return nil, err
+	}
+	report.SpecID = &spec.ID
+
+	if err := s.importOpenAPIOperations(doc, operations, collection, spec, userID, &variables, report, warn); err != nil {
+		s.db.Where("spec_id = ?", spec.ID).Delete(&models.Contract{})
+		s.db.Delete(spec)
+		s.collectionService.Delete(collection.ID, userID)
+		return nil, err
+	}
+
+	servers, _ := doc.Root["servers"].([]interface{})
+	if len(servers) == 0 {
+		warn("", "document has no servers; baseUrl defaults to http://localhost")
+		servers = []interface{}{map[string]interface{}{"url": "http://localhost"}}
+	}
+	for _, entry := range servers {
+		server, _ := entry.(map[string]interface{})
+		serverURL, _ := server["url"].(string)
+		if !strings.Contains(serverURL, "://") {
+			warn("", "server URL %q is relative; set baseUrl to an absolute URL before sending requests", serverURL)
+		}
+
+		envName := name
+		if len(servers) > 1 {
+			label, _ := server["description"].(string)
+			if label == "" {
+				label = serverURL
+			}
+			envName = name + " - " + label
+		}
+
+		values := []importVariable{{key: "baseUrl", value: strings.TrimSuffix(openAPIPathParam.ReplaceAllString(serverURL, "{{$1}}"), "/")}}
+		serverVariables, _ := server["variables"].(map[string]interface{})
+		for _, key := range sortedKeys(serverVariables) {
+			variable, _ := serverVariables[key].(map[string]interface{})
+			values = append(values, importVariable{key: key, value: exampleString(variable["default"])})
+		}
+		values = append(values, variables...)
+
+		imported, err := s.importEnvironment(workspaceID, userID, envName, "Imported from OpenAPI", values)
+		if err != nil {
+			return nil, err
+		}
+		report.Environments = append(report.Environments, *imported)
+	}
+
+	return report, nil
+}
+
+// importOpenAPIOperations creates a folder per tag, in the document's tag
+// order, and the operations' requests and contracts. Untagged operations go
+// to the collection root. Path parameter values and auth placeholders are
+// added to variables for the environments.
+func (s *ImportService) importOpenAPIOperations(doc *OpenAPIDocument, operations []OpenAPIOperation, collection *models.Collection, spec *models.APISpec, userID uuid.UUID, variables *[]importVariable, report *ImportReport, warn func(item, format string, args ...interface{})) error {
+	tagDescriptions := map[string]string{}
+	var tags []string
+	declared, _ := doc.Root["tags"].([]interface{})
+	for _, entry := range declared {
+		tag, _ := entry.(map[string]interface{})
+		if tagName, _ := tag["name"].(string); tagName != "" {
+			tagDescriptions[tagName], _ = tag["description"].(string)
+			tags = append(tags, tagName)
+		}
+	}
+	used := map[string]bool{}
+	for _, operation := range operations {
+		if tag := operationTag(operation); tag != "" && !used[tag] {
+			used[tag] = true
+			if _, ok := tagDescriptions[tag]; !ok {
+				tags = append(tags, tag)
+			}
+		}
+	}
+
+	folders := map[string]*uuid.UUID{}
+	positions := map[string]int{}
+	for _, tag := range tags {
+		if !used[tag] {
+			continue
+		}
+		folder, err := s.folderService.Create(collection.ID, userID, &models.Folder{
+			Name:        tag,
+			Description: tagDescriptions[tag],
+			Position:    positions[""],
+		})
+		if err != nil {
+			return fmt.Errorf("creating folder %q: %w", tag, err)
+		}
+		folders[tag] = &folder.ID
+		positions[""]++
+		report.Folders++
+	}
+
+	for _, operation := range operations {
+		tag := operationTag(operation)
+		request := openAPIRequest(doc, operation, variables, func(message string) {
+			warn(operation.Method+" "+operation.Path, "%s", message)
+		})
+		path := request.Name
+		if tag != "" {
+			path = tag + "/" + request.Name
+		}
+		request.FolderID = folders[tag]
+		request.Position = positions[tag]
+		positions[tag]++
+		if _, err := s.requestService.Create(collection.ID, request, userID); err != nil {
+			return fmt.Errorf("creating request %q: %w", path, err)
+		}
+		report.Requests++
+
+		contracts, err := openAPIContracts(doc, operation)
+		if err != nil {
+			warn(path, "contracts were not imported: %v", err)
+			continue
+		}
+		for _, contract := range contracts {
+			contract.WorkspaceID = collection.WorkspaceID
+			contract.RequestID = &request.ID
+			contract.SpecID = &spec.ID
+			contract.Name = request.Name + " " + contract.StatusCode
+			if err := s.db.Create(&contract).Error; err != nil {
+				return fmt.Errorf("creating contract for %q: %w", path, err)
+			}
+			report.Contracts++
+		}
+	}
+	return nil
+}
+
+// operationTag is the first tag of an operation, which names its folder.
+func operationTag(operation OpenAPIOperation) string {
+	tags, _ := operation.Operation["tags"].([]interface{})
+	if len(tags) == 0 {
+		return ""
+	}
+	tag, _ := tags[0].(string)
+	return tag
+}
+
+// openAPIRequest converts an operation to a request against {{baseUrl}}.
+// Required query params are enabled and optional ones disabled; only
+// required headers are added. Anything that cannot be represented is passed
+// to warn.
+func openAPIRequest(doc *OpenAPIDocument, operation OpenAPIOperation, variables *[]importVariable, warn func(string)) *models.Request {
+	name, _ := operation.Operation["summary"].(string)
+	if name == "" {
+		name, _ = operation.Operation["operationId"].(string)
+	}
+	if name == "" {
+		name = operation.Method + " " + operation.Path
+	}
+	description, _ := operation.Operation["description"].(string)
+
+	request := &models.Request{
+		Name:        name,
+		Method:      operation.Method,
+		URL:         "{{baseUrl}}" + openAPIPathParam.ReplaceAllString(operation.Path, "{{$1}}"),
+		Description: description,
+		BodyMode:    BodyModeNone,
+	}
+
+	headers := map[string]string{}
+	var query []RequestParam
+	for _, parameter := range operation.Parameters {
+		paramName, _ := parameter["name"].(string)
+		required, _ := parameter["required"].(bool)
+		value := exampleString(openAPIParameterExample(doc, parameter))
+		switch parameter["in"] {
+		case "path":
+			*variables = append(*variables, importVariable{key: paramName, value: value})
+		case "query":
+			param := RequestParam{Key: paramName, Value: value}
+			if !required {
+				disabled := false
+				param.Enabled = &disabled
+			}
+			query = append(query, param)
+		case "header":
+			if required {
+				headers[paramName] = value
+			}
+		case "cookie":
+			warn(fmt.Sprintf("cookie parameter %q was not imported", paramName))
+		}
+	}
+	if len(query) > 0 {
+		encoded, _ := json.Marshal(query)
+		request.QueryParams = string(encoded)
+	}
+
+	if requestBody, err := doc.Resolve(operation.Operation["requestBody"]); err != nil {
+		warn(fmt.Sprintf("request body was not imported: %v", err))
+	} else if requestBody != nil {
+		openAPIBody(doc, request, requestBody, headers, warn)
+	}
+
+	responses, _ := operation.Operation["responses"].(map[string]interface{})
+	for _, status := range sortedKeys(responses) {
+		if !strings.HasPrefix(status, "2") {
+			continue
+		}
+		response, _ := doc.Resolve(responses[status])
+		content, _ := response["content"].(map[string]interface{})
+		if mediaType := jsonMediaType(content); mediaType != "" && headerValue(headers, "Accept") == "" {
+			headers["Accept"] = mediaType
+		}
+	}
+	headersJSON, _ := json.Marshal(headers)
+	request.Headers = string(headersJSON)
+
+	if security, ok := operation.Operation["security"]; ok {
+		request.Auth = openAPIAuth(doc, security, variables, warn)
+	}
+	return request
+}
+
+// openAPIBody sets the request body from the first supported media type,
+// preferring JSON, then forms, then text.
+func openAPIBody(doc *OpenAPIDocument, request *models.Request, requestBody map[string]interface{}, headers map[string]string, warn func(string)) {
+	content, _ := requestBody["content"].(map[string]interface{})
+	if len(content) == 0 {
+		return
+	}
+
+	if mediaType := jsonMediaType(content); mediaType != "" {
+		media, _ := content[mediaType].(map[string]interface{})
+		example, err := openAPIMediaExample(doc, media)
+		if err != nil {
+			warn(fmt.Sprintf("example body was not generated: %v", err))
+		}
+		encoded, _ := json.MarshalIndent(example, "", "  ")
+		request.BodyMode, request.Body = BodyModeJSON, string(encoded)
+		if mediaType != "application/json" {
+			headers["Content-Type"] = mediaType
+		}
+		return
+	}
+
+	for _, mediaType := range []string{"application/x-www-form-urlencoded", "multipart/form-data"} {
+		media, ok := content[mediaType].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		example, err := openAPIMediaExample(doc, media)
+		if err != nil {
+			warn(fmt.Sprintf("example body was not generated: %v", err))
+		}
+		fields, _ := example.(map[string]interface{})
+		schema, _ := doc.InlineSchema(media["schema"])
+		properties, _ := schema["properties"].(map[string]interface{})
+
+		params := make([]RequestParam, 0, len(fields))
+		for _, key := range sortedKeys(fields) {
+			param := RequestParam{Key: key, Value: exampleString(fields[key])}
+			property, _ := properties[key].(map[string]interface{})
+			if format, _ := property["format"].(string); mediaType == "multipart/form-data" && (format == "binary" || format == "base64") {
+				param.Type, param.Value = "file", ""
+				warn(fmt.Sprintf("file field %q needs the file uploaded and attached", key))
+			}
+			params = append(params, param)
+		}
+		encoded, _ := json.Marshal(params)
+		request.Body = string(encoded)
+		if mediaType == "multipart/form-data" {
+			request.BodyMode = BodyModeFormData
+		} else {
+			request.BodyMode = BodyModeURLEncoded
+		}
+		return
+	}
+
+	mediaTypes := sortedKeys(content)
+	for _, mediaType := range mediaTypes {
+		if !strings.HasPrefix(mediaType, "text/") && !strings.HasSuffix(mediaType, "xml") {
+			continue
+		}
+		media, _ := content[mediaType].(map[string]interface{})
+		example, _ := openAPIMediaExample(doc, media)
+		text, _ := example.(string)
+		quoted, _ := json.Marshal(text)
+		request.BodyMode, request.Body = BodyModeText, string(quoted)
+		if strings.HasSuffix(mediaType, "xml") {
+			request.BodyMode = BodyModeXML
+		} else {
+			headers["Content-Type"] = mediaType
+		}
+		return
+	}
+
+	encoded, _ := json.Marshal(BinaryBody{ContentType: mediaTypes[0]})
+	request.BodyMode, request.Body = BodyModeBinary, string(encoded)
+	warn(fmt.Sprintf("%s body needs the file uploaded and attached", mediaTypes[0]))
+}
+
+// jsonMediaType returns application/json or the first +json media type of a
+// content map, or "" when it has neither.
+func jsonMediaType(content map[string]interface{}) string {
+	if _, ok := content["application/json"]; ok {
+		return "application/json"
+	}
+	for _, mediaType := range sortedKeys(content) {
+		if strings.HasSuffix(mediaType, "+json") || strings.HasPrefix(mediaType, "application/json;") {
+			return mediaType
+		}
+	}
+	return ""
+}
+
+// openAPIMediaExample returns the media type's example, its first named
+// example, the schema's example or default, or else data generated from the
+// schema.
+func openAPIMediaExample(doc *OpenAPIDocument, media map[string]interface{}) (interface{}, error) {
+	if example, ok := media["example"]; ok {
+		return example, nil
+	}
+	if examples, ok := media["examples"].(map[string]interface{}); ok && len(examples) > 0 {
+		example, err := doc.Resolve(examples[sortedKeys(examples)[0]])
+		if err != nil {
+			return nil, err
+		}
+		if value, ok := example["value"]; ok {
+			return value, nil
+		}
+	}
+
+	schema, err := doc.InlineSchema(media["schema"])
+	if err != nil || schema == nil {
+		return nil, err
+	}
+	for _, key := range []string{"example", "default"} {
+		if value, ok := schema[key]; ok {
+			return value, nil
+		}
+	}
+	encoded, _ := json.Marshal(exampleSchema(schema, true))
+	generated, err := NewTestDataGenerator().GenerateFromSchema(string(encoded))
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	json.Unmarshal([]byte(generated), &value)
+	return value, nil
+}
+
+// openAPIParameterExample returns a parameter's example, its first named
+// example, or its schema's example, default or first enum value.
+func openAPIParameterExample(doc *OpenAPIDocument, parameter map[string]interface{}) interface{} {
+	if example, ok := parameter["example"]; ok {
+		return example
+	}
+	if examples, ok := parameter["examples"].(map[string]interface{}); ok && len(examples) > 0 {
+		if example, _ := doc.Resolve(examples[sortedKeys(examples)[0]]); example != nil {
+			return example["value"]
+		}
+	}
+	schema, _ := doc.InlineSchema(parameter["schema"])
+	for _, key := range []string{"example", "default"} {
+		if value, ok := schema[key]; ok {
+			return value
+		}
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok && len(enum) > 0 {
+		return enum[0]
+	}
+	return nil
+}
+
+// exampleSchema simplifies an inlined schema for TestDataGenerator, which
+// only understands single types: allOf is merged, the first oneOf or anyOf
+// alternative is used, type arrays keep their first non-null type and
+// examples and defaults become single-value enums so they are reproduced.
+// Request bodies leave out readOnly properties.
+func exampleSchema(schema map[string]interface{}, request bool) map[string]interface{} {
+	if allOf, ok := schema["allOf"].([]interface{}); ok {
+		merged := map[string]interface{}{}
+		properties := map[string]interface{}{}
+		for key, value := range schema {
+			if key != "allOf" {
+				merged[key] = value
+			}
+		}
+		if own, ok := schema["properties"].(map[string]interface{}); ok {
+			for key, value := range own {
+				properties[key] = value
+			}
+		}
+		for _, entry := range allOf {
+			part, _ := entry.(map[string]interface{})
+			part = exampleSchema(part, request)
+			for key, value := range part {
+				if _, ok := merged[key]; !ok && key != "properties" {
+					merged[key] = value
+				}
+			}
+			if partProperties, ok := part["properties"].(map[string]interface{}); ok {
+				for key, value := range partProperties {
+					properties[key] = value
+				}
+			}
+		}
+		if len(properties) > 0 {
+			merged["properties"] = properties
+		}
+		schema = merged
+	}
+	for _, key := range []string{"oneOf", "anyOf"} {
+		if alternatives, ok := schema[key].([]interface{}); ok && len(alternatives) > 0 {
+			if first, ok := alternatives[0].(map[string]interface{}); ok {
+				return exampleSchema(first, request)
+			}
+		}
+	}
+
+	simplified := make(map[string]interface{}, len(schema))
+	for key, value := range schema {
+		simplified[key] = value
+	}
+	if types, ok := schema["type"].([]interface{}); ok {
+		delete(simplified, "type")
+		for _, entry := range types {
+			if typeName, _ := entry.(string); typeName != "" && typeName != "null" {
+				simplified["type"] = typeName
+				break
+			}
+		}
+	}
+	for _, key := range []string{"example", "default"} {
+		if value, ok := schema[key]; ok {
+			simplified["enum"] = []interface{}{value}
+			break
+		}
+	}
+	if properties, ok := schema["properties"].(map[string]interface{}); ok {
+		simplifiedProperties := make(map[string]interface{}, len(properties))
+		for key, value := range properties {
+			property, ok := value.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if readOnly, _ := property["readOnly"].(bool); readOnly && request {
+				continue
+			}
+			simplifiedProperties[key] = exampleSchema(property, request)
+		}
+		simplified["properties"] = simplifiedProperties
+	}
+	if items, ok := schema["items"].(map[string]interface{}); ok {
+		simplified["items"] = exampleSchema(items, request)
+	}
+	return simplified
+}
+
+// openAPIContracts returns a contract for each response with a JSON schema,
+// carrying the operation's JSON request body schema when it has one.
+func openAPIContracts(doc *OpenAPIDocument, operation OpenAPIOperation) ([]models.Contract, error) {
+	requestSchema := ""
+	requestBody, err := doc.Resolve(operation.Operation["requestBody"])
+	if err != nil {
+		return nil, err
+	}
+	if schema, err := openAPIContentSchema(doc, requestBody); err != nil {
+		return nil, err
+	} else if schema != "" {
+		requestSchema = schema
+	}
+
+	var contracts []models.Contract
+	responses, _ := operation.Operation["responses"].(map[string]interface{})
+	for _, status := range sortedKeys(responses) {
+		response, err := doc.Resolve(responses[status])
+		if err != nil {
+			return nil, fmt.Errorf("response %s: %w", status, err)
+		}
+		schema, err := openAPIContentSchema(doc, response)
+		if err != nil {
+			return nil, fmt.Errorf("response %s: %w", status, err)
+		}
+		if schema == "" {
+			continue
+		}
+		contracts = append(contracts, models.Contract{
+			Method:         operation.Method,
+			PathTemplate:   operation.Path,
+			StatusCode:     status,
+			RequestSchema:  requestSchema,
+			ResponseSchema: schema,
+			Source:         "openapi",
+		})
+	}
+	return contracts, nil
+}
+
+// openAPIContentSchema returns the JSON Schema of a request body's or
+// response's JSON content, or "" when it has none.
+func openAPIContentSchema(doc *OpenAPIDocument, object map[string]interface{}) (string, error) {
+	content, _ := object["content"].(map[string]interface{})
+	mediaType := jsonMediaType(content)
+	if mediaType == "" {
+		return "", nil
+	}
+	media, _ := content[mediaType].(map[string]interface{})
+	schema, err := doc.InlineSchema(media["schema"])
+	if err != nil || schema == nil {
+		return "", err
+	}
+	encoded, _ := json.Marshal(OpenAPIToJSONSchema(schema))
+	return string(encoded), nil
+}
+
+// openAPIAuth converts a security requirement list to an auth JSON column,
+// using the first requirement and the first scheme within it. Credentials
+// become {{variables}}, which are added to variables with empty values. An
+// empty list means the operation needs no auth.
+func openAPIAuth(doc *OpenAPIDocument, security interface{}, variables *[]importVariable, warn func(string)) string {
+	requirements, _ := security.([]interface{})
+	if len(requirements) == 0 {
+		encoded, _ := json.Marshal(AuthConfig{Type: AuthTypeNone})
+		return string(encoded)
+	}
+	requirement, _ := requirements[0].(map[string]interface{})
+	if len(requirement) == 0 {
+		encoded, _ := json.Marshal(AuthConfig{Type: AuthTypeNone})
+		return string(encoded)
+	}
+	names := sortedKeys(requirement)
+	if len(names) > 1 {
+		warn(fmt.Sprintf("only the first of the combined security schemes %s was imported", strings.Join(names, ", ")))
+	}
+
+	components, _ := doc.Root["components"].(map[string]interface{})
+	schemes, _ := components["securitySchemes"].(map[string]interface{})
+	scheme, err := doc.Resolve(schemes[names[0]])
+	if err != nil || scheme == nil {
+		warn(fmt.Sprintf("security scheme %q is not defined; auth is inherited instead", names[0]))
+		return ""
+	}
+	placeholder := func(key string) string {
+		*variables = append(*variables, importVariable{key: key})
+		return "{{" + key + "}}"
+	}
+
+	var cfg *AuthConfig
+	schemeType, _ := scheme["type"].(string)
+	switch schemeType {
+	case "http":
+		switch httpScheme, _ := scheme["scheme"].(string); strings.ToLower(httpScheme) {
+		case "basic":
+			cfg = &AuthConfig{Type: AuthTypeBasic, Basic: &BasicAuthConfig{Username: placeholder("username"), Password: placeholder("password")}}
+		case "bearer":
+			cfg = &AuthConfig{Type: AuthTypeBearer, Bearer: &BearerAuthConfig{Token: placeholder("bearerToken")}}
+		case "digest":
+			cfg = &AuthConfig{Type: AuthTypeDigest, Digest: &DigestAuthConfig{Username: placeholder("username"), Password: placeholder("password")}}
+		default:
+			warn(fmt.Sprintf("HTTP auth scheme %q is not supported; auth is inherited instead", httpScheme))
+			return ""
+		}
+	case "apiKey":
+		in, _ := scheme["in"].(string)
+		key, _ := scheme["name"].(string)
+		if in != "header" && in != "query" {
+			warn(fmt.Sprintf("API key in %s is not supported; auth is inherited instead", in))
+			return ""
+		}
+		cfg = &AuthConfig{Type: AuthTypeAPIKey, APIKey: &APIKeyAuthConfig{Key: key, Value: placeholder("apiKey"), In: in}}
+	case "oauth2":
+		flows, _ := scheme["flows"].(map[string]interface{})
+		scopes := func(flow map[string]interface{}) string {
+			available, _ := flow["scopes"].(map[string]interface{})
+			return strings.Join(sortedKeys(available), " ")
+		}
+		if flow, ok := flows["clientCredentials"].(map[string]interface{}); ok {
+			tokenURL, _ := flow["tokenUrl"].(string)
+			cfg = &AuthConfig{Type: AuthTypeOAuth2, OAuth2: &OAuth2AuthConfig{
+				GrantType:    "client_credentials",
+				TokenURL:     tokenURL,
+				ClientID:     placeholder("clientId"),
+				ClientSecret: placeholder("clientSecret"),
+				Scope:        scopes(flow),
+			}}
+		} else if flow, ok := flows["password"].(map[string]interface{}); ok {
+			tokenURL, _ := flow["tokenUrl"].(string)
+			cfg = &AuthConfig{Type: AuthTypeOAuth2, OAuth2: &OAuth2AuthConfig{
+				GrantType:    "password",
+				TokenURL:     tokenURL,
+				ClientID:     placeholder("clientId"),
+				ClientSecret: placeholder("clientSecret"),
+				Username:     placeholder("username"),
+				Password:     placeholder("password"),
+				Scope:        scopes(flow),
+			}}
+		} else {
+			warn("only the OAuth 2.0 client credentials and password flows are supported; auth is inherited instead")
+			return ""
+		}
+	default:
+		warn(fmt.Sprintf("security scheme type %q is not supported; auth is inherited instead", schemeType))
+		return ""
+	}
+
+	encoded, _ := json.Marshal(cfg)
+	return string(encoded)
+}
+
+// exampleString renders an example value as a param or variable value.
+func exampleString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		encoded, _ := json.Marshal(v)
+		return string(encoded)
+	}
+}
+
+func sortedKeys(object map[string]interface{}) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,598 @@
+package services
+
+import (
+	"backend/integrations"
+	"backend/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ImportReport summarises an import. Anything that could not be carried over
+// is listed in Warnings rather than failing the import.
+type ImportReport struct {
+	Collection   *models.Collection    `json:"collection,omitempty"`
+	Folders      int                   `json:"folders"`
+	Requests     int                   `json:"requests"`
+	Contracts    int                   `json:"contracts,omitempty"`
+	SpecID       *uuid.UUID            `json:"spec_id,omitempty"`  // stored copy of an imported API description
+	TraceID      *uuid.UUID            `json:"trace_id,omitempty"` // imports stored as a trace rather than a collection
+	Spans        int                   `json:"spans,omitempty"`
+	Environments []ImportedEnvironment `json:"environments"`
+	Warnings     []ImportWarning       `json:"warnings"`
+}
+
+type ImportedEnvironment struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	Variables int       `json:"variables"`
+	Secrets   int       `json:"secrets,omitempty"`
+}
+
+type ImportWarning struct {
+	Item    string `json:"item,omitempty"` // slash-separated path of the folder or request, empty for the collection itself
+	Message string `json:"message"`
+}
+
+// ImportService creates collections from other tools' export formats.
+type ImportService struct {
+	db                 *gorm.DB
+	workspaceService   *WorkspaceService
+	collectionService  *CollectionService
+	folderService      *FolderService
+	requestService     *RequestService
+	environmentService *EnvironmentService
+	contracts          *ContractService
+	schemas            *SchemaInferenceService
+}
+
+func NewImportService(db *gorm.DB, requestService *RequestService, folderService *FolderService) *ImportService {
+	return &ImportService{
+		db:                 db,
+		workspaceService:   NewWorkspaceService(db),
+		collectionService:  NewCollectionService(db),
+		folderService:      folderService,
+		requestService:     requestService,
+		environmentService: NewEnvironmentService(db),
+		contracts:          NewContractService(db),
+		schemas:            NewSchemaInferenceService(db),
+	}
+}
+
+// ImportPostman creates a collection from a Postman v2.1 export, keeping its
+// folder tree, and an environment for the collection variables and for each
+// environment export. If any part of the import fails, the collection and
+// the environments imported so far are deleted.
+func (s *ImportService) ImportPostman(workspaceID, userID uuid.UUID, collectionData []byte, environmentData [][]byte) (*ImportReport, error) {
+	if !s.workspaceService.HasAccess(workspaceID, userID) {
+		return nil, errors.New("access denied")
+	}
+
+	importer := integrations.NewPostmanImporter()
+	postman, err := importer.Parse(collectionData)
+	if err != nil {
+		return nil, err
+	}
+	environments := make([]*integrations.PostmanEnvironment, 0, len(environmentData))
+	for _, data := range environmentData {
+		environment, err := importer.ParseEnvironment(data)
+		if err != nil {
+			return nil, err
+		}
+		environments = append(environments, environment)
+	}
+
+	report := &ImportReport{Environments: []ImportedEnvironment{}, Warnings: []ImportWarning{}}
+	warn := func(item, format string, args ...interface{}) {
+		report.Warnings = append(report.Warnings, ImportWarning{Item: item, Message: fmt.Sprintf(format, args...)})
+	}
+
+	name := postman.Info.Name
+	if name == "" {
+		name = "Postman import"
+	}
+	auth := postmanAuth(postman.Auth, func(message string) { warn("", "%s", message) })
+	postmanScriptWarnings(postman.Event, func(message string) { warn("", "%s", message) })
+
+	collection, err := s.collectionService.Create(workspaceID, name, string(postman.Info.Description), auth, "", userID)
+	if err != nil {
+		return nil, err
+	}
+	report.Collection = collection
+	fail := func(err error) (*ImportReport, error) {
+		s.collectionService.Delete(collection.ID, userID)
+		for _, environment := range report.Environments {
+			s.environmentService.Delete(workspaceID, environment.ID, userID)
+		}
+		return nil, err
+	}
+
+	if err := s.importPostmanItems(collection.ID, userID, postman.Item, nil, "", report, warn); err != nil {
+		return fail(err)
+	}
+
+	if variables := postmanVariables(postman.Variable); len(variables) > 0 {
+		var values []importVariable
+		for _, variable := range variables {
+			values = append(values, importVariable{key: variable.Key, value: variable.Value, secret: variable.Type == "secret"})
+		}
+		imported, err := s.importEnvironment(workspaceID, userID, name, "Imported from Postman", values)
+		if err != nil {
+			return fail(err)
+		}
+		report.Environments = append(report.Environments, *imported)
+	}
+	for _, environment := range environments {
+		envName := environment.Name
+		if envName == "" {
+			envName = name + " environment"
+		}
+		var values []importVariable
+		for _, variable := range environment.Values {
+			if variable.Disabled {
+				warn("", "disabled variable %q in environment %q was not imported", variable.Key, envName)
+				continue
+			}
+			values = append(values, importVariable{key: variable.Key, value: variable.Value, secret: variable.Type == "secret"})
+		}
+		imported, err := s.importEnvironment(workspaceID, userID, envName, "Imported from Postman", values)
+		if err != nil {
+			return fail(err)
+		}
+		report.Environments = append(report.Environments, *imported)
+	}
+
+	return report, nil
+}
+
+// importPostmanItems creates the folders and requests of one tree level.
+func (s *ImportService) importPostmanItems(collectionID, userID uuid.UUID, items []integrations.PostmanItem, parentID *uuid.UUID, parentPath string, report *ImportReport, warn func(item, format string, args ...interface{})) error {
+	for position, item := range items {
+		path := item.Name
+		if parentPath != "" {
+			path = parentPath + "/" + item.Name
+		}
+		itemWarn := func(message string) { warn(path, "%s", message) }
+		postmanScriptWarnings(item.Event, itemWarn)
+
+		if item.IsFolder() {
+			folder := &models.Folder{
+				ParentID:    parentID,
+				Name:        item.Name,
+				Description: string(item.Description),
+				Position:    position,
+				Auth:        postmanAuth(item.Auth, itemWarn),
+			}
+			if variables := postmanVariables(item.Variable); len(variables) > 0 {
+				values := make(map[string]string, len(variables))
+				for _, variable := range variables {
+					values[variable.Key] = variable.Value
+				}
+				encoded, _ := json.Marshal(values)
+				folder.Variables = string(encoded)
+			}
+			created, err := s.folderService.Create(collectionID, userID, folder)
+			if err != nil {
+				return fmt.Errorf("creating folder %q: %w", path, err)
+			}
+			report.Folders++
+			if err := s.importPostmanItems(collectionID, userID, item.Item, &created.ID, path, report, warn); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if item.Request == nil {
+			itemWarn("item has neither a request nor sub-items and was skipped")
+			continue
+		}
+		request := PostmanRequestToModel(item, itemWarn)
+		request.FolderID = parentID
+		request.Position = position
+		if _, err := s.requestService.Create(collectionID, request, userID); err != nil {
+			return fmt.Errorf("creating request %q: %w", path, err)
+		}
+		report.Requests++
+	}
+	return nil
+}
+
+// importVariable is an environment variable to import or export.
+type importVariable struct {
+	key, value string
+	secret     bool // imported encrypted as an environment secret, exported with an empty value
+}
+
+// importEnvironment stores variables as a new environment, numbering the
+// name when the workspace already has one called that. Repeated keys keep
+// their first value. Secret variables are stored encrypted as environment
+// secrets. If a variable cannot be added, the environment is deleted.
+func (s *ImportService) importEnvironment(workspaceID, userID uuid.UUID, name, description string, variables []importVariable) (*ImportedEnvironment, error) {
+	var environment *models.Environment
+	var err error
+	for attempt := 1; ; attempt++ {
+		candidate := name
+		if attempt > 1 {
+			candidate = fmt.Sprintf("%s (%d)", name, attempt)
+		}
+		environment, err = s.environmentService.Create(workspaceID, candidate, environmentType(name), description, true, userID)
+		if !errors.Is(err, gorm.ErrDuplicatedKey) || attempt == 10 {
+			break
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("creating environment %q: %w", name, err)
+	}
+
+	imported := &ImportedEnvironment{ID: environment.ID, Name: environment.Name}
+	discard := func(err error) (*ImportedEnvironment, error) {
+		s.environmentService.Delete(workspaceID, environment.ID, userID)
+		return nil, err
+	}
+	seen := map[string]bool{}
+	for _, variable := range variables {
+		if variable.key == "" || seen[variable.key] {
+			continue
+		}
+		seen[variable.key] = true
+		if variable.secret {
+			if s.requestService.secrets == nil {
+				return discard(fmt.Errorf("secret variable %q cannot be imported without a secrets key", variable.key))
+			}
+			encrypted, err := s.requestService.secrets.encrypt(variable.value)
+			if err != nil {
+				return discard(fmt.Errorf("encrypting secret %q: %w", variable.key, err))
+			}
+			if _, err := s.environmentService.AddSecret(workspaceID, environment.ID, variable.key, encrypted, "", userID); err != nil {
+				return discard(fmt.Errorf("adding secret %q to environment %q: %w", variable.key, environment.Name, err))
+			}
+			imported.Secrets++
+			continue
+		}
+		if _, err := s.environmentService.AddVariable(workspaceID, environment.ID, variable.key, variable.value, "string", "", userID); err != nil {
+			return discard(fmt.Errorf("adding variable %q to environment %q: %w", variable.key, environment.Name, err))
+		}
+		imported.Variables++
+	}
+	return imported, nil
+}
+
+// environmentType guesses an environment type from its name.
+func environmentType(name string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.Contains(lower, "prod"):
+		return "production"
+	case strings.Contains(lower, "stag"):
+		return "staging"
+	default:
+		return "development"
+	}
+}
+
+// postmanDynamicVariable matches Postman's generated values, e.g. {{$guid}}.
+var postmanDynamicVariable = regexp.MustCompile(`\{\{\s*(\$\w+)\s*\}\}`)
+
+// postmanPathVariable matches :name path segments.
+var postmanPathVariable = regexp.MustCompile(`/:(\w+)`)
+
+// PostmanRequestToModel converts a Postman request item. Anything that cannot
+// be represented is passed to warn.
+func PostmanRequestToModel(item integrations.PostmanItem, warn func(string)) *models.Request {
+	source := item.Request
+	request := &models.Request{
+		Name:        item.Name,
+		Method:      strings.ToUpper(source.Method),
+		Description: string(item.Description),
+		BodyMode:    BodyModeNone,
+	}
+	if request.Method == "" {
+		request.Method = "GET"
+	}
+	if request.Description == "" {
+		request.Description = string(source.Description)
+	}
+
+	// The query array is authoritative and includes disabled params
+	rawURL := source.URL.Raw
+	if len(source.URL.Query) > 0 {
+		rawURL, _, _ = strings.Cut(rawURL, "?")
+		params := make([]RequestParam, 0, len(source.URL.Query))
+		for _, query := range source.URL.Query {
+			param := RequestParam{Key: query.Key, Value: query.Value}
+			if query.Disabled {
+				disabled := false
+				param.Enabled = &disabled
+			}
+			params = append(params, param)
+		}
+		encoded, _ := json.Marshal(params)
+		request.QueryParams = string(encoded)
+	}
+	pathValues := map[string]string{}
+	for _, variable := range source.URL.Variable {
+		pathValues[variable.Key] = variable.Value
+	}
+	rawURL = postmanPathVariable.ReplaceAllStringFunc(rawURL, func(match string) string {
+		key := match[2:]
+		if value := pathValues[key]; value != "" {
+			return "/" + value
+		}
+		warn(fmt.Sprintf("path variable :%s has no value and became {{%s}}", key, key))
+		return "/{{" + key + "}}"
+	})
+	if rawURL == "" {
+		warn("request has no URL")
+	}
+	request.URL = rawURL
+
+	headers := map[string]string{}
+	disabledHeaders := 0
+	for _, header := range source.Header {
+		if header.Disabled {
+			disabledHeaders++
+			continue
+		}
+		headers[header.Key] = header.Value
+	}
+	if disabledHeaders > 0 {
+		warn(fmt.Sprintf("%d disabled header(s) were not imported", disabledHeaders))
+	}
+
+	if body := source.Body; body != nil && !body.Disabled {
+		postmanBody(request, body, headers, warn)
+	}
+	headersJSON, _ := json.Marshal(headers)
+	request.Headers = string(headersJSON)
+
+	request.Auth = postmanAuth(source.Auth, warn)
+	request.Settings = postmanSettings(item.ProtocolProfileBehavior)
+
+	if len(item.Response) > 0 {
+		warn(fmt.Sprintf("%d saved example response(s) were not imported", len(item.Response)))
+	}
+
+	dynamic := map[string]bool{}
+	for _, text := range []string{request.URL, request.Headers, request.QueryParams, request.Body} {
+		for _, match := range postmanDynamicVariable.FindAllStringSubmatch(text, -1) {
+			dynamic[match[1]] = true
+		}
+	}
+	if len(dynamic) > 0 {
+		names := make([]string, 0, len(dynamic))
+		for name := range dynamic {
+			names = append(names, "{{"+name+"}}")
+		}
+		sort.Strings(names)
+		warn(fmt.Sprintf("Postman dynamic variables are not resolved: %s", strings.Join(names, ", ")))
+	}
+
+	return request
+}
+
+// postmanBody sets the request body, adding a Content-Type header where the
+// Postman body language implied one.
+func postmanBody(request *models.Request, body *integrations.PostmanBody, headers map[string]string, warn func(string)) {
+	quoted := func(text string) string {
+		encoded, _ := json.Marshal(text)
+		return string(encoded)
+	}
+	setContentType := func(contentType string) {
+		if headerValue(headers, "Content-Type") == "" {
+			headers["Content-Type"] = contentType
+		}
+	}
+	params := func(fields []integrations.PostmanKeyValue) []RequestParam {
+		result := make([]RequestParam, 0, len(fields))
+		for _, field := range fields {
+			param := RequestParam{Key: field.Key, Value: field.Value, ContentType: field.ContentType}
+			if field.Disabled {
+				disabled := false
+				param.Enabled = &disabled
+			}
+			result = append(result, param)
+		}
+		return result
+	}
+
+	switch body.Mode {
+	case "raw":
+		if body.Raw == "" {
+			return
+		}
+		switch language := body.Options.Raw.Language; {
+		case language == "json" || (language == "" && strings.HasSuffix(headerValue(headers, "Content-Type"), "json")):
+			if json.Valid([]byte(body.Raw)) {
+				request.BodyMode, request.Body = BodyModeJSON, body.Raw
+				return
+			}
+			// Usually unquoted {{variables}}, which are valid once resolved
+			setContentType("application/json")
+			request.BodyMode, request.Body = BodyModeText, quoted(body.Raw)
+		case language == "xml":
+			request.BodyMode, request.Body = BodyModeXML, quoted(body.Raw)
+		case language == "html":
+			setContentType("text/html")
+			request.BodyMode, request.Body = BodyModeText, quoted(body.Raw)
+		case language == "javascript":
+			setContentType("application/javascript")
+			request.BodyMode, request.Body = BodyModeText, quoted(body.Raw)
+		default:
+			request.BodyMode, request.Body = BodyModeText, quoted(body.Raw)
+		}
+	case "urlencoded":
+		encoded, _ := json.Marshal(params(body.URLEncoded))
+		request.BodyMode, request.Body = BodyModeURLEncoded, string(encoded)
+	case "formdata":
+		fields := params(body.FormData)
+		for i, field := range body.FormData {
+			if field.Type == "file" {
+				fields[i].Type = "file"
+				fields[i].Value = postmanFileSource(field.Src)
+				warn(fmt.Sprintf("file field %q needs the file uploaded and attached", field.Key))
+			}
+		}
+		encoded, _ := json.Marshal(fields)
+		request.BodyMode, request.Body = BodyModeFormData, string(encoded)
+	case "file":
+		encoded, _ := json.Marshal(BinaryBody{})
+		request.BodyMode, request.Body = BodyModeBinary, string(encoded)
+		warn("binary body needs the file uploaded and attached")
+	case "graphql":
+		if body.GraphQL == nil {
+			return
+		}
+		gql := GraphQLRequest{Query: body.GraphQL.Query}
+		if strings.TrimSpace(body.GraphQL.Variables) != "" {
+			if err := json.Unmarshal([]byte(body.GraphQL.Variables), &gql.Variables); err != nil {
+				warn("GraphQL variables are not a JSON object and were not imported")
+			}
+		}
+		encoded, _ := json.Marshal(gql)
+		request.Kind, request.GraphQL = RequestKindGraphQL, string(encoded)
+	case "":
+	default:
+		warn(fmt.Sprintf("body mode %q is not supported", body.Mode))
+	}
+}
+
+func postmanFileSource(src interface{}) string {
+	switch v := src.(type) {
+	case string:
+		return v
+	case []interface{}:
+		if len(v) > 0 {
+			if first, ok := v[0].(string); ok {
+				return first
+			}
+		}
+	}
+	return ""
+}
+
+// postmanAuth converts Postman auth to an auth JSON column. Missing auth
+// inherits, which is also Postman's behaviour.
+func postmanAuth(auth *integrations.PostmanAuth, warn func(string)) string {
+	if auth == nil || auth.Type == "" {
+		return ""
+	}
+
+	var cfg *AuthConfig
+	switch auth.Type {
+	case "inherit":
+		return ""
+	case "noauth":
+		cfg = &AuthConfig{Type: AuthTypeNone}
+	case "basic":
+		cfg = &AuthConfig{Type: AuthTypeBasic, Basic: &BasicAuthConfig{Username: auth.Get("username"), Password: auth.Get("password")}}
+	case "digest":
+		cfg = &AuthConfig{Type: AuthTypeDigest, Digest: &DigestAuthConfig{Username: auth.Get("username"), Password: auth.Get("password")}}
+	case "bearer":
+		cfg = &AuthConfig{Type: AuthTypeBearer, Bearer: &BearerAuthConfig{Token: auth.Get("token")}}
+	case "apikey":
+		in := "header"
+		if auth.Get("in") == "query" {
+			in = "query"
+		}
+		cfg = &AuthConfig{Type: AuthTypeAPIKey, APIKey: &APIKeyAuthConfig{Key: auth.Get("key"), Value: auth.Get("value"), In: in}}
+	case "awsv4":
+		cfg = &AuthConfig{Type: AuthTypeAWSV4, AWSV4: &AWSV4AuthConfig{
+			AccessKeyID:     auth.Get("accessKey"),
+			SecretAccessKey: auth.Get("secretKey"),
+			SessionToken:    auth.Get("sessionToken"),
+			Region:          auth.Get("region"),
+			Service:         auth.Get("service"),
+		}}
+	case "oauth2":
+		grantType := map[string]string{
+			"client_credentials":   "client_credentials",
+			"password_credentials": "password",
+		}[auth.Get("grant_type")]
+		switch {
+		case grantType != "":
+			clientAuth := "header"
+			if auth.Get("client_authentication") == "body" {
+				clientAuth = "body"
+			}
+			cfg = &AuthConfig{Type: AuthTypeOAuth2, OAuth2: &OAuth2AuthConfig{
+				GrantType:    grantType,
+				TokenURL:     auth.Get("accessTokenUrl"),
+				ClientID:     auth.Get("clientId"),
+				ClientSecret: auth.Get("clientSecret"),
+				Username:     auth.Get("username"),
+				Password:     auth.Get("password"),
+				Scope:        auth.Get("scope"),
+				Audience:     auth.Get("audience"),
+				ClientAuth:   clientAuth,
+			}}
+		case auth.Get("accessToken") != "":
+			warn(fmt.Sprintf("OAuth 2.0 grant type %q is not supported; its current access token was imported as bearer auth", auth.Get("grant_type")))
+			cfg = &AuthConfig{Type: AuthTypeBearer, Bearer: &BearerAuthConfig{Token: auth.Get("accessToken")}}
+		default:
+			warn(fmt.Sprintf("OAuth 2.0 grant type %q is not supported; auth is inherited instead", auth.Get("grant_type")))
+			return ""
+		}
+	default:
+		warn(fmt.Sprintf("auth type %q is not supported; auth is inherited instead", auth.Type))
+		return ""
+	}
+
+	encoded, _ := json.Marshal(cfg)
+	return string(encoded)
+}
+
+// postmanSettings maps the protocolProfileBehavior options with a client
+// settings equivalent.
+func postmanSettings(behavior map[string]interface{}) string {
+	settings := ClientSettings{}
+	if follow, ok := behavior["followRedirects"].(bool); ok && !follow {
+		policy := RedirectNone
+		settings.RedirectPolicy = &policy
+	}
+	if limit, ok := behavior["maxRedirects"].(float64); ok && settings.RedirectPolicy == nil {
+		policy, maxRedirects := RedirectLimit, int(limit)
+		settings.RedirectPolicy, settings.MaxRedirects = &policy, &maxRedirects
+	}
+	if strict, ok := behavior["strictSSL"].(bool); ok && !strict {
+		insecure := true
+		settings.InsecureSkipVerify = &insecure
+	}
+	if settings == (ClientSettings{}) {
+		return ""
+	}
+	encoded, _ := json.Marshal(settings)
+	return string(encoded)
+}
+
+func postmanVariables(variables []integrations.PostmanVariable) []integrations.PostmanVariable {
+	enabled := make([]integrations.PostmanVariable, 0, len(variables))
+	for _, variable := range variables {
+		if !variable.Disabled && variable.Key != "" {
+			enabled = append(enabled, variable)
+		}
+	}
+	return enabled
+}
+
+// postmanScriptWarnings reports scripts, which have no equivalent here.
+func postmanScriptWarnings(events []integrations.PostmanEvent, warn func(string)) {
+	for _, event := range events {
+		if event.Disabled || !event.HasCode() {
+			continue
+		}
+		switch event.Listen {
+		case "prerequest":
+			warn("pre-request script was not imported")
+		case "test":
+			warn("test script was not imported")
+		default:
+			warn(fmt.Sprintf("%s script was not imported", event.Listen))
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package services
+
+import (
+	"backend/integrations"
+	"database/sql/driver"
+	"encoding/json"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const postmanFixture = `{
+  "info": {"name": "Shop", "schema": "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"},
+  "auth": {"type": "bearer", "bearer": [{"key": "token", "value": "{{token}}", "type": "string"}]},
+  "variable": [{"key": "host", "value": "api.test"}, {"key": "old", "value": "x", "disabled": true}],
+  "item": [
+    {
+      "name": "Orders",
+      "auth": {"type": "apikey", "apikey": [{"key": "key", "value": "X-Key"}, {"key": "value", "value": "secret"}, {"key": "in", "value": "query"}]},
+      "variable": [{"key": "version", "value": 2}],
+      "item": [
+        {
+          "name": "Get order",
+          "event": [{"listen": "test", "script": {"exec": ["pm.test('ok')"]}}],
+          "request": {
+            "method": "get",
+            "header": [{"key": "Accept", "value": "application/json"}, {"key": "X-Debug", "value": "1", "disabled": true}],
+            "url": {
+              "raw": "https://{{host}}/orders/:id?expand=items&trace=1",
+              "query": [{"key": "expand", "value": "items"}, {"key": "trace", "value": "1", "disabled": true}],
+              "variable": [{"key": "id", "value": "42"}]
+            }
+          },
+          "response": [{"name": "200"}]
+        }
+      ]
+    },
+    {
+      "name": "Create order",
+      "request": {
+        "method": "POST",
+        "url": "https://{{host}}/orders",
+        "body": {"mode": "raw", "raw": "{\"qty\": {{qty}}, \"ref\": \"{{$guid}}\"}", "options": {"raw": {"language": "json"}}}
+      }
+    }
+  ]
+}`
+
+func TestPostmanImporter_ParsesNestedItems(t *testing.T) {
+	collection, err := integrations.NewPostmanImporter().Parse([]byte(postmanFixture))
+	require.NoError(t, err)
+
+	require.Len(t, collection.Item, 2)
+	assert.True(t, collection.Item[0].IsFolder())
+	assert.False(t, collection.Item[1].IsFolder())
+	assert.Equal(t, "https://{{host}}/orders", collection.Item[1].Request.URL.Raw, "string URLs are accepted")
+	assert.Equal(t, "2", collection.Item[0].Variable[0].Value, "non-string values are stringified")
+	assert.Equal(t, []string{"host"}, variableKeys(postmanVariables(collection.Variable)))
+
+	_, err = integrations.NewPostmanImporter().Parse([]byte(`{"info": {"name": "Old", "schema": "https://schema.getpostman.com/json/collection/v1.0.0/collection.json"}, "item": []}`))
+	assert.Error(t, err)
+}
+
+func TestPostmanRequestToModel(t *testing.T) {
+	collection, err := integrations.NewPostmanImporter().Parse([]byte(postmanFixture))
+	require.NoError(t, err)
+
+	var warnings []string
+	request := PostmanRequestToModel(collection.Item[0].Item[0], func(message string) { warnings = append(warnings, message) })
+
+	assert.Equal(t, "GET", request.Method)
+	assert.Equal(t, "https://{{host}}/orders/42", request.URL)
+	assert.Equal(t, `{"Accept":"application/json"}`, request.Headers)
+	assert.Equal(t, BodyModeNone, request.BodyMode)
+
+	params, err := ParseRequestParams(request.QueryParams)
+	require.NoError(t, err)
+	require.Len(t, params, 2)
+	assert.True(t, params[0].enabled())
+	assert.False(t, params[1].enabled(), "disabled query params are kept but disabled")
+
+	assert.ElementsMatch(t, []string{
+		"1 disabled header(s) were not imported",
+		"1 saved example response(s) were not imported",
+	}, warnings)
+
+	warnings = nil
+	request = PostmanRequestToModel(collection.Item[1], func(message string) { warnings = append(warnings, message) })
+	assert.Equal(t, BodyModeText, request.BodyMode, "JSON with unquoted variables is kept as text")
+	assert.JSONEq(t, `{"Content-Type":"application/json"}`, request.Headers)
+	assert.Equal(t, []string{"Postman dynamic variables are not resolved: {{$guid}}"}, warnings)
+}
+
+func TestPostmanAuth(t *testing.T) {
+	collection, err := integrations.NewPostmanImporter().Parse([]byte(postmanFixture))
+	require.NoError(t, err)
+	noWarnings := func(message string) { t.Errorf("unexpected warning: %s", message) }
+
+	cfg, err := ParseAuthConfig(postmanAuth(collection.Auth, noWarnings))
+	require.NoError(t, err)
+	assert.Equal(t, "{{token}}", cfg.Bearer.Token)
+
+	cfg, err = ParseAuthConfig(postmanAuth(collection.Item[0].Auth, noWarnings))
+	require.NoError(t, err)
+	assert.Equal(t, &APIKeyAuthConfig{Key: "X-Key", Value: "secret", In: "query"}, cfg.APIKey)
+
+	var auth integrations.PostmanAuth
+	require.NoError(t, json.Unmarshal([]byte(`{"type": "oauth2", "oauth2": [{"key": "grant_type", "value": "authorization_code"}, {"key": "accessToken", "value": "abc"}]}`), &auth))
+	var warnings []string
+	cfg, err = ParseAuthConfig(postmanAuth(&auth, func(message string) { warnings = append(warnings, message) }))
+	require.NoError(t, err)
+	assert.Equal(t, AuthTypeBearer, cfg.Type)
+	assert.Len(t, warnings, 1)
+
+	require.NoError(t, json.Unmarshal([]byte(`{"type": "hawk", "hawk": []}`), &auth))
+	assert.Equal(t, "", postmanAuth(&auth, func(string) {}), "unsupported auth inherits")
+}
+
+func variableKeys(variables []integrations.PostmanVariable) []string {
+	result := make([]string, len(variables))
+	for i, variable := range variables {
+		result[i] = variable.Key
+	}
+	return result
+}
+
+// decryptsTo matches an encrypted argument holding the given plaintext.
+type decryptsTo struct {
+	secrets   *SecretsService
+	plaintext string
+}
+
+func (a decryptsTo) Match(v driver.Value) bool {
+	ciphertext, ok := v.(string)
+	if !ok || ciphertext == a.plaintext {
+		return false
+	}
+	plaintext, err := a.secrets.decrypt(ciphertext)
+	return err == nil && plaintext == a.plaintext
+}
+
+func TestImportService_ImportEnvironment_StoresSecretsEncrypted(t *testing.T) {
+	db, mock := setupTestDBRequest(t)
+	secrets := NewSecretsService(db, "test-key")
+	service := NewImportService(db, NewRequestService(db, secrets), nil)
+
+	workspaceID := uuid.New()
+	userID := uuid.New()
+	environmentID := uuid.New()
+
+	expectEnvironmentAccess := func() {
+		mock.ExpectQuery(`(?i)SELECT \* FROM "workspace_members"`).
+			WithArgs(workspaceID, userID, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	}
+	expectEnvironmentAccess()
+	mock.ExpectQuery(`(?i)SELECT \* FROM "environments" WHERE \(workspace_id = \$1 AND name = \$2\)`).
+		WithArgs(workspaceID, "Production", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`(?i)INSERT INTO "environments"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(environmentID))
+	mock.ExpectCommit()
+
+	expectEnvironmentAccess()
+	mock.ExpectQuery(`(?i)SELECT \* FROM "environments" WHERE \(id = \$1`).
+		WithArgs(environmentID, workspaceID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "workspace_id"}).AddRow(environmentID, workspaceID))
+	mock.ExpectQuery(`(?i)SELECT \* FROM "environment_variables"`).
+		WithArgs(environmentID, "host", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`(?i)INSERT INTO "environment_variables"`).
+		WithArgs(environmentID, "host", "api.test", "string", "", sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectCommit()
+
+	// The secret goes to environment_secrets, encrypted, never as a variable
+	expectEnvironmentAccess()
+	mock.ExpectQuery(`(?i)SELECT \* FROM "environments" WHERE \(id = \$1`).
+		WithArgs(environmentID, workspaceID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "workspace_id"}).AddRow(environmentID, workspaceID))
+	mock.ExpectQuery(`(?i)SELECT \* FROM "environment_secrets"`).
+		WithArgs(environmentID, "token", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`(?i)INSERT INTO "environment_secrets"`).
+		WithArgs(environmentID, "token", decryptsTo{secrets, "s3cret"}, "", sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectCommit()
+
+	imported, err := service.importEnvironment(workspaceID, userID, "Production", "Imported from Postman", []importVariable{
+		{key: "host", value: "api.test"},
+		{key: "token", value: "s3cret", secret: true},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, imported.Variables)
+	assert.Equal(t, 1, imported.Secrets)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestImportService_ImportEnvironment_DeletesEnvironmentOnFailure(t *testing.T) {
+	db, mock := setupTestDBRequest(t)
+	service := NewImportService(db, NewRequestService(db, nil), nil)
+
+	workspaceID := uuid.New()
+	userID := uuid.New()
+	environmentID := uuid.New()
+
+	expectEnvironmentAccess := func() {
+		mock.ExpectQuery(`(?i)SELECT \* FROM "workspace_members"`).
+			WithArgs(workspaceID, userID, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	}
+	expectEnvironmentAccess()
+	mock.ExpectQuery(`(?i)SELECT \* FROM "environments" WHERE \(workspace_id = \$1 AND name = \$2\)`).
+		WithArgs(workspaceID, "Production", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`(?i)INSERT INTO "environments"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(environmentID))
+	mock.ExpectCommit()
+
+	// Without a secrets key the secret cannot be stored, so the environment goes
+	expectEnvironmentAccess()
+	mock.ExpectQuery(`(?i)SELECT \* FROM "environments" WHERE \(id = \$1`).
+		WithArgs(environmentID, workspaceID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "workspace_id"}).AddRow(environmentID, workspaceID))
+	for _, table := range []string{"environment_variables", "environment_secrets", "environments"} {
+		mock.ExpectBegin()
+		mock.ExpectExec(`(?i)UPDATE "` + table + `" SET "deleted_at"`).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+	}
+
+	_, err := service.importEnvironment(workspaceID, userID, "Production", "Imported from Postman", []importVariable{
+		{key: "token", value: "s3cret", secret: true},
+	})
+	assert.ErrorContains(t, err, "without a secrets key")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
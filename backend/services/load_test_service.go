@@ -20,6 +20,7 @@ type LoadTest struct {
 	Status          string    `gorm:"default:'pending'"` // pending, running, completed, failed
 	SuccessCount    int       `gorm:"default:0"`
 	FailureCount    int       `gorm:"default:0"`
+	RetriedCount    int       `gorm:"default:0"` // successes that needed more than one attempt
 	AvgResponseTime float64
 	P95ResponseTime float64
 	P99ResponseTime float64
@@ -33,10 +34,10 @@ type LoadTestService struct {
 	requestService *RequestService
 }
 
-func NewLoadTestService(db *gorm.DB) *LoadTestService {
+func NewLoadTestService(db *gorm.DB, requestService *RequestService) *LoadTestService {
 	return &LoadTestService{
 		db:             db,
-		requestService: NewRequestService(db),
+		requestService: requestService,
 	}
 }
 
@@ -79,6 +80,7 @@ func (s *LoadTestService) executeLoadTest(testID, userID uuid.UUID) {
 	responseTimes := []int64{}
 	successCount := 0
 	failureCount := 0
+	retriedCount := 0
 
 	// Calculate requests per worker
 	requestsPerWorker := test.TotalRequests / test.Concurrency
@@ -103,6 +105,9 @@ func (s *LoadTestService) executeLoadTest(testID, userID uuid.UUID) {
 					failureCount++
 				} else {
 					successCount++
+					if execution.AttemptCount > 1 {
+						retriedCount++
+					}
 					responseTimes = append(responseTimes, execution.ResponseTimeMs)
 				}
 				mu.Unlock()
@@ -138,6 +143,7 @@ func (s *LoadTestService) executeLoadTest(testID, userID uuid.UUID) {
 		"completed_at":      completedAt,
 		"success_count":     successCount,
 		"failure_count":     failureCount,
+		"retried_count":     retriedCount,
 		"avg_response_time": avgResponseTime,
 		"p95_response_time": p95,
 		"p99_response_time": p99,
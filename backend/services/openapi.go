@@ -0,0 +1,330 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// openAPIMethods are the operation keys of a path item, in display order.
+var openAPIMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// OpenAPIDocument is a parsed OpenAPI 3.0 or 3.1 document. It is kept as
+// generic JSON values, the same shape encoding/json produces, so schemas
+// can be handed to the JSON Schema validator directly.
+type OpenAPIDocument struct {
+	Version string // the openapi field, e.g. 3.0.3
+	Root    map[string]interface{}
+}
+
+// OpenAPIOperation is one method of one path, with the path item's
+// parameters merged into the operation's.
+type OpenAPIOperation struct {
+	Method     string // upper case
+	Path       string // path template, e.g. /orders/{id}
+	Operation  map[string]interface{}
+	Parameters []map[string]interface{} // resolved
+}
+
+// ParseOpenAPI reads an OpenAPI 3.x document from JSON or YAML.
+func ParseOpenAPI(data []byte) (*OpenAPIDocument, error) {
+	var decoded interface{}
+	trimmed := bytes.TrimSpace(data)
+	if bytes.HasPrefix(trimmed, []byte("{")) {
+		if err := json.Unmarshal(trimmed, &decoded); err != nil {
+			return nil, fmt.Errorf("invalid OpenAPI JSON: %w", err)
+		}
+	} else {
+		var node interface{}
+		if err := yaml.Unmarshal(trimmed, &node); err != nil {
+			return nil, fmt.Errorf("invalid OpenAPI YAML: %w", err)
+		}
+		// Round-trip through JSON so numbers and maps match encoding/json
+		encoded, err := json.Marshal(jsonCompatible(node))
+		if err != nil {
+			return nil, fmt.Errorf("invalid OpenAPI YAML: %w", err)
+		}
+		json.Unmarshal(encoded, &decoded)
+	}
+
+	root, ok := decoded.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("OpenAPI document must be an object")
+	}
+	version, _ := root["openapi"].(string)
+	if version == "" {
+		if _, ok := root["swagger"]; ok {
+			return nil, errors.New("Swagger 2.0 documents are not supported, convert the document to OpenAPI 3 first")
+		}
+		return nil, errors.New("not an OpenAPI document: missing the openapi version field")
+	}
+	if !strings.HasPrefix(version, "3.") {
+		return nil, fmt.Errorf("unsupported OpenAPI version: %s", version)
+	}
+	return &OpenAPIDocument{Version: version, Root: root}, nil
+}
+
+// jsonCompatible converts YAML mappings with non-string keys, such as
+// unquoted response codes, into string-keyed maps.
+func jsonCompatible(node interface{}) interface{} {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			v[key] = jsonCompatible(value)
+		}
+		return v
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			converted[fmt.Sprint(key)] = jsonCompatible(value)
+		}
+		return converted
+	case []interface{}:
+		for i, value := range v {
+			v[i] = jsonCompatible(value)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// Info returns a field of the info object, e.g. title or version.
+func (d *OpenAPIDocument) Info(field string) string {
+	info, _ := d.Root["info"].(map[string]interface{})
+	value, _ := info[field].(string)
+	return value
+}
+
+// Lookup follows a local JSON pointer reference such as
+// #/components/schemas/Order.
+func (d *OpenAPIDocument) Lookup(ref string) (interface{}, error) {
+	if !strings.HasPrefix(ref, "#") {
+		return nil, fmt.Errorf("external reference %s is not supported", ref)
+	}
+	var node interface{} = d.Root
+	pointer := strings.TrimPrefix(ref, "#")
+	if pointer == "" {
+		return node, nil
+	}
+	for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+		switch current := node.(type) {
+		case map[string]interface{}:
+			value, ok := current[token]
+			if !ok {
+				return nil, fmt.Errorf("reference %s not found", ref)
+			}
+			node = value
+		case []interface{}:
+			var index int
+			if _, err := fmt.Sscanf(token, "%d", &index); err != nil || index < 0 || index >= len(current) {
+				return nil, fmt.Errorf("reference %s not found", ref)
+			}
+			node = current[index]
+		default:
+			return nil, fmt.Errorf("reference %s not found", ref)
+		}
+	}
+	return node, nil
+}
+
+// Resolve follows $ref until it reaches an object that is not a reference.
+// Used for parameters, request bodies, responses and examples.
+func (d *OpenAPIDocument) Resolve(node interface{}) (map[string]interface{}, error) {
+	for depth := 0; depth < 32; depth++ {
+		object, ok := node.(map[string]interface{})
+		if !ok {
+			return nil, nil
+		}
+		ref, ok := object["$ref"].(string)
+		if !ok {
+			return object, nil
+		}
+		target, err := d.Lookup(ref)
+		if err != nil {
+			return nil, err
+		}
+		node = target
+	}
+	return nil, errors.New("reference chain is too deep")
+}
+
+// InlineSchema returns a copy of schema with every $ref replaced by its
+// target. A reference back into a schema that is already being inlined is
+// replaced with an empty, accept-anything schema.
+func (d *OpenAPIDocument) InlineSchema(schema interface{}) (map[string]interface{}, error) {
+	inlined, err := d.inline(schema, map[string]bool{})
+	if err != nil {
+		return nil, err
+	}
+	object, _ := inlined.(map[string]interface{})
+	return object, nil
+}
+
+func (d *OpenAPIDocument) inline(node interface{}, active map[string]bool) (interface{}, error) {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		if ref, ok := v["$ref"].(string); ok {
+			if active[ref] {
+				return map[string]interface{}{"description": "recursive reference to " + ref}, nil
+			}
+			target, err := d.Lookup(ref)
+			if err != nil {
+				return nil, err
+			}
+			active[ref] = true
+			defer delete(active, ref)
+			return d.inline(target, active)
+		}
+		copied := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			inlined, err := d.inline(value, active)
+			if err != nil {
+				return nil, err
+			}
+			copied[key] = inlined
+		}
+		return copied, nil
+	case []interface{}:
+		copied := make([]interface{}, len(v))
+		for i, value := range v {
+			inlined, err := d.inline(value, active)
+			if err != nil {
+				return nil, err
+			}
+			copied[i] = inlined
+		}
+		return copied, nil
+	default:
+		return v, nil
+	}
+}
+
+// Operations lists every operation, ordered by path and then method.
+func (d *OpenAPIDocument) Operations() ([]OpenAPIOperation, error) {
+	paths, _ := d.Root["paths"].(map[string]interface{})
+	templates := make([]string, 0, len(paths))
+	for path := range paths {
+		templates = append(templates, path)
+	}
+	sort.Strings(templates)
+
+	var operations []OpenAPIOperation
+	for _, path := range templates {
+		item, err := d.Resolve(paths[path])
+		if err != nil {
+			return nil, fmt.Errorf("path %s: %w", path, err)
+		}
+		shared, err := d.parameters(item["parameters"])
+		if err != nil {
+			return nil, fmt.Errorf("path %s: %w", path, err)
+		}
+
+		for _, method := range openAPIMethods {
+			operation, ok := item[method].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			own, err := d.parameters(operation["parameters"])
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %w", strings.ToUpper(method), path, err)
+			}
+
+			// Operation parameters override path item parameters with the same name and location
+			merged := append([]map[string]interface{}{}, own...)
+			for _, parameter := range shared {
+				overridden := false
+				for _, existing := range own {
+					if existing["name"] == parameter["name"] && existing["in"] == parameter["in"] {
+						overridden = true
+						break
+					}
+				}
+				if !overridden {
+					merged = append(merged, parameter)
+				}
+			}
+
+			operations = append(operations, OpenAPIOperation{
+				Method:     strings.ToUpper(method),
+				Path:       path,
+				Operation:  operation,
+				Parameters: merged,
+			})
+		}
+	}
+	return operations, nil
+}
+
+func (d *OpenAPIDocument) parameters(node interface{}) ([]map[string]interface{}, error) {
+	list, _ := node.([]interface{})
+	parameters := make([]map[string]interface{}, 0, len(list))
+	for _, entry := range list {
+		parameter, err := d.Resolve(entry)
+		if err != nil {
+			return nil, err
+		}
+		if parameter != nil {
+			parameters = append(parameters, parameter)
+		}
+	}
+	return parameters, nil
+}
+
+// OpenAPIToJSONSchema converts an inlined OpenAPI schema to JSON Schema.
+// OpenAPI 3.0's nullable becomes a null type; 3.1 schemas already are JSON
+// Schema and pass through.
+func OpenAPIToJSONSchema(schema map[string]interface{}) map[string]interface{} {
+	converted := make(map[string]interface{}, len(schema))
+	for key, value := range schema {
+		switch key {
+		case "properties", "patternProperties", "definitions", "$defs":
+			if children, ok := value.(map[string]interface{}); ok {
+				convertedChildren := make(map[string]interface{}, len(children))
+				for name, child := range children {
+					if childSchema, ok := child.(map[string]interface{}); ok {
+						convertedChildren[name] = OpenAPIToJSONSchema(childSchema)
+					} else {
+						convertedChildren[name] = child
+					}
+				}
+				value = convertedChildren
+			}
+		case "items", "additionalProperties", "not":
+			if child, ok := value.(map[string]interface{}); ok {
+				value = OpenAPIToJSONSchema(child)
+			}
+		case "allOf", "anyOf", "oneOf":
+			if list, ok := value.([]interface{}); ok {
+				convertedList := make([]interface{}, len(list))
+				for i, child := range list {
+					if childSchema, ok := child.(map[string]interface{}); ok {
+						convertedList[i] = OpenAPIToJSONSchema(childSchema)
+					} else {
+						convertedList[i] = child
+					}
+				}
+				value = convertedList
+			}
+		case "nullable", "discriminator", "xml", "externalDocs", "example", "readOnly", "writeOnly", "deprecated":
+			continue
+		}
+		converted[key] = value
+	}
+
+	if nullable, _ := schema["nullable"].(bool); nullable {
+		if schemaType, ok := converted["type"].(string); ok {
+			converted["type"] = []interface{}{schemaType, "null"}
+		}
+		if enum, ok := converted["enum"].([]interface{}); ok {
+			converted["enum"] = append(append([]interface{}{}, enum...), nil)
+		}
+	}
+	return converted
+}
@@ -0,0 +1,203 @@
+package services
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const openAPIFixture = `
+openapi: 3.0.3
+info:
+  title: Shop
+  version: 1.2.0
+servers:
+  - url: https://{region}.shop.test/v1
+    variables:
+      region:
+        default: eu
+security:
+  - ApiKey: []
+tags:
+  - name: Orders
+paths:
+  /orders/{id}:
+    parameters:
+      - name: id
+        in: path
+        required: true
+        schema: {type: integer, example: 42}
+    get:
+      tags: [Orders]
+      summary: Get order
+      parameters:
+        - name: expand
+          in: query
+          schema: {type: string, enum: [items, customer]}
+        - name: X-Tenant
+          in: header
+          required: true
+          example: acme
+        - name: session
+          in: cookie
+      responses:
+        200:
+          description: The order
+          content:
+            application/json:
+              schema: {$ref: '#/components/schemas/Order'}
+        404:
+          description: Not found
+  /orders:
+    post:
+      tags: [Orders]
+      operationId: createOrder
+      security: []
+      requestBody:
+        content:
+          application/json:
+            schema: {$ref: '#/components/schemas/Order'}
+      responses:
+        "201":
+          description: Created
+          content:
+            application/json:
+              schema: {$ref: '#/components/schemas/Order'}
+components:
+  securitySchemes:
+    ApiKey: {type: apiKey, in: header, name: X-Api-Key}
+  schemas:
+    Order:
+      type: object
+      required: [id]
+      properties:
+        id: {type: integer, readOnly: true}
+        note: {type: string, nullable: true, example: gift}
+        parent: {$ref: '#/components/schemas/Order'}
+`
+
+func TestParseOpenAPI(t *testing.T) {
+	doc, err := ParseOpenAPI([]byte(openAPIFixture))
+	require.NoError(t, err)
+	assert.Equal(t, "3.0.3", doc.Version)
+	assert.Equal(t, "Shop", doc.Info("title"))
+
+	operations, err := doc.Operations()
+	require.NoError(t, err)
+	require.Len(t, operations, 2)
+	assert.Equal(t, "POST", operations[0].Method, "operations are sorted by path")
+	assert.Equal(t, "/orders/{id}", operations[1].Path)
+	assert.Len(t, operations[1].Parameters, 4, "path item parameters are merged in")
+
+	responses := operations[1].Operation["responses"].(map[string]interface{})
+	assert.Contains(t, responses, "200", "unquoted YAML response codes become string keys")
+
+	_, err = ParseOpenAPI([]byte(`{"swagger": "2.0"}`))
+	assert.ErrorContains(t, err, "Swagger 2.0")
+	_, err = ParseOpenAPI([]byte(`{"openapi": "2.1"}`))
+	assert.Error(t, err)
+}
+
+func TestOpenAPIDocument_InlineSchema(t *testing.T) {
+	doc, err := ParseOpenAPI([]byte(openAPIFixture))
+	require.NoError(t, err)
+
+	schema, err := doc.InlineSchema(map[string]interface{}{"$ref": "#/components/schemas/Order"})
+	require.NoError(t, err)
+	properties := schema["properties"].(map[string]interface{})
+	parent := properties["parent"].(map[string]interface{})
+	assert.Equal(t, "recursive reference to #/components/schemas/Order", parent["description"])
+
+	converted := OpenAPIToJSONSchema(schema)
+	note := converted["properties"].(map[string]interface{})["note"].(map[string]interface{})
+	assert.Equal(t, []interface{}{"string", "null"}, note["type"])
+	assert.NotContains(t, note, "nullable")
+	assert.NotContains(t, note, "example")
+
+	_, err = doc.InlineSchema(map[string]interface{}{"$ref": "#/components/schemas/Missing"})
+	assert.Error(t, err)
+}
+
+func TestOpenAPIRequest(t *testing.T) {
+	doc, err := ParseOpenAPI([]byte(openAPIFixture))
+	require.NoError(t, err)
+	operations, err := doc.Operations()
+	require.NoError(t, err)
+
+	var variables []importVariable
+	var warnings []string
+	warn := func(message string) { warnings = append(warnings, message) }
+
+	get := openAPIRequest(doc, operations[1], &variables, warn)
+	assert.Equal(t, "Get order", get.Name)
+	assert.Equal(t, "{{baseUrl}}/orders/{{id}}", get.URL)
+	assert.Equal(t, `{"Accept":"application/json","X-Tenant":"acme"}`, get.Headers)
+	assert.Empty(t, get.Auth, "operations without security inherit the collection auth")
+	params, err := ParseRequestParams(get.QueryParams)
+	require.NoError(t, err)
+	require.Len(t, params, 1)
+	assert.Equal(t, "items", params[0].Value)
+	assert.False(t, params[0].enabled(), "optional query params are disabled")
+	assert.Equal(t, []importVariable{{key: "id", value: "42"}}, variables)
+	assert.Equal(t, []string{`cookie parameter "session" was not imported`}, warnings)
+
+	post := openAPIRequest(doc, operations[0], &variables, warn)
+	assert.Equal(t, "createOrder", post.Name)
+	assert.Equal(t, BodyModeJSON, post.BodyMode)
+	assert.JSONEq(t, `{"type":"none"}`, post.Auth)
+	var body map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(post.Body), &body))
+	assert.Equal(t, "gift", body["note"], "property examples are used")
+	assert.NotContains(t, body, "id", "readOnly properties are left out of request bodies")
+
+	contracts, err := openAPIContracts(doc, operations[1])
+	require.NoError(t, err)
+	require.Len(t, contracts, 1, "responses without a JSON schema have no contract")
+	assert.Equal(t, "200", contracts[0].StatusCode)
+	assert.Empty(t, contracts[0].RequestSchema)
+
+	result, err := NewSchemaValidator().ValidateContract("", `{"id": 1, "note": null}`, ContractFromModel(&contracts[0]))
+	require.NoError(t, err)
+	assert.True(t, result.Valid)
+	result, err = NewSchemaValidator().ValidateContract("", `{"note": 3}`, ContractFromModel(&contracts[0]))
+	require.NoError(t, err)
+	assert.False(t, result.Valid)
+}
+
+func TestOpenAPIAuth(t *testing.T) {
+	doc, err := ParseOpenAPI([]byte(openAPIFixture))
+	require.NoError(t, err)
+
+	var variables []importVariable
+	auth := openAPIAuth(doc, doc.Root["security"], &variables, func(string) {})
+	assert.JSONEq(t, `{"type":"apikey","apikey":{"key":"X-Api-Key","value":"{{apiKey}}","in":"header"}}`, auth)
+	assert.Equal(t, []importVariable{{key: "apiKey"}}, variables)
+
+	var warnings []string
+	auth = openAPIAuth(doc, []interface{}{map[string]interface{}{"Missing": []interface{}{}}}, &variables, func(message string) { warnings = append(warnings, message) })
+	assert.Empty(t, auth)
+	assert.Len(t, warnings, 1)
+}
+
+func TestParseOpenAPI_RepositorySpec(t *testing.T) {
+	data, err := os.ReadFile("../../openapi.yaml")
+	if err != nil {
+		t.Skip("openapi.yaml not found")
+	}
+	doc, err := ParseOpenAPI(data)
+	require.NoError(t, err)
+	operations, err := doc.Operations()
+	require.NoError(t, err)
+	require.NotEmpty(t, operations)
+
+	for _, operation := range operations {
+		var variables []importVariable
+		request := openAPIRequest(doc, operation, &variables, func(string) {})
+		assert.NotEmpty(t, request.Name)
+		_, err := openAPIContracts(doc, operation)
+		assert.NoError(t, err, "%s %s", operation.Method, operation.Path)
+	}
+}
@@ -0,0 +1,785 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// OpenAPIExchange is an HTTP call to validate against an OpenAPI document.
+// Headers that are nil were not captured: header and cookie parameters,
+// required request bodies and response headers are then not checked.
+type OpenAPIExchange struct {
+	Method          string
+	URL             string // absolute, or a path with an optional query
+	RequestHeaders  http.Header
+	RequestBody     string
+	StatusCode      int // 0 when there is no response; only the request is checked
+	ResponseHeaders http.Header
+	ResponseBody    string
+}
+
+// OpenAPIValidation is the outcome of validating a call. Method and Path
+// name the operation the call matched, if any.
+type OpenAPIValidation struct {
+	Valid       bool              `json:"valid"`
+	Method      string            `json:"method,omitempty"`
+	Path        string            `json:"path,omitempty"`
+	OperationID string            `json:"operation_id,omitempty"`
+	Errors      []ValidationError `json:"errors"`
+}
+
+// OpenAPIValidator validates calls against the operations of an OpenAPI
+// document. The operation is found by method and path template, then its
+// parameters, request body, response status, headers and body are checked.
+// Errors carry a JSON pointer into the document and, for bodies, one into
+// the payload. It is safe for concurrent use.
+type OpenAPIValidator struct {
+	doc        *OpenAPIDocument
+	operations []OpenAPIOperation
+	basePaths  []string // path parts of the server URLs, longest first
+
+	mu      sync.Mutex
+	schemas map[string]*gojsonschema.Schema // compiled, by spec pointer
+}
+
+func NewOpenAPIValidator(doc *OpenAPIDocument) (*OpenAPIValidator, error) {
+	operations, err := doc.Operations()
+	if err != nil {
+		return nil, err
+	}
+
+	var basePaths []string
+	servers, _ := doc.Root["servers"].([]interface{})
+	for _, entry := range servers {
+		server, _ := entry.(map[string]interface{})
+		serverURL, _ := server["url"].(string)
+		if base := strings.TrimSuffix(contractURLPath(serverURL), "/"); base != "" {
+			basePaths = append(basePaths, base)
+		}
+	}
+	sort.Slice(basePaths, func(i, j int) bool { return len(basePaths[i]) > len(basePaths[j]) })
+
+	return &OpenAPIValidator{doc: doc, operations: operations, basePaths: basePaths, schemas: map[string]*gojsonschema.Schema{}}, nil
+}
+
+// Validate checks a call against the operation it matches. A call that
+// matches no operation is invalid. The error is only set when the document
+// itself cannot be used, such as for a broken $ref.
+func (v *OpenAPIValidator) Validate(exchange OpenAPIExchange) (*OpenAPIValidation, error) {
+	result := &OpenAPIValidation{Errors: []ValidationError{}}
+	method := strings.ToUpper(exchange.Method)
+	urlPath, query := contractURLPath(exchange.URL), url.Values{}
+	if parsed, err := url.Parse(exchange.URL); err == nil {
+		query = parsed.Query()
+	}
+
+	operation, pathParams, problem := v.match(method, urlPath)
+	if operation == nil {
+		result.Errors = append(result.Errors, *problem)
+		return result, nil
+	}
+	result.Method, result.Path = operation.Method, operation.Path
+	result.OperationID, _ = operation.Operation["operationId"].(string)
+	pointer := openAPIPointer("paths", operation.Path, strings.ToLower(operation.Method))
+
+	errs, err := v.checkParameters(pointer, operation.Path, pathParams, query, exchange.RequestHeaders)
+	if err != nil {
+		return nil, err
+	}
+	result.Errors = append(result.Errors, errs...)
+
+	if errs, err = v.checkRequestBody(pointer, operation.Operation, exchange); err != nil {
+		return nil, err
+	}
+	result.Errors = append(result.Errors, errs...)
+
+	if exchange.StatusCode != 0 {
+		if errs, err = v.checkResponse(pointer, operation.Operation, method, exchange); err != nil {
+			return nil, err
+		}
+		result.Errors = append(result.Errors, errs...)
+	}
+
+	result.Valid = len(result.Errors) == 0
+	return result, nil
+}
+
+// match finds the operation for a call. The URL path is tried as is and
+// with each server's base path removed; templates with more literal
+// segments win, so /orders/mine beats /orders/{id}. When nothing matches
+// exactly, a template matching the end of the path is accepted.
+func (v *OpenAPIValidator) match(method, urlPath string) (*OpenAPIOperation, map[string]string, *ValidationError) {
+	paths := []string{urlPath}
+	for _, base := range v.basePaths {
+		if stripped, ok := stripBasePath(urlPath, base); ok {
+			paths = append(paths, stripped)
+		}
+	}
+
+	for _, suffix := range []bool{false, true} {
+		var best *OpenAPIOperation
+		var bestParams map[string]string
+		bestLiteral, pathFound := -1, ""
+		for i := range v.operations {
+			operation := &v.operations[i]
+			for _, candidate := range paths {
+				params, literal, ok := matchPathTemplate(operation.Path, candidate, suffix)
+				if !ok {
+					continue
+				}
+				if operation.Method != method {
+					pathFound = operation.Path
+					continue
+				}
+				if literal > bestLiteral {
+					best, bestParams, bestLiteral = operation, params, literal
+				}
+			}
+		}
+		if best != nil {
+			return best, bestParams, nil
+		}
+		if pathFound != "" {
+			return nil, nil, &ValidationError{
+				In:          "request",
+				Field:       "method",
+				Type:        "method_not_allowed",
+				Description: fmt.Sprintf("%s is not an operation of %s", method, pathFound),
+				SpecPointer: openAPIPointer("paths", pathFound),
+			}
+		}
+	}
+	return nil, nil, &ValidationError{
+		In:          "request",
+		Field:       "path",
+		Type:        "unknown_path",
+		Description: fmt.Sprintf("no path matches %s", urlPath),
+		SpecPointer: "/paths",
+	}
+}
+
+// checkParameters checks path, query, header and cookie parameters. Values
+// arrive as strings and are converted to the schema's type first.
+func (v *OpenAPIValidator) checkParameters(pointer, pathTemplate string, pathParams map[string]string, query url.Values, headers http.Header) ([]ValidationError, error) {
+	parameters, err := v.operationParameters(pointer, openAPIPointer("paths", pathTemplate))
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []ValidationError
+	for _, entry := range parameters {
+		parameter := entry.object
+		name, _ := parameter["name"].(string)
+		in, _ := parameter["in"].(string)
+
+		var values []string
+		switch in {
+		case "path":
+			if value, ok := pathParams[name]; ok {
+				values = []string{value}
+			}
+		case "query":
+			values = query[name]
+		case "header":
+			if headers == nil || ignoredParameterHeader(name) {
+				continue
+			}
+			values = headerValues(headers, name)
+		case "cookie":
+			if headers == nil {
+				continue
+			}
+			request := http.Request{Header: http.Header{"Cookie": headerValues(headers, "Cookie")}}
+			if cookie, err := request.Cookie(name); err == nil {
+				values = []string{cookie.Value}
+			}
+		default:
+			continue
+		}
+
+		if len(values) == 0 {
+			if required, _ := parameter["required"].(bool); required || in == "path" {
+				errs = append(errs, ValidationError{
+					In:          in,
+					Field:       name,
+					Type:        "required",
+					Description: fmt.Sprintf("%s parameter %s is required", in, name),
+					SpecPointer: entry.pointer,
+				})
+			}
+			continue
+		}
+		if _, ok := parameter["schema"]; !ok {
+			continue
+		}
+		schemaErrs, err := v.validateValue(entry.pointer+"/schema", gojsonschema.NewGoLoader(v.coerce(parameter["schema"], values)), in, name)
+		if err != nil {
+			return nil, fmt.Errorf("parameter %s: %w", name, err)
+		}
+		errs = append(errs, schemaErrs...)
+	}
+	return errs, nil
+}
+
+type pointedObject struct {
+	pointer string
+	object  map[string]interface{}
+}
+
+// operationParameters lists an operation's parameters followed by the path
+// item's that it does not override, each with the pointer of its
+// definition.
+func (v *OpenAPIValidator) operationParameters(operationPointer, pathPointer string) ([]pointedObject, error) {
+	var parameters []pointedObject
+	seen := map[string]bool{}
+	for _, owner := range []string{operationPointer, pathPointer} {
+		node, _ := v.doc.Lookup("#" + owner + "/parameters")
+		list, _ := node.([]interface{})
+		for i := range list {
+			pointer, parameter, err := v.resolve(fmt.Sprintf("%s/parameters/%d", owner, i))
+			if err != nil {
+				return nil, err
+			}
+			if parameter == nil {
+				continue
+			}
+			key := fmt.Sprint(parameter["in"], " ", parameter["name"])
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			parameters = append(parameters, pointedObject{pointer, parameter})
+		}
+	}
+	return parameters, nil
+}
+
+func (v *OpenAPIValidator) checkRequestBody(operationPointer string, operation map[string]interface{}, exchange OpenAPIExchange) ([]ValidationError, error) {
+	if _, ok := operation["requestBody"]; !ok {
+		return nil, nil
+	}
+	pointer, requestBody, err := v.resolve(operationPointer + "/requestBody")
+	if err != nil || requestBody == nil {
+		return nil, err
+	}
+
+	if strings.TrimSpace(exchange.RequestBody) == "" {
+		if required, _ := requestBody["required"].(bool); required && exchange.RequestHeaders != nil {
+			return []ValidationError{{
+				In:          "request",
+				Field:       "(body)",
+				Type:        "required",
+				Description: "request body is required",
+				SpecPointer: pointer + "/required",
+			}}, nil
+		}
+		return nil, nil
+	}
+	return v.checkContent(pointer, requestBody, bodyMediaType(exchange.RequestHeaders, exchange.RequestBody), exchange.RequestBody, "request")
+}
+
+// checkResponse checks that the status is declared, then the declared
+// headers and the body. An exact status beats a range such as 2XX, which
+// beats default.
+func (v *OpenAPIValidator) checkResponse(operationPointer string, operation map[string]interface{}, method string, exchange OpenAPIExchange) ([]ValidationError, error) {
+	responses, _ := operation["responses"].(map[string]interface{})
+	status := strconv.Itoa(exchange.StatusCode)
+	key := ""
+	for _, candidate := range []string{status, status[:1] + "XX", status[:1] + "xx", "default"} {
+		if _, ok := responses[candidate]; ok {
+			key = candidate
+			break
+		}
+	}
+	if key == "" {
+		return []ValidationError{{
+			In:          "response",
+			Field:       "status",
+			Type:        "undeclared_status",
+			Description: fmt.Sprintf("status %d is not declared", exchange.StatusCode),
+			SpecPointer: operationPointer + "/responses",
+		}}, nil
+	}
+	pointer, response, err := v.resolve(operationPointer + "/responses/" + escapePointerToken(key))
+	if err != nil || response == nil {
+		return nil, err
+	}
+
+	var errs []ValidationError
+	declared, _ := response["headers"].(map[string]interface{})
+	if exchange.ResponseHeaders != nil {
+		for _, name := range sortedKeys(declared) {
+			if strings.EqualFold(name, "Content-Type") {
+				continue
+			}
+			headerPointer, header, err := v.resolve(pointer + "/headers/" + escapePointerToken(name))
+			if err != nil {
+				return nil, err
+			}
+			values := headerValues(exchange.ResponseHeaders, name)
+			if len(values) == 0 {
+				if required, _ := header["required"].(bool); required {
+					errs = append(errs, ValidationError{
+						In:          "response_header",
+						Field:       name,
+						Type:        "required",
+						Description: fmt.Sprintf("response header %s is required", name),
+						SpecPointer: headerPointer + "/required",
+					})
+				}
+				continue
+			}
+			if _, ok := header["schema"]; !ok {
+				continue
+			}
+			headerErrs, err := v.validateValue(headerPointer+"/schema", gojsonschema.NewGoLoader(v.coerce(header["schema"], values)), "response_header", name)
+			if err != nil {
+				return nil, fmt.Errorf("response header %s: %w", name, err)
+			}
+			errs = append(errs, headerErrs...)
+		}
+	}
+
+	content, _ := response["content"].(map[string]interface{})
+	if strings.TrimSpace(exchange.ResponseBody) == "" {
+		noBody := method == http.MethodHead || exchange.StatusCode == http.StatusNoContent || exchange.StatusCode == http.StatusNotModified
+		if len(content) > 0 && !noBody {
+			errs = append(errs, ValidationError{
+				In:          "response",
+				Field:       "(body)",
+				Type:        "required",
+				Description: "response has no body",
+				SpecPointer: pointer + "/content",
+			})
+		}
+		return errs, nil
+	}
+	bodyErrs, err := v.checkContent(pointer, response, bodyMediaType(exchange.ResponseHeaders, exchange.ResponseBody), exchange.ResponseBody, "response")
+	return append(errs, bodyErrs...), err
+}
+
+// checkContent validates a body against the content of a request body or
+// response object. JSON and form bodies are validated against the media
+// type's schema, other bodies only when the schema is a string.
+func (v *OpenAPIValidator) checkContent(pointer string, object map[string]interface{}, contentType, body, in string) ([]ValidationError, error) {
+	content, _ := object["content"].(map[string]interface{})
+	if len(content) == 0 {
+		return nil, nil
+	}
+	mediaType := matchMediaType(content, contentType)
+	if mediaType == "" {
+		if contentType == "" {
+			return nil, nil
+		}
+		return []ValidationError{{
+			In:          in,
+			Field:       "(body)",
+			Type:        "unsupported_media_type",
+			Description: fmt.Sprintf("%s content type %s is not declared", in, contentType),
+			SpecPointer: pointer + "/content",
+		}}, nil
+	}
+	mediaPointer := pointer + "/content/" + escapePointerToken(mediaType)
+	media, _ := content[mediaType].(map[string]interface{})
+	if _, ok := media["schema"]; !ok {
+		return nil, nil
+	}
+	schemaPointer := mediaPointer + "/schema"
+
+	if contentType == "" {
+		contentType = mediaType
+	}
+	switch {
+	case isJSONMediaType(contentType):
+		if !json.Valid([]byte(body)) {
+			return []ValidationError{{
+				In:          in,
+				Field:       "(body)",
+				Type:        "invalid_json",
+				Description: in + " body is not JSON",
+				SpecPointer: mediaPointer,
+			}}, nil
+		}
+		return v.validateValue(schemaPointer, gojsonschema.NewStringLoader(body), in, "")
+	case contentType == "application/x-www-form-urlencoded":
+		values, err := url.ParseQuery(body)
+		if err != nil {
+			return []ValidationError{{In: in, Field: "(body)", Type: "invalid_form", Description: err.Error(), SpecPointer: mediaPointer}}, nil
+		}
+		schema, _ := v.doc.Resolve(media["schema"])
+		properties, _ := schema["properties"].(map[string]interface{})
+		form := map[string]interface{}{}
+		for key, list := range values {
+			form[key] = v.coerce(properties[key], list)
+		}
+		return v.validateValue(schemaPointer, gojsonschema.NewGoLoader(form), in, "")
+	default:
+		schema, _ := v.doc.Resolve(media["schema"])
+		if schemaType(schema) != "string" {
+			return nil, nil
+		}
+		return v.validateValue(schemaPointer, gojsonschema.NewGoLoader(body), in, "")
+	}
+}
+
+// validateValue validates a value against the schema at pointer. For
+// parameters and headers, field is their name; for bodies it is empty and
+// errors also point into the payload.
+func (v *OpenAPIValidator) validateValue(pointer string, value gojsonschema.JSONLoader, in, field string) ([]ValidationError, error) {
+	schema, err := v.compiled(pointer)
+	if err != nil {
+		return nil, err
+	}
+	result, err := schema.Validate(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []ValidationError
+	for _, resultErr := range result.Errors() {
+		path := contextTokens(resultErr.Context())
+		validationErr := ValidationError{
+			In:          in,
+			Field:       resultErr.Field(),
+			Type:        resultErr.Type(),
+			Description: resultErr.Description(),
+			SpecPointer: v.schemaPointer(pointer, path, schemaKeywords[resultErr.Type()]),
+		}
+		if field != "" {
+			validationErr.Field = strings.Join(append([]string{field}, path...), ".")
+		} else {
+			validationErr.DataPointer = jsonPointer(path)
+		}
+		errs = append(errs, validationErr)
+	}
+	// The validator reports in no particular order
+	sort.SliceStable(errs, func(i, j int) bool {
+		if errs[i].Field != errs[j].Field {
+			return errs[i].Field < errs[j].Field
+		}
+		return errs[i].Type < errs[j].Type
+	})
+	return errs, nil
+}
+
+// compiled returns the schema at a spec pointer as compiled JSON Schema,
+// with references inlined and OpenAPI 3.0 keywords converted.
+func (v *OpenAPIValidator) compiled(pointer string) (*gojsonschema.Schema, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if schema, ok := v.schemas[pointer]; ok {
+		return schema, nil
+	}
+
+	node, err := v.doc.Lookup("#" + pointer)
+	if err != nil {
+		return nil, err
+	}
+	inlined, err := v.doc.InlineSchema(node)
+	if err != nil {
+		return nil, err
+	}
+	if inlined == nil {
+		inlined = map[string]interface{}{}
+	}
+	schema, err := gojsonschema.NewSchema(gojsonschema.NewGoLoader(OpenAPIToJSONSchema(inlined)))
+	if err != nil {
+		return nil, fmt.Errorf("schema %s: %w", pointer, err)
+	}
+	v.schemas[pointer] = schema
+	return schema, nil
+}
+
+// schemaPointer follows a path into a payload through the schema at
+// pointer, across $refs, and returns the pointer of the subschema that
+// validates it, with keyword appended when the subschema has it. The walk
+// stops at the deepest subschema it can tell apart, such as at a oneOf.
+func (v *OpenAPIValidator) schemaPointer(pointer string, path []string, keyword string) string {
+	pointer, schema, err := v.resolve(pointer)
+	if err != nil {
+		return pointer
+	}
+	for _, token := range path {
+		properties, _ := schema["properties"].(map[string]interface{})
+		switch {
+		case properties[token] != nil:
+			pointer += "/properties/" + escapePointerToken(token)
+		case schema["items"] != nil && isArrayIndex(token):
+			pointer += "/items"
+		case isSchemaObject(schema["additionalProperties"]):
+			pointer += "/additionalProperties"
+		default:
+			return pointer
+		}
+		if pointer, schema, err = v.resolve(pointer); err != nil || schema == nil {
+			return pointer
+		}
+	}
+	if _, ok := schema[keyword]; ok && keyword != "" {
+		pointer += "/" + keyword
+	}
+	return pointer
+}
+
+// resolve looks up the object at a spec pointer, following $refs, and
+// returns it with the pointer of where it is defined.
+func (v *OpenAPIValidator) resolve(pointer string) (string, map[string]interface{}, error) {
+	for depth := 0; depth < 32; depth++ {
+		node, err := v.doc.Lookup("#" + pointer)
+		if err != nil {
+			return pointer, nil, err
+		}
+		object, _ := node.(map[string]interface{})
+		ref, ok := object["$ref"].(string)
+		if !ok {
+			return pointer, object, nil
+		}
+		if !strings.HasPrefix(ref, "#") {
+			return pointer, nil, fmt.Errorf("external reference %s is not supported", ref)
+		}
+		pointer = strings.TrimPrefix(ref, "#")
+	}
+	return pointer, nil, errors.New("reference chain is too deep")
+}
+
+// coerce converts parameter or header strings to the type their schema
+// expects. Values that do not convert are left as strings for the schema
+// to reject. Arrays take repeated values or a comma-separated list.
+func (v *OpenAPIValidator) coerce(schemaNode interface{}, values []string) interface{} {
+	if len(values) == 0 {
+		return ""
+	}
+	schema, _ := v.doc.Resolve(schemaNode)
+	switch schemaType(schema) {
+	case "array":
+		if len(values) == 1 {
+			values = strings.Split(values[0], ",")
+		}
+		items := make([]interface{}, len(values))
+		for i, value := range values {
+			items[i] = v.coerce(schema["items"], []string{value})
+		}
+		return items
+	case "integer", "number":
+		if number, err := strconv.ParseFloat(values[0], 64); err == nil {
+			return number
+		}
+	case "boolean":
+		if boolean, err := strconv.ParseBool(values[0]); err == nil {
+			return boolean
+		}
+	}
+	return values[0]
+}
+
+// schemaKeywords maps validator error types to the schema keyword that
+// raised them.
+var schemaKeywords = map[string]string{
+	"required":                        "required",
+	"invalid_type":                    "type",
+	"number_any_of":                   "anyOf",
+	"number_one_of":                   "oneOf",
+	"number_all_of":                   "allOf",
+	"number_not":                      "not",
+	"const":                           "const",
+	"enum":                            "enum",
+	"array_min_items":                 "minItems",
+	"array_max_items":                 "maxItems",
+	"unique":                          "uniqueItems",
+	"contains":                        "contains",
+	"array_min_properties":            "minProperties",
+	"array_max_properties":            "maxProperties",
+	"additional_property_not_allowed": "additionalProperties",
+	"string_gte":                      "minLength",
+	"string_lte":                      "maxLength",
+	"pattern":                         "pattern",
+	"format":                          "format",
+	"multiple_of":                     "multipleOf",
+	"number_gte":                      "minimum",
+	"number_gt":                       "exclusiveMinimum",
+	"number_lte":                      "maximum",
+	"number_lt":                       "exclusiveMaximum",
+}
+
+// matchPathTemplate matches a URL path against a path template and returns
+// the path parameters and the number of literal segments. With suffix, the
+// template only has to match the end of the path.
+func matchPathTemplate(template, urlPath string, suffix bool) (map[string]string, int, bool) {
+	templateSegments := strings.Split(strings.Trim(template, "/"), "/")
+	pathSegments := strings.Split(strings.Trim(urlPath, "/"), "/")
+	if len(pathSegments) < len(templateSegments) || (!suffix && len(pathSegments) != len(templateSegments)) {
+		return nil, 0, false
+	}
+	pathSegments = pathSegments[len(pathSegments)-len(templateSegments):]
+
+	params, literal := map[string]string{}, 0
+	for i, segment := range templateSegments {
+		names := openAPIPathParam.FindAllStringSubmatch(segment, -1)
+		if len(names) == 0 {
+			if segment != pathSegments[i] {
+				return nil, 0, false
+			}
+			literal++
+			continue
+		}
+		values := templateSegmentPattern(segment).FindStringSubmatch(pathSegments[i])
+		if values == nil {
+			return nil, 0, false
+		}
+		for j, name := range names {
+			value, err := url.PathUnescape(values[j+1])
+			if err != nil {
+				value = values[j+1]
+			}
+			params[name[1]] = value
+		}
+	}
+	return params, literal, true
+}
+
+// stripBasePath removes a server's base path, which may contain {variable}
+// segments, from the start of a URL path.
+func stripBasePath(urlPath, base string) (string, bool) {
+	baseSegments := strings.Split(strings.Trim(base, "/"), "/")
+	pathSegments := strings.Split(strings.Trim(urlPath, "/"), "/")
+	if len(pathSegments) < len(baseSegments) {
+		return "", false
+	}
+	for i, segment := range baseSegments {
+		if !templateSegmentPattern(segment).MatchString(pathSegments[i]) {
+			return "", false
+		}
+	}
+	return "/" + strings.Join(pathSegments[len(baseSegments):], "/"), true
+}
+
+// matchMediaType picks the content entry for a media type: an exact match,
+// then type/*, then */*. Without a media type, JSON content or the only
+// entry is used.
+func matchMediaType(content map[string]interface{}, contentType string) string {
+	if contentType == "" {
+		if mediaType := jsonMediaType(content); mediaType != "" {
+			return mediaType
+		}
+		if len(content) == 1 {
+			return sortedKeys(content)[0]
+		}
+		return ""
+	}
+	major, _, _ := strings.Cut(contentType, "/")
+	for _, candidate := range []string{contentType, major + "/*", "*/*"} {
+		for _, mediaType := range sortedKeys(content) {
+			parsed, _, err := mime.ParseMediaType(mediaType)
+			if err != nil {
+				parsed = strings.ToLower(mediaType)
+			}
+			if parsed == candidate {
+				return mediaType
+			}
+		}
+	}
+	return ""
+}
+
+// bodyMediaType returns the media type of a body from its Content-Type
+// header, or application/json for a JSON body sent without one.
+func bodyMediaType(headers http.Header, body string) string {
+	if values := headerValues(headers, "Content-Type"); len(values) > 0 {
+		if mediaType, _, err := mime.ParseMediaType(values[0]); err == nil {
+			return mediaType
+		}
+	}
+	if headers == nil && json.Valid([]byte(body)) {
+		return "application/json"
+	}
+	return ""
+}
+
+func isJSONMediaType(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// ignoredParameterHeader reports whether a header parameter is one OpenAPI
+// says to ignore, as it is described elsewhere in the document.
+func ignoredParameterHeader(name string) bool {
+	switch http.CanonicalHeaderKey(name) {
+	case "Accept", "Content-Type", "Authorization":
+		return true
+	}
+	return false
+}
+
+// headerValues returns a header's values whatever the case of its key.
+func headerValues(headers http.Header, name string) []string {
+	var values []string
+	for key, list := range headers {
+		if strings.EqualFold(key, name) {
+			values = append(values, list...)
+		}
+	}
+	return values
+}
+
+func schemaType(schema map[string]interface{}) string {
+	switch value := schema["type"].(type) {
+	case string:
+		return value
+	case []interface{}:
+		for _, entry := range value {
+			if name, ok := entry.(string); ok && name != "null" {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+func isSchemaObject(node interface{}) bool {
+	_, ok := node.(map[string]interface{})
+	return ok
+}
+
+var arrayIndexPattern = regexp.MustCompile(`^(0|[1-9][0-9]*)$`)
+
+func isArrayIndex(token string) bool {
+	return arrayIndexPattern.MatchString(token)
+}
+
+// openAPIPointer builds a JSON pointer from unescaped tokens.
+func openAPIPointer(tokens ...string) string {
+	return jsonPointer(tokens)
+}
+
+func jsonPointer(tokens []string) string {
+	var pointer strings.Builder
+	for _, token := range tokens {
+		pointer.WriteString("/")
+		pointer.WriteString(escapePointerToken(token))
+	}
+	return pointer.String()
+}
+
+func escapePointerToken(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
+
+// contextTokens returns the path of a validator error context below the
+// root, e.g. [items 0 id] for (root).items.0.id.
+func contextTokens(context *gojsonschema.JsonContext) []string {
+	if context == nil {
+		return nil
+	}
+	tokens := strings.Split(context.String("\x00"), "\x00")
+	return tokens[1:]
+}
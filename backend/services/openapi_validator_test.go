@@ -0,0 +1,253 @@
+package services
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const validatorFixture = `
+openapi: 3.0.3
+info: {title: Shop, version: 1.0.0}
+servers:
+  - url: https://shop.test/api/{version}
+paths:
+  /orders/mine:
+    get:
+      responses:
+        200: {description: My orders}
+  /orders/{id}:
+    parameters:
+      - $ref: '#/components/parameters/OrderID'
+    get:
+      operationId: getOrder
+      parameters:
+        - name: expand
+          in: query
+          schema: {type: array, items: {type: string, enum: [items, customer]}}
+        - name: X-Tenant
+          in: header
+          required: true
+          schema: {type: string}
+      responses:
+        200:
+          description: The order
+          headers:
+            X-Rate-Limit:
+              required: true
+              schema: {type: integer}
+          content:
+            application/json:
+              schema: {$ref: '#/components/schemas/Order'}
+        4XX:
+          $ref: '#/components/responses/Problem'
+  /orders:
+    post:
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema: {$ref: '#/components/schemas/Order'}
+          application/x-www-form-urlencoded:
+            schema:
+              type: object
+              required: [qty]
+              properties:
+                qty: {type: integer, minimum: 1}
+      responses:
+        201: {description: Created}
+components:
+  parameters:
+    OrderID:
+      name: id
+      in: path
+      required: true
+      schema: {type: integer, minimum: 1}
+  responses:
+    Problem:
+      description: Problem
+      content:
+        application/problem+json:
+          schema:
+            type: object
+            required: [title]
+  schemas:
+    Order:
+      type: object
+      required: [id, lines]
+      properties:
+        id: {type: integer}
+        note: {type: string, nullable: true}
+        lines:
+          type: array
+          items: {$ref: '#/components/schemas/Line'}
+    Line:
+      type: object
+      properties:
+        qty: {type: integer, minimum: 1}
+`
+
+func newTestValidator(t *testing.T) *OpenAPIValidator {
+	doc, err := ParseOpenAPI([]byte(validatorFixture))
+	require.NoError(t, err)
+	validator, err := NewOpenAPIValidator(doc)
+	require.NoError(t, err)
+	return validator
+}
+
+func validGetOrder() OpenAPIExchange {
+	return OpenAPIExchange{
+		Method:          "GET",
+		URL:             "https://shop.test/api/v2/orders/42?expand=items,customer",
+		RequestHeaders:  http.Header{"x-tenant": {"acme"}},
+		StatusCode:      200,
+		ResponseHeaders: http.Header{"Content-Type": {"application/json"}, "X-Rate-Limit": {"100"}},
+		ResponseBody:    `{"id": 42, "note": null, "lines": [{"qty": 1}]}`,
+	}
+}
+
+func TestOpenAPIValidator_Valid(t *testing.T) {
+	validator := newTestValidator(t)
+	result, err := validator.Validate(validGetOrder())
+	require.NoError(t, err)
+	assert.True(t, result.Valid, "%v", result.Errors)
+	assert.Equal(t, "/orders/{id}", result.Path)
+	assert.Equal(t, "getOrder", result.OperationID)
+
+	result, err = validator.Validate(OpenAPIExchange{Method: "GET", URL: "/api/v1/orders/mine", StatusCode: 200})
+	require.NoError(t, err)
+	assert.Equal(t, "/orders/mine", result.Path, "literal segments beat templates")
+
+	result, err = validator.Validate(OpenAPIExchange{Method: "POST", URL: "/orders", RequestHeaders: http.Header{"Content-Type": {"application/x-www-form-urlencoded"}}, RequestBody: "qty=2"})
+	require.NoError(t, err)
+	assert.True(t, result.Valid, "%v", result.Errors)
+}
+
+func TestOpenAPIValidator_Parameters(t *testing.T) {
+	validator := newTestValidator(t)
+	exchange := validGetOrder()
+	exchange.URL = "/orders/0?expand=items,shipping"
+	exchange.RequestHeaders = http.Header{}
+
+	result, err := validator.Validate(exchange)
+	require.NoError(t, err)
+	require.Len(t, result.Errors, 3)
+	byIn := map[string]ValidationError{}
+	for _, validationErr := range result.Errors {
+		byIn[validationErr.In] = validationErr
+	}
+	assert.Equal(t, "/components/parameters/OrderID/schema/minimum", byIn["path"].SpecPointer, "referenced parameters point at their definition")
+	assert.Equal(t, "expand.1", byIn["query"].Field)
+	assert.Equal(t, "/paths/~1orders~1{id}/get/parameters/0/schema/items/enum", byIn["query"].SpecPointer)
+	assert.Equal(t, "required", byIn["header"].Type)
+
+	exchange.RequestHeaders = nil
+	exchange.URL = "/orders/abc"
+	result, err = validator.Validate(exchange)
+	require.NoError(t, err)
+	require.Len(t, result.Errors, 1, "headers that were not captured are not checked")
+	assert.Equal(t, "invalid_type", result.Errors[0].Type)
+}
+
+func TestOpenAPIValidator_Bodies(t *testing.T) {
+	validator := newTestValidator(t)
+	exchange := validGetOrder()
+	exchange.ResponseBody = `{"id": "42", "lines": [{"qty": 1}, {"qty": 0}]}`
+
+	result, err := validator.Validate(exchange)
+	require.NoError(t, err)
+	require.Len(t, result.Errors, 2)
+	assert.Equal(t, ValidationError{
+		In:          "response",
+		Field:       "id",
+		Type:        "invalid_type",
+		Description: result.Errors[0].Description,
+		SpecPointer: "/components/schemas/Order/properties/id/type",
+		DataPointer: "/id",
+	}, result.Errors[0])
+	assert.Equal(t, "/components/schemas/Line/properties/qty/minimum", result.Errors[1].SpecPointer, "pointers follow $refs")
+	assert.Equal(t, "/lines/1/qty", result.Errors[1].DataPointer)
+
+	exchange.ResponseBody = `{"lines": []}`
+	result, err = validator.Validate(exchange)
+	require.NoError(t, err)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, "/components/schemas/Order/required", result.Errors[0].SpecPointer)
+
+	post := OpenAPIExchange{Method: "POST", URL: "/orders", RequestHeaders: http.Header{"Content-Type": {"text/plain"}}, RequestBody: "hi"}
+	result, err = validator.Validate(post)
+	require.NoError(t, err)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, "unsupported_media_type", result.Errors[0].Type)
+
+	post.RequestBody = ""
+	result, err = validator.Validate(post)
+	require.NoError(t, err)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, "/paths/~1orders/post/requestBody/required", result.Errors[0].SpecPointer)
+
+	post.RequestHeaders = http.Header{"Content-Type": {"application/x-www-form-urlencoded"}}
+	post.RequestBody = "qty=0"
+	result, err = validator.Validate(post)
+	require.NoError(t, err)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, "/qty", result.Errors[0].DataPointer)
+}
+
+func TestOpenAPIValidator_Responses(t *testing.T) {
+	validator := newTestValidator(t)
+	exchange := validGetOrder()
+	exchange.ResponseHeaders = http.Header{"X-Rate-Limit": {"lots"}}
+	result, err := validator.Validate(exchange)
+	require.NoError(t, err)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, "response_header", result.Errors[0].In)
+	assert.Equal(t, "/paths/~1orders~1{id}/get/responses/200/headers/X-Rate-Limit/schema/type", result.Errors[0].SpecPointer)
+
+	exchange = validGetOrder()
+	exchange.StatusCode = 404
+	exchange.ResponseHeaders = http.Header{"Content-Type": {"application/problem+json"}}
+	exchange.ResponseBody = `{"detail": "gone"}`
+	result, err = validator.Validate(exchange)
+	require.NoError(t, err)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, "/components/responses/Problem/content/application~1problem+json/schema/required", result.Errors[0].SpecPointer, "status ranges and referenced responses are used")
+
+	exchange.StatusCode = 500
+	result, err = validator.Validate(exchange)
+	require.NoError(t, err)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, "undeclared_status", result.Errors[0].Type)
+	assert.Equal(t, "/paths/~1orders~1{id}/get/responses", result.Errors[0].SpecPointer)
+}
+
+func TestOpenAPIValidator_Unmatched(t *testing.T) {
+	validator := newTestValidator(t)
+	result, err := validator.Validate(OpenAPIExchange{Method: "DELETE", URL: "/orders/42"})
+	require.NoError(t, err)
+	assert.False(t, result.Valid)
+	assert.Equal(t, "method_not_allowed", result.Errors[0].Type)
+	assert.Equal(t, "/paths/~1orders~1{id}", result.Errors[0].SpecPointer)
+
+	result, err = validator.Validate(OpenAPIExchange{Method: "GET", URL: "/customers"})
+	require.NoError(t, err)
+	assert.Equal(t, "unknown_path", result.Errors[0].Type)
+}
+
+func TestMatchPathTemplate(t *testing.T) {
+	params, literal, ok := matchPathTemplate("/files/{name}.{ext}", "/files/report%20q1.csv", false)
+	require.True(t, ok)
+	assert.Equal(t, map[string]string{"name": "report q1", "ext": "csv"}, params)
+	assert.Equal(t, 1, literal)
+
+	_, _, ok = matchPathTemplate("/orders/{id}", "/v1/orders/42", false)
+	assert.False(t, ok)
+	_, _, ok = matchPathTemplate("/orders/{id}", "/v1/orders/42", true)
+	assert.True(t, ok)
+
+	stripped, ok := stripBasePath("/api/v3/orders", "/api/{version}")
+	assert.True(t, ok)
+	assert.Equal(t, "/orders", stripped)
+}
@@ -0,0 +1,961 @@
+package services
+
+import (
+	"backend/models"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// pactSpecificationVersion is the version of the Pact files generated from
+// traces.
+const pactSpecificationVersion = "3.0.0"
+
+// PactFile is a Pact contract file: the interactions a consumer expects a
+// provider to honour. Versions 2 and 3 of the specification are read;
+// version 2 provider states and matching rules are converted to version 3.
+type PactFile struct {
+	Consumer     PactParticipant        `json:"consumer"`
+	Provider     PactParticipant        `json:"provider"`
+	Interactions []PactInteraction      `json:"interactions"`
+	Metadata     map[string]interface{} `json:"metadata,omitempty"`
+}
+
+type PactParticipant struct {
+	Name string `json:"name"`
+}
+
+type PactInteraction struct {
+	Description    string              `json:"description"`
+	ProviderState  string              `json:"providerState,omitempty"` // version 2, moved to ProviderStates on parse
+	ProviderStates []PactProviderState `json:"providerStates,omitempty"`
+	Request        PactRequest         `json:"request"`
+	Response       PactResponse        `json:"response"`
+}
+
+// PactProviderState is a state the provider is put in before an
+// interaction is replayed, e.g. "order 42 exists".
+type PactProviderState struct {
+	Name   string                 `json:"name"`
+	Params map[string]interface{} `json:"params,omitempty"`
+}
+
+type PactRequest struct {
+	Method        string             `json:"method"`
+	Path          string             `json:"path"`
+	Query         PactQuery          `json:"query,omitempty"`
+	Headers       PactHeaders        `json:"headers,omitempty"`
+	Body          json.RawMessage    `json:"body,omitempty"`
+	MatchingRules *PactMatchingRules `json:"matchingRules,omitempty"`
+}
+
+type PactResponse struct {
+	Status        int                `json:"status"`
+	Headers       PactHeaders        `json:"headers,omitempty"`
+	Body          json.RawMessage    `json:"body,omitempty"`
+	MatchingRules *PactMatchingRules `json:"matchingRules,omitempty"`
+}
+
+// PactQuery holds query params. Version 3 files give a map of value lists,
+// version 2 files a query string.
+type PactQuery map[string][]string
+
+func (q *PactQuery) UnmarshalJSON(data []byte) error {
+	var text string
+	if json.Unmarshal(data, &text) == nil {
+		values, err := url.ParseQuery(text)
+		if err != nil {
+			return fmt.Errorf("invalid query: %w", err)
+		}
+		*q = PactQuery(values)
+		return nil
+	}
+	var object map[string]interface{}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return fmt.Errorf("invalid query: %w", err)
+	}
+	*q = PactQuery{}
+	for key, value := range object {
+		(*q)[key] = pactStrings(value)
+	}
+	return nil
+}
+
+// PactHeaders holds headers by name. Headers given as a list of values are
+// joined with commas.
+type PactHeaders map[string]string
+
+func (h *PactHeaders) UnmarshalJSON(data []byte) error {
+	var object map[string]interface{}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return fmt.Errorf("invalid headers: %w", err)
+	}
+	*h = PactHeaders{}
+	for key, value := range object {
+		(*h)[key] = strings.Join(pactStrings(value), ", ")
+	}
+	return nil
+}
+
+func (h PactHeaders) names() []string {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func pactStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case string:
+		return []string{v}
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			values = append(values, pactStrings(item)...)
+		}
+		return values
+	default:
+		return []string{fmt.Sprint(v)}
+	}
+}
+
+// PactMatchingRules loosen the comparison of parts of a request or
+// response, e.g. to accept any integer where the example has 42. Body
+// rules are keyed by a JSON path such as $.items[*].id.
+type PactMatchingRules struct {
+	Path   *PactRuleSet           `json:"path,omitempty"`
+	Query  map[string]PactRuleSet `json:"query,omitempty"`
+	Header map[string]PactRuleSet `json:"header,omitempty"`
+	Body   map[string]PactRuleSet `json:"body,omitempty"`
+}
+
+// UnmarshalJSON reads version 3 rules, or version 2 rules keyed by paths
+// such as $.body.id and $.headers.Accept.
+func (r *PactMatchingRules) UnmarshalJSON(data []byte) error {
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return fmt.Errorf("invalid matchingRules: %w", err)
+	}
+	v2 := false
+	for key := range keys {
+		if strings.HasPrefix(key, "$") {
+			v2 = true
+			break
+		}
+	}
+	if !v2 {
+		type plain PactMatchingRules
+		if err := json.Unmarshal(data, (*plain)(r)); err != nil {
+			return fmt.Errorf("invalid matchingRules: %w", err)
+		}
+		return nil
+	}
+
+	*r = PactMatchingRules{}
+	for key, raw := range keys {
+		var matcher PactMatcher
+		if err := json.Unmarshal(raw, &matcher); err != nil {
+			return fmt.Errorf("invalid matching rule %s: %w", key, err)
+		}
+		set := PactRuleSet{Matchers: []PactMatcher{matcher}}
+		switch {
+		case key == "$.body" || strings.HasPrefix(key, "$.body.") || strings.HasPrefix(key, "$.body["):
+			if r.Body == nil {
+				r.Body = map[string]PactRuleSet{}
+			}
+			r.Body["$"+strings.TrimPrefix(key, "$.body")] = set
+		case strings.HasPrefix(key, "$.headers."):
+			if r.Header == nil {
+				r.Header = map[string]PactRuleSet{}
+			}
+			r.Header[strings.TrimPrefix(key, "$.headers.")] = set
+		case strings.HasPrefix(key, "$.query."):
+			if r.Query == nil {
+				r.Query = map[string]PactRuleSet{}
+			}
+			r.Query[strings.TrimPrefix(key, "$.query.")] = set
+		case key == "$.path":
+			r.Path = &set
+		}
+	}
+	return nil
+}
+
+// PactRuleSet is the matchers for one location. They must all pass, or
+// with Combine OR any of them.
+type PactRuleSet struct {
+	Matchers []PactMatcher `json:"matchers"`
+	Combine  string        `json:"combine,omitempty"` // AND (default) or OR
+}
+
+// PactMatcher is one matching rule. Date, time and timestamp matchers
+// check for a string; their formats are not checked.
+type PactMatcher struct {
+	Match  string `json:"match"` // type, regex, integer, decimal, number, boolean, null, equality, include, values, date, time, timestamp
+	Regex  string `json:"regex,omitempty"`
+	Min    *int   `json:"min,omitempty"`
+	Max    *int   `json:"max,omitempty"`
+	Value  string `json:"value,omitempty"`  // include
+	Format string `json:"format,omitempty"` // date, time, timestamp
+}
+
+// kind is the matcher's type, reading the version 2 min and max rules as
+// type rules.
+func (m PactMatcher) kind() string {
+	if m.Match == "" && (m.Min != nil || m.Max != nil) {
+		return "type"
+	}
+	return m.Match
+}
+
+// ParsePact reads a Pact file and checks that it has what verification
+// needs.
+func ParsePact(data []byte) (*PactFile, error) {
+	var pact PactFile
+	if err := json.Unmarshal(data, &pact); err != nil {
+		return nil, fmt.Errorf("invalid pact: %w", err)
+	}
+	if version := pactSpecVersion(&pact); version != "" {
+		if major, _, _ := strings.Cut(version, "."); major != "1" && major != "2" && major != "3" {
+			return nil, fmt.Errorf("pact specification %s is not supported, only versions up to 3", version)
+		}
+	}
+	if pact.Consumer.Name == "" || pact.Provider.Name == "" {
+		return nil, errors.New("pact must name its consumer and provider")
+	}
+	if len(pact.Interactions) == 0 {
+		return nil, errors.New("pact has no interactions")
+	}
+
+	for i := range pact.Interactions {
+		interaction := &pact.Interactions[i]
+		if interaction.Description == "" {
+			return nil, fmt.Errorf("interaction %d has no description", i+1)
+		}
+		if interaction.ProviderState != "" {
+			interaction.ProviderStates = append([]PactProviderState{{Name: interaction.ProviderState}}, interaction.ProviderStates...)
+			interaction.ProviderState = ""
+		}
+		interaction.Request.Method = strings.ToUpper(interaction.Request.Method)
+		if interaction.Request.Method == "" || !strings.HasPrefix(interaction.Request.Path, "/") {
+			return nil, fmt.Errorf("interaction %q needs a request method and a path starting with /", interaction.Description)
+		}
+		if interaction.Response.Status == 0 {
+			return nil, fmt.Errorf("interaction %q has no response status", interaction.Description)
+		}
+		for key, set := range bodyRules(interaction.Response.MatchingRules) {
+			if _, err := parsePactPath(key); err != nil {
+				return nil, fmt.Errorf("interaction %q: %w", interaction.Description, err)
+			}
+			for _, matcher := range set.Matchers {
+				if matcher.kind() == "regex" {
+					if _, err := regexp.Compile(matcher.Regex); err != nil {
+						return nil, fmt.Errorf("interaction %q: invalid regex for %s: %w", interaction.Description, key, err)
+					}
+				}
+			}
+		}
+	}
+	return &pact, nil
+}
+
+// pactSpecVersion reads the specification version from the metadata, under
+// either of the keys Pact implementations have used.
+func pactSpecVersion(pact *PactFile) string {
+	for _, key := range []string{"pactSpecification", "pact-specification"} {
+		if spec, ok := pact.Metadata[key].(map[string]interface{}); ok {
+			if version, ok := spec["version"].(string); ok {
+				return version
+			}
+		}
+	}
+	return ""
+}
+
+func bodyRules(rules *PactMatchingRules) map[string]PactRuleSet {
+	if rules == nil {
+		return nil
+	}
+	return rules.Body
+}
+
+// PactMismatch is one way a provider's response differed from what an
+// interaction expects.
+type PactMismatch struct {
+	Type     string      `json:"type"`           // status, header, body
+	Path     string      `json:"path,omitempty"` // the header name, or a JSON path into the body
+	Expected interface{} `json:"expected,omitempty"`
+	Actual   interface{} `json:"actual,omitempty"`
+	Message  string      `json:"message"`
+}
+
+// matchPactResponse compares a provider's response with what an interaction
+// expects. Headers and body fields the interaction does not mention are
+// ignored.
+func matchPactResponse(expected PactResponse, status int, headers http.Header, body string) []PactMismatch {
+	var mismatches []PactMismatch
+	if status != expected.Status {
+		mismatches = append(mismatches, PactMismatch{
+			Type:     "status",
+			Expected: expected.Status,
+			Actual:   status,
+			Message:  fmt.Sprintf("expected status %d but got %d", expected.Status, status),
+		})
+	}
+
+	var headerRules map[string]PactRuleSet
+	if expected.MatchingRules != nil {
+		headerRules = expected.MatchingRules.Header
+	}
+	for _, name := range expected.Headers.names() {
+		want := expected.Headers[name]
+		values := headerValues(headers, name)
+		if len(values) == 0 {
+			mismatches = append(mismatches, PactMismatch{Type: "header", Path: name, Expected: want, Message: fmt.Sprintf("expected header %s but it was missing", name)})
+			continue
+		}
+		got := strings.Join(values, ", ")
+		if set, ok := headerRuleSet(headerRules, name); ok {
+			if problem := set.check(want, got, false); problem != "" {
+				mismatches = append(mismatches, PactMismatch{Type: "header", Path: name, Expected: want, Actual: got, Message: "header " + name + ": " + problem})
+			}
+			continue
+		}
+		if !pactHeaderEqual(name, want, got) {
+			mismatches = append(mismatches, PactMismatch{
+				Type:     "header",
+				Path:     name,
+				Expected: want,
+				Actual:   got,
+				Message:  fmt.Sprintf("expected header %s to be %q but got %q", name, want, got),
+			})
+		}
+	}
+
+	if len(expected.Body) > 0 {
+		mismatches = append(mismatches, matchPactBody(expected, headers, body)...)
+	}
+	return mismatches
+}
+
+func headerRuleSet(rules map[string]PactRuleSet, name string) (PactRuleSet, bool) {
+	for key, set := range rules {
+		if strings.EqualFold(key, name) {
+			return set, true
+		}
+	}
+	return PactRuleSet{}, false
+}
+
+// pactHeaderEqual compares header values item by item. Content types only
+// need the parameters the interaction names.
+func pactHeaderEqual(name, want, got string) bool {
+	if strings.EqualFold(name, "Content-Type") {
+		wantType, wantParams, err1 := mime.ParseMediaType(want)
+		gotType, gotParams, err2 := mime.ParseMediaType(got)
+		if err1 == nil && err2 == nil {
+			if wantType != gotType {
+				return false
+			}
+			for key, value := range wantParams {
+				if !strings.EqualFold(gotParams[key], value) {
+					return false
+				}
+			}
+			return true
+		}
+	}
+	split := func(value string) []string {
+		items := strings.Split(value, ",")
+		for i := range items {
+			items[i] = strings.TrimSpace(items[i])
+		}
+		return items
+	}
+	wantItems, gotItems := split(want), split(got)
+	if len(wantItems) != len(gotItems) {
+		return false
+	}
+	for i := range wantItems {
+		if wantItems[i] != gotItems[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// matchPactBody compares a response body with the expected one: as JSON,
+// or as text when the interaction expects a string from a response that
+// is not JSON.
+func matchPactBody(expected PactResponse, headers http.Header, body string) []PactMismatch {
+	matcher, err := newPactBodyMatcher(bodyRules(expected.MatchingRules))
+	if err != nil {
+		return []PactMismatch{{Type: "body", Message: err.Error()}}
+	}
+	want, err := decodePactJSON([]byte(expected.Body))
+	if err != nil {
+		return []PactMismatch{{Type: "body", Message: "the interaction's body is not valid JSON"}}
+	}
+
+	text, wantsText := want.(string)
+	if wantsText && !isJSONMediaType(bodyMediaType(headers, body)) {
+		matcher.compare(nil, text, body)
+		return matcher.mismatches
+	}
+	if strings.TrimSpace(body) == "" {
+		return []PactMismatch{{Type: "body", Path: "$", Expected: want, Message: "expected a body but the response had none"}}
+	}
+	got, err := decodePactJSON([]byte(body))
+	if err != nil {
+		return []PactMismatch{{Type: "body", Path: "$", Expected: want, Message: "response body is not valid JSON"}}
+	}
+	matcher.compare(nil, want, got)
+	return matcher.mismatches
+}
+
+// decodePactJSON decodes JSON keeping numbers as written, so that integer
+// and decimal matchers can tell 1 from 1.0.
+func decodePactJSON(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, errors.New("unexpected data after the JSON value")
+	}
+	return value, nil
+}
+
+// pactRule is a body rule set with its JSON path split into tokens.
+type pactRule struct {
+	path []string
+	set  PactRuleSet
+}
+
+// pactBodyMatcher compares JSON bodies under a set of body rules.
+type pactBodyMatcher struct {
+	rules      []pactRule
+	mismatches []PactMismatch
+}
+
+func newPactBodyMatcher(rules map[string]PactRuleSet) (*pactBodyMatcher, error) {
+	keys := make([]string, 0, len(rules))
+	for key := range rules {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys) // ties between equally specific rules go to the first path
+
+	matcher := &pactBodyMatcher{}
+	for _, key := range keys {
+		path, err := parsePactPath(key)
+		if err != nil {
+			return nil, err
+		}
+		matcher.rules = append(matcher.rules, pactRule{path: path, set: rules[key]})
+	}
+	return matcher, nil
+}
+
+// ruleFor returns the rule set that applies at path: the most specific
+// rule written for it, or else the nearest type rule on an ancestor, as
+// type rules cascade to everything below them. inherited reports the
+// latter.
+func (m *pactBodyMatcher) ruleFor(path []string) (set *PactRuleSet, inherited bool) {
+	bestLength, bestWeight := -1, -1
+	for i := range m.rules {
+		rule := &m.rules[i]
+		if len(rule.path) > len(path) || (len(rule.path) < len(path) && !rule.set.cascades()) {
+			continue
+		}
+		weight := 0
+		for j, token := range rule.path {
+			switch token {
+			case path[j]:
+				weight += 2
+			case "*":
+				weight++
+			default:
+				weight = -1
+			}
+			if weight < 0 {
+				break
+			}
+		}
+		if weight < 0 {
+			continue
+		}
+		if len(rule.path) > bestLength || (len(rule.path) == bestLength && weight > bestWeight) {
+			set, bestLength, bestWeight = &rule.set, len(rule.path), weight
+		}
+	}
+	return set, set != nil && bestLength < len(path)
+}
+
+// cascades reports whether the rule set only checks types.
+func (s PactRuleSet) cascades() bool {
+	for _, matcher := range s.Matchers {
+		if matcher.kind() != "type" {
+			return false
+		}
+	}
+	return len(s.Matchers) > 0
+}
+
+// compare records how got differs from want at path. Objects may have
+// fields the interaction does not mention; arrays must have the same
+// length unless a rule says otherwise.
+func (m *pactBodyMatcher) compare(path []string, want, got interface{}) {
+	set, inherited := m.ruleFor(path)
+	if set != nil {
+		if problem := set.check(want, got, inherited); problem != "" {
+			m.mismatch(path, want, got, problem)
+			return
+		}
+		switch want := want.(type) {
+		case map[string]interface{}:
+			object, ok := got.(map[string]interface{})
+			if !ok {
+				return
+			}
+			if set.ignoresKeys() {
+				m.compareValues(path, want, object)
+				return
+			}
+			m.compareObject(path, want, object)
+		case []interface{}:
+			items, _ := got.([]interface{})
+			// Rules let arrays grow; extra items are compared with the first example
+			for i, item := range items {
+				if len(want) == 0 {
+					break
+				}
+				example := want[0]
+				if i < len(want) {
+					example = want[i]
+				}
+				m.compare(appendPath(path, strconv.Itoa(i)), example, item)
+			}
+		}
+		return
+	}
+
+	switch want := want.(type) {
+	case map[string]interface{}:
+		object, ok := got.(map[string]interface{})
+		if !ok {
+			m.mismatch(path, want, got, fmt.Sprintf("expected an object but got %s", pactKind(got)))
+			return
+		}
+		m.compareObject(path, want, object)
+	case []interface{}:
+		items, ok := got.([]interface{})
+		if !ok {
+			m.mismatch(path, want, got, fmt.Sprintf("expected an array but got %s", pactKind(got)))
+			return
+		}
+		if len(items) != len(want) {
+			m.mismatch(path, want, got, fmt.Sprintf("expected %d items but got %d", len(want), len(items)))
+			return
+		}
+		for i := range want {
+			m.compare(appendPath(path, strconv.Itoa(i)), want[i], items[i])
+		}
+	default:
+		if !pactEqual(want, got) {
+			m.mismatch(path, want, got, fmt.Sprintf("expected %s but got %s", pactText(want), pactText(got)))
+		}
+	}
+}
+
+func (m *pactBodyMatcher) compareObject(path []string, want, got map[string]interface{}) {
+	for _, key := range sortedKeys(want) {
+		value, ok := got[key]
+		if !ok {
+			m.mismatch(appendPath(path, key), want[key], nil, "expected field "+key+" but it was missing")
+			continue
+		}
+		m.compare(appendPath(path, key), want[key], value)
+	}
+}
+
+// compareValues compares every value of got with the example under the
+// same key, or with the first example when the key is new.
+func (m *pactBodyMatcher) compareValues(path []string, want, got map[string]interface{}) {
+	keys := sortedKeys(want)
+	if len(keys) == 0 {
+		return
+	}
+	for _, key := range sortedKeys(got) {
+		example, ok := want[key]
+		if !ok {
+			example = want[keys[0]]
+		}
+		m.compare(appendPath(path, key), example, got[key])
+	}
+}
+
+func (m *pactBodyMatcher) mismatch(path []string, want, got interface{}, message string) {
+	m.mismatches = append(m.mismatches, PactMismatch{
+		Type:     "body",
+		Path:     pactPathString(path),
+		Expected: want,
+		Actual:   got,
+		Message:  message,
+	})
+}
+
+func appendPath(path []string, token string) []string {
+	return append(append([]string(nil), path...), token)
+}
+
+func (s PactRuleSet) ignoresKeys() bool {
+	for _, matcher := range s.Matchers {
+		if matcher.kind() == "values" {
+			return true
+		}
+	}
+	return false
+}
+
+// check applies the rule set to a value and describes why it failed, or
+// returns "". Inherited type rules do not apply their length bounds.
+func (s PactRuleSet) check(want, got interface{}, inherited bool) string {
+	var problems []string
+	for _, matcher := range s.Matchers {
+		problem := matcher.check(want, got, inherited)
+		if problem == "" && strings.EqualFold(s.Combine, "OR") {
+			return ""
+		}
+		if problem != "" {
+			problems = append(problems, problem)
+		}
+	}
+	return strings.Join(problems, "; ")
+}
+
+func (m PactMatcher) check(want, got interface{}, inherited bool) string {
+	switch m.kind() {
+	case "type":
+		if pactKind(want) != pactKind(got) {
+			return fmt.Sprintf("expected %s but got %s", pactKind(want), pactKind(got))
+		}
+		if items, ok := got.([]interface{}); ok && !inherited {
+			if m.Min != nil && len(items) < *m.Min {
+				return fmt.Sprintf("expected at least %d items but got %d", *m.Min, len(items))
+			}
+			if m.Max != nil && len(items) > *m.Max {
+				return fmt.Sprintf("expected at most %d items but got %d", *m.Max, len(items))
+			}
+		}
+	case "regex":
+		text, ok := pactScalarText(got)
+		if !ok {
+			return fmt.Sprintf("expected a value matching %s but got %s", m.Regex, pactKind(got))
+		}
+		pattern, err := regexp.Compile(`^(?:` + m.Regex + `)$`)
+		if err != nil {
+			return "invalid regex " + m.Regex
+		}
+		if !pattern.MatchString(text) {
+			return fmt.Sprintf("expected %q to match %s", text, m.Regex)
+		}
+	case "integer", "decimal", "number":
+		number, ok := got.(json.Number)
+		if !ok {
+			return fmt.Sprintf("expected %s but got %s", m.kind(), pactKind(got))
+		}
+		fractional := strings.ContainsAny(number.String(), ".eE")
+		if m.kind() == "integer" && fractional {
+			return fmt.Sprintf("expected an integer but got %s", number)
+		}
+		if m.kind() == "decimal" && !fractional {
+			return fmt.Sprintf("expected a decimal but got %s", number)
+		}
+	case "boolean":
+		if _, ok := got.(bool); !ok {
+			return "expected a boolean but got " + pactKind(got)
+		}
+	case "null":
+		if got != nil {
+			return "expected null but got " + pactKind(got)
+		}
+	case "equality":
+		if !pactEqual(want, got) {
+			return fmt.Sprintf("expected %s but got %s", pactText(want), pactText(got))
+		}
+	case "include":
+		text, ok := pactScalarText(got)
+		if !ok || !strings.Contains(text, m.Value) {
+			return fmt.Sprintf("expected a value including %q but got %s", m.Value, pactText(got))
+		}
+	case "values":
+		if _, ok := got.(map[string]interface{}); !ok {
+			return "expected an object but got " + pactKind(got)
+		}
+	case "date", "time", "timestamp":
+		if _, ok := got.(string); !ok {
+			return fmt.Sprintf("expected a %s string but got %s", m.kind(), pactKind(got))
+		}
+	default:
+		return fmt.Sprintf("unsupported matcher %q", m.Match)
+	}
+	return ""
+}
+
+func pactKind(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "an object"
+	case []interface{}:
+		return "an array"
+	case string:
+		return "a string"
+	case json.Number, float64:
+		return "a number"
+	case bool:
+		return "a boolean"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// pactScalarText returns strings, numbers and booleans as text.
+func pactScalarText(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case bool:
+		return strconv.FormatBool(v), true
+	}
+	return "", false
+}
+
+func pactText(value interface{}) string {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(encoded)
+}
+
+// pactEqual compares JSON values, numbers by value.
+func pactEqual(want, got interface{}) bool {
+	switch want := want.(type) {
+	case json.Number:
+		number, ok := got.(json.Number)
+		if !ok {
+			return false
+		}
+		a, err1 := want.Float64()
+		b, err2 := number.Float64()
+		return err1 == nil && err2 == nil && a == b
+	case map[string]interface{}:
+		object, ok := got.(map[string]interface{})
+		if !ok || len(object) != len(want) {
+			return false
+		}
+		for key, value := range want {
+			other, ok := object[key]
+			if !ok || !pactEqual(value, other) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		items, ok := got.([]interface{})
+		if !ok || len(items) != len(want) {
+			return false
+		}
+		for i := range want {
+			if !pactEqual(want[i], items[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return want == got
+	}
+}
+
+// parsePactPath splits a JSON path such as $.items[*].id or $['x-id'] into
+// tokens. Array indexes become their decimal text.
+func parsePactPath(expr string) ([]string, error) {
+	if !strings.HasPrefix(expr, "$") {
+		return nil, fmt.Errorf("invalid matching rule path %q", expr)
+	}
+	var tokens []string
+	rest := expr[1:]
+	for rest != "" {
+		switch {
+		case strings.HasPrefix(rest, "['"):
+			end := strings.Index(rest, "']")
+			if end < 0 {
+				return nil, fmt.Errorf("invalid matching rule path %q", expr)
+			}
+			tokens = append(tokens, rest[2:end])
+			rest = rest[end+2:]
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid matching rule path %q", expr)
+			}
+			token := rest[1:end]
+			if _, err := strconv.Atoi(token); err != nil && token != "*" {
+				return nil, fmt.Errorf("invalid matching rule path %q", expr)
+			}
+			tokens = append(tokens, token)
+			rest = rest[end+1:]
+		case rest[0] == '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("invalid matching rule path %q", expr)
+			}
+			tokens = append(tokens, rest[1:end+1])
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("invalid matching rule path %q", expr)
+		}
+	}
+	return tokens, nil
+}
+
+var pactIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// pactPathString writes tokens back as a JSON path.
+func pactPathString(path []string) string {
+	var b strings.Builder
+	b.WriteString("$")
+	for _, token := range path {
+		switch {
+		case pactIdentifier.MatchString(token):
+			b.WriteString("." + token)
+		case isArrayIndex(token):
+			b.WriteString("[" + token + "]")
+		default:
+			b.WriteString("['" + token + "']")
+		}
+	}
+	return b.String()
+}
+
+// pactInteractionFromSpan describes the HTTP call a client span records as
+// an interaction. The response body is matched by type, as recorded values
+// such as IDs and timestamps differ from call to call.
+func pactInteractionFromSpan(span *models.Span) (PactInteraction, bool) {
+	tags := spanTags(span)
+	method, rawURL := spanEndpoint(tags)
+	status := spanStatusCode(tags)
+	if method == "" || status == 0 {
+		return PactInteraction{}, false
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return PactInteraction{}, false
+	}
+	path := parsed.Path
+	if path == "" {
+		path = "/"
+	}
+
+	request := PactRequest{Method: method, Path: path}
+	if query := parsed.Query(); len(query) > 0 {
+		request.Query = PactQuery(query)
+	}
+	request.Body, request.Headers = pactSpanBody(tags, "request")
+
+	response := PactResponse{Status: status}
+	response.Body, response.Headers = pactSpanBody(tags, "response")
+	if len(response.Body) > 0 {
+		response.MatchingRules = &PactMatchingRules{Body: map[string]PactRuleSet{
+			"$": {Matchers: []PactMatcher{{Match: "type"}}},
+		}}
+	}
+
+	target := path
+	if parsed.RawQuery != "" {
+		target += "?" + parsed.Query().Encode()
+	}
+	return PactInteraction{
+		Description: fmt.Sprintf("%s %s returning %d", method, target, status),
+		Request:     request,
+		Response:    response,
+	}, true
+}
+
+// pactSpanBody reads the request or response body of a span and the
+// content type to send with it. Bodies that are not JSON are kept as
+// strings.
+func pactSpanBody(tags map[string]interface{}, side string) (json.RawMessage, PactHeaders) {
+	body := spanTag(tags, "http."+side+".body")
+	if body == "" {
+		return nil, nil
+	}
+	contentType := strings.Join(pactStrings(tags["http."+side+".header.content-type"]), ", ")
+	var raw json.RawMessage
+	if json.Valid([]byte(body)) {
+		raw = json.RawMessage(body)
+		if contentType == "" {
+			contentType = "application/json"
+		}
+	} else {
+		raw, _ = json.Marshal(body)
+	}
+	if contentType == "" {
+		return raw, nil
+	}
+	return raw, PactHeaders{"Content-Type": contentType}
+}
+
+// buildPactFile collects interactions from a consumer's client spans, newest
+// first. Calls to the same endpoint with the same status are recorded once.
+func buildPactFile(consumer, provider string, spans []models.Span) *PactFile {
+	seen := map[string]bool{}
+	interactions := []PactInteraction{}
+	for i := range spans {
+		interaction, ok := pactInteractionFromSpan(&spans[i])
+		if !ok || seen[interaction.Description] {
+			continue
+		}
+		seen[interaction.Description] = true
+		interactions = append(interactions, interaction)
+	}
+	sort.Slice(interactions, func(i, j int) bool {
+		return interactions[i].Description < interactions[j].Description
+	})
+	return &PactFile{
+		Consumer:     PactParticipant{Name: consumer},
+		Provider:     PactParticipant{Name: provider},
+		Interactions: interactions,
+		Metadata: map[string]interface{}{
+			"pactSpecification": map[string]interface{}{"version": pactSpecificationVersion},
+		},
+	}
+}
+
+var pactFileNameUnsafe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// PactFileName is the conventional file name for a pact,
+// consumer-provider.json.
+func PactFileName(consumer, provider string) string {
+	return pactFileNameUnsafe.ReplaceAllString(consumer+"-"+provider, "_") + ".json"
+}
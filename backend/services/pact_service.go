@@ -0,0 +1,511 @@
+package services
+
+import (
+	"backend/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Pact sources for models.Pact.Source.
+const (
+	PactSourceImport = "import"
+	PactSourceTraces = "traces"
+)
+
+// maxPactSpans bounds how many consumer spans a generated pact is built from.
+const maxPactSpans = 5000
+
+// PactService stores consumer-driven contracts, verifies them against
+// providers and generates them from recorded traces.
+type PactService struct {
+	db               *gorm.DB
+	workspaceService *WorkspaceService
+	requests         *RequestService
+}
+
+func NewPactService(db *gorm.DB, requests *RequestService) *PactService {
+	return &PactService{
+		db:               db,
+		workspaceService: NewWorkspaceService(db),
+		requests:         requests,
+	}
+}
+
+// Import stores a Pact file. Pact files do not carry the consumer's
+// version, so it is given here.
+func (s *PactService) Import(workspaceID, userID uuid.UUID, data []byte, consumerVersion string) (*models.Pact, error) {
+	if !s.workspaceService.HasAccess(workspaceID, userID) {
+		return nil, errors.New("access denied")
+	}
+	pact, err := ParsePact(data)
+	if err != nil {
+		return nil, err
+	}
+	return s.save(workspaceID, userID, pact, consumerVersion, PactSourceImport)
+}
+
+func (s *PactService) save(workspaceID, userID uuid.UUID, pact *PactFile, consumerVersion, source string) (*models.Pact, error) {
+	content, err := json.Marshal(pact)
+	if err != nil {
+		return nil, err
+	}
+	specVersion := pactSpecVersion(pact)
+	if specVersion == "" {
+		specVersion = "2.0.0"
+	}
+	record := models.Pact{
+		WorkspaceID:      workspaceID,
+		Consumer:         pact.Consumer.Name,
+		Provider:         pact.Provider.Name,
+		ConsumerVersion:  consumerVersion,
+		SpecVersion:      specVersion,
+		InteractionCount: len(pact.Interactions),
+		Source:           source,
+		Content:          string(content),
+		CreatedBy:        userID,
+	}
+	if err := s.db.Create(&record).Error; err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// List returns a workspace's pacts, newest first, optionally only those of
+// a consumer or provider.
+func (s *PactService) List(workspaceID, userID uuid.UUID, consumer, provider string) ([]models.Pact, error) {
+	if !s.workspaceService.HasAccess(workspaceID, userID) {
+		return nil, errors.New("access denied")
+	}
+	query := s.db.Where("workspace_id = ?", workspaceID)
+	if consumer != "" {
+		query = query.Where("consumer = ?", consumer)
+	}
+	if provider != "" {
+		query = query.Where("provider = ?", provider)
+	}
+	var pacts []models.Pact
+	err := query.Order("created_at DESC").Find(&pacts).Error
+	return pacts, err
+}
+
+func (s *PactService) GetByID(workspaceID, pactID, userID uuid.UUID) (*models.Pact, error) {
+	if !s.workspaceService.HasAccess(workspaceID, userID) {
+		return nil, errors.New("access denied")
+	}
+	var pact models.Pact
+	if err := s.db.Where("id = ? AND workspace_id = ?", pactID, workspaceID).First(&pact).Error; err != nil {
+		return nil, err
+	}
+	return &pact, nil
+}
+
+// Delete removes a pact. Its verifications are kept as history.
+func (s *PactService) Delete(workspaceID, pactID, userID uuid.UUID) error {
+	if !s.workspaceService.HasAccess(workspaceID, userID) {
+		return errors.New("access denied")
+	}
+	return s.db.Where("id = ? AND workspace_id = ?", pactID, workspaceID).Delete(&models.Pact{}).Error
+}
+
+// PactVerifyOptions say where and how a pact is verified.
+type PactVerifyOptions struct {
+	BaseURL         string            // the provider, e.g. https://orders.staging.internal
+	ProviderVersion string            // recorded with the results
+	StateChangeURL  string            // receives a POST to set up each provider state
+	StateTeardown   bool              // also POST with action teardown after each interaction
+	Headers         map[string]string // sent with every request, e.g. credentials
+	Auth            string            // AuthConfig JSON applied to every request
+}
+
+// PactInteractionResult is the outcome of replaying one interaction.
+type PactInteractionResult struct {
+	Description    string         `json:"description"`
+	ProviderStates []string       `json:"provider_states,omitempty"`
+	Success        bool           `json:"success"`
+	StatusCode     int            `json:"status_code,omitempty"`
+	DurationMs     int64          `json:"duration_ms"`
+	Error          string         `json:"error,omitempty"` // provider state or transport failure
+	Mismatches     []PactMismatch `json:"mismatches,omitempty"`
+}
+
+// pactStateChange is the body POSTed to the state change URL, as Pact
+// verifiers send it.
+type pactStateChange struct {
+	Consumer string                 `json:"consumer"`
+	State    string                 `json:"state"`
+	Params   map[string]interface{} `json:"params,omitempty"`
+	Action   string                 `json:"action"` // setup or teardown
+}
+
+// Verify replays a pact's interactions against a provider in the
+// background and stores the results for the pact's consumer version and the
+// given provider version. Poll GetVerification for progress and the
+// results. Requests go through the request executor, so the workspace's
+// client settings apply.
+func (s *PactService) Verify(workspaceID, pactID, userID uuid.UUID, options PactVerifyOptions) (*models.PactVerification, error) {
+	if !isHTTPURL(options.BaseURL) {
+		return nil, errors.New("base_url must be an http or https URL")
+	}
+	if options.StateChangeURL != "" && !isHTTPURL(options.StateChangeURL) {
+		return nil, errors.New("state_change_url must be an http or https URL")
+	}
+	if _, err := ParseAuthConfig(options.Auth); err != nil {
+		return nil, err
+	}
+
+	pact, err := s.GetByID(workspaceID, pactID, userID)
+	if err != nil {
+		return nil, err
+	}
+	file, err := ParsePact([]byte(pact.Content))
+	if err != nil {
+		return nil, err
+	}
+
+	verification := &models.PactVerification{
+		WorkspaceID:     workspaceID,
+		PactID:          pact.ID,
+		Consumer:        pact.Consumer,
+		Provider:        pact.Provider,
+		ConsumerVersion: pact.ConsumerVersion,
+		ProviderVersion: options.ProviderVersion,
+		BaseURL:         options.BaseURL,
+		Status:          RunStatusPending,
+		Results:         "[]",
+		CreatedBy:       userID,
+	}
+	if err := s.db.Create(verification).Error; err != nil {
+		return nil, err
+	}
+
+	go s.verify(verification, file, options)
+
+	return verification, nil
+}
+
+// verify replays the interactions one at a time and stores the results.
+func (s *PactService) verify(verification *models.PactVerification, file *PactFile, options PactVerifyOptions) {
+	startedAt := time.Now()
+	s.db.Model(verification).Updates(map[string]interface{}{
+		"status":     RunStatusRunning,
+		"started_at": startedAt,
+	})
+
+	results := make([]PactInteractionResult, 0, len(file.Interactions))
+	for _, interaction := range file.Interactions {
+		result := s.verifyInteraction(verification.WorkspaceID, file.Consumer.Name, interaction, options)
+		if result.Success {
+			verification.Passed++
+		} else {
+			verification.Failed++
+		}
+		results = append(results, result)
+		s.db.Model(&models.PactVerification{}).Where("id = ?", verification.ID).Updates(map[string]interface{}{
+			"passed": verification.Passed,
+			"failed": verification.Failed,
+		})
+	}
+
+	completedAt := time.Now()
+	verification.Success = verification.Failed == 0
+	verification.Status = RunStatusPassed
+	if !verification.Success {
+		verification.Status = RunStatusFailed
+	}
+	verification.DurationMs = completedAt.Sub(startedAt).Milliseconds()
+	resultsJSON, _ := json.Marshal(results)
+	verification.Results = string(resultsJSON)
+	verification.StartedAt = &startedAt
+	verification.CompletedAt = &completedAt
+	s.db.Model(verification).Updates(map[string]interface{}{
+		"status":       verification.Status,
+		"success":      verification.Success,
+		"passed":       verification.Passed,
+		"failed":       verification.Failed,
+		"results":      verification.Results,
+		"duration_ms":  verification.DurationMs,
+		"completed_at": completedAt,
+	})
+}
+
+// GetVerification returns a verification's progress and, once finished,
+// its results.
+func (s *PactService) GetVerification(workspaceID, verificationID, userID uuid.UUID) (*models.PactVerification, error) {
+	if !s.workspaceService.HasAccess(workspaceID, userID) {
+		return nil, errors.New("access denied")
+	}
+	var verification models.PactVerification
+	if err := s.db.Where("id = ? AND workspace_id = ?", verificationID, workspaceID).First(&verification).Error; err != nil {
+		return nil, err
+	}
+	return &verification, nil
+}
+
+// FailUnfinished marks verifications left pending or running by a previous
+// process as failed. Call it on startup, before any verification is started.
+func (s *PactService) FailUnfinished() (int64, error) {
+	result := s.db.Model(&models.PactVerification{}).
+		Where("status IN ?", []string{RunStatusPending, RunStatusRunning}).
+		Updates(map[string]interface{}{
+			"status":       RunStatusFailed,
+			"completed_at": time.Now(),
+		})
+	return result.RowsAffected, result.Error
+}
+
+func (s *PactService) verifyInteraction(workspaceID uuid.UUID, consumer string, interaction PactInteraction, options PactVerifyOptions) (result PactInteractionResult) {
+	result.Description = interaction.Description
+	for _, state := range interaction.ProviderStates {
+		result.ProviderStates = append(result.ProviderStates, state.Name)
+	}
+	startTime := time.Now()
+	defer func() { result.DurationMs = time.Since(startTime).Milliseconds() }()
+
+	if err := s.changeStates(workspaceID, consumer, interaction.ProviderStates, options, "setup"); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if options.StateTeardown {
+		// A failed teardown does not change the interaction's outcome
+		defer s.changeStates(workspaceID, consumer, interaction.ProviderStates, options, "teardown")
+	}
+
+	execution, err := s.send(pactProviderRequest(workspaceID, options, interaction.Request), options.Headers)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if execution.ErrorMessage != "" {
+		result.Error = execution.ErrorMessage
+		return result
+	}
+
+	var headers http.Header
+	json.Unmarshal([]byte(execution.ResponseHeaders), &headers)
+	result.StatusCode = execution.StatusCode
+	result.Mismatches = matchPactResponse(interaction.Response, execution.StatusCode, headers, execution.ResponseBody)
+	result.Success = len(result.Mismatches) == 0
+	return result
+}
+
+// changeStates POSTs each provider state to the state change URL. States
+// are skipped when no URL is configured.
+func (s *PactService) changeStates(workspaceID uuid.UUID, consumer string, states []PactProviderState, options PactVerifyOptions, action string) error {
+	if options.StateChangeURL == "" {
+		return nil
+	}
+	for _, state := range states {
+		body, _ := json.Marshal(pactStateChange{Consumer: consumer, State: state.Name, Params: state.Params, Action: action})
+		execution, err := s.send(&models.Request{
+			Method:     http.MethodPost,
+			URL:        options.StateChangeURL,
+			Body:       string(body),
+			BodyMode:   BodyModeJSON,
+			Auth:       options.Auth,
+			Collection: models.Collection{WorkspaceID: workspaceID},
+		}, options.Headers)
+		if err == nil && execution.ErrorMessage != "" {
+			err = errors.New(execution.ErrorMessage)
+		}
+		if err != nil {
+			return fmt.Errorf("provider state %q %s failed: %w", state.Name, action, err)
+		}
+		if execution.StatusCode >= 300 {
+			return fmt.Errorf("provider state %q %s returned status %d", state.Name, action, execution.StatusCode)
+		}
+	}
+	return nil
+}
+
+// send makes one attempt with an unsaved request. Verification calls are
+// not recorded as executions of any stored request.
+func (s *PactService) send(request *models.Request, headers map[string]string) (*models.Execution, error) {
+	prepared, err := s.requests.prepare(request, "", headers)
+	if err != nil {
+		return nil, err
+	}
+	var execution models.Execution
+	s.requests.send(prepared.client, prepared.httpReq, prepared.body, prepared.authConfig, &execution)
+	return &execution, nil
+}
+
+// pactProviderRequest builds the request an interaction describes, sent to
+// the provider's base URL.
+func pactProviderRequest(workspaceID uuid.UUID, options PactVerifyOptions, interaction PactRequest) *models.Request {
+	rawURL := strings.TrimRight(options.BaseURL, "/") + interaction.Path
+	if len(interaction.Query) > 0 {
+		rawURL += "?" + url.Values(interaction.Query).Encode()
+	}
+	request := &models.Request{
+		Method:     interaction.Method,
+		URL:        rawURL,
+		BodyMode:   BodyModeRaw,
+		Auth:       options.Auth,
+		Collection: models.Collection{WorkspaceID: workspaceID},
+	}
+	if len(interaction.Headers) > 0 {
+		headersJSON, _ := json.Marshal(interaction.Headers)
+		request.Headers = string(headersJSON)
+	}
+
+	if len(interaction.Body) > 0 {
+		// Bodies that are not JSON are given as strings and sent as they are
+		var text string
+		contentType := ""
+		for _, name := range interaction.Headers.names() {
+			if strings.EqualFold(name, "Content-Type") {
+				contentType = interaction.Headers[name]
+			}
+		}
+		mediaType, _, _ := mime.ParseMediaType(contentType)
+		if json.Unmarshal(interaction.Body, &text) == nil && !isJSONMediaType(mediaType) {
+			request.Body = text
+		} else {
+			request.Body = string(interaction.Body)
+		}
+	}
+	return request
+}
+
+func isHTTPURL(rawURL string) bool {
+	parsed, err := url.Parse(rawURL)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+// PactVerificationFilter narrows a verification listing. Zero values do
+// not filter.
+type PactVerificationFilter struct {
+	PactID          *uuid.UUID
+	Consumer        string
+	Provider        string
+	ConsumerVersion string
+	ProviderVersion string
+	Limit           int
+	Offset          int
+}
+
+// ListVerifications returns a workspace's verification results, newest
+// first, and how many match the filter in total.
+func (s *PactService) ListVerifications(workspaceID, userID uuid.UUID, filter PactVerificationFilter) ([]models.PactVerification, int64, error) {
+	if !s.workspaceService.HasAccess(workspaceID, userID) {
+		return nil, 0, errors.New("access denied")
+	}
+	query := s.db.Model(&models.PactVerification{}).Where("workspace_id = ?", workspaceID)
+	if filter.PactID != nil {
+		query = query.Where("pact_id = ?", *filter.PactID)
+	}
+	for column, value := range map[string]string{
+		"consumer":         filter.Consumer,
+		"provider":         filter.Provider,
+		"consumer_version": filter.ConsumerVersion,
+		"provider_version": filter.ProviderVersion,
+	} {
+		if value != "" {
+			query = query.Where(column+" = ?", value)
+		}
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if filter.Limit <= 0 || filter.Limit > 200 {
+		filter.Limit = 50
+	}
+	var verifications []models.PactVerification
+	err := query.Order("created_at DESC").Limit(filter.Limit).Offset(filter.Offset).Find(&verifications).Error
+	return verifications, total, err
+}
+
+// PactGenerateOptions select the consumer traffic a pact is generated from.
+type PactGenerateOptions struct {
+	Consumer string
+	Provider string
+	Since    time.Time
+	TraceID  *uuid.UUID // only this trace
+}
+
+// Generate writes a Pact file from the consumer's recorded client spans to
+// the provider. A span is a call to the provider when its peer.service tag
+// or host names the provider, or when the provider recorded a child span
+// for it.
+func (s *PactService) Generate(workspaceID, userID uuid.UUID, options PactGenerateOptions) (*PactFile, error) {
+	if !s.workspaceService.HasAccess(workspaceID, userID) {
+		return nil, errors.New("access denied")
+	}
+	if options.Consumer == "" || options.Provider == "" {
+		return nil, errors.New("consumer and provider are required")
+	}
+
+	query := s.db.Joins("JOIN traces ON traces.id = spans.trace_id").
+		Where("traces.workspace_id = ? AND spans.service_name = ? AND spans.start_time >= ?", workspaceID, options.Consumer, options.Since).
+		Where("spans.tags ->> 'http.method' IS NOT NULL OR spans.tags ->> 'http.request.method' IS NOT NULL").
+		Where("COALESCE(spans.tags ->> 'span.kind', 'client') = 'client'")
+	if options.TraceID != nil {
+		query = query.Where("spans.trace_id = ?", *options.TraceID)
+	}
+	var spans []models.Span
+	if err := query.Order("spans.start_time DESC").Limit(maxPactSpans).Find(&spans).Error; err != nil {
+		return nil, err
+	}
+
+	ids := make([]uuid.UUID, 0, len(spans))
+	for _, span := range spans {
+		ids = append(ids, span.ID)
+	}
+	served := map[uuid.UUID]bool{}
+	if len(ids) > 0 {
+		var parents []uuid.UUID
+		err := s.db.Model(&models.Span{}).
+			Where("service_name = ? AND parent_span_id IN ?", options.Provider, ids).
+			Pluck("parent_span_id", &parents).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range parents {
+			served[id] = true
+		}
+	}
+
+	var calls []models.Span
+	for _, span := range spans {
+		if served[span.ID] || spanCallsService(spanTags(&span), options.Provider) {
+			calls = append(calls, span)
+		}
+	}
+	pact := buildPactFile(options.Consumer, options.Provider, calls)
+	if len(pact.Interactions) == 0 {
+		return nil, fmt.Errorf("no recorded calls from %s to %s", options.Consumer, options.Provider)
+	}
+	return pact, nil
+}
+
+// SaveGenerated stores a generated pact so that it can be verified.
+func (s *PactService) SaveGenerated(workspaceID, userID uuid.UUID, pact *PactFile, consumerVersion string) (*models.Pact, error) {
+	return s.save(workspaceID, userID, pact, consumerVersion, PactSourceTraces)
+}
+
+// spanCallsService reports whether a client span's tags name service as
+// the peer, or as the host called.
+func spanCallsService(tags map[string]interface{}, service string) bool {
+	if strings.EqualFold(spanTag(tags, "peer.service"), service) {
+		return true
+	}
+	host := spanTag(tags, "server.address", "net.peer.name", "http.host")
+	if host == "" {
+		if parsed, err := url.Parse(spanTag(tags, "http.url", "url.full")); err == nil {
+			host = parsed.Hostname()
+		}
+	}
+	host, _, _ = strings.Cut(host, ":")
+	return host != "" && strings.EqualFold(host, service)
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"backend/models"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPactService_Verify(t *testing.T) {
+	db, mock := setupTestDBRequest(t)
+	service := NewPactService(db, NewRequestService(db, nil))
+
+	var states []pactStateChange
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/_pact/state":
+			var change pactStateChange
+			body, _ := io.ReadAll(r.Body)
+			require.NoError(t, json.Unmarshal(body, &change))
+			states = append(states, change)
+		case "/orders/42":
+			assert.Equal(t, "items", r.URL.Query().Get("expand"))
+			assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"id": 42, "state": "paid", "total": 9.5, "items": [{"sku": "A-1", "qty": 2}]}`))
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer provider.Close()
+
+	var document PactFile
+	require.NoError(t, json.Unmarshal([]byte(pactFixture), &document))
+	document.Interactions = append(document.Interactions, PactInteraction{
+		Description: "a request for a missing order",
+		Request:     PactRequest{Method: "GET", Path: "/orders/404"},
+		Response:    PactResponse{Status: 404},
+	})
+	content, _ := json.Marshal(document)
+
+	workspaceID := uuid.New()
+	verification := &models.PactVerification{
+		ID: uuid.New(), WorkspaceID: workspaceID, Consumer: "web", Provider: "orders", Status: RunStatusPending,
+	}
+	options := PactVerifyOptions{
+		BaseURL:         provider.URL,
+		ProviderVersion: "2.0.1",
+		StateChangeURL:  provider.URL + "/_pact/state",
+		Headers:         map[string]string{"Authorization": "Bearer token"},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`(?i)UPDATE "pact_verifications" SET .*"status"`).
+		WithArgs(sqlmock.AnyArg(), RunStatusRunning, verification.ID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectSettings := func() {
+		mock.ExpectQuery(`(?i)SELECT "settings" FROM "workspaces"`).
+			WithArgs(workspaceID, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"settings"}).AddRow(""))
+	}
+	expectProgress := func(failed, passed int) {
+		mock.ExpectBegin()
+		mock.ExpectExec(`(?i)UPDATE "pact_verifications" SET "failed"=\$1,"passed"=\$2 WHERE id = \$3`).
+			WithArgs(failed, passed, verification.ID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+	}
+	// Client settings for the state change and each interaction
+	expectSettings()
+	expectSettings()
+	expectProgress(0, 1)
+	expectSettings()
+	expectProgress(1, 1)
+	mock.ExpectBegin()
+	mock.ExpectExec(`(?i)UPDATE "pact_verifications" SET .*"status"`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	file, err := ParsePact(content)
+	require.NoError(t, err)
+	service.verify(verification, file, options)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	assert.Equal(t, RunStatusFailed, verification.Status)
+	assert.False(t, verification.Success)
+	assert.Equal(t, 1, verification.Passed)
+	assert.Equal(t, 1, verification.Failed)
+	assert.Equal(t, []pactStateChange{{Consumer: "web", State: "order 42 exists", Params: map[string]interface{}{"id": float64(42)}, Action: "setup"}}, states)
+
+	var results []PactInteractionResult
+	require.NoError(t, json.Unmarshal([]byte(verification.Results), &results))
+	require.Len(t, results, 2)
+	assert.True(t, results[0].Success)
+	assert.Equal(t, []string{"order 42 exists"}, results[0].ProviderStates)
+	require.Len(t, results[1].Mismatches, 1)
+	assert.Equal(t, "expected status 404 but got 200", results[1].Mismatches[0].Message)
+}
+
+func TestPactService_VerifyOptions(t *testing.T) {
+	service := &PactService{}
+	for _, options := range []PactVerifyOptions{
+		{},
+		{BaseURL: "orders.internal"},
+		{BaseURL: "http://orders.internal", StateChangeURL: "ftp://orders.internal/state"},
+		{BaseURL: "http://orders.internal", Auth: "{"},
+	} {
+		_, err := service.Verify(uuid.New(), uuid.New(), uuid.New(), options)
+		assert.Error(t, err, options)
+	}
+}
+
+func TestPactProviderRequest(t *testing.T) {
+	options := PactVerifyOptions{BaseURL: "http://orders.internal/api/"}
+
+	request := pactProviderRequest(uuid.Nil, options, PactRequest{
+		Method:  "POST",
+		Path:    "/orders",
+		Query:   PactQuery{"dry_run": {"true"}},
+		Headers: PactHeaders{"Content-Type": "application/json"},
+		Body:    json.RawMessage(`{"qty": 1}`),
+	})
+	assert.Equal(t, "http://orders.internal/api/orders?dry_run=true", request.URL)
+	assert.Equal(t, `{"qty": 1}`, request.Body)
+	assert.JSONEq(t, `{"Content-Type": "application/json"}`, request.Headers)
+
+	request = pactProviderRequest(uuid.Nil, options, PactRequest{
+		Method:  "PUT",
+		Path:    "/notes/1",
+		Headers: PactHeaders{"content-type": "text/plain"},
+		Body:    json.RawMessage(`"hello"`),
+	})
+	assert.Equal(t, "hello", request.Body, "text bodies are sent as they are")
+}
+
+func TestSpanCallsService(t *testing.T) {
+	assert.True(t, spanCallsService(map[string]interface{}{"peer.service": "Orders"}, "orders"))
+	assert.True(t, spanCallsService(map[string]interface{}{"http.url": "http://orders:8080/orders/1"}, "orders"))
+	assert.True(t, spanCallsService(map[string]interface{}{"server.address": "orders:8080", "http.url": "/orders/1"}, "orders"))
+	assert.False(t, spanCallsService(map[string]interface{}{"http.url": "http://billing/invoices"}, "orders"))
+	assert.False(t, spanCallsService(map[string]interface{}{"http.target": "/orders"}, "orders"))
+}
@@ -0,0 +1,186 @@
+package services
+
+import (
+	"backend/models"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const pactFixture = `{
+  "consumer": {"name": "web"},
+  "provider": {"name": "orders"},
+  "interactions": [
+    {
+      "description": "a request for order 42",
+      "providerStates": [{"name": "order 42 exists", "params": {"id": 42}}],
+      "request": {"method": "get", "path": "/orders/42", "query": {"expand": ["items"]}, "headers": {"Accept": "application/json"}},
+      "response": {
+        "status": 200,
+        "headers": {"Content-Type": "application/json"},
+        "body": {"id": 42, "state": "open", "total": 9.5, "items": [{"sku": "A-1", "qty": 1}]},
+        "matchingRules": {
+          "body": {
+            "$.id": {"matchers": [{"match": "integer"}]},
+            "$.state": {"matchers": [{"match": "regex", "regex": "open|paid"}]},
+            "$.items": {"matchers": [{"match": "type", "min": 1}]}
+          }
+        }
+      }
+    }
+  ],
+  "metadata": {"pactSpecification": {"version": "3.0.0"}}
+}`
+
+func TestParsePact(t *testing.T) {
+	pact, err := ParsePact([]byte(pactFixture))
+	require.NoError(t, err)
+	assert.Equal(t, "web", pact.Consumer.Name)
+	assert.Equal(t, "3.0.0", pactSpecVersion(pact))
+	interaction := pact.Interactions[0]
+	assert.Equal(t, "GET", interaction.Request.Method)
+	assert.Equal(t, PactQuery{"expand": {"items"}}, interaction.Request.Query)
+	assert.Equal(t, float64(42), interaction.ProviderStates[0].Params["id"])
+	assert.Len(t, interaction.Response.MatchingRules.Body, 3)
+
+	v2, err := ParsePact([]byte(`{
+	  "consumer": {"name": "web"}, "provider": {"name": "orders"},
+	  "interactions": [{
+	    "description": "list", "providerState": "orders exist",
+	    "request": {"method": "GET", "path": "/orders", "query": "page=2&page=3"},
+	    "response": {"status": 200, "body": [{"id": 1}], "matchingRules": {"$.body": {"min": 1}, "$.headers.Date": {"match": "type"}}}
+	  }],
+	  "metadata": {"pact-specification": {"version": "2.0.0"}}
+	}`))
+	require.NoError(t, err)
+	interaction = v2.Interactions[0]
+	assert.Equal(t, []PactProviderState{{Name: "orders exist"}}, interaction.ProviderStates)
+	assert.Empty(t, interaction.ProviderState)
+	assert.Equal(t, PactQuery{"page": {"2", "3"}}, interaction.Request.Query)
+	assert.Equal(t, "type", interaction.Response.MatchingRules.Body["$"].Matchers[0].kind())
+	assert.Contains(t, interaction.Response.MatchingRules.Header, "Date")
+
+	for name, document := range map[string]string{
+		"v4":             `{"consumer":{"name":"a"},"provider":{"name":"b"},"interactions":[{}],"metadata":{"pactSpecification":{"version":"4.0"}}}`,
+		"no provider":    `{"consumer":{"name":"a"},"interactions":[{}]}`,
+		"no interaction": `{"consumer":{"name":"a"},"provider":{"name":"b"},"interactions":[]}`,
+		"bad path":       `{"consumer":{"name":"a"},"provider":{"name":"b"},"interactions":[{"description":"x","request":{"method":"GET","path":"orders"},"response":{"status":200}}]}`,
+		"bad regex":      `{"consumer":{"name":"a"},"provider":{"name":"b"},"interactions":[{"description":"x","request":{"method":"GET","path":"/"},"response":{"status":200,"matchingRules":{"body":{"$.a":{"matchers":[{"match":"regex","regex":"("}]}}}}}]}`,
+	} {
+		_, err := ParsePact([]byte(document))
+		assert.Error(t, err, name)
+	}
+}
+
+func TestMatchPactResponse(t *testing.T) {
+	pact, err := ParsePact([]byte(pactFixture))
+	require.NoError(t, err)
+	expected := pact.Interactions[0].Response
+	jsonHeaders := http.Header{"Content-Type": {"application/json; charset=utf-8"}}
+
+	mismatches := matchPactResponse(expected, 200, jsonHeaders,
+		`{"id": 7, "state": "paid", "total": 9.5, "extra": true, "items": [{"sku": "B-2", "qty": 3}, {"sku": "C-3", "qty": 1}]}`)
+	assert.Empty(t, mismatches, "rules loosen matching and unexpected fields are allowed")
+
+	mismatches = matchPactResponse(expected, 201, http.Header{"Content-Type": {"text/plain"}},
+		`{"id": 7.5, "state": "gone", "total": 10, "items": [{"sku": 5}]}`)
+	type summary struct{ kind, path string }
+	var got []summary
+	for _, mismatch := range mismatches {
+		got = append(got, summary{mismatch.Type, mismatch.Path})
+	}
+	assert.Equal(t, []summary{
+		{"status", ""},
+		{"header", "Content-Type"},
+		{"body", "$.id"},
+		{"body", "$.items[0].qty"},
+		{"body", "$.items[0].sku"},
+		{"body", "$.state"},
+		{"body", "$.total"},
+	}, got)
+	assert.Equal(t, "expected a string but got a number", mismatches[4].Message, "the items rule cascades to their fields")
+	assert.Equal(t, "expected field qty but it was missing", mismatches[3].Message)
+	assert.Equal(t, "expected 9.5 but got 10", mismatches[6].Message)
+
+	mismatches = matchPactResponse(expected, 200, jsonHeaders, `{"id": 1, "state": "open", "total": 9.5, "items": []}`)
+	require.Len(t, mismatches, 1)
+	assert.Equal(t, "expected at least 1 items but got 0", mismatches[0].Message)
+
+	mismatches = matchPactResponse(expected, 200, jsonHeaders, `not json`)
+	require.Len(t, mismatches, 1)
+	assert.Equal(t, "response body is not valid JSON", mismatches[0].Message)
+}
+
+func TestMatchPactResponse_Rules(t *testing.T) {
+	match := func(rules, want, got string) []PactMismatch {
+		var set map[string]PactRuleSet
+		require.NoError(t, json.Unmarshal([]byte(rules), &set))
+		return matchPactResponse(PactResponse{
+			Status:        200,
+			Body:          json.RawMessage(want),
+			MatchingRules: &PactMatchingRules{Body: set},
+		}, 200, nil, got)
+	}
+
+	assert.Empty(t, match(`{"$": {"matchers": [{"match": "type"}]}}`, `{"a": [{"b": 1}]}`, `{"a": [{"b": 2}, {"b": 3}]}`),
+		"a type rule cascades to everything below it")
+	assert.Len(t, match(`{"$": {"matchers": [{"match": "type"}]}}`, `{"a": [{"b": 1}]}`, `{"a": [{"b": "2"}]}`), 1)
+	assert.Empty(t, match(`{"$.a[*].b": {"matchers": [{"match": "decimal"}]}}`, `{"a": [{"b": 1.5}]}`, `{"a": [{"b": 2.0}]}`))
+	assert.Len(t, match(`{"$.a[*].b": {"matchers": [{"match": "decimal"}]}}`, `{"a": [{"b": 1.5}]}`, `{"a": [{"b": 2}]}`), 1)
+	assert.Empty(t, match(`{"$.a": {"matchers": [{"match": "null"}, {"match": "integer"}], "combine": "OR"}}`, `{"a": 1}`, `{"a": null}`))
+	assert.Empty(t, match(`{"$.m": {"matchers": [{"match": "values"}]}}`, `{"m": {"x": {"n": 1}}}`, `{"m": {"y": {"n": 1}, "z": {"n": 1}}}`))
+	assert.Empty(t, match(`{"$['x-id']": {"matchers": [{"match": "include", "value": "-"}]}}`, `{"x-id": "a-b"}`, `{"x-id": "c-d"}`))
+	assert.Len(t, match(`{"$.a": {"matchers": [{"match": "semver"}]}}`, `{"a": "1.0.0"}`, `{"a": "1.0.0"}`), 1, "unknown matchers fail")
+	assert.Empty(t, match(`{}`, `"plain text"`, "plain text"), "string bodies compare as text")
+	assert.Empty(t, match(`{}`, `{"n": 1}`, `{"n": 1.0}`), "numbers compare by value")
+}
+
+func TestParsePactPath(t *testing.T) {
+	path, err := parsePactPath("$.items[*].id")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"items", "*", "id"}, path)
+
+	path, err = parsePactPath("$['x-id'][0]")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"x-id", "0"}, path)
+	assert.Equal(t, "$['x-id'][0]", pactPathString(path))
+
+	for _, expr := range []string{"items", "$.a[", "$..a", "$[x]"} {
+		_, err := parsePactPath(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestBuildPactFile(t *testing.T) {
+	span := func(tags string) models.Span { return models.Span{Tags: tags} }
+	spans := []models.Span{
+		span(`{"http.method": "GET", "http.url": "http://orders/orders/42?expand=items", "http.status_code": 200, "http.response.body": "{\"id\":42}"}`),
+		span(`{"http.method": "GET", "http.url": "http://orders/orders/42?expand=items", "http.status_code": 200, "http.response.body": "{\"id\":41}"}`),
+		span(`{"http.method": "POST", "http.url": "http://orders/orders", "http.status_code": "201", "http.request.body": "{\"qty\":1}", "http.response.body": "created", "http.response.header.content-type": ["text/plain"]}`),
+		span(`{"http.method": "DELETE", "http.url": "http://orders/orders/1"}`),
+	}
+
+	pact := buildPactFile("web", "orders", spans)
+	require.Len(t, pact.Interactions, 2, "duplicates and calls without a response are left out")
+	get, post := pact.Interactions[0], pact.Interactions[1]
+	assert.Equal(t, "GET /orders/42?expand=items returning 200", get.Description)
+	assert.Equal(t, PactQuery{"expand": {"items"}}, get.Request.Query)
+	assert.JSONEq(t, `{"id":42}`, string(get.Response.Body), "the newest call is kept")
+	assert.Equal(t, "type", get.Response.MatchingRules.Body["$"].Matchers[0].Match)
+
+	assert.Equal(t, "POST /orders returning 201", post.Description)
+	assert.Equal(t, PactHeaders{"Content-Type": "application/json"}, post.Request.Headers)
+	assert.Equal(t, `"created"`, string(post.Response.Body))
+	assert.Equal(t, PactHeaders{"Content-Type": "text/plain"}, post.Response.Headers)
+
+	// A generated pact is one the parser accepts
+	encoded, err := json.Marshal(pact)
+	require.NoError(t, err)
+	_, err = ParsePact(encoded)
+	require.NoError(t, err)
+
+	assert.Equal(t, "web_app-orders.json", PactFileName("web app", "orders"))
+}
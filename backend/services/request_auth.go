@@ -0,0 +1,632 @@
+package services
+
+import (
+	"backend/models"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+// Supported auth types for outbound requests.
+const (
+	AuthTypeInherit = "inherit"
+	AuthTypeNone    = "none"
+	AuthTypeBasic   = "basic"
+	AuthTypeBearer  = "bearer"
+	AuthTypeAPIKey  = "apikey"
+	AuthTypeOAuth2  = "oauth2"
+	AuthTypeDigest  = "digest"
+	AuthTypeAWSV4   = "awsv4"
+)
+
+// AuthConfig is the JSON stored in Request.Auth and Collection.Auth.
+// Only the block matching Type is used.
+type AuthConfig struct {
+	Type   string            `json:"type"`
+	Basic  *BasicAuthConfig  `json:"basic,omitempty"`
+	Bearer *BearerAuthConfig `json:"bearer,omitempty"`
+	APIKey *APIKeyAuthConfig `json:"apikey,omitempty"`
+	OAuth2 *OAuth2AuthConfig `json:"oauth2,omitempty"`
+	Digest *DigestAuthConfig `json:"digest,omitempty"`
+	AWSV4  *AWSV4AuthConfig  `json:"awsv4,omitempty"`
+}
+
+type BasicAuthConfig struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type BearerAuthConfig struct {
+	Token string `json:"token"`
+}
+
+type APIKeyAuthConfig struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	In    string `json:"in"` // header (default), query
+}
+
+type OAuth2AuthConfig struct {
+	GrantType    string `json:"grant_type"` // client_credentials, password
+	TokenURL     string `json:"token_url"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	Username     string `json:"username,omitempty"`
+	Password     string `json:"password,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+	Audience     string `json:"audience,omitempty"`
+	ClientAuth   string `json:"client_auth,omitempty"` // header (default), body
+}
+
+type DigestAuthConfig struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type AWSV4AuthConfig struct {
+	AccessKeyID     string `json:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key"`
+	SessionToken    string `json:"session_token,omitempty"`
+	Region          string `json:"region"`
+	Service         string `json:"service"`
+}
+
+// ParseAuthConfig decodes an auth JSON column. Empty input yields nil.
+func ParseAuthConfig(raw string) (*AuthConfig, error) {
+	if raw == "" || raw == "null" {
+		return nil, nil
+	}
+	var cfg AuthConfig
+	if err := json.Unmarshal([]byte(raw), &cfg); err != nil {
+		return nil, fmt.Errorf("invalid auth config: %w", err)
+	}
+	return &cfg, nil
+}
+
+// redactedSecret stands in for auth secrets in API responses, revision
+// snapshots and synced files. Sending it back keeps the stored secret.
+const redactedSecret = "{{redacted}}"
+
+// secrets returns the fields that hold credentials, keyed by block and field.
+func (c *AuthConfig) secrets() map[string]*string {
+	secrets := map[string]*string{}
+	if c.Basic != nil {
+		secrets["basic.password"] = &c.Basic.Password
+	}
+	if c.Bearer != nil {
+		secrets["bearer.token"] = &c.Bearer.Token
+	}
+	if c.APIKey != nil {
+		secrets["apikey.value"] = &c.APIKey.Value
+	}
+	if c.OAuth2 != nil {
+		secrets["oauth2.client_secret"] = &c.OAuth2.ClientSecret
+		secrets["oauth2.password"] = &c.OAuth2.Password
+	}
+	if c.Digest != nil {
+		secrets["digest.password"] = &c.Digest.Password
+	}
+	if c.AWSV4 != nil {
+		secrets["awsv4.secret_access_key"] = &c.AWSV4.SecretAccessKey
+		secrets["awsv4.session_token"] = &c.AWSV4.SessionToken
+	}
+	return secrets
+}
+
+// RedactAuth replaces the secrets in an auth JSON column with redactedSecret.
+// Values that only reference {{variables}} hold no secret and are kept.
+func RedactAuth(raw string) string {
+	cfg, err := ParseAuthConfig(raw)
+	if err != nil || cfg == nil {
+		return raw
+	}
+	for _, secret := range cfg.secrets() {
+		if strings.TrimSpace(variablePattern.ReplaceAllString(*secret, "")) != "" {
+			*secret = redactedSecret
+		}
+	}
+	encoded, _ := json.Marshal(cfg)
+	return string(encoded)
+}
+
+// mergeAuthSecrets puts the secrets stored in current back into incoming
+// wherever incoming holds redactedSecret, so clients can save auth as they
+// read it. Redacted secrets with nothing stored to restore are cleared.
+func mergeAuthSecrets(incoming, current string) string {
+	merged, _ := fillAuthSecrets(incoming, current)
+	return merged
+}
+
+// fillAuthSecrets is mergeAuthSecrets, also returning the sorted keys of the
+// redacted secrets it filled in or cleared, e.g. bearer.token.
+func fillAuthSecrets(incoming, current string) (string, []string) {
+	if !strings.Contains(incoming, redactedSecret) {
+		return incoming, nil
+	}
+	cfg, err := ParseAuthConfig(incoming)
+	if err != nil || cfg == nil {
+		return incoming, nil
+	}
+	stored := map[string]*string{}
+	if currentCfg, err := ParseAuthConfig(current); err == nil && currentCfg != nil {
+		stored = currentCfg.secrets()
+	}
+	var filled []string
+	for key, secret := range cfg.secrets() {
+		if *secret != redactedSecret {
+			continue
+		}
+		filled = append(filled, key)
+		*secret = ""
+		if value, ok := stored[key]; ok {
+			*secret = *value
+		}
+	}
+	sort.Strings(filled)
+	encoded, _ := json.Marshal(cfg)
+	return string(encoded), filled
+}
+
+// RedactRequest redacts the auth secrets of a request and its preloaded
+// collection before the request is returned by the API.
+func RedactRequest(request *models.Request) {
+	request.Auth = RedactAuth(request.Auth)
+	request.Collection.Auth = RedactAuth(request.Collection.Auth)
+}
+
+// RedactCollection redacts a collection's auth secrets before the collection
+// is returned by the API.
+func RedactCollection(collection *models.Collection) {
+	collection.Auth = RedactAuth(collection.Auth)
+}
+
+// RedactFolder redacts the auth secrets of a folder and its preloaded
+// collection before the folder is returned by the API.
+func RedactFolder(folder *models.Folder) {
+	folder.Auth = RedactAuth(folder.Auth)
+	folder.Collection.Auth = RedactAuth(folder.Collection.Auth)
+}
+
+// RedactTree redacts every folder and request in a folder tree.
+func RedactTree(items []TreeItem) {
+	for i := range items {
+		if items[i].Folder != nil {
+			RedactFolder(items[i].Folder)
+		}
+		if items[i].Request != nil {
+			RedactRequest(items[i].Request)
+		}
+		RedactTree(items[i].Items)
+	}
+}
+
+// resolveAuthConfig returns the auth that applies to a request, falling back
+// through its folders, innermost first, and then its collection when the
+// request has none or is set to inherit.
+func resolveAuthConfig(request *models.Request) (*AuthConfig, error) {
+	chain := []string{request.Auth}
+	for i := len(request.Folders) - 1; i >= 0; i-- {
+		chain = append(chain, request.Folders[i].Auth)
+	}
+	chain = append(chain, request.Collection.Auth)
+
+	for _, raw := range chain {
+		cfg, err := ParseAuthConfig(raw)
+		if err != nil {
+			return nil, err
+		}
+		if cfg == nil || cfg.Type == "" || cfg.Type == AuthTypeInherit {
+			continue
+		}
+		if cfg.Type == AuthTypeNone {
+			return nil, nil
+		}
+		return cfg, nil
+	}
+	return nil, nil
+}
+
+// applyAuth sets credentials on an outgoing request. It must run after all
+// other headers are set so that signatures cover them. Digest auth needs a
+// server challenge and is handled by authTransport instead.
+func (s *RequestService) applyAuth(req *http.Request, body []byte, cfg *AuthConfig) error {
+	if cfg == nil {
+		return nil
+	}
+
+	switch cfg.Type {
+	case AuthTypeBasic:
+		if cfg.Basic == nil {
+			return errors.New("basic auth config missing")
+		}
+		req.SetBasicAuth(cfg.Basic.Username, cfg.Basic.Password)
+
+	case AuthTypeBearer:
+		if cfg.Bearer == nil {
+			return errors.New("bearer auth config missing")
+		}
+		req.Header.Set("Authorization", "Bearer "+cfg.Bearer.Token)
+
+	case AuthTypeAPIKey:
+		if cfg.APIKey == nil || cfg.APIKey.Key == "" {
+			return errors.New("api key auth config missing")
+		}
+		if cfg.APIKey.In == "query" {
+			query := req.URL.Query()
+			query.Set(cfg.APIKey.Key, cfg.APIKey.Value)
+			req.URL.RawQuery = query.Encode()
+		} else {
+			req.Header.Set(cfg.APIKey.Key, cfg.APIKey.Value)
+		}
+
+	case AuthTypeOAuth2:
+		if cfg.OAuth2 == nil {
+			return errors.New("oauth2 auth config missing")
+		}
+		token, err := s.oauth2Tokens.Token(cfg.OAuth2)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+
+	case AuthTypeAWSV4:
+		if cfg.AWSV4 == nil {
+			return errors.New("aws sigv4 auth config missing")
+		}
+		creds := aws.Credentials{
+			AccessKeyID:     cfg.AWSV4.AccessKeyID,
+			SecretAccessKey: cfg.AWSV4.SecretAccessKey,
+			SessionToken:    cfg.AWSV4.SessionToken,
+		}
+		payloadHash := sha256.Sum256(body)
+		return v4.NewSigner().SignHTTP(context.Background(), creds, req, hex.EncodeToString(payloadHash[:]), cfg.AWSV4.Service, cfg.AWSV4.Region, time.Now())
+
+	case AuthTypeDigest:
+		if cfg.Digest == nil {
+			return errors.New("digest auth config missing")
+		}
+
+	default:
+		return fmt.Errorf("unsupported auth type: %s", cfg.Type)
+	}
+
+	return nil
+}
+
+// authTransport wraps base with any transport-level auth the config needs.
+func authTransport(cfg *AuthConfig, base http.RoundTripper) http.RoundTripper {
+	if cfg != nil && cfg.Type == AuthTypeDigest && cfg.Digest != nil {
+		return &digestTransport{username: cfg.Digest.Username, password: cfg.Digest.Password, base: base}
+	}
+	return base
+}
+
+// oauth2Token is a cached access token for one OAuth2 configuration.
+type oauth2Token struct {
+	AccessToken  string
+	RefreshToken string
+	Expiry       time.Time
+}
+
+func (t *oauth2Token) valid() bool {
+	return t.AccessToken != "" && (t.Expiry.IsZero() || time.Now().Before(t.Expiry))
+}
+
+// oauth2TokenCache caches tokens per configuration and refreshes them on expiry.
+// Fetches for one configuration are serialized so concurrent executions share
+// a token, while a slow token endpoint holds up only its own configuration.
+type oauth2TokenCache struct {
+	mu      sync.Mutex
+	tokens  map[string]*oauth2Token
+	fetches map[string]*sync.Mutex
+	client  *http.Client
+}
+
+func newOAuth2TokenCache() *oauth2TokenCache {
+	return &oauth2TokenCache{
+		tokens:  make(map[string]*oauth2Token),
+		fetches: make(map[string]*sync.Mutex),
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// sharedOAuth2Tokens is shared by every RequestService so that load tests and
+// workflows reuse tokens instead of hitting the token endpoint per call.
+var sharedOAuth2Tokens = newOAuth2TokenCache()
+
+// oauth2TokenExpirySkew refreshes tokens slightly before the server expires them.
+const oauth2TokenExpirySkew = 30 * time.Second
+
+func (c *oauth2TokenCache) Token(cfg *OAuth2AuthConfig) (*oauth2Token, error) {
+	key := oauth2CacheKey(cfg)
+
+	fetch := c.fetchLock(key)
+	fetch.Lock()
+	defer fetch.Unlock()
+
+	cached := c.cached(key)
+	if cached != nil && cached.valid() {
+		return cached, nil
+	}
+
+	if cached != nil && cached.RefreshToken != "" {
+		form := url.Values{}
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", cached.RefreshToken)
+		if token, err := c.requestToken(cfg, form); err == nil {
+			if token.RefreshToken == "" {
+				token.RefreshToken = cached.RefreshToken
+			}
+			c.store(key, token)
+			return token, nil
+		}
+	}
+
+	form := url.Values{}
+	switch cfg.GrantType {
+	case "", "client_credentials":
+		form.Set("grant_type", "client_credentials")
+	case "password":
+		form.Set("grant_type", "password")
+		form.Set("username", cfg.Username)
+		form.Set("password", cfg.Password)
+	default:
+		return nil, fmt.Errorf("unsupported oauth2 grant type: %s", cfg.GrantType)
+	}
+	if cfg.Scope != "" {
+		form.Set("scope", cfg.Scope)
+	}
+	if cfg.Audience != "" {
+		form.Set("audience", cfg.Audience)
+	}
+
+	token, err := c.requestToken(cfg, form)
+	if err != nil {
+		return nil, err
+	}
+	c.store(key, token)
+	return token, nil
+}
+
+// fetchLock returns the lock held while fetching a configuration's token.
+func (c *oauth2TokenCache) fetchLock(key string) *sync.Mutex {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fetch := c.fetches[key]
+	if fetch == nil {
+		fetch = &sync.Mutex{}
+		c.fetches[key] = fetch
+	}
+	return fetch
+}
+
+func (c *oauth2TokenCache) cached(key string) *oauth2Token {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.tokens[key]
+}
+
+func (c *oauth2TokenCache) store(key string, token *oauth2Token) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tokens[key] = token
+}
+
+func (c *oauth2TokenCache) requestToken(cfg *OAuth2AuthConfig, form url.Values) (*oauth2Token, error) {
+	if cfg.ClientAuth == "body" {
+		form.Set("client_id", cfg.ClientID)
+		form.Set("client_secret", cfg.ClientSecret)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, cfg.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if cfg.ClientAuth != "body" {
+		req.SetBasicAuth(url.QueryEscape(cfg.ClientID), url.QueryEscape(cfg.ClientSecret))
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oauth2 token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oauth2 token endpoint returned %d: %s", resp.StatusCode, string(body))
+	}
+
+	var payload struct {
+		AccessToken  string      `json:"access_token"`
+		RefreshToken string      `json:"refresh_token"`
+		ExpiresIn    json.Number `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("invalid oauth2 token response: %w", err)
+	}
+	if payload.AccessToken == "" {
+		return nil, errors.New("oauth2 token response has no access_token")
+	}
+
+	token := &oauth2Token{
+		AccessToken:  payload.AccessToken,
+		RefreshToken: payload.RefreshToken,
+	}
+	if seconds, err := payload.ExpiresIn.Int64(); err == nil && seconds > 0 {
+		token.Expiry = time.Now().Add(time.Duration(seconds)*time.Second - oauth2TokenExpirySkew)
+	}
+	return token, nil
+}
+
+func oauth2CacheKey(cfg *OAuth2AuthConfig) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		cfg.GrantType, cfg.TokenURL, cfg.ClientID, cfg.ClientSecret, cfg.Username, cfg.Password, cfg.Scope, cfg.Audience,
+	}, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// digestTransport answers HTTP Digest challenges (RFC 7616) by replaying the
+// request once with an Authorization header.
+type digestTransport struct {
+	username string
+	password string
+	base     http.RoundTripper
+}
+
+func (t *digestTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	challenge := parseDigestChallenge(resp.Header.Get("WWW-Authenticate"))
+	if challenge == nil {
+		return resp, nil
+	}
+
+	retry := req.Clone(req.Context())
+	if req.Body != nil {
+		if req.GetBody == nil {
+			return resp, nil
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return resp, nil
+		}
+		retry.Body = body
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	authorization, err := challenge.authorize(t.username, t.password, retry.Method, retry.URL.RequestURI())
+	if err != nil {
+		return nil, err
+	}
+	retry.Header.Set("Authorization", authorization)
+	return t.base.RoundTrip(retry)
+}
+
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       string
+}
+
+func parseDigestChallenge(header string) *digestChallenge {
+	if len(header) < 7 || !strings.EqualFold(header[:7], "Digest ") {
+		return nil
+	}
+
+	params := map[string]string{}
+	for _, part := range splitDigestParams(header[7:]) {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		params[strings.ToLower(kv[0])] = strings.Trim(kv[1], `"`)
+	}
+
+	challenge := &digestChallenge{
+		realm:     params["realm"],
+		nonce:     params["nonce"],
+		opaque:    params["opaque"],
+		algorithm: params["algorithm"],
+	}
+	for _, qop := range strings.Split(params["qop"], ",") {
+		if strings.TrimSpace(qop) == "auth" {
+			challenge.qop = "auth"
+		}
+	}
+	return challenge
+}
+
+// splitDigestParams splits on commas that are not inside quoted strings.
+func splitDigestParams(s string) []string {
+	var parts []string
+	inQuotes := false
+	start := 0
+	for i, r := range s {
+		switch r {
+		case '"':
+			inQuotes = !inQuotes
+		case ',':
+			if !inQuotes {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func (c *digestChallenge) authorize(username, password, method, uri string) (string, error) {
+	var newHash func() hash.Hash
+	algorithm := strings.ToUpper(c.algorithm)
+	switch algorithm {
+	case "", "MD5", "MD5-SESS":
+		newHash = md5.New
+	case "SHA-256", "SHA-256-SESS":
+		newHash = sha256.New
+	default:
+		return "", fmt.Errorf("unsupported digest algorithm: %s", c.algorithm)
+	}
+	h := func(s string) string {
+		hasher := newHash()
+		hasher.Write([]byte(s))
+		return hex.EncodeToString(hasher.Sum(nil))
+	}
+
+	cnonceBytes := make([]byte, 8)
+	if _, err := rand.Read(cnonceBytes); err != nil {
+		return "", err
+	}
+	cnonce := hex.EncodeToString(cnonceBytes)
+	nc := "00000001"
+
+	ha1 := h(username + ":" + c.realm + ":" + password)
+	if strings.HasSuffix(algorithm, "-SESS") {
+		ha1 = h(ha1 + ":" + c.nonce + ":" + cnonce)
+	}
+	ha2 := h(method + ":" + uri)
+
+	var response string
+	if c.qop != "" {
+		response = h(strings.Join([]string{ha1, c.nonce, nc, cnonce, c.qop, ha2}, ":"))
+	} else {
+		response = h(ha1 + ":" + c.nonce + ":" + ha2)
+	}
+
+	header := fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s", response="%s"`,
+		username, c.realm, c.nonce, uri, response)
+	if c.algorithm != "" {
+		header += ", algorithm=" + c.algorithm
+	}
+	if c.qop != "" {
+		header += fmt.Sprintf(`, qop=%s, nc=%s, cnonce="%s"`, c.qop, nc, cnonce)
+	}
+	if c.opaque != "" {
+		header += fmt.Sprintf(`, opaque="%s"`, c.opaque)
+	}
+	return header, nil
+}
@@ -0,0 +1,208 @@
+package services
+
+import (
+	"backend/models"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveAuthConfig_InheritsFromCollection(t *testing.T) {
+	request := &models.Request{
+		Auth:       `{"type":"inherit"}`,
+		Collection: models.Collection{Auth: `{"type":"bearer","bearer":{"token":"abc"}}`},
+	}
+
+	cfg, err := resolveAuthConfig(request)
+	require.NoError(t, err)
+	require.NotNil(t, cfg)
+	assert.Equal(t, AuthTypeBearer, cfg.Type)
+	assert.Equal(t, "abc", cfg.Bearer.Token)
+
+	request.Auth = `{"type":"none"}`
+	cfg, err = resolveAuthConfig(request)
+	require.NoError(t, err)
+	assert.Nil(t, cfg)
+}
+
+func TestRedactAuth(t *testing.T) {
+	stored := `{"type":"oauth2","oauth2":{"grant_type":"password","token_url":"https://idp.test/token","client_id":"app","client_secret":"s3cret","username":"ann","password":"{{ann_password}}"}}`
+
+	redacted := RedactAuth(stored)
+	assert.NotContains(t, redacted, "s3cret")
+	cfg, err := ParseAuthConfig(redacted)
+	require.NoError(t, err)
+	assert.Equal(t, redactedSecret, cfg.OAuth2.ClientSecret)
+	assert.Equal(t, "{{ann_password}}", cfg.OAuth2.Password, "variable references hold no secret")
+	assert.Equal(t, "app", cfg.OAuth2.ClientID)
+
+	// Saving the redacted auth keeps the stored secret
+	assert.JSONEq(t, stored, mergeAuthSecrets(redacted, stored))
+	changed := strings.Replace(redacted, `"client_id":"app"`, `"client_id":"other"`, 1)
+	cfg, err = ParseAuthConfig(mergeAuthSecrets(changed, stored))
+	require.NoError(t, err)
+	assert.Equal(t, "other", cfg.OAuth2.ClientID)
+	assert.Equal(t, "s3cret", cfg.OAuth2.ClientSecret)
+
+	// With nothing stored for the auth type, redacted secrets are cleared
+	cfg, err = ParseAuthConfig(mergeAuthSecrets(`{"type":"basic","basic":{"username":"u","password":"{{redacted}}"}}`, stored))
+	require.NoError(t, err)
+	assert.Equal(t, "", cfg.Basic.Password)
+
+	// Restores report the secrets they could not take from the snapshot
+	_, filled := fillAuthSecrets(`{"type":"awsv4","awsv4":{"access_key_id":"AKID","secret_access_key":"{{redacted}}","session_token":"{{redacted}}"}}`, stored)
+	assert.Equal(t, []string{"awsv4.secret_access_key", "awsv4.session_token"}, filled)
+	_, filled = fillAuthSecrets(`{"type":"bearer","bearer":{"token":"{{token}}"}}`, stored)
+	assert.Empty(t, filled)
+
+	assert.Equal(t, "", RedactAuth(""))
+	assert.Equal(t, `{"type":"bearer","bearer":{"token":"abc"}}`, mergeAuthSecrets(`{"type":"bearer","bearer":{"token":"abc"}}`, stored))
+}
+
+func TestRequestService_ApplyAuth(t *testing.T) {
+	service := &RequestService{oauth2Tokens: newOAuth2TokenCache()}
+
+	t.Run("Basic", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "http://api.test/items", nil)
+		err := service.applyAuth(req, nil, &AuthConfig{Type: AuthTypeBasic, Basic: &BasicAuthConfig{Username: "u", Password: "p"}})
+		require.NoError(t, err)
+		user, pass, ok := req.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "u", user)
+		assert.Equal(t, "p", pass)
+	})
+
+	t.Run("API key in query", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "http://api.test/items?page=2", nil)
+		err := service.applyAuth(req, nil, &AuthConfig{Type: AuthTypeAPIKey, APIKey: &APIKeyAuthConfig{Key: "api_key", Value: "secret", In: "query"}})
+		require.NoError(t, err)
+		assert.Equal(t, "secret", req.URL.Query().Get("api_key"))
+		assert.Equal(t, "2", req.URL.Query().Get("page"))
+	})
+
+	t.Run("AWS SigV4", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", "https://execute-api.us-east-1.amazonaws.com/prod/items", strings.NewReader("{}"))
+		err := service.applyAuth(req, []byte("{}"), &AuthConfig{Type: AuthTypeAWSV4, AWSV4: &AWSV4AuthConfig{
+			AccessKeyID: "AKID", SecretAccessKey: "SECRET", Region: "us-east-1", Service: "execute-api",
+		}})
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(req.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/"))
+		assert.NotEmpty(t, req.Header.Get("X-Amz-Date"))
+	})
+}
+
+func TestOAuth2TokenCache_CachesAndRefreshes(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		r.ParseForm()
+		user, _, _ := r.BasicAuth()
+		assert.Equal(t, "client", user)
+		if n == 1 {
+			assert.Equal(t, "client_credentials", r.PostForm.Get("grant_type"))
+			// Expires immediately so the next call has to refresh
+			fmt.Fprint(w, `{"access_token":"first","refresh_token":"r1","expires_in":1}`)
+			return
+		}
+		assert.Equal(t, "refresh_token", r.PostForm.Get("grant_type"))
+		assert.Equal(t, "r1", r.PostForm.Get("refresh_token"))
+		fmt.Fprint(w, `{"access_token":"second","expires_in":3600}`)
+	}))
+	defer ts.Close()
+
+	cache := newOAuth2TokenCache()
+	cfg := &OAuth2AuthConfig{GrantType: "client_credentials", TokenURL: ts.URL, ClientID: "client", ClientSecret: "secret"}
+
+	token, err := cache.Token(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "first", token.AccessToken)
+
+	token, err = cache.Token(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "second", token.AccessToken)
+	assert.Equal(t, "r1", token.RefreshToken)
+
+	token, err = cache.Token(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "second", token.AccessToken)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestOAuth2TokenCache_SlowEndpointDoesNotBlockOthers(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		fmt.Fprint(w, `{"access_token":"slow","expires_in":3600}`)
+	}))
+	defer slow.Close()
+	defer close(release)
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"access_token":"fast","expires_in":3600}`)
+	}))
+	defer fast.Close()
+
+	cache := newOAuth2TokenCache()
+	go cache.Token(&OAuth2AuthConfig{TokenURL: slow.URL, ClientID: "slow"})
+	<-started
+
+	done := make(chan *oauth2Token)
+	go func() {
+		token, _ := cache.Token(&OAuth2AuthConfig{TokenURL: fast.URL, ClientID: "fast"})
+		done <- token
+	}()
+	select {
+	case token := <-done:
+		require.NotNil(t, token)
+		assert.Equal(t, "fast", token.AccessToken)
+	case <-time.After(5 * time.Second):
+		t.Fatal("a slow token endpoint blocked another configuration")
+	}
+}
+
+func TestDigestTransport_AnswersChallenge(t *testing.T) {
+	md5hex := func(s string) string {
+		sum := md5.Sum([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if auth == "" {
+			w.Header().Set("WWW-Authenticate", `Digest realm="test", qop="auth", nonce="abc123", opaque="xyz"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		params := map[string]string{}
+		for _, part := range splitDigestParams(strings.TrimPrefix(auth, "Digest ")) {
+			kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+			params[kv[0]] = strings.Trim(kv[1], `"`)
+		}
+		ha1 := md5hex("user:test:pass")
+		ha2 := md5hex(r.Method + ":" + params["uri"])
+		expected := md5hex(strings.Join([]string{ha1, params["nonce"], params["nc"], params["cnonce"], params["qop"], ha2}, ":"))
+		if params["response"] != expected || params["opaque"] != "xyz" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	client := &http.Client{Transport: authTransport(&AuthConfig{Type: AuthTypeDigest, Digest: &DigestAuthConfig{Username: "user", Password: "pass"}}, http.DefaultTransport)}
+	req, _ := http.NewRequest("POST", ts.URL+"/protected?x=1", strings.NewReader(`{"a":1}`))
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
@@ -0,0 +1,262 @@
+package services
+
+import (
+	"backend/models"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Body modes for models.Request.BodyMode. An empty mode behaves like raw.
+const (
+	BodyModeNone       = "none"
+	BodyModeRaw        = "raw"
+	BodyModeJSON       = "json"
+	BodyModeXML        = "xml"
+	BodyModeText       = "text"
+	BodyModeURLEncoded = "urlencoded"
+	BodyModeFormData   = "formdata"
+	BodyModeBinary     = "binary"
+)
+
+// RequestParam is a key/value pair used for query params, urlencoded and
+// multipart bodies. Params are enabled unless Enabled is explicitly false.
+type RequestParam struct {
+	Key         string `json:"key"`
+	Value       string `json:"value"`
+	Enabled     *bool  `json:"enabled,omitempty"`
+	Type        string `json:"type,omitempty"`         // text (default) or file, formdata only
+	FileID      string `json:"file_id,omitempty"`      // uploaded file for file parts
+	ContentType string `json:"content_type,omitempty"` // overrides the part content type
+}
+
+func (p RequestParam) enabled() bool {
+	return p.Enabled == nil || *p.Enabled
+}
+
+// BinaryBody is the Body JSON for binary mode.
+type BinaryBody struct {
+	FileID      string `json:"file_id"`
+	ContentType string `json:"content_type,omitempty"`
+}
+
+// ParseRequestParams accepts either a JSON object of key/values or an array of
+// RequestParam and returns the params in a stable order.
+func ParseRequestParams(raw string) ([]RequestParam, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" || raw == "null" {
+		return nil, nil
+	}
+
+	if strings.HasPrefix(raw, "[") {
+		var params []RequestParam
+		if err := json.Unmarshal([]byte(raw), &params); err != nil {
+			return nil, fmt.Errorf("invalid params: %w", err)
+		}
+		return params, nil
+	}
+
+	var object map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &object); err != nil {
+		return nil, fmt.Errorf("invalid params: %w", err)
+	}
+	keys := make([]string, 0, len(object))
+	for k := range object {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	params := make([]RequestParam, 0, len(keys))
+	for _, k := range keys {
+		value := ""
+		switch v := object[k].(type) {
+		case string:
+			value = v
+		case nil:
+		default:
+			value = fmt.Sprint(v)
+		}
+		params = append(params, RequestParam{Key: k, Value: value})
+	}
+	return params, nil
+}
+
+// applyQueryParams appends the enabled params to any query already in rawURL.
+func applyQueryParams(rawURL, queryParams string) (string, error) {
+	params, err := ParseRequestParams(queryParams)
+	if err != nil || len(params) == 0 {
+		return rawURL, err
+	}
+
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	query := parsed.Query()
+	for _, p := range params {
+		if p.enabled() && p.Key != "" {
+			query.Add(p.Key, p.Value)
+		}
+	}
+	parsed.RawQuery = query.Encode()
+	return parsed.String(), nil
+}
+
+// buildRequestBody encodes the request body for its mode and returns the
+// Content-Type it implies. A nil body means nothing should be sent.
+func (s *RequestService) buildRequestBody(request *models.Request) ([]byte, string, error) {
+	switch request.BodyMode {
+	case BodyModeNone:
+		return nil, "", nil
+
+	case "", BodyModeRaw:
+		if request.Body == "" || request.Body == "null" {
+			return nil, "", nil
+		}
+		return []byte(request.Body), "", nil
+
+	case BodyModeJSON:
+		if request.Body == "" || request.Body == "null" {
+			return nil, "", nil
+		}
+		return []byte(request.Body), "application/json", nil
+
+	case BodyModeXML:
+		return []byte(unquoteBody(request.Body)), "application/xml", nil
+
+	case BodyModeText:
+		return []byte(unquoteBody(request.Body)), "text/plain; charset=utf-8", nil
+
+	case BodyModeURLEncoded:
+		params, err := ParseRequestParams(request.Body)
+		if err != nil {
+			return nil, "", err
+		}
+		form := url.Values{}
+		for _, p := range params {
+			if p.enabled() {
+				form.Add(p.Key, p.Value)
+			}
+		}
+		return []byte(form.Encode()), "application/x-www-form-urlencoded", nil
+
+	case BodyModeFormData:
+		return s.buildMultipartBody(request)
+
+	case BodyModeBinary:
+		var binary BinaryBody
+		if err := json.Unmarshal([]byte(request.Body), &binary); err != nil {
+			return nil, "", fmt.Errorf("invalid binary body: %w", err)
+		}
+		file, err := s.loadBodyFile(binary.FileID, request.Collection.WorkspaceID)
+		if err != nil {
+			return nil, "", err
+		}
+		contentType := binary.ContentType
+		if contentType == "" {
+			contentType = file.ContentType
+		}
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		return file.Data, contentType, nil
+
+	default:
+		return nil, "", fmt.Errorf("unsupported body mode: %s", request.BodyMode)
+	}
+}
+
+func (s *RequestService) buildMultipartBody(request *models.Request) ([]byte, string, error) {
+	params, err := ParseRequestParams(request.Body)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	for _, p := range params {
+		if !p.enabled() {
+			continue
+		}
+
+		if p.Type != "file" {
+			if p.ContentType == "" {
+				if err := writer.WriteField(p.Key, p.Value); err != nil {
+					return nil, "", err
+				}
+				continue
+			}
+			header := make(textproto.MIMEHeader)
+			header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"`, escapeQuotes(p.Key)))
+			header.Set("Content-Type", p.ContentType)
+			part, err := writer.CreatePart(header)
+			if err != nil {
+				return nil, "", err
+			}
+			part.Write([]byte(p.Value))
+			continue
+		}
+
+		file, err := s.loadBodyFile(p.FileID, request.Collection.WorkspaceID)
+		if err != nil {
+			return nil, "", err
+		}
+		contentType := p.ContentType
+		if contentType == "" {
+			contentType = file.ContentType
+		}
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, escapeQuotes(p.Key), escapeQuotes(file.Name)))
+		header.Set("Content-Type", contentType)
+		part, err := writer.CreatePart(header)
+		if err != nil {
+			return nil, "", err
+		}
+		part.Write(file.Data)
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), writer.FormDataContentType(), nil
+}
+
+// loadBodyFile fetches an uploaded file, scoped to the request's workspace.
+func (s *RequestService) loadBodyFile(fileID string, workspaceID uuid.UUID) (*models.File, error) {
+	id, err := uuid.Parse(fileID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid file id: %q", fileID)
+	}
+
+	var file models.File
+	if err := s.db.Where("id = ? AND workspace_id = ?", id, workspaceID).First(&file).Error; err != nil {
+		return nil, fmt.Errorf("file %s not found: %w", fileID, err)
+	}
+	return &file, nil
+}
+
+// unquoteBody returns the text of a body stored as a JSON string literal,
+// which is how the request handler persists plain text bodies.
+func unquoteBody(body string) string {
+	var text string
+	if strings.HasPrefix(body, `"`) && json.Unmarshal([]byte(body), &text) == nil {
+		return text
+	}
+	return body
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
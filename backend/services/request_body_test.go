@@ -0,0 +1,120 @@
+package services
+
+import (
+	"backend/models"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyQueryParams(t *testing.T) {
+	t.Run("Object form", func(t *testing.T) {
+		result, err := applyQueryParams("http://api.test/items?sort=asc", `{"page":2,"q":"a b"}`)
+		require.NoError(t, err)
+		parsed, _ := url.Parse(result)
+		assert.Equal(t, "asc", parsed.Query().Get("sort"))
+		assert.Equal(t, "2", parsed.Query().Get("page"))
+		assert.Equal(t, "a b", parsed.Query().Get("q"))
+	})
+
+	t.Run("Array form skips disabled params", func(t *testing.T) {
+		result, err := applyQueryParams("http://api.test/items", `[{"key":"tag","value":"a"},{"key":"tag","value":"b"},{"key":"debug","value":"1","enabled":false}]`)
+		require.NoError(t, err)
+		parsed, _ := url.Parse(result)
+		assert.Equal(t, []string{"a", "b"}, parsed.Query()["tag"])
+		assert.False(t, parsed.Query().Has("debug"))
+	})
+
+	t.Run("No params leaves URL untouched", func(t *testing.T) {
+		result, err := applyQueryParams("http://api.test/items?x=1", "null")
+		require.NoError(t, err)
+		assert.Equal(t, "http://api.test/items?x=1", result)
+	})
+}
+
+func TestRequestService_BuildRequestBody(t *testing.T) {
+	service := &RequestService{}
+
+	t.Run("Text mode unquotes stored string", func(t *testing.T) {
+		body, contentType, err := service.buildRequestBody(&models.Request{BodyMode: BodyModeText, Body: `"hello world"`})
+		require.NoError(t, err)
+		assert.Equal(t, "hello world", string(body))
+		assert.Equal(t, "text/plain; charset=utf-8", contentType)
+	})
+
+	t.Run("JSON mode", func(t *testing.T) {
+		body, contentType, err := service.buildRequestBody(&models.Request{BodyMode: BodyModeJSON, Body: `{"a":1}`})
+		require.NoError(t, err)
+		assert.Equal(t, `{"a":1}`, string(body))
+		assert.Equal(t, "application/json", contentType)
+	})
+
+	t.Run("URL encoded", func(t *testing.T) {
+		body, contentType, err := service.buildRequestBody(&models.Request{
+			BodyMode: BodyModeURLEncoded,
+			Body:     `[{"key":"user","value":"jo&e"},{"key":"skip","value":"x","enabled":false}]`,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "user=jo%26e", string(body))
+		assert.Equal(t, "application/x-www-form-urlencoded", contentType)
+	})
+
+	t.Run("Raw null body is not sent", func(t *testing.T) {
+		body, contentType, err := service.buildRequestBody(&models.Request{Body: "null"})
+		require.NoError(t, err)
+		assert.Nil(t, body)
+		assert.Empty(t, contentType)
+	})
+}
+
+func TestRequestService_BuildMultipartBody_WithFile(t *testing.T) {
+	db, mock := setupTestDBRequest(t)
+	service := NewRequestService(db, nil)
+
+	workspaceID := uuid.New()
+	fileID := uuid.New()
+
+	mock.ExpectQuery(`(?i)SELECT \* FROM "files" WHERE \(id = \$1 AND workspace_id = \$2\)`).
+		WithArgs(fileID, workspaceID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "workspace_id", "name", "content_type", "data"}).
+			AddRow(fileID, workspaceID, "avatar.png", "image/png", []byte("PNGDATA")))
+
+	request := &models.Request{
+		BodyMode:   BodyModeFormData,
+		Body:       `[{"key":"name","value":"joe"},{"key":"avatar","type":"file","file_id":"` + fileID.String() + `"}]`,
+		Collection: models.Collection{WorkspaceID: workspaceID},
+	}
+
+	body, contentType, err := service.buildRequestBody(request)
+	require.NoError(t, err)
+
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	require.NoError(t, err)
+	assert.Equal(t, "multipart/form-data", mediaType)
+
+	reader := multipart.NewReader(strings.NewReader(string(body)), params["boundary"])
+
+	part, err := reader.NextPart()
+	require.NoError(t, err)
+	assert.Equal(t, "name", part.FormName())
+	value, _ := io.ReadAll(part)
+	assert.Equal(t, "joe", string(value))
+
+	part, err = reader.NextPart()
+	require.NoError(t, err)
+	assert.Equal(t, "avatar", part.FormName())
+	assert.Equal(t, "avatar.png", part.FileName())
+	assert.Equal(t, "image/png", part.Header.Get("Content-Type"))
+	data, _ := io.ReadAll(part)
+	assert.Equal(t, "PNGDATA", string(data))
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
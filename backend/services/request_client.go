@@ -0,0 +1,342 @@
+package services
+
+import (
+	"backend/models"
+	"container/list"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Redirect policies for ClientSettings.RedirectPolicy.
+const (
+	RedirectFollow = "follow" // follow up to DefaultMaxRedirects
+	RedirectLimit  = "limit"  // follow up to MaxRedirects
+	RedirectNone   = "none"   // return the 3xx response as-is
+)
+
+const (
+	DefaultRequestTimeout = 30 * time.Second
+	DefaultMaxRedirects   = 10
+)
+
+// ClientSettings configure the HTTP client used to send a request. They are
+// stored as JSON on workspaces, collections and requests; each level only
+// overrides the fields it sets.
+type ClientSettings struct {
+	TimeoutMs          *int         `json:"timeout_ms,omitempty"`
+	CACertificates     *string      `json:"ca_certificates,omitempty"`       // PEM bundle trusted in addition to the system roots
+	ClientCertSecretID *string      `json:"client_cert_secret_id,omitempty"` // secret holding the PEM client certificate
+	ClientKeySecretID  *string      `json:"client_key_secret_id,omitempty"`  // secret holding the PEM private key
+	InsecureSkipVerify *bool        `json:"insecure_skip_verify,omitempty"`
+	ProxyURL           *string      `json:"proxy_url,omitempty"` // http://, https:// or socks5://
+	RedirectPolicy     *string      `json:"redirect_policy,omitempty"`
+	MaxRedirects       *int         `json:"max_redirects,omitempty"`
+	HTTP2              *bool        `json:"http2,omitempty"`
+	Retry              *RetryPolicy `json:"retry,omitempty"` // replaced as a whole, not merged field by field
+}
+
+// RedirectHop is one followed redirect, stored in Execution.RedirectChain.
+type RedirectHop struct {
+	URL        string `json:"url"`
+	StatusCode int    `json:"status_code"`
+	Location   string `json:"location"`
+}
+
+// ParseClientSettings parses a stored settings column. Empty means unset.
+func ParseClientSettings(raw string) (*ClientSettings, error) {
+	settings := &ClientSettings{}
+	raw = strings.TrimSpace(raw)
+	if raw == "" || raw == "null" {
+		return settings, nil
+	}
+	if err := json.Unmarshal([]byte(raw), settings); err != nil {
+		return nil, fmt.Errorf("invalid client settings: %w", err)
+	}
+	return settings, nil
+}
+
+// merge overlays every field set in other onto s.
+func (s *ClientSettings) merge(other *ClientSettings) {
+	if other.TimeoutMs != nil {
+		s.TimeoutMs = other.TimeoutMs
+	}
+	if other.CACertificates != nil {
+		s.CACertificates = other.CACertificates
+	}
+	if other.ClientCertSecretID != nil {
+		s.ClientCertSecretID = other.ClientCertSecretID
+	}
+	if other.ClientKeySecretID != nil {
+		s.ClientKeySecretID = other.ClientKeySecretID
+	}
+	if other.InsecureSkipVerify != nil {
+		s.InsecureSkipVerify = other.InsecureSkipVerify
+	}
+	if other.ProxyURL != nil {
+		s.ProxyURL = other.ProxyURL
+	}
+	if other.RedirectPolicy != nil {
+		s.RedirectPolicy = other.RedirectPolicy
+	}
+	if other.MaxRedirects != nil {
+		s.MaxRedirects = other.MaxRedirects
+	}
+	if other.HTTP2 != nil {
+		s.HTTP2 = other.HTTP2
+	}
+	if other.Retry != nil {
+		s.Retry = other.Retry
+	}
+}
+
+// resolveClientSettings merges workspace, collection, folder and request
+// settings, innermost winning.
+func resolveClientSettings(workspaceSettings string, request *models.Request) (*ClientSettings, error) {
+	layers := []string{workspaceSettings, request.Collection.Settings}
+	for _, folder := range request.Folders {
+		layers = append(layers, folder.Settings)
+	}
+	layers = append(layers, request.Settings)
+
+	resolved := &ClientSettings{}
+	for _, raw := range layers {
+		settings, err := ParseClientSettings(raw)
+		if err != nil {
+			return nil, err
+		}
+		resolved.merge(settings)
+	}
+	return resolved, nil
+}
+
+// transportOptions are the resolved settings that affect the transport, and
+// so identify which pooled transport a request can share.
+type transportOptions struct {
+	caPEM     string
+	certPEM   string
+	keyPEM    string
+	insecure  bool
+	proxyURL  string
+	disableH2 bool
+}
+
+func (o transportOptions) key() string {
+	h := sha256.New()
+	for _, part := range []string{o.caPEM, o.certPEM, o.keyPEM, o.proxyURL} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	fmt.Fprintf(h, "%t|%t", o.insecure, o.disableH2)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// maxPooledTransports bounds the transports kept at once. Every client
+// certificate, CA bundle and proxy makes a distinct option set.
+const maxPooledTransports = 64
+
+// transportPool keeps one transport per distinct option set so repeated
+// executions reuse idle connections. Past its size, the least recently used
+// transport is dropped and its idle connections closed.
+type transportPool struct {
+	mu         sync.Mutex
+	size       int
+	recent     *list.List // of *pooledTransport, most recently used first
+	transports map[string]*list.Element
+}
+
+type pooledTransport struct {
+	key       string
+	transport *http.Transport
+}
+
+func newTransportPool(size int) *transportPool {
+	return &transportPool{size: size, recent: list.New(), transports: make(map[string]*list.Element)}
+}
+
+var sharedTransports = newTransportPool(maxPooledTransports)
+
+func (p *transportPool) get(opts transportOptions) (*http.Transport, error) {
+	key := opts.key()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if element, ok := p.transports[key]; ok {
+		p.recent.MoveToFront(element)
+		return element.Value.(*pooledTransport).transport, nil
+	}
+	transport, err := newTransport(opts)
+	if err != nil {
+		return nil, err
+	}
+	p.transports[key] = p.recent.PushFront(&pooledTransport{key: key, transport: transport})
+	for p.recent.Len() > p.size {
+		// Requests still using it finish; only idle connections close
+		oldest := p.recent.Remove(p.recent.Back()).(*pooledTransport)
+		delete(p.transports, oldest.key)
+		oldest.transport.CloseIdleConnections()
+	}
+	return transport, nil
+}
+
+func newTransport(opts transportOptions) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = 16
+
+	tlsConfig, err := newTLSConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	if opts.proxyURL != "" {
+		proxy, err := url.Parse(opts.proxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy url: %w", err)
+		}
+		switch proxy.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return nil, fmt.Errorf("unsupported proxy scheme: %s", proxy.Scheme)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	if opts.disableH2 {
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	}
+
+	return transport, nil
+}
+
+// newTLSConfig builds the TLS configuration shared by HTTP and gRPC requests.
+func newTLSConfig(opts transportOptions) (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: opts.insecure}
+	if opts.caPEM != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM([]byte(opts.caPEM)) {
+			return nil, errors.New("no certificates found in CA bundle")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if opts.certPEM != "" || opts.keyPEM != "" {
+		cert, err := tls.X509KeyPair([]byte(opts.certPEM), []byte(opts.keyPEM))
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// transportOptions resolves the transport settings, loading the client
+// certificate and key from the workspace's secrets.
+func (s *RequestService) transportOptions(settings *ClientSettings, workspaceID uuid.UUID) (transportOptions, error) {
+	opts := transportOptions{}
+	if settings.CACertificates != nil {
+		opts.caPEM = *settings.CACertificates
+	}
+	if settings.InsecureSkipVerify != nil {
+		opts.insecure = *settings.InsecureSkipVerify
+	}
+	if settings.ProxyURL != nil {
+		opts.proxyURL = *settings.ProxyURL
+	}
+	if settings.HTTP2 != nil {
+		opts.disableH2 = !*settings.HTTP2
+	}
+
+	if settings.ClientCertSecretID != nil || settings.ClientKeySecretID != nil {
+		if settings.ClientCertSecretID == nil || settings.ClientKeySecretID == nil {
+			return opts, errors.New("client certificate and key secrets must both be set")
+		}
+		var err error
+		if opts.certPEM, err = s.secretValue(*settings.ClientCertSecretID, workspaceID); err != nil {
+			return opts, fmt.Errorf("client certificate: %w", err)
+		}
+		if opts.keyPEM, err = s.secretValue(*settings.ClientKeySecretID, workspaceID); err != nil {
+			return opts, fmt.Errorf("client key: %w", err)
+		}
+	}
+	return opts, nil
+}
+
+// buildClient returns a client for the resolved settings. Followed redirects
+// are appended to chain.
+func (s *RequestService) buildClient(settings *ClientSettings, workspaceID uuid.UUID, chain *[]RedirectHop) (*http.Client, error) {
+	opts, err := s.transportOptions(settings, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	transport, err := s.transports.get(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := DefaultRequestTimeout
+	if settings.TimeoutMs != nil && *settings.TimeoutMs > 0 {
+		timeout = time.Duration(*settings.TimeoutMs) * time.Millisecond
+	}
+
+	policy := RedirectFollow
+	if settings.RedirectPolicy != nil && *settings.RedirectPolicy != "" {
+		policy = *settings.RedirectPolicy
+	}
+	maxRedirects := DefaultMaxRedirects
+	switch policy {
+	case RedirectFollow:
+	case RedirectLimit:
+		if settings.MaxRedirects == nil || *settings.MaxRedirects < 0 {
+			return nil, errors.New("max_redirects is required for the limit redirect policy")
+		}
+		maxRedirects = *settings.MaxRedirects
+	case RedirectNone:
+		maxRedirects = 0
+	default:
+		return nil, fmt.Errorf("unsupported redirect policy: %s", policy)
+	}
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if policy == RedirectNone {
+				return http.ErrUseLastResponse
+			}
+			if len(via) > maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			hop := RedirectHop{URL: via[len(via)-1].URL.String(), Location: req.URL.String()}
+			if req.Response != nil {
+				hop.StatusCode = req.Response.StatusCode
+			}
+			*chain = append(*chain, hop)
+			return nil
+		},
+	}, nil
+}
+
+func (s *RequestService) secretValue(secretID string, workspaceID uuid.UUID) (string, error) {
+	id, err := uuid.Parse(secretID)
+	if err != nil {
+		return "", fmt.Errorf("invalid secret id: %q", secretID)
+	}
+	return s.secrets.GetSecret(id, workspaceID)
+}
@@ -0,0 +1,207 @@
+package services
+
+import (
+	"backend/models"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveClientSettings(t *testing.T) {
+	request := &models.Request{
+		Settings: `{"timeout_ms":500}`,
+		Collection: models.Collection{
+			Settings: `{"timeout_ms":2000,"redirect_policy":"none"}`,
+		},
+	}
+
+	settings, err := resolveClientSettings(`{"insecure_skip_verify":true,"redirect_policy":"follow"}`, request)
+	require.NoError(t, err)
+	assert.Equal(t, 500, *settings.TimeoutMs)
+	assert.Equal(t, RedirectNone, *settings.RedirectPolicy)
+	assert.True(t, *settings.InsecureSkipVerify)
+	assert.Nil(t, settings.ProxyURL)
+}
+
+func TestRequestService_BuildClient_Redirects(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/a", func(w http.ResponseWriter, r *http.Request) { http.Redirect(w, r, "/b", http.StatusFound) })
+	mux.HandleFunc("/b", func(w http.ResponseWriter, r *http.Request) { http.Redirect(w, r, "/c", http.StatusMovedPermanently) })
+	mux.HandleFunc("/c", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("done")) })
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	service := &RequestService{transports: sharedTransports}
+	policy := func(name string, max int) *ClientSettings {
+		return &ClientSettings{RedirectPolicy: &name, MaxRedirects: &max}
+	}
+
+	t.Run("Follow records the chain", func(t *testing.T) {
+		var chain []RedirectHop
+		client, err := service.buildClient(&ClientSettings{}, uuid.Nil, &chain)
+		require.NoError(t, err)
+
+		resp, err := client.Get(ts.URL + "/a")
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Len(t, chain, 2)
+		assert.Equal(t, RedirectHop{URL: ts.URL + "/a", StatusCode: http.StatusFound, Location: ts.URL + "/b"}, chain[0])
+		assert.Equal(t, http.StatusMovedPermanently, chain[1].StatusCode)
+	})
+
+	t.Run("None returns the redirect", func(t *testing.T) {
+		var chain []RedirectHop
+		client, err := service.buildClient(policy(RedirectNone, 0), uuid.Nil, &chain)
+		require.NoError(t, err)
+
+		resp, err := client.Get(ts.URL + "/a")
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusFound, resp.StatusCode)
+		assert.Empty(t, chain)
+	})
+
+	t.Run("Limit stops after max redirects", func(t *testing.T) {
+		var chain []RedirectHop
+		client, err := service.buildClient(policy(RedirectLimit, 1), uuid.Nil, &chain)
+		require.NoError(t, err)
+
+		_, err = client.Get(ts.URL + "/a")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "stopped after 1 redirects")
+		assert.Len(t, chain, 1)
+	})
+}
+
+func TestRequestService_BuildClient_PooledTransport(t *testing.T) {
+	service := &RequestService{transports: newTransportPool(maxPooledTransports)}
+	proxy := "socks5://127.0.0.1:1080"
+
+	first, err := service.buildClient(&ClientSettings{}, uuid.Nil, new([]RedirectHop))
+	require.NoError(t, err)
+	second, err := service.buildClient(&ClientSettings{}, uuid.Nil, new([]RedirectHop))
+	require.NoError(t, err)
+	proxied, err := service.buildClient(&ClientSettings{ProxyURL: &proxy}, uuid.Nil, new([]RedirectHop))
+	require.NoError(t, err)
+
+	assert.Same(t, first.Transport, second.Transport)
+	assert.NotSame(t, first.Transport, proxied.Transport)
+
+	bad := "ftp://proxy"
+	_, err = service.buildClient(&ClientSettings{ProxyURL: &bad}, uuid.Nil, new([]RedirectHop))
+	assert.EqualError(t, err, "unsupported proxy scheme: ftp")
+}
+
+func TestTransportPool_EvictsLeastRecentlyUsed(t *testing.T) {
+	pool := newTransportPool(2)
+	options := func(port int) transportOptions {
+		return transportOptions{proxyURL: fmt.Sprintf("http://127.0.0.1:%d", port)}
+	}
+
+	first, err := pool.get(options(1))
+	require.NoError(t, err)
+	_, err = pool.get(options(2))
+	require.NoError(t, err)
+	again, err := pool.get(options(1))
+	require.NoError(t, err)
+	assert.Same(t, first, again)
+
+	// The second option set is now the least recently used
+	_, err = pool.get(options(3))
+	require.NoError(t, err)
+	assert.Equal(t, 2, pool.recent.Len())
+	assert.Contains(t, pool.transports, options(1).key())
+	assert.NotContains(t, pool.transports, options(2).key())
+	assert.Contains(t, pool.transports, options(3).key())
+}
+
+func TestRequestService_BuildClient_MutualTLS(t *testing.T) {
+	db, mock := setupTestDBRequest(t)
+	service := NewRequestService(db, NewSecretsService(db, "test-key"))
+	service.transports = newTransportPool(maxPooledTransports)
+
+	certPEM, keyPEM := generateTestCertificate(t)
+	clientPool := x509.NewCertPool()
+	clientPool.AppendCertsFromPEM(certPEM)
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	ts.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientPool}
+	ts.StartTLS()
+	defer ts.Close()
+
+	serverCA := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}))
+
+	workspaceID := uuid.New()
+	certSecretID, keySecretID := uuid.New(), uuid.New()
+	for _, secret := range []struct {
+		id    uuid.UUID
+		value []byte
+	}{{certSecretID, certPEM}, {keySecretID, keyPEM}} {
+		encrypted, err := service.secrets.encrypt(string(secret.value))
+		require.NoError(t, err)
+		mock.ExpectQuery(`(?i)SELECT \* FROM "secrets" WHERE id = \$1 AND workspace_id = \$2`).
+			WithArgs(secret.id, workspaceID, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "workspace_id", "value"}).AddRow(secret.id, workspaceID, encrypted))
+	}
+
+	certID, keyID := certSecretID.String(), keySecretID.String()
+	settings := &ClientSettings{CACertificates: &serverCA, ClientCertSecretID: &certID, ClientKeySecretID: &keyID}
+
+	client, err := service.buildClient(settings, workspaceID, new([]RedirectHop))
+	require.NoError(t, err)
+
+	resp, err := client.Get(ts.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "tracely-client", string(body))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// generateTestCertificate returns a self-signed client certificate and key.
+func generateTestCertificate(t *testing.T) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "tracely-client"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM
+}
@@ -0,0 +1,502 @@
+package services
+
+import (
+	"backend/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// ImportCurl parses a curl command and adds it to the collection as a request.
+// An empty name is derived from the method and URL path.
+func (s *RequestService) ImportCurl(collectionID, userID uuid.UUID, command, name string) (*models.Request, error) {
+	request, err := ParseCurl(command)
+	if err != nil {
+		return nil, err
+	}
+	if name != "" {
+		request.Name = name
+	}
+	return s.Create(collectionID, request, userID)
+}
+
+// curlShortValueFlags are the short curl flags that take a value.
+const curlShortValueFlags = "XHduFAebxmowcD"
+
+// ParseCurl converts a curl command line into a request. Headers, data, form
+// parts, basic/digest/bearer credentials and the common transport flags are
+// understood; output and verbosity flags are ignored.
+func ParseCurl(command string) (*models.Request, error) {
+	args, err := splitShellWords(command)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 || args[0] != "curl" {
+		return nil, errors.New("not a curl command")
+	}
+
+	var (
+		method, rawURL, userInfo string
+		data                     []string
+		form                     []RequestParam
+		useGet, digest, follow   bool
+		bearer                   string
+	)
+	headers := map[string]string{}
+	settings := ClientSettings{}
+	setHeader := func(key, value string) {
+		for existing := range headers {
+			if strings.EqualFold(existing, key) {
+				delete(headers, existing)
+			}
+		}
+		headers[key] = value
+	}
+
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		flag, inline, hasInline := arg, "", false
+
+		// --flag=value and grouped short flags, e.g. -sSLk. A short flag
+		// that takes a value ends its group and takes the rest of the arg
+		// or the next one, e.g. -XPOST or -sX POST
+		if strings.HasPrefix(arg, "--") {
+			if eq := strings.Index(arg, "="); eq > 0 {
+				flag, inline, hasInline = arg[:eq], arg[eq+1:], true
+			}
+		} else if strings.HasPrefix(arg, "-") && len(arg) > 2 {
+			flag = ""
+			for j := 1; j < len(arg) && flag == ""; j++ {
+				switch short := arg[j]; {
+				case strings.IndexByte(curlShortValueFlags, short) >= 0:
+					flag = "-" + string(short)
+					if j+1 < len(arg) {
+						inline, hasInline = arg[j+1:], true
+					}
+				case short == 'L':
+					follow = true
+				case short == 'k':
+					insecure := true
+					settings.InsecureSkipVerify = &insecure
+				case short == 'G':
+					useGet = true
+				case short == 'I':
+					method = "HEAD"
+				}
+			}
+			if flag == "" {
+				continue
+			}
+		}
+
+		value := func() (string, error) {
+			if hasInline {
+				return inline, nil
+			}
+			if i+1 >= len(args) {
+				return "", fmt.Errorf("%s needs a value", flag)
+			}
+			i++
+			return args[i], nil
+		}
+
+		switch flag {
+		case "-X", "--request":
+			v, err := value()
+			if err != nil {
+				return nil, err
+			}
+			method = strings.ToUpper(v)
+		case "-H", "--header":
+			v, err := value()
+			if err != nil {
+				return nil, err
+			}
+			key, headerValue, ok := strings.Cut(v, ":")
+			if !ok {
+				continue
+			}
+			setHeader(strings.TrimSpace(key), strings.TrimSpace(headerValue))
+		case "-d", "--data", "--data-raw", "--data-ascii", "--data-binary", "--data-urlencode", "--json":
+			v, err := value()
+			if err != nil {
+				return nil, err
+			}
+			if strings.HasPrefix(v, "@") && flag != "--data-raw" {
+				return nil, fmt.Errorf("reading request data from a file is not supported: %s", v)
+			}
+			if flag == "--data-urlencode" {
+				v = encodeCurlURLData(v)
+			}
+			if flag == "--json" {
+				setHeader("Content-Type", "application/json")
+				setHeader("Accept", "application/json")
+			}
+			data = append(data, v)
+		case "-F", "--form", "--form-string":
+			v, err := value()
+			if err != nil {
+				return nil, err
+			}
+			key, formValue, _ := strings.Cut(v, "=")
+			if flag != "--form-string" && (strings.HasPrefix(formValue, "@") || strings.HasPrefix(formValue, "<")) {
+				return nil, fmt.Errorf("uploading form parts from a file is not supported: %s", v)
+			}
+			form = append(form, RequestParam{Key: key, Value: formValue})
+		case "-u", "--user":
+			v, err := value()
+			if err != nil {
+				return nil, err
+			}
+			userInfo = v
+		case "--digest":
+			digest = true
+		case "--basic":
+			digest = false
+		case "--oauth2-bearer":
+			v, err := value()
+			if err != nil {
+				return nil, err
+			}
+			bearer = v
+		case "-A", "--user-agent":
+			v, err := value()
+			if err != nil {
+				return nil, err
+			}
+			setHeader("User-Agent", v)
+		case "-e", "--referer":
+			v, err := value()
+			if err != nil {
+				return nil, err
+			}
+			setHeader("Referer", v)
+		case "-b", "--cookie":
+			v, err := value()
+			if err != nil {
+				return nil, err
+			}
+			if strings.Contains(v, "=") {
+				setHeader("Cookie", v)
+			}
+		case "-x", "--proxy":
+			v, err := value()
+			if err != nil {
+				return nil, err
+			}
+			if !strings.Contains(v, "://") {
+				v = "http://" + v
+			}
+			settings.ProxyURL = &v
+		case "-m", "--max-time":
+			v, err := value()
+			if err != nil {
+				return nil, err
+			}
+			seconds, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid --max-time: %s", v)
+			}
+			timeoutMs := int(seconds * 1000)
+			settings.TimeoutMs = &timeoutMs
+		case "--max-redirs":
+			v, err := value()
+			if err != nil {
+				return nil, err
+			}
+			maxRedirects, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid --max-redirs: %s", v)
+			}
+			settings.MaxRedirects = &maxRedirects
+		case "--url":
+			v, err := value()
+			if err != nil {
+				return nil, err
+			}
+			rawURL = v
+		case "-L", "--location":
+			follow = true
+		case "-k", "--insecure":
+			insecure := true
+			settings.InsecureSkipVerify = &insecure
+		case "-G", "--get":
+			useGet = true
+		case "-I", "--head":
+			method = "HEAD"
+		case "--http2":
+			http2 := true
+			settings.HTTP2 = &http2
+		case "--http1.1", "--http1.0":
+			http2 := false
+			settings.HTTP2 = &http2
+		case "-o", "--output", "-w", "--write-out", "--connect-timeout", "--retry", "-c", "--cookie-jar", "-D", "--dump-header":
+			// Output and client-side options with a value that have no request equivalent
+			if !hasInline {
+				i++
+			}
+		default:
+			if strings.HasPrefix(arg, "-") {
+				continue // --compressed, -s, -v and other switches
+			}
+			if rawURL == "" {
+				rawURL = arg
+			}
+		}
+	}
+
+	if rawURL == "" {
+		return nil, errors.New("curl command has no URL")
+	}
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + rawURL
+	}
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+
+	request := &models.Request{URL: rawURL, BodyMode: BodyModeNone}
+
+	joined := strings.Join(data, "&")
+	switch {
+	case useGet && len(data) > 0:
+		if parsedURL.RawQuery != "" {
+			parsedURL.RawQuery += "&" + joined
+		} else {
+			parsedURL.RawQuery = joined
+		}
+		request.URL = parsedURL.String()
+	case len(form) > 0:
+		body, _ := json.Marshal(form)
+		request.BodyMode, request.Body = BodyModeFormData, string(body)
+	case len(data) > 0:
+		request.BodyMode, request.Body = curlBody(joined, headerValue(headers, "Content-Type"))
+	}
+
+	if method == "" {
+		method = "GET"
+		if !useGet && (len(data) > 0 || len(form) > 0) {
+			method = "POST"
+		}
+	}
+	request.Method = method
+	request.Name = method + " " + parsedURL.Path
+	if parsedURL.Path == "" {
+		request.Name = method + " /"
+	}
+
+	// Credentials in the URL behave like -u
+	if parsedURL.User != nil && userInfo == "" {
+		userInfo = parsedURL.User.String()
+		if unescaped, err := url.PathUnescape(userInfo); err == nil {
+			userInfo = unescaped
+		}
+		parsedURL.User = nil
+		request.URL = parsedURL.String()
+	}
+
+	var auth *AuthConfig
+	switch {
+	case userInfo != "":
+		username, password, _ := strings.Cut(userInfo, ":")
+		if digest {
+			auth = &AuthConfig{Type: AuthTypeDigest, Digest: &DigestAuthConfig{Username: username, Password: password}}
+		} else {
+			auth = &AuthConfig{Type: AuthTypeBasic, Basic: &BasicAuthConfig{Username: username, Password: password}}
+		}
+	case bearer != "":
+		auth = &AuthConfig{Type: AuthTypeBearer, Bearer: &BearerAuthConfig{Token: bearer}}
+	}
+	if auth != nil {
+		authJSON, _ := json.Marshal(auth)
+		request.Auth = string(authJSON)
+	}
+
+	// curl only follows redirects with -L, and --max-redirs needs it to matter
+	switch {
+	case !follow:
+		policy := RedirectNone
+		settings.RedirectPolicy = &policy
+		settings.MaxRedirects = nil
+	case settings.MaxRedirects != nil:
+		policy := RedirectLimit
+		settings.RedirectPolicy = &policy
+	}
+	if settings != (ClientSettings{}) {
+		settingsJSON, _ := json.Marshal(settings)
+		request.Settings = string(settingsJSON)
+	}
+
+	// Multipart boundaries are generated per request
+	if len(form) > 0 {
+		for key := range headers {
+			if strings.EqualFold(key, "Content-Type") && strings.HasPrefix(headers[key], "multipart/") {
+				delete(headers, key)
+			}
+		}
+	}
+	headersJSON, _ := json.Marshal(headers)
+	request.Headers = string(headersJSON)
+
+	return request, nil
+}
+
+// curlBody picks a body mode for -d data from the content type, defaulting
+// to urlencoded like curl itself.
+func curlBody(data, contentType string) (string, string) {
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	quoted := func() string {
+		encoded, _ := json.Marshal(data)
+		return string(encoded)
+	}
+
+	switch {
+	case strings.HasSuffix(mediaType, "json"), mediaType == "" && json.Valid([]byte(data)) && strings.HasPrefix(strings.TrimSpace(data), "{"):
+		if json.Valid([]byte(data)) {
+			return BodyModeJSON, data
+		}
+		return BodyModeText, quoted()
+	case strings.HasSuffix(mediaType, "xml"):
+		return BodyModeXML, quoted()
+	case mediaType == "" || mediaType == "application/x-www-form-urlencoded":
+		values, err := url.ParseQuery(data)
+		if err != nil {
+			return BodyModeText, quoted()
+		}
+		params := []RequestParam{}
+		for _, pair := range strings.Split(data, "&") {
+			key, _, _ := strings.Cut(pair, "=")
+			key, _ = url.QueryUnescape(key)
+			if len(values[key]) == 0 {
+				continue
+			}
+			params = append(params, RequestParam{Key: key, Value: values[key][0]})
+			values[key] = values[key][1:]
+		}
+		body, _ := json.Marshal(params)
+		return BodyModeURLEncoded, string(body)
+	default:
+		return BodyModeText, quoted()
+	}
+}
+
+// encodeCurlURLData applies --data-urlencode's name=content rule.
+func encodeCurlURLData(v string) string {
+	if name, content, ok := strings.Cut(v, "="); ok {
+		if name == "" {
+			return url.QueryEscape(content)
+		}
+		return name + "=" + url.QueryEscape(content)
+	}
+	return url.QueryEscape(v)
+}
+
+func headerValue(headers map[string]string, key string) string {
+	for k, v := range headers {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return ""
+}
+
+// splitShellWords splits a command line the way a POSIX shell would for the
+// quoting curl commands use: single and double quotes, $'...' strings,
+// backslash escapes and line continuations.
+func splitShellWords(command string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	runes := []rune(command)
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\\':
+			if i+1 < len(runes) {
+				i++
+				if runes[i] == '\n' || runes[i] == '\r' {
+					if runes[i] == '\r' && i+1 < len(runes) && runes[i+1] == '\n' {
+						i++
+					}
+					continue
+				}
+				word.WriteRune(runes[i])
+				inWord = true
+			}
+		case r == '\'':
+			end := indexRune(runes, '\'', i+1)
+			if end < 0 {
+				return nil, errors.New("unterminated single quote")
+			}
+			word.WriteString(string(runes[i+1 : end]))
+			i, inWord = end, true
+		case r == '$' && i+1 < len(runes) && runes[i+1] == '\'':
+			i += 2
+			for ; i < len(runes) && runes[i] != '\''; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+					switch runes[i] {
+					case 'n':
+						word.WriteRune('\n')
+					case 't':
+						word.WriteRune('\t')
+					case 'r':
+						word.WriteRune('\r')
+					default:
+						word.WriteRune(runes[i])
+					}
+					continue
+				}
+				word.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, errors.New("unterminated $' quote")
+			}
+			inWord = true
+		case r == '"':
+			i++
+			for ; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) && strings.ContainsRune("\"\\$`\n", runes[i+1]) {
+					i++
+					if runes[i] == '\n' {
+						continue
+					}
+				}
+				word.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, errors.New("unterminated double quote")
+			}
+			inWord = true
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+func indexRune(runes []rune, r rune, from int) int {
+	for i := from; i < len(runes); i++ {
+		if runes[i] == r {
+			return i
+		}
+	}
+	return -1
+}
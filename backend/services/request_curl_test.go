@@ -0,0 +1,127 @@
+package services
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCurl_HeadersAndJSONData(t *testing.T) {
+	request, err := ParseCurl(`curl -X POST 'https://api.example.com/v1/orders?page=2' \
+  -H 'Content-Type: application/json' \
+  -H "X-Trace: abc" \
+  --data-raw '{"sku":"A-1","qty":2}' --compressed -sS`)
+	require.NoError(t, err)
+
+	assert.Equal(t, "POST", request.Method)
+	assert.Equal(t, "https://api.example.com/v1/orders?page=2", request.URL)
+	assert.Equal(t, "POST /v1/orders", request.Name)
+
+	var headers map[string]string
+	require.NoError(t, json.Unmarshal([]byte(request.Headers), &headers))
+	assert.Equal(t, map[string]string{"Content-Type": "application/json", "X-Trace": "abc"}, headers)
+	assert.Equal(t, BodyModeJSON, request.BodyMode)
+	assert.JSONEq(t, `{"sku":"A-1","qty":2}`, request.Body)
+}
+
+func TestParseCurl_UserAndForm(t *testing.T) {
+	request, err := ParseCurl(`curl -u ann:s3cret -F name=report --form-string 'note=@home' https://files.test/upload`)
+	require.NoError(t, err)
+
+	assert.Equal(t, "POST", request.Method)
+	assert.Equal(t, BodyModeFormData, request.BodyMode)
+
+	params, err := ParseRequestParams(request.Body)
+	require.NoError(t, err)
+	require.Len(t, params, 2)
+	assert.Equal(t, "report", params[0].Value)
+	assert.Equal(t, "@home", params[1].Value, "--form-string values are literal")
+
+	auth, err := ParseAuthConfig(request.Auth)
+	require.NoError(t, err)
+	assert.Equal(t, AuthTypeBasic, auth.Type)
+	assert.Equal(t, "ann", auth.Basic.Username)
+	assert.Equal(t, "s3cret", auth.Basic.Password)
+}
+
+func TestParseCurl_GetDataAndSettings(t *testing.T) {
+	request, err := ParseCurl(`curl -G -sLk --max-redirs 3 -m 2.5 https://api.test/search -d q=shoes --data-urlencode 'tag=a b'`)
+	require.NoError(t, err)
+
+	assert.Equal(t, "GET", request.Method)
+	assert.Equal(t, "https://api.test/search?q=shoes&tag=a+b", request.URL)
+	assert.Equal(t, BodyModeNone, request.BodyMode)
+
+	settings, err := ParseClientSettings(request.Settings)
+	require.NoError(t, err)
+	require.NotNil(t, settings.InsecureSkipVerify)
+	assert.True(t, *settings.InsecureSkipVerify)
+	require.NotNil(t, settings.TimeoutMs)
+	assert.Equal(t, 2500, *settings.TimeoutMs)
+	require.NotNil(t, settings.MaxRedirects)
+	assert.Equal(t, 3, *settings.MaxRedirects)
+	assert.Equal(t, RedirectLimit, *settings.RedirectPolicy)
+}
+
+func TestParseCurl_GroupedShortFlags(t *testing.T) {
+	request, err := ParseCurl(`curl -sX POST https://example.com/a`)
+	require.NoError(t, err)
+	assert.Equal(t, "POST", request.Method)
+	assert.Equal(t, "https://example.com/a", request.URL)
+
+	request, err = ParseCurl(`curl -sSo /dev/null https://example.com/a`)
+	require.NoError(t, err)
+	assert.Equal(t, "GET", request.Method)
+	assert.Equal(t, "https://example.com/a", request.URL)
+
+	request, err = ParseCurl(`curl -sH 'X-Tenant: acme' -kXPUT https://example.com/a`)
+	require.NoError(t, err)
+	assert.Equal(t, "PUT", request.Method)
+	assert.JSONEq(t, `{"X-Tenant":"acme"}`, request.Headers)
+	settings, err := ParseClientSettings(request.Settings)
+	require.NoError(t, err)
+	require.NotNil(t, settings.InsecureSkipVerify)
+	assert.True(t, *settings.InsecureSkipVerify)
+}
+
+func TestParseCurl_Redirects(t *testing.T) {
+	policy := func(command string) *string {
+		request, err := ParseCurl(command)
+		require.NoError(t, err)
+		settings, err := ParseClientSettings(request.Settings)
+		require.NoError(t, err)
+		return settings.RedirectPolicy
+	}
+
+	// Without -L curl returns the redirect response itself
+	require.NotNil(t, policy(`curl https://api.test/old`))
+	assert.Equal(t, RedirectNone, *policy(`curl https://api.test/old`))
+	assert.Equal(t, RedirectNone, *policy(`curl --max-redirs 2 https://api.test/old`))
+	assert.Nil(t, policy(`curl --location https://api.test/old`), "follows by default")
+	assert.Nil(t, policy(`curl -sL https://api.test/old`))
+}
+
+func TestParseCurl_Errors(t *testing.T) {
+	_, err := ParseCurl(`wget https://example.com`)
+	assert.Error(t, err)
+
+	_, err = ParseCurl(`curl -H 'Accept: */*'`)
+	assert.Error(t, err)
+
+	_, err = ParseCurl(`curl -d @payload.json https://example.com`)
+	assert.Error(t, err)
+
+	_, err = ParseCurl(`curl -F 'file=@./out.csv;type=text/csv' https://files.test/upload`)
+	assert.Error(t, err, "form files cannot be attached on import")
+
+	_, err = ParseCurl(`curl 'https://example.com`)
+	assert.Error(t, err)
+}
+
+func TestSplitShellWords(t *testing.T) {
+	words, err := splitShellWords("curl $'a\\nb' \"x \\\"y\\\"\" 'it'\\''s' c\\\n d")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"curl", "a\nb", `x "y"`, "it's", "c", "d"}, words)
+}
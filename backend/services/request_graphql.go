@@ -0,0 +1,519 @@
+package services
+
+import (
+	"backend/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GraphQLRequest is the stored GraphQL column of a request.
+type GraphQLRequest struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+	OperationName string                 `json:"operation_name,omitempty"`
+}
+
+// graphQLPayload is the request body defined by the GraphQL over HTTP spec.
+type graphQLPayload struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+	OperationName string                 `json:"operationName,omitempty"`
+}
+
+// GraphQLError is one entry of a response's errors array, or a validation
+// error found before sending. Field is the dotted response path, if any.
+type GraphQLError struct {
+	Message    string                 `json:"message"`
+	Field      string                 `json:"field,omitempty"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Locations  []GraphQLErrorLocation `json:"locations,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+type GraphQLErrorLocation struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// ParseGraphQLRequest parses the stored GraphQL column.
+func ParseGraphQLRequest(raw string) (*GraphQLRequest, error) {
+	var gql GraphQLRequest
+	if strings.TrimSpace(raw) == "" {
+		return nil, errors.New("graphql request has no query")
+	}
+	if err := json.Unmarshal([]byte(raw), &gql); err != nil {
+		return nil, fmt.Errorf("invalid graphql request: %w", err)
+	}
+	if strings.TrimSpace(gql.Query) == "" {
+		return nil, errors.New("graphql request has no query")
+	}
+	return &gql, nil
+}
+
+// buildGraphQLBody encodes the POST body for a GraphQL request.
+func buildGraphQLBody(gql *GraphQLRequest) ([]byte, string, error) {
+	body, err := json.Marshal(graphQLPayload{Query: gql.Query, Variables: gql.Variables, OperationName: gql.OperationName})
+	if err != nil {
+		return nil, "", err
+	}
+	return body, "application/json", nil
+}
+
+// graphQLQueryURL encodes a GraphQL request into the URL for GET requests.
+func graphQLQueryURL(rawURL string, gql *GraphQLRequest) (string, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	query := parsed.Query()
+	query.Set("query", gql.Query)
+	if gql.OperationName != "" {
+		query.Set("operationName", gql.OperationName)
+	}
+	if len(gql.Variables) > 0 {
+		variables, err := json.Marshal(gql.Variables)
+		if err != nil {
+			return "", err
+		}
+		query.Set("variables", string(variables))
+	}
+	parsed.RawQuery = query.Encode()
+	return parsed.String(), nil
+}
+
+// graphQLEndpoint is the schema cache key for a URL: the URL without its query.
+func graphQLEndpoint(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	parsed.RawQuery = ""
+	parsed.Fragment = ""
+	return parsed.String()
+}
+
+// extractGraphQLErrors reads the errors array from a GraphQL response body.
+func extractGraphQLErrors(body string) []GraphQLError {
+	var response struct {
+		Errors []GraphQLError `json:"errors"`
+	}
+	if json.Unmarshal([]byte(body), &response) != nil {
+		return nil
+	}
+	for i := range response.Errors {
+		response.Errors[i].Field = graphQLPathString(response.Errors[i].Path)
+	}
+	return response.Errors
+}
+
+// graphQLPathString renders a response path as user.friends[0].name.
+func graphQLPathString(path []interface{}) string {
+	var b strings.Builder
+	for _, segment := range path {
+		switch v := segment.(type) {
+		case float64:
+			b.WriteString("[" + strconv.Itoa(int(v)) + "]")
+		case int:
+			b.WriteString("[" + strconv.Itoa(v) + "]")
+		default:
+			if b.Len() > 0 {
+				b.WriteByte('.')
+			}
+			b.WriteString(fmt.Sprint(v))
+		}
+	}
+	return b.String()
+}
+
+// validateGraphQLQuery checks a query against a schema.
+func validateGraphQLQuery(schema *ast.Schema, query string) []GraphQLError {
+	_, errs := gqlparser.LoadQueryWithRules(schema, query, nil)
+	if len(errs) == 0 {
+		return nil
+	}
+
+	result := make([]GraphQLError, 0, len(errs))
+	for _, err := range errs {
+		gqlErr := GraphQLError{Message: err.Message}
+		for _, loc := range err.Locations {
+			gqlErr.Locations = append(gqlErr.Locations, GraphQLErrorLocation{Line: loc.Line, Column: loc.Column})
+		}
+		if err.Rule != "" {
+			gqlErr.Extensions = map[string]interface{}{"rule": err.Rule}
+		}
+		result = append(result, gqlErr)
+	}
+	return result
+}
+
+// IntrospectGraphQL fetches the schema of a GraphQL request's endpoint using
+// the request's headers, auth and client settings, and caches it. The
+// endpoint is resolved with folder variables and, when environmentID is set,
+// that environment's values, as it is when the request executes.
+func (s *RequestService) IntrospectGraphQL(requestID, userID uuid.UUID, environmentID *uuid.UUID) (*models.GraphQLSchema, error) {
+	request, err := s.graphQLRequest(requestID, userID, environmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	introspection := *request
+	introspection.Method = "POST"
+	introspectionJSON, _ := json.Marshal(GraphQLRequest{Query: graphQLIntrospectionQuery, OperationName: "IntrospectionQuery"})
+	introspection.GraphQL = string(introspectionJSON)
+
+	prepared, err := s.prepare(&introspection, "", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result models.Execution
+	if _, err := s.send(prepared.client, prepared.httpReq, prepared.body, prepared.authConfig, &result); err != nil {
+		return nil, err
+	}
+	if result.ErrorMessage != "" {
+		return nil, errors.New(result.ErrorMessage)
+	}
+	if result.StatusCode >= 400 {
+		return nil, fmt.Errorf("introspection failed with status %d", result.StatusCode)
+	}
+	if errs := extractGraphQLErrors(result.ResponseBody); len(errs) > 0 {
+		return nil, fmt.Errorf("introspection failed: %s", errs[0].Message)
+	}
+
+	// Make sure the result builds a usable schema before caching it
+	if _, err := loadIntrospectedSchema(result.ResponseBody); err != nil {
+		return nil, err
+	}
+
+	schema := models.GraphQLSchema{
+		WorkspaceID:   request.Collection.WorkspaceID,
+		Endpoint:      graphQLEndpoint(request.URL),
+		Introspection: result.ResponseBody,
+		FetchedAt:     result.Timestamp,
+	}
+	err = s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "workspace_id"}, {Name: "endpoint"}},
+		DoUpdates: clause.AssignmentColumns([]string{"introspection", "fetched_at", "updated_at"}),
+	}).Create(&schema).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &schema, nil
+}
+
+// ValidateGraphQL validates a GraphQL request against its endpoint's cached
+// schema, resolving the endpoint the same way IntrospectGraphQL does.
+func (s *RequestService) ValidateGraphQL(requestID, userID uuid.UUID, environmentID *uuid.UUID) ([]GraphQLError, error) {
+	request, err := s.graphQLRequest(requestID, userID, environmentID)
+	if err != nil {
+		return nil, err
+	}
+	gql, err := ParseGraphQLRequest(request.GraphQL)
+	if err != nil {
+		return nil, err
+	}
+
+	schema, err := s.cachedGraphQLSchema(request.Collection.WorkspaceID, request.URL)
+	if err != nil {
+		return nil, err
+	}
+	if schema == nil {
+		return nil, errors.New("no cached schema for this endpoint, run introspection first")
+	}
+
+	return validateGraphQLQuery(schema, gql.Query), nil
+}
+
+// graphQLRequest loads a GraphQL request with its {{variable}} placeholders
+// resolved, environment values taking precedence over folder variables.
+func (s *RequestService) graphQLRequest(requestID, userID uuid.UUID, environmentID *uuid.UUID) (*models.Request, error) {
+	request, err := s.GetByID(requestID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if request.Kind != RequestKindGraphQL {
+		return nil, errors.New("request is not a graphql request")
+	}
+
+	vars := folderVariables(request)
+	if environmentID != nil {
+		values, err := s.environmentVariables(request.Collection.WorkspaceID, *environmentID)
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range values {
+			vars[key] = value
+		}
+	}
+	return resolveRequestVariables(request, vars), nil
+}
+
+// cachedGraphQLSchema returns the cached schema for an endpoint, or nil if
+// it was never introspected.
+func (s *RequestService) cachedGraphQLSchema(workspaceID uuid.UUID, rawURL string) (*ast.Schema, error) {
+	var cached models.GraphQLSchema
+	err := s.db.Where("workspace_id = ? AND endpoint = ?", workspaceID, graphQLEndpoint(rawURL)).First(&cached).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return loadIntrospectedSchema(cached.Introspection)
+}
+
+// Introspection result types, only the parts needed to rebuild the schema.
+type introspectionTypeRef struct {
+	Kind   string                `json:"kind"`
+	Name   string                `json:"name"`
+	OfType *introspectionTypeRef `json:"ofType"`
+}
+
+type introspectionInputValue struct {
+	Name         string               `json:"name"`
+	Type         introspectionTypeRef `json:"type"`
+	DefaultValue *string              `json:"defaultValue"`
+}
+
+type introspectionField struct {
+	Name string                    `json:"name"`
+	Args []introspectionInputValue `json:"args"`
+	Type introspectionTypeRef      `json:"type"`
+}
+
+type introspectionType struct {
+	Kind          string                    `json:"kind"`
+	Name          string                    `json:"name"`
+	Fields        []introspectionField      `json:"fields"`
+	InputFields   []introspectionInputValue `json:"inputFields"`
+	Interfaces    []introspectionTypeRef    `json:"interfaces"`
+	EnumValues    []struct{ Name string }   `json:"enumValues"`
+	PossibleTypes []introspectionTypeRef    `json:"possibleTypes"`
+}
+
+type introspectionSchema struct {
+	QueryType        *struct{ Name string } `json:"queryType"`
+	MutationType     *struct{ Name string } `json:"mutationType"`
+	SubscriptionType *struct{ Name string } `json:"subscriptionType"`
+	Types            []introspectionType    `json:"types"`
+	Directives       []struct {
+		Name      string                    `json:"name"`
+		Locations []string                  `json:"locations"`
+		Args      []introspectionInputValue `json:"args"`
+	} `json:"directives"`
+}
+
+// Types and directives gqlparser already defines in its prelude.
+var graphQLBuiltins = map[string]bool{
+	"Int": true, "Float": true, "String": true, "Boolean": true, "ID": true,
+	"defer": true, "include": true, "skip": true, "deprecated": true, "specifiedBy": true, "oneOf": true,
+}
+
+// loadIntrospectedSchema rebuilds a schema from an introspection response by
+// rendering it as SDL.
+func loadIntrospectedSchema(response string) (*ast.Schema, error) {
+	var result struct {
+		Data struct {
+			Schema *introspectionSchema `json:"__schema"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(response), &result); err != nil {
+		return nil, fmt.Errorf("invalid introspection response: %w", err)
+	}
+	if result.Data.Schema == nil {
+		return nil, errors.New("introspection response has no __schema")
+	}
+
+	schema, err := gqlparser.LoadSchema(&ast.Source{Name: "introspection", Input: introspectionSDL(result.Data.Schema)})
+	if err != nil {
+		return nil, fmt.Errorf("invalid introspected schema: %w", err)
+	}
+	return schema, nil
+}
+
+func introspectionSDL(schema *introspectionSchema) string {
+	var b strings.Builder
+
+	b.WriteString("schema {\n")
+	if schema.QueryType != nil {
+		fmt.Fprintf(&b, "  query: %s\n", schema.QueryType.Name)
+	}
+	if schema.MutationType != nil {
+		fmt.Fprintf(&b, "  mutation: %s\n", schema.MutationType.Name)
+	}
+	if schema.SubscriptionType != nil {
+		fmt.Fprintf(&b, "  subscription: %s\n", schema.SubscriptionType.Name)
+	}
+	b.WriteString("}\n")
+
+	types := append([]introspectionType(nil), schema.Types...)
+	sort.Slice(types, func(i, j int) bool { return types[i].Name < types[j].Name })
+
+	for _, t := range types {
+		if strings.HasPrefix(t.Name, "__") || graphQLBuiltins[t.Name] {
+			continue
+		}
+		switch t.Kind {
+		case "SCALAR":
+			fmt.Fprintf(&b, "scalar %s\n", t.Name)
+		case "OBJECT", "INTERFACE":
+			keyword := "type"
+			if t.Kind == "INTERFACE" {
+				keyword = "interface"
+			}
+			fmt.Fprintf(&b, "%s %s", keyword, t.Name)
+			if len(t.Interfaces) > 0 {
+				names := make([]string, len(t.Interfaces))
+				for i, iface := range t.Interfaces {
+					names[i] = iface.Name
+				}
+				b.WriteString(" implements " + strings.Join(names, " & "))
+			}
+			if len(t.Fields) > 0 {
+				b.WriteString(" {\n")
+				for _, f := range t.Fields {
+					fmt.Fprintf(&b, "  %s%s: %s\n", f.Name, introspectionArgs(f.Args), f.Type.String())
+				}
+				b.WriteString("}")
+			}
+			b.WriteString("\n")
+		case "UNION":
+			names := make([]string, len(t.PossibleTypes))
+			for i, possible := range t.PossibleTypes {
+				names[i] = possible.Name
+			}
+			fmt.Fprintf(&b, "union %s = %s\n", t.Name, strings.Join(names, " | "))
+		case "ENUM":
+			fmt.Fprintf(&b, "enum %s {\n", t.Name)
+			for _, v := range t.EnumValues {
+				fmt.Fprintf(&b, "  %s\n", v.Name)
+			}
+			b.WriteString("}\n")
+		case "INPUT_OBJECT":
+			fmt.Fprintf(&b, "input %s {\n", t.Name)
+			for _, f := range t.InputFields {
+				fmt.Fprintf(&b, "  %s\n", introspectionInputValueSDL(f))
+			}
+			b.WriteString("}\n")
+		}
+	}
+
+	for _, d := range schema.Directives {
+		if graphQLBuiltins[d.Name] {
+			continue
+		}
+		fmt.Fprintf(&b, "directive @%s%s on %s\n", d.Name, introspectionArgs(d.Args), strings.Join(d.Locations, " | "))
+	}
+
+	return b.String()
+}
+
+func introspectionArgs(args []introspectionInputValue) string {
+	if len(args) == 0 {
+		return ""
+	}
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		parts[i] = introspectionInputValueSDL(arg)
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
+func introspectionInputValueSDL(v introspectionInputValue) string {
+	s := v.Name + ": " + v.Type.String()
+	if v.DefaultValue != nil {
+		s += " = " + *v.DefaultValue
+	}
+	return s
+}
+
+func (t introspectionTypeRef) String() string {
+	switch t.Kind {
+	case "NON_NULL":
+		if t.OfType != nil {
+			return t.OfType.String() + "!"
+		}
+	case "LIST":
+		if t.OfType != nil {
+			return "[" + t.OfType.String() + "]"
+		}
+	}
+	return t.Name
+}
+
+// graphQLIntrospectionQuery is the standard introspection query, without
+// descriptions since only the type system is needed.
+const graphQLIntrospectionQuery = `query IntrospectionQuery {
+  __schema {
+    queryType { name }
+    mutationType { name }
+    subscriptionType { name }
+    types { ...FullType }
+    directives {
+      name
+      locations
+      args { ...InputValue }
+    }
+  }
+}
+
+fragment FullType on __Type {
+  kind
+  name
+  fields(includeDeprecated: true) {
+    name
+    args { ...InputValue }
+    type { ...TypeRef }
+  }
+  inputFields { ...InputValue }
+  interfaces { ...TypeRef }
+  enumValues(includeDeprecated: true) { name }
+  possibleTypes { ...TypeRef }
+}
+
+fragment InputValue on __InputValue {
+  name
+  type { ...TypeRef }
+  defaultValue
+}
+
+fragment TypeRef on __Type {
+  kind
+  name
+  ofType {
+    kind
+    name
+    ofType {
+      kind
+      name
+      ofType {
+        kind
+        name
+        ofType {
+          kind
+          name
+          ofType {
+            kind
+            name
+            ofType {
+              kind
+              name
+            }
+          }
+        }
+      }
+    }
+  }
+}`
@@ -0,0 +1,224 @@
+package services
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testIntrospection = `{"data":{"__schema":{
+	"queryType":{"name":"Query"},"mutationType":null,"subscriptionType":null,
+	"types":[
+		{"kind":"OBJECT","name":"Query","fields":[
+			{"name":"user","args":[{"name":"id","type":{"kind":"NON_NULL","name":null,"ofType":{"kind":"SCALAR","name":"ID"}},"defaultValue":null}],
+			 "type":{"kind":"OBJECT","name":"User"}}],"interfaces":[]},
+		{"kind":"OBJECT","name":"User","fields":[
+			{"name":"id","args":[],"type":{"kind":"NON_NULL","name":null,"ofType":{"kind":"SCALAR","name":"ID"}}},
+			{"name":"name","args":[],"type":{"kind":"SCALAR","name":"String"}},
+			{"name":"role","args":[],"type":{"kind":"ENUM","name":"Role"}},
+			{"name":"friends","args":[{"name":"first","type":{"kind":"SCALAR","name":"Int"},"defaultValue":"10"}],
+			 "type":{"kind":"LIST","name":null,"ofType":{"kind":"NON_NULL","name":null,"ofType":{"kind":"OBJECT","name":"User"}}}}],
+		 "interfaces":[]},
+		{"kind":"ENUM","name":"Role","enumValues":[{"name":"ADMIN"},{"name":"MEMBER"}]},
+		{"kind":"SCALAR","name":"ID"},
+		{"kind":"SCALAR","name":"String"},
+		{"kind":"OBJECT","name":"__Type","fields":[]}
+	],
+	"directives":[{"name":"skip","locations":["FIELD"],"args":[]}]
+}}}`
+
+func TestLoadIntrospectedSchema_Validate(t *testing.T) {
+	schema, err := loadIntrospectedSchema(testIntrospection)
+	require.NoError(t, err)
+
+	assert.Empty(t, validateGraphQLQuery(schema, `query($id: ID!) { user(id: $id) { name role friends(first: 2) { id } } }`))
+
+	errs := validateGraphQLQuery(schema, `{ user(id: "1") { email } }`)
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Message, `Cannot query field "email" on type "User"`)
+	assert.Equal(t, 1, errs[0].Locations[0].Line)
+
+	errs = validateGraphQLQuery(schema, `{ user { id } }`)
+	require.NotEmpty(t, errs)
+	assert.Contains(t, errs[0].Message, `argument "id" of type "ID!" is required`)
+}
+
+func TestExtractGraphQLErrors(t *testing.T) {
+	errs := extractGraphQLErrors(`{"data":{"user":null},"errors":[
+		{"message":"not allowed","path":["user","friends",0,"name"],"locations":[{"line":1,"column":3}],"extensions":{"code":"FORBIDDEN"}},
+		{"message":"boom"}]}`)
+
+	require.Len(t, errs, 2)
+	assert.Equal(t, "user.friends[0].name", errs[0].Field)
+	assert.Equal(t, "FORBIDDEN", errs[0].Extensions["code"])
+	assert.Equal(t, GraphQLErrorLocation{Line: 1, Column: 3}, errs[0].Locations[0])
+	assert.Empty(t, errs[1].Field)
+
+	assert.Nil(t, extractGraphQLErrors(`not json`))
+}
+
+func TestGraphQLQueryURL(t *testing.T) {
+	result, err := graphQLQueryURL("http://api.test/graphql?x=1", &GraphQLRequest{
+		Query:         "{ me { id } }",
+		Variables:     map[string]interface{}{"a": 1},
+		OperationName: "Me",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "http://api.test/graphql?operationName=Me&query=%7B+me+%7B+id+%7D+%7D&variables=%7B%22a%22%3A1%7D&x=1", result)
+	assert.Equal(t, "http://api.test/graphql", graphQLEndpoint(result))
+}
+
+func TestRequestService_Execute_GraphQL(t *testing.T) {
+	db, mock := setupTestDBRequest(t)
+	service := NewRequestService(db, nil)
+
+	var received map[string]interface{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &received)
+		w.Write([]byte(`{"data":{"user":{"name":null}},"errors":[{"message":"name hidden","path":["user","name"]}]}`))
+	}))
+	defer ts.Close()
+
+	requestID := uuid.New()
+	collectionID := uuid.New()
+	workspaceID := uuid.New()
+	userID := uuid.New()
+
+	mock.ExpectQuery(`(?i)SELECT \* FROM "requests"`).
+		WithArgs(requestID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "collection_id", "url", "method", "kind", "graphql"}).
+			AddRow(requestID, collectionID, ts.URL, "POST", RequestKindGraphQL,
+				`{"query":"query U($id: ID!) { user(id: $id) { name } }","variables":{"id":"7"},"operation_name":"U"}`))
+	mock.ExpectQuery(`(?i)SELECT \* FROM "collections"`).
+		WithArgs(collectionID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "workspace_id"}).AddRow(collectionID, workspaceID))
+	mock.ExpectQuery(`(?i)SELECT count\(\*\) FROM "workspace_members"`).
+		WithArgs(workspaceID, userID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(`(?i)SELECT "settings" FROM "workspaces"`).
+		WithArgs(workspaceID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"settings"}).AddRow(""))
+	mock.ExpectQuery(`(?i)SELECT \* FROM "graphql_schemas" WHERE workspace_id = \$1 AND endpoint = \$2`).
+		WithArgs(workspaceID, ts.URL, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "workspace_id", "endpoint", "introspection"}).
+			AddRow(uuid.New(), workspaceID, ts.URL, testIntrospection))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`(?i)INSERT INTO "executions"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectCommit()
+
+	execution, err := service.Execute(requestID, userID, "", nil, uuid.New(), nil, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, "U", received["operationName"])
+	assert.Equal(t, map[string]interface{}{"id": "7"}, received["variables"])
+
+	var errs []GraphQLError
+	require.NoError(t, json.Unmarshal([]byte(execution.GraphQLErrors), &errs))
+	require.Len(t, errs, 1)
+	assert.Equal(t, "user.name", errs[0].Field)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRequestService_Execute_GraphQLValidationFailure(t *testing.T) {
+	db, mock := setupTestDBRequest(t)
+	service := NewRequestService(db, nil)
+
+	requestID := uuid.New()
+	collectionID := uuid.New()
+	workspaceID := uuid.New()
+	userID := uuid.New()
+	endpoint := "http://graphql.invalid/graphql"
+
+	mock.ExpectQuery(`(?i)SELECT \* FROM "requests"`).
+		WithArgs(requestID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "collection_id", "url", "method", "kind", "graphql"}).
+			AddRow(requestID, collectionID, endpoint, "POST", RequestKindGraphQL, `{"query":"{ user(id: \"1\") { email } }"}`))
+	mock.ExpectQuery(`(?i)SELECT \* FROM "collections"`).
+		WithArgs(collectionID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "workspace_id"}).AddRow(collectionID, workspaceID))
+	mock.ExpectQuery(`(?i)SELECT count\(\*\) FROM "workspace_members"`).
+		WithArgs(workspaceID, userID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(`(?i)SELECT "settings" FROM "workspaces"`).
+		WithArgs(workspaceID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"settings"}).AddRow(""))
+	mock.ExpectQuery(`(?i)SELECT \* FROM "graphql_schemas"`).
+		WithArgs(workspaceID, endpoint, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "workspace_id", "endpoint", "introspection"}).
+			AddRow(uuid.New(), workspaceID, endpoint, testIntrospection))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`(?i)INSERT INTO "executions"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectCommit()
+
+	// The endpoint does not resolve, so a sent request would fail differently
+	execution, err := service.Execute(requestID, userID, "", nil, uuid.New(), nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 0, execution.StatusCode)
+	assert.Contains(t, execution.ErrorMessage, "graphql validation failed")
+	assert.Contains(t, execution.GraphQLErrors, `Cannot query field \"email\"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRequestService_IntrospectGraphQL(t *testing.T) {
+	db, mock := setupTestDBRequest(t)
+	service := NewRequestService(db, nil)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		assert.Equal(t, "IntrospectionQuery", payload["operationName"])
+		w.Write([]byte(testIntrospection))
+	}))
+	defer ts.Close()
+
+	requestID := uuid.New()
+	collectionID := uuid.New()
+	workspaceID := uuid.New()
+	userID := uuid.New()
+
+	mock.ExpectQuery(`(?i)SELECT \* FROM "requests"`).
+		WithArgs(requestID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "collection_id", "url", "method", "kind", "graphql"}).
+			AddRow(requestID, collectionID, "{{base_url}}?debug=1", "GET", RequestKindGraphQL, `{"query":"{ user(id: 1) { id } }"}`))
+	mock.ExpectQuery(`(?i)SELECT \* FROM "collections"`).
+		WithArgs(collectionID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "workspace_id"}).AddRow(collectionID, workspaceID))
+	mock.ExpectQuery(`(?i)SELECT count\(\*\) FROM "workspace_members"`).
+		WithArgs(workspaceID, userID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	environmentID := uuid.New()
+	mock.ExpectQuery(`(?i)SELECT \* FROM "environments"`).
+		WithArgs(environmentID, workspaceID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "workspace_id"}).AddRow(environmentID, workspaceID))
+	mock.ExpectQuery(`(?i)SELECT \* FROM "environment_secrets"`).
+		WithArgs(environmentID).
+		WillReturnRows(sqlmock.NewRows([]string{"environment_id", "key", "value"}))
+	mock.ExpectQuery(`(?i)SELECT \* FROM "environment_variables"`).
+		WithArgs(environmentID).
+		WillReturnRows(sqlmock.NewRows([]string{"environment_id", "key", "value"}).AddRow(environmentID, "base_url", ts.URL))
+	mock.ExpectQuery(`(?i)SELECT "settings" FROM "workspaces"`).
+		WithArgs(workspaceID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"settings"}).AddRow(""))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`(?i)INSERT INTO "graphql_schemas" .* ON CONFLICT \("workspace_id","endpoint"\) DO UPDATE`).
+		WithArgs(workspaceID, ts.URL, testIntrospection, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectCommit()
+
+	// The cache key is the resolved endpoint, not the {{base_url}} template
+	schema, err := service.IntrospectGraphQL(requestID, userID, &environmentID)
+	require.NoError(t, err)
+	assert.Equal(t, ts.URL, schema.Endpoint)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
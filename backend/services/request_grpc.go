@@ -0,0 +1,559 @@
+package services
+
+import (
+	"backend/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bufbuild/protocompile"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// GRPCRequest is the stored gRPC column of a request. Services and messages
+// are described by the uploaded proto files, or by server reflection when
+// none are given.
+type GRPCRequest struct {
+	Service      string            `json:"service"` // fully qualified, e.g. helloworld.Greeter
+	Method       string            `json:"method"`
+	Message      json.RawMessage   `json:"message,omitempty"` // request message in protobuf JSON
+	Metadata     map[string]string `json:"metadata,omitempty"`
+	ProtoFileIDs []string          `json:"proto_file_ids,omitempty"`
+	MaxMessages  int               `json:"max_messages,omitempty"` // server streaming: stop after this many, 0 reads to the end
+}
+
+// GRPCService describes a service available to a gRPC request.
+type GRPCService struct {
+	Name    string       `json:"name"`
+	Methods []GRPCMethod `json:"methods"`
+}
+
+type GRPCMethod struct {
+	Name            string `json:"name"`
+	InputType       string `json:"input_type"`
+	OutputType      string `json:"output_type"`
+	ClientStreaming bool   `json:"client_streaming"`
+	ServerStreaming bool   `json:"server_streaming"`
+}
+
+// ParseGRPCRequest parses the stored gRPC column.
+func ParseGRPCRequest(raw string) (*GRPCRequest, error) {
+	var call GRPCRequest
+	if strings.TrimSpace(raw) == "" {
+		return nil, errors.New("grpc request has no method")
+	}
+	if err := json.Unmarshal([]byte(raw), &call); err != nil {
+		return nil, fmt.Errorf("invalid grpc request: %w", err)
+	}
+	if call.Service == "" || call.Method == "" {
+		return nil, errors.New("grpc request needs a service and method")
+	}
+	return &call, nil
+}
+
+// grpcTarget turns a request URL into a dial target. grpcs:// and https://
+// use TLS; grpc://, http:// and bare host:port do not.
+func grpcTarget(rawURL string) (string, bool, error) {
+	if !strings.Contains(rawURL, "://") {
+		return rawURL, false, nil
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "", false, err
+	}
+	switch parsed.Scheme {
+	case "grpc", "http":
+		return parsed.Host, false, nil
+	case "grpcs", "https":
+		return parsed.Host, true, nil
+	default:
+		return "", false, fmt.Errorf("unsupported grpc scheme: %s", parsed.Scheme)
+	}
+}
+
+// executeGRPC sends a unary or server-streaming call and records it as an execution.
+func (s *RequestService) executeGRPC(ctx context.Context, request *models.Request, overrideURL string, overrideHeaders map[string]string, traceID uuid.UUID, spanID, parentSpanID *uuid.UUID) (*models.Execution, error) {
+	call, err := ParseGRPCRequest(request.GRPC)
+	if err != nil {
+		return nil, err
+	}
+	authConfig, err := resolveAuthConfig(request)
+	if err != nil {
+		return nil, err
+	}
+	settings, err := s.clientSettings(request)
+	if err != nil {
+		return nil, err
+	}
+
+	rawURL := request.URL
+	if overrideURL != "" {
+		rawURL = overrideURL
+	}
+	target, useTLS, err := grpcTarget(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := DefaultRequestTimeout
+	if settings.TimeoutMs != nil && *settings.TimeoutMs > 0 {
+		timeout = time.Duration(*settings.TimeoutMs) * time.Millisecond
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	conn, err := s.dialGRPC(target, useTLS, settings, request.Collection.WorkspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	startTime := time.Now()
+	execution := models.Execution{
+		RequestID:     request.ID,
+		TraceID:       traceID,
+		SpanID:        spanID,
+		ParentSpanID:  parentSpanID,
+		Timestamp:     startTime,
+		AttemptCount:  1,
+		Protocol:      "gRPC",
+		RemoteAddress: target,
+	}
+
+	callErr := func() error {
+		method, err := s.resolveGRPCMethod(ctx, conn, call, request.Collection.WorkspaceID)
+		if err != nil {
+			return err
+		}
+		if method.IsStreamingClient() {
+			return status.Error(codes.Unimplemented, "client streaming calls are not supported")
+		}
+
+		input := dynamicpb.NewMessage(method.Input())
+		if len(call.Message) > 0 {
+			if err := protojson.Unmarshal(call.Message, input); err != nil {
+				return status.Errorf(codes.InvalidArgument, "invalid request message: %v", err)
+			}
+		}
+
+		md, err := s.grpcMetadata(request, call, overrideHeaders, authConfig, target)
+		if err != nil {
+			return err
+		}
+		md.Set("x-span-id", spanID.String())
+		if traceID != uuid.Nil {
+			md.Set("x-trace-id", traceID.String())
+		}
+		if parentSpanID != nil && *parentSpanID != uuid.Nil {
+			md.Set("x-parent-span-id", parentSpanID.String())
+		}
+		callCtx := metadata.NewOutgoingContext(ctx, md)
+
+		fullMethod := fmt.Sprintf("/%s/%s", method.Parent().FullName(), method.Name())
+		var header, trailer metadata.MD
+		defer func() {
+			headerJSON, _ := json.Marshal(header)
+			execution.ResponseHeaders = string(headerJSON)
+			if len(trailer) > 0 {
+				trailerJSON, _ := json.Marshal(trailer)
+				execution.ResponseTrailers = string(trailerJSON)
+			}
+		}()
+
+		if !method.IsStreamingServer() {
+			output := dynamicpb.NewMessage(method.Output())
+			if err := conn.Invoke(callCtx, fullMethod, input, output, grpc.Header(&header), grpc.Trailer(&trailer)); err != nil {
+				return err
+			}
+			execution.TimeToFirstByteMs = phaseMs(startTime, time.Now())
+			body, _ := protojson.Marshal(output)
+			execution.ResponseBody = string(body)
+			return nil
+		}
+
+		streamCtx, stopStream := context.WithCancel(callCtx)
+		defer stopStream()
+		stream, err := conn.NewStream(streamCtx, &grpc.StreamDesc{ServerStreams: true}, fullMethod)
+		if err != nil {
+			return err
+		}
+		if err := stream.SendMsg(input); err != nil {
+			return err
+		}
+		if err := stream.CloseSend(); err != nil {
+			return err
+		}
+
+		messages := []json.RawMessage{}
+		defer func() {
+			body, _ := json.Marshal(messages)
+			execution.ResponseBody = string(body)
+		}()
+		for {
+			output := dynamicpb.NewMessage(method.Output())
+			err := stream.RecvMsg(output)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				header, _ = stream.Header()
+				trailer = stream.Trailer()
+				return err
+			}
+			if len(messages) == 0 {
+				execution.TimeToFirstByteMs = phaseMs(startTime, time.Now())
+				header, _ = stream.Header()
+			}
+			body, _ := protojson.Marshal(output)
+			messages = append(messages, body)
+			if call.MaxMessages > 0 && len(messages) >= call.MaxMessages {
+				return nil
+			}
+		}
+		trailer = stream.Trailer()
+		return nil
+	}()
+
+	grpcStatus := status.Convert(callErr)
+	execution.GRPCStatus = grpcStatus.Code().String()
+	execution.StatusCode = grpcHTTPStatus(grpcStatus.Code())
+	if callErr != nil {
+		execution.ErrorMessage = grpcStatus.Message()
+	}
+	execution.ResponseTimeMs = time.Since(startTime).Milliseconds()
+
+	if err := s.db.Create(&execution).Error; err != nil {
+		return nil, err
+	}
+
+	return &execution, nil
+}
+
+// DescribeGRPC lists the services and methods available to a gRPC request.
+func (s *RequestService) DescribeGRPC(ctx context.Context, requestID, userID uuid.UUID) ([]GRPCService, error) {
+	request, err := s.GetByID(requestID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if request.Kind != RequestKindGRPC {
+		return nil, errors.New("request is not a grpc request")
+	}
+
+	var call GRPCRequest
+	if request.GRPC != "" {
+		if err := json.Unmarshal([]byte(request.GRPC), &call); err != nil {
+			return nil, fmt.Errorf("invalid grpc request: %w", err)
+		}
+	}
+
+	files, err := func() (*protoregistry.Files, error) {
+		if len(call.ProtoFileIDs) > 0 {
+			return s.compileProtoFiles(call.ProtoFileIDs, request.Collection.WorkspaceID)
+		}
+
+		settings, err := s.clientSettings(request)
+		if err != nil {
+			return nil, err
+		}
+		target, useTLS, err := grpcTarget(request.URL)
+		if err != nil {
+			return nil, err
+		}
+		conn, err := s.dialGRPC(target, useTLS, settings, request.Collection.WorkspaceID)
+		if err != nil {
+			return nil, err
+		}
+		defer conn.Close()
+
+		ctx, cancel := context.WithTimeout(ctx, DefaultRequestTimeout)
+		defer cancel()
+		return reflectGRPCFiles(ctx, conn, "")
+	}()
+	if err != nil {
+		return nil, err
+	}
+
+	services := []GRPCService{}
+	files.RangeFiles(func(file protoreflect.FileDescriptor) bool {
+		for i := 0; i < file.Services().Len(); i++ {
+			sd := file.Services().Get(i)
+			if strings.HasPrefix(string(sd.FullName()), "grpc.reflection.") {
+				continue
+			}
+			service := GRPCService{Name: string(sd.FullName())}
+			for j := 0; j < sd.Methods().Len(); j++ {
+				md := sd.Methods().Get(j)
+				service.Methods = append(service.Methods, GRPCMethod{
+					Name:            string(md.Name()),
+					InputType:       string(md.Input().FullName()),
+					OutputType:      string(md.Output().FullName()),
+					ClientStreaming: md.IsStreamingClient(),
+					ServerStreaming: md.IsStreamingServer(),
+				})
+			}
+			services = append(services, service)
+		}
+		return true
+	})
+	sort.Slice(services, func(i, j int) bool { return services[i].Name < services[j].Name })
+
+	return services, nil
+}
+
+func (s *RequestService) dialGRPC(target string, useTLS bool, settings *ClientSettings, workspaceID uuid.UUID) (*grpc.ClientConn, error) {
+	creds := insecure.NewCredentials()
+	if useTLS {
+		opts, err := s.transportOptions(settings, workspaceID)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig, err := newTLSConfig(opts)
+		if err != nil {
+			return nil, err
+		}
+		creds = credentials.NewTLS(tlsConfig)
+	}
+	return grpc.NewClient(target, grpc.WithTransportCredentials(creds))
+}
+
+// resolveGRPCMethod finds the method descriptor from the proto files or the server.
+func (s *RequestService) resolveGRPCMethod(ctx context.Context, conn *grpc.ClientConn, call *GRPCRequest, workspaceID uuid.UUID) (protoreflect.MethodDescriptor, error) {
+	var files *protoregistry.Files
+	var err error
+	if len(call.ProtoFileIDs) > 0 {
+		files, err = s.compileProtoFiles(call.ProtoFileIDs, workspaceID)
+	} else {
+		files, err = reflectGRPCFiles(ctx, conn, call.Service)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	descriptor, err := files.FindDescriptorByName(protoreflect.FullName(call.Service))
+	if err != nil {
+		return nil, status.Errorf(codes.NotFound, "service %s not found", call.Service)
+	}
+	service, ok := descriptor.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "%s is not a service", call.Service)
+	}
+	method := service.Methods().ByName(protoreflect.Name(call.Method))
+	if method == nil {
+		return nil, status.Errorf(codes.NotFound, "method %s not found on %s", call.Method, call.Service)
+	}
+	return method, nil
+}
+
+// grpcMetadata builds the outgoing metadata from the request headers, the
+// call's metadata and the request's auth.
+func (s *RequestService) grpcMetadata(request *models.Request, call *GRPCRequest, overrideHeaders map[string]string, authConfig *AuthConfig, target string) (metadata.MD, error) {
+	// Auth helpers work on HTTP requests, so apply them to a stand-in and
+	// copy the resulting headers
+	httpReq, err := http.NewRequest(http.MethodPost, "http://"+target+"/"+call.Service+"/"+call.Method, nil)
+	if err != nil {
+		return nil, err
+	}
+	var headers map[string]string
+	if request.Headers != "" {
+		json.Unmarshal([]byte(request.Headers), &headers)
+	}
+	for k, v := range headers {
+		httpReq.Header.Set(k, v)
+	}
+	for k, v := range call.Metadata {
+		httpReq.Header.Set(k, v)
+	}
+	for k, v := range overrideHeaders {
+		httpReq.Header.Set(k, v)
+	}
+	if err := s.applyAuth(httpReq, nil, authConfig); err != nil {
+		return nil, err
+	}
+
+	md := metadata.MD{}
+	for k, values := range httpReq.Header {
+		md.Append(strings.ToLower(k), values...)
+	}
+	return md, nil
+}
+
+// compileProtoFiles compiles uploaded .proto files. Imports are resolved by
+// file name among the uploaded files and the well-known types.
+func (s *RequestService) compileProtoFiles(fileIDs []string, workspaceID uuid.UUID) (*protoregistry.Files, error) {
+	sources := make(map[string]string, len(fileIDs))
+	names := make([]string, 0, len(fileIDs))
+	for _, id := range fileIDs {
+		file, err := s.loadBodyFile(id, workspaceID)
+		if err != nil {
+			return nil, err
+		}
+		sources[file.Name] = string(file.Data)
+		names = append(names, file.Name)
+	}
+
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{
+			Accessor: protocompile.SourceAccessorFromMap(sources),
+		}),
+	}
+	compiled, err := compiler.Compile(context.Background(), names...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proto files: %w", err)
+	}
+
+	files := &protoregistry.Files{}
+	for _, file := range compiled {
+		if err := files.RegisterFile(file); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// reflectGRPCFiles loads descriptors through the v1 server reflection
+// service: the file defining symbol, or every listed service when symbol is
+// empty, plus their imports.
+func reflectGRPCFiles(ctx context.Context, conn *grpc.ClientConn, symbol string) (*protoregistry.Files, error) {
+	stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.CloseSend()
+
+	ask := func(req *reflectionpb.ServerReflectionRequest) (*reflectionpb.ServerReflectionResponse, error) {
+		if err := stream.Send(req); err != nil {
+			return nil, err
+		}
+		resp, err := stream.Recv()
+		if err != nil {
+			return nil, err
+		}
+		if errResp := resp.GetErrorResponse(); errResp != nil {
+			return nil, status.Error(codes.Code(errResp.ErrorCode), errResp.ErrorMessage)
+		}
+		return resp, nil
+	}
+
+	symbols := []string{symbol}
+	if symbol == "" {
+		resp, err := ask(&reflectionpb.ServerReflectionRequest{
+			MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{ListServices: "*"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		symbols = symbols[:0]
+		for _, service := range resp.GetListServicesResponse().GetService() {
+			symbols = append(symbols, service.Name)
+		}
+	}
+
+	protos := map[string]*descriptorpb.FileDescriptorProto{}
+	addFiles := func(resp *reflectionpb.ServerReflectionResponse) error {
+		for _, raw := range resp.GetFileDescriptorResponse().GetFileDescriptorProto() {
+			fd := &descriptorpb.FileDescriptorProto{}
+			if err := proto.Unmarshal(raw, fd); err != nil {
+				return err
+			}
+			protos[fd.GetName()] = fd
+		}
+		return nil
+	}
+
+	for _, name := range symbols {
+		resp, err := ask(&reflectionpb.ServerReflectionRequest{
+			MessageRequest: &reflectionpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: name},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := addFiles(resp); err != nil {
+			return nil, err
+		}
+	}
+
+	// Fetch imports the server did not send along, falling back to the
+	// well-known types compiled into this binary
+	for pending := true; pending; {
+		pending = false
+		for _, fd := range protos {
+			for _, dep := range fd.GetDependency() {
+				if _, ok := protos[dep]; ok {
+					continue
+				}
+				pending = true
+				if known, err := protoregistry.GlobalFiles.FindFileByPath(dep); err == nil {
+					protos[dep] = protodesc.ToFileDescriptorProto(known)
+					continue
+				}
+				resp, err := ask(&reflectionpb.ServerReflectionRequest{
+					MessageRequest: &reflectionpb.ServerReflectionRequest_FileByFilename{FileByFilename: dep},
+				})
+				if err != nil {
+					return nil, err
+				}
+				if err := addFiles(resp); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	set := &descriptorpb.FileDescriptorSet{}
+	for _, fd := range protos {
+		set.File = append(set.File, fd)
+	}
+	return protodesc.NewFiles(set)
+}
+
+// grpcHTTPStatus maps a gRPC code to the closest HTTP status, so gRPC
+// executions read like HTTP ones in history and load tests.
+func grpcHTTPStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,205 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+)
+
+const testHealthProto = `syntax = "proto3";
+package grpc.health.v1;
+
+message HealthCheckRequest { string service = 1; }
+message HealthCheckResponse {
+  enum ServingStatus { UNKNOWN = 0; SERVING = 1; NOT_SERVING = 2; SERVICE_UNKNOWN = 3; }
+  ServingStatus status = 1;
+}
+service Health {
+  rpc Check(HealthCheckRequest) returns (HealthCheckResponse);
+  rpc Watch(HealthCheckRequest) returns (stream HealthCheckResponse);
+}`
+
+// startGRPCHealthServer serves the health service with reflection and
+// records the metadata of the last unary call.
+func startGRPCHealthServer(t *testing.T) (string, *metadata.MD) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	received := &metadata.MD{}
+	server := grpc.NewServer(grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		*received, _ = metadata.FromIncomingContext(ctx)
+		grpc.SetTrailer(ctx, metadata.Pairs("x-served-by", "test"))
+		return handler(ctx, req)
+	}))
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("orders", healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(server, healthServer)
+	reflection.Register(server)
+
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+	return listener.Addr().String(), received
+}
+
+func expectGRPCRequest(mock sqlmock.Sqlmock, requestID, collectionID, workspaceID, userID uuid.UUID, target, call string) {
+	mock.ExpectQuery(`(?i)SELECT \* FROM "requests"`).
+		WithArgs(requestID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "collection_id", "url", "method", "headers", "kind", "grpc"}).
+			AddRow(requestID, collectionID, target, "POST", `{"authorization":"Bearer t0k"}`, RequestKindGRPC, call))
+	mock.ExpectQuery(`(?i)SELECT \* FROM "collections"`).
+		WithArgs(collectionID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "workspace_id"}).AddRow(collectionID, workspaceID))
+	mock.ExpectQuery(`(?i)SELECT count\(\*\) FROM "workspace_members"`).
+		WithArgs(workspaceID, userID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(`(?i)SELECT "settings" FROM "workspaces"`).
+		WithArgs(workspaceID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"settings"}).AddRow(""))
+}
+
+func TestGRPCTarget(t *testing.T) {
+	target, useTLS, err := grpcTarget("grpcs://api.test:443")
+	require.NoError(t, err)
+	assert.Equal(t, "api.test:443", target)
+	assert.True(t, useTLS)
+
+	target, useTLS, err = grpcTarget("localhost:50051")
+	require.NoError(t, err)
+	assert.Equal(t, "localhost:50051", target)
+	assert.False(t, useTLS)
+
+	_, _, err = grpcTarget("ftp://api.test")
+	assert.Error(t, err)
+}
+
+func TestRequestService_Execute_GRPCUnaryViaReflection(t *testing.T) {
+	db, mock := setupTestDBRequest(t)
+	service := NewRequestService(db, nil)
+	target, received := startGRPCHealthServer(t)
+
+	requestID := uuid.New()
+	collectionID := uuid.New()
+	workspaceID := uuid.New()
+	userID := uuid.New()
+	traceID := uuid.New()
+	parentSpanID := uuid.New()
+
+	expectGRPCRequest(mock, requestID, collectionID, workspaceID, userID, "grpc://"+target,
+		`{"service":"grpc.health.v1.Health","method":"Check","message":{"service":"orders"},"metadata":{"x-tenant":"acme"}}`)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`(?i)INSERT INTO "executions"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectCommit()
+
+	execution, err := service.Execute(requestID, userID, "", nil, traceID, nil, &parentSpanID)
+	require.NoError(t, err)
+
+	assert.Equal(t, "gRPC", execution.Protocol)
+	assert.Equal(t, "OK", execution.GRPCStatus)
+	assert.Equal(t, 200, execution.StatusCode)
+	assert.JSONEq(t, `{"status":"SERVING"}`, execution.ResponseBody)
+	assert.Contains(t, execution.ResponseTrailers, "x-served-by")
+
+	assert.Equal(t, []string{traceID.String()}, received.Get("x-trace-id"))
+	assert.Equal(t, []string{execution.SpanID.String()}, received.Get("x-span-id"))
+	assert.Equal(t, []string{parentSpanID.String()}, received.Get("x-parent-span-id"))
+	assert.Equal(t, []string{"Bearer t0k"}, received.Get("authorization"))
+	assert.Equal(t, []string{"acme"}, received.Get("x-tenant"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRequestService_Execute_GRPCServerStreaming(t *testing.T) {
+	db, mock := setupTestDBRequest(t)
+	service := NewRequestService(db, nil)
+	target, _ := startGRPCHealthServer(t)
+
+	requestID := uuid.New()
+	collectionID := uuid.New()
+	workspaceID := uuid.New()
+	userID := uuid.New()
+
+	expectGRPCRequest(mock, requestID, collectionID, workspaceID, userID, target,
+		`{"service":"grpc.health.v1.Health","method":"Watch","message":{"service":"orders"},"max_messages":1}`)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`(?i)INSERT INTO "executions"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectCommit()
+
+	execution, err := service.Execute(requestID, userID, "", nil, uuid.New(), nil, nil)
+	require.NoError(t, err)
+
+	var messages []map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(execution.ResponseBody), &messages))
+	assert.Equal(t, []map[string]interface{}{{"status": "SERVING"}}, messages)
+	assert.Equal(t, "OK", execution.GRPCStatus)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRequestService_Execute_GRPCProtoFileStatus(t *testing.T) {
+	db, mock := setupTestDBRequest(t)
+	service := NewRequestService(db, nil)
+	target, _ := startGRPCHealthServer(t)
+
+	requestID := uuid.New()
+	collectionID := uuid.New()
+	workspaceID := uuid.New()
+	userID := uuid.New()
+	fileID := uuid.New()
+
+	expectGRPCRequest(mock, requestID, collectionID, workspaceID, userID, target,
+		`{"service":"grpc.health.v1.Health","method":"Check","message":{"service":"billing"},"proto_file_ids":["`+fileID.String()+`"]}`)
+	mock.ExpectQuery(`(?i)SELECT \* FROM "files"`).
+		WithArgs(fileID, workspaceID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "workspace_id", "name", "data"}).
+			AddRow(fileID, workspaceID, "health.proto", []byte(testHealthProto)))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`(?i)INSERT INTO "executions"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectCommit()
+
+	execution, err := service.Execute(requestID, userID, "", nil, uuid.New(), nil, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, "NotFound", execution.GRPCStatus)
+	assert.Equal(t, 404, execution.StatusCode)
+	assert.Equal(t, "unknown service", execution.ErrorMessage)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRequestService_DescribeGRPC(t *testing.T) {
+	db, mock := setupTestDBRequest(t)
+	service := NewRequestService(db, nil)
+	target, _ := startGRPCHealthServer(t)
+
+	requestID := uuid.New()
+	collectionID := uuid.New()
+	workspaceID := uuid.New()
+	userID := uuid.New()
+
+	expectGRPCRequest(mock, requestID, collectionID, workspaceID, userID, target, "")
+
+	grpcServices, err := service.DescribeGRPC(context.Background(), requestID, userID)
+	require.NoError(t, err)
+
+	require.Len(t, grpcServices, 1)
+	assert.Equal(t, "grpc.health.v1.Health", grpcServices[0].Name)
+	methods := map[string]GRPCMethod{}
+	for _, method := range grpcServices[0].Methods {
+		methods[method.Name] = method
+	}
+	assert.False(t, methods["Check"].ServerStreaming)
+	assert.True(t, methods["Watch"].ServerStreaming)
+	assert.Equal(t, "grpc.health.v1.HealthCheckRequest", methods["Watch"].InputType)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
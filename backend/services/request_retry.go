@@ -0,0 +1,148 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Backoff strategies for RetryPolicy.Backoff.
+const (
+	BackoffFixed       = "fixed"
+	BackoffLinear      = "linear"
+	BackoffExponential = "exponential"
+)
+
+// MaxRetryAttempts caps RetryPolicy.MaxAttempts.
+const MaxRetryAttempts = 10
+
+// defaultMaxRetryDelay caps the wait between attempts when the policy sets no
+// max_delay_ms, so a large Retry-After cannot hold an execution for hours.
+const defaultMaxRetryDelay = time.Minute
+
+// RetryPolicy controls how Execute retries a request. It is part of the
+// client settings, so it can be set on the workspace, collection or request.
+type RetryPolicy struct {
+	MaxAttempts         int    `json:"max_attempts"`      // total attempts including the first
+	Backoff             string `json:"backoff,omitempty"` // fixed (default), linear, exponential
+	InitialDelayMs      int    `json:"initial_delay_ms,omitempty"`
+	MaxDelayMs          int    `json:"max_delay_ms,omitempty"`    // caps backoff and Retry-After, 0 caps them at a minute
+	RetryOnStatus       []int  `json:"retry_on_status,omitempty"` // e.g. 429, 502, 503, 504
+	RetryOnNetworkError bool   `json:"retry_on_network_error,omitempty"`
+	RetryOnTimeout      bool   `json:"retry_on_timeout,omitempty"`
+	RespectRetryAfter   bool   `json:"respect_retry_after,omitempty"`
+}
+
+// validate checks the policy before the first attempt is sent.
+func (p *RetryPolicy) validate() error {
+	if p.MaxAttempts > MaxRetryAttempts {
+		return fmt.Errorf("max_attempts must be at most %d", MaxRetryAttempts)
+	}
+	switch p.Backoff {
+	case "", BackoffFixed, BackoffLinear, BackoffExponential:
+	default:
+		return fmt.Errorf("unsupported backoff strategy: %s", p.Backoff)
+	}
+	return nil
+}
+
+// enabled reports whether more than one attempt may be made.
+func (p *RetryPolicy) enabled() bool {
+	return p != nil && p.MaxAttempts > 1
+}
+
+// nextDelay decides whether attempt (1-based) should be retried given its
+// outcome, and how long to wait first. sendErr is the transport error, if any.
+func (p *RetryPolicy) nextDelay(attempt, statusCode int, header http.Header, sendErr error) (time.Duration, bool) {
+	if !p.enabled() || attempt >= p.MaxAttempts {
+		return 0, false
+	}
+
+	if !p.shouldRetry(statusCode, sendErr) {
+		return 0, false
+	}
+
+	delay := time.Duration(p.InitialDelayMs) * time.Millisecond
+	switch p.Backoff {
+	case BackoffLinear:
+		delay *= time.Duration(attempt)
+	case BackoffExponential:
+		delay *= time.Duration(1) << (attempt - 1)
+	}
+
+	if p.RespectRetryAfter && header != nil {
+		if retryAfter, ok := parseRetryAfter(header.Get("Retry-After"), time.Now()); ok {
+			delay = retryAfter
+		}
+	}
+
+	maxDelay := defaultMaxRetryDelay
+	if p.MaxDelayMs > 0 {
+		maxDelay = time.Duration(p.MaxDelayMs) * time.Millisecond
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+	return delay, true
+}
+
+func (p *RetryPolicy) shouldRetry(statusCode int, sendErr error) bool {
+	if sendErr != nil {
+		// Errors from before the request went out, such as auth that could
+		// not be applied, fail the same way on every attempt
+		var urlErr *url.Error
+		if !errors.As(sendErr, &urlErr) {
+			return false
+		}
+		if isTimeout(sendErr) {
+			return p.RetryOnTimeout
+		}
+		return p.RetryOnNetworkError
+	}
+	for _, code := range p.RetryOnStatus {
+		if code == statusCode {
+			return true
+		}
+	}
+	return false
+}
+
+// sleepContext waits for d and reports whether it did, returning false early
+// when ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if at.Before(now) {
+			return 0, true
+		}
+		return at.Sub(now), true
+	}
+	return 0, false
+}
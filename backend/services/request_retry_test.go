@@ -0,0 +1,156 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestRetryPolicy_NextDelay(t *testing.T) {
+	policy := &RetryPolicy{
+		MaxAttempts:    4,
+		Backoff:        BackoffExponential,
+		InitialDelayMs: 100,
+		MaxDelayMs:     300,
+		RetryOnStatus:  []int{503},
+		RetryOnTimeout: true,
+	}
+
+	delay, retry := policy.nextDelay(1, 503, nil, nil)
+	assert.True(t, retry)
+	assert.Equal(t, 100*time.Millisecond, delay)
+
+	delay, _ = policy.nextDelay(2, 503, nil, nil)
+	assert.Equal(t, 200*time.Millisecond, delay)
+
+	delay, _ = policy.nextDelay(3, 503, nil, nil)
+	assert.Equal(t, 300*time.Millisecond, delay, "capped by max_delay_ms")
+
+	_, retry = policy.nextDelay(4, 503, nil, nil)
+	assert.False(t, retry, "attempts exhausted")
+
+	_, retry = policy.nextDelay(1, 500, nil, nil)
+	assert.False(t, retry, "status not listed")
+
+	_, retry = policy.nextDelay(1, 0, nil, &url.Error{Op: "Get", URL: "http://api.test", Err: timeoutError{}})
+	assert.True(t, retry)
+
+	refused := &url.Error{Op: "Get", URL: "http://api.test", Err: errors.New("connection refused")}
+	_, retry = policy.nextDelay(1, 0, nil, refused)
+	assert.False(t, retry, "network errors not enabled")
+
+	policy.RetryOnNetworkError = true
+	_, retry = policy.nextDelay(1, 0, nil, refused)
+	assert.True(t, retry)
+	_, retry = policy.nextDelay(1, 0, nil, errors.New("oauth2 token request failed"))
+	assert.False(t, retry, "errors from before sending are not retried")
+
+	var disabled *RetryPolicy
+	_, retry = disabled.nextDelay(1, 503, nil, nil)
+	assert.False(t, retry)
+}
+
+func TestRetryPolicy_RespectRetryAfter(t *testing.T) {
+	policy := &RetryPolicy{MaxAttempts: 2, InitialDelayMs: 10, RetryOnStatus: []int{429}, RespectRetryAfter: true, MaxDelayMs: 5000}
+
+	delay, retry := policy.nextDelay(1, 429, http.Header{"Retry-After": []string{"2"}}, nil)
+	assert.True(t, retry)
+	assert.Equal(t, 2*time.Second, delay)
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	delay, ok := parseRetryAfter(now.Add(90*time.Second).Format(http.TimeFormat), now)
+	assert.True(t, ok)
+	assert.Equal(t, 90*time.Second, delay)
+
+	_, ok = parseRetryAfter("soon", now)
+	assert.False(t, ok)
+
+	// Without max_delay_ms a server cannot hold the execution for a day
+	policy.MaxDelayMs = 0
+	delay, retry = policy.nextDelay(1, 429, http.Header{"Retry-After": []string{"86400"}}, nil)
+	assert.True(t, retry)
+	assert.Equal(t, defaultMaxRetryDelay, delay)
+}
+
+func TestSleepContext(t *testing.T) {
+	assert.True(t, sleepContext(context.Background(), time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	started := time.Now()
+	assert.False(t, sleepContext(ctx, time.Hour))
+	assert.Less(t, time.Since(started), time.Second)
+}
+
+func TestRequestService_Execute_Retries(t *testing.T) {
+	db, mock := setupTestDBRequest(t)
+	service := NewRequestService(db, nil)
+
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer ts.Close()
+
+	requestID := uuid.New()
+	collectionID := uuid.New()
+	workspaceID := uuid.New()
+	userID := uuid.New()
+
+	mock.ExpectQuery(`(?i)SELECT \* FROM "requests"`).
+		WithArgs(requestID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "collection_id", "url", "method", "settings"}).
+			AddRow(requestID, collectionID, ts.URL, "GET", `{"retry":{"max_attempts":3,"retry_on_status":[503]}}`))
+	mock.ExpectQuery(`(?i)SELECT \* FROM "collections"`).
+		WithArgs(collectionID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "workspace_id"}).AddRow(collectionID, workspaceID))
+	mock.ExpectQuery(`(?i)SELECT count\(\*\) FROM "workspace_members"`).
+		WithArgs(workspaceID, userID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(`(?i)SELECT "settings" FROM "workspaces"`).
+		WithArgs(workspaceID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"settings"}).AddRow(""))
+
+	// Logical execution, then its attempts as children
+	executionID := uuid.New()
+	mock.ExpectBegin()
+	mock.ExpectQuery(`(?i)INSERT INTO "executions"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(executionID))
+	mock.ExpectQuery(`(?i)INSERT INTO "executions" .* ON CONFLICT`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()).AddRow(uuid.New()).AddRow(uuid.New()))
+	mock.ExpectCommit()
+
+	execution, err := service.Execute(requestID, userID, "", nil, uuid.New(), nil, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, execution.StatusCode)
+	assert.Equal(t, 3, execution.AttemptCount)
+	require.Len(t, execution.Attempts, 3)
+	for i, attempt := range execution.Attempts {
+		assert.Equal(t, i+1, attempt.Attempt)
+		assert.Equal(t, executionID, *attempt.ParentExecutionID)
+	}
+	assert.Equal(t, http.StatusServiceUnavailable, execution.Attempts[0].StatusCode)
+	assert.Equal(t, http.StatusOK, execution.Attempts[2].StatusCode)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
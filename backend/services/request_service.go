@@ -2,10 +2,12 @@ package services
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"io"
 	"net/http"
+	"net/http/httptrace"
 	"time"
 	"backend/models"
 
@@ -13,19 +15,46 @@ import (
 	"gorm.io/gorm"
 )
 
+// Request kinds for models.Request.Kind. An empty kind is a plain HTTP request.
+const (
+	RequestKindHTTP      = "http"
+	RequestKindGraphQL   = "graphql"
+	RequestKindGRPC      = "grpc"
+	RequestKindWebSocket = "websocket"
+	RequestKindSSE       = "sse"
+)
+
 type RequestService struct {
 	db               *gorm.DB
 	workspaceService *WorkspaceService
+	oauth2Tokens     *oauth2TokenCache
+	transports       *transportPool
+	secrets          *SecretsService
+	traces           *TraceService
+	revisions        *RevisionService
+	contracts        *ContractService
+	schemas          *SchemaInferenceService
 }
 
-func NewRequestService(db *gorm.DB) *RequestService {
+// NewRequestService builds the service that runs requests. secrets holds the
+// mTLS material referenced by client settings.
+func NewRequestService(db *gorm.DB, secrets *SecretsService) *RequestService {
 	return &RequestService{
 		db:               db,
 		workspaceService: NewWorkspaceService(db),
+		oauth2Tokens:     sharedOAuth2Tokens,
+		transports:       sharedTransports,
+		secrets:          secrets,
+		traces:           NewTraceService(db),
+		revisions:        NewRevisionService(db),
+		contracts:        NewContractService(db),
+		schemas:          NewSchemaInferenceService(db),
 	}
 }
 
-func (s *RequestService) Create(collectionID uuid.UUID, name, method, url, headers, queryParams, body, description string, userID uuid.UUID) (*models.Request, error) {
+// Create adds a request to a collection. CollectionID is set here and the ID
+// is assigned by the database.
+func (s *RequestService) Create(collectionID uuid.UUID, request *models.Request, userID uuid.UUID) (*models.Request, error) {
 	var collection models.Collection
 	if err := s.db.First(&collection, collectionID).Error; err != nil {
 		return nil, err
@@ -35,25 +64,29 @@ func (s *RequestService) Create(collectionID uuid.UUID, name, method, url, heade
 		return nil, errors.New("access denied")
 	}
 
-	request := models.Request{
-		Name:         name,
-		Method:       method,
-		URL:          url,
-		Headers:      headers,
-		QueryParams:  queryParams,
-		Body:         body,
-		Description:  description,
-		CollectionID: collectionID,
+	request.ID = uuid.Nil
+	request.CollectionID = collectionID
+	if request.FolderID != nil {
+		if _, err := folderChain(s.db, collectionID, request.FolderID); err != nil {
+			return nil, err
+		}
 	}
 
-	if err := s.db.Create(&request).Error; err != nil {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(request).Error; err != nil {
+			return err
+		}
+		_, err := s.revisions.recordRequest(tx, collection.WorkspaceID, userID, request, RevisionCreate)
+		return err
+	})
+	if err != nil {
 		return nil, err
 	}
 
 	// Update collection request count
 	s.db.Model(&collection).Update("request_count", gorm.Expr("request_count + ?", 1))
 
-	return &request, nil
+	return request, nil
 }
 
 func (s *RequestService) GetByID(requestID, userID uuid.UUID) (*models.Request, error) {
@@ -66,6 +99,12 @@ func (s *RequestService) GetByID(requestID, userID uuid.UUID) (*models.Request,
 		return nil, errors.New("access denied")
 	}
 
+	folders, err := folderChain(s.db, request.CollectionID, request.FolderID)
+	if err != nil {
+		return nil, err
+	}
+	request.Folders = folders
+
 	return &request, nil
 }
 
@@ -89,8 +128,18 @@ func (s *RequestService) Update(requestID, userID uuid.UUID, updates map[string]
 	if err != nil {
 		return nil, err
 	}
+	if auth, ok := updates["auth"].(string); ok {
+		updates["auth"] = mergeAuthSecrets(auth, request.Auth)
+	}
 
-	if err := s.db.Model(request).Updates(updates).Error; err != nil {
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(request).Updates(updates).Error; err != nil {
+			return err
+		}
+		_, err := s.revisions.recordRequest(tx, request.Collection.WorkspaceID, userID, request, RevisionUpdate)
+		return err
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -103,26 +152,259 @@ func (s *RequestService) Delete(requestID, userID uuid.UUID) error {
 		return err
 	}
 
-	return s.db.Delete(request).Error
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(request).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&request.Collection).Update("request_count", gorm.Expr("request_count - ?", 1)).Error; err != nil {
+			return err
+		}
+		_, err := s.revisions.recordRequest(tx, request.Collection.WorkspaceID, userID, request, RevisionDelete)
+		return err
+	})
 }
 
 func (s *RequestService) Execute(requestID, userID uuid.UUID, overrideURL string, overrideHeaders map[string]string, traceID uuid.UUID, spanID, parentSpanID *uuid.UUID) (*models.Execution, error) {
+	return s.ExecuteContext(context.Background(), requestID, userID, overrideURL, overrideHeaders, traceID, spanID, parentSpanID)
+}
+
+// ExecuteContext is Execute with a context that, once done, cancels the
+// request in flight and any retries still waiting.
+func (s *RequestService) ExecuteContext(ctx context.Context, requestID, userID uuid.UUID, overrideURL string, overrideHeaders map[string]string, traceID uuid.UUID, spanID, parentSpanID *uuid.UUID) (*models.Execution, error) {
 	request, err := s.GetByID(requestID, userID)
 	if err != nil {
 		return nil, err
 	}
+	return s.execute(ctx, request, nil, overrideURL, overrideHeaders, traceID, spanID, parentSpanID)
+}
 
+// execute sends a loaded request. vars fill {{variable}} placeholders and
+// take precedence over folder variables.
+func (s *RequestService) execute(ctx context.Context, request *models.Request, vars map[string]string, overrideURL string, overrideHeaders map[string]string, traceID uuid.UUID, spanID, parentSpanID *uuid.UUID) (*models.Execution, error) {
+	if spanID == nil || *spanID == uuid.Nil {
+		newSpanID := uuid.New()
+		spanID = &newSpanID
+	}
+
+	resolved := folderVariables(request)
+	for key, value := range vars {
+		resolved[key] = value
+	}
+	if len(resolved) > 0 {
+		request = resolveRequestVariables(request, resolved)
+	}
+
+	var execution *models.Execution
+	var err error
+	switch request.Kind {
+	case RequestKindGRPC:
+		execution, err = s.executeGRPC(ctx, request, overrideURL, overrideHeaders, traceID, spanID, parentSpanID)
+	case RequestKindWebSocket:
+		execution, err = s.executeWebSocket(ctx, request, overrideURL, overrideHeaders, traceID, spanID, parentSpanID)
+	case RequestKindSSE:
+		execution, err = s.executeSSE(ctx, request, overrideURL, overrideHeaders, traceID, spanID, parentSpanID)
+	default:
+		execution, err = s.executeHTTP(ctx, request, overrideURL, overrideHeaders, traceID, spanID, parentSpanID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Contract violations are recorded on the side and never fail the execution
+	sentURL := request.URL
+	if overrideURL != "" {
+		sentURL = overrideURL
+	}
+	s.contracts.CheckExecution(request.Collection.WorkspaceID, request, execution, sentURL)
+	// and so is learning the response's schema
+	s.schemas.ObserveExecution(request.Collection.WorkspaceID, request, execution)
+
+	// The execution is already saved, so a span that fails to record is dropped
+	if traceID != uuid.Nil {
+		s.traces.RecordSpan(request.Collection.WorkspaceID, clientSpan(request, execution, overrideURL))
+	}
+
+	return execution, nil
+}
+
+// executeHTTP sends an HTTP or GraphQL request, retrying per its retry policy.
+func (s *RequestService) executeHTTP(ctx context.Context, request *models.Request, overrideURL string, overrideHeaders map[string]string, traceID uuid.UUID, spanID, parentSpanID *uuid.UUID) (*models.Execution, error) {
 	startTime := time.Now()
 
+	prepared, err := s.prepare(request, overrideURL, overrideHeaders)
+	if err != nil {
+		return nil, err
+	}
+	httpReq := prepared.httpReq.WithContext(ctx)
+
+	setTraceHeaders(httpReq.Header, traceID, spanID, parentSpanID)
+
+	// Validate GraphQL queries against the cached schema before sending
+	if prepared.graphQL != nil {
+		schema, err := s.cachedGraphQLSchema(request.Collection.WorkspaceID, httpReq.URL.String())
+		if err != nil {
+			return nil, err
+		}
+		if schema != nil {
+			if errs := validateGraphQLQuery(schema, prepared.graphQL.Query); len(errs) > 0 {
+				errorsJSON, _ := json.Marshal(errs)
+				execution := models.Execution{
+					RequestID:     request.ID,
+					TraceID:       traceID,
+					SpanID:        spanID,
+					ParentSpanID:  parentSpanID,
+					Timestamp:     startTime,
+					AttemptCount:  1,
+					ErrorMessage:  "graphql validation failed: " + errs[0].Message,
+					GraphQLErrors: string(errorsJSON),
+				}
+				if err := s.db.Create(&execution).Error; err != nil {
+					return nil, err
+				}
+				return &execution, nil
+			}
+		}
+	}
+
+	retryPolicy := prepared.settings.Retry
+
+	// Send attempts until one succeeds or the retry policy gives up
+	var attempts []models.Execution
+	for attempt := 1; ; attempt++ {
+		*prepared.redirects = nil
+		result := models.Execution{
+			RequestID:    request.ID,
+			TraceID:      traceID,
+			SpanID:       spanID,
+			ParentSpanID: parentSpanID,
+			Attempt:      attempt,
+		}
+		header, sendErr := s.send(prepared.client, httpReq, prepared.body, prepared.authConfig, &result)
+		if len(*prepared.redirects) > 0 {
+			redirectsJSON, _ := json.Marshal(*prepared.redirects)
+			result.RedirectChain = string(redirectsJSON)
+		}
+		attempts = append(attempts, result)
+
+		delay, retry := retryPolicy.nextDelay(attempt, result.StatusCode, header, sendErr)
+		if !retry || !sleepContext(ctx, delay) {
+			break
+		}
+	}
+
+	// The logical execution mirrors the final attempt; timing covers all of them
+	execution := attempts[len(attempts)-1]
+	execution.Attempt = 0
+	execution.AttemptCount = len(attempts)
+	execution.Timestamp = startTime
+	execution.ResponseTimeMs = time.Since(startTime).Milliseconds()
+	if retryPolicy.enabled() {
+		execution.Attempts = attempts
+	}
+
+	if prepared.graphQL != nil && execution.ResponseBody != "" {
+		if errs := extractGraphQLErrors(execution.ResponseBody); len(errs) > 0 {
+			errorsJSON, _ := json.Marshal(errs)
+			execution.GraphQLErrors = string(errorsJSON)
+		}
+	}
+
+	if err := s.db.Create(&execution).Error; err != nil {
+		return nil, err
+	}
+
+	return &execution, nil
+}
+
+// clientSettings loads the workspace settings and merges them with the
+// collection's and request's.
+func (s *RequestService) clientSettings(request *models.Request) (*ClientSettings, error) {
+	var workspace models.Workspace
+	if err := s.db.Select("settings").First(&workspace, request.Collection.WorkspaceID).Error; err != nil {
+		return nil, err
+	}
+	settings, err := resolveClientSettings(workspace.Settings, request)
+	if err != nil {
+		return nil, err
+	}
+	if settings.Retry != nil {
+		if err := settings.Retry.validate(); err != nil {
+			return nil, err
+		}
+	}
+	return settings, nil
+}
+
+// setTraceHeaders adds the trace and span context headers.
+func setTraceHeaders(header http.Header, traceID uuid.UUID, spanID, parentSpanID *uuid.UUID) {
+	if traceID != uuid.Nil {
+		header.Set("X-Trace-ID", traceID.String())
+	}
+
+	header.Set("X-Span-ID", spanID.String())
+	if parentSpanID != nil && *parentSpanID != uuid.Nil {
+		header.Set("X-Parent-Span-ID", parentSpanID.String())
+	}
+}
+
+// preparedRequest is a request ready to send, with the client and settings
+// resolved for it.
+type preparedRequest struct {
+	httpReq    *http.Request
+	body       []byte
+	client     *http.Client
+	authConfig *AuthConfig
+	settings   *ClientSettings
+	redirects  *[]RedirectHop // followed redirects of the latest attempt
+	graphQL    *GraphQLRequest
+}
+
+// prepare builds the outgoing request from a stored request: URL, query,
+// body, headers, auth and client settings. Trace headers are left to the caller.
+func (s *RequestService) prepare(request *models.Request, overrideURL string, overrideHeaders map[string]string) (*preparedRequest, error) {
+	authConfig, err := resolveAuthConfig(request)
+	if err != nil {
+		return nil, err
+	}
+
+	clientSettings, err := s.clientSettings(request)
+	if err != nil {
+		return nil, err
+	}
+
 	// Prepare request
 	url := request.URL
 	if overrideURL != "" {
 		url = overrideURL
 	}
 
+	url, err = applyQueryParams(url, request.QueryParams)
+	if err != nil {
+		return nil, err
+	}
+
+	var gql *GraphQLRequest
+	var bodyBytes []byte
+	var contentType string
+	if request.Kind == RequestKindGraphQL {
+		if gql, err = ParseGraphQLRequest(request.GraphQL); err != nil {
+			return nil, err
+		}
+		if request.Method == http.MethodGet {
+			url, err = graphQLQueryURL(url, gql)
+		} else {
+			bodyBytes, contentType, err = buildGraphQLBody(gql)
+		}
+	} else {
+		bodyBytes, contentType, err = s.buildRequestBody(request)
+	}
+	if err != nil {
+		return nil, err
+	}
+
 	var reqBody io.Reader
-	if request.Body != "" {
-		reqBody = bytes.NewBufferString(request.Body)
+	if bodyBytes != nil {
+		reqBody = bytes.NewReader(bodyBytes)
 	}
 
 	httpReq, err := http.NewRequest(request.Method, url, reqBody)
@@ -144,57 +426,71 @@ func (s *RequestService) Execute(requestID, userID uuid.UUID, overrideURL string
 		httpReq.Header.Set(k, v)
 	}
 
-	// Add trace ID
-	if traceID != uuid.Nil {
-		httpReq.Header.Set("X-Trace-ID", traceID.String())
+	// Body mode content type unless the user set one explicitly
+	if contentType != "" && httpReq.Header.Get("Content-Type") == "" {
+		httpReq.Header.Set("Content-Type", contentType)
 	}
 
-	// Add span context headers
-	if spanID == nil || *spanID == uuid.Nil {
-		newSpanID := uuid.New()
-		spanID = &newSpanID
-	}
-	httpReq.Header.Set("X-Span-ID", spanID.String())
-	if parentSpanID != nil && *parentSpanID != uuid.Nil {
-		httpReq.Header.Set("X-Parent-Span-ID", parentSpanID.String())
+	redirects := &[]RedirectHop{}
+	client, err := s.buildClient(clientSettings, request.Collection.WorkspaceID, redirects)
+	if err != nil {
+		return nil, err
 	}
+	client.Transport = authTransport(authConfig, client.Transport)
+
+	return &preparedRequest{
+		httpReq:    httpReq,
+		body:       bodyBytes,
+		client:     client,
+		authConfig: authConfig,
+		settings:   clientSettings,
+		redirects:  redirects,
+		graphQL:    gql,
+	}, nil
+}
 
-	// Execute request
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(httpReq)
-	
-	responseTime := time.Since(startTime).Milliseconds()
+// send makes one attempt with a copy of the prepared request and records the
+// outcome on execution. It returns the response headers and the transport
+// error, if any, for the retry decision.
+func (s *RequestService) send(client *http.Client, prepared *http.Request, body []byte, authConfig *AuthConfig, execution *models.Execution) (http.Header, error) {
+	timer := newExecutionTimer()
+	startTime := time.Now()
+	execution.Timestamp = startTime
 
-	execution := models.Execution{
-		RequestID:      requestID,
-		ResponseTimeMs: responseTime,
-		TraceID:        traceID,
-		SpanID:         spanID,
-		ParentSpanID:   parentSpanID,
-		Timestamp:      startTime,
+	httpReq := prepared.Clone(httptrace.WithClientTrace(prepared.Context(), timer.clientTrace()))
+	if body != nil {
+		httpReq.Body = io.NopCloser(bytes.NewReader(body))
 	}
 
-	if err != nil {
+	// Apply auth last so signatures cover every header
+	if err := s.applyAuth(httpReq, body, authConfig); err != nil {
 		execution.ErrorMessage = err.Error()
-		execution.StatusCode = 0
-	} else {
-		defer resp.Body.Close()
-		execution.StatusCode = resp.StatusCode
-
-		// Read response body
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		execution.ResponseBody = string(bodyBytes)
-
-		// Save response headers
-		headersJSON, _ := json.Marshal(resp.Header)
-		execution.ResponseHeaders = string(headersJSON)
+		execution.ResponseTimeMs = time.Since(startTime).Milliseconds()
+		return nil, err
 	}
 
-	if err := s.db.Create(&execution).Error; err != nil {
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		execution.ErrorMessage = err.Error()
+		execution.StatusCode = 0
+		execution.ResponseTimeMs = time.Since(startTime).Milliseconds()
+		timer.apply(execution, nil, time.Now())
 		return nil, err
 	}
+	defer resp.Body.Close()
+	execution.StatusCode = resp.StatusCode
 
-	return &execution, nil
+	// Read response body
+	responseBody, _ := io.ReadAll(resp.Body)
+	execution.ResponseBody = string(responseBody)
+	execution.ResponseTimeMs = time.Since(startTime).Milliseconds()
+	timer.apply(execution, resp, time.Now())
+
+	// Save response headers
+	headersJSON, _ := json.Marshal(resp.Header)
+	execution.ResponseHeaders = string(headersJSON)
+
+	return resp.Header, nil
 }
 
 func (s *RequestService) GetHistory(requestID, userID uuid.UUID, limit, offset int) ([]models.Execution, int64, error) {
@@ -206,9 +502,12 @@ func (s *RequestService) GetHistory(requestID, userID uuid.UUID, limit, offset i
 	var executions []models.Execution
 	var total int64
 
-	s.db.Model(&models.Execution{}).Where("request_id = ?", request.ID).Count(&total)
+	s.db.Model(&models.Execution{}).Where("request_id = ? AND parent_execution_id IS NULL", request.ID).Count(&total)
 	
-	err = s.db.Where("request_id = ?", request.ID).
+	err = s.db.Where("request_id = ? AND parent_execution_id IS NULL", request.ID).
+		Preload("Attempts", func(db *gorm.DB) *gorm.DB {
+			return db.Order("attempt")
+		}).
 		Order("timestamp DESC").
 		Limit(limit).
 		Offset(offset).
@@ -1,8 +1,12 @@
 package services
 
 import (
+	"backend/models"
+	"context"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"sync"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
@@ -11,6 +15,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
 )
 
 func setupTestDBRequest(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
@@ -27,7 +32,7 @@ func setupTestDBRequest(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
 
 func TestRequestService_Create(t *testing.T) {
 	db, mock := setupTestDBRequest(t)
-	service := NewRequestService(db)
+	service := NewRequestService(db, nil)
 
 	collectionID := uuid.New()
 	workspaceID := uuid.New()
@@ -46,8 +51,9 @@ func TestRequestService_Create(t *testing.T) {
 	// 3. Insert Request
 	mock.ExpectBegin()
 	mock.ExpectQuery(`(?i)INSERT INTO "requests"`).
-		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), collectionID, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), collectionID, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	expectRevisionRecorded(mock, RevisionResourceRequest, sqlmock.AnyArg())
 	mock.ExpectCommit()
 
 	// 4. Update Collection Request Count
@@ -58,14 +64,49 @@ func TestRequestService_Create(t *testing.T) {
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
-	req, err := service.Create(collectionID, "Test Req", "GET", "http://api.com", "{}", "{}", "", "desc", userID)
+	req, err := service.Create(collectionID, &models.Request{
+		Name:        "Test Req",
+		Method:      "GET",
+		URL:         "http://api.com",
+		Headers:     "{}",
+		QueryParams: "{}",
+		Description: "desc",
+	}, userID)
 	require.NoError(t, err)
 	assert.NotNil(t, req)
 }
 
+func TestJSONNullColumns(t *testing.T) {
+	serializer := models.JSONNullSerializer{}
+	value, err := serializer.Value(context.Background(), nil, reflect.Value{}, "")
+	require.NoError(t, err)
+	assert.Nil(t, value, "Postgres rejects an empty string as jsonb")
+	value, err = serializer.Value(context.Background(), nil, reflect.Value{}, `{"type": "none"}`)
+	require.NoError(t, err)
+	assert.Equal(t, `{"type": "none"}`, value)
+
+	for model, columns := range map[interface{}][]string{
+		&models.Workspace{}:     {"settings"},
+		&models.Collection{}:    {"auth", "settings"},
+		&models.Request{}:       {"auth", "settings", "graphql", "grpc", "stream"},
+		&models.Execution{}:     {"redirect_chain", "graphql_errors", "response_trailers", "transcript", "assertion_results"},
+		&models.GraphQLSchema{}: {"introspection"},
+		&models.Folder{}:        {"auth", "settings", "variables"},
+		&models.CollectionRun{}: {"report"},
+		&models.Contract{}:      {"request_schema", "response_schema"},
+		&models.FuzzRun{}:       {"report"},
+	} {
+		parsed, err := schema.Parse(model, &sync.Map{}, schema.NamingStrategy{})
+		require.NoError(t, err)
+		for _, column := range columns {
+			assert.NotNil(t, parsed.LookUpField(column).Serializer, "%s.%s", parsed.Name, column)
+		}
+	}
+}
+
 func TestRequestService_Execute(t *testing.T) {
 	db, mock := setupTestDBRequest(t)
-	service := NewRequestService(db)
+	service := NewRequestService(db, nil)
 
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -97,6 +138,11 @@ func TestRequestService_Execute(t *testing.T) {
 		WithArgs(workspaceID, userID).
 		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
 
+	// Workspace client settings
+	mock.ExpectQuery(`(?i)SELECT "settings" FROM "workspaces"`).
+		WithArgs(workspaceID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"settings"}).AddRow(""))
+
 	// 2. Insert Execution
 	mock.ExpectBegin()
 	mock.ExpectQuery(`(?i)INSERT INTO "executions"`).
@@ -106,11 +152,13 @@ func TestRequestService_Execute(t *testing.T) {
 	execution, err := service.Execute(requestID, userID, "", nil, uuid.New(), nil, nil)
 	require.NoError(t, err)
 	assert.Equal(t, 200, execution.StatusCode)
+	assert.Equal(t, "HTTP/1.1", execution.Protocol)
+	assert.Equal(t, ts.Listener.Addr().String(), execution.RemoteAddress)
 }
 
 func TestRequestService_Update(t *testing.T) {
 	db, mock := setupTestDBRequest(t)
-	service := NewRequestService(db)
+	service := NewRequestService(db, nil)
 
 	requestID := uuid.New()
 	workspaceID := uuid.New()
@@ -141,7 +189,7 @@ func TestRequestService_Update(t *testing.T) {
 
 	// GORM updates the association (Collection) because it's part of the struct
 	mock.ExpectQuery(`(?i)INSERT INTO "collections"`).
-		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), workspaceID, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), collectionID).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), workspaceID, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), collectionID).
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(collectionID))
 
 	// The actual Request UPDATE (must match all 4 arguments found in your logs)
@@ -154,6 +202,9 @@ func TestRequestService_Update(t *testing.T) {
 		).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
+	// The change is recorded as a revision in the same transaction
+	expectRevisionRecorded(mock, RevisionResourceRequest, requestID)
+
 	mock.ExpectCommit()
 
 	// --- 4. EXECUTION ---
@@ -166,7 +217,7 @@ func TestRequestService_Update(t *testing.T) {
 
 func TestRequestService_Delete(t *testing.T) {
 	db, mock := setupTestDBRequest(t)
-	service := NewRequestService(db)
+	service := NewRequestService(db, nil)
 
 	requestID := uuid.New()
 	userID := uuid.New()
@@ -193,6 +244,10 @@ func TestRequestService_Delete(t *testing.T) {
 	// Mock Delete
 	mock.ExpectBegin()
 	mock.ExpectExec(`(?i)UPDATE "requests" SET "deleted_at"`).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`(?i)UPDATE "collections" SET "request_count"=request_count - \$1`).
+		WithArgs(1, sqlmock.AnyArg(), collectionID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectRevisionRecorded(mock, RevisionResourceRequest, requestID)
 	mock.ExpectCommit()
 
 	err := service.Delete(requestID, userID)
@@ -201,7 +256,7 @@ func TestRequestService_Delete(t *testing.T) {
 
 func TestRequestService_Execute_AccessDenied(t *testing.T) {
 	db, mock := setupTestDBRequest(t)
-	service := NewRequestService(db)
+	service := NewRequestService(db, nil)
 
 	requestID := uuid.New()
 	collectionID := uuid.New()
@@ -0,0 +1,578 @@
+package services
+
+import (
+	"backend/models"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Snippet languages for GenerateSnippet.
+const (
+	SnippetCurl       = "curl"
+	SnippetGo         = "go"
+	SnippetPython     = "python"
+	SnippetJavaScript = "javascript"
+	SnippetHTTPie     = "httpie"
+)
+
+var variablePattern = regexp.MustCompile(`\{\{\s*([\w.-]+)\s*\}\}`)
+
+// snippetRequest is a request reduced to what the snippet renderers need,
+// with variables resolved and static auth turned into headers.
+type snippetRequest struct {
+	Method   string
+	URL      string
+	Headers  [][2]string
+	Body     string
+	HasBody  bool
+	Form     []snippetFormField
+	BodyFile string // binary body, sent from a local file of this name
+	Digest   *DigestAuthConfig
+	Note     string // what the snippet leaves out
+}
+
+type snippetFormField struct {
+	Name     string
+	Value    string
+	FileName string // file parts are read from a local file of this name
+}
+
+// GenerateSnippet renders a request as ready-to-run code. {{variable}}
+// placeholders are replaced with folder variables and, when environmentID is
+// set, that environment's values.
+func (s *RequestService) GenerateSnippet(requestID, userID uuid.UUID, language string, environmentID *uuid.UUID) (string, error) {
+	request, err := s.GetByID(requestID, userID)
+	if err != nil {
+		return "", err
+	}
+
+	// Environment values take precedence over folder variables
+	vars := folderVariables(request)
+	if environmentID != nil {
+		values, err := s.environmentVariables(request.Collection.WorkspaceID, *environmentID)
+		if err != nil {
+			return "", err
+		}
+		for key, value := range values {
+			vars[key] = value
+		}
+	}
+
+	snippet, err := s.snippetRequest(resolveRequestVariables(request, vars))
+	if err != nil {
+		return "", err
+	}
+
+	switch language {
+	case SnippetCurl:
+		return renderCurl(snippet), nil
+	case SnippetGo:
+		return renderGoSnippet(snippet), nil
+	case SnippetPython:
+		return renderPythonSnippet(snippet), nil
+	case SnippetJavaScript:
+		return renderJavaScriptSnippet(snippet), nil
+	case SnippetHTTPie:
+		return renderHTTPieSnippet(snippet), nil
+	default:
+		return "", fmt.Errorf("unsupported snippet language: %s", language)
+	}
+}
+
+// environmentVariables returns an environment's variables and decrypted
+// secrets, scoped to the workspace.
+func (s *RequestService) environmentVariables(workspaceID, environmentID uuid.UUID) (map[string]string, error) {
+	var environment models.Environment
+	err := s.db.Preload("Variables").Preload("Secrets").
+		Where("id = ? AND workspace_id = ?", environmentID, workspaceID).
+		First(&environment).Error
+	if err != nil {
+		return nil, fmt.Errorf("environment not found: %w", err)
+	}
+	vars := make(map[string]string, len(environment.Variables)+len(environment.Secrets))
+	for _, variable := range environment.Variables {
+		vars[variable.Key] = variable.Value
+	}
+	if len(environment.Secrets) > 0 && s.secrets == nil {
+		return nil, errors.New("environment secrets cannot be read: secrets are not configured")
+	}
+	for _, secret := range environment.Secrets {
+		value, err := s.secrets.decrypt(secret.Value)
+		if err != nil {
+			return nil, fmt.Errorf("environment secret %s: %w", secret.Key, err)
+		}
+		vars[secret.Key] = value
+	}
+	return vars, nil
+}
+
+// resolveRequestVariables returns a copy of request with {{variable}}
+// placeholders replaced. Values are JSON-escaped inside JSON columns.
+func resolveRequestVariables(request *models.Request, vars map[string]string) *models.Request {
+	resolved := *request
+	if len(vars) == 0 {
+		return &resolved
+	}
+
+	replace := func(s string, escapeJSON bool) string {
+		return variablePattern.ReplaceAllStringFunc(s, func(match string) string {
+			value, ok := vars[variablePattern.FindStringSubmatch(match)[1]]
+			if !ok {
+				return match
+			}
+			if escapeJSON {
+				encoded, _ := json.Marshal(value)
+				return string(encoded[1 : len(encoded)-1])
+			}
+			return value
+		})
+	}
+
+	resolved.URL = replace(request.URL, false)
+	resolved.Headers = replace(request.Headers, true)
+	resolved.QueryParams = replace(request.QueryParams, true)
+	resolved.Body = replace(request.Body, request.BodyMode != "" && request.BodyMode != BodyModeRaw)
+	resolved.Auth = replace(request.Auth, true)
+	resolved.GraphQL = replace(request.GraphQL, true)
+	resolved.GRPC = replace(request.GRPC, true)
+	resolved.Stream = replace(request.Stream, true)
+	resolved.Settings = replace(request.Settings, true)
+	resolved.Collection.Auth = replace(request.Collection.Auth, true)
+	resolved.Collection.Settings = replace(request.Collection.Settings, true)
+	resolved.Folders = make([]models.Folder, len(request.Folders))
+	for i, folder := range request.Folders {
+		folder.Auth = replace(folder.Auth, true)
+		folder.Settings = replace(folder.Settings, true)
+		resolved.Folders[i] = folder
+	}
+	return &resolved
+}
+
+func (s *RequestService) snippetRequest(request *models.Request) (*snippetRequest, error) {
+	switch request.Kind {
+	case "", RequestKindHTTP, RequestKindGraphQL, RequestKindSSE:
+	default:
+		return nil, fmt.Errorf("snippets are not available for %s requests", request.Kind)
+	}
+
+	rawURL, err := applyQueryParams(request.URL, request.QueryParams)
+	if err != nil {
+		return nil, err
+	}
+	snippet := &snippetRequest{Method: strings.ToUpper(request.Method)}
+
+	headers := map[string]string{}
+	if request.Headers != "" {
+		json.Unmarshal([]byte(request.Headers), &headers)
+	}
+
+	var contentType string
+	switch {
+	case request.Kind == RequestKindGraphQL:
+		gql, err := ParseGraphQLRequest(request.GraphQL)
+		if err != nil {
+			return nil, err
+		}
+		if snippet.Method == http.MethodGet {
+			if rawURL, err = graphQLQueryURL(rawURL, gql); err != nil {
+				return nil, err
+			}
+		} else {
+			body, bodyType, err := buildGraphQLBody(gql)
+			if err != nil {
+				return nil, err
+			}
+			snippet.Body, snippet.HasBody, contentType = string(body), true, bodyType
+		}
+	case request.BodyMode == BodyModeFormData:
+		params, err := ParseRequestParams(request.Body)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range params {
+			if !p.enabled() {
+				continue
+			}
+			field := snippetFormField{Name: p.Key, Value: p.Value}
+			if p.Type == "file" {
+				field.FileName = s.bodyFileName(p.FileID, p.Value, request.Collection.WorkspaceID)
+			}
+			snippet.Form = append(snippet.Form, field)
+		}
+	case request.BodyMode == BodyModeBinary:
+		var binary BinaryBody
+		if err := json.Unmarshal([]byte(request.Body), &binary); err != nil {
+			return nil, fmt.Errorf("invalid binary body: %w", err)
+		}
+		snippet.BodyFile = s.bodyFileName(binary.FileID, binary.FileID, request.Collection.WorkspaceID)
+		contentType = binary.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+	default:
+		body, bodyType, err := s.buildRequestBody(request)
+		if err != nil {
+			return nil, err
+		}
+		if body != nil {
+			snippet.Body, snippet.HasBody, contentType = string(body), true, bodyType
+		}
+	}
+	if contentType != "" && headerValue(headers, "Content-Type") == "" {
+		headers["Content-Type"] = contentType
+	}
+	if request.Kind == RequestKindSSE && headerValue(headers, "Accept") == "" {
+		headers["Accept"] = "text/event-stream"
+	}
+
+	authConfig, err := resolveAuthConfig(request)
+	if err != nil {
+		return nil, err
+	}
+	if authConfig != nil {
+		switch authConfig.Type {
+		case AuthTypeBasic:
+			if authConfig.Basic != nil && headerValue(headers, "Authorization") == "" {
+				credentials := authConfig.Basic.Username + ":" + authConfig.Basic.Password
+				headers["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials))
+			}
+		case AuthTypeBearer:
+			if authConfig.Bearer != nil && headerValue(headers, "Authorization") == "" {
+				headers["Authorization"] = "Bearer " + authConfig.Bearer.Token
+			}
+		case AuthTypeAPIKey:
+			if authConfig.APIKey != nil {
+				if authConfig.APIKey.In == "query" {
+					parsed, err := url.Parse(rawURL)
+					if err != nil {
+						return nil, err
+					}
+					query := parsed.Query()
+					query.Set(authConfig.APIKey.Key, authConfig.APIKey.Value)
+					parsed.RawQuery = query.Encode()
+					rawURL = parsed.String()
+				} else {
+					headers[authConfig.APIKey.Key] = authConfig.APIKey.Value
+				}
+			}
+		case AuthTypeDigest:
+			snippet.Digest = authConfig.Digest
+		default:
+			snippet.Note = fmt.Sprintf("%s auth is applied when the request runs and is not included", authConfig.Type)
+		}
+	}
+
+	snippet.URL = rawURL
+	for key, value := range headers {
+		snippet.Headers = append(snippet.Headers, [2]string{key, value})
+	}
+	sort.Slice(snippet.Headers, func(i, j int) bool { return snippet.Headers[i][0] < snippet.Headers[j][0] })
+	return snippet, nil
+}
+
+// bodyFileName names an uploaded file for snippets, which read it from disk.
+func (s *RequestService) bodyFileName(fileID, fallback string, workspaceID uuid.UUID) string {
+	var file models.File
+	if id, err := uuid.Parse(fileID); err == nil {
+		if err := s.db.Select("name").Where("id = ? AND workspace_id = ?", id, workspaceID).First(&file).Error; err == nil {
+			return file.Name
+		}
+	}
+	if fallback == "" {
+		return "file"
+	}
+	return fallback
+}
+
+func renderCurl(r *snippetRequest) string {
+	var b strings.Builder
+	if r.Note != "" {
+		fmt.Fprintf(&b, "# %s\n", r.Note)
+	}
+	b.WriteString("curl")
+	if r.Method != http.MethodGet || r.HasBody || r.BodyFile != "" || len(r.Form) > 0 {
+		if r.Method == http.MethodHead {
+			b.WriteString(" --head")
+		} else {
+			b.WriteString(" -X " + r.Method)
+		}
+	}
+	b.WriteString(" " + shellQuote(r.URL))
+
+	line := func(arg string) {
+		b.WriteString(" \\\n  " + arg)
+	}
+	for _, h := range r.Headers {
+		line("-H " + shellQuote(h[0]+": "+h[1]))
+	}
+	if r.Digest != nil {
+		line("--digest -u " + shellQuote(r.Digest.Username+":"+r.Digest.Password))
+	}
+	for _, f := range r.Form {
+		if f.FileName != "" {
+			line("-F " + shellQuote(f.Name+"=@"+f.FileName))
+		} else {
+			line("--form-string " + shellQuote(f.Name+"="+f.Value))
+		}
+	}
+	if r.BodyFile != "" {
+		line("--data-binary " + shellQuote("@"+r.BodyFile))
+	}
+	if r.HasBody {
+		line("--data-raw " + shellQuote(r.Body))
+	}
+	return b.String()
+}
+
+func renderHTTPieSnippet(r *snippetRequest) string {
+	var b strings.Builder
+	if r.Note != "" {
+		fmt.Fprintf(&b, "# %s\n", r.Note)
+	}
+	b.WriteString("http")
+	if len(r.Form) > 0 {
+		b.WriteString(" --multipart")
+	}
+	if r.Digest != nil {
+		b.WriteString(" -A digest -a " + shellQuote(r.Digest.Username+":"+r.Digest.Password))
+	}
+	if r.HasBody {
+		b.WriteString(" --raw " + shellQuote(r.Body))
+	}
+	b.WriteString(" " + r.Method + " " + shellQuote(r.URL))
+
+	for _, h := range r.Headers {
+		if len(r.Form) > 0 && strings.EqualFold(h[0], "Content-Type") {
+			continue
+		}
+		b.WriteString(" \\\n  " + shellQuote(h[0]+":"+h[1]))
+	}
+	for _, f := range r.Form {
+		if f.FileName != "" {
+			b.WriteString(" \\\n  " + shellQuote(f.Name+"@"+f.FileName))
+		} else {
+			b.WriteString(" \\\n  " + shellQuote(f.Name+"="+f.Value))
+		}
+	}
+	if r.BodyFile != "" {
+		b.WriteString(" \\\n  < " + shellQuote(r.BodyFile))
+	}
+	return b.String()
+}
+
+func renderGoSnippet(r *snippetRequest) string {
+	imports := []string{"fmt", "io", "net/http"}
+	var body strings.Builder
+
+	bodyVar := "nil"
+	switch {
+	case len(r.Form) > 0:
+		imports = append(imports, "bytes", "mime/multipart")
+		body.WriteString("\tvar form bytes.Buffer\n\twriter := multipart.NewWriter(&form)\n")
+		for _, f := range r.Form {
+			if f.FileName != "" {
+				if !containsString(imports, "os") {
+					imports = append(imports, "os")
+				}
+				// Each file part gets its own scope so several can be declared
+				fmt.Fprintf(&body, "\t{\n\t\tpart, _ := writer.CreateFormFile(%s, %s)\n", strconv.Quote(f.Name), strconv.Quote(f.FileName))
+				fmt.Fprintf(&body, "\t\tfileData, err := os.ReadFile(%s)\n\t\tif err != nil {\n\t\t\tpanic(err)\n\t\t}\n\t\tpart.Write(fileData)\n\t}\n", strconv.Quote(f.FileName))
+			} else {
+				fmt.Fprintf(&body, "\twriter.WriteField(%s, %s)\n", strconv.Quote(f.Name), strconv.Quote(f.Value))
+			}
+		}
+		body.WriteString("\twriter.Close()\n\n")
+		bodyVar = "&form"
+	case r.BodyFile != "":
+		imports = append(imports, "os")
+		fmt.Fprintf(&body, "\tbody, err := os.Open(%s)\n\tif err != nil {\n\t\tpanic(err)\n\t}\n\tdefer body.Close()\n\n", strconv.Quote(r.BodyFile))
+		bodyVar = "body"
+	case r.HasBody:
+		imports = append(imports, "strings")
+		fmt.Fprintf(&body, "\tbody := strings.NewReader(%s)\n\n", goStringLiteral(r.Body))
+		bodyVar = "body"
+	}
+	sort.Strings(imports)
+
+	var b strings.Builder
+	b.WriteString("package main\n\nimport (\n")
+	for _, imp := range imports {
+		fmt.Fprintf(&b, "\t%q\n", imp)
+	}
+	b.WriteString(")\n\nfunc main() {\n")
+	if r.Note != "" {
+		fmt.Fprintf(&b, "\t// %s\n", r.Note)
+	}
+	b.WriteString(body.String())
+	fmt.Fprintf(&b, "\treq, err := http.NewRequest(%s, %s, %s)\n\tif err != nil {\n\t\tpanic(err)\n\t}\n", strconv.Quote(r.Method), strconv.Quote(r.URL), bodyVar)
+	for _, h := range r.Headers {
+		if len(r.Form) > 0 && strings.EqualFold(h[0], "Content-Type") {
+			continue
+		}
+		fmt.Fprintf(&b, "\treq.Header.Set(%s, %s)\n", strconv.Quote(h[0]), strconv.Quote(h[1]))
+	}
+	if len(r.Form) > 0 {
+		b.WriteString("\treq.Header.Set(\"Content-Type\", writer.FormDataContentType())\n")
+	}
+	if r.Digest != nil {
+		b.WriteString("\t// Digest auth needs a client that answers the server challenge\n")
+	}
+	b.WriteString("\n\tresp, err := http.DefaultClient.Do(req)\n\tif err != nil {\n\t\tpanic(err)\n\t}\n\tdefer resp.Body.Close()\n\n")
+	b.WriteString("\tdata, _ := io.ReadAll(resp.Body)\n\tfmt.Println(resp.Status)\n\tfmt.Println(string(data))\n}\n")
+	return b.String()
+}
+
+func renderPythonSnippet(r *snippetRequest) string {
+	var b strings.Builder
+	b.WriteString("import requests\n")
+	if r.Digest != nil {
+		b.WriteString("from requests.auth import HTTPDigestAuth\n")
+	}
+	b.WriteString("\n")
+	if r.Note != "" {
+		fmt.Fprintf(&b, "# %s\n", r.Note)
+	}
+	fmt.Fprintf(&b, "url = %s\n", jsonString(r.URL))
+
+	headers := r.Headers
+	if len(r.Form) > 0 {
+		headers = withoutHeader(headers, "Content-Type")
+	}
+	args := []string{jsonString(r.Method), "url"}
+	if len(headers) > 0 {
+		b.WriteString("headers = {\n")
+		for _, h := range headers {
+			fmt.Fprintf(&b, "    %s: %s,\n", jsonString(h[0]), jsonString(h[1]))
+		}
+		b.WriteString("}\n")
+		args = append(args, "headers=headers")
+	}
+
+	switch {
+	case len(r.Form) > 0:
+		b.WriteString("files = {\n")
+		for _, f := range r.Form {
+			if f.FileName != "" {
+				fmt.Fprintf(&b, "    %s: open(%s, \"rb\"),\n", jsonString(f.Name), jsonString(f.FileName))
+			} else {
+				fmt.Fprintf(&b, "    %s: (None, %s),\n", jsonString(f.Name), jsonString(f.Value))
+			}
+		}
+		b.WriteString("}\n")
+		args = append(args, "files=files")
+	case r.BodyFile != "":
+		fmt.Fprintf(&b, "data = open(%s, \"rb\")\n", jsonString(r.BodyFile))
+		args = append(args, "data=data")
+	case r.HasBody:
+		fmt.Fprintf(&b, "data = %s\n", jsonString(r.Body))
+		args = append(args, "data=data")
+	}
+	if r.Digest != nil {
+		args = append(args, fmt.Sprintf("auth=HTTPDigestAuth(%s, %s)", jsonString(r.Digest.Username), jsonString(r.Digest.Password)))
+	}
+
+	fmt.Fprintf(&b, "\nresponse = requests.request(%s)\n", strings.Join(args, ", "))
+	b.WriteString("print(response.status_code)\nprint(response.text)\n")
+	return b.String()
+}
+
+func renderJavaScriptSnippet(r *snippetRequest) string {
+	var b strings.Builder
+	if r.Note != "" {
+		fmt.Fprintf(&b, "// %s\n", r.Note)
+	}
+	if r.Digest != nil {
+		b.WriteString("// Digest auth needs a client that answers the server challenge\n")
+	}
+
+	headers := r.Headers
+	bodyExpr := ""
+	switch {
+	case len(r.Form) > 0:
+		headers = withoutHeader(headers, "Content-Type")
+		b.WriteString("const form = new FormData();\n")
+		for _, f := range r.Form {
+			if f.FileName != "" {
+				fmt.Fprintf(&b, "form.append(%s, fileInput.files[0], %s); // %s\n", jsonString(f.Name), jsonString(f.FileName), f.FileName)
+			} else {
+				fmt.Fprintf(&b, "form.append(%s, %s);\n", jsonString(f.Name), jsonString(f.Value))
+			}
+		}
+		b.WriteString("\n")
+		bodyExpr = "form"
+	case r.BodyFile != "":
+		fmt.Fprintf(&b, "const body = fileInput.files[0]; // %s\n\n", r.BodyFile)
+		bodyExpr = "body"
+	case r.HasBody:
+		bodyExpr = jsonString(r.Body)
+	}
+
+	fmt.Fprintf(&b, "const response = await fetch(%s, {\n  method: %s,\n", jsonString(r.URL), jsonString(r.Method))
+	if len(headers) > 0 {
+		b.WriteString("  headers: {\n")
+		for _, h := range headers {
+			fmt.Fprintf(&b, "    %s: %s,\n", jsonString(h[0]), jsonString(h[1]))
+		}
+		b.WriteString("  },\n")
+	}
+	if bodyExpr != "" {
+		fmt.Fprintf(&b, "  body: %s,\n", bodyExpr)
+	}
+	b.WriteString("});\n\nconsole.log(response.status);\nconsole.log(await response.text());\n")
+	return b.String()
+}
+
+// shellQuote single-quotes s for POSIX shells.
+func shellQuote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\n'\"\\$`!*?&;|<>(){}[]#~") {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// jsonString quotes s as a JSON string, which Python and JavaScript accept as a literal.
+func jsonString(s string) string {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// goStringLiteral prefers a raw string for readability when s allows it.
+func goStringLiteral(s string) string {
+	if !strings.Contains(s, "`") && strconv.CanBackquote(strings.ReplaceAll(s, "\n", "")) {
+		return "`" + s + "`"
+	}
+	return strconv.Quote(s)
+}
+
+func withoutHeader(headers [][2]string, name string) [][2]string {
+	result := make([][2]string, 0, len(headers))
+	for _, h := range headers {
+		if !strings.EqualFold(h[0], name) {
+			result = append(result, h)
+		}
+	}
+	return result
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"backend/models"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateSnippet_ResolvesEnvironment(t *testing.T) {
+	db, mock := setupTestDBRequest(t)
+	secrets := NewSecretsService(db, "test-key")
+	service := NewRequestService(db, secrets)
+
+	requestID := uuid.New()
+	collectionID := uuid.New()
+	workspaceID := uuid.New()
+	userID := uuid.New()
+	environmentID := uuid.New()
+
+	mock.ExpectQuery(`(?i)SELECT \* FROM "requests"`).
+		WithArgs(requestID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "collection_id", "method", "url", "headers", "body_mode", "body", "auth"}).
+			AddRow(requestID, collectionID, "POST", "{{base_url}}/orders", `{"X-Tenant":"{{tenant}}"}`, BodyModeJSON,
+				`{"note":"{{note}}"}`, `{"type":"bearer","bearer":{"token":"{{token}}"}}`))
+	mock.ExpectQuery(`(?i)SELECT \* FROM "collections"`).
+		WithArgs(collectionID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "workspace_id"}).AddRow(collectionID, workspaceID))
+	mock.ExpectQuery(`(?i)SELECT count\(\*\) FROM "workspace_members"`).
+		WithArgs(workspaceID, userID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(`(?i)SELECT \* FROM "environments" WHERE \(id = \$1 AND workspace_id = \$2\)`).
+		WithArgs(environmentID, workspaceID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "workspace_id"}).AddRow(environmentID, workspaceID))
+	token, err := secrets.encrypt("t0k")
+	require.NoError(t, err)
+	mock.ExpectQuery(`(?i)SELECT \* FROM "environment_secrets"`).
+		WithArgs(environmentID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "environment_id", "key", "value"}).
+			AddRow(uuid.New(), environmentID, "token", token))
+	mock.ExpectQuery(`(?i)SELECT \* FROM "environment_variables"`).
+		WithArgs(environmentID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "environment_id", "key", "value"}).
+			AddRow(uuid.New(), environmentID, "base_url", "https://shop.test").
+			AddRow(uuid.New(), environmentID, "tenant", "acme").
+			AddRow(uuid.New(), environmentID, "note", `say "hi"`))
+
+	snippet, err := service.GenerateSnippet(requestID, userID, SnippetCurl, &environmentID)
+	require.NoError(t, err)
+
+	assert.Equal(t, `curl -X POST https://shop.test/orders \
+  -H 'Authorization: Bearer t0k' \
+  -H 'Content-Type: application/json' \
+  -H 'X-Tenant: acme' \
+  --data-raw '{"note":"say \"hi\""}'`, snippet)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSnippetRenderers(t *testing.T) {
+	request := &snippetRequest{
+		Method:  "POST",
+		URL:     "https://api.test/items?q=a b",
+		Headers: [][2]string{{"Content-Type", "application/json"}, {"X-Key", "it's"}},
+		Body:    `{"name":"widget"}`,
+		HasBody: true,
+	}
+
+	curl := renderCurl(request)
+	assert.Contains(t, curl, `curl -X POST 'https://api.test/items?q=a b'`)
+	assert.Contains(t, curl, `-H 'X-Key: it'\''s'`)
+
+	roundTrip, err := ParseCurl(curl)
+	require.NoError(t, err)
+	assert.Equal(t, "POST", roundTrip.Method)
+	assert.JSONEq(t, request.Body, roundTrip.Body)
+
+	assert.Contains(t, renderHTTPieSnippet(request), `http --raw '{"name":"widget"}' POST`)
+	assert.Contains(t, renderPythonSnippet(request), `response = requests.request("POST", url, headers=headers, data=data)`)
+	assert.Contains(t, renderJavaScriptSnippet(request), `body: "{\"name\":\"widget\"}",`)
+
+	_, err = parser.ParseFile(token.NewFileSet(), "main.go", renderGoSnippet(request), 0)
+	assert.NoError(t, err)
+}
+
+func TestSnippetRenderers_Form(t *testing.T) {
+	request := &snippetRequest{
+		Method:  "POST",
+		URL:     "https://api.test/upload",
+		Headers: [][2]string{{"Content-Type", "multipart/form-data"}},
+		Form: []snippetFormField{
+			{Name: "title", Value: "Q3"},
+			{Name: "report", FileName: "report.csv"},
+			{Name: "chart", FileName: "chart.png"},
+		},
+	}
+
+	assert.Contains(t, renderCurl(request), `-F report=@report.csv`)
+	assert.NotContains(t, renderPythonSnippet(request), "Content-Type")
+	assert.Contains(t, renderPythonSnippet(request), `"title": (None, "Q3"),`)
+
+	goSnippet := renderGoSnippet(request)
+	assert.Contains(t, goSnippet, "writer.FormDataContentType()")
+	_, err := parser.ParseFile(token.NewFileSet(), "main.go", goSnippet, 0)
+	assert.NoError(t, err)
+}
+
+func TestSnippetRequest_UnsupportedKind(t *testing.T) {
+	service := &RequestService{}
+	_, err := service.snippetRequest(&models.Request{Kind: RequestKindGRPC, Method: "POST", URL: "grpc://api.test"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,572 @@
+package services
+
+import (
+	"backend/models"
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// DefaultStreamDuration is how long a WebSocket or SSE session is captured
+// when the request does not set duration_ms; MaxStreamDuration caps it.
+const (
+	DefaultStreamDuration = 10 * time.Second
+	MaxStreamDuration     = 5 * time.Minute
+)
+
+// Stream assertion types.
+const (
+	StreamAssertContains = "contains"
+	StreamAssertEquals   = "equals"
+	StreamAssertRegex    = "regex"
+	StreamAssertJSONPath = "json_path" // value at path of a JSON message equals Value
+	StreamAssertMinCount = "min_count" // at least Value messages received
+)
+
+// StreamRequest is the stored stream column of a WebSocket or SSE request.
+type StreamRequest struct {
+	Messages    []StreamMessage   `json:"messages,omitempty"` // WebSocket only, sent in order after connecting
+	DurationMs  int               `json:"duration_ms,omitempty"`
+	MaxMessages int               `json:"max_messages,omitempty"` // stop after this many received, 0 waits for the duration
+	Assertions  []StreamAssertion `json:"assertions,omitempty"`
+}
+
+// StreamMessage is a scripted outbound WebSocket message.
+type StreamMessage struct {
+	Data    string `json:"data"`
+	Binary  bool   `json:"binary,omitempty"`   // data is base64 and sent as a binary frame
+	DelayMs int    `json:"delay_ms,omitempty"` // wait before sending, after the previous message
+}
+
+// StreamAssertion is checked against the received messages once the session ends.
+type StreamAssertion struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+	Path  string `json:"path,omitempty"`  // json_path: dotted path, e.g. data.items.0.id
+	Index *int   `json:"index,omitempty"` // received message to check, unset passes if any matches
+	Event string `json:"event,omitempty"` // SSE: only consider events of this type
+}
+
+type StreamAssertionResult struct {
+	StreamAssertion
+	Passed  bool   `json:"passed"`
+	Message string `json:"message,omitempty"`
+}
+
+// StreamFrame is one entry of an execution's transcript.
+type StreamFrame struct {
+	Direction string    `json:"direction"` // sent, received
+	Type      string    `json:"type"`      // text, binary or close for WebSocket, the event type for SSE
+	Data      string    `json:"data"`      // binary frames are base64 encoded
+	ID        string    `json:"id,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	OffsetMs  float64   `json:"offset_ms"` // since the session opened
+}
+
+// ParseStreamRequest parses the stored stream column. An empty column uses the defaults.
+func ParseStreamRequest(raw string) (*StreamRequest, error) {
+	var stream StreamRequest
+	if strings.TrimSpace(raw) != "" && raw != "null" {
+		if err := json.Unmarshal([]byte(raw), &stream); err != nil {
+			return nil, fmt.Errorf("invalid stream settings: %w", err)
+		}
+	}
+	if time.Duration(stream.DurationMs)*time.Millisecond > MaxStreamDuration {
+		return nil, fmt.Errorf("duration_ms must be at most %d", MaxStreamDuration.Milliseconds())
+	}
+	for _, assertion := range stream.Assertions {
+		switch assertion.Type {
+		case StreamAssertContains, StreamAssertEquals, StreamAssertJSONPath:
+		case StreamAssertRegex:
+			if _, err := regexp.Compile(assertion.Value); err != nil {
+				return nil, fmt.Errorf("invalid assertion pattern: %w", err)
+			}
+		case StreamAssertMinCount:
+			if _, err := strconv.Atoi(assertion.Value); err != nil {
+				return nil, fmt.Errorf("min_count assertion needs a number: %q", assertion.Value)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported assertion type: %s", assertion.Type)
+		}
+	}
+	return &stream, nil
+}
+
+func (r *StreamRequest) duration() time.Duration {
+	if r.DurationMs <= 0 {
+		return DefaultStreamDuration
+	}
+	return time.Duration(r.DurationMs) * time.Millisecond
+}
+
+// streamTranscript collects frames from the reading and writing goroutines.
+type streamTranscript struct {
+	mu       sync.Mutex
+	start    time.Time
+	frames   []StreamFrame
+	received int
+}
+
+func (t *streamTranscript) record(frame StreamFrame) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	frame.Timestamp = time.Now()
+	frame.OffsetMs = phaseMs(t.start, frame.Timestamp)
+	t.frames = append(t.frames, frame)
+	if frame.Direction == "received" {
+		t.received++
+	}
+	return t.received
+}
+
+// finish stores the transcript and assertion results on the execution.
+func (t *streamTranscript) finish(execution *models.Execution, stream *StreamRequest) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.frames == nil {
+		t.frames = []StreamFrame{}
+	}
+	transcriptJSON, _ := json.Marshal(t.frames)
+	execution.Transcript = string(transcriptJSON)
+	if len(stream.Assertions) > 0 {
+		resultsJSON, _ := json.Marshal(checkStreamAssertions(stream.Assertions, t.frames))
+		execution.AssertionResults = string(resultsJSON)
+	}
+	execution.ResponseTimeMs = time.Since(execution.Timestamp).Milliseconds()
+}
+
+// executeWebSocket opens a WebSocket, sends the scripted messages and records
+// every frame until the duration passes, max_messages arrive or the server closes.
+func (s *RequestService) executeWebSocket(ctx context.Context, request *models.Request, overrideURL string, overrideHeaders map[string]string, traceID uuid.UUID, spanID, parentSpanID *uuid.UUID) (*models.Execution, error) {
+	stream, err := ParseStreamRequest(request.Stream)
+	if err != nil {
+		return nil, err
+	}
+	prepared, err := s.prepare(request, overrideURL, overrideHeaders)
+	if err != nil {
+		return nil, err
+	}
+	httpReq := prepared.httpReq
+	setTraceHeaders(httpReq.Header, traceID, spanID, parentSpanID)
+	if err := s.applyAuth(httpReq, nil, prepared.authConfig); err != nil {
+		return nil, err
+	}
+
+	dialer, err := s.webSocketDialer(prepared.settings, request.Collection.WorkspaceID)
+	if err != nil {
+		return nil, err
+	}
+	wsURL, err := webSocketURL(httpReq.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	startTime := time.Now()
+	execution := models.Execution{
+		RequestID:     request.ID,
+		TraceID:       traceID,
+		SpanID:        spanID,
+		ParentSpanID:  parentSpanID,
+		Timestamp:     startTime,
+		AttemptCount:  1,
+		Protocol:      "WebSocket",
+		RemoteAddress: httpReq.URL.Host,
+	}
+	transcript := &streamTranscript{start: startTime}
+
+	ctx, cancel := context.WithTimeout(ctx, stream.duration())
+	defer cancel()
+
+	conn, resp, err := dialer.DialContext(ctx, wsURL, httpReq.Header)
+	if resp != nil {
+		execution.StatusCode = resp.StatusCode
+		headersJSON, _ := json.Marshal(resp.Header)
+		execution.ResponseHeaders = string(headersJSON)
+	}
+	if err != nil {
+		// A rejected handshake keeps the response body for debugging
+		if resp != nil && resp.Body != nil {
+			body, _ := io.ReadAll(resp.Body)
+			execution.ResponseBody = string(body)
+		}
+		execution.ErrorMessage = err.Error()
+		transcript.finish(&execution, stream)
+		if err := s.db.Create(&execution).Error; err != nil {
+			return nil, err
+		}
+		return &execution, nil
+	}
+	execution.TimeToFirstByteMs = phaseMs(startTime, time.Now())
+
+	readDone := make(chan error, 1)
+	readerExited := make(chan struct{})
+	go func() {
+		defer close(readerExited)
+		for {
+			messageType, data, err := conn.ReadMessage()
+			if err != nil {
+				var closeErr *websocket.CloseError
+				if errors.As(err, &closeErr) {
+					transcript.record(StreamFrame{Direction: "received", Type: "close", Data: closeFrameData(closeErr)})
+					err = nil
+				}
+				readDone <- err
+				return
+			}
+			frame := StreamFrame{Direction: "received", Type: "text", Data: string(data)}
+			if messageType == websocket.BinaryMessage {
+				frame.Type = "binary"
+				frame.Data = base64.StdEncoding.EncodeToString(data)
+			}
+			if received := transcript.record(frame); stream.MaxMessages > 0 && received >= stream.MaxMessages {
+				readDone <- nil
+				return
+			}
+		}
+	}()
+
+	sessionErr := func() error {
+		for _, message := range stream.Messages {
+			select {
+			case <-time.After(time.Duration(message.DelayMs) * time.Millisecond):
+			case <-ctx.Done():
+				return nil
+			case err := <-readDone:
+				return err
+			}
+
+			messageType, data := websocket.TextMessage, []byte(message.Data)
+			frameType := "text"
+			if message.Binary {
+				decoded, err := base64.StdEncoding.DecodeString(message.Data)
+				if err != nil {
+					return fmt.Errorf("binary message is not base64: %w", err)
+				}
+				messageType, data, frameType = websocket.BinaryMessage, decoded, "binary"
+			}
+			// Recorded first so a fast reply cannot precede it in the transcript
+			transcript.record(StreamFrame{Direction: "sent", Type: frameType, Data: message.Data})
+			if err := conn.WriteMessage(messageType, data); err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-readDone:
+			return err
+		}
+	}()
+
+	// Close politely, then wait for the reader so the transcript is complete
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	conn.Close()
+	<-readerExited
+
+	if sessionErr != nil && ctx.Err() == nil {
+		execution.ErrorMessage = sessionErr.Error()
+	}
+	transcript.finish(&execution, stream)
+
+	if err := s.db.Create(&execution).Error; err != nil {
+		return nil, err
+	}
+
+	return &execution, nil
+}
+
+// executeSSE subscribes to a server-sent events stream and records events
+// until the duration passes, max_messages arrive or the server ends the stream.
+func (s *RequestService) executeSSE(ctx context.Context, request *models.Request, overrideURL string, overrideHeaders map[string]string, traceID uuid.UUID, spanID, parentSpanID *uuid.UUID) (*models.Execution, error) {
+	stream, err := ParseStreamRequest(request.Stream)
+	if err != nil {
+		return nil, err
+	}
+	prepared, err := s.prepare(request, overrideURL, overrideHeaders)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, stream.duration())
+	defer cancel()
+
+	httpReq := prepared.httpReq.WithContext(ctx)
+	if httpReq.Header.Get("Accept") == "" {
+		httpReq.Header.Set("Accept", "text/event-stream")
+	}
+	httpReq.Header.Set("Cache-Control", "no-cache")
+	setTraceHeaders(httpReq.Header, traceID, spanID, parentSpanID)
+	if err := s.applyAuth(httpReq, prepared.body, prepared.authConfig); err != nil {
+		return nil, err
+	}
+
+	// The session duration bounds the stream, not the client timeout
+	client := *prepared.client
+	client.Timeout = 0
+
+	startTime := time.Now()
+	execution := models.Execution{
+		RequestID:     request.ID,
+		TraceID:       traceID,
+		SpanID:        spanID,
+		ParentSpanID:  parentSpanID,
+		Timestamp:     startTime,
+		AttemptCount:  1,
+		Protocol:      "SSE",
+		RemoteAddress: httpReq.URL.Host,
+	}
+	transcript := &streamTranscript{start: startTime}
+
+	sessionErr := func() error {
+		resp, err := client.Do(httpReq)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		execution.StatusCode = resp.StatusCode
+		execution.TimeToFirstByteMs = phaseMs(startTime, time.Now())
+		headersJSON, _ := json.Marshal(resp.Header)
+		execution.ResponseHeaders = string(headersJSON)
+
+		if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+			body, _ := io.ReadAll(resp.Body)
+			execution.ResponseBody = string(body)
+			return fmt.Errorf("expected an event stream, got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+		}
+
+		return readServerSentEvents(resp.Body, func(frame StreamFrame) bool {
+			received := transcript.record(frame)
+			return stream.MaxMessages == 0 || received < stream.MaxMessages
+		})
+	}()
+
+	if sessionErr != nil && ctx.Err() == nil {
+		execution.ErrorMessage = sessionErr.Error()
+	}
+	transcript.finish(&execution, stream)
+
+	if err := s.db.Create(&execution).Error; err != nil {
+		return nil, err
+	}
+
+	return &execution, nil
+}
+
+// readServerSentEvents parses an event stream and passes each event to emit
+// until emit returns false or the stream ends.
+func readServerSentEvents(body io.Reader, emit func(StreamFrame) bool) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var eventType, lastID string
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if len(data) > 0 {
+				frame := StreamFrame{Direction: "received", Type: eventType, Data: strings.Join(data, "\n"), ID: lastID}
+				if frame.Type == "" {
+					frame.Type = "message"
+				}
+				if !emit(frame) {
+					return nil
+				}
+			}
+			eventType, data = "", nil
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue // comment or keep-alive
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			eventType = value
+		case "data":
+			data = append(data, value)
+		case "id":
+			lastID = value
+		}
+	}
+	return scanner.Err()
+}
+
+func (s *RequestService) webSocketDialer(settings *ClientSettings, workspaceID uuid.UUID) (*websocket.Dialer, error) {
+	opts, err := s.transportOptions(settings, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := newTLSConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	handshakeTimeout := DefaultRequestTimeout
+	if settings.TimeoutMs != nil && *settings.TimeoutMs > 0 {
+		handshakeTimeout = time.Duration(*settings.TimeoutMs) * time.Millisecond
+	}
+
+	dialer := &websocket.Dialer{
+		TLSClientConfig:  tlsConfig,
+		HandshakeTimeout: handshakeTimeout,
+		Proxy:            http.ProxyFromEnvironment,
+	}
+	if opts.proxyURL != "" {
+		proxy, err := url.Parse(opts.proxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy url: %w", err)
+		}
+		dialer.Proxy = http.ProxyURL(proxy)
+	}
+	return dialer, nil
+}
+
+// webSocketURL accepts ws(s):// URLs as well as http(s):// ones.
+func webSocketURL(u *url.URL) (string, error) {
+	wsURL := *u
+	switch u.Scheme {
+	case "ws", "wss":
+	case "http":
+		wsURL.Scheme = "ws"
+	case "https":
+		wsURL.Scheme = "wss"
+	default:
+		return "", fmt.Errorf("unsupported websocket scheme: %s", u.Scheme)
+	}
+	return wsURL.String(), nil
+}
+
+func closeFrameData(err *websocket.CloseError) string {
+	if err.Text == "" {
+		return strconv.Itoa(err.Code)
+	}
+	return fmt.Sprintf("%d %s", err.Code, err.Text)
+}
+
+// checkStreamAssertions evaluates assertions against the received frames.
+func checkStreamAssertions(assertions []StreamAssertion, frames []StreamFrame) []StreamAssertionResult {
+	results := make([]StreamAssertionResult, 0, len(assertions))
+	for _, assertion := range assertions {
+		var received []StreamFrame
+		for _, frame := range frames {
+			if frame.Direction != "received" || frame.Type == "close" {
+				continue
+			}
+			if assertion.Event != "" && frame.Type != assertion.Event {
+				continue
+			}
+			received = append(received, frame)
+		}
+
+		result := StreamAssertionResult{StreamAssertion: assertion}
+		switch {
+		case assertion.Type == StreamAssertMinCount:
+			want, _ := strconv.Atoi(assertion.Value)
+			result.Passed = len(received) >= want
+			if !result.Passed {
+				result.Message = fmt.Sprintf("received %d messages, want at least %d", len(received), want)
+			}
+		case assertion.Index != nil:
+			if *assertion.Index < 0 || *assertion.Index >= len(received) {
+				result.Message = fmt.Sprintf("no message at index %d, received %d", *assertion.Index, len(received))
+				break
+			}
+			result.Passed, result.Message = matchStreamMessage(assertion, received[*assertion.Index].Data)
+		default:
+			result.Message = "no received message matched"
+			for _, frame := range received {
+				if passed, _ := matchStreamMessage(assertion, frame.Data); passed {
+					result.Passed, result.Message = true, ""
+					break
+				}
+			}
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+func matchStreamMessage(assertion StreamAssertion, data string) (bool, string) {
+	switch assertion.Type {
+	case StreamAssertContains:
+		if strings.Contains(data, assertion.Value) {
+			return true, ""
+		}
+		return false, fmt.Sprintf("%q does not contain %q", data, assertion.Value)
+	case StreamAssertEquals:
+		if data == assertion.Value {
+			return true, ""
+		}
+		return false, fmt.Sprintf("got %q", data)
+	case StreamAssertRegex:
+		if regexp.MustCompile(assertion.Value).MatchString(data) {
+			return true, ""
+		}
+		return false, fmt.Sprintf("%q does not match %s", data, assertion.Value)
+	case StreamAssertJSONPath:
+		var doc interface{}
+		if err := json.Unmarshal([]byte(data), &doc); err != nil {
+			return false, "message is not JSON"
+		}
+		value, ok := jsonPathValue(doc, assertion.Path)
+		if !ok {
+			return false, fmt.Sprintf("path %s not found", assertion.Path)
+		}
+		actual, isString := value.(string)
+		if !isString {
+			encoded, _ := json.Marshal(value)
+			actual = string(encoded)
+		}
+		if actual == assertion.Value {
+			return true, ""
+		}
+		return false, fmt.Sprintf("%s is %s", assertion.Path, actual)
+	}
+	return false, "unsupported assertion"
+}
+
+// jsonPathValue walks a dotted path through decoded JSON. Numeric segments index arrays.
+func jsonPathValue(doc interface{}, path string) (interface{}, bool) {
+	if path == "" {
+		return doc, true
+	}
+	current := doc
+	for _, segment := range strings.Split(path, ".") {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, ok := node[segment]
+			if !ok {
+				return nil, false
+			}
+			current = value
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, false
+			}
+			current = node[index]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
@@ -0,0 +1,265 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func expectStreamRequest(mock sqlmock.Sqlmock, requestID, collectionID, workspaceID, userID uuid.UUID, rawURL, kind, stream string) {
+	mock.ExpectQuery(`(?i)SELECT \* FROM "requests"`).
+		WithArgs(requestID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "collection_id", "url", "method", "headers", "kind", "stream"}).
+			AddRow(requestID, collectionID, rawURL, "GET", `{"X-Client":"tracely"}`, kind, stream))
+	mock.ExpectQuery(`(?i)SELECT \* FROM "collections"`).
+		WithArgs(collectionID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "workspace_id"}).AddRow(collectionID, workspaceID))
+	mock.ExpectQuery(`(?i)SELECT count\(\*\) FROM "workspace_members"`).
+		WithArgs(workspaceID, userID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(`(?i)SELECT "settings" FROM "workspaces"`).
+		WithArgs(workspaceID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"settings"}).AddRow(""))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`(?i)INSERT INTO "executions"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectCommit()
+}
+
+func TestReadServerSentEvents(t *testing.T) {
+	input := ": keep-alive\n\nid: 1\ndata: first\n\nevent: price\ndata: {\"v\":1}\ndata: {\"v\":2}\n\nretry: 100\n\n"
+
+	var frames []StreamFrame
+	err := readServerSentEvents(strings.NewReader(input), func(frame StreamFrame) bool {
+		frames = append(frames, frame)
+		return true
+	})
+	require.NoError(t, err)
+
+	require.Len(t, frames, 2)
+	assert.Equal(t, StreamFrame{Direction: "received", Type: "message", Data: "first", ID: "1"}, frames[0])
+	assert.Equal(t, "price", frames[1].Type)
+	assert.Equal(t, "{\"v\":1}\n{\"v\":2}", frames[1].Data)
+	assert.Equal(t, "1", frames[1].ID, "last event id carries over")
+}
+
+func TestCheckStreamAssertions(t *testing.T) {
+	zero, five := 0, 5
+	frames := []StreamFrame{
+		{Direction: "sent", Type: "text", Data: "subscribe"},
+		{Direction: "received", Type: "text", Data: `{"type":"ack","items":[{"id":7}]}`},
+		{Direction: "received", Type: "text", Data: "tick 1"},
+		{Direction: "received", Type: "close", Data: "1000"},
+	}
+
+	results := checkStreamAssertions([]StreamAssertion{
+		{Type: StreamAssertJSONPath, Path: "items.0.id", Value: "7", Index: &zero},
+		{Type: StreamAssertRegex, Value: `^tick \d+$`},
+		{Type: StreamAssertContains, Value: "subscribe"},
+		{Type: StreamAssertMinCount, Value: "2"},
+		{Type: StreamAssertEquals, Value: "tick 1", Index: &five},
+	}, frames)
+
+	assert.True(t, results[0].Passed)
+	assert.True(t, results[1].Passed)
+	assert.False(t, results[2].Passed, "sent frames are not checked")
+	assert.True(t, results[3].Passed, "close frames are not counted")
+	assert.False(t, results[4].Passed)
+	assert.Equal(t, "no message at index 5, received 2", results[4].Message)
+}
+
+func TestParseStreamRequest_Validates(t *testing.T) {
+	stream, err := ParseStreamRequest("")
+	require.NoError(t, err)
+	assert.Equal(t, DefaultStreamDuration, stream.duration())
+
+	_, err = ParseStreamRequest(`{"duration_ms":600000}`)
+	assert.Error(t, err)
+
+	_, err = ParseStreamRequest(`{"assertions":[{"type":"regex","value":"("}]}`)
+	assert.Error(t, err)
+
+	_, err = ParseStreamRequest(`{"assertions":[{"type":"lt","value":"1"}]}`)
+	assert.Error(t, err)
+}
+
+func TestRequestService_Execute_WebSocket(t *testing.T) {
+	db, mock := setupTestDBRequest(t)
+	service := NewRequestService(db, nil)
+
+	var handshake http.Header
+	upgrader := websocket.Upgrader{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handshake = r.Header.Clone()
+		conn, err := upgrader.Upgrade(w, r, nil)
+		require.NoError(t, err)
+		defer conn.Close()
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"welcome"}`))
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			conn.WriteMessage(websocket.TextMessage, []byte("echo: "+string(data)))
+		}
+	}))
+	defer ts.Close()
+
+	requestID := uuid.New()
+	collectionID := uuid.New()
+	workspaceID := uuid.New()
+	userID := uuid.New()
+	traceID := uuid.New()
+
+	expectStreamRequest(mock, requestID, collectionID, workspaceID, userID, ts.URL+"/live", RequestKindWebSocket, `{
+		"messages":[{"data":"hello","delay_ms":50},{"data":"again","delay_ms":50}],
+		"duration_ms":2000,
+		"max_messages":3,
+		"assertions":[
+			{"type":"json_path","path":"type","value":"welcome","index":0},
+			{"type":"equals","value":"echo: again"},
+			{"type":"min_count","value":"4"}]}`)
+
+	execution, err := service.Execute(requestID, userID, "", nil, traceID, nil, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, "tracely", handshake.Get("X-Client"))
+	assert.Equal(t, traceID.String(), handshake.Get("X-Trace-ID"))
+	assert.Equal(t, http.StatusSwitchingProtocols, execution.StatusCode)
+	assert.Equal(t, "WebSocket", execution.Protocol)
+	assert.Empty(t, execution.ErrorMessage)
+
+	var frames []StreamFrame
+	require.NoError(t, json.Unmarshal([]byte(execution.Transcript), &frames))
+	var summary []string
+	for _, frame := range frames {
+		summary = append(summary, fmt.Sprintf("%s %s", frame.Direction, frame.Data))
+	}
+	assert.Equal(t, []string{
+		`received {"type":"welcome"}`,
+		"sent hello",
+		"received echo: hello",
+		"sent again",
+		"received echo: again",
+	}, summary)
+
+	var results []StreamAssertionResult
+	require.NoError(t, json.Unmarshal([]byte(execution.AssertionResults), &results))
+	require.Len(t, results, 3)
+	assert.True(t, results[0].Passed)
+	assert.True(t, results[1].Passed)
+	assert.False(t, results[2].Passed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRequestService_Execute_SSE(t *testing.T) {
+	db, mock := setupTestDBRequest(t)
+	service := NewRequestService(db, nil)
+
+	var accept string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accept = r.Header.Get("Accept")
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+		for i := 1; i <= 5; i++ {
+			fmt.Fprintf(w, "event: tick\nid: %d\ndata: {\"n\":%d}\n\n", i, i)
+			flusher.Flush()
+		}
+		<-r.Context().Done()
+	}))
+	defer ts.Close()
+
+	requestID := uuid.New()
+	collectionID := uuid.New()
+	workspaceID := uuid.New()
+	userID := uuid.New()
+
+	expectStreamRequest(mock, requestID, collectionID, workspaceID, userID, ts.URL, RequestKindSSE,
+		`{"max_messages":2,"duration_ms":2000,"assertions":[{"type":"json_path","path":"n","value":"2","event":"tick","index":1}]}`)
+
+	execution, err := service.Execute(requestID, userID, "", nil, uuid.New(), nil, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, "text/event-stream", accept)
+	assert.Equal(t, http.StatusOK, execution.StatusCode)
+	assert.Equal(t, "SSE", execution.Protocol)
+	assert.Empty(t, execution.ErrorMessage)
+
+	var frames []StreamFrame
+	require.NoError(t, json.Unmarshal([]byte(execution.Transcript), &frames))
+	require.Len(t, frames, 2)
+	assert.Equal(t, "tick", frames[1].Type)
+	assert.Equal(t, "2", frames[1].ID)
+	assert.Contains(t, execution.AssertionResults, `"passed":true`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRequestService_ExecuteContext_CancelStopsSSE(t *testing.T) {
+	db, mock := setupTestDBRequest(t)
+	service := NewRequestService(db, nil)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: first\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer ts.Close()
+
+	requestID := uuid.New()
+	collectionID := uuid.New()
+	workspaceID := uuid.New()
+	userID := uuid.New()
+
+	expectStreamRequest(mock, requestID, collectionID, workspaceID, userID, ts.URL, RequestKindSSE, `{"duration_ms":60000}`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	execution, err := service.ExecuteContext(ctx, requestID, userID, "", nil, uuid.New(), nil, nil)
+	require.NoError(t, err)
+
+	assert.Less(t, time.Since(started), 10*time.Second, "the caller's context bounds the stream")
+	var frames []StreamFrame
+	require.NoError(t, json.Unmarshal([]byte(execution.Transcript), &frames))
+	require.Len(t, frames, 1)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRequestService_Execute_SSERejected(t *testing.T) {
+	db, mock := setupTestDBRequest(t)
+	service := NewRequestService(db, nil)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":"no token"}`))
+	}))
+	defer ts.Close()
+
+	requestID := uuid.New()
+	collectionID := uuid.New()
+	workspaceID := uuid.New()
+	userID := uuid.New()
+
+	expectStreamRequest(mock, requestID, collectionID, workspaceID, userID, ts.URL, RequestKindSSE, "")
+
+	execution, err := service.Execute(requestID, userID, "", nil, uuid.New(), nil, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusUnauthorized, execution.StatusCode)
+	assert.Equal(t, `{"error":"no token"}`, execution.ResponseBody)
+	assert.Contains(t, execution.ErrorMessage, "expected an event stream")
+	assert.Equal(t, "[]", execution.Transcript)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"backend/models"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// executionTimer records connection phases for a single execution using
+// net/http/httptrace. Callbacks can fire on different goroutines. When
+// redirects are followed, the phases are those of the last hop.
+type executionTimer struct {
+	mu sync.Mutex
+
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	wroteRequest time.Time
+	firstByte    time.Time
+
+	remoteAddr string
+	reused     bool
+}
+
+func newExecutionTimer() *executionTimer {
+	return &executionTimer{}
+}
+
+func (t *executionTimer) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		// Each hop of a redirect chain gets a connection first
+		GetConn: func(string) {
+			t.mu.Lock()
+			t.dnsStart, t.dnsDone = time.Time{}, time.Time{}
+			t.connectStart, t.connectDone = time.Time{}, time.Time{}
+			t.tlsStart, t.tlsDone = time.Time{}, time.Time{}
+			t.wroteRequest, t.firstByte = time.Time{}, time.Time{}
+			t.mu.Unlock()
+		},
+		DNSStart: func(httptrace.DNSStartInfo) { t.mark(&t.dnsStart) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { t.mark(&t.dnsDone) },
+		ConnectStart: func(network, addr string) {
+			// Dual-stack hosts dial several addresses; time from the first
+			t.mu.Lock()
+			if t.connectStart.IsZero() {
+				t.connectStart = time.Now()
+			}
+			t.mu.Unlock()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			if err == nil {
+				t.mark(&t.connectDone)
+			}
+		},
+		TLSHandshakeStart: func() { t.mark(&t.tlsStart) },
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.mark(&t.tlsDone)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			t.reused = info.Reused
+			if info.Conn != nil {
+				t.remoteAddr = info.Conn.RemoteAddr().String()
+			}
+			t.mu.Unlock()
+		},
+		WroteRequest:         func(httptrace.WroteRequestInfo) { t.mark(&t.wroteRequest) },
+		GotFirstResponseByte: func() { t.mark(&t.firstByte) },
+	}
+}
+
+func (t *executionTimer) mark(field *time.Time) {
+	t.mu.Lock()
+	*field = time.Now()
+	t.mu.Unlock()
+}
+
+// apply copies the recorded phases and connection details onto the execution.
+// bodyDone is when the response body finished reading.
+func (t *executionTimer) apply(execution *models.Execution, resp *http.Response, bodyDone time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	execution.DNSLookupMs = phaseMs(t.dnsStart, t.dnsDone)
+	execution.TCPConnectMs = phaseMs(t.connectStart, t.connectDone)
+	execution.TLSHandshakeMs = phaseMs(t.tlsStart, t.tlsDone)
+	execution.TimeToFirstByteMs = phaseMs(t.wroteRequest, t.firstByte)
+	execution.ContentTransferMs = phaseMs(t.firstByte, bodyDone)
+	execution.ConnectionReused = t.reused
+	execution.RemoteAddress = t.remoteAddr
+
+	if resp == nil {
+		return
+	}
+
+	execution.Protocol = resp.Proto
+	if resp.TLS != nil {
+		execution.TLSVersion = tls.VersionName(resp.TLS.Version)
+		execution.TLSCipherSuite = tls.CipherSuiteName(resp.TLS.CipherSuite)
+		for _, cert := range resp.TLS.PeerCertificates {
+			notAfter := cert.NotAfter
+			if execution.TLSCertExpiresAt == nil || notAfter.Before(*execution.TLSCertExpiresAt) {
+				execution.TLSCertExpiresAt = &notAfter
+			}
+		}
+	}
+}
+
+func phaseMs(start, end time.Time) float64 {
+	if start.IsZero() || end.IsZero() || end.Before(start) {
+		return 0
+	}
+	return float64(end.Sub(start).Microseconds()) / 1000
+}
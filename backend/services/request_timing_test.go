@@ -0,0 +1,83 @@
+package services
+
+import (
+	"backend/models"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecutionTimer_RecordsTLSConnection(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(5 * time.Millisecond)
+		w.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer ts.Close()
+
+	timer := newExecutionTimer()
+	req, _ := http.NewRequest("GET", ts.URL, nil)
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), timer.clientTrace()))
+
+	resp, err := ts.Client().Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	io.ReadAll(resp.Body)
+
+	var execution models.Execution
+	timer.apply(&execution, resp, time.Now())
+
+	assert.Equal(t, "HTTP/1.1", execution.Protocol)
+	assert.Equal(t, ts.Listener.Addr().String(), execution.RemoteAddress)
+	assert.False(t, execution.ConnectionReused)
+	assert.Greater(t, execution.TCPConnectMs, 0.0)
+	assert.Greater(t, execution.TLSHandshakeMs, 0.0)
+	assert.GreaterOrEqual(t, execution.TimeToFirstByteMs, 5.0)
+	assert.NotEmpty(t, execution.TLSVersion)
+	assert.NotEmpty(t, execution.TLSCipherSuite)
+	require.NotNil(t, execution.TLSCertExpiresAt)
+	assert.Equal(t, ts.Certificate().NotAfter, *execution.TLSCertExpiresAt)
+}
+
+func TestPhaseMs(t *testing.T) {
+	start := time.Now()
+	assert.Equal(t, 1.5, phaseMs(start, start.Add(1500*time.Microsecond)))
+	assert.Equal(t, 0.0, phaseMs(time.Time{}, start))
+	assert.Equal(t, 0.0, phaseMs(start, start.Add(-time.Millisecond)))
+}
+
+func TestExecutionTimer_TimesLastRedirectHop(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer target.Close()
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		http.Redirect(w, r, target.URL, http.StatusFound)
+	}))
+	defer origin.Close()
+
+	timer := newExecutionTimer()
+	req, _ := http.NewRequest("GET", origin.URL, nil)
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), timer.clientTrace()))
+
+	client := &http.Client{Transport: &http.Transport{}}
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	io.ReadAll(resp.Body)
+
+	var execution models.Execution
+	timer.apply(&execution, resp, time.Now())
+
+	// The connect phase does not span the first hop's response
+	assert.Equal(t, target.Listener.Addr().String(), execution.RemoteAddress)
+	assert.Greater(t, execution.TCPConnectMs, 0.0)
+	assert.Less(t, execution.TCPConnectMs, 20.0)
+	assert.Less(t, execution.TimeToFirstByteMs, 20.0)
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"backend/models"
+	"encoding/json"
+	"net/url"
+	"strings"
+)
+
+// ClientServiceName is the service name on spans recorded for executions.
+const ClientServiceName = "tracely"
+
+// clientSpan describes an execution as the client span of its trace. It
+// reuses the execution's span ID, which was sent as X-Span-ID, so spans the
+// downstream service reports attach beneath it.
+func clientSpan(request *models.Request, execution *models.Execution, overrideURL string) *models.Span {
+	rawURL := request.URL
+	if overrideURL != "" {
+		rawURL = overrideURL
+	}
+	path := "/"
+	if parsed, err := url.Parse(rawURL); err == nil {
+		if parsed.User != nil {
+			parsed.User = nil
+			rawURL = parsed.String()
+		}
+		if parsed.Path != "" {
+			path = parsed.Path
+		}
+	}
+
+	method := strings.ToUpper(request.Method)
+	tags := map[string]interface{}{
+		"span.kind":     "client",
+		"request.id":    request.ID.String(),
+		"request.kind":  request.Kind,
+		"execution.id":  execution.ID.String(),
+		"http.method":   method,
+		"http.url":      rawURL,
+		"attempt_count": execution.AttemptCount,
+		"net.peer.addr": execution.RemoteAddress,
+		"http.protocol": execution.Protocol,
+	}
+	if request.Kind == "" {
+		tags["request.kind"] = RequestKindHTTP
+	}
+	if execution.StatusCode != 0 {
+		tags["http.status_code"] = execution.StatusCode
+	}
+
+	operationName := method + " " + path
+	switch request.Kind {
+	case RequestKindGRPC:
+		if call, err := ParseGRPCRequest(request.GRPC); err == nil {
+			operationName = call.Service + "/" + call.Method
+			tags["rpc.system"] = "grpc"
+			tags["rpc.service"] = call.Service
+			tags["rpc.method"] = call.Method
+		}
+		tags["rpc.grpc.status"] = execution.GRPCStatus
+	case RequestKindWebSocket:
+		operationName = "WebSocket " + path
+	}
+
+	phases := map[string]float64{
+		"timing.dns_lookup_ms":         execution.DNSLookupMs,
+		"timing.tcp_connect_ms":        execution.TCPConnectMs,
+		"timing.tls_handshake_ms":      execution.TLSHandshakeMs,
+		"timing.time_to_first_byte_ms": execution.TimeToFirstByteMs,
+		"timing.content_transfer_ms":   execution.ContentTransferMs,
+	}
+	for name, ms := range phases {
+		if ms > 0 {
+			tags[name] = ms
+		}
+	}
+
+	// Client spans count 4xx as failures as well as 5xx and transport errors
+	status := "ok"
+	logs := map[string]interface{}{}
+	if execution.ErrorMessage != "" || execution.StatusCode >= 400 {
+		status = "error"
+		tags["error"] = true
+	}
+	if execution.ErrorMessage != "" {
+		tags["error.message"] = execution.ErrorMessage
+		logs["error"] = execution.ErrorMessage
+	}
+
+	tagsJSON, _ := json.Marshal(tags)
+	logsJSON, _ := json.Marshal(logs)
+	return &models.Span{
+		ID:            *execution.SpanID,
+		TraceID:       execution.TraceID,
+		ParentSpanID:  execution.ParentSpanID,
+		OperationName: operationName,
+		ServiceName:   ClientServiceName,
+		StartTime:     execution.Timestamp,
+		DurationMs:    float64(execution.ResponseTimeMs),
+		Tags:          string(tagsJSON),
+		Logs:          string(logsJSON),
+		Status:        status,
+	}
+}
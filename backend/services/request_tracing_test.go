@@ -0,0 +1,123 @@
+package services
+
+import (
+	"backend/models"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientSpan(t *testing.T) {
+	spanID := uuid.New()
+	parentSpanID := uuid.New()
+	request := &models.Request{ID: uuid.New(), Method: "post", URL: "https://ann:pw@api.test/orders?page=1"}
+	execution := &models.Execution{
+		ID:                uuid.New(),
+		TraceID:           uuid.New(),
+		SpanID:            &spanID,
+		ParentSpanID:      &parentSpanID,
+		StatusCode:        503,
+		ResponseTimeMs:    42,
+		Timestamp:         time.Now(),
+		AttemptCount:      1,
+		TimeToFirstByteMs: 30.5,
+	}
+
+	span := clientSpan(request, execution, "")
+	assert.Equal(t, spanID, span.ID)
+	assert.Equal(t, execution.TraceID, span.TraceID)
+	assert.Equal(t, &parentSpanID, span.ParentSpanID)
+	assert.Equal(t, "POST /orders", span.OperationName)
+	assert.Equal(t, ClientServiceName, span.ServiceName)
+	assert.Equal(t, float64(42), span.DurationMs)
+	assert.Equal(t, "error", span.Status)
+
+	var tags map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(span.Tags), &tags))
+	assert.Equal(t, "client", tags["span.kind"])
+	assert.Equal(t, "https://api.test/orders?page=1", tags["http.url"], "credentials are dropped")
+	assert.Equal(t, float64(503), tags["http.status_code"])
+	assert.Equal(t, 30.5, tags["timing.time_to_first_byte_ms"])
+	assert.NotContains(t, tags, "timing.dns_lookup_ms")
+	assert.Equal(t, true, tags["error"])
+}
+
+func TestClientSpan_GRPC(t *testing.T) {
+	spanID := uuid.New()
+	request := &models.Request{
+		Kind:   RequestKindGRPC,
+		Method: "POST",
+		URL:    "grpc://localhost:50051",
+		GRPC:   `{"service":"grpc.health.v1.Health","method":"Check"}`,
+	}
+	execution := &models.Execution{SpanID: &spanID, StatusCode: 200, GRPCStatus: "OK"}
+
+	span := clientSpan(request, execution, "")
+	assert.Equal(t, "grpc.health.v1.Health/Check", span.OperationName)
+	assert.Equal(t, "ok", span.Status)
+	assert.Nil(t, span.ParentSpanID)
+}
+
+func TestRequestService_Execute_RecordsClientSpan(t *testing.T) {
+	db, mock := setupTestDBRequest(t)
+	service := NewRequestService(db, nil)
+
+	var received http.Header
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer ts.Close()
+
+	requestID := uuid.New()
+	collectionID := uuid.New()
+	workspaceID := uuid.New()
+	userID := uuid.New()
+	traceID := uuid.New()
+
+	mock.ExpectQuery(`(?i)SELECT \* FROM "requests"`).
+		WithArgs(requestID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "collection_id", "url", "method"}).
+			AddRow(requestID, collectionID, ts.URL+"/orders", "POST"))
+	mock.ExpectQuery(`(?i)SELECT \* FROM "collections"`).
+		WithArgs(collectionID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "workspace_id"}).AddRow(collectionID, workspaceID))
+	mock.ExpectQuery(`(?i)SELECT count\(\*\) FROM "workspace_members"`).
+		WithArgs(workspaceID, userID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(`(?i)SELECT "settings" FROM "workspaces"`).
+		WithArgs(workspaceID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"settings"}).AddRow(""))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`(?i)INSERT INTO "executions"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectCommit()
+
+	// The trace is created on its first span
+	mock.ExpectBegin()
+	mock.ExpectQuery(`(?i)INSERT INTO "traces" .* ON CONFLICT DO NOTHING`).
+		WillReturnRows(sqlmock.NewRows([]string{"span_count"}).AddRow(0))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery(`(?i)INSERT INTO "spans"`).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("ok"))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(`(?i)UPDATE "traces" SET .*end_time"=GREATEST\(end_time, \$\d\).*span_count"=span_count \+ \$\d.* WHERE id = \$\d`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	execution, err := service.Execute(requestID, userID, "", nil, traceID, nil, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, traceID.String(), received.Get("X-Trace-ID"))
+	assert.Equal(t, execution.SpanID.String(), received.Get("X-Span-ID"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,360 @@
+package services
+
+import (
+	"backend/models"
+	"encoding/json"
+	"errors"
+	"reflect"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Revisioned resource types and the actions that create revisions.
+const (
+	RevisionResourceRequest    = "request"
+	RevisionResourceCollection = "collection"
+
+	RevisionCreate  = "create"
+	RevisionUpdate  = "update"
+	RevisionDelete  = "delete"
+	RevisionRestore = "restore"
+)
+
+// Columns captured in a snapshot and written back on restore.
+var (
+	requestRevisionColumns    = []string{"name", "method", "url", "headers", "query_params", "body", "body_mode", "auth", "settings", "kind", "graphql", "grpc", "stream", "description"}
+	collectionRevisionColumns = []string{"name", "description", "auth", "settings"}
+)
+
+// revisionTables are the tables of the revisioned resources.
+var revisionTables = map[string]string{
+	RevisionResourceRequest:    "requests",
+	RevisionResourceCollection: "collections",
+}
+
+// revisionJSONColumns hold JSON strings. Snapshots embed them decoded so
+// revision diffs point into them, e.g. headers.Authorization.
+var revisionJSONColumns = map[string]bool{
+	"headers": true, "query_params": true, "body": true, "auth": true, "settings": true,
+	"graphql": true, "grpc": true, "stream": true,
+}
+
+// RevisionDiff lists the field changes between two revisions of a resource.
+type RevisionDiff struct {
+	ResourceType string       `json:"resource_type"`
+	ResourceID   uuid.UUID    `json:"resource_id"`
+	FromVersion  int          `json:"from_version"` // 0 when diffing the first revision against nothing
+	ToVersion    int          `json:"to_version"`
+	Changes      []BodyChange `json:"changes"`
+}
+
+// RevisionService keeps the change history of requests and collections.
+type RevisionService struct {
+	db               *gorm.DB
+	workspaceService *WorkspaceService
+}
+
+func NewRevisionService(db *gorm.DB) *RevisionService {
+	return &RevisionService{
+		db:               db,
+		workspaceService: NewWorkspaceService(db),
+	}
+}
+
+// List returns a resource's revisions, newest first.
+func (s *RevisionService) List(workspaceID, userID uuid.UUID, resourceType string, resourceID uuid.UUID) ([]models.Revision, error) {
+	if !s.workspaceService.HasAccess(workspaceID, userID) {
+		return nil, errors.New("access denied")
+	}
+
+	var revisions []models.Revision
+	err := s.db.Preload("Author").
+		Where("workspace_id = ? AND resource_type = ? AND resource_id = ?", workspaceID, resourceType, resourceID).
+		Order("version DESC").
+		Find(&revisions).Error
+	if err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+func (s *RevisionService) Get(workspaceID, userID, revisionID uuid.UUID) (*models.Revision, error) {
+	if !s.workspaceService.HasAccess(workspaceID, userID) {
+		return nil, errors.New("access denied")
+	}
+	return s.revision(workspaceID, revisionID)
+}
+
+// Diff compares a revision with another revision of the same resource. A nil
+// againstID compares it with the revision before it.
+func (s *RevisionService) Diff(workspaceID, userID, revisionID uuid.UUID, againstID *uuid.UUID) (*RevisionDiff, error) {
+	if !s.workspaceService.HasAccess(workspaceID, userID) {
+		return nil, errors.New("access denied")
+	}
+
+	target, err := s.revision(workspaceID, revisionID)
+	if err != nil {
+		return nil, err
+	}
+
+	var base *models.Revision
+	if againstID != nil {
+		if base, err = s.revision(workspaceID, *againstID); err != nil {
+			return nil, err
+		}
+		if base.ResourceType != target.ResourceType || base.ResourceID != target.ResourceID {
+			return nil, errors.New("revisions belong to different resources")
+		}
+	} else {
+		var previous models.Revision
+		err := s.db.Where("resource_type = ? AND resource_id = ? AND version < ?", target.ResourceType, target.ResourceID, target.Version).
+			Order("version DESC").
+			First(&previous).Error
+		if err == nil {
+			base = &previous
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+
+	diff := &RevisionDiff{
+		ResourceType: target.ResourceType,
+		ResourceID:   target.ResourceID,
+		ToVersion:    target.Version,
+		Changes:      []BodyChange{},
+	}
+	var baseSnapshot interface{} = map[string]interface{}{}
+	if base != nil {
+		diff.FromVersion = base.Version
+		json.Unmarshal([]byte(base.Snapshot), &baseSnapshot)
+	}
+	var targetSnapshot interface{}
+	json.Unmarshal([]byte(target.Snapshot), &targetSnapshot)
+
+	diffJSONValues(nil, baseSnapshot, targetSnapshot, nil, &diff.Changes)
+	return diff, nil
+}
+
+// RestoredRevision is the revision recorded by a restore.
+type RestoredRevision struct {
+	models.Revision
+	// UnrestoredSecrets are the auth secrets, e.g. bearer.token, that could
+	// not be restored because snapshots keep them redacted. They hold the
+	// resource's current values, or are empty where its current auth has none.
+	UnrestoredSecrets []string `json:"unrestored_secrets,omitempty"`
+}
+
+// Restore writes a revision's snapshot back to its resource, undeleting it if
+// needed, and records the result as a new revision.
+func (s *RevisionService) Restore(workspaceID, userID, revisionID uuid.UUID) (*RestoredRevision, error) {
+	if !s.workspaceService.HasAccess(workspaceID, userID) {
+		return nil, errors.New("access denied")
+	}
+
+	revision, err := s.revision(workspaceID, revisionID)
+	if err != nil {
+		return nil, err
+	}
+	var snapshot map[string]interface{}
+	if err := json.Unmarshal([]byte(revision.Snapshot), &snapshot); err != nil {
+		return nil, errors.New("revision snapshot is invalid")
+	}
+
+	var restored *models.Revision
+	var unrestored []string
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		switch revision.ResourceType {
+		case RevisionResourceRequest:
+			var request models.Request
+			if err := tx.Unscoped().First(&request, "id = ?", revision.ResourceID).Error; err != nil {
+				return err
+			}
+			if err := tx.First(&models.Collection{}, "id = ?", request.CollectionID).Error; err != nil {
+				return errors.New("the request's collection no longer exists")
+			}
+			values := restoreValues(snapshot, requestRevisionColumns)
+			if auth, ok := values["auth"].(string); ok {
+				values["auth"], unrestored = fillAuthSecrets(auth, request.Auth)
+			}
+			if folderID, ok := snapshot["folder_id"]; ok {
+				values["folder_id"] = restoreFolderID(tx, request.CollectionID, folderID)
+			}
+			values["deleted_at"] = nil
+			deleted := request.DeletedAt.Valid
+			if err := tx.Unscoped().Model(&request).Updates(values).Error; err != nil {
+				return err
+			}
+			if deleted {
+				if err := tx.Model(&models.Collection{}).Where("id = ?", request.CollectionID).
+					Update("request_count", gorm.Expr("request_count + ?", 1)).Error; err != nil {
+					return err
+				}
+			}
+			restored, err = s.recordRequest(tx, workspaceID, userID, &request, RevisionRestore)
+			return err
+		case RevisionResourceCollection:
+			var collection models.Collection
+			if err := tx.Unscoped().First(&collection, "id = ?", revision.ResourceID).Error; err != nil {
+				return err
+			}
+			values := restoreValues(snapshot, collectionRevisionColumns)
+			if auth, ok := values["auth"].(string); ok {
+				values["auth"], unrestored = fillAuthSecrets(auth, collection.Auth)
+			}
+			values["deleted_at"] = nil
+			if err := tx.Unscoped().Model(&collection).Updates(values).Error; err != nil {
+				return err
+			}
+			restored, err = s.recordCollection(tx, userID, &collection, RevisionRestore)
+			return err
+		default:
+			return errors.New("unknown revision resource type")
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &RestoredRevision{Revision: *restored, UnrestoredSecrets: unrestored}, nil
+}
+
+func (s *RevisionService) revision(workspaceID, revisionID uuid.UUID) (*models.Revision, error) {
+	var revision models.Revision
+	if err := s.db.Preload("Author").Where("id = ? AND workspace_id = ?", revisionID, workspaceID).First(&revision).Error; err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// recordRequest snapshots a request inside the caller's transaction.
+func (s *RevisionService) recordRequest(tx *gorm.DB, workspaceID, authorID uuid.UUID, request *models.Request, action string) (*models.Revision, error) {
+	snapshot := snapshotColumns(map[string]string{
+		"name":         request.Name,
+		"method":       request.Method,
+		"url":          request.URL,
+		"headers":      request.Headers,
+		"query_params": request.QueryParams,
+		"body":         request.Body,
+		"body_mode":    request.BodyMode,
+		"auth":         RedactAuth(request.Auth),
+		"settings":     request.Settings,
+		"kind":         request.Kind,
+		"graphql":      request.GraphQL,
+		"grpc":         request.GRPC,
+		"stream":       request.Stream,
+		"description":  request.Description,
+	})
+	snapshot["folder_id"] = request.FolderID
+	return s.record(tx, workspaceID, authorID, RevisionResourceRequest, request.ID, action, snapshot)
+}
+
+// recordCollection snapshots a collection's metadata inside the caller's transaction.
+func (s *RevisionService) recordCollection(tx *gorm.DB, authorID uuid.UUID, collection *models.Collection, action string) (*models.Revision, error) {
+	return s.record(tx, collection.WorkspaceID, authorID, RevisionResourceCollection, collection.ID, action, snapshotColumns(map[string]string{
+		"name":        collection.Name,
+		"description": collection.Description,
+		"auth":        RedactAuth(collection.Auth),
+		"settings":    collection.Settings,
+	}))
+}
+
+// record stores the next revision of a resource. Updates that leave the
+// snapshot unchanged are not recorded and return the latest revision.
+func (s *RevisionService) record(tx *gorm.DB, workspaceID, authorID uuid.UUID, resourceType string, resourceID uuid.UUID, action string, snapshot map[string]interface{}) (*models.Revision, error) {
+	// Lock the resource so concurrent changes take versions one at a time
+	// instead of colliding on the (resource, version) index
+	var locked []uuid.UUID
+	err := tx.Table(revisionTables[resourceType]).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", resourceID).
+		Pluck("id", &locked).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var latest models.Revision
+	err = tx.Where("resource_type = ? AND resource_id = ?", resourceType, resourceID).
+		Order("version DESC").
+		First(&latest).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if err == nil && action == RevisionUpdate {
+		var latestSnapshot, current interface{}
+		json.Unmarshal([]byte(latest.Snapshot), &latestSnapshot)
+		encoded, _ := json.Marshal(snapshot)
+		json.Unmarshal(encoded, &current)
+		if reflect.DeepEqual(latestSnapshot, current) {
+			return &latest, nil
+		}
+	}
+
+	snapshotJSON, _ := json.Marshal(snapshot)
+	revision := models.Revision{
+		WorkspaceID:  workspaceID,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		Version:      latest.Version + 1,
+		Action:       action,
+		Snapshot:     string(snapshotJSON),
+		AuthorID:     authorID,
+	}
+	if err := tx.Create(&revision).Error; err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// snapshotColumns decodes the JSON string columns so snapshots nest them.
+func snapshotColumns(columns map[string]string) map[string]interface{} {
+	snapshot := make(map[string]interface{}, len(columns))
+	for column, value := range columns {
+		snapshot[column] = value
+		if revisionJSONColumns[column] {
+			var decoded interface{}
+			if value == "" || json.Unmarshal([]byte(value), &decoded) == nil {
+				snapshot[column] = decoded
+			}
+		}
+	}
+	return snapshot
+}
+
+// restoreValues turns a snapshot back into column updates. JSON columns
+// snapshotted as null are restored as NULL.
+func restoreValues(snapshot map[string]interface{}, columns []string) map[string]interface{} {
+	values := make(map[string]interface{}, len(columns)+1)
+	for _, column := range columns {
+		value := snapshot[column]
+		switch {
+		case value == nil && revisionJSONColumns[column]:
+			values[column] = nil
+		case value == nil:
+			values[column] = ""
+		case revisionJSONColumns[column]:
+			encoded, _ := json.Marshal(value)
+			values[column] = string(encoded)
+		default:
+			values[column] = value
+		}
+	}
+	return values
+}
+
+// restoreFolderID returns the snapshotted folder of a request if it still
+// exists in the request's collection, otherwise nil for the collection root.
+func restoreFolderID(tx *gorm.DB, collectionID uuid.UUID, value interface{}) *uuid.UUID {
+	raw, ok := value.(string)
+	if !ok {
+		return nil
+	}
+	folderID, err := uuid.Parse(raw)
+	if err != nil {
+		return nil
+	}
+	if err := tx.First(&models.Folder{}, "id = ? AND collection_id = ?", folderID, collectionID).Error; err != nil {
+		return nil
+	}
+	return &folderID
+}
@@ -0,0 +1,276 @@
+package services
+
+import (
+	"backend/models"
+	"database/sql/driver"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// expectRevisionLock mocks the resource row being locked before its next
+// revision is numbered.
+func expectRevisionLock(mock sqlmock.Sqlmock, resourceType string, resourceID driver.Value) {
+	mock.ExpectQuery(`(?i)SELECT "id" FROM "` + revisionTables[resourceType] + `" WHERE id = \$1 FOR UPDATE`).
+		WithArgs(resourceID).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+}
+
+// expectRevisionRecorded mocks the first revision of a resource being stored.
+func expectRevisionRecorded(mock sqlmock.Sqlmock, resourceType string, resourceID driver.Value) {
+	expectRevisionLock(mock, resourceType, resourceID)
+	mock.ExpectQuery(`(?i)SELECT \* FROM "revisions" WHERE resource_type = \$1 AND resource_id = \$2 ORDER BY version DESC`).
+		WithArgs(resourceType, resourceID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "version"}))
+	mock.ExpectQuery(`(?i)INSERT INTO "revisions"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+}
+
+// snapshotArg matches a revision INSERT argument holding the given snapshot.
+type snapshotArg map[string]interface{}
+
+func (a snapshotArg) Match(v driver.Value) bool {
+	raw, ok := v.(string)
+	if !ok {
+		return false
+	}
+	var snapshot map[string]interface{}
+	if json.Unmarshal([]byte(raw), &snapshot) != nil {
+		return false
+	}
+	for key, want := range a {
+		got, _ := json.Marshal(snapshot[key])
+		expected, _ := json.Marshal(want)
+		if string(got) != string(expected) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSnapshotColumns_RoundTrip(t *testing.T) {
+	snapshot := snapshotColumns(map[string]string{
+		"name":    "Get user",
+		"headers": `{"Accept":"application/json"}`,
+		"auth":    "",
+	})
+	assert.Equal(t, map[string]interface{}{"Accept": "application/json"}, snapshot["headers"])
+	assert.Nil(t, snapshot["auth"])
+
+	// Unset JSON columns go back to NULL, not an empty string jsonb rejects
+	values := restoreValues(snapshot, []string{"name", "headers", "auth"})
+	assert.Equal(t, map[string]interface{}{
+		"name":    "Get user",
+		"headers": `{"Accept":"application/json"}`,
+		"auth":    nil,
+	}, values)
+}
+
+func TestRequestService_Update_RecordsRevision(t *testing.T) {
+	db, mock := setupTestDBRequest(t)
+	service := NewRequestService(db, nil)
+
+	requestID := uuid.New()
+	collectionID := uuid.New()
+	workspaceID := uuid.New()
+	userID := uuid.New()
+
+	mock.ExpectQuery(`(?i)SELECT \* FROM "requests"`).
+		WithArgs(requestID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "collection_id", "name", "url", "method", "headers"}).
+			AddRow(requestID, collectionID, "List orders", "http://api.test/orders", "GET", `{"Accept":"*/*"}`))
+	mock.ExpectQuery(`(?i)SELECT \* FROM "collections"`).
+		WithArgs(collectionID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "workspace_id"}).AddRow(collectionID, workspaceID))
+	mock.ExpectQuery(`(?i)SELECT count\(\*\) FROM "workspace_members"`).
+		WithArgs(workspaceID, userID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`(?i)INSERT INTO "collections"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(collectionID))
+	mock.ExpectExec(`(?i)UPDATE "requests"`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectRevisionLock(mock, RevisionResourceRequest, requestID)
+	mock.ExpectQuery(`(?i)SELECT \* FROM "revisions" WHERE resource_type = \$1 AND resource_id = \$2 ORDER BY version DESC`).
+		WithArgs(RevisionResourceRequest, requestID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "version", "snapshot"}).
+			AddRow(uuid.New(), 3, `{"name":"List orders","url":"http://api.test/orders","headers":{"Accept":"*/*"}}`))
+	mock.ExpectQuery(`(?i)INSERT INTO "revisions"`).
+		WithArgs(workspaceID, RevisionResourceRequest, requestID, 4, RevisionUpdate,
+			snapshotArg{"url": "http://api.test/v2/orders", "headers": map[string]interface{}{"Accept": "*/*"}},
+			userID, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectCommit()
+
+	request, err := service.Update(requestID, userID, map[string]interface{}{"url": "http://api.test/v2/orders"})
+	require.NoError(t, err)
+	assert.Equal(t, "http://api.test/v2/orders", request.URL)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRequestService_Update_KeepsRedactedSecrets(t *testing.T) {
+	db, mock := setupTestDBRequest(t)
+	service := NewRequestService(db, nil)
+
+	requestID := uuid.New()
+	collectionID := uuid.New()
+	workspaceID := uuid.New()
+	userID := uuid.New()
+	stored := `{"type":"bearer","bearer":{"token":"abc"}}`
+
+	mock.ExpectQuery(`(?i)SELECT \* FROM "requests"`).
+		WithArgs(requestID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "collection_id", "name", "url", "method", "auth"}).
+			AddRow(requestID, collectionID, "List orders", "http://api.test/orders", "GET", stored))
+	mock.ExpectQuery(`(?i)SELECT \* FROM "collections"`).
+		WithArgs(collectionID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "workspace_id"}).AddRow(collectionID, workspaceID))
+	mock.ExpectQuery(`(?i)SELECT count\(\*\) FROM "workspace_members"`).
+		WithArgs(workspaceID, userID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`(?i)INSERT INTO "collections"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(collectionID))
+	mock.ExpectExec(`(?i)UPDATE "requests" SET "auth"=\$1`).
+		WithArgs(stored, collectionID, "http://api.test/v2/orders", sqlmock.AnyArg(), requestID).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectRevisionLock(mock, RevisionResourceRequest, requestID)
+	mock.ExpectQuery(`(?i)SELECT \* FROM "revisions" WHERE resource_type = \$1 AND resource_id = \$2 ORDER BY version DESC`).
+		WithArgs(RevisionResourceRequest, requestID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "version"}))
+	mock.ExpectQuery(`(?i)INSERT INTO "revisions"`).
+		WithArgs(workspaceID, RevisionResourceRequest, requestID, 1, RevisionUpdate,
+			snapshotArg{"auth": map[string]interface{}{"type": "bearer", "bearer": map[string]interface{}{"token": redactedSecret}}},
+			userID, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectCommit()
+
+	// Clients send back the auth they read, with its secrets redacted
+	_, err := service.Update(requestID, userID, map[string]interface{}{
+		"url":  "http://api.test/v2/orders",
+		"auth": RedactAuth(stored),
+	})
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRevisionService_Diff_AgainstPrevious(t *testing.T) {
+	db, mock := setupTestDBRequest(t)
+	service := NewRevisionService(db)
+
+	workspaceID := uuid.New()
+	userID := uuid.New()
+	requestID := uuid.New()
+	revisionID := uuid.New()
+
+	mock.ExpectQuery(`(?i)SELECT count\(\*\) FROM "workspace_members"`).
+		WithArgs(workspaceID, userID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(`(?i)SELECT \* FROM "revisions" WHERE id = \$1 AND workspace_id = \$2`).
+		WithArgs(revisionID, workspaceID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "workspace_id", "resource_type", "resource_id", "version", "snapshot", "author_id"}).
+			AddRow(revisionID, workspaceID, RevisionResourceRequest, requestID, 2,
+				`{"name":"Create order","method":"POST","headers":{"Authorization":"Bearer new"}}`, userID))
+	mock.ExpectQuery(`(?i)SELECT \* FROM "users"`).
+		WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(userID, "Ann"))
+	mock.ExpectQuery(`(?i)SELECT \* FROM "revisions" WHERE resource_type = \$1 AND resource_id = \$2 AND version < \$3 ORDER BY version DESC`).
+		WithArgs(RevisionResourceRequest, requestID, 2, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "version", "snapshot"}).
+			AddRow(uuid.New(), 1, `{"name":"Create order","method":"PUT","headers":{"Authorization":"Bearer old","X-Debug":"1"}}`))
+
+	diff, err := service.Diff(workspaceID, userID, revisionID, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, diff.FromVersion)
+	assert.Equal(t, 2, diff.ToVersion)
+	assert.Equal(t, []BodyChange{
+		{Path: "headers.Authorization", Type: ChangeChanged, Base: "Bearer old", Target: "Bearer new"},
+		{Path: "headers.X-Debug", Type: ChangeRemoved, Base: "1"},
+		{Path: "method", Type: ChangeChanged, Base: "PUT", Target: "POST"},
+	}, diff.Changes)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRevisionService_Restore_DeletedRequest(t *testing.T) {
+	db, mock := setupTestDBRequest(t)
+	service := NewRevisionService(db)
+
+	workspaceID := uuid.New()
+	userID := uuid.New()
+	requestID := uuid.New()
+	collectionID := uuid.New()
+	revisionID := uuid.New()
+	folderID := uuid.New()
+
+	mock.ExpectQuery(`(?i)SELECT count\(\*\) FROM "workspace_members"`).
+		WithArgs(workspaceID, userID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(`(?i)SELECT \* FROM "revisions" WHERE id = \$1 AND workspace_id = \$2`).
+		WithArgs(revisionID, workspaceID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "workspace_id", "resource_type", "resource_id", "version", "snapshot"}).
+			AddRow(revisionID, workspaceID, RevisionResourceRequest, requestID, 1,
+				`{"name":"Health","method":"GET","url":"http://api.test/health","headers":{"Accept":"*/*"},"auth":null,"folder_id":"`+folderID.String()+`"}`))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`(?i)SELECT \* FROM "requests" WHERE id = \$1 ORDER BY`).
+		WithArgs(requestID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "collection_id", "name", "method", "url", "deleted_at"}).
+			AddRow(requestID, collectionID, "Broken", "POST", "http://api.test/oops", time.Now()))
+	mock.ExpectQuery(`(?i)SELECT \* FROM "collections" WHERE id = \$1 AND "collections"."deleted_at" IS NULL`).
+		WithArgs(collectionID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "workspace_id"}).AddRow(collectionID, workspaceID))
+	mock.ExpectQuery(`(?i)SELECT \* FROM "folders" WHERE \(id = \$1 AND collection_id = \$2\)`).
+		WithArgs(folderID, collectionID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "collection_id"}).AddRow(folderID, collectionID))
+	// Unset auth is restored as NULL and the request goes back into its folder
+	mock.ExpectExec(`(?i)UPDATE "requests" SET "auth"=\$1,.*"deleted_at"=\$\d.*"folder_id"=\$\d.* WHERE "id" = \$\d+$`).
+		WithArgs(nil, nil, "", nil, "", &folderID, nil, nil, `{"Accept":"*/*"}`, "", "GET", "Health", nil, nil, nil, "http://api.test/health", sqlmock.AnyArg(), requestID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`(?i)UPDATE "collections" SET "request_count"=request_count \+ \$1`).
+		WithArgs(1, sqlmock.AnyArg(), collectionID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectRevisionLock(mock, RevisionResourceRequest, requestID)
+	mock.ExpectQuery(`(?i)SELECT \* FROM "revisions" WHERE resource_type = \$1 AND resource_id = \$2 ORDER BY version DESC`).
+		WithArgs(RevisionResourceRequest, requestID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "version"}).AddRow(uuid.New(), 2))
+	mock.ExpectQuery(`(?i)INSERT INTO "revisions"`).
+		WithArgs(workspaceID, RevisionResourceRequest, requestID, 3, RevisionRestore,
+			snapshotArg{"name": "Health", "url": "http://api.test/health", "headers": map[string]interface{}{"Accept": "*/*"}},
+			userID, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectCommit()
+
+	restored, err := service.Restore(workspaceID, userID, revisionID)
+	require.NoError(t, err)
+	assert.Equal(t, 3, restored.Version)
+	assert.Equal(t, RevisionRestore, restored.Action)
+	assert.Empty(t, restored.UnrestoredSecrets, "the revision had no auth")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRevisionService_RecordSkipsUnchangedUpdate(t *testing.T) {
+	db, mock := setupTestDBRequest(t)
+	service := NewRevisionService(db)
+
+	collection := &models.Collection{ID: uuid.New(), WorkspaceID: uuid.New(), Name: "Orders"}
+	latestID := uuid.New()
+
+	expectRevisionLock(mock, RevisionResourceCollection, collection.ID)
+	mock.ExpectQuery(`(?i)SELECT \* FROM "revisions"`).
+		WithArgs(RevisionResourceCollection, collection.ID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "version", "snapshot"}).
+			AddRow(latestID, 5, `{"name":"Orders","description":"","auth":null,"settings":null}`))
+
+	revision, err := service.recordCollection(db, uuid.New(), collection, RevisionUpdate)
+	require.NoError(t, err)
+	assert.Equal(t, latestID, revision.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
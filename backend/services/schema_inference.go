@@ -0,0 +1,498 @@
+package services
+
+import (
+	"encoding/json"
+	"math"
+	"net"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	uuidPattern  = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	emailPattern = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+)
+
+// stringFormat returns the JSON Schema format a string has, or "".
+func stringFormat(value string) string {
+	switch {
+	case uuidPattern.MatchString(value):
+		return "uuid"
+	case isTime(time.RFC3339, value):
+		return "date-time"
+	case isTime("2006-01-02", value):
+		return "date"
+	case emailPattern.MatchString(value):
+		return "email"
+	}
+	if parsed, err := url.Parse(value); err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != "" {
+		return "uri"
+	}
+	if ip := net.ParseIP(value); ip != nil && ip.To4() != nil && strings.Count(value, ".") == 3 {
+		return "ipv4"
+	}
+	return ""
+}
+
+func isTime(layout, value string) bool {
+	_, err := time.Parse(layout, value)
+	return err == nil
+}
+
+// jsonValueType is the JSON Schema type of a decoded JSON value, telling
+// integers from other numbers.
+func jsonValueType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) && !math.IsInf(v, 0) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return ""
+}
+
+// inferJSONSchema describes a decoded JSON value as a JSON Schema. Every
+// property of an object sample is required; merging further samples with
+// mergeJSONSchemas relaxes that to the properties present in all of them.
+func inferJSONSchema(value interface{}) map[string]interface{} {
+	switch v := value.(type) {
+	case nil, bool, float64:
+		return map[string]interface{}{"type": jsonValueType(v)}
+	case string:
+		schema := map[string]interface{}{"type": "string"}
+		if format := stringFormat(v); format != "" {
+			schema["format"] = format
+		}
+		return schema
+	case []interface{}:
+		var items map[string]interface{}
+		for _, item := range v {
+			items = mergeJSONSchemas(items, inferJSONSchema(item))
+		}
+		schema := map[string]interface{}{"type": "array"}
+		if items != nil {
+			schema["items"] = items
+		}
+		return schema
+	case map[string]interface{}:
+		properties := make(map[string]interface{}, len(v))
+		required := make([]interface{}, 0, len(v))
+		for _, key := range sortedKeys(v) {
+			properties[key] = inferJSONSchema(v[key])
+			required = append(required, key)
+		}
+		schema := map[string]interface{}{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	}
+	return map[string]interface{}{}
+}
+
+// mergeJSONSchemas widens a to also describe the values b describes. Either
+// may be nil. Integers widen to numbers, differing types become a type list
+// and object properties missing from either side stop being required.
+func mergeJSONSchemas(a, b map[string]interface{}) map[string]interface{} {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+
+	typesA, typesB := schemaTypes(a), schemaTypes(b)
+	if len(typesA) == 0 || len(typesB) == 0 {
+		return map[string]interface{}{}
+	}
+	types := map[string]bool{}
+	for _, t := range append(typesA, typesB...) {
+		types[t] = true
+	}
+	if types["number"] && types["integer"] {
+		delete(types, "integer")
+	}
+
+	merged := map[string]interface{}{}
+	for t := range types {
+		switch t {
+		case "object":
+			mergeObjectSchemas(merged, a, b)
+		case "array":
+			itemsA, _ := a["items"].(map[string]interface{})
+			itemsB, _ := b["items"].(map[string]interface{})
+			if items := mergeJSONSchemas(itemsA, itemsB); items != nil {
+				merged["items"] = items
+			}
+		case "string":
+			if a["format"] != nil && a["format"] == b["format"] {
+				merged["format"] = a["format"]
+			} else if b["format"] != nil && !containsString(typesA, "string") {
+				merged["format"] = b["format"]
+			} else if a["format"] != nil && !containsString(typesB, "string") {
+				merged["format"] = a["format"]
+			}
+		}
+	}
+
+	names := make([]string, 0, len(types))
+	for t := range types {
+		names = append(names, t)
+	}
+	sort.Strings(names)
+	if len(names) == 1 {
+		merged["type"] = names[0]
+	} else {
+		list := make([]interface{}, len(names))
+		for i, name := range names {
+			list[i] = name
+		}
+		merged["type"] = list
+	}
+	return merged
+}
+
+func mergeObjectSchemas(merged, a, b map[string]interface{}) {
+	propertiesA, _ := a["properties"].(map[string]interface{})
+	propertiesB, _ := b["properties"].(map[string]interface{})
+	properties := map[string]interface{}{}
+	for key, value := range propertiesA {
+		properties[key] = value
+	}
+	for key, value := range propertiesB {
+		existing, _ := properties[key].(map[string]interface{})
+		schema, _ := value.(map[string]interface{})
+		properties[key] = mergeJSONSchemas(existing, schema)
+	}
+	merged["properties"] = properties
+
+	// Only objects on both sides constrain required properties
+	requiredA, objectA := requiredSet(a), containsString(schemaTypes(a), "object")
+	requiredB, objectB := requiredSet(b), containsString(schemaTypes(b), "object")
+	var required []interface{}
+	for _, key := range sortedKeys(properties) {
+		if (!objectA || requiredA[key]) && (!objectB || requiredB[key]) {
+			required = append(required, key)
+		}
+	}
+	if len(required) > 0 {
+		merged["required"] = required
+	}
+}
+
+// schemaTypes lists the types a schema allows, or nil when it allows any.
+func schemaTypes(schema map[string]interface{}) []string {
+	switch t := schema["type"].(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		var types []string
+		for _, entry := range t {
+			if name, ok := entry.(string); ok {
+				types = append(types, name)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+func requiredSet(schema map[string]interface{}) map[string]bool {
+	set := map[string]bool{}
+	required, _ := schema["required"].([]interface{})
+	for _, entry := range required {
+		if key, ok := entry.(string); ok {
+			set[key] = true
+		}
+	}
+	return set
+}
+
+// Limits on what a schemaLearner keeps and when it reports an enum.
+const (
+	maxLearnedEnumValues  = 10  // more distinct strings than this are not an enum
+	maxLearnedEnumLength  = 64  // longer strings are never enum values
+	minLearnedEnumSamples = 10  // strings seen before an enum is reported
+	maxLearnedProperties  = 200 // objects with more keys are treated as maps
+)
+
+// schemaLearner learns a JSON Schema from many JSON samples. Unlike
+// mergeJSONSchemas it keeps counts, so it can tell optional fields from
+// required ones and spot enums, and it can be stored and resumed.
+type schemaLearner struct {
+	Samples int         `json:"samples"`
+	Root    *schemaNode `json:"root"`
+}
+
+// schemaNode counts what was seen at one place in the samples.
+type schemaNode struct {
+	Types      map[string]int         `json:"types"`                 // values seen per JSON type
+	Formats    map[string]int         `json:"formats,omitempty"`     // strings seen per format, "" for none
+	Values     map[string]int         `json:"values,omitempty"`      // distinct strings, until there are too many
+	ManyValues bool                   `json:"many_values,omitempty"` // Values was dropped
+	Properties map[string]*schemaNode `json:"properties,omitempty"`
+	Map        bool                   `json:"map,omitempty"` // too many keys to list as properties
+	Items      *schemaNode            `json:"items,omitempty"`
+}
+
+func newSchemaNode() *schemaNode {
+	return &schemaNode{Types: map[string]int{}}
+}
+
+// observe adds a decoded JSON sample.
+func (l *schemaLearner) observe(value interface{}) {
+	if l.Root == nil {
+		l.Root = newSchemaNode()
+	}
+	l.Samples++
+	l.Root.observe(value)
+}
+
+// schema returns the schema learned so far, nil before any sample.
+func (l *schemaLearner) schema() map[string]interface{} {
+	if l.Root == nil {
+		return nil
+	}
+	return l.Root.schema()
+}
+
+func (n *schemaNode) observe(value interface{}) {
+	n.Types[jsonValueType(value)]++
+	switch v := value.(type) {
+	case string:
+		if n.Formats == nil {
+			n.Formats = map[string]int{}
+		}
+		n.Formats[stringFormat(v)]++
+		if !n.ManyValues {
+			if n.Values == nil {
+				n.Values = map[string]int{}
+			}
+			n.Values[v]++
+			if len(n.Values) > maxLearnedEnumValues || len(v) > maxLearnedEnumLength {
+				n.Values, n.ManyValues = nil, true
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if n.Items == nil {
+				n.Items = newSchemaNode()
+			}
+			n.Items.observe(item)
+		}
+	case map[string]interface{}:
+		if n.Properties == nil {
+			n.Properties = map[string]*schemaNode{}
+		}
+		for key, item := range v {
+			child := n.Properties[key]
+			if child == nil {
+				if len(n.Properties) >= maxLearnedProperties {
+					n.Map = true
+					continue
+				}
+				child = newSchemaNode()
+				n.Properties[key] = child
+			}
+			child.observe(item)
+		}
+	}
+}
+
+// schema describes the counts: a field is required when every object had
+// it, a format when every string had it, and an enum when enough strings
+// were seen and they kept repeating a few values.
+func (n *schemaNode) schema() map[string]interface{} {
+	types := make([]string, 0, len(n.Types))
+	for t := range n.Types {
+		if t == "integer" && n.Types["number"] > 0 {
+			continue
+		}
+		types = append(types, t)
+	}
+	sort.Strings(types)
+
+	schema := map[string]interface{}{}
+	switch len(types) {
+	case 0:
+		return schema
+	case 1:
+		schema["type"] = types[0]
+	default:
+		list := make([]interface{}, len(types))
+		for i, t := range types {
+			list[i] = t
+		}
+		schema["type"] = list
+	}
+
+	if seen := n.Types["string"]; seen > 0 {
+		for format, count := range n.Formats {
+			if format != "" && count == seen {
+				schema["format"] = format
+			}
+		}
+		if !n.ManyValues && seen >= minLearnedEnumSamples && len(n.Values)*2 <= seen {
+			enum := make([]interface{}, 0, len(n.Values)+1)
+			for _, value := range sortedCountKeys(n.Values) {
+				enum = append(enum, value)
+			}
+			if n.Types["null"] > 0 {
+				enum = append(enum, nil)
+			}
+			schema["enum"] = enum
+		}
+	}
+
+	if objects := n.Types["object"]; objects > 0 && !n.Map {
+		properties := map[string]interface{}{}
+		var required []interface{}
+		for _, key := range sortedNodeKeys(n.Properties) {
+			child := n.Properties[key]
+			properties[key] = child.schema()
+			if child.total() == objects {
+				required = append(required, key)
+			}
+		}
+		schema["properties"] = properties
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+	}
+
+	if n.Types["array"] > 0 && n.Items != nil {
+		schema["items"] = n.Items.schema()
+	}
+	return schema
+}
+
+// total is how many values were seen at the node.
+func (n *schemaNode) total() int {
+	total := 0
+	for _, count := range n.Types {
+		total += count
+	}
+	return total
+}
+
+func sortedCountKeys(counts map[string]int) []string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedNodeKeys(nodes map[string]*schemaNode) []string {
+	keys := make([]string, 0, len(nodes))
+	for key := range nodes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Kinds of SchemaDriftChange.
+const (
+	DriftTypeChanged   = "type_changed"
+	DriftFieldAdded    = "field_added"
+	DriftFieldMissing  = "field_missing"
+	DriftEnumValue     = "enum_value"
+	DriftFormatChanged = "format_changed"
+)
+
+// SchemaDriftChange is one way a body's shape differs from a learned schema.
+type SchemaDriftChange struct {
+	Kind     string      `json:"kind"`
+	Path     string      `json:"path"` // JSON pointer into the body
+	Expected interface{} `json:"expected,omitempty"`
+	Actual   interface{} `json:"actual,omitempty"`
+}
+
+// schemaDrift lists how a decoded body differs from a learned schema.
+// Objects learned as maps accept any keys.
+func schemaDrift(schema map[string]interface{}, value interface{}) []SchemaDriftChange {
+	var changes []SchemaDriftChange
+	collectSchemaDrift(schema, value, nil, &changes)
+	return changes
+}
+
+func collectSchemaDrift(schema map[string]interface{}, value interface{}, tokens []string, changes *[]SchemaDriftChange) {
+	types := schemaTypes(schema)
+	if len(types) == 0 {
+		return
+	}
+	actual := jsonValueType(value)
+	if !containsString(types, actual) && !(actual == "integer" && containsString(types, "number")) {
+		*changes = append(*changes, SchemaDriftChange{Kind: DriftTypeChanged, Path: jsonPointer(tokens), Expected: schema["type"], Actual: actual})
+		return
+	}
+
+	switch v := value.(type) {
+	case string:
+		if enum, ok := schema["enum"].([]interface{}); ok && !containsValue(enum, v) {
+			*changes = append(*changes, SchemaDriftChange{Kind: DriftEnumValue, Path: jsonPointer(tokens), Expected: enum, Actual: v})
+		} else if format, ok := schema["format"].(string); ok && stringFormat(v) != format {
+			*changes = append(*changes, SchemaDriftChange{Kind: DriftFormatChanged, Path: jsonPointer(tokens), Expected: format, Actual: v})
+		}
+	case []interface{}:
+		items, _ := schema["items"].(map[string]interface{})
+		if items == nil {
+			return
+		}
+		for i, item := range v {
+			collectSchemaDrift(items, item, appendPath(tokens, strconv.Itoa(i)), changes)
+		}
+	case map[string]interface{}:
+		properties, ok := schema["properties"].(map[string]interface{})
+		if !ok {
+			return
+		}
+		required, _ := schema["required"].([]interface{})
+		for _, entry := range required {
+			key, _ := entry.(string)
+			if _, ok := v[key]; !ok {
+				*changes = append(*changes, SchemaDriftChange{Kind: DriftFieldMissing, Path: jsonPointer(appendPath(tokens, key))})
+			}
+		}
+		for _, key := range sortedKeys(v) {
+			if property, ok := properties[key].(map[string]interface{}); ok {
+				collectSchemaDrift(property, v[key], appendPath(tokens, key), changes)
+			} else {
+				*changes = append(*changes, SchemaDriftChange{Kind: DriftFieldAdded, Path: jsonPointer(appendPath(tokens, key)), Actual: jsonValueType(v[key])})
+			}
+		}
+	}
+}
+
+func containsValue(values []interface{}, value interface{}) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
+
+// schemaJSON encodes a schema with sorted keys, so equal schemas encode
+// equally.
+func schemaJSON(schema map[string]interface{}) string {
+	encoded, _ := json.Marshal(schema)
+	return string(encoded)
+}
@@ -0,0 +1,461 @@
+package services
+
+import (
+	"backend/models"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// minTrainedSamples is how many bodies an endpoint's schema learns from
+// before new bodies are checked for drift. Until then the first version is
+// refined in place.
+const minTrainedSamples = 20
+
+// maxLearnSamples bounds how many executions, and how many spans, a
+// relearn reads.
+const maxLearnSamples = 5000
+
+// SchemaInferenceService learns a JSON Schema per endpoint from observed
+// response bodies, versions it as it changes and records bodies that drift
+// from it.
+type SchemaInferenceService struct {
+	db               *gorm.DB
+	workspaceService *WorkspaceService
+}
+
+func NewSchemaInferenceService(db *gorm.DB) *SchemaInferenceService {
+	return &SchemaInferenceService{
+		db:               db,
+		workspaceService: NewWorkspaceService(db),
+	}
+}
+
+// schemaSample is a JSON response body observed for an endpoint.
+type schemaSample struct {
+	method, pathTemplate string
+	status               int
+	body                 interface{}
+	source               string
+	executionID          *uuid.UUID
+	traceID, spanID      *uuid.UUID
+}
+
+func (s schemaSample) endpoint() string {
+	return s.method + " " + s.pathTemplate + " " + http.StatusText(s.status)
+}
+
+// executionSample reads the JSON response body of an execution of request.
+// The endpoint is named by the request's URL, so {{variables}} in it
+// become path parameters.
+func executionSample(request *models.Request, execution *models.Execution) (schemaSample, bool) {
+	if execution.StatusCode == 0 || !exportable(request) {
+		return schemaSample{}, false
+	}
+	var headers http.Header
+	json.Unmarshal([]byte(execution.ResponseHeaders), &headers)
+	body, ok := jsonBody(headers, execution.ResponseBody)
+	if !ok {
+		return schemaSample{}, false
+	}
+	_, template, _ := openAPIExportPath(request.URL)
+	sample := schemaSample{
+		method:       strings.ToUpper(request.Method),
+		pathTemplate: template,
+		status:       execution.StatusCode,
+		body:         body,
+		source:       ViolationSourceExecution,
+		executionID:  &execution.ID,
+		spanID:       execution.SpanID,
+	}
+	if execution.TraceID != uuid.Nil {
+		sample.traceID = &execution.TraceID
+	}
+	return sample, true
+}
+
+// spanSample reads the JSON response body a span carries in its
+// http.response.body tag.
+func spanSample(span *models.Span) (schemaSample, bool) {
+	call, ok := spanCall(span)
+	if !ok || call.status == 0 {
+		return schemaSample{}, false
+	}
+	body, ok := jsonBody(nil, call.responseBody)
+	if !ok {
+		return schemaSample{}, false
+	}
+	_, template, _ := openAPIExportPath(call.url)
+	return schemaSample{
+		method:       call.method,
+		pathTemplate: template,
+		status:       call.status,
+		body:         body,
+		source:       ViolationSourceSpan,
+		traceID:      &span.TraceID,
+		spanID:       &span.ID,
+	}, true
+}
+
+// jsonBody decodes a body that is JSON by its content type, or when there
+// are no headers, by its content.
+func jsonBody(headers http.Header, body string) (interface{}, bool) {
+	if body == "" || !isJSONMediaType(bodyMediaType(headers, body)) {
+		return nil, false
+	}
+	var value interface{}
+	if err := json.Unmarshal([]byte(body), &value); err != nil {
+		return nil, false
+	}
+	return value, true
+}
+
+// ObserveExecution learns from an execution's JSON response body, and
+// records a drift when the body does not fit the endpoint's schema.
+func (s *SchemaInferenceService) ObserveExecution(workspaceID uuid.UUID, request *models.Request, execution *models.Execution) error {
+	sample, ok := executionSample(request, execution)
+	if !ok {
+		return nil
+	}
+	return s.observe(workspaceID, []schemaSample{sample})
+}
+
+// ObserveSpans learns from the JSON response bodies ingested spans carry.
+func (s *SchemaInferenceService) ObserveSpans(workspaceID uuid.UUID, spans []models.Span) error {
+	var samples []schemaSample
+	for i := range spans {
+		if sample, ok := spanSample(&spans[i]); ok {
+			samples = append(samples, sample)
+		}
+	}
+	if len(samples) == 0 {
+		return nil
+	}
+	return s.observe(workspaceID, samples)
+}
+
+// learnedEndpoint is an endpoint's latest schema version and its learner.
+type learnedEndpoint struct {
+	row     *models.InferredSchema
+	learner *schemaLearner
+}
+
+// observe learns from the samples one endpoint at a time, each in a
+// transaction holding the endpoint's versions locked.
+func (s *SchemaInferenceService) observe(workspaceID uuid.UUID, samples []schemaSample) error {
+	endpoints := map[string][]schemaSample{}
+	var order []string
+	for _, sample := range samples {
+		key := sample.endpoint()
+		if endpoints[key] == nil {
+			order = append(order, key)
+		}
+		endpoints[key] = append(endpoints[key], sample)
+	}
+
+	for _, key := range order {
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			return s.observeEndpoint(tx, workspaceID, endpoints[key])
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// observeEndpoint learns from samples of a single endpoint.
+func (s *SchemaInferenceService) observeEndpoint(tx *gorm.DB, workspaceID uuid.UUID, samples []schemaSample) error {
+	endpoint, err := s.latest(tx, workspaceID, samples[0])
+	if err != nil {
+		return err
+	}
+
+	for _, sample := range samples {
+		trained := endpoint.row
+		drift, created := endpoint.learn(workspaceID, sample)
+		if len(drift) > 0 {
+			changes, _ := json.Marshal(drift)
+			err := tx.Create(&models.SchemaDrift{
+				WorkspaceID:      workspaceID,
+				InferredSchemaID: trained.ID,
+				Version:          trained.Version,
+				Source:           sample.source,
+				ExecutionID:      sample.executionID,
+				TraceID:          sample.traceID,
+				SpanID:           sample.spanID,
+				Method:           sample.method,
+				PathTemplate:     sample.pathTemplate,
+				StatusCode:       sample.status,
+				Changes:          string(changes),
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+		if created {
+			if err := tx.Create(endpoint.row).Error; err != nil {
+				return err
+			}
+		} else if err := tx.Save(endpoint.row).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// latest loads an endpoint's latest schema version, if it has one. It
+// locks the endpoint's versions first, so tx learns from the latest one
+// until it commits. Two first versions of an endpoint collide on the
+// version index instead.
+func (s *SchemaInferenceService) latest(tx *gorm.DB, workspaceID uuid.UUID, sample schemaSample) (*learnedEndpoint, error) {
+	var locked []uuid.UUID
+	err := tx.Model(&models.InferredSchema{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("workspace_id = ? AND method = ? AND path_template = ? AND status_code = ?",
+			workspaceID, sample.method, sample.pathTemplate, sample.status).
+		Pluck("id", &locked).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var row models.InferredSchema
+	err = tx.Where("workspace_id = ? AND method = ? AND path_template = ? AND status_code = ?",
+		workspaceID, sample.method, sample.pathTemplate, sample.status).
+		Order("version DESC").First(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &learnedEndpoint{learner: &schemaLearner{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	learner := &schemaLearner{}
+	if row.Stats != "" {
+		json.Unmarshal([]byte(row.Stats), learner)
+	}
+	return &learnedEndpoint{row: &row, learner: learner}, nil
+}
+
+// learn checks a sample against the endpoint's trained schema, then learns
+// from it. A changed schema becomes a new version once trained; created
+// reports that row is a new version to insert rather than save.
+func (e *learnedEndpoint) learn(workspaceID uuid.UUID, sample schemaSample) (drift []SchemaDriftChange, created bool) {
+	if e.row != nil && e.row.SampleCount >= minTrainedSamples {
+		var schema map[string]interface{}
+		json.Unmarshal([]byte(e.row.Schema), &schema)
+		drift = schemaDrift(schema, sample.body)
+	}
+
+	e.learner.observe(sample.body)
+	learned := schemaJSON(e.learner.schema())
+	stats, _ := json.Marshal(e.learner)
+
+	if e.row == nil || (e.row.SampleCount >= minTrainedSamples && learned != e.row.Schema) {
+		version := 1
+		if e.row != nil {
+			version = e.row.Version + 1
+		}
+		e.row = &models.InferredSchema{
+			WorkspaceID:  workspaceID,
+			Method:       sample.method,
+			PathTemplate: sample.pathTemplate,
+			StatusCode:   sample.status,
+			Version:      version,
+		}
+		created = true
+	}
+	e.row.Schema = learned
+	e.row.Stats = string(stats)
+	e.row.SampleCount = e.learner.Samples
+	return drift, created
+}
+
+// Learn relearns every endpoint's schema from the executions and spans
+// since the given time, storing a new version where the result differs
+// from the latest one. Past bodies are not checked for drift.
+func (s *SchemaInferenceService) Learn(workspaceID, userID uuid.UUID, since time.Time) ([]models.InferredSchema, error) {
+	if !s.workspaceService.HasAccess(workspaceID, userID) {
+		return nil, errors.New("access denied")
+	}
+
+	var executions []models.Execution
+	err := s.db.Preload("Request").
+		Joins("JOIN requests ON requests.id = executions.request_id").
+		Joins("JOIN collections ON collections.id = requests.collection_id").
+		Where("collections.workspace_id = ? AND executions.timestamp >= ? AND executions.status_code > 0", workspaceID, since).
+		Order("executions.timestamp").Limit(maxLearnSamples).
+		Find(&executions).Error
+	if err != nil {
+		return nil, err
+	}
+	var spans []models.Span
+	err = s.db.Joins("JOIN traces ON traces.id = spans.trace_id").
+		Where("traces.workspace_id = ? AND spans.start_time >= ?", workspaceID, since).
+		Where("spans.tags ->> 'http.response.body' IS NOT NULL").
+		Order("spans.start_time").Limit(maxLearnSamples).
+		Find(&spans).Error
+	if err != nil {
+		return nil, err
+	}
+
+	learners := map[string]*schemaLearner{}
+	firsts := map[string]schemaSample{}
+	var order []string
+	add := func(sample schemaSample) {
+		key := sample.endpoint()
+		if learners[key] == nil {
+			learners[key] = &schemaLearner{}
+			firsts[key] = sample
+			order = append(order, key)
+		}
+		learners[key].observe(sample.body)
+	}
+	for i := range executions {
+		if sample, ok := executionSample(&executions[i].Request, &executions[i]); ok {
+			add(sample)
+		}
+	}
+	for i := range spans {
+		if sample, ok := spanSample(&spans[i]); ok {
+			add(sample)
+		}
+	}
+
+	learned := []models.InferredSchema{}
+	for _, key := range order {
+		sample, learner := firsts[key], learners[key]
+		var row models.InferredSchema
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			endpoint, err := s.latest(tx, workspaceID, sample)
+			if err != nil {
+				return err
+			}
+			schema := schemaJSON(learner.schema())
+			if endpoint.row != nil && endpoint.row.Schema == schema {
+				row = *endpoint.row
+				return nil
+			}
+
+			stats, _ := json.Marshal(learner)
+			row = models.InferredSchema{
+				WorkspaceID:  workspaceID,
+				Method:       sample.method,
+				PathTemplate: sample.pathTemplate,
+				StatusCode:   sample.status,
+				Version:      1,
+				Schema:       schema,
+				Stats:        string(stats),
+				SampleCount:  learner.Samples,
+			}
+			if endpoint.row != nil {
+				row.Version = endpoint.row.Version + 1
+			}
+			return tx.Create(&row).Error
+		})
+		if err != nil {
+			return nil, err
+		}
+		learned = append(learned, row)
+	}
+	return learned, nil
+}
+
+// List returns the latest schema version of each endpoint, optionally only
+// those of a method or path template.
+func (s *SchemaInferenceService) List(workspaceID, userID uuid.UUID, method, pathTemplate string) ([]models.InferredSchema, error) {
+	if !s.workspaceService.HasAccess(workspaceID, userID) {
+		return nil, errors.New("access denied")
+	}
+	query := s.db.Where("workspace_id = ?", workspaceID).
+		Where(`version = (SELECT MAX(v.version) FROM inferred_schemas v WHERE v.workspace_id = inferred_schemas.workspace_id
+			AND v.method = inferred_schemas.method AND v.path_template = inferred_schemas.path_template
+			AND v.status_code = inferred_schemas.status_code)`)
+	if method != "" {
+		query = query.Where("method = ?", strings.ToUpper(method))
+	}
+	if pathTemplate != "" {
+		query = query.Where("path_template = ?", pathTemplate)
+	}
+	var schemas []models.InferredSchema
+	err := query.Order("path_template, method, status_code").Find(&schemas).Error
+	return schemas, err
+}
+
+func (s *SchemaInferenceService) GetByID(workspaceID, schemaID, userID uuid.UUID) (*models.InferredSchema, error) {
+	if !s.workspaceService.HasAccess(workspaceID, userID) {
+		return nil, errors.New("access denied")
+	}
+	var schema models.InferredSchema
+	if err := s.db.Where("id = ? AND workspace_id = ?", schemaID, workspaceID).First(&schema).Error; err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
+
+// Versions returns every version of a schema's endpoint, newest first.
+func (s *SchemaInferenceService) Versions(workspaceID, schemaID, userID uuid.UUID) ([]models.InferredSchema, error) {
+	schema, err := s.GetByID(workspaceID, schemaID, userID)
+	if err != nil {
+		return nil, err
+	}
+	var versions []models.InferredSchema
+	err = s.db.Where("workspace_id = ? AND method = ? AND path_template = ? AND status_code = ?",
+		workspaceID, schema.Method, schema.PathTemplate, schema.StatusCode).
+		Order("version DESC").Find(&versions).Error
+	return versions, err
+}
+
+// SchemaDriftFilter narrows a drift listing. Zero values do not filter.
+type SchemaDriftFilter struct {
+	SchemaID     *uuid.UUID // any version of this schema's endpoint
+	Method       string
+	PathTemplate string
+	Since        time.Time
+	Until        time.Time
+	Limit        int
+	Offset       int
+}
+
+// ListDrifts returns a workspace's drifts, newest first, and how many match
+// the filter in total.
+func (s *SchemaInferenceService) ListDrifts(workspaceID, userID uuid.UUID, filter SchemaDriftFilter) ([]models.SchemaDrift, int64, error) {
+	if filter.SchemaID != nil {
+		schema, err := s.GetByID(workspaceID, *filter.SchemaID, userID)
+		if err != nil {
+			return nil, 0, err
+		}
+		filter.Method, filter.PathTemplate = schema.Method, schema.PathTemplate
+	} else if !s.workspaceService.HasAccess(workspaceID, userID) {
+		return nil, 0, errors.New("access denied")
+	}
+
+	query := s.db.Model(&models.SchemaDrift{}).Where("workspace_id = ?", workspaceID)
+	if filter.Method != "" {
+		query = query.Where("method = ?", strings.ToUpper(filter.Method))
+	}
+	if filter.PathTemplate != "" {
+		query = query.Where("path_template = ?", filter.PathTemplate)
+	}
+	if !filter.Since.IsZero() {
+		query = query.Where("created_at >= ?", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		query = query.Where("created_at < ?", filter.Until)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if filter.Limit <= 0 || filter.Limit > 200 {
+		filter.Limit = 50
+	}
+	var drifts []models.SchemaDrift
+	err := query.Order("created_at DESC").Limit(filter.Limit).Offset(filter.Offset).Find(&drifts).Error
+	return drifts, total, err
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"backend/models"
+	"fmt"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLearnedEndpoint_Versions(t *testing.T) {
+	workspaceID := uuid.New()
+	endpoint := &learnedEndpoint{learner: &schemaLearner{}}
+	sample := func(body string) schemaSample {
+		return schemaSample{method: "GET", pathTemplate: "/orders/{orderId}", status: 200, body: decodeSample(t, body)}
+	}
+
+	drift, created := endpoint.learn(workspaceID, sample(`{"id": 1}`))
+	assert.Empty(t, drift)
+	assert.True(t, created, "the first sample creates version 1")
+	assert.Equal(t, 1, endpoint.row.Version)
+
+	// Until trained, the first version is refined in place and nothing drifts
+	for i := 2; i <= minTrainedSamples; i++ {
+		body := fmt.Sprintf(`{"id": %d}`, i)
+		if i == 2 {
+			body = `{"id": 2, "note": "gift"}`
+		}
+		drift, created = endpoint.learn(workspaceID, sample(body))
+		assert.Empty(t, drift)
+		assert.False(t, created)
+	}
+	assert.Equal(t, 1, endpoint.row.Version)
+	assert.Equal(t, minTrainedSamples, endpoint.row.SampleCount)
+	assert.JSONEq(t, `{"type": "object", "properties": {"id": {"type": "integer"}, "note": {"type": "string"}}, "required": ["id"]}`, endpoint.row.Schema)
+
+	// A trained schema that a body fits stays the same version
+	endpoint.row.ID = uuid.New()
+	drift, created = endpoint.learn(workspaceID, sample(`{"id": 21}`))
+	assert.Empty(t, drift)
+	assert.False(t, created)
+
+	// A body that drifts is reported, and the schema that learns it is a new version
+	drift, created = endpoint.learn(workspaceID, sample(`{"id": "22"}`))
+	assert.Equal(t, []SchemaDriftChange{{Kind: DriftTypeChanged, Path: "/id", Expected: "integer", Actual: "string"}}, drift)
+	assert.True(t, created)
+	assert.Equal(t, 2, endpoint.row.Version)
+	assert.Equal(t, minTrainedSamples+2, endpoint.row.SampleCount)
+	assert.Contains(t, endpoint.row.Schema, `"type":["integer","string"]`)
+}
+
+func TestExecutionSample(t *testing.T) {
+	request := &models.Request{Method: "get", URL: "{{base}}/orders/42?expand=items"}
+	execution := &models.Execution{
+		ID:              uuid.New(),
+		StatusCode:      200,
+		ResponseHeaders: `{"Content-Type": ["application/json"]}`,
+		ResponseBody:    `{"id": 42}`,
+	}
+
+	sample, ok := executionSample(request, execution)
+	require.True(t, ok)
+	assert.Equal(t, "GET", sample.method)
+	assert.Equal(t, "/orders/{orderId}", sample.pathTemplate)
+	assert.Equal(t, map[string]interface{}{"id": float64(42)}, sample.body)
+	assert.Equal(t, ViolationSourceExecution, sample.source)
+	assert.Nil(t, sample.traceID)
+
+	execution.ResponseHeaders = `{"Content-Type": ["text/html"]}`
+	_, ok = executionSample(request, execution)
+	assert.False(t, ok, "only JSON bodies are learned")
+
+	execution.ResponseHeaders, execution.StatusCode = "", 0
+	_, ok = executionSample(request, execution)
+	assert.False(t, ok, "failed executions have no response")
+}
+
+func TestSchemaInferenceService_ObserveSpans(t *testing.T) {
+	db, mock := setupTestDBRequest(t)
+	service := NewSchemaInferenceService(db)
+	workspaceID := uuid.New()
+
+	spans := []models.Span{
+		{ID: uuid.New(), TraceID: uuid.New(), Tags: `{"http.method": "GET", "http.url": "http://orders/orders/1", "http.status_code": 200, "http.response.body": "{\"id\":1}"}`},
+		{ID: uuid.New(), TraceID: uuid.New(), Tags: `{"http.method": "GET", "http.url": "http://orders/orders/2", "http.status_code": 200, "http.response.body": "{\"id\":2}"}`},
+		{ID: uuid.New(), TraceID: uuid.New(), Tags: `{"http.method": "GET", "http.url": "http://orders/health", "http.status_code": 200, "http.response.body": "ok"}`},
+	}
+
+	// Both bodies are learned in one transaction holding the endpoint locked
+	mock.ExpectBegin()
+	mock.ExpectQuery(`(?i)SELECT "id" FROM "inferred_schemas" WHERE .* FOR UPDATE`).
+		WithArgs(workspaceID, "GET", "/orders/{orderId}", 200).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(`(?i)SELECT \* FROM "inferred_schemas"`).
+		WithArgs(workspaceID, "GET", "/orders/{orderId}", 200, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(`(?i)INSERT INTO "inferred_schemas"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	// The second body refines the first version
+	mock.ExpectExec(`(?i)UPDATE "inferred_schemas"`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	require.NoError(t, service.ObserveSpans(workspaceID, spans))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decodeSample(t *testing.T, body string) interface{} {
+	var value interface{}
+	require.NoError(t, json.Unmarshal([]byte(body), &value))
+	return value
+}
+
+func TestStringFormat(t *testing.T) {
+	for value, format := range map[string]string{
+		"6f1c2a4e-8d3b-4c5a-9e7f-0a1b2c3d4e5f": "uuid",
+		"2024-05-01T12:30:00Z":                 "date-time",
+		"2024-05-01":                           "date",
+		"ada@example.com":                      "email",
+		"https://example.com/a":                "uri",
+		"10.0.0.1":                             "ipv4",
+		"hello":                                "",
+		"/relative/path":                       "",
+	} {
+		assert.Equal(t, format, stringFormat(value), value)
+	}
+}
+
+func TestSchemaLearner(t *testing.T) {
+	learner := &schemaLearner{}
+	states := []string{"open", "paid", "shipped"}
+	for i := 0; i < 12; i++ {
+		body := fmt.Sprintf(`{"id": %d, "state": %q, "email": "user%d@example.com", "created_at": "2024-05-01T12:%02d:00Z", "total": %d.5, "items": [{"sku": "A-%d"}]`,
+			i, states[i%3], i, i, i, i)
+		if i%2 == 0 {
+			body += `, "note": "gift"`
+		}
+		learner.observe(decodeSample(t, body+"}"))
+	}
+
+	schema := learner.schema()
+	assert.Equal(t, 12, learner.Samples)
+	assert.Equal(t, "object", schema["type"])
+	assert.Equal(t, []interface{}{"created_at", "email", "id", "items", "state", "total"}, schema["required"], "note is only in half the samples")
+
+	properties := schema["properties"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"type": "integer"}, properties["id"])
+	assert.Equal(t, map[string]interface{}{"type": "number"}, properties["total"])
+	assert.Equal(t, map[string]interface{}{"type": "string", "format": "email"}, properties["email"], "12 distinct emails are not an enum")
+	assert.Equal(t, "date-time", properties["created_at"].(map[string]interface{})["format"])
+	assert.Equal(t, []interface{}{"open", "paid", "shipped"}, properties["state"].(map[string]interface{})["enum"])
+	assert.NotContains(t, properties["note"], "enum", "too few samples for an enum")
+	items := properties["items"].(map[string]interface{})
+	assert.Equal(t, "array", items["type"])
+	assert.Equal(t, []interface{}{"sku"}, items["items"].(map[string]interface{})["required"])
+
+	// A learner stored between observations resumes where it left off
+	encoded, err := json.Marshal(learner)
+	require.NoError(t, err)
+	var resumed schemaLearner
+	require.NoError(t, json.Unmarshal(encoded, &resumed))
+	assert.Equal(t, schemaJSON(schema), schemaJSON(resumed.schema()))
+}
+
+func TestSchemaLearner_MixedValues(t *testing.T) {
+	learner := &schemaLearner{}
+	learner.observe(decodeSample(t, `{"count": 1, "parent": null}`))
+	learner.observe(decodeSample(t, `{"count": 1.5, "parent": "6f1c2a4e-8d3b-4c5a-9e7f-0a1b2c3d4e5f"}`))
+	properties := learner.schema()["properties"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"type": "number"}, properties["count"], "integers fold into numbers")
+	assert.Equal(t, map[string]interface{}{"type": []interface{}{"null", "string"}, "format": "uuid"}, properties["parent"])
+
+	// Objects with too many keys to be records are learned as maps
+	learner = &schemaLearner{}
+	keys := map[string]interface{}{}
+	for i := 0; i <= maxLearnedProperties; i++ {
+		keys[fmt.Sprintf("key-%d", i)] = float64(i)
+	}
+	learner.observe(keys)
+	assert.Equal(t, map[string]interface{}{"type": "object"}, learner.schema())
+	assert.Empty(t, schemaDrift(learner.schema(), map[string]interface{}{"other": true}))
+}
+
+func TestSchemaDrift(t *testing.T) {
+	schema := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal([]byte(`{
+	  "type": "object",
+	  "properties": {
+	    "id": {"type": "integer"},
+	    "total": {"type": "number"},
+	    "state": {"type": "string", "enum": ["open", "paid"]},
+	    "email": {"type": "string", "format": "email"},
+	    "items": {"type": "array", "items": {"type": "object", "properties": {"sku": {"type": "string"}}, "required": ["sku"]}}
+	  },
+	  "required": ["id", "state"]
+	}`), &schema))
+
+	assert.Empty(t, schemaDrift(schema, decodeSample(t, `{"id": 1, "total": 3, "state": "paid", "items": [{"sku": "A"}]}`)),
+		"integers are numbers and optional fields may be left out")
+
+	changes := schemaDrift(schema, decodeSample(t,
+		`{"id": "1", "state": "void", "email": "nobody", "items": [{"qty": 1}], "coupon": null}`))
+	assert.Equal(t, []SchemaDriftChange{
+		{Kind: DriftFieldAdded, Path: "/coupon", Actual: "null"},
+		{Kind: DriftFormatChanged, Path: "/email", Expected: "email", Actual: "nobody"},
+		{Kind: DriftTypeChanged, Path: "/id", Expected: "integer", Actual: "string"},
+		{Kind: DriftFieldMissing, Path: "/items/0/sku"},
+		{Kind: DriftFieldAdded, Path: "/items/0/qty", Actual: "integer"},
+		{Kind: DriftEnumValue, Path: "/state", Expected: []interface{}{"open", "paid"}, Actual: "void"},
+	}, changes)
+
+	changes = schemaDrift(schema, decodeSample(t, `[]`))
+	require.Len(t, changes, 1)
+	assert.Equal(t, SchemaDriftChange{Kind: DriftTypeChanged, Path: "", Expected: "object", Actual: "array"}, changes[0])
+}
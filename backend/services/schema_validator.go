@@ -1,6 +1,8 @@
 package services
 
 import (
+	"backend/models"
+
 	"github.com/xeipuuv/gojsonschema"
 )
 
@@ -10,7 +12,8 @@ func NewSchemaValidator() *SchemaValidator {
 	return &SchemaValidator{}
 }
 
-// ValidateAgainstOpenAPI validates response against OpenAPI schema
+// ValidateAgainstOpenAPI validates response against a bare JSON Schema. Use
+// OpenAPIValidator to validate a call against a whole OpenAPI document.
 func (s *SchemaValidator) ValidateAgainstOpenAPI(responseBody string, schemaJSON string) (*ValidationResult, error) {
 	schemaLoader := gojsonschema.NewStringLoader(schemaJSON)
 	documentLoader := gojsonschema.NewStringLoader(responseBody)
@@ -31,6 +34,7 @@ func (s *SchemaValidator) ValidateAgainstOpenAPI(responseBody string, schemaJSON
 				Field:       err.Field(),
 				Type:        err.Type(),
 				Description: err.Description(),
+				DataPointer: jsonPointer(contextTokens(err.Context())),
 			})
 		}
 	}
@@ -78,12 +82,20 @@ type ValidationResult struct {
 }
 
 type ValidationError struct {
+	In          string `json:"in,omitempty"` // request, response, path, query, header, cookie or response_header
 	Field       string `json:"field"`
 	Type        string `json:"type"`
 	Description string `json:"description"`
+	SpecPointer string `json:"spec_pointer,omitempty"` // JSON pointer into the OpenAPI document
+	DataPointer string `json:"data_pointer,omitempty"` // JSON pointer into the body
 }
 
 type Contract struct {
 	RequestSchema  string `json:"request_schema"`
 	ResponseSchema string `json:"response_schema"`
 }
+
+// ContractFromModel returns the schemas of a stored contract for ValidateContract.
+func ContractFromModel(contract *models.Contract) Contract {
+	return Contract{RequestSchema: contract.RequestSchema, ResponseSchema: contract.ResponseSchema}
+}
@@ -0,0 +1,456 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Kinds of SpecChange.
+const (
+	SpecEndpointRemoved     = "endpoint_removed"
+	SpecEndpointAdded       = "endpoint_added"
+	SpecParameterAdded      = "parameter_added"
+	SpecParameterRemoved    = "parameter_removed"
+	SpecParameterRequired   = "parameter_required"
+	SpecParameterOptional   = "parameter_optional"
+	SpecRequestBodyRequired = "request_body_required"
+	SpecMediaTypeRemoved    = "media_type_removed"
+	SpecMediaTypeAdded      = "media_type_added"
+	SpecResponseRemoved     = "response_removed"
+	SpecResponseAdded       = "response_added"
+	SpecTypeChanged         = "type_changed"
+	SpecEnumNarrowed        = "enum_narrowed"
+	SpecEnumWidened         = "enum_widened"
+	SpecFieldAdded          = "field_added"
+	SpecFieldRemoved        = "field_removed"
+	SpecFieldRequired       = "field_required"
+	SpecFieldOptional       = "field_optional"
+)
+
+// maxSchemaDiffDepth stops comparing schemas nested deeper than this.
+const maxSchemaDiffDepth = 32
+
+// SpecDiff is what changed from a base version of a spec to a target one.
+type SpecDiff struct {
+	BaseSpecID        uuid.UUID    `json:"base_spec_id"`
+	TargetSpecID      uuid.UUID    `json:"target_spec_id"`
+	BaseVersion       string       `json:"base_version"`
+	TargetVersion     string       `json:"target_version"`
+	Breaking          int          `json:"breaking"`
+	NonBreaking       int          `json:"non_breaking"`
+	ImpactedEndpoints int          `json:"impacted_endpoints"` // endpoints with breaking changes that saw traffic since Since
+	Since             time.Time    `json:"since"`
+	Changes           []SpecChange `json:"changes"`
+}
+
+// SpecChange is one difference between two versions of a spec. Method and
+// Path name the endpoint as the base version documents it, or the target
+// version for added endpoints.
+type SpecChange struct {
+	Kind        string           `json:"kind"`
+	Breaking    bool             `json:"breaking"`
+	Method      string           `json:"method"`
+	Path        string           `json:"path"`
+	Location    string           `json:"location,omitempty"` // e.g. query parameter limit, request body, response 200 body
+	Field       string           `json:"field,omitempty"`    // dotted path within a schema, [] for array items
+	Description string           `json:"description"`
+	Base        interface{}      `json:"base,omitempty"`
+	Target      interface{}      `json:"target,omitempty"`
+	Impact      *EndpointTraffic `json:"impact,omitempty"` // breaking changes only
+}
+
+// EndpointTraffic is how often an endpoint was called recently, as seen in
+// executions and ingested spans.
+type EndpointTraffic struct {
+	Executions int        `json:"executions"`
+	Spans      int        `json:"spans"`
+	LastSeen   *time.Time `json:"last_seen,omitempty"`
+}
+
+// DiffOpenAPI compares two versions of an OpenAPI document and classifies
+// every change as breaking or not for existing consumers. What breaks
+// depends on direction: a request may accept more than before and a
+// response may promise more, but not the other way round. Endpoints are
+// matched by method and path template, ignoring path parameter names.
+func DiffOpenAPI(base, target *OpenAPIDocument) ([]SpecChange, error) {
+	baseOperations, err := specOperations(base)
+	if err != nil {
+		return nil, fmt.Errorf("base: %w", err)
+	}
+	targetOperations, err := specOperations(target)
+	if err != nil {
+		return nil, fmt.Errorf("target: %w", err)
+	}
+
+	changes := []SpecChange{}
+	for key, baseOperation := range baseOperations {
+		d := &specDiffer{base: base, target: target, method: baseOperation.Method, path: baseOperation.Path, changes: &changes}
+		targetOperation, ok := targetOperations[key]
+		if !ok {
+			d.add(SpecEndpointRemoved, true, "", "", "endpoint was removed", nil, nil)
+			continue
+		}
+		if err := d.operations(baseOperation, targetOperation); err != nil {
+			return nil, fmt.Errorf("%s %s: %w", baseOperation.Method, baseOperation.Path, err)
+		}
+	}
+	for key, targetOperation := range targetOperations {
+		if _, ok := baseOperations[key]; !ok {
+			d := &specDiffer{method: targetOperation.Method, path: targetOperation.Path, changes: &changes}
+			d.add(SpecEndpointAdded, false, "", "", "endpoint was added", nil, nil)
+		}
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		if changes[i].Path != changes[j].Path {
+			return changes[i].Path < changes[j].Path
+		}
+		return changes[i].Method < changes[j].Method
+	})
+	return changes, nil
+}
+
+// specOperations keys a document's operations by method and path template
+// with parameter names blanked, so /orders/{id} and /orders/{orderId} are
+// the same endpoint.
+func specOperations(doc *OpenAPIDocument) (map[string]OpenAPIOperation, error) {
+	operations, err := doc.Operations()
+	if err != nil {
+		return nil, err
+	}
+	keyed := make(map[string]OpenAPIOperation, len(operations))
+	for _, operation := range operations {
+		keyed[operation.Method+" "+openAPIPathParam.ReplaceAllString(operation.Path, "{}")] = operation
+	}
+	return keyed, nil
+}
+
+// specDiffer collects the changes of one endpoint.
+type specDiffer struct {
+	base, target *OpenAPIDocument
+	method, path string
+	changes      *[]SpecChange
+}
+
+func (d *specDiffer) add(kind string, breaking bool, location, field, description string, base, target interface{}) {
+	*d.changes = append(*d.changes, SpecChange{
+		Kind:        kind,
+		Breaking:    breaking,
+		Method:      d.method,
+		Path:        d.path,
+		Location:    location,
+		Field:       field,
+		Description: description,
+		Base:        base,
+		Target:      target,
+	})
+}
+
+func (d *specDiffer) operations(base, target OpenAPIOperation) error {
+	if err := d.parameters(base.Parameters, target.Parameters); err != nil {
+		return err
+	}
+	if err := d.requestBodies(base.Operation["requestBody"], target.Operation["requestBody"]); err != nil {
+		return err
+	}
+	return d.responses(base.Operation["responses"], target.Operation["responses"])
+}
+
+func (d *specDiffer) parameters(base, target []map[string]interface{}) error {
+	key := func(parameter map[string]interface{}) string {
+		name, _ := parameter["name"].(string)
+		in, _ := parameter["in"].(string)
+		if in == "header" {
+			name = strings.ToLower(name)
+		}
+		return in + " " + name
+	}
+	targetByKey := map[string]map[string]interface{}{}
+	for _, parameter := range target {
+		targetByKey[key(parameter)] = parameter
+	}
+	baseByKey := map[string]map[string]interface{}{}
+	for _, parameter := range base {
+		baseByKey[key(parameter)] = parameter
+	}
+
+	location := func(parameter map[string]interface{}) string {
+		return strings.Replace(key(parameter), " ", " parameter ", 1)
+	}
+
+	for _, parameter := range base {
+		location := location(parameter)
+		required, _ := parameter["required"].(bool)
+		other, ok := targetByKey[key(parameter)]
+		if !ok {
+			d.add(SpecParameterRemoved, false, location, "", location+" was removed", nil, nil)
+			continue
+		}
+		otherRequired, _ := other["required"].(bool)
+		switch {
+		case !required && otherRequired:
+			d.add(SpecParameterRequired, true, location, "", location+" became required", false, true)
+		case required && !otherRequired:
+			d.add(SpecParameterOptional, false, location, "", location+" became optional", true, false)
+		}
+		if err := d.schemas(parameter["schema"], other["schema"], location, true); err != nil {
+			return err
+		}
+	}
+	for _, parameter := range target {
+		if _, ok := baseByKey[key(parameter)]; ok {
+			continue
+		}
+		location := location(parameter)
+		if required, _ := parameter["required"].(bool); required {
+			d.add(SpecParameterAdded, true, location, "", "required "+location+" was added", nil, nil)
+		} else {
+			d.add(SpecParameterAdded, false, location, "", "optional "+location+" was added", nil, nil)
+		}
+	}
+	return nil
+}
+
+func (d *specDiffer) requestBodies(baseNode, targetNode interface{}) error {
+	base, err := d.base.Resolve(baseNode)
+	if err != nil {
+		return err
+	}
+	target, err := d.target.Resolve(targetNode)
+	if err != nil {
+		return err
+	}
+	baseRequired, _ := base["required"].(bool)
+	targetRequired, _ := target["required"].(bool)
+	if target != nil && targetRequired && (base == nil || !baseRequired) {
+		d.add(SpecRequestBodyRequired, true, "request body", "", "request body became required", nil, nil)
+	}
+	if base == nil || target == nil {
+		return nil
+	}
+	return d.content(base, target, "request body", true)
+}
+
+func (d *specDiffer) responses(baseNode, targetNode interface{}) error {
+	base, _ := baseNode.(map[string]interface{})
+	target, _ := targetNode.(map[string]interface{})
+	for _, status := range sortedKeys(base) {
+		location := "response " + status
+		if _, ok := target[status]; !ok {
+			// Consumers depend on the success responses they were promised
+			d.add(SpecResponseRemoved, strings.HasPrefix(status, "2"), location, "", location+" was removed", nil, nil)
+			continue
+		}
+		baseResponse, err := d.base.Resolve(base[status])
+		if err != nil {
+			return err
+		}
+		targetResponse, err := d.target.Resolve(target[status])
+		if err != nil {
+			return err
+		}
+		if err := d.content(baseResponse, targetResponse, location+" body", false); err != nil {
+			return err
+		}
+	}
+	for _, status := range sortedKeys(target) {
+		if _, ok := base[status]; !ok {
+			d.add(SpecResponseAdded, false, "response "+status, "", "response "+status+" was added", nil, nil)
+		}
+	}
+	return nil
+}
+
+// content compares the media types of a request body or response. Removing
+// a media type breaks consumers in both directions.
+func (d *specDiffer) content(base, target map[string]interface{}, location string, request bool) error {
+	baseContent, _ := base["content"].(map[string]interface{})
+	targetContent, _ := target["content"].(map[string]interface{})
+	for _, mediaType := range sortedKeys(baseContent) {
+		targetMedia, ok := targetContent[mediaType].(map[string]interface{})
+		if !ok {
+			d.add(SpecMediaTypeRemoved, true, location, "", fmt.Sprintf("%s no longer accepts %s", location, mediaType), mediaType, nil)
+			continue
+		}
+		baseMedia, _ := baseContent[mediaType].(map[string]interface{})
+		if err := d.schemas(baseMedia["schema"], targetMedia["schema"], location, request); err != nil {
+			return err
+		}
+	}
+	for _, mediaType := range sortedKeys(targetContent) {
+		if _, ok := baseContent[mediaType]; !ok {
+			d.add(SpecMediaTypeAdded, false, location, "", fmt.Sprintf("%s also accepts %s", location, mediaType), nil, mediaType)
+		}
+	}
+	return nil
+}
+
+func (d *specDiffer) schemas(baseNode, targetNode interface{}, location string, request bool) error {
+	if baseNode == nil || targetNode == nil {
+		return nil
+	}
+	base, err := d.base.InlineSchema(baseNode)
+	if err != nil {
+		return err
+	}
+	target, err := d.target.InlineSchema(targetNode)
+	if err != nil {
+		return err
+	}
+	d.schema(base, target, location, "", request, 0)
+	return nil
+}
+
+// schema compares two inlined schemas. For requests, accepting less is
+// breaking; for responses, promising less or returning more kinds of
+// value is.
+func (d *specDiffer) schema(base, target map[string]interface{}, location, field string, request bool, depth int) {
+	if base == nil || target == nil || depth > maxSchemaDiffDepth {
+		return
+	}
+	describe := func(what string) string {
+		if field == "" {
+			return location + " " + what
+		}
+		return location + " field " + field + " " + what
+	}
+
+	baseTypes, targetTypes := diffSchemaTypes(base), diffSchemaTypes(target)
+	if len(baseTypes) > 0 && len(targetTypes) > 0 && !equalStrings(baseTypes, targetTypes) {
+		compatible := typesWithin(baseTypes, targetTypes)
+		if !request {
+			compatible = typesWithin(targetTypes, baseTypes)
+		}
+		d.add(SpecTypeChanged, !compatible, location, field, describe("changed type"), strings.Join(baseTypes, "|"), strings.Join(targetTypes, "|"))
+	}
+
+	baseEnum, baseHasEnum := base["enum"].([]interface{})
+	targetEnum, targetHasEnum := target["enum"].([]interface{})
+	switch {
+	case baseHasEnum && targetHasEnum:
+		removed, added := enumDifference(baseEnum, targetEnum)
+		if len(removed) > 0 {
+			d.add(SpecEnumNarrowed, request, location, field, describe("no longer allows "+strings.Join(removed, ", ")), baseEnum, targetEnum)
+		}
+		if len(added) > 0 {
+			d.add(SpecEnumWidened, !request, location, field, describe("now also allows "+strings.Join(added, ", ")), baseEnum, targetEnum)
+		}
+	case !baseHasEnum && targetHasEnum:
+		d.add(SpecEnumNarrowed, request, location, field, describe("is now limited to an enum"), nil, targetEnum)
+	case baseHasEnum && !targetHasEnum:
+		d.add(SpecEnumWidened, !request, location, field, describe("is no longer limited to an enum"), baseEnum, nil)
+	}
+
+	baseProperties, _ := base["properties"].(map[string]interface{})
+	targetProperties, _ := target["properties"].(map[string]interface{})
+	baseRequired, targetRequired := requiredSet(base), requiredSet(target)
+	for _, name := range sortedKeys(baseProperties) {
+		child := joinSchemaField(field, name)
+		childDescribe := location + " field " + child
+		targetProperty, ok := targetProperties[name].(map[string]interface{})
+		if !ok {
+			// A request field that is dropped is ignored; a response field is missed
+			d.add(SpecFieldRemoved, !request, location, child, childDescribe+" was removed", nil, nil)
+			continue
+		}
+		switch {
+		case !baseRequired[name] && targetRequired[name]:
+			d.add(SpecFieldRequired, request, location, child, childDescribe+" became required", false, true)
+		case baseRequired[name] && !targetRequired[name]:
+			d.add(SpecFieldOptional, !request, location, child, childDescribe+" became optional", true, false)
+		}
+		baseProperty, _ := baseProperties[name].(map[string]interface{})
+		d.schema(baseProperty, targetProperty, location, child, request, depth+1)
+	}
+	for _, name := range sortedKeys(targetProperties) {
+		if _, ok := baseProperties[name]; ok {
+			continue
+		}
+		child := joinSchemaField(field, name)
+		if request && targetRequired[name] {
+			d.add(SpecFieldRequired, true, location, child, location+" field "+child+" was added as required", nil, nil)
+		} else {
+			d.add(SpecFieldAdded, false, location, child, location+" field "+child+" was added", nil, nil)
+		}
+	}
+
+	baseItems, _ := base["items"].(map[string]interface{})
+	targetItems, _ := target["items"].(map[string]interface{})
+	d.schema(baseItems, targetItems, location, field+"[]", request, depth+1)
+}
+
+// diffSchemaTypes returns a schema's types, sorted, with null added for
+// OpenAPI 3.0's nullable.
+func diffSchemaTypes(schema map[string]interface{}) []string {
+	types := append([]string{}, schemaTypes(schema)...)
+	if nullable, _ := schema["nullable"].(bool); nullable && len(types) > 0 {
+		types = append(types, "null")
+	}
+	sort.Strings(types)
+	return types
+}
+
+// typesWithin reports whether every type in inner is allowed by outer, an
+// integer being a number.
+func typesWithin(inner, outer []string) bool {
+	allowed := map[string]bool{}
+	for _, name := range outer {
+		allowed[name] = true
+	}
+	for _, name := range inner {
+		if !allowed[name] && !(name == "integer" && allowed["number"]) {
+			return false
+		}
+	}
+	return true
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// enumDifference lists the values only in base and only in target, as JSON.
+func enumDifference(base, target []interface{}) (removed, added []string) {
+	encode := func(values []interface{}) map[string]bool {
+		set := map[string]bool{}
+		for _, value := range values {
+			encoded, _ := json.Marshal(value)
+			set[string(encoded)] = true
+		}
+		return set
+	}
+	baseSet, targetSet := encode(base), encode(target)
+	for value := range baseSet {
+		if !targetSet[value] {
+			removed = append(removed, value)
+		}
+	}
+	for value := range targetSet {
+		if !baseSet[value] {
+			added = append(added, value)
+		}
+	}
+	sort.Strings(removed)
+	sort.Strings(added)
+	return removed, added
+}
+
+func joinSchemaField(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const specDiffBase = `
+openapi: 3.0.3
+info: {title: Shop, version: 1.0.0}
+paths:
+  /orders:
+    get:
+      parameters:
+        - {name: status, in: query, schema: {type: string, enum: [open, paid, shipped]}}
+        - {name: limit, in: query, schema: {type: integer}}
+      responses:
+        200:
+          description: Orders
+          content:
+            application/json:
+              schema:
+                type: array
+                items: {$ref: '#/components/schemas/Order'}
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+              properties:
+                qty: {type: integer}
+      responses:
+        201: {description: Created}
+  /orders/{id}:
+    delete:
+      responses:
+        204: {description: Deleted}
+components:
+  schemas:
+    Order:
+      type: object
+      required: [id]
+      properties:
+        id: {type: integer}
+        total: {type: number}
+        state: {type: string, enum: [open, paid]}
+`
+
+const specDiffTarget = `
+openapi: 3.0.3
+info: {title: Shop, version: 2.0.0}
+paths:
+  /orders:
+    get:
+      parameters:
+        - {name: status, in: query, schema: {type: string, enum: [open, paid]}}
+        - {name: limit, in: query, schema: {type: number}}
+        - {name: X-Tenant, in: header, required: true, schema: {type: string}}
+      responses:
+        200:
+          description: Orders
+          content:
+            application/json:
+              schema:
+                type: array
+                items: {$ref: '#/components/schemas/Order'}
+    post:
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              type: object
+              required: [qty, currency]
+              properties:
+                qty: {type: integer}
+                currency: {type: string}
+      responses:
+        201: {description: Created}
+        409: {description: Conflict}
+  /orders/{orderId}/items:
+    get:
+      responses:
+        200: {description: Items}
+components:
+  schemas:
+    Order:
+      type: object
+      required: [id]
+      properties:
+        id: {type: string}
+        state: {type: string, enum: [open, paid, refunded]}
+        currency: {type: string}
+`
+
+func diffFixtures(t *testing.T, base, target string) []SpecChange {
+	baseDoc, err := ParseOpenAPI([]byte(base))
+	require.NoError(t, err)
+	targetDoc, err := ParseOpenAPI([]byte(target))
+	require.NoError(t, err)
+	changes, err := DiffOpenAPI(baseDoc, targetDoc)
+	require.NoError(t, err)
+	return changes
+}
+
+func TestDiffOpenAPI(t *testing.T) {
+	changes := diffFixtures(t, specDiffBase, specDiffTarget)
+
+	type summary struct {
+		kind, method, path, location, field string
+		breaking                            bool
+	}
+	var got []summary
+	for _, change := range changes {
+		got = append(got, summary{change.Kind, change.Method, change.Path, change.Location, change.Field, change.Breaking})
+	}
+	assert.Equal(t, []summary{
+		{SpecEnumNarrowed, "GET", "/orders", "query parameter status", "", true},
+		{SpecTypeChanged, "GET", "/orders", "query parameter limit", "", false},
+		{SpecParameterAdded, "GET", "/orders", "header parameter x-tenant", "", true},
+		{SpecTypeChanged, "GET", "/orders", "response 200 body", "[].id", true},
+		{SpecEnumWidened, "GET", "/orders", "response 200 body", "[].state", true},
+		{SpecFieldRemoved, "GET", "/orders", "response 200 body", "[].total", true},
+		{SpecFieldAdded, "GET", "/orders", "response 200 body", "[].currency", false},
+		{SpecRequestBodyRequired, "POST", "/orders", "request body", "", true},
+		{SpecFieldRequired, "POST", "/orders", "request body", "qty", true},
+		{SpecFieldRequired, "POST", "/orders", "request body", "currency", true},
+		{SpecResponseAdded, "POST", "/orders", "response 409", "", false},
+		{SpecEndpointRemoved, "DELETE", "/orders/{id}", "", "", true},
+		{SpecEndpointAdded, "GET", "/orders/{orderId}/items", "", "", false},
+	}, got)
+	assert.Equal(t, "response 200 body field [].state now also allows \"refunded\"", changes[4].Description)
+}
+
+func TestDiffOpenAPI_Direction(t *testing.T) {
+	doc := func(body, response string) string {
+		return `
+openapi: 3.1.0
+info: {title: Shop, version: "1"}
+paths:
+  /pay:
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema: ` + body + `
+      responses:
+        200:
+          description: OK
+          content:
+            application/json:
+              schema: ` + response + `
+`
+	}
+
+	changes := diffFixtures(t,
+		doc(`{type: object, properties: {note: {type: string}}}`, `{type: object, required: [id], properties: {id: {type: integer}}}`),
+		doc(`{type: object, properties: {note: {type: [string, "null"]}}}`, `{type: object, properties: {id: {type: integer}}}`))
+	require.Len(t, changes, 2)
+	assert.Equal(t, SpecTypeChanged, changes[0].Kind)
+	assert.False(t, changes[0].Breaking, "a request accepting more is compatible")
+	assert.Equal(t, SpecFieldOptional, changes[1].Kind)
+	assert.True(t, changes[1].Breaking, "a response field that may be missing breaks consumers")
+
+	changes = diffFixtures(t,
+		doc(`{type: object}`, `{type: number}`),
+		doc(`{type: object}`, `{type: integer}`))
+	require.Len(t, changes, 1)
+	assert.False(t, changes[0].Breaking, "a response returning integers where numbers were promised is compatible")
+
+	assert.Empty(t, diffFixtures(t, specDiffBase, specDiffBase))
+}
+
+func TestMatchSpecEndpoint(t *testing.T) {
+	endpoints := []specEndpoint{{"GET", "/orders/{id}"}, {"GET", "/orders/mine"}, {"POST", "/orders"}}
+
+	endpoint, ok := matchSpecEndpoint(endpoints, "GET", "/api/v1/orders/mine")
+	require.True(t, ok)
+	assert.Equal(t, specEndpoint{"GET", "/orders/mine"}, endpoint)
+
+	endpoint, ok = matchSpecEndpoint(endpoints, "GET", contractURLPath("{{baseUrl}}/orders/{{orderId}}?expand=1"))
+	require.True(t, ok)
+	assert.Equal(t, specEndpoint{"GET", "/orders/{id}"}, endpoint)
+
+	_, ok = matchSpecEndpoint(endpoints, "DELETE", "/orders/1")
+	assert.False(t, ok)
+}
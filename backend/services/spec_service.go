@@ -0,0 +1,394 @@
+package services
+
+import (
+	"backend/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// maxCachedValidators bounds how many parsed specs are kept in memory.
+const maxCachedValidators = 64
+
+// SpecService reads stored API descriptions and validates calls against
+// them.
+type SpecService struct {
+	db               *gorm.DB
+	workspaceService *WorkspaceService
+
+	mu         sync.Mutex
+	validators map[uuid.UUID]*OpenAPIValidator // specs never change, so entries never go stale
+}
+
+func NewSpecService(db *gorm.DB) *SpecService {
+	return &SpecService{
+		db:               db,
+		workspaceService: NewWorkspaceService(db),
+		validators:       map[uuid.UUID]*OpenAPIValidator{},
+	}
+}
+
+// List returns a workspace's specs, newest version first within each name.
+func (s *SpecService) List(workspaceID, userID uuid.UUID) ([]models.APISpec, error) {
+	if !s.workspaceService.HasAccess(workspaceID, userID) {
+		return nil, errors.New("access denied")
+	}
+	var specs []models.APISpec
+	err := s.db.Where("workspace_id = ?", workspaceID).Order("name, created_at DESC").Find(&specs).Error
+	return specs, err
+}
+
+func (s *SpecService) GetByID(workspaceID, specID, userID uuid.UUID) (*models.APISpec, error) {
+	if !s.workspaceService.HasAccess(workspaceID, userID) {
+		return nil, errors.New("access denied")
+	}
+	var spec models.APISpec
+	if err := s.db.Where("id = ? AND workspace_id = ?", specID, workspaceID).First(&spec).Error; err != nil {
+		return nil, err
+	}
+	return &spec, nil
+}
+
+// Validate checks a call against a spec's operations.
+func (s *SpecService) Validate(workspaceID, specID, userID uuid.UUID, exchange OpenAPIExchange) (*OpenAPIValidation, error) {
+	spec, err := s.GetByID(workspaceID, specID, userID)
+	if err != nil {
+		return nil, err
+	}
+	validator, err := s.validator(spec)
+	if err != nil {
+		return nil, err
+	}
+	return validator.Validate(exchange)
+}
+
+// ValidateExecution checks a stored execution, and the request it sent,
+// against a spec's operations.
+func (s *SpecService) ValidateExecution(workspaceID, specID, executionID, userID uuid.UUID) (*OpenAPIValidation, error) {
+	if !s.workspaceService.HasAccess(workspaceID, userID) {
+		return nil, errors.New("access denied")
+	}
+	var execution models.Execution
+	err := s.db.Preload("Request.Collection").
+		Where("id = ?", executionID).First(&execution).Error
+	if err != nil {
+		return nil, err
+	}
+	if execution.Request.Collection.WorkspaceID != workspaceID {
+		return nil, errors.New("execution not found")
+	}
+	return s.Validate(workspaceID, specID, userID, executionExchange(&execution.Request, &execution, execution.Request.URL))
+}
+
+// validatorFor returns the validator of a spec without an access check,
+// for checks that run on the workspace's own traffic.
+func (s *SpecService) validatorFor(specID uuid.UUID) (*OpenAPIValidator, error) {
+	s.mu.Lock()
+	validator, ok := s.validators[specID]
+	s.mu.Unlock()
+	if ok {
+		return validator, nil
+	}
+	var spec models.APISpec
+	if err := s.db.First(&spec, "id = ?", specID).Error; err != nil {
+		return nil, err
+	}
+	return s.validator(&spec)
+}
+
+func (s *SpecService) validator(spec *models.APISpec) (*OpenAPIValidator, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if validator, ok := s.validators[spec.ID]; ok {
+		return validator, nil
+	}
+
+	doc, err := ParseOpenAPI([]byte(spec.Content))
+	if err != nil {
+		return nil, err
+	}
+	validator, err := NewOpenAPIValidator(doc)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.validators) >= maxCachedValidators {
+		s.validators = map[uuid.UUID]*OpenAPIValidator{}
+	}
+	s.validators[spec.ID] = validator
+	return validator, nil
+}
+
+// executionExchange describes an execution of request as a call to
+// validate. rawURL is the URL the request was sent to, before its query
+// params were added.
+func executionExchange(request *models.Request, execution *models.Execution, rawURL string) OpenAPIExchange {
+	if withQuery, err := applyQueryParams(rawURL, request.QueryParams); err == nil {
+		rawURL = withQuery
+	}
+	exchange := OpenAPIExchange{
+		Method:          request.Method,
+		URL:             rawURL,
+		RequestHeaders:  http.Header{},
+		StatusCode:      execution.StatusCode,
+		ResponseHeaders: http.Header{},
+		ResponseBody:    execution.ResponseBody,
+	}
+	for key, value := range requestHeaders(request) {
+		exchange.RequestHeaders.Add(key, value)
+	}
+
+	switch request.BodyMode {
+	case BodyModeFormData, BodyModeBinary:
+		// The body is not captured, so neither are the headers, which
+		// keeps a required body from being reported missing
+		exchange.RequestHeaders = nil
+	default:
+		// The remaining modes are encoded without touching the service
+		body, contentType, err := (&RequestService{}).buildRequestBody(request)
+		if err == nil {
+			exchange.RequestBody = string(body)
+			if contentType != "" && len(headerValues(exchange.RequestHeaders, "Content-Type")) == 0 {
+				exchange.RequestHeaders.Set("Content-Type", contentType)
+			}
+		}
+	}
+	if execution.ResponseHeaders != "" {
+		json.Unmarshal([]byte(execution.ResponseHeaders), &exchange.ResponseHeaders)
+	}
+	return exchange
+}
+
+// maxTrafficSpans bounds how many recent HTTP spans are read to measure an
+// endpoint's traffic.
+const maxTrafficSpans = 50000
+
+// Create stores a new version of a spec from an OpenAPI document. Versions
+// of the same spec share its info.title.
+func (s *SpecService) Create(workspaceID, userID uuid.UUID, data []byte) (*models.APISpec, error) {
+	if !s.workspaceService.HasAccess(workspaceID, userID) {
+		return nil, errors.New("access denied")
+	}
+	doc, err := ParseOpenAPI(data)
+	if err != nil {
+		return nil, err
+	}
+	operations, err := doc.Operations()
+	if err != nil {
+		return nil, err
+	}
+	name := doc.Info("title")
+	if name == "" {
+		return nil, errors.New("OpenAPI document has no info.title to name the spec by")
+	}
+
+	content, _ := json.Marshal(doc.Root)
+	spec := &models.APISpec{
+		WorkspaceID:    workspaceID,
+		Name:           name,
+		Version:        doc.Info("version"),
+		Format:         "openapi",
+		FormatVersion:  doc.Version,
+		OperationCount: len(operations),
+		Content:        string(content),
+		CreatedBy:      userID,
+	}
+	if err := s.db.Create(spec).Error; err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// Diff compares a spec with a base version, by default the version of the
+// same spec stored before it. Breaking changes are annotated with how often
+// their endpoint was called since the given time.
+func (s *SpecService) Diff(workspaceID, specID, userID uuid.UUID, baseID *uuid.UUID, since time.Time) (*SpecDiff, error) {
+	target, err := s.GetByID(workspaceID, specID, userID)
+	if err != nil {
+		return nil, err
+	}
+	var base *models.APISpec
+	if baseID != nil {
+		if base, err = s.GetByID(workspaceID, *baseID, userID); err != nil {
+			return nil, err
+		}
+	} else {
+		var previous models.APISpec
+		err := s.db.Where("workspace_id = ? AND name = ? AND created_at < ?", workspaceID, target.Name, target.CreatedAt).
+			Order("created_at DESC").First(&previous).Error
+		if err != nil {
+			return nil, fmt.Errorf("%s has no earlier version to compare with", target.Name)
+		}
+		base = &previous
+	}
+
+	baseDoc, err := ParseOpenAPI([]byte(base.Content))
+	if err != nil {
+		return nil, err
+	}
+	targetDoc, err := ParseOpenAPI([]byte(target.Content))
+	if err != nil {
+		return nil, err
+	}
+	changes, err := DiffOpenAPI(baseDoc, targetDoc)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &SpecDiff{
+		BaseSpecID:    base.ID,
+		TargetSpecID:  target.ID,
+		BaseVersion:   base.Version,
+		TargetVersion: target.Version,
+		Since:         since,
+		Changes:       changes,
+	}
+	var broken []specEndpoint
+	seen := map[specEndpoint]bool{}
+	for _, change := range changes {
+		if !change.Breaking {
+			diff.NonBreaking++
+			continue
+		}
+		diff.Breaking++
+		endpoint := specEndpoint{change.Method, change.Path}
+		if !seen[endpoint] {
+			seen[endpoint] = true
+			broken = append(broken, endpoint)
+		}
+	}
+	if len(broken) == 0 {
+		return diff, nil
+	}
+
+	endpoints, err := specEndpoints(baseDoc)
+	if err != nil {
+		return nil, err
+	}
+	traffic, err := s.endpointTraffic(workspaceID, endpoints, since)
+	if err != nil {
+		return nil, err
+	}
+	for i := range diff.Changes {
+		change := &diff.Changes[i]
+		if !change.Breaking {
+			continue
+		}
+		impact := traffic[specEndpoint{change.Method, change.Path}]
+		if impact == nil {
+			impact = &EndpointTraffic{}
+		}
+		change.Impact = impact
+	}
+	for _, endpoint := range broken {
+		if traffic[endpoint] != nil {
+			diff.ImpactedEndpoints++
+		}
+	}
+	return diff, nil
+}
+
+type specEndpoint struct {
+	method, path string
+}
+
+func specEndpoints(doc *OpenAPIDocument) ([]specEndpoint, error) {
+	operations, err := doc.Operations()
+	if err != nil {
+		return nil, err
+	}
+	endpoints := make([]specEndpoint, len(operations))
+	for i, operation := range operations {
+		endpoints[i] = specEndpoint{operation.Method, operation.Path}
+	}
+	return endpoints, nil
+}
+
+// endpointTraffic counts the executions and HTTP spans since a time that
+// called each endpoint. A call is counted against the most specific
+// endpoint it matches; endpoints without calls are left out. Retry attempts
+// and the spans Tracely traces its own executions with are not counted
+// again.
+func (s *SpecService) endpointTraffic(workspaceID uuid.UUID, endpoints []specEndpoint, since time.Time) (map[specEndpoint]*EndpointTraffic, error) {
+	traffic := map[specEndpoint]*EndpointTraffic{}
+	record := func(method, rawURL string, count int, at time.Time, spans bool) {
+		endpoint, ok := matchSpecEndpoint(endpoints, method, contractURLPath(rawURL))
+		if !ok {
+			return
+		}
+		entry := traffic[endpoint]
+		if entry == nil {
+			entry = &EndpointTraffic{}
+			traffic[endpoint] = entry
+		}
+		if spans {
+			entry.Spans += count
+		} else {
+			entry.Executions += count
+		}
+		if entry.LastSeen == nil || at.After(*entry.LastSeen) {
+			last := at
+			entry.LastSeen = &last
+		}
+	}
+
+	var executions []struct {
+		Method   string
+		URL      string
+		Count    int
+		LastSeen time.Time
+	}
+	err := s.db.Table("executions").
+		Select("requests.method, requests.url, COUNT(executions.id) AS count, MAX(executions.timestamp) AS last_seen").
+		Joins("JOIN requests ON requests.id = executions.request_id").
+		Joins("JOIN collections ON collections.id = requests.collection_id").
+		Where("collections.workspace_id = ? AND executions.timestamp >= ? AND executions.deleted_at IS NULL", workspaceID, since).
+		Where("executions.parent_execution_id IS NULL").
+		Group("requests.id, requests.method, requests.url").
+		Scan(&executions).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range executions {
+		record(strings.ToUpper(row.Method), row.URL, row.Count, row.LastSeen, false)
+	}
+
+	var spans []models.Span
+	err = s.db.Joins("JOIN traces ON traces.id = spans.trace_id").
+		Where("traces.workspace_id = ? AND spans.start_time >= ? AND spans.service_name <> ?", workspaceID, since, ClientServiceName).
+		Where("spans.tags ->> 'http.method' IS NOT NULL OR spans.tags ->> 'http.request.method' IS NOT NULL").
+		Select("spans.tags, spans.start_time").
+		Order("spans.start_time DESC").Limit(maxTrafficSpans).
+		Find(&spans).Error
+	if err != nil {
+		return nil, err
+	}
+	for i := range spans {
+		if method, rawURL := spanEndpoint(spanTags(&spans[i])); method != "" {
+			record(method, rawURL, 1, spans[i].StartTime, true)
+		}
+	}
+	return traffic, nil
+}
+
+// matchSpecEndpoint finds the endpoint a call was made to. Paths may carry
+// a base path, and literal segments beat templated ones.
+func matchSpecEndpoint(endpoints []specEndpoint, method, urlPath string) (specEndpoint, bool) {
+	var best specEndpoint
+	bestLiteral := -1
+	for _, endpoint := range endpoints {
+		if endpoint.method != method {
+			continue
+		}
+		if _, literal, ok := matchPathTemplate(endpoint.path, urlPath, true); ok && literal > bestLiteral {
+			best, bestLiteral = endpoint, literal
+		}
+	}
+	return best, bestLiteral >= 0
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"backend/models"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecutionExchange(t *testing.T) {
+	request := &models.Request{
+		Method:      "POST",
+		Headers:     `{"X-Tenant":"acme"}`,
+		QueryParams: `{"dry_run":"true"}`,
+		BodyMode:    BodyModeURLEncoded,
+		Body:        `[{"key":"qty","value":"2"},{"key":"note","value":"gift","enabled":false}]`,
+	}
+	execution := &models.Execution{StatusCode: 201, ResponseHeaders: `{"Location":["/orders/7"]}`, ResponseBody: `{"id":7}`}
+
+	exchange := executionExchange(request, execution, "https://shop.test/orders")
+	assert.Equal(t, "https://shop.test/orders?dry_run=true", exchange.URL)
+	assert.Equal(t, "qty=2", exchange.RequestBody)
+	assert.Equal(t, http.Header{"X-Tenant": {"acme"}, "Content-Type": {"application/x-www-form-urlencoded"}}, exchange.RequestHeaders)
+	assert.Equal(t, http.Header{"Location": {"/orders/7"}}, exchange.ResponseHeaders)
+	assert.Equal(t, 201, exchange.StatusCode)
+
+	request.BodyMode = BodyModeFormData
+	assert.Nil(t, executionExchange(request, execution, "/orders").RequestHeaders, "uncaptured multipart bodies leave the headers out")
+}
+
+func TestSpecService_EndpointTraffic_SkipsAttemptsAndOwnSpans(t *testing.T) {
+	db, mock := setupTestDBRequest(t)
+	service := NewSpecService(db)
+	workspaceID := uuid.New()
+	since := time.Now().Add(-time.Hour)
+	seen := time.Now()
+
+	mock.ExpectQuery(`(?i)SELECT requests.method, .* FROM "executions" .* WHERE \(collections.workspace_id = \$1 AND executions.timestamp >= \$2 AND executions.deleted_at IS NULL\) AND executions.parent_execution_id IS NULL`).
+		WithArgs(workspaceID, since).
+		WillReturnRows(sqlmock.NewRows([]string{"method", "url", "count", "last_seen"}).
+			AddRow("get", "https://shop.test/orders/7", 2, seen))
+	mock.ExpectQuery(`(?i)SELECT spans.tags, spans.start_time FROM "spans" .* WHERE \(traces.workspace_id = \$1 AND spans.start_time >= \$2 AND spans.service_name <> \$3\)`).
+		WithArgs(workspaceID, since, ClientServiceName, maxTrafficSpans).
+		WillReturnRows(sqlmock.NewRows([]string{"tags", "start_time"}).
+			AddRow(`{"http.method": "GET", "http.url": "http://orders/orders/8"}`, seen))
+
+	endpoint := specEndpoint{"GET", "/orders/{id}"}
+	traffic, err := service.endpointTraffic(workspaceID, []specEndpoint{endpoint}, since)
+	require.NoError(t, err)
+	require.Contains(t, traffic, endpoint)
+	assert.Equal(t, 2, traffic[endpoint].Executions)
+	assert.Equal(t, 1, traffic[endpoint].Spans)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -28,9 +28,16 @@ func (g *TestDataGenerator) GenerateFromSchema(schemaJSON string) (string, error
 }
 
 func (g *TestDataGenerator) generateFromSchemaObject(schema map[string]interface{}) interface{} {
+	if enum, ok := schema["enum"].([]interface{}); ok && len(enum) > 0 {
+		return enum[rand.Intn(len(enum))]
+	}
+
 	schemaType, ok := schema["type"].(string)
 	if !ok {
-		return nil
+		if _, hasProperties := schema["properties"]; !hasProperties {
+			return nil
+		}
+		schemaType = "object"
 	}
 
 	switch schemaType {
@@ -76,6 +83,10 @@ func (g *TestDataGenerator) generateArray(schema map[string]interface{}) []inter
 		maxItems = int(max)
 	}
 
+	if maxItems < minItems {
+		maxItems = minItems
+	}
+
 	count := minItems + rand.Intn(maxItems-minItems+1)
 	arr := make([]interface{}, count)
 
@@ -107,11 +118,6 @@ func (g *TestDataGenerator) generateString(schema map[string]interface{}) string
 		}
 	}
 
-	// Check for pattern or enum
-	if enum, ok := schema["enum"].([]interface{}); ok {
-		return enum[rand.Intn(len(enum))].(string)
-	}
-
 	// Default string
 	minLength := 5
 	maxLength := 20
@@ -121,6 +127,9 @@ func (g *TestDataGenerator) generateString(schema map[string]interface{}) string
 	if max, ok := schema["maxLength"].(float64); ok {
 		maxLength = int(max)
 	}
+	if maxLength < minLength {
+		maxLength = minLength
+	}
 
 	return gofakeit.LetterN(uint(minLength + rand.Intn(maxLength-minLength+1)))
 }
@@ -135,6 +144,9 @@ func (g *TestDataGenerator) generateInteger(schema map[string]interface{}) int {
 	if maximum, ok := schema["maximum"].(float64); ok {
 		max = int(maximum)
 	}
+	if max < min {
+		max = min
+	}
 
 	return min + rand.Intn(max-min+1)
 }
@@ -149,6 +161,9 @@ func (g *TestDataGenerator) generateNumber(schema map[string]interface{}) float6
 	if maximum, ok := schema["maximum"].(float64); ok {
 		max = maximum
 	}
+	if max < min {
+		max = min
+	}
 
 	return min + rand.Float64()*(max-min)
 }
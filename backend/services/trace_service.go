@@ -8,17 +8,22 @@ import (
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type TraceService struct {
 	db               *gorm.DB
 	workspaceService *WorkspaceService
+	contracts        *ContractService
+	schemas          *SchemaInferenceService
 }
 
 func NewTraceService(db *gorm.DB) *TraceService {
 	return &TraceService{
 		db:               db,
 		workspaceService: NewWorkspaceService(db),
+		contracts:        NewContractService(db),
+		schemas:          NewSchemaInferenceService(db),
 	}
 }
 
@@ -56,6 +61,15 @@ func (s *TraceService) AddSpan(traceID uuid.UUID, parentSpanID *uuid.UUID, opera
 	if err := s.db.Create(&span).Error; err != nil {
 		return nil, err
 	}
+	// Spans carrying bodies are checked against the contracts of the trace's
+	// workspace and teach its inferred schemas; neither failing fails ingestion
+	if _, ok := spanCall(&span); ok {
+		var trace models.Trace
+		if err := s.db.Select("workspace_id").First(&trace, "id = ?", traceID).Error; err == nil {
+			s.contracts.CheckSpans(trace.WorkspaceID, []models.Span{span})
+			s.schemas.ObserveSpans(trace.WorkspaceID, []models.Span{span})
+		}
+	}
 
 	// Update trace span count and duration
 	s.db.Model(&models.Trace{}).Where("id = ?", traceID).Updates(map[string]interface{}{
@@ -67,6 +81,54 @@ func (s *TraceService) AddSpan(traceID uuid.UUID, parentSpanID *uuid.UUID, opera
 	return &span, nil
 }
 
+// RecordSpan stores a span whose trace and span IDs were assigned by the
+// caller, e.g. propagated in X-Trace-ID/X-Span-ID headers. The trace is
+// created with the first span reported for it and widened to cover later ones.
+func (s *TraceService) RecordSpan(workspaceID uuid.UUID, span *models.Span) error {
+	endTime := span.StartTime.Add(time.Duration(span.DurationMs * float64(time.Millisecond)))
+
+	trace := models.Trace{
+		ID:          span.TraceID,
+		WorkspaceID: workspaceID,
+		ServiceName: span.ServiceName,
+		StartTime:   span.StartTime,
+		EndTime:     endTime,
+		Status:      "success",
+	}
+	result := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&trace)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		var existing models.Trace
+		if err := s.db.Select("workspace_id").First(&existing, "id = ?", span.TraceID).Error; err != nil {
+			return err
+		}
+		if existing.WorkspaceID != workspaceID {
+			return errors.New("trace belongs to another workspace")
+		}
+	}
+
+	if err := s.db.Create(span).Error; err != nil {
+		return err
+	}
+	// Spans carrying bodies are checked against contracts and teach the
+	// inferred schemas; neither failing fails ingestion
+	s.contracts.CheckSpans(workspaceID, []models.Span{*span})
+	s.schemas.ObserveSpans(workspaceID, []models.Span{*span})
+
+	updates := map[string]interface{}{
+		"span_count":        gorm.Expr("span_count + ?", 1),
+		"total_duration_ms": gorm.Expr("total_duration_ms + ?", span.DurationMs),
+		"start_time":        gorm.Expr("LEAST(start_time, ?)", span.StartTime),
+		"end_time":          gorm.Expr("GREATEST(end_time, ?)", endTime),
+	}
+	if span.Status == "error" {
+		updates["status"] = "error"
+	}
+	return s.db.Model(&models.Trace{}).Where("id = ?", span.TraceID).Updates(updates).Error
+}
+
 func (s *TraceService) GetTraces(workspaceID, userID uuid.UUID, serviceName string, startTime, endTime *time.Time, limit, offset int) ([]models.Trace, int64, error) {
 	if !s.workspaceService.HasAccess(workspaceID, userID) {
 		return nil, 0, errors.New("access denied")
@@ -68,6 +68,57 @@ func TestTraceService_CreateTrace(t *testing.T) {
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestTraceService_AddSpan_ChecksContractsAndLearnsSchemas(t *testing.T) {
+	db, mock := setupTestDBTrace(t)
+	service := NewTraceService(db)
+
+	traceID := uuid.New()
+	workspaceID := uuid.New()
+	contractID := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "spans" .* RETURNING "id"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`SELECT "workspace_id" FROM "traces" WHERE id = \$1`).
+		WithArgs(traceID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"workspace_id"}).AddRow(workspaceID))
+	mock.ExpectQuery(`SELECT \* FROM "contracts" WHERE workspace_id = \$1 AND \(request_id IS NULL AND method = \$2\)`).
+		WithArgs(workspaceID, "POST").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "workspace_id", "name", "method", "path_template", "status_code", "response_schema"}).
+			AddRow(contractID, workspaceID, "create order", "POST", "/orders", "201", `{"type":"object","required":["id"]}`))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "contract_violations" .* RETURNING "id"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT "id" FROM "inferred_schemas" WHERE .* FOR UPDATE`).
+		WithArgs(workspaceID, "POST", "/orders", 201).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(`SELECT \* FROM "inferred_schemas" WHERE workspace_id = \$1 AND method = \$2`).
+		WithArgs(workspaceID, "POST", "/orders", 201, 1).
+		WillReturnError(gorm.ErrRecordNotFound)
+	mock.ExpectQuery(`INSERT INTO "inferred_schemas" .* RETURNING "id"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "traces" SET`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	tags := map[string]interface{}{
+		"http.method":        "POST",
+		"http.url":           "https://shop.test/orders",
+		"http.status_code":   201,
+		"http.request.body":  `{"qty":2}`,
+		"http.response.body": `{"status":"created"}`,
+	}
+	span, err := service.AddSpan(traceID, nil, "POST /orders", "shop", 12, tags, nil)
+
+	require.NoError(t, err)
+	require.NotNil(t, span)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestTraceService_GetCriticalPath(t *testing.T) {
 	// No DB setup needed for logic-only tests
 	service := &TraceService{}
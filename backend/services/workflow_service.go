@@ -50,10 +50,10 @@ type WorkflowService struct {
 	requestService *RequestService
 }
 
-func NewWorkflowService(db *gorm.DB) *WorkflowService {
+func NewWorkflowService(db *gorm.DB, requestService *RequestService) *WorkflowService {
 	return &WorkflowService{
 		db:             db,
-		requestService: NewRequestService(db),
+		requestService: requestService,
 	}
 }
 
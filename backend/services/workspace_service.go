@@ -85,7 +85,8 @@ func (s *WorkspaceService) GetByID(workspaceID, userID uuid.UUID) (*models.Works
 	return &workspace, nil
 }
 
-func (s *WorkspaceService) Update(workspaceID, userID uuid.UUID, name, description string) (*models.Workspace, error) {
+// Update replaces name and description. Settings are only replaced when non-empty.
+func (s *WorkspaceService) Update(workspaceID, userID uuid.UUID, name, description, settings string) (*models.Workspace, error) {
 	var workspace models.Workspace
 
 	// Check if user is admin
@@ -99,6 +100,9 @@ func (s *WorkspaceService) Update(workspaceID, userID uuid.UUID, name, descripti
 
 	workspace.Name = name
 	workspace.Description = description
+	if settings != "" {
+		workspace.Settings = settings
+	}
 
 	if err := s.db.Save(&workspace).Error; err != nil {
 		return nil, err